@@ -0,0 +1,63 @@
+// Command grant-role assigns a role to (or with -revoke removes it from) the
+// user with the given email. It is used to bootstrap the first admin, since
+// only admins can manage roles through the API.
+package main
+
+import (
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/repository/postgres"
+	"UserRESTfulApi/internal/service"
+	"UserRESTfulApi/pkg/config"
+	"flag"
+	"log"
+
+	gormpostgres "gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func main() {
+	email := flag.String("email", "", "email of the user to update")
+	role := flag.String("role", domain.RoleAdmin, "name of the role to grant")
+	revoke := flag.Bool("revoke", false, "remove the role instead of granting it")
+	flag.Parse()
+
+	if *email == "" {
+		log.Fatal("-email is required")
+	}
+
+	// Load configuration
+	cfg := config.LoadConfig()
+
+	// Initialize database connection
+	db, err := gorm.Open(gormpostgres.Open(cfg.GetDatabaseDSN()), &gorm.Config{})
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+
+	userRepo := postgres.NewUserRepository(db)
+	roleService := service.NewRoleService(postgres.NewRoleRepository(db), userRepo)
+	if err := roleService.EnsureBuiltinRoles(); err != nil {
+		log.Fatalf("Failed to sync built-in roles: %v", err)
+	}
+
+	user, err := userRepo.GetByEmail(*email)
+	if err != nil {
+		log.Fatalf("Failed to look up user: %v", err)
+	}
+	if user == nil {
+		log.Fatalf("No user with email %s", *email)
+	}
+
+	if *revoke {
+		if err := roleService.RemoveFromUser(domain.SystemPrincipal(), user.ID, *role); err != nil {
+			log.Fatalf("Failed to remove role: %v", err)
+		}
+		log.Printf("Removed role %s from %s", *role, *email)
+		return
+	}
+
+	if err := roleService.AssignToUser(domain.SystemPrincipal(), user.ID, *role); err != nil {
+		log.Fatalf("Failed to grant role: %v", err)
+	}
+	log.Printf("Granted role %s to %s", *role, *email)
+}
@@ -0,0 +1,79 @@
+// Command rehash-passwords is a one-off migration that finds rows in the
+// users table whose password column does not hold a hash in any supported
+// format (see password.IsHash), i.e. values stored in plaintext before
+// hashing was enforced, and replaces them with a hash of the stored value
+// using the configured password hasher.
+package main
+
+import (
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/pkg/config"
+	"UserRESTfulApi/pkg/password"
+	"flag"
+	"log"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "report affected users without updating them")
+	batchSize := flag.Int("batch-size", 500, "number of users loaded per batch")
+	flag.Parse()
+
+	// Load configuration
+	cfg := config.LoadConfig()
+
+	hasher, err := password.NewHasher(cfg.Password)
+	if err != nil {
+		log.Fatalf("Failed to initialize password hasher: %v", err)
+	}
+
+	// Initialize database connection
+	db, err := gorm.Open(postgres.Open(cfg.GetDatabaseDSN()), &gorm.Config{})
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+
+	var scanned, rehashed int
+	var users []domain.User
+	result := db.Model(&domain.User{}).Order("id").FindInBatches(&users, *batchSize, func(tx *gorm.DB, batch int) error {
+		for _, user := range users {
+			scanned++
+			if password.IsHash(user.Password) {
+				continue
+			}
+
+			log.Printf("User %d has a plaintext password value", user.ID)
+			if *dryRun {
+				rehashed++
+				continue
+			}
+
+			hashed, err := hasher.Hash(user.Password)
+			if err != nil {
+				return err
+			}
+
+			// Only replace the value we read so a concurrent password change is
+			// never overwritten, and advance the version so cached ETags go stale
+			update := db.Model(&domain.User{}).
+				Where("id = ? AND password = ?", user.ID, user.Password).
+				Updates(map[string]interface{}{"password": hashed, "version": gorm.Expr("version + 1")})
+			if update.Error != nil {
+				return update.Error
+			}
+			rehashed += int(update.RowsAffected)
+		}
+		return nil
+	})
+	if result.Error != nil {
+		log.Fatalf("Failed to rehash passwords: %v", result.Error)
+	}
+
+	if *dryRun {
+		log.Printf("Scanned %d users, %d would be rehashed", scanned, rehashed)
+		return
+	}
+	log.Printf("Scanned %d users, rehashed %d", scanned, rehashed)
+}
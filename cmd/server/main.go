@@ -29,7 +29,10 @@ func main() {
 	}
 
 	// Initialize router
-	router := internal.NewRouter(db)
+	router, err := internal.NewRouter(db, cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize router: %v", err)
+	}
 
 	// Start server
 	log.Printf("Server starting on port %s", port)
@@ -0,0 +1,90 @@
+package domain
+
+import (
+	"net/netip"
+	"time"
+)
+
+// APIKey grants machine-to-machine access without a user session. Only the
+// public prefix and the SHA-256 hash of the key are stored. A key either acts
+// as its owning user, limited to its scopes, or belongs to a service account
+// and holds exactly its scopes.
+type APIKey struct {
+	ID         uint         `json:"id" gorm:"primaryKey"`
+	UserID     *uint        `json:"user_id,omitempty" gorm:"index"` // nil for service account keys
+	Name       string       `json:"name" gorm:"not null"`
+	Prefix     string       `json:"prefix" gorm:"not null;uniqueIndex"`
+	KeyHash    string       `json:"-" gorm:"not null"`
+	Scopes     []Permission `json:"scopes" gorm:"serializer:json;type:jsonb;not null"`
+	AllowedIPs []string     `json:"allowed_ips,omitempty" gorm:"serializer:json;type:jsonb;not null"` // IPs or CIDR ranges, empty allows any
+	ExpiresAt  *time.Time   `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time   `json:"last_used_at,omitempty"`
+	LastUsedIP string       `json:"last_used_ip,omitempty" gorm:"not null;default:''"`
+	CreatedBy  uint         `json:"created_by" gorm:"not null"`
+	CreatedAt  time.Time    `json:"created_at"`
+}
+
+// IsServiceAccount reports whether the key belongs to no user
+func (k *APIKey) IsServiceAccount() bool {
+	return k.UserID == nil
+}
+
+// IsExpired reports whether the key has expired at the given time
+func (k *APIKey) IsExpired(now time.Time) bool {
+	return k.ExpiresAt != nil && !now.Before(*k.ExpiresAt)
+}
+
+// AllowsIP reports whether the key may be used from the client IP
+func (k *APIKey) AllowsIP(clientIP string) bool {
+	if len(k.AllowedIPs) == 0 {
+		return true
+	}
+	addr, err := netip.ParseAddr(clientIP)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, allowed := range k.AllowedIPs {
+		if prefix, err := ParseIPRange(allowed); err == nil && prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseIPRange parses an entry of an IP allowlist, either a single address or a CIDR range
+func ParseIPRange(value string) (netip.Prefix, error) {
+	if addr, err := netip.ParseAddr(value); err == nil {
+		addr = addr.Unmap()
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+	prefix, err := netip.ParsePrefix(value)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return prefix.Masked(), nil
+}
+
+// APIKeyService defines the interface for API key management
+type APIKeyService interface {
+	// Create issues a key for the actor, or a service account key when
+	// key.UserID is nil, and returns the key, which is shown only once
+	Create(actor *Principal, key *APIKey) (string, error)
+	// List returns the actor's keys, or every key for API key managers
+	List(actor *Principal) ([]*APIKey, error)
+	Delete(actor *Principal, id uint) error
+	// Authenticate resolves a key presented from the client IP to the principal it acts as
+	Authenticate(key, clientIP string) (*Principal, error)
+}
+
+// APIKeyRepository defines the interface for API key persistence
+type APIKeyRepository interface {
+	Create(key *APIKey) error
+	Get(id uint) (*APIKey, error)
+	GetByPrefix(prefix string) (*APIKey, error)
+	// List returns the keys of the user, or every key when userID is nil
+	List(userID *uint) ([]*APIKey, error)
+	Delete(id uint) (bool, error)
+	// MarkUsed records when and from where the key was last used
+	MarkUsed(id uint, at time.Time, clientIP string) error
+}
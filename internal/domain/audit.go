@@ -0,0 +1,161 @@
+package domain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// Audited actions
+const (
+	AuditUserCreated            = "user.created"
+	AuditUserUpdated            = "user.updated"
+	AuditUserDeleted            = "user.deleted"
+	AuditLoginSucceeded         = "auth.login.succeeded"
+	AuditLoginFailed            = "auth.login.failed"
+	AuditPasswordResetRequested = "auth.password_reset.requested"
+	AuditPasswordResetCompleted = "auth.password_reset.completed"
+	AuditTokensRevoked          = "auth.tokens.revoked"
+	AuditMagicLinkRequested     = "auth.magic_link.requested"
+	AuditImpersonationStarted   = "auth.impersonation.started"
+	AuditEmailChangeConfirmed   = "user.email_change.confirmed"
+	AuditEmailChangeReverted    = "user.email_change.reverted"
+)
+
+// AuditTargetUser is the target type of events performed on a user
+const AuditTargetUser = "user"
+
+// AuditRedacted replaces the value of secrets in recorded changes
+const AuditRedacted = "[REDACTED]"
+
+// RequestMeta describes the HTTP request an action was performed in
+type RequestMeta struct {
+	ClientIP  string
+	RequestID string
+	UserAgent string
+}
+
+// AuditChange is the value of a field before and after a change. Secrets are
+// replaced with AuditRedacted.
+type AuditChange struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+// AuditEvent is an entry of the append-only audit log. Each event stores the
+// hash of the previous one, so altering or removing an event breaks the chain.
+type AuditEvent struct {
+	ID       uint   `json:"id" gorm:"primaryKey"`
+	Action   string `json:"action" gorm:"not null;index"`
+	ActorID  *uint  `json:"actor_id,omitempty" gorm:"index"` // nil for anonymous requests and service accounts
+	APIKeyID *uint  `json:"api_key_id,omitempty"`
+	// ImpersonatorID is the admin who acted as ActorID with an impersonation token
+	ImpersonatorID *uint                  `json:"impersonator_id,omitempty" gorm:"index"`
+	TargetType     string                 `json:"target_type,omitempty" gorm:"not null;default:''"`
+	TargetID       string                 `json:"target_id,omitempty" gorm:"not null;default:''"`
+	ClientIP       string                 `json:"client_ip,omitempty" gorm:"not null;default:''"`
+	RequestID      string                 `json:"request_id,omitempty" gorm:"not null;default:''"`
+	Changes        map[string]AuditChange `json:"changes,omitempty" gorm:"serializer:json;type:jsonb"`
+	Details        map[string]string      `json:"details,omitempty" gorm:"serializer:json;type:jsonb"`
+	PrevHash       string                 `json:"prev_hash" gorm:"not null"`
+	Hash           string                 `json:"hash" gorm:"not null;uniqueIndex"`
+	CreatedAt      time.Time              `json:"created_at" gorm:"index"`
+}
+
+// NewAuditEvent starts an event for an action performed by the actor, which
+// is nil for anonymous requests, within the request
+func NewAuditEvent(action string, actor *Principal, request RequestMeta) *AuditEvent {
+	event := &AuditEvent{Action: action, ClientIP: request.ClientIP, RequestID: request.RequestID}
+	if actor != nil {
+		if id := actor.ID(); id != 0 {
+			event.ActorID = &id
+		}
+		if actor.APIKeyID != 0 {
+			apiKeyID := actor.APIKeyID
+			event.APIKeyID = &apiKeyID
+		}
+		if actor.Impersonated() {
+			impersonatorID := actor.Impersonator.ID
+			event.ImpersonatorID = &impersonatorID
+		}
+	}
+	return event
+}
+
+// ForUser sets the user the action was performed on
+func (e *AuditEvent) ForUser(id uint) *AuditEvent {
+	e.TargetType = AuditTargetUser
+	e.TargetID = strconv.FormatUint(uint64(id), 10)
+	return e
+}
+
+// Seal chains the event to the previous event's hash and computes its own.
+// CreatedAt is truncated to the precision stored by the database.
+func (e *AuditEvent) Seal(prevHash string, at time.Time) {
+	e.PrevHash = prevHash
+	e.CreatedAt = at.UTC().Truncate(time.Microsecond)
+	e.Hash = e.ComputeHash()
+}
+
+// ComputeHash returns the SHA-256 hash of the event's content and the previous
+// hash. The impersonator is left out when unset, so events recorded before it
+// existed keep their hash.
+func (e *AuditEvent) ComputeHash() string {
+	content, _ := json.Marshal(struct {
+		Action         string                 `json:"action"`
+		ActorID        *uint                  `json:"actor_id"`
+		APIKeyID       *uint                  `json:"api_key_id"`
+		ImpersonatorID *uint                  `json:"impersonator_id,omitempty"`
+		TargetType     string                 `json:"target_type"`
+		TargetID       string                 `json:"target_id"`
+		ClientIP       string                 `json:"client_ip"`
+		RequestID      string                 `json:"request_id"`
+		Changes        map[string]AuditChange `json:"changes"`
+		Details        map[string]string      `json:"details"`
+		PrevHash       string                 `json:"prev_hash"`
+		CreatedAt      string                 `json:"created_at"`
+	}{e.Action, e.ActorID, e.APIKeyID, e.ImpersonatorID, e.TargetType, e.TargetID, e.ClientIP, e.RequestID,
+		e.Changes, e.Details, e.PrevHash, e.CreatedAt.UTC().Format(time.RFC3339Nano)})
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// AuditFilter selects audit events. Zero fields match every event.
+type AuditFilter struct {
+	ActorID        *uint
+	ImpersonatorID *uint
+	TargetType     string
+	TargetID       string
+	Action         string
+	From           *time.Time
+	To             *time.Time
+}
+
+// AuditVerification is the result of checking the hash chain of the audit log
+type AuditVerification struct {
+	Valid    bool  `json:"valid"`
+	Checked  int64 `json:"checked"`
+	BrokenAt *uint `json:"broken_at,omitempty"` // first event whose hash or link does not match
+}
+
+// AuditService records and queries the audit log
+type AuditService interface {
+	// Record appends the event. Failures are logged and do not fail the audited action.
+	Record(event *AuditEvent)
+	List(actor *Principal, filter AuditFilter, page, limit int) ([]*AuditEvent, error)
+	// Verify recomputes the hash chain from the first event
+	Verify(actor *Principal) (*AuditVerification, error)
+}
+
+// AuditRepository defines the interface for audit log persistence. Events
+// can only be appended.
+type AuditRepository interface {
+	// Append seals the event against the latest event and stores it, serialized
+	// with concurrent appends so the chain stays linear
+	Append(event *AuditEvent) error
+	List(filter AuditFilter, page, limit int) ([]*AuditEvent, error)
+	// ListAfter returns up to limit events with an ID above afterID, in ID order
+	ListAfter(afterID uint, limit int) ([]*AuditEvent, error)
+}
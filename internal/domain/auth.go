@@ -0,0 +1,82 @@
+package domain
+
+import "time"
+
+// AccessToken represents the tokens issued after a successful login or refresh
+type AccessToken struct {
+	AccessToken  string    `json:"access_token"`
+	TokenType    string    `json:"token_type"`
+	ExpiresIn    int64     `json:"expires_in"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+}
+
+// MFA methods a login challenge can be completed with
+const (
+	MFAMethodTOTP     = "totp" // TOTP or recovery code
+	MFAMethodWebAuthn = "webauthn"
+)
+
+// LoginResult is the outcome of a correct password. Users with MFA enabled get
+// a challenge token to complete with a second factor instead of tokens.
+type LoginResult struct {
+	Token        *AccessToken
+	MFARequired  bool
+	MFAToken     string
+	MFAExpiresAt time.Time
+	MFAMethods   []string
+}
+
+// RefreshToken represents a stored, single-use refresh token. Only the SHA-256
+// hash of the token is stored. Tokens issued from the same login share a
+// FamilyID so the whole chain can be revoked when reuse is detected.
+type RefreshToken struct {
+	ID        uint       `gorm:"primaryKey"`
+	UserID    uint       `gorm:"not null;index"`
+	FamilyID  string     `gorm:"not null;index"`
+	TokenHash string     `gorm:"not null;uniqueIndex"`
+	ExpiresAt time.Time  `gorm:"not null"`
+	RotatedAt *time.Time // set once the token has been exchanged for a new one
+	RevokedAt *time.Time
+	MFA       bool `gorm:"not null;default:false"` // the login passed a second factor
+	CreatedAt time.Time
+}
+
+// AuthService defines the interface for authentication logic
+type AuthService interface {
+	Login(email, password string, request RequestMeta) (*LoginResult, error)
+	// LoginWithMagicLink signs in with a magic link token, or an emailed code
+	// and the email address it was sent to
+	LoginWithMagicLink(linkToken, email, code string, request RequestMeta) (*LoginResult, error)
+	// LoginWithPasskey signs in with a user verified passkey, which counts as
+	// both factors
+	LoginWithPasskey(assertion *WebAuthnAssertion, request RequestMeta) (*AccessToken, error)
+	// VerifyMFA completes a login that required a second factor
+	VerifyMFA(mfaToken, code string, request RequestMeta) (*AccessToken, error)
+	// BeginPasskeyMFA starts completing a login challenge with a passkey
+	BeginPasskeyMFA(mfaToken string) (*WebAuthnRequestOptions, error)
+	// VerifyPasskeyMFA completes a login that required a second factor with a passkey
+	VerifyPasskeyMFA(mfaToken string, assertion *WebAuthnAssertion, request RequestMeta) (*AccessToken, error)
+	// Authenticate resolves an access token presented from the client IP to its principal
+	Authenticate(accessToken, clientIP string) (*Principal, error)
+	Refresh(refreshToken string, request RequestMeta) (*AccessToken, error)
+	Logout(refreshToken string, request RequestMeta) error
+	// LogoutAll revokes every refresh token of the actor
+	LogoutAll(actor *Principal) error
+	// Impersonate issues a short-lived access token, without a refresh token,
+	// that lets the actor act as the user. The reason is recorded in the audit log.
+	Impersonate(actor *Principal, userID uint, reason string) (*AccessToken, error)
+}
+
+// RefreshTokenRepository defines the interface for refresh token persistence
+type RefreshTokenRepository interface {
+	Create(token *RefreshToken) error
+	GetByHash(tokenHash string) (*RefreshToken, error)
+	// MarkRotated marks an active token as used and reports whether this call
+	// was the one that rotated it, so concurrent exchanges are detected
+	MarkRotated(id uint) (bool, error)
+	// RevokeFamily revokes every token of the family and ends its session
+	RevokeFamily(familyID string) error
+	// RevokeAllForUser revokes every token of the user and ends all their sessions
+	RevokeAllForUser(userID uint) error
+}
@@ -0,0 +1,46 @@
+package domain
+
+import "time"
+
+// EmailChange is a requested change of a user's email address. While it
+// awaits confirmation from the new address, that address is reserved for the
+// user. The old address is mailed a revert link that cancels the change, or
+// undoes it once confirmed, until RevertExpiresAt. Only the SHA-256 hash of
+// the revert token is stored.
+type EmailChange struct {
+	ID              uint      `gorm:"primaryKey"`
+	UserID          uint      `gorm:"not null;index"`
+	OldEmail        string    `gorm:"not null;index"`
+	NewEmail        string    `gorm:"not null;index"`
+	RevertTokenHash string    `gorm:"not null;uniqueIndex"`
+	ExpiresAt       time.Time `gorm:"not null"` // deadline for confirming the new address
+	RevertExpiresAt time.Time `gorm:"not null"`
+	ConfirmedAt     *time.Time
+	RevertedAt      *time.Time
+	CancelledAt     *time.Time // set when a later change replaced it before it was confirmed
+	CreatedAt       time.Time
+}
+
+// EmailChangeRepository defines the interface for email change persistence
+type EmailChangeRepository interface {
+	// Reserve stores the change unless its new address belongs to another user
+	// or is reserved by another user's change, and cancels the user's earlier
+	// unconfirmed changes. Reservations of the same address are serialized
+	// across replicas. It reports whether the change was stored.
+	Reserve(change *EmailChange) (bool, error)
+	// Reserved reports whether a change of another user than userID holds the
+	// address: as the new address of an unexpired unconfirmed change, or as
+	// the old address of a confirmed change that can still be reverted
+	Reserved(email string, userID uint) (bool, error)
+	// GetPending returns the user's unconfirmed change that was neither
+	// reverted nor cancelled, or nil
+	GetPending(userID uint) (*EmailChange, error)
+	GetByRevertHash(tokenHash string) (*EmailChange, error)
+	// MarkConfirmed marks a pending change as confirmed and reports whether this call did so
+	MarkConfirmed(id uint) (bool, error)
+	// MarkReverted marks a change that was neither reverted nor cancelled as
+	// reverted and reports whether this call did so
+	MarkReverted(id uint) (bool, error)
+	// CancelPending cancels the user's unconfirmed changes
+	CancelPending(userID uint) error
+}
@@ -0,0 +1,50 @@
+package domain
+
+import "time"
+
+// EmailVerificationToken represents a single-use token confirming that the
+// user controls Email, which is either their current or their pending
+// address. Only the SHA-256 hash of the token is stored.
+type EmailVerificationToken struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    uint      `gorm:"not null;index"`
+	Email     string    `gorm:"not null"`
+	TokenHash string    `gorm:"not null;uniqueIndex"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// EmailVerificationService defines the interface for email address confirmation
+type EmailVerificationService interface {
+	// Send emails a verification link for the address to the user,
+	// invalidating links sent earlier
+	Send(user *User, email string) error
+	// Verify confirms the address the token was sent to. A confirmed pending
+	// address replaces the user's current one.
+	Verify(verificationToken string) (*User, error)
+	// Resend sends a new link to a user whose address is unconfirmed. It
+	// returns nil for unknown addresses so callers cannot probe for accounts.
+	Resend(email string) error
+	// RequestChange reserves the new address for the user, mails it a
+	// verification link and mails the current address a revert link. It fails
+	// with DuplicateEmail when the address is taken or reserved.
+	RequestChange(user *User, newEmail string) error
+	// Revert cancels the email change the revert token was sent for, or
+	// restores the old address and ends every session if it was confirmed
+	Revert(revertToken string, request RequestMeta) (*User, error)
+	// EmailReserved reports whether another user's email change holds the address
+	EmailReserved(email string, userID uint) (bool, error)
+}
+
+// EmailVerificationRepository defines the interface for email verification token persistence
+type EmailVerificationRepository interface {
+	Create(token *EmailVerificationToken) error
+	GetByHash(tokenHash string) (*EmailVerificationToken, error)
+	// GetLatestForUser returns the most recently issued token of the user, or nil
+	GetLatestForUser(userID uint) (*EmailVerificationToken, error)
+	// MarkUsed marks an unused token as used and reports whether this call did so
+	MarkUsed(id uint) (bool, error)
+	// InvalidateForUser marks every unused token of the user as used
+	InvalidateForUser(userID uint) error
+}
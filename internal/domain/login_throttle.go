@@ -0,0 +1,49 @@
+package domain
+
+import "time"
+
+// Login throttle kinds
+const (
+	ThrottleAccount = "account" // keyed by the normalized login email
+	ThrottleIP      = "ip"      // keyed by the client IP address
+)
+
+// LoginThrottle tracks recent failed logins for an account or a source IP.
+// It is stored in Postgres so every replica behind the load balancer sees
+// the same counters and locks.
+type LoginThrottle struct {
+	Kind          string     `json:"kind" gorm:"primaryKey;type:varchar(16)"`
+	Subject       string     `json:"subject" gorm:"primaryKey;type:varchar(255)"`
+	Failures      int        `json:"failures" gorm:"not null;default:0"`
+	LastFailureAt time.Time  `json:"last_failure_at" gorm:"not null"`
+	LockedUntil   *time.Time `json:"locked_until,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+
+	// BlockedUntil is the earliest time a new attempt is accepted, taking the
+	// lock and the progressive delay into account. It is not stored.
+	BlockedUntil *time.Time `json:"blocked_until,omitempty" gorm:"-"`
+}
+
+// LoginThrottleService defines the interface for failed login tracking
+type LoginThrottleService interface {
+	// Check rejects the attempt when the account or IP is locked or still backing off
+	Check(email, ip string) error
+	RecordFailure(email, ip string) error
+	RecordSuccess(email string) error
+	List(actor *Principal, lockedOnly bool) ([]*LoginThrottle, error)
+	Clear(actor *Principal, kind, subject string) error
+}
+
+// LoginThrottleRepository defines the interface for login throttle persistence
+type LoginThrottleRepository interface {
+	Get(kind, subject string) (*LoginThrottle, error)
+	// RecordFailure atomically counts a failed attempt. The counter restarts
+	// when the previous failure is older than windowStart or its lock has expired.
+	RecordFailure(kind, subject string, now, windowStart time.Time) (*LoginThrottle, error)
+	Lock(kind, subject string, until time.Time) error
+	// List returns throttles with a failure since windowStart or a lock active at now
+	List(windowStart, now time.Time, lockedOnly bool) ([]*LoginThrottle, error)
+	// Delete removes the throttle and reports whether it existed
+	Delete(kind, subject string) (bool, error)
+}
@@ -0,0 +1,48 @@
+package domain
+
+import "time"
+
+// MagicLink is a single-use passwordless sign in sent by email. It can be
+// redeemed once, either through the link carrying its token or by entering
+// its 6 digit code together with the email address. Only hashes are stored.
+type MagicLink struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    uint      `gorm:"not null;index"`
+	TokenHash string    `gorm:"not null;uniqueIndex"`
+	CodeHash  string    `gorm:"not null"`
+	ClientIP  string    `gorm:"not null;default:'';index"` // the IP that requested the link, for rate limiting
+	Attempts  int       `gorm:"not null;default:0"`        // wrong codes entered
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// MagicLinkService defines the interface for passwordless sign in
+type MagicLinkService interface {
+	// Send emails a magic link and code if the address belongs to a user. It
+	// returns nil for unknown addresses and rate limited requests so callers
+	// cannot probe for accounts.
+	Send(email string, request RequestMeta) error
+	// Redeem consumes a magic link token, or else a code sent to the email
+	// address, and returns the user it was sent to
+	Redeem(linkToken, email, code string) (*User, error)
+}
+
+// MagicLinkRepository defines the interface for magic link persistence
+type MagicLinkRepository interface {
+	Create(link *MagicLink) error
+	GetByHash(tokenHash string) (*MagicLink, error)
+	// GetLatestForUser returns the most recently sent link of the user, or nil
+	GetLatestForUser(userID uint) (*MagicLink, error)
+	// CountForUserSince counts the links sent to the user since the given time
+	CountForUserSince(userID uint, since time.Time) (int64, error)
+	// CountForIPSince counts the links requested from the client IP since the given time
+	CountForIPSince(clientIP string, since time.Time) (int64, error)
+	// RecordFailure increments the wrong code counter of a link and returns the new count
+	RecordFailure(id uint) (int, error)
+	// MarkUsed marks an unused link as used and reports whether this call did
+	// so, making redemption single-use across replicas
+	MarkUsed(id uint) (bool, error)
+	// InvalidateForUser marks every unused link of the user as used
+	InvalidateForUser(userID uint) error
+}
@@ -0,0 +1,105 @@
+package domain
+
+import "time"
+
+// MFAFactor represents a user's TOTP authenticator. The secret is stored
+// encrypted and the factor only takes effect once ConfirmedAt is set.
+type MFAFactor struct {
+	ID           uint   `gorm:"primaryKey"`
+	UserID       uint   `gorm:"not null;uniqueIndex"`
+	Secret       string `gorm:"not null"` // encrypted base32 TOTP secret
+	ConfirmedAt  *time.Time
+	LastUsedStep int64 `gorm:"not null;default:0"` // last accepted time step, rejects replayed codes
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// MFARecoveryCode represents a single-use recovery code. Only the SHA-256 hash
+// of the code is stored.
+type MFARecoveryCode struct {
+	ID        uint   `gorm:"primaryKey"`
+	UserID    uint   `gorm:"not null;index"`
+	CodeHash  string `gorm:"not null;uniqueIndex"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// MFAChallenge represents the short-lived token handed out after a correct
+// password, which is exchanged together with a second factor for tokens.
+// Only the SHA-256 hash of the token is stored.
+type MFAChallenge struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    uint      `gorm:"not null;index"`
+	TokenHash string    `gorm:"not null;uniqueIndex"`
+	ExpiresAt time.Time `gorm:"not null"`
+	Attempts  int       `gorm:"not null;default:0"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// MFAEnrollment is returned when a user starts enrolling an authenticator
+type MFAEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+	QRCode []byte `json:"qr_code_png"` // PNG image of URI, base64 encoded in JSON
+}
+
+// MFAStatus describes a user's MFA enrollment
+type MFAStatus struct {
+	Enabled                bool       `json:"enabled"`
+	ConfirmedAt            *time.Time `json:"confirmed_at,omitempty"`
+	RecoveryCodesRemaining int        `json:"recovery_codes_remaining"`
+}
+
+// MFAService defines the interface for TOTP multi-factor authentication
+type MFAService interface {
+	Status(userID uint) (*MFAStatus, error)
+	// Enroll generates a new secret for the user. It replaces an unconfirmed
+	// enrollment and fails if MFA is already enabled.
+	Enroll(user *User) (*MFAEnrollment, error)
+	// Confirm enables MFA after the user proves the authenticator works and
+	// returns the plaintext recovery codes, which are not retrievable later
+	Confirm(userID uint, code string) ([]string, error)
+	// Enabled reports whether the user has a confirmed factor
+	Enabled(userID uint) (bool, error)
+	// StartChallenge issues the token for the second login step
+	StartChallenge(userID uint) (string, time.Time, error)
+	// VerifyChallenge redeems a challenge with a TOTP or recovery code and
+	// returns the user it was issued for. On a wrong code the user ID is
+	// returned along with the error so callers can count the failure.
+	VerifyChallenge(challengeToken, code string) (uint, error)
+	// ChallengeUser returns the user of an open challenge without redeeming it
+	ChallengeUser(challengeToken string) (uint, error)
+	// CompleteChallenge redeems a challenge with a second factor checked by
+	// verify, which counts Unauthorized errors as wrong attempts like VerifyChallenge
+	CompleteChallenge(challengeToken string, verify func(userID uint) error) (uint, error)
+	// Reset removes the user's factor and recovery codes and ends their sessions
+	Reset(actor *Principal, userID uint) error
+}
+
+// MFARepository defines the interface for MFA factor, recovery code and challenge persistence
+type MFARepository interface {
+	GetFactor(userID uint) (*MFAFactor, error)
+	// SaveFactor creates the user's factor or replaces an unconfirmed one
+	SaveFactor(factor *MFAFactor) error
+	// ConfirmFactor enables an unconfirmed factor and reports whether this call did so
+	ConfirmFactor(userID uint, step int64) (bool, error)
+	// UseStep records an accepted time step and reports whether it was newer
+	// than the last one, so a code cannot be replayed across replicas
+	UseStep(userID uint, step int64) (bool, error)
+	// DeleteFactor removes the factor, recovery codes and open challenges of the user
+	DeleteFactor(userID uint) (bool, error)
+
+	// ReplaceRecoveryCodes swaps every recovery code of the user for the given hashes
+	ReplaceRecoveryCodes(userID uint, codeHashes []string) error
+	// UseRecoveryCode marks an unused code as used and reports whether this call did so
+	UseRecoveryCode(userID uint, codeHash string) (bool, error)
+	CountRecoveryCodes(userID uint) (int, error)
+
+	CreateChallenge(challenge *MFAChallenge) error
+	GetChallengeByHash(tokenHash string) (*MFAChallenge, error)
+	// RecordChallengeFailure increments the attempt counter and returns the new count
+	RecordChallengeFailure(id uint) (int, error)
+	// MarkChallengeUsed marks an unused challenge as used and reports whether this call did so
+	MarkChallengeUsed(id uint) (bool, error)
+}
@@ -0,0 +1,344 @@
+package domain
+
+import (
+	"net/url"
+	"strings"
+	"time"
+)
+
+// OAuth 2.0 error codes (RFC 6749 sections 4.1.2.1 and 5.2, RFC 6750 section 3.1)
+const (
+	OAuthInvalidRequest          = "invalid_request"
+	OAuthServerError             = "server_error"
+	OAuthInvalidClient           = "invalid_client"
+	OAuthInvalidGrant            = "invalid_grant"
+	OAuthInvalidScope            = "invalid_scope"
+	OAuthInvalidToken            = "invalid_token"
+	OAuthInsufficientScope       = "insufficient_scope"
+	OAuthUnauthorizedClient      = "unauthorized_client"
+	OAuthUnsupportedGrantType    = "unsupported_grant_type"
+	OAuthUnsupportedResponseType = "unsupported_response_type"
+)
+
+// OAuthError is a protocol error reported to OAuth clients in the
+// {"error": ..., "error_description": ...} format of RFC 6749
+type OAuthError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+func (e *OAuthError) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+// NewOAuthError creates a new OAuth protocol error
+func NewOAuthError(code, description string) *OAuthError {
+	return &OAuthError{Code: code, Description: description}
+}
+
+// Scopes understood by the authorization server
+const (
+	ScopeOpenID        = "openid"
+	ScopeEmail         = "email"
+	ScopeProfile       = "profile"
+	ScopeOfflineAccess = "offline_access" // a refresh token is issued
+)
+
+// SupportedScopes lists every scope a client may request
+var SupportedScopes = []string{ScopeOpenID, ScopeEmail, ScopeProfile, ScopeOfflineAccess}
+
+// Scopes is a space separated list of OAuth scopes
+type Scopes []string
+
+// ParseScopes splits a scope parameter, dropping duplicates
+func ParseScopes(scope string) Scopes {
+	var scopes Scopes
+	for _, s := range strings.Fields(scope) {
+		if !scopes.Has(s) {
+			scopes = append(scopes, s)
+		}
+	}
+	return scopes
+}
+
+// Has reports whether the scope is included
+func (s Scopes) Has(scope string) bool {
+	for _, v := range s {
+		if v == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func (s Scopes) String() string {
+	return strings.Join(s, " ")
+}
+
+// Token kinds issued to OAuth clients
+const (
+	OAuthAccessToken  = "access_token"
+	OAuthRefreshToken = "refresh_token"
+)
+
+// OAuthClient represents an application registered to sign users in through
+// this server. Confidential clients authenticate with a secret of which only
+// the SHA-256 hash is stored; public clients rely on PKCE alone.
+type OAuthClient struct {
+	ID           uint      `json:"-" gorm:"primaryKey"`
+	ClientID     string    `json:"client_id" gorm:"not null;uniqueIndex"`
+	SecretHash   string    `json:"-"`
+	Name         string    `json:"name" gorm:"not null"`
+	RedirectURIs []string  `json:"redirect_uris" gorm:"serializer:json;type:jsonb;not null"`
+	Public       bool      `json:"public" gorm:"not null;default:false"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// TableName keeps GORM from naming the table o_auth_clients
+func (OAuthClient) TableName() string {
+	return "oauth_clients"
+}
+
+// HasRedirectURI reports whether the URI exactly matches a registered redirect URI
+func (c *OAuthClient) HasRedirectURI(uri string) bool {
+	for _, registered := range c.RedirectURIs {
+		if registered == uri {
+			return true
+		}
+	}
+	return false
+}
+
+// AuthorizationRequest holds the parameters of an authorization request
+type AuthorizationRequest struct {
+	ResponseType        string `form:"response_type" json:"response_type"`
+	ClientID            string `form:"client_id" json:"client_id"`
+	RedirectURI         string `form:"redirect_uri" json:"redirect_uri"`
+	Scope               string `form:"scope" json:"scope"`
+	State               string `form:"state" json:"state"`
+	Nonce               string `form:"nonce" json:"nonce"`
+	CodeChallenge       string `form:"code_challenge" json:"code_challenge"`
+	CodeChallengeMethod string `form:"code_challenge_method" json:"code_challenge_method"`
+}
+
+// AuthorizationResponseURI adds the non-empty parameters, such as code and
+// state or error and error_description, to the query of a redirect URI
+func AuthorizationResponseURI(redirectURI string, params map[string]string) string {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		return redirectURI
+	}
+	query := u.Query()
+	for name, value := range params {
+		if value != "" {
+			query.Set(name, value)
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// AuthorizationCode represents a single-use authorization code. Only the
+// SHA-256 hash of the code is stored. Every token issued from the code shares
+// its GrantID, so the grant can be revoked as a whole.
+type AuthorizationCode struct {
+	ID            uint      `gorm:"primaryKey"`
+	CodeHash      string    `gorm:"not null;uniqueIndex"`
+	GrantID       string    `gorm:"not null"`
+	ClientID      string    `gorm:"not null"`
+	UserID        uint      `gorm:"not null;index"`
+	RedirectURI   string    `gorm:"not null"`
+	Scope         string    `gorm:"not null"`
+	Nonce         string    `gorm:"not null;default:''"`
+	CodeChallenge string    `gorm:"not null"`
+	ExpiresAt     time.Time `gorm:"not null"`
+	UsedAt        *time.Time
+	CreatedAt     time.Time
+}
+
+// OAuthToken represents an opaque access or refresh token issued to a client.
+// Only the SHA-256 hash of the token is stored.
+type OAuthToken struct {
+	ID        uint      `gorm:"primaryKey"`
+	TokenHash string    `gorm:"not null;uniqueIndex"`
+	Kind      string    `gorm:"not null"` // OAuthAccessToken or OAuthRefreshToken
+	GrantID   string    `gorm:"not null;index"`
+	ClientID  string    `gorm:"not null"`
+	UserID    uint      `gorm:"not null;index"`
+	Scope     string    `gorm:"not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+// TableName keeps GORM from naming the table o_auth_tokens
+func (OAuthToken) TableName() string {
+	return "oauth_tokens"
+}
+
+// TokenRequest holds the parameters of a token endpoint request
+type TokenRequest struct {
+	GrantType    string `form:"grant_type"`
+	Code         string `form:"code"`
+	RedirectURI  string `form:"redirect_uri"`
+	CodeVerifier string `form:"code_verifier"`
+	RefreshToken string `form:"refresh_token"`
+	Scope        string `form:"scope"`
+}
+
+// TokenResponse is returned by the token endpoint
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+	Scope        string `json:"scope"`
+}
+
+// UserInfo holds the claims returned by the userinfo endpoint
+type UserInfo struct {
+	Subject       string `json:"sub"`
+	Email         string `json:"email,omitempty"`
+	EmailVerified *bool  `json:"email_verified,omitempty"`
+	Name          string `json:"name,omitempty"`
+}
+
+// Introspection is the RFC 7662 description of a token. Inactive tokens only
+// report Active.
+type Introspection struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Username  string `json:"username,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+	Subject   string `json:"sub,omitempty"`
+	Audience  string `json:"aud,omitempty"`
+	Issuer    string `json:"iss,omitempty"`
+}
+
+// ProviderMetadata is published at /.well-known/openid-configuration
+type ProviderMetadata struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserInfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	IntrospectionEndpoint             string   `json:"introspection_endpoint"`
+	RevocationEndpoint                string   `json:"revocation_endpoint"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+}
+
+// SigningKey represents an RSA key pair signing ID tokens. The private key is
+// stored encrypted. The newest key without RetiredAt signs new tokens; retired
+// keys stay published for a while so tokens they signed can still be verified.
+type SigningKey struct {
+	ID         string     `json:"kid" gorm:"primaryKey"`
+	Algorithm  string     `json:"alg" gorm:"not null"`
+	PrivateKey string     `json:"-" gorm:"not null"` // encrypted PEM
+	PublicKey  string     `json:"public_key" gorm:"not null"`
+	RetiredAt  *time.Time `json:"retired_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// JSONWebKey is the public part of a signing key in RFC 7517 format
+type JSONWebKey struct {
+	KeyType   string `json:"kty"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+	Modulus   string `json:"n"`
+	Exponent  string `json:"e"`
+}
+
+// JSONWebKeySet is published at the jwks_uri
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// OAuthClientService defines the interface for managing OAuth clients
+type OAuthClientService interface {
+	// Create registers a client and returns its secret, which is not
+	// retrievable later. Public clients get no secret.
+	Create(actor *Principal, client *OAuthClient) (string, error)
+	List(actor *Principal) ([]*OAuthClient, error)
+	Delete(actor *Principal, clientID string) error
+	// Authenticate verifies the credentials a client presents to the token,
+	// introspection and revocation endpoints
+	Authenticate(clientID, secret string) (*OAuthClient, error)
+}
+
+// SigningKeyService defines the interface for ID token signing keys
+type SigningKeyService interface {
+	// Sign signs the claims with the active key, rotating it first when it is due
+	Sign(claims map[string]interface{}) (string, error)
+	// JWKS returns the public keys of the active and recently retired keys
+	JWKS() (*JSONWebKeySet, error)
+	// Rotate replaces the active key immediately
+	Rotate(actor *Principal) (*SigningKey, error)
+}
+
+// OIDCService defines the interface for the OpenID Connect authorization server
+type OIDCService interface {
+	Metadata() *ProviderMetadata
+	// ValidateAuthorization checks an authorization request. A nil client means
+	// the redirect URI could not be trusted and errors must not be redirected.
+	ValidateAuthorization(req *AuthorizationRequest) (*OAuthClient, error)
+	// Authorize issues an authorization code to the signed in user and returns
+	// the client redirect URI carrying it
+	Authorize(actor *Principal, req *AuthorizationRequest) (string, error)
+	Token(client *OAuthClient, req *TokenRequest) (*TokenResponse, error)
+	UserInfo(accessToken string) (*UserInfo, error)
+	Introspect(client *OAuthClient, token string) (*Introspection, error)
+	// Revoke revokes a token of the client. Revoking a refresh token revokes
+	// every token of its grant. Unknown tokens are ignored.
+	Revoke(client *OAuthClient, token string) error
+}
+
+// OAuthClientRepository defines the interface for OAuth client persistence
+type OAuthClientRepository interface {
+	Create(client *OAuthClient) error
+	GetByClientID(clientID string) (*OAuthClient, error)
+	List() ([]*OAuthClient, error)
+	Delete(clientID string) (bool, error)
+}
+
+// OAuthGrantRepository defines the interface for authorization code and token persistence
+type OAuthGrantRepository interface {
+	CreateCode(code *AuthorizationCode) error
+	GetCodeByHash(codeHash string) (*AuthorizationCode, error)
+	// MarkCodeUsed marks an unused code as used and reports whether this call did so
+	MarkCodeUsed(id uint) (bool, error)
+
+	CreateToken(token *OAuthToken) error
+	GetTokenByHash(tokenHash string) (*OAuthToken, error)
+	// RevokeToken revokes an active token and reports whether this call did so,
+	// which makes refresh token rotation single-use across replicas
+	RevokeToken(id uint) (bool, error)
+	// RevokeGrant revokes every token issued from the same authorization
+	RevokeGrant(grantID string) error
+	// RevokeClient revokes every token issued to the client
+	RevokeClient(clientID string) error
+}
+
+// SigningKeyRepository defines the interface for signing key persistence
+type SigningKeyRepository interface {
+	// List returns every key, newest first
+	List() ([]*SigningKey, error)
+	// Rotate stores the new key and retires the active ones unless a key
+	// created after staleBefore is already active, in which case another
+	// replica rotated first and false is returned
+	Rotate(key *SigningKey, staleBefore time.Time) (bool, error)
+	// DeleteRetiredBefore removes keys retired before the given time
+	DeleteRetiredBefore(before time.Time) error
+}
@@ -0,0 +1,36 @@
+package domain
+
+import "time"
+
+// PasswordResetToken represents a single-use password reset token. Only the
+// SHA-256 hash of the token is stored.
+type PasswordResetToken struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    uint      `gorm:"not null;index"`
+	TokenHash string    `gorm:"not null;uniqueIndex"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// PasswordResetService defines the interface for the forgotten password flow
+type PasswordResetService interface {
+	// Forgot emails a reset link if the address belongs to a user. It returns
+	// nil for unknown addresses so callers cannot probe for accounts.
+	Forgot(email string, request RequestMeta) error
+	// Reset sets a new password using a reset token and ends every session of the user
+	Reset(resetToken, newPassword string, request RequestMeta) error
+}
+
+// PasswordResetRepository defines the interface for password reset token persistence
+type PasswordResetRepository interface {
+	Create(token *PasswordResetToken) error
+	GetByHash(tokenHash string) (*PasswordResetToken, error)
+	// GetLatestForUser returns the most recently issued token of the user, or nil
+	GetLatestForUser(userID uint) (*PasswordResetToken, error)
+	// MarkUsed marks an unused token as used and reports whether this call did
+	// so, making redemption single-use across replicas
+	MarkUsed(id uint) (bool, error)
+	// InvalidateForUser marks every unused token of the user as used
+	InvalidateForUser(userID uint) error
+}
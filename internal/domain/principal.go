@@ -0,0 +1,113 @@
+package domain
+
+import "strings"
+
+// Principal is the authenticated identity a request acts as, together with
+// the permissions granted by its roles
+type Principal struct {
+	User         *User
+	Roles        []string
+	APIKeyID     uint        // set when the request authenticated with an API key
+	SessionID    string      // set when the request authenticated with a session's access token
+	Impersonator *User       // set when an admin acts as User with an impersonation token
+	Request      RequestMeta // the request the principal authenticated in, for the audit log
+	permissions  map[Permission]bool
+	system       bool
+}
+
+// NewPrincipal creates a principal for the user with the given roles. The
+// built-in user role is always included.
+func NewPrincipal(user *User, roles []*Role) *Principal {
+	p := &Principal{
+		User:        user,
+		Roles:       []string{RoleUser},
+		permissions: make(map[Permission]bool),
+	}
+	for _, permission := range BuiltinRoles[RoleUser] {
+		p.permissions[permission] = true
+	}
+
+	for _, role := range roles {
+		if role.Name != RoleUser {
+			p.Roles = append(p.Roles, role.Name)
+		}
+		for _, permission := range role.PermissionList() {
+			p.permissions[permission] = true
+		}
+	}
+	return p
+}
+
+// NewAPIKeyPrincipal creates a principal for a request authenticated with the
+// API key. A user's key keeps only the user's permissions covered by its
+// scopes, where a scope also covers its ":self" variant. A service account
+// key, given a nil user, holds exactly its scopes.
+func NewAPIKeyPrincipal(key *APIKey, user *User, roles []*Role) *Principal {
+	scopes := make(map[Permission]bool, len(key.Scopes))
+	for _, scope := range key.Scopes {
+		scopes[scope] = true
+	}
+
+	if user == nil {
+		return &Principal{APIKeyID: key.ID, permissions: scopes}
+	}
+
+	p := NewPrincipal(user, roles)
+	p.APIKeyID = key.ID
+	for permission := range p.permissions {
+		base := Permission(strings.TrimSuffix(string(permission), selfSuffix))
+		if !scopes[permission] && !scopes[base] {
+			delete(p.permissions, permission)
+		}
+	}
+	return p
+}
+
+// SystemPrincipal returns a principal for trusted internal operations that
+// are not performed on behalf of a user, such as resolving a token's subject
+func SystemPrincipal() *Principal {
+	return &Principal{system: true}
+}
+
+// ID returns the ID of the principal's user, or 0 for system principals
+func (p *Principal) ID() uint {
+	if p == nil || p.User == nil {
+		return 0
+	}
+	return p.User.ID
+}
+
+// Impersonated reports whether another user is acting as the principal's user
+func (p *Principal) Impersonated() bool {
+	return p != nil && p.Impersonator != nil
+}
+
+// HasRole reports whether the principal has the named role
+func (p *Principal) HasRole(name string) bool {
+	if p == nil {
+		return false
+	}
+	for _, role := range p.Roles {
+		if role == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Can reports whether the principal holds the permission without a self restriction
+func (p *Principal) Can(permission Permission) bool {
+	if p == nil {
+		return false
+	}
+	return p.system || p.permissions[permission]
+}
+
+// CanAccessUser reports whether the principal may perform the action on the given
+// user, either through the unrestricted permission or its ":self" variant
+func (p *Principal) CanAccessUser(permission Permission, userID uint) bool {
+	if p.Can(permission) {
+		return true
+	}
+	return p != nil && p.User != nil && p.User.ID == userID && p.permissions[permission.Self()]
+}
@@ -0,0 +1,161 @@
+package domain
+
+import (
+	"strings"
+	"time"
+)
+
+// Permission names an action a principal may perform. A permission ending in
+// ":self" only grants the action on the principal's own user record.
+type Permission string
+
+const (
+	PermissionUsersRead        Permission = "users:read"
+	PermissionUsersUpdate      Permission = "users:update"
+	PermissionUsersDelete      Permission = "users:delete"
+	PermissionUsersList        Permission = "users:list"
+	PermissionUsersImpersonate Permission = "users:impersonate"
+	PermissionRolesManage      Permission = "roles:manage"
+	PermissionLockoutsManage   Permission = "lockouts:manage"
+	PermissionMFAManage        Permission = "mfa:manage"
+	PermissionOAuthManage      Permission = "oauth:manage"
+	PermissionSCIMProvision    Permission = "scim:provision"
+	PermissionAPIKeysManage    Permission = "apikeys:manage"
+	PermissionAuditRead        Permission = "audit:read"
+	PermissionSessionsManage   Permission = "sessions:manage"
+)
+
+// selfSuffix marks a permission restricted to the principal's own record
+const selfSuffix = ":self"
+
+// Self returns the variant of the permission restricted to the principal's own record
+func (p Permission) Self() Permission {
+	if p.IsSelf() {
+		return p
+	}
+	return p + selfSuffix
+}
+
+// IsSelf reports whether the permission is restricted to the principal's own record
+func (p Permission) IsSelf() bool {
+	return strings.HasSuffix(string(p), selfSuffix)
+}
+
+// KnownPermissions lists every permission that can be granted to a role
+var KnownPermissions = []Permission{
+	PermissionUsersRead,
+	PermissionUsersRead.Self(),
+	PermissionUsersUpdate,
+	PermissionUsersUpdate.Self(),
+	PermissionUsersDelete,
+	PermissionUsersDelete.Self(),
+	PermissionUsersList,
+	PermissionUsersImpersonate,
+	PermissionRolesManage,
+	PermissionLockoutsManage,
+	PermissionMFAManage,
+	PermissionOAuthManage,
+	PermissionSCIMProvision,
+	PermissionAPIKeysManage,
+	PermissionAuditRead,
+	PermissionSessionsManage,
+	PermissionSessionsManage.Self(),
+}
+
+// IsKnownPermission reports whether the permission can be granted to a role
+func IsKnownPermission(permission Permission) bool {
+	for _, known := range KnownPermissions {
+		if known == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// Built-in role names
+const (
+	RoleAdmin = "admin"
+	RoleUser  = "user"
+)
+
+// BuiltinRoles are defined in code and synced to the database at startup.
+// Every authenticated user implicitly has the RoleUser permissions.
+var BuiltinRoles = map[string][]Permission{
+	RoleAdmin: {
+		PermissionUsersRead,
+		PermissionUsersUpdate,
+		PermissionUsersDelete,
+		PermissionUsersList,
+		PermissionUsersImpersonate,
+		PermissionRolesManage,
+		PermissionLockoutsManage,
+		PermissionMFAManage,
+		PermissionOAuthManage,
+		PermissionSCIMProvision,
+		PermissionAPIKeysManage,
+		PermissionAuditRead,
+		PermissionSessionsManage,
+	},
+	RoleUser: {
+		PermissionUsersRead.Self(),
+		PermissionUsersUpdate.Self(),
+		PermissionSessionsManage.Self(),
+	},
+}
+
+// Role represents a named set of permissions
+type Role struct {
+	ID          uint             `json:"id" gorm:"primaryKey"`
+	Name        string           `json:"name" gorm:"uniqueIndex;not null"`
+	Description string           `json:"description"`
+	Builtin     bool             `json:"builtin" gorm:"not null;default:false"`
+	Permissions []RolePermission `json:"-" gorm:"foreignKey:RoleID;constraint:OnDelete:CASCADE"`
+	CreatedAt   time.Time        `json:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+}
+
+// PermissionList returns the permissions granted by the role
+func (r *Role) PermissionList() []Permission {
+	permissions := make([]Permission, 0, len(r.Permissions))
+	for _, p := range r.Permissions {
+		permissions = append(permissions, p.Permission)
+	}
+	return permissions
+}
+
+// RolePermission grants a permission to a role
+type RolePermission struct {
+	RoleID     uint       `gorm:"primaryKey"`
+	Permission Permission `gorm:"primaryKey;type:varchar(100)"`
+}
+
+// UserRole assigns a role to a user
+type UserRole struct {
+	UserID    uint `gorm:"primaryKey"`
+	RoleID    uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+}
+
+// RoleService defines the interface for role management
+type RoleService interface {
+	List(actor *Principal) ([]*Role, error)
+	Create(actor *Principal, role *Role) error
+	Delete(actor *Principal, name string) error
+	GetUserRoles(actor *Principal, userID uint) ([]*Role, error)
+	AssignToUser(actor *Principal, userID uint, roleName string) error
+	RemoveFromUser(actor *Principal, userID uint, roleName string) error
+	EnsureBuiltinRoles() error
+}
+
+// RoleRepository defines the interface for role persistence
+type RoleRepository interface {
+	List() ([]*Role, error)
+	GetByName(name string) (*Role, error)
+	Create(role *Role) error
+	Delete(id uint) error
+	// Sync creates the role if missing and sets its permissions to exactly the given set
+	Sync(role *Role) error
+	GetUserRoles(userID uint) ([]*Role, error)
+	AssignToUser(userID, roleID uint) error
+	RemoveFromUser(userID, roleID uint) error
+}
@@ -0,0 +1,198 @@
+package domain
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// SCIM 2.0 schema URNs (RFC 7643 and RFC 7644)
+const (
+	SCIMUserSchema                  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SCIMServiceProviderConfigSchema = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	SCIMResourceTypeSchema          = "urn:ietf:params:scim:schemas:core:2.0:ResourceType"
+	SCIMSchemaSchema                = "urn:ietf:params:scim:schemas:core:2.0:Schema"
+	SCIMListResponseSchema          = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SCIMPatchOpSchema               = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SCIMErrorSchema                 = "urn:ietf:params:scim:api:messages:2.0:Error"
+)
+
+// SCIM error types (RFC 7644 section 3.12)
+const (
+	SCIMInvalidFilter = "invalidFilter"
+	SCIMTooMany       = "tooMany"
+	SCIMUniqueness    = "uniqueness"
+	SCIMMutability    = "mutability"
+	SCIMInvalidSyntax = "invalidSyntax"
+	SCIMInvalidPath   = "invalidPath"
+	SCIMNoTarget      = "noTarget"
+	SCIMInvalidValue  = "invalidValue"
+)
+
+// SCIMError is an error reported to SCIM clients in the format of RFC 7644 section 3.12
+type SCIMError struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	SCIMType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail,omitempty"`
+}
+
+func (e *SCIMError) Error() string {
+	return e.Detail
+}
+
+// NewSCIMError creates a new SCIM error with the HTTP status and scimType
+func NewSCIMError(status int, scimType, detail string) *SCIMError {
+	return &SCIMError{Schemas: []string{SCIMErrorSchema}, Status: strconv.Itoa(status), SCIMType: scimType, Detail: detail}
+}
+
+// SCIMUser is the SCIM core User resource. userName is the email address;
+// name.formatted and displayName both carry User.Name.
+type SCIMUser struct {
+	Schemas     []string    `json:"schemas"`
+	ID          string      `json:"id,omitempty"`
+	UserName    string      `json:"userName"`
+	Name        *SCIMName   `json:"name,omitempty"`
+	DisplayName string      `json:"displayName,omitempty"`
+	Emails      []SCIMEmail `json:"emails,omitempty"`
+	Active      *bool       `json:"active,omitempty"`
+	Password    string      `json:"password,omitempty"` // write only, never returned
+	Meta        *SCIMMeta   `json:"meta,omitempty"`
+}
+
+// SCIMName is the name complex attribute of a SCIM user
+type SCIMName struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+// SCIMEmail is a value of the emails attribute of a SCIM user
+type SCIMEmail struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+// SCIMMeta holds the resource metadata of RFC 7643 section 3.1
+type SCIMMeta struct {
+	ResourceType string    `json:"resourceType"`
+	Created      time.Time `json:"created"`
+	LastModified time.Time `json:"lastModified"`
+	Location     string    `json:"location"`
+}
+
+// SCIMListResponse is a page of query results (RFC 7644 section 3.4.2)
+type SCIMListResponse struct {
+	Schemas      []string    `json:"schemas"`
+	TotalResults int64       `json:"totalResults"`
+	StartIndex   int         `json:"startIndex"`
+	ItemsPerPage int         `json:"itemsPerPage"`
+	Resources    interface{} `json:"Resources"`
+}
+
+// SCIMPatchRequest is the body of a PATCH request (RFC 7644 section 3.5.2)
+type SCIMPatchRequest struct {
+	Schemas    []string             `json:"schemas"`
+	Operations []SCIMPatchOperation `json:"Operations"`
+}
+
+// SCIMPatchOperation adds, replaces or removes the value at Path. Without a
+// path, Value is an object of attributes to set.
+type SCIMPatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// SCIMServiceProviderConfig describes the supported SCIM features (RFC 7643 section 5)
+type SCIMServiceProviderConfig struct {
+	Schemas               []string                 `json:"schemas"`
+	DocumentationURI      string                   `json:"documentationUri,omitempty"`
+	Patch                 SCIMSupported            `json:"patch"`
+	Bulk                  SCIMBulk                 `json:"bulk"`
+	Filter                SCIMFilterSupport        `json:"filter"`
+	ChangePassword        SCIMSupported            `json:"changePassword"`
+	Sort                  SCIMSupported            `json:"sort"`
+	ETag                  SCIMSupported            `json:"etag"`
+	AuthenticationSchemes []SCIMAuthenticationType `json:"authenticationSchemes"`
+	Meta                  *SCIMMeta                `json:"meta,omitempty"`
+}
+
+// SCIMSupported reports whether an optional feature is supported
+type SCIMSupported struct {
+	Supported bool `json:"supported"`
+}
+
+// SCIMBulk describes bulk operation support
+type SCIMBulk struct {
+	Supported      bool `json:"supported"`
+	MaxOperations  int  `json:"maxOperations"`
+	MaxPayloadSize int  `json:"maxPayloadSize"`
+}
+
+// SCIMFilterSupport describes filter support
+type SCIMFilterSupport struct {
+	Supported  bool `json:"supported"`
+	MaxResults int  `json:"maxResults"`
+}
+
+// SCIMAuthenticationType describes an accepted authentication scheme
+type SCIMAuthenticationType struct {
+	Type        string `json:"type"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Primary     bool   `json:"primary,omitempty"`
+}
+
+// SCIMResourceType describes an endpoint and its schema (RFC 7643 section 6)
+type SCIMResourceType struct {
+	Schemas     []string  `json:"schemas"`
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Endpoint    string    `json:"endpoint"`
+	Description string    `json:"description"`
+	Schema      string    `json:"schema"`
+	Meta        *SCIMMeta `json:"meta,omitempty"`
+}
+
+// SCIMSchema describes the attributes of a resource (RFC 7643 section 7)
+type SCIMSchema struct {
+	Schemas     []string        `json:"schemas"`
+	ID          string          `json:"id"`
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Attributes  []SCIMAttribute `json:"attributes"`
+	Meta        *SCIMMeta       `json:"meta,omitempty"`
+}
+
+// SCIMAttribute describes one attribute of a schema
+type SCIMAttribute struct {
+	Name          string          `json:"name"`
+	Type          string          `json:"type"`
+	MultiValued   bool            `json:"multiValued"`
+	Description   string          `json:"description"`
+	Required      bool            `json:"required"`
+	CaseExact     bool            `json:"caseExact"`
+	Mutability    string          `json:"mutability"`
+	Returned      string          `json:"returned"`
+	Uniqueness    string          `json:"uniqueness"`
+	SubAttributes []SCIMAttribute `json:"subAttributes,omitempty"`
+}
+
+// SCIMService defines the interface for SCIM user provisioning. The methods
+// acting on users require the scim:provision permission, the discovery
+// methods are public. Protocol errors are *SCIMError.
+type SCIMService interface {
+	ServiceProviderConfig() *SCIMServiceProviderConfig
+	ResourceTypes() []*SCIMResourceType
+	Schemas() []*SCIMSchema
+
+	// List returns the users matching the filter, starting at the 1-based startIndex
+	List(actor *Principal, filter string, startIndex, count int) (*SCIMListResponse, error)
+	Get(actor *Principal, id string) (*SCIMUser, error)
+	Create(actor *Principal, user *SCIMUser) (*SCIMUser, error)
+	Replace(actor *Principal, id string, user *SCIMUser) (*SCIMUser, error)
+	Patch(actor *Principal, id string, patch *SCIMPatchRequest) (*SCIMUser, error)
+	Delete(actor *Principal, id string) error
+}
@@ -0,0 +1,49 @@
+package domain
+
+import "time"
+
+// Session is a signed in device. It is started by a login and lives as long
+// as its refresh token family: its ID is the FamilyID of its refresh tokens
+// and the sid claim of its access tokens, so revoking the family ends the
+// session and rejects its access tokens on the next request.
+type Session struct {
+	ID         string     `json:"id" gorm:"primaryKey"`
+	UserID     uint       `json:"user_id" gorm:"not null;index"`
+	UserAgent  string     `json:"user_agent" gorm:"not null;default:''"`
+	Browser    string     `json:"browser" gorm:"not null;default:''"`
+	OS         string     `json:"os" gorm:"not null;default:''"`
+	DeviceType string     `json:"device_type" gorm:"not null;default:''"`
+	ClientIP   string     `json:"ip" gorm:"not null;default:''"` // the IP the session was last seen from
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at" gorm:"not null"`
+	ExpiresAt  time.Time  `json:"expires_at" gorm:"not null"` // when its latest refresh token expires
+	RevokedAt  *time.Time `json:"-"`
+	Current    bool       `json:"current" gorm:"-"` // the session of the request listing it
+}
+
+// IsActive reports whether the session is neither revoked nor expired at the given time
+func (s *Session) IsActive(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
+
+// SessionService defines the interface for managing a user's sessions
+type SessionService interface {
+	// List returns the active sessions of the user, most recently seen first
+	List(actor *Principal, userID uint) ([]*Session, error)
+	// Revoke ends one session of the user
+	Revoke(actor *Principal, userID uint, sessionID string) error
+	// RevokeAll ends every session of the user, forcing them to log in again
+	RevokeAll(actor *Principal, userID uint) error
+}
+
+// SessionRepository defines the interface for session persistence. Sessions
+// are revoked together with their refresh tokens, through
+// RefreshTokenRepository.RevokeFamily and RevokeAllForUser.
+type SessionRepository interface {
+	Create(session *Session) error
+	Get(id string) (*Session, error)
+	// ListActive returns the user's sessions that are active at the given time
+	ListActive(userID uint, now time.Time) ([]*Session, error)
+	// Touch stores the last seen time, client IP and expiry of the session
+	Touch(session *Session) error
+}
@@ -4,30 +4,203 @@ import "time"
 
 // User represents the user entity
 type User struct {
-	ID        uint      `json:"id" gorm:"primaryKey"`
-	Email     string    `json:"email" gorm:"unique;not null"`
-	Password  string    `json:"password,omitempty" gorm:"not null"`
-	Name      string    `json:"name" gorm:"not null"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID              uint       `json:"id" gorm:"primaryKey"`
+	Email           string     `json:"email" gorm:"unique;not null"`
+	Password        string     `json:"-" gorm:"not null"` // password hash, never serialized
+	Name            string     `json:"name" gorm:"not null"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	PendingEmail    string     `json:"pending_email,omitempty" gorm:"not null;default:''"` // new address awaiting confirmation
+	DeactivatedAt   *time.Time `json:"deactivated_at,omitempty"`                           // set when deprovisioned, blocks sign in
+	Version         uint       `json:"-" gorm:"not null;default:1"`                        // advanced by every write, used as the ETag
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
 }
 
-// UserService defines the interface for user business logic
+// Media types of the PATCH request bodies accepted for users
+const (
+	MergePatchContentType = "application/merge-patch+json"
+	JSONPatchContentType  = "application/json-patch+json"
+)
+
+// UserPatch is the body of a PATCH request and its media type. The patch
+// applies to a document holding the user's id, email and name; adding a
+// password member changes the password.
+type UserPatch struct {
+	ContentType string
+	Body        []byte
+	Version     uint // version the patch is based on, or 0 to patch any version
+}
+
+// IsEmailVerified reports whether the user confirmed their current email address
+func (u *User) IsEmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+// IsActive reports whether the user may sign in
+func (u *User) IsActive() bool {
+	return u.DeactivatedAt == nil
+}
+
+// User fields that can be searched
+const (
+	UserFieldID        = "id"
+	UserFieldEmail     = "email"
+	UserFieldName      = "name"
+	UserFieldActive    = "active"
+	UserFieldCreatedAt = "created_at"
+	UserFieldUpdatedAt = "updated_at"
+)
+
+// Operators of a UserFilter
+const (
+	FilterAnd            = "and"
+	FilterOr             = "or"
+	FilterNot            = "not"
+	FilterEqual          = "eq"
+	FilterNotEqual       = "ne"
+	FilterContains       = "co"
+	FilterStartsWith     = "sw"
+	FilterEndsWith       = "ew"
+	FilterGreater        = "gt"
+	FilterGreaterOrEqual = "ge"
+	FilterLess           = "lt"
+	FilterLessOrEqual    = "le"
+	FilterPresent        = "pr"
+)
+
+// UserFilter is a condition on users. Logical operators combine Operands;
+// the others compare Field with Value, which is a string, uint, bool or
+// time.Time matching the field. String comparisons ignore case.
+type UserFilter struct {
+	Operator string
+	Field    string
+	Value    interface{}
+	Operands []*UserFilter
+}
+
+// UserSort orders users by a field, ascending unless Descending is set
+type UserSort struct {
+	Field      string
+	Descending bool
+}
+
+// UserQuery selects and orders the users of a listing. A nil Filter matches
+// every user; a non-empty Search keeps the users whose name or email is
+// similar to it. Users are ordered by Sort, then by relevance to Search, then by ID.
+type UserQuery struct {
+	Filter *UserFilter
+	Search string
+	Sort   []UserSort
+}
+
+// UserListFilter is an unparsed filter[field] or filter[field][operator]
+// parameter of a user listing. Operator is empty for the first form.
+type UserListFilter struct {
+	Field    string
+	Operator string
+	Value    string
+}
+
+// UserListParams are the unparsed filter, sort and search parameters of a
+// user listing. Sort is a comma separated list of fields, each prefixed with
+// - for descending order.
+type UserListParams struct {
+	Filters []UserListFilter
+	Sort    string
+	Search  string
+}
+
+// Ways of counting the users matching a listing
+const (
+	UserTotalExact    = "exact"    // COUNT(*) of the matching users
+	UserTotalEstimate = "estimate" // the query planner's row estimate, cheap on large tables
+)
+
+// UserPageParams selects a page of a user listing. After and Before are
+// cursors returned with an earlier page of the same listing; at most one is
+// set. A zero Limit selects the default page size and an empty Total leaves
+// the matching users uncounted.
+type UserPageParams struct {
+	Limit  int
+	After  string
+	Before string
+	Total  string
+}
+
+// UserPage is a page of a user listing. NextCursor and PrevCursor are empty
+// at the ends of the listing.
+type UserPage struct {
+	Users          []*User
+	NextCursor     string
+	PrevCursor     string
+	Total          *int64
+	TotalEstimated bool
+}
+
+// UserKey is the position of a user in the order of a UserQuery: the values
+// of its sort fields, its search rank when searching, and its ID, formatted
+// by the repository
+type UserKey []string
+
+// UserSeek selects up to Limit users of a query following the user at After
+// or preceding the user at Before, or the first users when neither is set
+type UserSeek struct {
+	Limit  int
+	After  UserKey
+	Before UserKey
+	Total  string
+}
+
+// UserKeyPage holds the users selected by a UserSeek in query order, the keys
+// of the first and last of them and whether more users precede and follow
+type UserKeyPage struct {
+	Users   []*User
+	First   UserKey
+	Last    UserKey
+	HasPrev bool
+	HasNext bool
+	Total   *int64
+}
+
+// UserService defines the interface for user business logic. Methods acting
+// on existing users receive the acting principal and enforce its permissions.
 type UserService interface {
-	Create(user *User) error
-	Get(id uint) (*User, error)
-	Update(user *User) error
-	Delete(id uint) error
-	List(page, limit int) ([]*User, error)
+	// Create signs up a new user in the request
+	Create(user *User, request RequestMeta) error
+	Get(actor *Principal, id uint) (*User, error)
+	// Update replaces the user. A non-zero user.Version must be the stored
+	// version or the update fails with PreconditionFailed.
+	Update(actor *Principal, user *User) error
+	// Patch applies a JSON Merge Patch or JSON Patch to the user and stores
+	// the fields it changed
+	Patch(actor *Principal, id uint, patch *UserPatch) (*User, error)
+	// Delete deletes the user. A non-zero version must be the stored version.
+	Delete(actor *Principal, id, version uint) error
+	// List lists a page of the users matching the filter and search
+	// parameters, which fail with InvalidInput on fields or operators that
+	// are not allowed
+	List(actor *Principal, params UserListParams, page UserPageParams) (*UserPage, error)
 	GetByEmail(email string) (*User, error)
+	VerifyPassword(email, plainPassword string) (*User, error)
 }
 
 // UserRepository defines the interface for user data persistence
 type UserRepository interface {
 	Create(user *User) error
 	Get(id uint) (*User, error)
+	// Update stores the user if the stored version is still user.Version and
+	// advances the version. It fails with PreconditionFailed otherwise.
 	Update(user *User) error
-	Delete(id uint) error
-	List(page, limit int) ([]*User, error)
+	// UpdateColumns stores only the named columns of the user, with the same
+	// version check as Update
+	UpdateColumns(user *User, columns ...string) error
+	// Delete deletes the user if the stored version is still the given one
+	Delete(id, version uint) error
+	// List retrieves the users of the query selected by the seek, in query order
+	List(query UserQuery, seek UserSeek) (*UserKeyPage, error)
 	GetByEmail(email string) (*User, error)
+	UpdatePassword(id uint, hashedPassword string) error
+	// Search returns the users matching the filter ordered by ID, and the
+	// number of matching users. A nil filter matches every user.
+	Search(filter *UserFilter, offset, limit int) ([]*User, int64, error)
 }
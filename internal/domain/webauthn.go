@@ -0,0 +1,175 @@
+package domain
+
+import "time"
+
+// Purposes of WebAuthn ceremonies
+const (
+	WebAuthnRegistration = "registration"
+	WebAuthnLogin        = "login" // passkey as the only factor
+	WebAuthnMFA          = "mfa"   // passkey as the second factor after a password or magic link
+)
+
+// WebAuthnCredential is a passkey or security key registered by a user. Only
+// the public key is stored; SignCount is the authenticator's signature
+// counter from the last login, used to detect cloned authenticators.
+type WebAuthnCredential struct {
+	ID              uint       `gorm:"primaryKey" json:"id"`
+	UserID          uint       `gorm:"not null;index" json:"-"`
+	CredentialID    string     `gorm:"not null;uniqueIndex" json:"credential_id"` // base64url
+	PublicKey       []byte     `gorm:"not null" json:"-"`                         // COSE_Key
+	Algorithm       int        `gorm:"not null" json:"algorithm"`
+	SignCount       uint32     `gorm:"not null;default:0" json:"-"`
+	AAGUID          string     `gorm:"column:aaguid;not null;default:''" json:"aaguid"` // hex, identifies the authenticator model
+	AttestationType string     `gorm:"not null" json:"attestation_type"`
+	Name            string     `gorm:"not null" json:"name"`
+	CreatedAt       time.Time  `json:"created_at"`
+	LastUsedAt      *time.Time `json:"last_used_at,omitempty"`
+}
+
+// TableName keeps GORM from naming the table web_authn_credentials
+func (WebAuthnCredential) TableName() string {
+	return "webauthn_credentials"
+}
+
+// WebAuthnChallenge is the random challenge of a ceremony in progress. Only
+// the SHA-256 hash of its base64url encoding is stored, and it is looked up
+// from the challenge signed in the client data.
+type WebAuthnChallenge struct {
+	ID            uint      `gorm:"primaryKey"`
+	UserID        *uint     `gorm:"index"` // nil for passkey logins, which identify the user from the credential
+	Purpose       string    `gorm:"not null"`
+	ChallengeHash string    `gorm:"not null;uniqueIndex"`
+	ExpiresAt     time.Time `gorm:"not null"`
+	UsedAt        *time.Time
+	CreatedAt     time.Time
+}
+
+// TableName keeps GORM from naming the table web_authn_challenges
+func (WebAuthnChallenge) TableName() string {
+	return "webauthn_challenges"
+}
+
+// WebAuthnRelyingParty identifies this service to authenticators
+type WebAuthnRelyingParty struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// WebAuthnUser is the account a credential is created for
+type WebAuthnUser struct {
+	ID          string `json:"id"` // base64url user handle
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+// WebAuthnCredentialParameter names an accepted credential algorithm
+type WebAuthnCredentialParameter struct {
+	Type string `json:"type"`
+	Alg  int    `json:"alg"`
+}
+
+// WebAuthnCredentialDescriptor references a registered credential
+type WebAuthnCredentialDescriptor struct {
+	Type string `json:"type"`
+	ID   string `json:"id"` // base64url
+}
+
+// WebAuthnAuthenticatorSelection states the authenticator features the relying party wants
+type WebAuthnAuthenticatorSelection struct {
+	ResidentKey      string `json:"residentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+// WebAuthnCreationOptions are the PublicKeyCredentialCreationOptions for
+// navigator.credentials.create(), with binary values base64url encoded
+type WebAuthnCreationOptions struct {
+	Challenge              string                         `json:"challenge"`
+	RP                     WebAuthnRelyingParty           `json:"rp"`
+	User                   WebAuthnUser                   `json:"user"`
+	PubKeyCredParams       []WebAuthnCredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                          `json:"timeout"` // milliseconds
+	ExcludeCredentials     []WebAuthnCredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection WebAuthnAuthenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                         `json:"attestation"`
+}
+
+// WebAuthnRequestOptions are the PublicKeyCredentialRequestOptions for
+// navigator.credentials.get(), with binary values base64url encoded
+type WebAuthnRequestOptions struct {
+	Challenge        string                         `json:"challenge"`
+	Timeout          int64                          `json:"timeout"` // milliseconds
+	RPID             string                         `json:"rpId"`
+	AllowCredentials []WebAuthnCredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                         `json:"userVerification"`
+}
+
+// WebAuthnAttestation is a registration response in the JSON form of PublicKeyCredential
+type WebAuthnAttestation struct {
+	ID       string                      `json:"id" binding:"required"`
+	RawID    string                      `json:"rawId"`
+	Type     string                      `json:"type"`
+	Response WebAuthnAttestationResponse `json:"response"`
+}
+
+// WebAuthnAttestationResponse is the AuthenticatorAttestationResponse of a registration
+type WebAuthnAttestationResponse struct {
+	ClientDataJSON    string `json:"clientDataJSON" binding:"required"`
+	AttestationObject string `json:"attestationObject" binding:"required"`
+}
+
+// WebAuthnAssertion is a login response in the JSON form of PublicKeyCredential
+type WebAuthnAssertion struct {
+	ID       string                    `json:"id" binding:"required"`
+	RawID    string                    `json:"rawId"`
+	Type     string                    `json:"type"`
+	Response WebAuthnAssertionResponse `json:"response"`
+}
+
+// WebAuthnAssertionResponse is the AuthenticatorAssertionResponse of a login
+type WebAuthnAssertionResponse struct {
+	ClientDataJSON    string `json:"clientDataJSON" binding:"required"`
+	AuthenticatorData string `json:"authenticatorData" binding:"required"`
+	Signature         string `json:"signature" binding:"required"`
+	UserHandle        string `json:"userHandle"`
+}
+
+// WebAuthnService defines the interface for passkey registration and verification
+type WebAuthnService interface {
+	// BeginRegistration starts registering a passkey for the actor
+	BeginRegistration(actor *Principal) (*WebAuthnCreationOptions, error)
+	// FinishRegistration verifies the authenticator's attestation and stores the credential
+	FinishRegistration(actor *Principal, name string, attestation *WebAuthnAttestation) (*WebAuthnCredential, error)
+	List(actor *Principal) ([]*WebAuthnCredential, error)
+	Delete(actor *Principal, id uint) error
+	// HasCredentials reports whether the user registered a passkey
+	HasCredentials(userID uint) (bool, error)
+
+	// BeginLogin starts a passkey login with any discoverable credential
+	BeginLogin() (*WebAuthnRequestOptions, error)
+	// FinishLogin verifies a passkey login, which must be user verified, and
+	// returns the user the credential belongs to
+	FinishLogin(assertion *WebAuthnAssertion) (*User, error)
+	// BeginMFA starts a second factor check with the user's credentials
+	BeginMFA(userID uint) (*WebAuthnRequestOptions, error)
+	// FinishMFA verifies a second factor assertion by one of the user's credentials
+	FinishMFA(userID uint, assertion *WebAuthnAssertion) error
+}
+
+// WebAuthnRepository defines the interface for WebAuthn credential and challenge persistence
+type WebAuthnRepository interface {
+	CreateCredential(credential *WebAuthnCredential) error
+	// GetCredential returns the credential with the base64url credential ID, or nil
+	GetCredential(credentialID string) (*WebAuthnCredential, error)
+	ListCredentials(userID uint) ([]*WebAuthnCredential, error)
+	CountCredentials(userID uint) (int64, error)
+	// UseCredential records a login and the new signature counter. It reports
+	// false when the stored counter is no longer the one the login was checked
+	// against, so concurrent use of a cloned authenticator is detected.
+	UseCredential(id uint, oldSignCount, newSignCount uint32) (bool, error)
+	DeleteCredential(userID, id uint) (bool, error)
+
+	CreateChallenge(challenge *WebAuthnChallenge) error
+	GetChallengeByHash(challengeHash string) (*WebAuthnChallenge, error)
+	// MarkChallengeUsed marks an unused challenge as used and reports whether this call did so
+	MarkChallengeUsed(id uint) (bool, error)
+}
@@ -1,22 +1,43 @@
 package errors
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+	"time"
+)
 
 type ErrorType string
 
 const (
-	NotFound          ErrorType = "NOT_FOUND"
-	InvalidInput      ErrorType = "INVALID_INPUT"
-	DuplicateEmail    ErrorType = "DUPLICATE_EMAIL"
-	InvalidEmail      ErrorType = "INVALID_EMAIL"
-	InvalidPassword   ErrorType = "INVALID_PASSWORD"
-	DatabaseOperation ErrorType = "DATABASE_OPERATION"
-	InternalServer    ErrorType = "INTERNAL_SERVER"
+	NotFound           ErrorType = "NOT_FOUND"
+	InvalidInput       ErrorType = "INVALID_INPUT"
+	DuplicateEmail     ErrorType = "DUPLICATE_EMAIL"
+	InvalidEmail       ErrorType = "INVALID_EMAIL"
+	InvalidPassword    ErrorType = "INVALID_PASSWORD"
+	Unauthorized       ErrorType = "UNAUTHORIZED"
+	Forbidden          ErrorType = "FORBIDDEN"
+	Conflict           ErrorType = "CONFLICT"
+	PreconditionFailed ErrorType = "PRECONDITION_FAILED"
+	// PreconditionRequired is a conditional request sent without its condition
+	PreconditionRequired ErrorType = "PRECONDITION_REQUIRED"
+	UnsupportedMediaType ErrorType = "UNSUPPORTED_MEDIA_TYPE"
+	TooManyRequests      ErrorType = "TOO_MANY_REQUESTS"
+	DatabaseOperation    ErrorType = "DATABASE_OPERATION"
+	InternalServer       ErrorType = "INTERNAL_SERVER"
 )
 
+// ErrorDetail describes a single problem contributing to an error
+type ErrorDetail struct {
+	Field   string `json:"field,omitempty"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
 type AppError struct {
-	Type    ErrorType
-	Message string
+	Type       ErrorType
+	Message    string
+	Details    []ErrorDetail
+	RetryAfter time.Duration // how long the client should wait before retrying, if known
 }
 
 func (e *AppError) Error() string {
@@ -36,6 +57,20 @@ func InvalidInputError(field, reason string) error {
 	return &AppError{
 		Type:    InvalidInput,
 		Message: fmt.Sprintf("Invalid input for %s: %s", field, reason),
+		Details: []ErrorDetail{{Field: field, Code: "invalid", Message: reason}},
+	}
+}
+
+// ValidationError creates a new invalid input error listing every invalid field
+func ValidationError(details []ErrorDetail) error {
+	messages := make([]string, 0, len(details))
+	for _, detail := range details {
+		messages = append(messages, detail.Field+" "+detail.Message)
+	}
+	return &AppError{
+		Type:    InvalidInput,
+		Message: fmt.Sprintf("Invalid input: %s", strings.Join(messages, "; ")),
+		Details: details,
 	}
 }
 
@@ -52,6 +87,7 @@ func InvalidEmailError(email string) error {
 	return &AppError{
 		Type:    InvalidEmail,
 		Message: fmt.Sprintf("Invalid email format: %s", email),
+		Details: []ErrorDetail{{Field: "email", Code: "format", Message: "must be a valid email address"}},
 	}
 }
 
@@ -63,6 +99,81 @@ func InvalidPasswordError(reason string) error {
 	}
 }
 
+// PasswordPolicyError creates a new invalid password error listing every policy violation
+func PasswordPolicyError(details []ErrorDetail) error {
+	messages := make([]string, 0, len(details))
+	for _, detail := range details {
+		messages = append(messages, detail.Message)
+	}
+	return &AppError{
+		Type:    InvalidPassword,
+		Message: fmt.Sprintf("Invalid password: %s", strings.Join(messages, "; ")),
+		Details: details,
+	}
+}
+
+// UnauthorizedError creates a new unauthorized error
+func UnauthorizedError(reason string) error {
+	return &AppError{
+		Type:    Unauthorized,
+		Message: fmt.Sprintf("Unauthorized: %s", reason),
+	}
+}
+
+// InvalidCredentialsError creates a new error for a failed login attempt
+func InvalidCredentialsError() error {
+	return UnauthorizedError("invalid email or password")
+}
+
+// ForbiddenError creates a new error for an action the principal is not allowed to perform
+func ForbiddenError(action string) error {
+	return &AppError{
+		Type:    Forbidden,
+		Message: fmt.Sprintf("Forbidden: you are not allowed to %s", action),
+	}
+}
+
+// ConflictError creates a new error for a request that conflicts with the current state
+func ConflictError(reason string) error {
+	return &AppError{
+		Type:    Conflict,
+		Message: fmt.Sprintf("Conflict: %s", reason),
+	}
+}
+
+// PreconditionFailedError creates a new error for a write based on an outdated version of a resource
+func PreconditionFailedError(resource string, id interface{}) error {
+	return &AppError{
+		Type:    PreconditionFailed,
+		Message: fmt.Sprintf("%s with ID %v was modified since it was read", resource, id),
+	}
+}
+
+// PreconditionRequiredError creates a new error for a write sent without the header making it conditional
+func PreconditionRequiredError(header string) error {
+	return &AppError{
+		Type:    PreconditionRequired,
+		Message: fmt.Sprintf("%s header is required", header),
+	}
+}
+
+// UnsupportedMediaTypeError creates a new error for a request body of a media type the endpoint does not accept
+func UnsupportedMediaTypeError(accepted ...string) error {
+	return &AppError{
+		Type:    UnsupportedMediaType,
+		Message: fmt.Sprintf("Content-Type must be %s", strings.Join(accepted, " or ")),
+	}
+}
+
+// TooManyAttemptsError creates a new error for a request rejected by throttling
+func TooManyAttemptsError(reason string, retryAfter time.Duration) error {
+	return &AppError{
+		Type:       TooManyRequests,
+		Message:    fmt.Sprintf("Too many attempts: %s", reason),
+		RetryAfter: retryAfter,
+	}
+}
+
 // DatabaseError creates a new database operation error
 func DatabaseError(operation string, err error) error {
 	return &AppError{
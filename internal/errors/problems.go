@@ -0,0 +1,101 @@
+package errors
+
+import (
+	"net/http"
+	"sort"
+)
+
+// Problem describes how errors of a type are reported to clients as RFC 7807
+// problem details. Code is stable, so clients can branch on it instead of on
+// messages.
+type Problem struct {
+	Code        string `json:"code"`
+	Status      int    `json:"status"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+}
+
+// internalProblem reports database and unexpected errors, whose messages are never shown to clients
+var internalProblem = Problem{
+	Code:        "internal_error",
+	Status:      http.StatusInternalServerError,
+	Title:       "Internal server error",
+	Description: "The server failed to handle the request. Retrying may help; the request ID identifies it in the logs.",
+}
+
+// problems is the catalog of errors clients can receive
+var problems = map[ErrorType]Problem{
+	NotFound: {
+		Code: "not_found", Status: http.StatusNotFound, Title: "Not found",
+		Description: "The resource does not exist, or the route is unknown.",
+	},
+	InvalidInput: {
+		Code: "invalid_input", Status: http.StatusBadRequest, Title: "Invalid input",
+		Description: "A parameter or body field is missing or malformed. The errors array names each invalid field.",
+	},
+	InvalidEmail: {
+		Code: "invalid_email", Status: http.StatusBadRequest, Title: "Invalid email address",
+		Description: "The email address is not a valid address.",
+	},
+	InvalidPassword: {
+		Code: "invalid_password", Status: http.StatusBadRequest, Title: "Password rejected",
+		Description: "The password violates the password policy. The errors array lists every violated rule.",
+	},
+	DuplicateEmail: {
+		Code: "duplicate_email", Status: http.StatusConflict, Title: "Email already registered",
+		Description: "Another user has or has reserved the email address.",
+	},
+	Unauthorized: {
+		Code: "unauthorized", Status: http.StatusUnauthorized, Title: "Unauthorized",
+		Description: "The request lacks valid credentials, or a token or code is invalid or expired.",
+	},
+	Forbidden: {
+		Code: "forbidden", Status: http.StatusForbidden, Title: "Forbidden",
+		Description: "The principal is not allowed to perform the action.",
+	},
+	Conflict: {
+		Code: "conflict", Status: http.StatusConflict, Title: "Conflict",
+		Description: "The request conflicts with the current state of the resource.",
+	},
+	PreconditionFailed: {
+		Code: "precondition_failed", Status: http.StatusPreconditionFailed, Title: "Precondition failed",
+		Description: "The resource changed since it was read. Fetch it again for its current ETag.",
+	},
+	PreconditionRequired: {
+		Code: "precondition_required", Status: http.StatusPreconditionRequired, Title: "Precondition required",
+		Description: "The write must be conditional. Send the ETag of the resource in If-Match.",
+	},
+	UnsupportedMediaType: {
+		Code: "unsupported_media_type", Status: http.StatusUnsupportedMediaType, Title: "Unsupported media type",
+		Description: "The endpoint does not accept the Content-Type of the request body.",
+	},
+	TooManyRequests: {
+		Code: "too_many_requests", Status: http.StatusTooManyRequests, Title: "Too many requests",
+		Description: "The request was throttled. Retry after the number of seconds in Retry-After.",
+	},
+	DatabaseOperation: internalProblem,
+	InternalServer:    internalProblem,
+}
+
+// ProblemOf returns how errors of the type are reported, which is as an
+// internal error for unknown types
+func ProblemOf(errorType ErrorType) Problem {
+	if problem, ok := problems[errorType]; ok {
+		return problem
+	}
+	return internalProblem
+}
+
+// Catalog returns every problem clients can receive, ordered by code
+func Catalog() []Problem {
+	seen := make(map[string]bool)
+	var catalog []Problem
+	for _, problem := range problems {
+		if !seen[problem.Code] {
+			seen[problem.Code] = true
+			catalog = append(catalog, problem)
+		}
+	}
+	sort.Slice(catalog, func(i, j int) bool { return catalog[i].Code < catalog[j].Code })
+	return catalog
+}
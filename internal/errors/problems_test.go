@@ -0,0 +1,64 @@
+package errors
+
+import (
+	"net/http"
+	"testing"
+)
+
+var errorTypes = []ErrorType{
+	NotFound, InvalidInput, DuplicateEmail, InvalidEmail, InvalidPassword, Unauthorized, Forbidden, Conflict,
+	PreconditionFailed, PreconditionRequired, UnsupportedMediaType, TooManyRequests, DatabaseOperation, InternalServer,
+}
+
+func TestProblemOf(t *testing.T) {
+	for _, errorType := range errorTypes {
+		if _, ok := problems[errorType]; !ok {
+			t.Errorf("problems has no entry for %s", errorType)
+		}
+	}
+
+	tests := []struct {
+		errorType  ErrorType
+		wantCode   string
+		wantStatus int
+	}{
+		{NotFound, "not_found", http.StatusNotFound},
+		{InvalidInput, "invalid_input", http.StatusBadRequest},
+		{DuplicateEmail, "duplicate_email", http.StatusConflict},
+		{PreconditionRequired, "precondition_required", http.StatusPreconditionRequired},
+		{TooManyRequests, "too_many_requests", http.StatusTooManyRequests},
+		// Database errors must not be distinguishable from other internal errors
+		{DatabaseOperation, "internal_error", http.StatusInternalServerError},
+		{InternalServer, "internal_error", http.StatusInternalServerError},
+		{ErrorType("UNKNOWN"), "internal_error", http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		got := ProblemOf(tt.errorType)
+		if got.Code != tt.wantCode || got.Status != tt.wantStatus {
+			t.Errorf("ProblemOf(%s) = %s %d, want %s %d", tt.errorType, got.Code, got.Status, tt.wantCode, tt.wantStatus)
+		}
+	}
+}
+
+func TestCatalog(t *testing.T) {
+	catalog := Catalog()
+	if len(catalog) != len(errorTypes)-1 {
+		t.Errorf("Catalog() has %d problems, want %d", len(catalog), len(errorTypes)-1)
+	}
+	for i, problem := range catalog {
+		if problem.Code == "" || problem.Title == "" || problem.Description == "" || problem.Status == 0 {
+			t.Errorf("Catalog()[%d] = %+v, want every field set", i, problem)
+		}
+		if i > 0 && catalog[i-1].Code >= problem.Code {
+			t.Errorf("Catalog() codes %q and %q are not unique and sorted", catalog[i-1].Code, problem.Code)
+		}
+	}
+}
+
+func TestValidationError(t *testing.T) {
+	err := ValidationError([]ErrorDetail{{Field: "email", Code: "required", Message: "is required"}})
+	appErr, ok := err.(*AppError)
+	if !ok || appErr.Type != InvalidInput || len(appErr.Details) != 1 || appErr.Details[0].Field != "email" {
+		t.Errorf("ValidationError() = %#v, want an invalid input error with the details", err)
+	}
+}
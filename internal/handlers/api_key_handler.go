@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/errors"
+	"UserRESTfulApi/internal/middleware"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type APIKeyHandler struct {
+	service domain.APIKeyService
+}
+
+// NewAPIKeyHandler creates a new API key handler
+func NewAPIKeyHandler(service domain.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{service: service}
+}
+
+// CreateAPIKeyRequest represents the payload for issuing an API key
+type CreateAPIKeyRequest struct {
+	Name           string              `json:"name" binding:"required"`
+	Scopes         []domain.Permission `json:"scopes" binding:"required"`
+	ExpiresAt      *time.Time          `json:"expires_at"`
+	AllowedIPs     []string            `json:"allowed_ips"`
+	ServiceAccount bool                `json:"service_account"`
+}
+
+// ListAPIKeys handles listing the caller's API keys, or every key for API key managers
+func (h *APIKeyHandler) ListAPIKeys(c *gin.Context) {
+	actor, ok := principal(c)
+	if !ok {
+		return
+	}
+
+	keys, err := h.service.List(actor)
+	if err != nil {
+		middleware.AbortWithProblem(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, keys)
+}
+
+// CreateAPIKey handles issuing an API key for the caller or a service
+// account. The response holds the key, which is shown only once.
+func (h *APIKeyHandler) CreateAPIKey(c *gin.Context) {
+	var req CreateAPIKeyRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	actor, ok := principal(c)
+	if !ok {
+		return
+	}
+
+	key := &domain.APIKey{
+		Name:       req.Name,
+		Scopes:     req.Scopes,
+		ExpiresAt:  req.ExpiresAt,
+		AllowedIPs: req.AllowedIPs,
+	}
+	if !req.ServiceAccount {
+		userID := actor.ID()
+		key.UserID = &userID
+	}
+
+	secret, err := h.service.Create(actor, key)
+	if err != nil {
+		middleware.AbortWithProblem(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"api_key": key, "key": secret})
+}
+
+// DeleteAPIKey handles revoking an API key
+func (h *APIKeyHandler) DeleteAPIKey(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		middleware.AbortWithProblem(c, errors.InvalidInputError("id", "must be a positive integer"))
+		return
+	}
+
+	actor, ok := principal(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.Delete(actor, uint(id)); err != nil {
+		middleware.AbortWithProblem(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "API key deleted successfully"})
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/errors"
+	"UserRESTfulApi/internal/middleware"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AuditHandler struct {
+	service domain.AuditService
+}
+
+// NewAuditHandler creates a new audit log handler
+func NewAuditHandler(service domain.AuditService) *AuditHandler {
+	return &AuditHandler{service: service}
+}
+
+// timeQuery parses an optional RFC 3339 query parameter, recording an invalid
+// input error when it is malformed
+func timeQuery(c *gin.Context, param string) (*time.Time, bool) {
+	value := c.Query(param)
+	if value == "" {
+		return nil, true
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		middleware.AbortWithProblem(c, errors.InvalidInputError(param, "must be an RFC 3339 time"))
+		return nil, false
+	}
+	return &t, true
+}
+
+// ListAuditEvents handles listing audit events, newest first. Events can be
+// filtered with ?actor_id=, ?impersonator_id=, ?target_type=, ?target_id=,
+// ?action= and an RFC 3339 ?from= and ?to= time range.
+func (h *AuditHandler) ListAuditEvents(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+
+	filter := domain.AuditFilter{
+		TargetType: c.Query("target_type"),
+		TargetID:   c.Query("target_id"),
+		Action:     c.Query("action"),
+	}
+	if value := c.Query("actor_id"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			middleware.AbortWithProblem(c, errors.InvalidInputError("actor_id", "must be a positive integer"))
+			return
+		}
+		actorID := uint(id)
+		filter.ActorID = &actorID
+	}
+	if value := c.Query("impersonator_id"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			middleware.AbortWithProblem(c, errors.InvalidInputError("impersonator_id", "must be a positive integer"))
+			return
+		}
+		impersonatorID := uint(id)
+		filter.ImpersonatorID = &impersonatorID
+	}
+	var ok bool
+	if filter.From, ok = timeQuery(c, "from"); !ok {
+		return
+	}
+	if filter.To, ok = timeQuery(c, "to"); !ok {
+		return
+	}
+
+	actor, ok := principal(c)
+	if !ok {
+		return
+	}
+
+	events, err := h.service.List(actor, filter, page, limit)
+	if err != nil {
+		middleware.AbortWithProblem(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, events)
+}
+
+// VerifyAuditLog handles checking the hash chain of the audit log for tampering
+func (h *AuditHandler) VerifyAuditLog(c *gin.Context) {
+	actor, ok := principal(c)
+	if !ok {
+		return
+	}
+
+	result, err := h.service.Verify(actor)
+	if err != nil {
+		middleware.AbortWithProblem(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
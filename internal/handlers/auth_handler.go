@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/errors"
+	"UserRESTfulApi/internal/middleware"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AuthHandler struct {
+	service domain.AuthService
+}
+
+// NewAuthHandler creates a new auth handler
+func NewAuthHandler(service domain.AuthService) *AuthHandler {
+	return &AuthHandler{service: service}
+}
+
+// LoginRequest represents the credentials sent to the login endpoint
+type LoginRequest struct {
+	Email    string `json:"email" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// VerifyMFARequest represents the MFA token from the login response and a TOTP or recovery code
+type VerifyMFARequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+// MagicLinkLoginRequest represents a magic link token, or an emailed code and
+// the email address it was sent to
+type MagicLinkLoginRequest struct {
+	Token string `json:"token"`
+	Email string `json:"email"`
+	Code  string `json:"code"`
+}
+
+// RefreshRequest represents a refresh token sent to the refresh and logout endpoints
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// ImpersonateRequest represents why an admin acts as another user
+type ImpersonateRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// Login handles user authentication and access token issuance
+func (h *AuthHandler) Login(c *gin.Context) {
+	var req LoginRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	result, err := h.service.Login(req.Email, req.Password, middleware.RequestMeta(c))
+	writeLoginResult(c, result, err)
+}
+
+// LoginWithMagicLink handles signing in with a magic link token or emailed code
+func (h *AuthHandler) LoginWithMagicLink(c *gin.Context) {
+	var req MagicLinkLoginRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	result, err := h.service.LoginWithMagicLink(req.Token, req.Email, req.Code, middleware.RequestMeta(c))
+	writeLoginResult(c, result, err)
+}
+
+// writeLoginResult writes the tokens or MFA challenge of a login, or its error
+func writeLoginResult(c *gin.Context, result *domain.LoginResult, err error) {
+	if err != nil {
+		middleware.AbortWithProblem(c, err)
+		return
+	}
+
+	if result.MFARequired {
+		c.JSON(http.StatusOK, gin.H{
+			"mfa_required": true,
+			"mfa_token":    result.MFAToken,
+			"expires_at":   result.MFAExpiresAt,
+			"mfa_methods":  result.MFAMethods,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, result.Token)
+}
+
+// VerifyMFA handles the second login step for users with MFA enabled
+func (h *AuthHandler) VerifyMFA(c *gin.Context) {
+	var req VerifyMFARequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	accessToken, err := h.service.VerifyMFA(req.MFAToken, req.Code, middleware.RequestMeta(c))
+	if err != nil {
+		middleware.AbortWithProblem(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, accessToken)
+}
+
+// Refresh handles exchanging a refresh token for a new token pair
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req RefreshRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	accessToken, err := h.service.Refresh(req.RefreshToken, middleware.RequestMeta(c))
+	if err != nil {
+		middleware.AbortWithProblem(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, accessToken)
+}
+
+// Logout handles revoking a refresh token and its family
+func (h *AuthHandler) Logout(c *gin.Context) {
+	var req RefreshRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	err := h.service.Logout(req.RefreshToken, middleware.RequestMeta(c))
+	if err != nil {
+		middleware.AbortWithProblem(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+// LogoutAll handles revoking every refresh token of the authenticated user
+func (h *AuthHandler) LogoutAll(c *gin.Context) {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		middleware.AbortWithProblem(c, errors.UnauthorizedError("authentication required"))
+		return
+	}
+
+	if err := h.service.LogoutAll(principal); err != nil {
+		middleware.AbortWithProblem(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out of all sessions successfully"})
+}
+
+// Impersonate handles issuing a token that lets the authenticated admin act as
+// the user identified by the :id route parameter
+func (h *AuthHandler) Impersonate(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		middleware.AbortWithProblem(c, errors.InvalidInputError("id", "must be a positive integer"))
+		return
+	}
+
+	var req ImpersonateRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	actor, ok := principal(c)
+	if !ok {
+		return
+	}
+
+	accessToken, err := h.service.Impersonate(actor, uint(id), req.Reason)
+	if err != nil {
+		middleware.AbortWithProblem(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, accessToken)
+}
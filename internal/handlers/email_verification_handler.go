@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/middleware"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type EmailVerificationHandler struct {
+	service domain.EmailVerificationService
+}
+
+// NewEmailVerificationHandler creates a new email verification handler
+func NewEmailVerificationHandler(service domain.EmailVerificationService) *EmailVerificationHandler {
+	return &EmailVerificationHandler{service: service}
+}
+
+// VerifyEmailRequest represents a verification token sent to the verify endpoint
+type VerifyEmailRequest struct {
+	Token string `json:"token" form:"token" binding:"required"`
+}
+
+// RevertEmailChangeRequest represents a revert token sent to the old address of an email change
+type RevertEmailChangeRequest struct {
+	Token string `json:"token" form:"token" binding:"required"`
+}
+
+// ResendVerificationRequest represents the email a new verification link is requested for
+type ResendVerificationRequest struct {
+	Email string `json:"email" binding:"required"`
+}
+
+// VerifyEmail handles confirming an email address. The token is read from the
+// query string on GET, so the emailed link works directly, and from the JSON body on POST.
+func (h *EmailVerificationHandler) VerifyEmail(c *gin.Context) {
+	var req VerifyEmailRequest
+	var err error
+	if c.Request.Method == http.MethodGet {
+		err = c.ShouldBindQuery(&req)
+	} else {
+		err = c.ShouldBindJSON(&req)
+	}
+	if err != nil {
+		middleware.AbortWithProblem(c, bindError(&req, err))
+		return
+	}
+
+	user, err := h.service.Verify(req.Token)
+	if err != nil {
+		middleware.AbortWithProblem(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified successfully", "user": newUserResponse(user)})
+}
+
+// ResendVerification handles requesting a new verification email. The
+// response is the same whether or not the email is registered.
+func (h *EmailVerificationHandler) ResendVerification(c *gin.Context) {
+	var req ResendVerificationRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	if err := h.service.Resend(req.Email); err != nil {
+		middleware.AbortWithProblem(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "If the email needs verification, a new link has been sent"})
+}
+
+// ConfirmEmailChangeRevert handles opening the revert link sent to the old
+// address of an email change. Reverting signs out every session, so the link
+// only describes the POST that confirms it and never reverts by itself; a
+// mail scanner or link preview following the link changes nothing.
+func (h *EmailVerificationHandler) ConfirmEmailChangeRevert(c *gin.Context) {
+	var req RevertEmailChangeRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		middleware.AbortWithProblem(c, bindError(&req, err))
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Referrer-Policy", "no-referrer")
+	c.JSON(http.StatusOK, gin.H{
+		"message": "POST the token to confirm reverting the email change, this signs out all sessions",
+		"method":  http.MethodPost,
+		"action":  c.Request.URL.Path,
+		"token":   req.Token,
+	})
+}
+
+// RevertEmailChange handles undoing an email change with the token from the
+// link sent to the old address, read from the JSON body
+func (h *EmailVerificationHandler) RevertEmailChange(c *gin.Context) {
+	var req RevertEmailChangeRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	user, err := h.service.Revert(req.Token, middleware.RequestMeta(c))
+	if err != nil {
+		middleware.AbortWithProblem(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email change reverted, all sessions signed out", "user": newUserResponse(user)})
+}
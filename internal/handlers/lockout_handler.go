@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/middleware"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type LockoutHandler struct {
+	service domain.LoginThrottleService
+}
+
+// NewLockoutHandler creates a new login lockout handler
+func NewLockoutHandler(service domain.LoginThrottleService) *LockoutHandler {
+	return &LockoutHandler{service: service}
+}
+
+// ListLockouts handles listing accounts and IPs with recent failed logins.
+// Pass ?locked=true to only list active locks.
+func (h *LockoutHandler) ListLockouts(c *gin.Context) {
+	actor, ok := principal(c)
+	if !ok {
+		return
+	}
+
+	throttles, err := h.service.List(actor, c.Query("locked") == "true")
+	if err != nil {
+		middleware.AbortWithProblem(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, throttles)
+}
+
+// ClearLockout handles clearing the failed logins and lock of an account or IP
+func (h *LockoutHandler) ClearLockout(c *gin.Context) {
+	actor, ok := principal(c)
+	if !ok {
+		return
+	}
+
+	err := h.service.Clear(actor, c.Param("kind"), c.Param("subject"))
+	if err != nil {
+		middleware.AbortWithProblem(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Lockout cleared successfully"})
+}
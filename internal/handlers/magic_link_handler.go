@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/middleware"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MagicLinkHandler struct {
+	service domain.MagicLinkService
+}
+
+// NewMagicLinkHandler creates a new magic link handler
+func NewMagicLinkHandler(service domain.MagicLinkService) *MagicLinkHandler {
+	return &MagicLinkHandler{service: service}
+}
+
+// SendMagicLinkRequest represents the email a magic link is requested for
+type SendMagicLinkRequest struct {
+	Email string `json:"email" binding:"required"`
+}
+
+// SendMagicLink handles requesting a sign in link and code by email. The
+// response is the same whether or not the email is registered.
+func (h *MagicLinkHandler) SendMagicLink(c *gin.Context) {
+	var req SendMagicLinkRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	if err := h.service.Send(req.Email, middleware.RequestMeta(c)); err != nil {
+		middleware.AbortWithProblem(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "If the email is registered, a sign in link has been sent"})
+}
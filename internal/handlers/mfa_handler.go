@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/errors"
+	"UserRESTfulApi/internal/middleware"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MFAHandler struct {
+	service domain.MFAService
+}
+
+// NewMFAHandler creates a new MFA handler
+func NewMFAHandler(service domain.MFAService) *MFAHandler {
+	return &MFAHandler{service: service}
+}
+
+// ConfirmMFARequest represents a code from the newly enrolled authenticator
+type ConfirmMFARequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// GetStatus handles describing the authenticated user's MFA enrollment
+func (h *MFAHandler) GetStatus(c *gin.Context) {
+	actor, ok := principal(c)
+	if !ok {
+		return
+	}
+
+	status, err := h.service.Status(actor.ID())
+	if err != nil {
+		middleware.AbortWithProblem(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// Enroll handles generating a TOTP secret for the authenticated user
+func (h *MFAHandler) Enroll(c *gin.Context) {
+	actor, ok := principal(c)
+	if !ok {
+		return
+	}
+
+	enrollment, err := h.service.Enroll(actor.User)
+	if err != nil {
+		middleware.AbortWithProblem(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, enrollment)
+}
+
+// Confirm handles enabling MFA with a code from the enrolled authenticator.
+// The response holds the recovery codes, which are shown only once.
+func (h *MFAHandler) Confirm(c *gin.Context) {
+	var req ConfirmMFARequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	actor, ok := principal(c)
+	if !ok {
+		return
+	}
+
+	codes, err := h.service.Confirm(actor.ID(), req.Code)
+	if err != nil {
+		middleware.AbortWithProblem(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "MFA enabled successfully", "recovery_codes": codes})
+}
+
+// ResetUserMFA handles removing the MFA enrollment of a user
+func (h *MFAHandler) ResetUserMFA(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		middleware.AbortWithProblem(c, errors.InvalidInputError("id", "must be a positive integer"))
+		return
+	}
+
+	actor, ok := principal(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.Reset(actor, uint(id)); err != nil {
+		middleware.AbortWithProblem(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "MFA reset successfully"})
+}
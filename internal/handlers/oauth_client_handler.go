@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/middleware"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type OAuthClientHandler struct {
+	service domain.OAuthClientService
+	keys    domain.SigningKeyService
+}
+
+// NewOAuthClientHandler creates a new OAuth client administration handler
+func NewOAuthClientHandler(service domain.OAuthClientService, keys domain.SigningKeyService) *OAuthClientHandler {
+	return &OAuthClientHandler{service: service, keys: keys}
+}
+
+// CreateOAuthClientRequest represents the payload for registering an OAuth client
+type CreateOAuthClientRequest struct {
+	Name         string   `json:"name" binding:"required"`
+	RedirectURIs []string `json:"redirect_uris" binding:"required"`
+	Public       bool     `json:"public"`
+}
+
+// ListClients handles listing every registered OAuth client
+func (h *OAuthClientHandler) ListClients(c *gin.Context) {
+	actor, ok := principal(c)
+	if !ok {
+		return
+	}
+
+	clients, err := h.service.List(actor)
+	if err != nil {
+		middleware.AbortWithProblem(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, clients)
+}
+
+// CreateClient handles OAuth client registration. The response holds the
+// client secret, which is shown only once.
+func (h *OAuthClientHandler) CreateClient(c *gin.Context) {
+	var req CreateOAuthClientRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	actor, ok := principal(c)
+	if !ok {
+		return
+	}
+
+	client := &domain.OAuthClient{Name: req.Name, RedirectURIs: req.RedirectURIs, Public: req.Public}
+	secret, err := h.service.Create(actor, client)
+	if err != nil {
+		middleware.AbortWithProblem(c, err)
+		return
+	}
+
+	response := gin.H{"client": client}
+	if secret != "" {
+		response["client_secret"] = secret
+	}
+	c.JSON(http.StatusCreated, response)
+}
+
+// DeleteClient handles removing an OAuth client and revoking its tokens
+func (h *OAuthClientHandler) DeleteClient(c *gin.Context) {
+	actor, ok := principal(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.Delete(actor, c.Param("client_id")); err != nil {
+		middleware.AbortWithProblem(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "OAuth client deleted successfully"})
+}
+
+// RotateSigningKey handles replacing the active ID token signing key
+func (h *OAuthClientHandler) RotateSigningKey(c *gin.Context) {
+	actor, ok := principal(c)
+	if !ok {
+		return
+	}
+
+	key, err := h.keys.Rotate(actor)
+	if err != nil {
+		middleware.AbortWithProblem(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, key)
+}
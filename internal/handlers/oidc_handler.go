@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"UserRESTfulApi/internal/domain"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type OIDCHandler struct {
+	service  domain.OIDCService
+	clients  domain.OAuthClientService
+	keys     domain.SigningKeyService
+	loginURL string
+}
+
+// NewOIDCHandler creates a new OpenID Connect handler. Valid authorization
+// requests are forwarded to the login page at loginURL.
+func NewOIDCHandler(service domain.OIDCService, clients domain.OAuthClientService, keys domain.SigningKeyService, loginURL string) *OIDCHandler {
+	return &OIDCHandler{service: service, clients: clients, keys: keys, loginURL: loginURL}
+}
+
+// oauthError writes an error in the RFC 6749 format. Errors other than
+// protocol errors are reported as server_error.
+func oauthError(c *gin.Context, err error) {
+	oauthErr, ok := err.(*domain.OAuthError)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, domain.NewOAuthError(domain.OAuthServerError, "Internal server error"))
+		return
+	}
+
+	switch oauthErr.Code {
+	case domain.OAuthInvalidClient:
+		c.Header("WWW-Authenticate", `Basic realm="oauth2"`)
+		c.JSON(http.StatusUnauthorized, oauthErr)
+	case domain.OAuthInvalidToken:
+		c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+		c.JSON(http.StatusUnauthorized, oauthErr)
+	case domain.OAuthInsufficientScope:
+		c.Header("WWW-Authenticate", `Bearer error="insufficient_scope", scope="openid"`)
+		c.JSON(http.StatusForbidden, oauthErr)
+	default:
+		c.JSON(http.StatusBadRequest, oauthErr)
+	}
+}
+
+// errorRedirect returns the client redirect URI carrying a protocol error
+func errorRedirect(req *domain.AuthorizationRequest, err error) string {
+	params := map[string]string{"error": domain.OAuthServerError, "state": req.State}
+	if oauthErr, ok := err.(*domain.OAuthError); ok {
+		params["error"] = oauthErr.Code
+		params["error_description"] = oauthErr.Description
+	}
+	return domain.AuthorizationResponseURI(req.RedirectURI, params)
+}
+
+// authenticateClient reads the client credentials from HTTP Basic auth or
+// the client_id and client_secret form fields and verifies them
+func (h *OIDCHandler) authenticateClient(c *gin.Context) (*domain.OAuthClient, bool) {
+	clientID, secret, basic := c.Request.BasicAuth()
+	if basic {
+		// Basic credentials are form encoded (RFC 6749 section 2.3.1)
+		var err error
+		if clientID, err = url.QueryUnescape(clientID); err == nil {
+			secret, err = url.QueryUnescape(secret)
+		}
+		if err != nil {
+			oauthError(c, domain.NewOAuthError(domain.OAuthInvalidClient, "malformed client credentials"))
+			return nil, false
+		}
+	} else {
+		clientID, secret = c.PostForm("client_id"), c.PostForm("client_secret")
+	}
+	if clientID == "" {
+		oauthError(c, domain.NewOAuthError(domain.OAuthInvalidClient, "client authentication is required"))
+		return nil, false
+	}
+
+	client, err := h.clients.Authenticate(clientID, secret)
+	if err != nil {
+		oauthError(c, err)
+		return nil, false
+	}
+	return client, true
+}
+
+// Discovery handles the OpenID Connect discovery document
+func (h *OIDCHandler) Discovery(c *gin.Context) {
+	c.JSON(http.StatusOK, h.service.Metadata())
+}
+
+// JWKS handles publishing the public keys that verify ID tokens
+func (h *OIDCHandler) JWKS(c *gin.Context) {
+	set, err := h.keys.JWKS()
+	if err != nil {
+		oauthError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, set)
+}
+
+// Authorize handles the authorization endpoint. A valid request is forwarded
+// to the login page, which signs the user in and completes it through Approve.
+// Errors are redirected to the client unless its redirect URI is untrusted.
+func (h *OIDCHandler) Authorize(c *gin.Context) {
+	var req domain.AuthorizationRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		oauthError(c, domain.NewOAuthError(domain.OAuthInvalidRequest, err.Error()))
+		return
+	}
+
+	client, err := h.service.ValidateAuthorization(&req)
+	if err != nil {
+		if client == nil {
+			oauthError(c, err)
+			return
+		}
+		c.Redirect(http.StatusFound, errorRedirect(&req, err))
+		return
+	}
+
+	loginURL, err := url.Parse(h.loginURL)
+	if err != nil {
+		oauthError(c, err)
+		return
+	}
+	loginURL.RawQuery = c.Request.URL.RawQuery
+	c.Redirect(http.StatusFound, loginURL.String())
+}
+
+// Approve handles completing an authorization request for the signed in
+// user. The response names the client redirect URI carrying the code.
+func (h *OIDCHandler) Approve(c *gin.Context) {
+	var req domain.AuthorizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		oauthError(c, domain.NewOAuthError(domain.OAuthInvalidRequest, err.Error()))
+		return
+	}
+
+	actor, ok := principal(c)
+	if !ok {
+		return
+	}
+
+	client, err := h.service.ValidateAuthorization(&req)
+	if err != nil {
+		if client == nil {
+			oauthError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"redirect_to": errorRedirect(&req, err)})
+		return
+	}
+
+	redirectTo, err := h.service.Authorize(actor, &req)
+	if err != nil {
+		oauthError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"redirect_to": redirectTo})
+}
+
+// Token handles exchanging an authorization code or refresh token for tokens
+func (h *OIDCHandler) Token(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	client, ok := h.authenticateClient(c)
+	if !ok {
+		return
+	}
+
+	var req domain.TokenRequest
+	if err := c.ShouldBind(&req); err != nil {
+		oauthError(c, domain.NewOAuthError(domain.OAuthInvalidRequest, err.Error()))
+		return
+	}
+
+	resp, err := h.service.Token(client, &req)
+	if err != nil {
+		oauthError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// UserInfo handles returning the claims of the user an access token belongs to
+func (h *OIDCHandler) UserInfo(c *gin.Context) {
+	accessToken := ""
+	if header := c.GetHeader("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		accessToken = strings.TrimPrefix(header, "Bearer ")
+	}
+	if accessToken == "" {
+		c.Header("WWW-Authenticate", `Bearer realm="oauth2"`)
+		c.Status(http.StatusUnauthorized)
+		return
+	}
+
+	info, err := h.service.UserInfo(accessToken)
+	if err != nil {
+		oauthError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, info)
+}
+
+// Introspect handles describing a token to a resource server (RFC 7662)
+func (h *OIDCHandler) Introspect(c *gin.Context) {
+	client, ok := h.authenticateClient(c)
+	if !ok {
+		return
+	}
+
+	value := c.PostForm("token")
+	if value == "" {
+		oauthError(c, domain.NewOAuthError(domain.OAuthInvalidRequest, "token is required"))
+		return
+	}
+
+	result, err := h.service.Introspect(client, value)
+	if err != nil {
+		oauthError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// Revoke handles token revocation (RFC 7009). Unknown tokens are not an error.
+func (h *OIDCHandler) Revoke(c *gin.Context) {
+	client, ok := h.authenticateClient(c)
+	if !ok {
+		return
+	}
+
+	value := c.PostForm("token")
+	if value == "" {
+		oauthError(c, domain.NewOAuthError(domain.OAuthInvalidRequest, "token is required"))
+		return
+	}
+
+	if err := h.service.Revoke(client, value); err != nil {
+		oauthError(c, err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
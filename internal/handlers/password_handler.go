@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"UserRESTfulApi/internal/middleware"
+	"UserRESTfulApi/pkg/password"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PasswordHandler struct {
+	policy *password.Policy
+}
+
+// NewPasswordHandler creates a new password policy handler
+func NewPasswordHandler(policy *password.Policy) *PasswordHandler {
+	return &PasswordHandler{policy: policy}
+}
+
+// CheckPasswordRequest represents a candidate password to validate against the policy
+type CheckPasswordRequest struct {
+	Password string `json:"password"`
+	Email    string `json:"email"`
+	Name     string `json:"name"`
+}
+
+// CheckPasswordResponse lists every policy rule the candidate password violates
+type CheckPasswordResponse struct {
+	Valid      bool                 `json:"valid"`
+	Violations []password.Violation `json:"violations"`
+}
+
+// GetPolicy returns the active password policy so clients can show the rules up front
+func (h *PasswordHandler) GetPolicy(c *gin.Context) {
+	c.JSON(http.StatusOK, h.policy)
+}
+
+// CheckPassword validates a candidate password without storing it
+func (h *PasswordHandler) CheckPassword(c *gin.Context) {
+	var req CheckPasswordRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	response := CheckPasswordResponse{Valid: true, Violations: []password.Violation{}}
+	if err := h.policy.Validate(req.Password, req.Email, req.Name); err != nil {
+		policyErr, ok := err.(*password.PolicyError)
+		if !ok {
+			middleware.AbortWithProblem(c, err)
+			return
+		}
+		response.Valid = false
+		response.Violations = policyErr.Violations
+	}
+
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/errors"
+	"UserRESTfulApi/internal/middleware"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// userETag returns the strong entity tag of the stored version of a user
+func userETag(user *domain.User) string {
+	return `"` + strconv.FormatUint(uint64(user.Version), 10) + `"`
+}
+
+// writeUser writes a user with its entity tag
+func writeUser(c *gin.Context, status int, user *domain.User) {
+	c.Header("ETag", userETag(user))
+	c.JSON(status, newUserResponse(user))
+}
+
+// ifMatchVersion returns the user version required by the If-Match header,
+// or 0 when the header is absent or "*". Only a single strong entity tag can
+// name a version; it records a precondition failed error and returns false for any other
+// value, since such a tag never matches a user.
+func ifMatchVersion(c *gin.Context) (uint, bool) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" || header == "*" {
+		return 0, true
+	}
+	if len(header) >= 2 && header[0] == '"' && header[len(header)-1] == '"' {
+		version, err := strconv.ParseUint(header[1:len(header)-1], 10, 32)
+		if err == nil && version > 0 {
+			return uint(version), true
+		}
+	}
+	middleware.AbortWithProblem(c, &errors.AppError{Type: errors.PreconditionFailed, Message: "If-Match does not match the current version"})
+	return 0, false
+}
+
+// ifNoneMatch reports whether the If-None-Match header lists the entity tag or
+// is "*". Tags are compared weakly, as RFC 9110 requires for GET.
+func ifNoneMatch(c *gin.Context, etag string) bool {
+	header := strings.TrimSpace(c.GetHeader("If-None-Match"))
+	if header == "*" {
+		return true
+	}
+	for _, tag := range strings.Split(header, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(tag), "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
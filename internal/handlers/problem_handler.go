@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"UserRESTfulApi/internal/errors"
+	"UserRESTfulApi/internal/middleware"
+	"encoding/json"
+	stderrors "errors"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+type ProblemHandler struct{}
+
+// NewProblemHandler creates a new handler serving the catalog of problem types
+func NewProblemHandler() *ProblemHandler {
+	return &ProblemHandler{}
+}
+
+// ListProblems handles listing every problem type error responses can have
+func (h *ProblemHandler) ListProblems(c *gin.Context) {
+	c.JSON(http.StatusOK, errors.Catalog())
+}
+
+// GetProblem handles describing the problem type identified by the :code
+// route parameter, which is where the type URI of a problem resolves
+func (h *ProblemHandler) GetProblem(c *gin.Context) {
+	for _, problem := range errors.Catalog() {
+		if problem.Code == c.Param("code") {
+			c.JSON(http.StatusOK, problem)
+			return
+		}
+	}
+	middleware.AbortWithProblem(c, errors.NotFoundError("problem type", c.Param("code")))
+}
+
+// NoRoute handles requests to unknown routes
+func (h *ProblemHandler) NoRoute(c *gin.Context) {
+	middleware.AbortWithProblem(c, &errors.AppError{Type: errors.NotFound, Message: "No route for " + c.Request.Method + " " + c.Request.URL.Path})
+}
+
+// bindJSON binds the JSON request body into obj, recording an invalid input
+// error for the Problems middleware and returning false when it does not fit
+func bindJSON(c *gin.Context, obj interface{}) bool {
+	if err := c.ShouldBindJSON(obj); err != nil {
+		middleware.AbortWithProblem(c, bindError(obj, err))
+		return false
+	}
+	return true
+}
+
+// bindError converts an error binding a request body into obj into an
+// invalid input error naming the JSON fields at fault, so that decoder and
+// validator internals are not exposed
+func bindError(obj interface{}, err error) error {
+	var validationErrors validator.ValidationErrors
+	if stderrors.As(err, &validationErrors) {
+		details := make([]errors.ErrorDetail, 0, len(validationErrors))
+		for _, fieldErr := range validationErrors {
+			detail := errors.ErrorDetail{Field: jsonFieldName(obj, fieldErr.StructField()), Code: fieldErr.Tag(), Message: "is invalid"}
+			if fieldErr.Tag() == "required" {
+				detail.Message = "is required"
+			}
+			details = append(details, detail)
+		}
+		return errors.ValidationError(details)
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if stderrors.As(err, &typeErr) && typeErr.Field != "" {
+		return errors.ValidationError([]errors.ErrorDetail{{Field: typeErr.Field, Code: "type", Message: "has the wrong type"}})
+	}
+	return errors.InvalidInputError("body", "must be a JSON object")
+}
+
+// jsonFieldName returns the JSON name of a field of the struct obj points to
+func jsonFieldName(obj interface{}, field string) string {
+	t := reflect.TypeOf(obj)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Struct {
+		if structField, ok := t.FieldByName(field); ok {
+			if name, _, _ := strings.Cut(structField.Tag.Get("json"), ","); name != "" && name != "-" {
+				return name
+			}
+		}
+	}
+	return field
+}
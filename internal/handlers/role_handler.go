@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/errors"
+	"UserRESTfulApi/internal/middleware"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RoleHandler struct {
+	service domain.RoleService
+}
+
+// NewRoleHandler creates a new role handler
+func NewRoleHandler(service domain.RoleService) *RoleHandler {
+	return &RoleHandler{service: service}
+}
+
+// CreateRoleRequest represents the payload for creating a custom role
+type CreateRoleRequest struct {
+	Name        string              `json:"name" binding:"required"`
+	Description string              `json:"description"`
+	Permissions []domain.Permission `json:"permissions" binding:"required"`
+}
+
+// AssignRoleRequest represents the payload for assigning a role to a user
+type AssignRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+// RoleResponse is the public representation of a role
+type RoleResponse struct {
+	ID          uint                `json:"id"`
+	Name        string              `json:"name"`
+	Description string              `json:"description"`
+	Builtin     bool                `json:"builtin"`
+	Permissions []domain.Permission `json:"permissions"`
+	CreatedAt   time.Time           `json:"created_at"`
+	UpdatedAt   time.Time           `json:"updated_at"`
+}
+
+func newRoleResponse(role *domain.Role) RoleResponse {
+	return RoleResponse{
+		ID:          role.ID,
+		Name:        role.Name,
+		Description: role.Description,
+		Builtin:     role.Builtin,
+		Permissions: role.PermissionList(),
+		CreatedAt:   role.CreatedAt,
+		UpdatedAt:   role.UpdatedAt,
+	}
+}
+
+func newRoleListResponse(roles []*domain.Role) []RoleResponse {
+	responses := make([]RoleResponse, 0, len(roles))
+	for _, role := range roles {
+		responses = append(responses, newRoleResponse(role))
+	}
+	return responses
+}
+
+// ListRoles handles listing every role
+func (h *RoleHandler) ListRoles(c *gin.Context) {
+	actor, ok := principal(c)
+	if !ok {
+		return
+	}
+
+	roles, err := h.service.List(actor)
+	if err != nil {
+		middleware.AbortWithProblem(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newRoleListResponse(roles))
+}
+
+// CreateRole handles custom role creation
+func (h *RoleHandler) CreateRole(c *gin.Context) {
+	var req CreateRoleRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	actor, ok := principal(c)
+	if !ok {
+		return
+	}
+
+	role := &domain.Role{Name: req.Name, Description: req.Description}
+	for _, permission := range req.Permissions {
+		role.Permissions = append(role.Permissions, domain.RolePermission{Permission: permission})
+	}
+
+	if err := h.service.Create(actor, role); err != nil {
+		middleware.AbortWithProblem(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, newRoleResponse(role))
+}
+
+// DeleteRole handles custom role deletion
+func (h *RoleHandler) DeleteRole(c *gin.Context) {
+	actor, ok := principal(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.Delete(actor, c.Param("name")); err != nil {
+		middleware.AbortWithProblem(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role deleted successfully"})
+}
+
+// GetUserRoles handles listing the roles assigned to a user
+func (h *RoleHandler) GetUserRoles(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		middleware.AbortWithProblem(c, errors.InvalidInputError("id", "must be a positive integer"))
+		return
+	}
+
+	actor, ok := principal(c)
+	if !ok {
+		return
+	}
+
+	roles, err := h.service.GetUserRoles(actor, uint(id))
+	if err != nil {
+		middleware.AbortWithProblem(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newRoleListResponse(roles))
+}
+
+// AssignUserRole handles assigning a role to a user
+func (h *RoleHandler) AssignUserRole(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		middleware.AbortWithProblem(c, errors.InvalidInputError("id", "must be a positive integer"))
+		return
+	}
+
+	var req AssignRoleRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	actor, ok := principal(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.AssignToUser(actor, uint(id), req.Role); err != nil {
+		middleware.AbortWithProblem(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role assigned successfully"})
+}
+
+// RemoveUserRole handles removing a role from a user
+func (h *RoleHandler) RemoveUserRole(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		middleware.AbortWithProblem(c, errors.InvalidInputError("id", "must be a positive integer"))
+		return
+	}
+
+	actor, ok := principal(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.RemoveFromUser(actor, uint(id), c.Param("role")); err != nil {
+		middleware.AbortWithProblem(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role removed successfully"})
+}
@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/errors"
+	"UserRESTfulApi/internal/middleware"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// scimContentType is the media type of SCIM requests and responses (RFC 7644 section 8.1)
+const scimContentType = "application/scim+json"
+
+type SCIMHandler struct {
+	service domain.SCIMService
+}
+
+// NewSCIMHandler creates a new SCIM provisioning handler
+func NewSCIMHandler(service domain.SCIMService) *SCIMHandler {
+	return &SCIMHandler{service: service}
+}
+
+// writeSCIM writes a SCIM response body
+func writeSCIM(c *gin.Context, status int, body interface{}) {
+	c.Header("Content-Type", scimContentType)
+	c.JSON(status, body)
+}
+
+// SCIMAbort writes an authentication failure in the SCIM error format, for
+// use with middleware.AuthWithErrors
+func SCIMAbort(c *gin.Context, status int, message string) {
+	c.Header("Content-Type", scimContentType)
+	c.AbortWithStatusJSON(status, domain.NewSCIMError(status, "", message))
+}
+
+// scimError writes an error in the SCIM format. Application errors are
+// mapped to the matching status, anything else is an internal server error.
+func scimError(c *gin.Context, err error) {
+	scimErr, ok := err.(*domain.SCIMError)
+	if !ok {
+		scimErr = domain.NewSCIMError(http.StatusInternalServerError, "", "Internal server error")
+		if appErr, isAppErr := err.(*errors.AppError); isAppErr {
+			switch appErr.Type {
+			case errors.NotFound:
+				scimErr = domain.NewSCIMError(http.StatusNotFound, "", appErr.Error())
+			case errors.Forbidden:
+				scimErr = domain.NewSCIMError(http.StatusForbidden, "", appErr.Error())
+			case errors.InvalidInput, errors.InvalidEmail, errors.InvalidPassword:
+				scimErr = domain.NewSCIMError(http.StatusBadRequest, domain.SCIMInvalidValue, appErr.Error())
+			case errors.DuplicateEmail:
+				scimErr = domain.NewSCIMError(http.StatusConflict, domain.SCIMUniqueness, appErr.Error())
+			case errors.PreconditionFailed:
+				scimErr = domain.NewSCIMError(http.StatusPreconditionFailed, "", appErr.Error())
+			}
+		}
+	}
+
+	status, _ := strconv.Atoi(scimErr.Status)
+	writeSCIM(c, status, scimErr)
+}
+
+// scimPrincipal returns the authenticated principal, writing a SCIM 401 response when there is none
+func scimPrincipal(c *gin.Context) (*domain.Principal, bool) {
+	actor, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		scimError(c, domain.NewSCIMError(http.StatusUnauthorized, "", "Authentication required"))
+	}
+	return actor, ok
+}
+
+// bindSCIM decodes the JSON request body, writing a SCIM 400 response on failure
+func bindSCIM(c *gin.Context, body interface{}) bool {
+	if err := c.ShouldBindJSON(body); err != nil {
+		scimError(c, domain.NewSCIMError(http.StatusBadRequest, domain.SCIMInvalidSyntax, err.Error()))
+		return false
+	}
+	return true
+}
+
+// ServiceProviderConfig handles describing the supported SCIM features
+func (h *SCIMHandler) ServiceProviderConfig(c *gin.Context) {
+	writeSCIM(c, http.StatusOK, h.service.ServiceProviderConfig())
+}
+
+// ListResourceTypes handles listing the provisioned resource types
+func (h *SCIMHandler) ListResourceTypes(c *gin.Context) {
+	resourceTypes := h.service.ResourceTypes()
+	writeSCIM(c, http.StatusOK, &domain.SCIMListResponse{
+		Schemas:      []string{domain.SCIMListResponseSchema},
+		TotalResults: int64(len(resourceTypes)),
+		StartIndex:   1,
+		ItemsPerPage: len(resourceTypes),
+		Resources:    resourceTypes,
+	})
+}
+
+// GetResourceType handles retrieving a resource type by its ID
+func (h *SCIMHandler) GetResourceType(c *gin.Context) {
+	for _, resourceType := range h.service.ResourceTypes() {
+		if resourceType.ID == c.Param("id") {
+			writeSCIM(c, http.StatusOK, resourceType)
+			return
+		}
+	}
+	scimError(c, domain.NewSCIMError(http.StatusNotFound, "", "Resource type "+c.Param("id")+" not found"))
+}
+
+// ListSchemas handles listing the schemas of the provisioned resources
+func (h *SCIMHandler) ListSchemas(c *gin.Context) {
+	schemas := h.service.Schemas()
+	writeSCIM(c, http.StatusOK, &domain.SCIMListResponse{
+		Schemas:      []string{domain.SCIMListResponseSchema},
+		TotalResults: int64(len(schemas)),
+		StartIndex:   1,
+		ItemsPerPage: len(schemas),
+		Resources:    schemas,
+	})
+}
+
+// GetSchema handles retrieving a schema by its URN
+func (h *SCIMHandler) GetSchema(c *gin.Context) {
+	for _, schema := range h.service.Schemas() {
+		if schema.ID == c.Param("id") {
+			writeSCIM(c, http.StatusOK, schema)
+			return
+		}
+	}
+	scimError(c, domain.NewSCIMError(http.StatusNotFound, "", "Schema "+c.Param("id")+" not found"))
+}
+
+// ListUsers handles querying users with the filter, startIndex and count parameters
+func (h *SCIMHandler) ListUsers(c *gin.Context) {
+	actor, ok := scimPrincipal(c)
+	if !ok {
+		return
+	}
+
+	startIndex, count := 1, -1
+	for name, target := range map[string]*int{"startIndex": &startIndex, "count": &count} {
+		value := c.Query(name)
+		if value == "" {
+			continue
+		}
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			scimError(c, domain.NewSCIMError(http.StatusBadRequest, domain.SCIMInvalidValue, name+" must be an integer"))
+			return
+		}
+		// A negative count asks for no results (RFC 7644 section 3.4.2.4)
+		*target = max(parsed, 0)
+	}
+
+	list, err := h.service.List(actor, c.Query("filter"), startIndex, count)
+	if err != nil {
+		scimError(c, err)
+		return
+	}
+
+	writeSCIM(c, http.StatusOK, list)
+}
+
+// GetUser handles retrieving a user
+func (h *SCIMHandler) GetUser(c *gin.Context) {
+	actor, ok := scimPrincipal(c)
+	if !ok {
+		return
+	}
+
+	user, err := h.service.Get(actor, c.Param("id"))
+	if err != nil {
+		scimError(c, err)
+		return
+	}
+
+	writeSCIM(c, http.StatusOK, user)
+}
+
+// CreateUser handles provisioning a user
+func (h *SCIMHandler) CreateUser(c *gin.Context) {
+	actor, ok := scimPrincipal(c)
+	if !ok {
+		return
+	}
+
+	var req domain.SCIMUser
+	if !bindSCIM(c, &req) {
+		return
+	}
+
+	user, err := h.service.Create(actor, &req)
+	if err != nil {
+		scimError(c, err)
+		return
+	}
+
+	c.Header("Location", user.Meta.Location)
+	writeSCIM(c, http.StatusCreated, user)
+}
+
+// ReplaceUser handles replacing the attributes of a user
+func (h *SCIMHandler) ReplaceUser(c *gin.Context) {
+	actor, ok := scimPrincipal(c)
+	if !ok {
+		return
+	}
+
+	var req domain.SCIMUser
+	if !bindSCIM(c, &req) {
+		return
+	}
+
+	user, err := h.service.Replace(actor, c.Param("id"), &req)
+	if err != nil {
+		scimError(c, err)
+		return
+	}
+
+	writeSCIM(c, http.StatusOK, user)
+}
+
+// PatchUser handles modifying a user with PATCH operations
+func (h *SCIMHandler) PatchUser(c *gin.Context) {
+	actor, ok := scimPrincipal(c)
+	if !ok {
+		return
+	}
+
+	var req domain.SCIMPatchRequest
+	if !bindSCIM(c, &req) {
+		return
+	}
+
+	user, err := h.service.Patch(actor, c.Param("id"), &req)
+	if err != nil {
+		scimError(c, err)
+		return
+	}
+
+	writeSCIM(c, http.StatusOK, user)
+}
+
+// DeleteUser handles deprovisioning a user
+func (h *SCIMHandler) DeleteUser(c *gin.Context) {
+	actor, ok := scimPrincipal(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.Delete(actor, c.Param("id")); err != nil {
+		scimError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/errors"
+	"UserRESTfulApi/internal/middleware"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SessionHandler struct {
+	service domain.SessionService
+}
+
+// NewSessionHandler creates a new session handler
+func NewSessionHandler(service domain.SessionService) *SessionHandler {
+	return &SessionHandler{service: service}
+}
+
+// ListSessions handles listing the devices a user is signed in on
+func (h *SessionHandler) ListSessions(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		middleware.AbortWithProblem(c, errors.InvalidInputError("id", "must be a positive integer"))
+		return
+	}
+
+	actor, ok := principal(c)
+	if !ok {
+		return
+	}
+
+	sessions, err := h.service.List(actor, uint(id))
+	if err != nil {
+		middleware.AbortWithProblem(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, sessions)
+}
+
+// RevokeSession handles signing a user out of one session
+func (h *SessionHandler) RevokeSession(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		middleware.AbortWithProblem(c, errors.InvalidInputError("id", "must be a positive integer"))
+		return
+	}
+
+	actor, ok := principal(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.Revoke(actor, uint(id), c.Param("sid")); err != nil {
+		middleware.AbortWithProblem(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked successfully"})
+}
+
+// RevokeAllSessions handles signing a user out of every session
+func (h *SessionHandler) RevokeAllSessions(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		middleware.AbortWithProblem(c, errors.InvalidInputError("id", "must be a positive integer"))
+		return
+	}
+
+	actor, ok := principal(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.RevokeAll(actor, uint(id)); err != nil {
+		middleware.AbortWithProblem(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "All sessions revoked successfully"})
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"UserRESTfulApi/internal/domain"
+	"time"
+)
+
+// CreateUserRequest represents the payload accepted when creating a user
+type CreateUserRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	Name     string `json:"name"`
+}
+
+// toDomain converts the request into a user entity
+func (r CreateUserRequest) toDomain() *domain.User {
+	return &domain.User{
+		Email:    r.Email,
+		Password: r.Password,
+		Name:     r.Name,
+	}
+}
+
+// UpdateUserRequest represents the payload accepted when updating a user.
+// Password is optional; when omitted the current password is kept.
+type UpdateUserRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password,omitempty"`
+	Name     string `json:"name"`
+}
+
+// toDomain converts the request into a user entity with the given ID
+func (r UpdateUserRequest) toDomain(id uint) *domain.User {
+	return &domain.User{
+		ID:       id,
+		Email:    r.Email,
+		Password: r.Password,
+		Name:     r.Name,
+	}
+}
+
+// UserResponse represents a user as returned by the API. It deliberately
+// has no password field so the stored hash can never be serialized.
+type UserResponse struct {
+	ID              uint       `json:"id"`
+	Email           string     `json:"email"`
+	Name            string     `json:"name"`
+	EmailVerified   bool       `json:"email_verified"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+	PendingEmail    string     `json:"pending_email,omitempty"`
+	Active          bool       `json:"active"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// newUserResponse builds the response representation of a user
+func newUserResponse(user *domain.User) UserResponse {
+	return UserResponse{
+		ID:              user.ID,
+		Email:           user.Email,
+		Name:            user.Name,
+		EmailVerified:   user.IsEmailVerified(),
+		EmailVerifiedAt: user.EmailVerifiedAt,
+		PendingEmail:    user.PendingEmail,
+		Active:          user.IsActive(),
+		CreatedAt:       user.CreatedAt,
+		UpdatedAt:       user.UpdatedAt,
+	}
+}
+
+// UserPageResponse represents a page of users. The cursors continue the
+// listing with the after and before query parameters and are omitted at its
+// ends; Total is only present when requested.
+type UserPageResponse struct {
+	Data           []UserResponse `json:"data"`
+	NextCursor     string         `json:"next_cursor,omitempty"`
+	PrevCursor     string         `json:"prev_cursor,omitempty"`
+	Total          *int64         `json:"total,omitempty"`
+	TotalEstimated bool           `json:"total_estimated,omitempty"`
+}
+
+// newUserPageResponse builds the response representation of a page of users
+func newUserPageResponse(page *domain.UserPage) UserPageResponse {
+	response := UserPageResponse{
+		Data:           make([]UserResponse, 0, len(page.Users)),
+		NextCursor:     page.NextCursor,
+		PrevCursor:     page.PrevCursor,
+		Total:          page.Total,
+		TotalEstimated: page.TotalEstimated,
+	}
+	for _, user := range page.Users {
+		response.Data = append(response.Data, newUserResponse(user))
+	}
+	return response
+}
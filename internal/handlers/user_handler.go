@@ -3,8 +3,14 @@ package handlers
 import (
 	"UserRESTfulApi/internal/domain"
 	"UserRESTfulApi/internal/errors"
+	"UserRESTfulApi/internal/middleware"
+	"fmt"
 	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
@@ -18,141 +24,244 @@ func NewUserHandler(service domain.UserService) *UserHandler {
 	return &UserHandler{service: service}
 }
 
+// principal returns the authenticated principal, recording an unauthorized
+// error when there is none
+func principal(c *gin.Context) (*domain.Principal, bool) {
+	actor, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		middleware.AbortWithProblem(c, errors.UnauthorizedError("authentication required"))
+	}
+	return actor, ok
+}
+
 // CreateUser handles user creation
 func (h *UserHandler) CreateUser(c *gin.Context) {
-	var user domain.User
-	if err := c.ShouldBindJSON(&user); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	var req CreateUserRequest
+	if !bindJSON(c, &req) {
 		return
 	}
 
-	err := h.service.Create(&user)
+	user := req.toDomain()
+	err := h.service.Create(user, middleware.RequestMeta(c))
 	if err != nil {
-		appErr, ok := err.(*errors.AppError)
-		if !ok {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
-			return
-		}
-
-		switch appErr.Type {
-		case errors.InvalidEmail, errors.InvalidPassword, errors.InvalidInput:
-			c.JSON(http.StatusBadRequest, gin.H{"error": appErr.Error()})
-		case errors.DuplicateEmail:
-			c.JSON(http.StatusConflict, gin.H{"error": appErr.Error()})
-		default:
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
-		}
+		middleware.AbortWithProblem(c, err)
 		return
 	}
 
-	c.JSON(http.StatusCreated, user)
+	writeUser(c, http.StatusCreated, user)
 }
 
 // GetUser handles user retrieval
 func (h *UserHandler) GetUser(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		middleware.AbortWithProblem(c, errors.InvalidInputError("id", "must be a positive integer"))
 		return
 	}
 
-	user, err := h.service.Get(uint(id))
-	if err != nil {
-		appErr, ok := err.(*errors.AppError)
-		if !ok {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
-			return
-		}
+	actor, ok := principal(c)
+	if !ok {
+		return
+	}
 
-		switch appErr.Type {
-		case errors.NotFound:
-			c.JSON(http.StatusNotFound, gin.H{"error": appErr.Error()})
-		default:
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
-		}
+	user, err := h.service.Get(actor, uint(id))
+	if err != nil {
+		middleware.AbortWithProblem(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, user)
+	if ifNoneMatch(c, userETag(user)) {
+		c.Header("ETag", userETag(user))
+		c.Status(http.StatusNotModified)
+		return
+	}
+	writeUser(c, http.StatusOK, user)
 }
 
 // UpdateUser handles user updates
 func (h *UserHandler) UpdateUser(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		middleware.AbortWithProblem(c, errors.InvalidInputError("id", "must be a positive integer"))
 		return
 	}
 
-	var user domain.User
-	if err := c.ShouldBindJSON(&user); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	var req UpdateUserRequest
+	if !bindJSON(c, &req) {
 		return
 	}
 
-	user.ID = uint(id)
-	err = h.service.Update(&user)
+	user := req.toDomain(uint(id))
+	version, ok := ifMatchVersion(c)
+	if !ok {
+		return
+	}
+	user.Version = version
+	actor, ok := principal(c)
+	if !ok {
+		return
+	}
+
+	err = h.service.Update(actor, user)
 	if err != nil {
-		appErr, ok := err.(*errors.AppError)
-		if !ok {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
-			return
-		}
+		middleware.AbortWithProblem(c, err)
+		return
+	}
 
-		switch appErr.Type {
-		case errors.NotFound:
-			c.JSON(http.StatusNotFound, gin.H{"error": appErr.Error()})
-		case errors.InvalidEmail, errors.InvalidPassword, errors.InvalidInput:
-			c.JSON(http.StatusBadRequest, gin.H{"error": appErr.Error()})
-		case errors.DuplicateEmail:
-			c.JSON(http.StatusConflict, gin.H{"error": appErr.Error()})
-		default:
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
-		}
+	writeUser(c, http.StatusOK, user)
+}
+
+// PatchUser handles partial user updates with a JSON Merge Patch or a JSON
+// Patch, chosen by the Content-Type of the request
+func (h *UserHandler) PatchUser(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		middleware.AbortWithProblem(c, errors.InvalidInputError("id", "must be a positive integer"))
+		return
+	}
+
+	contentType := c.ContentType()
+	if contentType != domain.MergePatchContentType && contentType != domain.JSONPatchContentType {
+		c.Header("Accept-Patch", domain.MergePatchContentType+", "+domain.JSONPatchContentType)
+		middleware.AbortWithProblem(c, errors.UnsupportedMediaTypeError(domain.MergePatchContentType, domain.JSONPatchContentType))
+		return
+	}
+	body, err := c.GetRawData()
+	if err != nil {
+		middleware.AbortWithProblem(c, errors.InvalidInputError("body", "could not be read"))
+		return
+	}
+	version, ok := ifMatchVersion(c)
+	if !ok {
+		return
+	}
+
+	actor, ok := principal(c)
+	if !ok {
+		return
+	}
+
+	user, err := h.service.Patch(actor, uint(id), &domain.UserPatch{ContentType: contentType, Body: body, Version: version})
+	if err != nil {
+		middleware.AbortWithProblem(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, user)
+	writeUser(c, http.StatusOK, user)
 }
 
 // DeleteUser handles user deletion
 func (h *UserHandler) DeleteUser(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		middleware.AbortWithProblem(c, errors.InvalidInputError("id", "must be a positive integer"))
+		return
+	}
+
+	version, ok := ifMatchVersion(c)
+	if !ok {
+		return
+	}
+	actor, ok := principal(c)
+	if !ok {
 		return
 	}
 
-	err = h.service.Delete(uint(id))
+	err = h.service.Delete(actor, uint(id), version)
 	if err != nil {
-		appErr, ok := err.(*errors.AppError)
-		if !ok {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
-			return
+		middleware.AbortWithProblem(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
+}
+
+// filterParamPattern matches filter[field] and filter[field][operator] query parameters
+var filterParamPattern = regexp.MustCompile(`^filter\[([a-z_]+)\](?:\[([a-z_]+)\])?$`)
+
+// userListParams collects the filter, sort and q query parameters of a user
+// listing, recording an invalid input error for a malformed filter parameter
+func userListParams(c *gin.Context) (domain.UserListParams, bool) {
+	params := domain.UserListParams{Sort: c.Query("sort"), Search: c.Query("q")}
+
+	query := c.Request.URL.Query()
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		if strings.HasPrefix(key, "filter") {
+			keys = append(keys, key)
 		}
+	}
+	sort.Strings(keys)
 
-		switch appErr.Type {
-		case errors.NotFound:
-			c.JSON(http.StatusNotFound, gin.H{"error": appErr.Error()})
-		default:
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+	for _, key := range keys {
+		match := filterParamPattern.FindStringSubmatch(key)
+		if match == nil {
+			middleware.AbortWithProblem(c, errors.InvalidInputError(key, "expected filter[field] or filter[field][operator]"))
+			return domain.UserListParams{}, false
+		}
+		for _, value := range query[key] {
+			params.Filters = append(params.Filters, domain.UserListFilter{Field: match[1], Operator: match[2], Value: value})
 		}
-		return
 	}
+	return params, true
+}
 
-	c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
+// setPageLinks sets an RFC 8288 Link header pointing to the first, next and
+// previous pages of the listing, keeping its other query parameters
+func setPageLinks(c *gin.Context, page *domain.UserPage) {
+	link := func(param, cursor, rel string) string {
+		query := c.Request.URL.Query()
+		query.Del("after")
+		query.Del("before")
+		if param != "" {
+			query.Set(param, cursor)
+		}
+		target := url.URL{Path: c.Request.URL.Path, RawQuery: query.Encode()}
+		return fmt.Sprintf("<%s>; rel=%q", target.String(), rel)
+	}
+
+	links := []string{link("", "", "first")}
+	if page.PrevCursor != "" {
+		links = append(links, link("before", page.PrevCursor, "prev"))
+	}
+	if page.NextCursor != "" {
+		links = append(links, link("after", page.NextCursor, "next"))
+	}
+	c.Header("Link", strings.Join(links, ", "))
 }
 
-// ListUsers handles user listing with pagination
+// ListUsers handles listing a page of users. Users can be filtered with
+// ?filter[field]= and ?filter[field][operator]=, searched with ?q= and
+// ordered with ?sort=, e.g. sort=-created_at,name. Pages are selected with
+// ?limit= and the ?after= or ?before= cursors of a previous page, and
+// ?total=exact or ?total=estimate counts the matching users.
 func (h *UserHandler) ListUsers(c *gin.Context) {
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	var limit int
+	if value := c.Query("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 {
+			middleware.AbortWithProblem(c, errors.InvalidInputError("limit", "must be a positive integer"))
+			return
+		}
+	}
+	pageParams := domain.UserPageParams{Limit: limit, After: c.Query("after"), Before: c.Query("before"), Total: c.Query("total")}
+
+	params, ok := userListParams(c)
+	if !ok {
+		return
+	}
+
+	actor, ok := principal(c)
+	if !ok {
+		return
+	}
 
-	users, err := h.service.List(page, limit)
+	page, err := h.service.List(actor, params, pageParams)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		middleware.AbortWithProblem(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, users)
+	setPageLinks(c, page)
+	c.JSON(http.StatusOK, newUserPageResponse(page))
 }
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/errors"
+	"UserRESTfulApi/internal/middleware"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type WebAuthnHandler struct {
+	service domain.WebAuthnService
+	auth    domain.AuthService
+}
+
+// NewWebAuthnHandler creates a new passkey handler
+func NewWebAuthnHandler(service domain.WebAuthnService, auth domain.AuthService) *WebAuthnHandler {
+	return &WebAuthnHandler{service: service, auth: auth}
+}
+
+// RegisterPasskeyRequest represents the authenticator's response to the registration options
+type RegisterPasskeyRequest struct {
+	Name       string                     `json:"name"`
+	Credential domain.WebAuthnAttestation `json:"credential"`
+}
+
+// PasskeyMFARequest represents the MFA token from the login response
+type PasskeyMFARequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+}
+
+// VerifyPasskeyMFARequest represents the MFA token from the login response and a passkey assertion
+type VerifyPasskeyMFARequest struct {
+	MFAToken   string                   `json:"mfa_token" binding:"required"`
+	Credential domain.WebAuthnAssertion `json:"credential"`
+}
+
+// BeginRegistration handles creating the options for registering a passkey
+func (h *WebAuthnHandler) BeginRegistration(c *gin.Context) {
+	actor, ok := principal(c)
+	if !ok {
+		return
+	}
+
+	options, err := h.service.BeginRegistration(actor)
+	if err != nil {
+		middleware.AbortWithProblem(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, options)
+}
+
+// FinishRegistration handles storing the passkey the authenticator created
+func (h *WebAuthnHandler) FinishRegistration(c *gin.Context) {
+	var req RegisterPasskeyRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	actor, ok := principal(c)
+	if !ok {
+		return
+	}
+
+	credential, err := h.service.FinishRegistration(actor, req.Name, &req.Credential)
+	if err != nil {
+		middleware.AbortWithProblem(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, credential)
+}
+
+// ListPasskeys handles listing the authenticated user's passkeys
+func (h *WebAuthnHandler) ListPasskeys(c *gin.Context) {
+	actor, ok := principal(c)
+	if !ok {
+		return
+	}
+
+	credentials, err := h.service.List(actor)
+	if err != nil {
+		middleware.AbortWithProblem(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, credentials)
+}
+
+// DeletePasskey handles removing one of the authenticated user's passkeys
+func (h *WebAuthnHandler) DeletePasskey(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		middleware.AbortWithProblem(c, errors.InvalidInputError("id", "must be a positive integer"))
+		return
+	}
+
+	actor, ok := principal(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.Delete(actor, uint(id)); err != nil {
+		middleware.AbortWithProblem(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Passkey deleted successfully"})
+}
+
+// BeginLogin handles creating the options for signing in with a passkey
+func (h *WebAuthnHandler) BeginLogin(c *gin.Context) {
+	options, err := h.service.BeginLogin()
+	if err != nil {
+		middleware.AbortWithProblem(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, options)
+}
+
+// FinishLogin handles signing in with a passkey
+func (h *WebAuthnHandler) FinishLogin(c *gin.Context) {
+	var req domain.WebAuthnAssertion
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	accessToken, err := h.auth.LoginWithPasskey(&req, middleware.RequestMeta(c))
+	if err != nil {
+		middleware.AbortWithProblem(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, accessToken)
+}
+
+// BeginMFA handles creating the options for completing an MFA login with a passkey
+func (h *WebAuthnHandler) BeginMFA(c *gin.Context) {
+	var req PasskeyMFARequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	options, err := h.auth.BeginPasskeyMFA(req.MFAToken)
+	if err != nil {
+		middleware.AbortWithProblem(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, options)
+}
+
+// VerifyMFA handles completing an MFA login with a passkey
+func (h *WebAuthnHandler) VerifyMFA(c *gin.Context) {
+	var req VerifyPasskeyMFARequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	accessToken, err := h.auth.VerifyPasskeyMFA(req.MFAToken, &req.Credential, middleware.RequestMeta(c))
+	if err != nil {
+		middleware.AbortWithProblem(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, accessToken)
+}
@@ -0,0 +1,160 @@
+package middleware
+
+import (
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// principalContextKey is the gin context key holding the authenticated principal
+const principalContextKey = "auth.principal"
+
+// apiKeyHeader carries an API key for machine-to-machine requests
+const apiKeyHeader = "X-API-Key"
+
+// ImpersonatedByHeader is set on responses to requests made with an
+// impersonation token, carrying the ID of the impersonating user so clients
+// can show a banner
+const ImpersonatedByHeader = "X-Impersonated-By"
+
+// Auth middleware validates the bearer token or API key and stores the authenticated principal on the context
+func Auth(authService domain.AuthService, apiKeys domain.APIKeyService) gin.HandlerFunc {
+	return AuthWithErrors(authService, apiKeys, func(c *gin.Context, status int, message string) {
+		errorType := errors.Unauthorized
+		if status != http.StatusUnauthorized {
+			errorType = errors.InternalServer
+		}
+		AbortWithProblem(c, &errors.AppError{Type: errorType, Message: message})
+	})
+}
+
+// AuthWithErrors works like Auth but reports failures through abort, for
+// routes that use a different error format than the rest of the API
+func AuthWithErrors(authService domain.AuthService, apiKeys domain.APIKeyService, abort func(c *gin.Context, status int, message string)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var principal *domain.Principal
+		var err error
+		if apiKey := strings.TrimSpace(c.GetHeader(apiKeyHeader)); apiKey != "" {
+			principal, err = apiKeys.Authenticate(apiKey, c.ClientIP())
+		} else {
+			header := c.GetHeader("Authorization")
+			scheme, accessToken, found := strings.Cut(header, " ")
+			if !found || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(accessToken) == "" {
+				c.Header("WWW-Authenticate", `Bearer realm="api"`)
+				abort(c, http.StatusUnauthorized, "Missing or malformed bearer token")
+				return
+			}
+			principal, err = authService.Authenticate(strings.TrimSpace(accessToken), c.ClientIP())
+		}
+
+		if err != nil {
+			appErr, ok := err.(*errors.AppError)
+			if ok && appErr.Type == errors.Unauthorized {
+				c.Header("WWW-Authenticate", `Bearer realm="api", error="invalid_token"`)
+				abort(c, http.StatusUnauthorized, appErr.Error())
+				return
+			}
+			abort(c, http.StatusInternalServerError, "Internal server error")
+			return
+		}
+
+		principal.Request = RequestMeta(c)
+		if principal.Impersonated() {
+			c.Header(ImpersonatedByHeader, strconv.FormatUint(uint64(principal.Impersonator.ID), 10))
+			log.Printf("User %d impersonating user %d: %s %s (request %s)", principal.Impersonator.ID, principal.ID(),
+				c.Request.Method, c.Request.URL.Path, principal.Request.RequestID)
+		}
+		c.Set(principalContextKey, principal)
+		c.Next()
+	}
+}
+
+// RequirePermission middleware rejects principals that do not hold the permission.
+// It must be registered after Auth.
+func RequirePermission(permission domain.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := CurrentPrincipal(c)
+		if !ok {
+			AbortWithProblem(c, errors.UnauthorizedError("authentication required"))
+			return
+		}
+		if !principal.Can(permission) {
+			AbortWithProblem(c, errors.ForbiddenError("use this endpoint without the "+string(permission)+" permission"))
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireUserPermission middleware rejects principals that may not perform the
+// action on the user identified by the :id route parameter, allowing the
+// ":self" variant of the permission when the principal is that user.
+// It must be registered after Auth.
+func RequireUserPermission(permission domain.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := CurrentPrincipal(c)
+		if !ok {
+			AbortWithProblem(c, errors.UnauthorizedError("authentication required"))
+			return
+		}
+
+		// Malformed IDs are left for the handler to reject with 400
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err == nil && !principal.CanAccessUser(permission, uint(id)) {
+			AbortWithProblem(c, errors.ForbiddenError("use this endpoint without the "+string(permission)+" permission"))
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireUserSession middleware rejects requests authenticated with an API key,
+// for routes that act on the signed in user's own session or credentials.
+// It must be registered after Auth.
+func RequireUserSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := CurrentPrincipal(c)
+		if !ok {
+			AbortWithProblem(c, errors.UnauthorizedError("authentication required"))
+			return
+		}
+		if principal.APIKeyID != 0 || principal.User == nil {
+			AbortWithProblem(c, errors.ForbiddenError("use an API key for this endpoint"))
+			return
+		}
+		c.Next()
+	}
+}
+
+// RejectImpersonation middleware rejects requests made with an impersonation
+// token, for routes that change the user's credentials or issue new ones.
+// It must be registered after Auth.
+func RejectImpersonation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := CurrentPrincipal(c)
+		if !ok {
+			AbortWithProblem(c, errors.UnauthorizedError("authentication required"))
+			return
+		}
+		if principal.Impersonated() {
+			AbortWithProblem(c, errors.ForbiddenError("use this endpoint while impersonating a user"))
+			return
+		}
+		c.Next()
+	}
+}
+
+// CurrentPrincipal returns the authenticated principal stored on the context by Auth
+func CurrentPrincipal(c *gin.Context) (*domain.Principal, bool) {
+	value, exists := c.Get(principalContextKey)
+	if !exists {
+		return nil, false
+	}
+	principal, ok := value.(*domain.Principal)
+	return principal, ok && principal != nil
+}
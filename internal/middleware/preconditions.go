@@ -0,0 +1,20 @@
+package middleware
+
+import (
+	"UserRESTfulApi/internal/errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireIfMatch middleware rejects requests without an If-Match header with
+// 428 Precondition Required, so clients cannot overwrite changes they have
+// not seen. When not required it lets every request through.
+func RequireIfMatch(required bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if required && c.GetHeader("If-Match") == "" {
+			AbortWithProblem(c, errors.PreconditionRequiredError("If-Match"))
+			return
+		}
+		c.Next()
+	}
+}
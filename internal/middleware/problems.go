@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"UserRESTfulApi/internal/errors"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ProblemContentType is the media type of error responses
+const ProblemContentType = "application/problem+json"
+
+// ProblemTypeBase prefixes the code of a problem to form its type URI, which
+// resolves to the problem's entry in the catalog
+const ProblemTypeBase = "/api/problems/"
+
+// ProblemDetails is an RFC 7807 error response. Code is the stable code of
+// the problem type and Errors lists the invalid fields of a validation failure.
+type ProblemDetails struct {
+	Type      string               `json:"type"`
+	Title     string               `json:"title"`
+	Status    int                  `json:"status"`
+	Detail    string               `json:"detail,omitempty"`
+	Instance  string               `json:"instance,omitempty"`
+	Code      string               `json:"code"`
+	RequestID string               `json:"request_id,omitempty"`
+	Errors    []errors.ErrorDetail `json:"errors,omitempty"`
+}
+
+// AbortWithProblem stops the handler chain and records the error, which the
+// Problems middleware writes as the response
+func AbortWithProblem(c *gin.Context, err error) {
+	_ = c.Error(err)
+	c.Abort()
+}
+
+// Problems middleware writes the last error recorded by a handler as an RFC
+// 7807 problem, unless the handler already wrote a response
+func Problems() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+		WriteProblem(c, c.Errors.Last().Err)
+	}
+}
+
+// WriteProblem writes the error as an RFC 7807 problem. Database and
+// unexpected errors are logged and reported without their message, so SQL
+// and other internals never reach clients.
+func WriteProblem(c *gin.Context, err error) {
+	appErr, ok := err.(*errors.AppError)
+	if !ok {
+		appErr = &errors.AppError{Type: errors.InternalServer, Message: err.Error()}
+	}
+	problem := errors.ProblemOf(appErr.Type)
+	requestID := c.GetString(requestIDContextKey)
+
+	details := ProblemDetails{
+		Type:      ProblemTypeBase + problem.Code,
+		Title:     problem.Title,
+		Status:    problem.Status,
+		Detail:    appErr.Message,
+		Instance:  c.Request.URL.Path,
+		Code:      problem.Code,
+		RequestID: requestID,
+		Errors:    appErr.Details,
+	}
+	if problem.Status >= 500 {
+		log.Printf("Request %s %s %s failed: %v", requestID, c.Request.Method, c.Request.URL.Path, err)
+		details.Detail, details.Errors = "", nil
+	}
+	if appErr.RetryAfter > 0 {
+		seconds := int64((appErr.RetryAfter + time.Second - 1) / time.Second)
+		c.Header("Retry-After", strconv.FormatInt(seconds, 10))
+	}
+
+	c.Header("Content-Type", ProblemContentType)
+	c.AbortWithStatusJSON(problem.Status, details)
+}
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"UserRESTfulApi/internal/domain"
+	"crypto/rand"
+	"encoding/hex"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requestIDHeader carries the ID correlating a request across nginx, the logs and the audit log
+const requestIDHeader = "X-Request-ID"
+
+// requestIDContextKey is the gin context key holding the request ID
+const requestIDContextKey = "request.id"
+
+// validRequestID limits forwarded request IDs to short tokens that are safe to store and log
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestID middleware keeps the X-Request-ID set by nginx, or generates one,
+// and echoes it in the response
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(requestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = newRequestID()
+		}
+
+		c.Set(requestIDContextKey, id)
+		c.Header(requestIDHeader, id)
+		c.Next()
+	}
+}
+
+// RequestMeta returns the client IP, request ID and User-Agent of the request
+func RequestMeta(c *gin.Context) domain.RequestMeta {
+	return domain.RequestMeta{ClientIP: c.ClientIP(), RequestID: c.GetString(requestIDContextKey), UserAgent: c.Request.UserAgent()}
+}
+
+// newRequestID returns a random 128-bit request ID
+func newRequestID() string {
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(id[:])
+}
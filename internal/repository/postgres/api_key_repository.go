@@ -0,0 +1,107 @@
+package postgres
+
+import (
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/errors"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type apiKeyRepository struct {
+	db *gorm.DB
+}
+
+// NewAPIKeyRepository creates a new PostgreSQL API key repository
+func NewAPIKeyRepository(db *gorm.DB) domain.APIKeyRepository {
+	return &apiKeyRepository{db: db}
+}
+
+// Create stores a new API key
+func (r *apiKeyRepository) Create(key *domain.APIKey) error {
+	key.CreatedAt = time.Now()
+	if key.AllowedIPs == nil {
+		key.AllowedIPs = []string{}
+	}
+
+	result := r.db.Create(key)
+	if result.Error != nil {
+		log.Printf("Failed to create API key %s: %v", key.Name, result.Error)
+		return errors.DatabaseError("create api key", result.Error)
+	}
+
+	return nil
+}
+
+// Get retrieves an API key by ID
+func (r *apiKeyRepository) Get(id uint) (*domain.APIKey, error) {
+	var key domain.APIKey
+	result := r.db.First(&key, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		log.Printf("Failed to get API key %d: %v", id, result.Error)
+		return nil, errors.DatabaseError("get api key", result.Error)
+	}
+
+	return &key, nil
+}
+
+// GetByPrefix retrieves an API key by its public prefix
+func (r *apiKeyRepository) GetByPrefix(prefix string) (*domain.APIKey, error) {
+	var key domain.APIKey
+	result := r.db.Where("prefix = ?", prefix).First(&key)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		log.Printf("Failed to get API key %s: %v", prefix, result.Error)
+		return nil, errors.DatabaseError("get api key", result.Error)
+	}
+
+	return &key, nil
+}
+
+// List retrieves the API keys of a user, or every key when userID is nil, newest first
+func (r *apiKeyRepository) List(userID *uint) ([]*domain.APIKey, error) {
+	var keys []*domain.APIKey
+	query := r.db.Order("created_at DESC")
+	if userID != nil {
+		query = query.Where("user_id = ?", *userID)
+	}
+
+	result := query.Find(&keys)
+	if result.Error != nil {
+		log.Printf("Failed to list API keys: %v", result.Error)
+		return nil, errors.DatabaseError("list api keys", result.Error)
+	}
+
+	return keys, nil
+}
+
+// Delete removes an API key, which revokes it immediately
+func (r *apiKeyRepository) Delete(id uint) (bool, error) {
+	result := r.db.Delete(&domain.APIKey{}, id)
+	if result.Error != nil {
+		log.Printf("Failed to delete API key %d: %v", id, result.Error)
+		return false, errors.DatabaseError("delete api key", result.Error)
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+// MarkUsed records the last use of an API key
+func (r *apiKeyRepository) MarkUsed(id uint, at time.Time, clientIP string) error {
+	result := r.db.Model(&domain.APIKey{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"last_used_at": at,
+		"last_used_ip": clientIP,
+	})
+	if result.Error != nil {
+		log.Printf("Failed to record use of API key %d: %v", id, result.Error)
+		return errors.DatabaseError("mark api key used", result.Error)
+	}
+
+	return nil
+}
@@ -0,0 +1,96 @@
+package postgres
+
+import (
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/errors"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// auditChainLockID serializes audit log appends across replicas with a
+// transaction scoped advisory lock, so every event links to its predecessor
+const auditChainLockID = 7261002
+
+type auditRepository struct {
+	db *gorm.DB
+}
+
+// NewAuditRepository creates a new PostgreSQL audit log repository
+func NewAuditRepository(db *gorm.DB) domain.AuditRepository {
+	return &auditRepository{db: db}
+}
+
+// Append seals the event against the latest event and stores it
+func (r *auditRepository) Append(event *domain.AuditEvent) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", auditChainLockID).Error; err != nil {
+			return err
+		}
+
+		var prevHash string
+		err := tx.Model(&domain.AuditEvent{}).Order("id DESC").Limit(1).Pluck("hash", &prevHash).Error
+		if err != nil {
+			return err
+		}
+
+		event.Seal(prevHash, time.Now())
+		return tx.Create(event).Error
+	})
+	if err != nil {
+		log.Printf("Failed to append audit event %s: %v", event.Action, err)
+		return errors.DatabaseError("append audit event", err)
+	}
+
+	return nil
+}
+
+// List retrieves the events matching the filter, newest first
+func (r *auditRepository) List(filter domain.AuditFilter, page, limit int) ([]*domain.AuditEvent, error) {
+	var events []*domain.AuditEvent
+	offset := (page - 1) * limit
+
+	query := r.db.Order("id DESC")
+	if filter.ActorID != nil {
+		query = query.Where("actor_id = ?", *filter.ActorID)
+	}
+	if filter.ImpersonatorID != nil {
+		query = query.Where("impersonator_id = ?", *filter.ImpersonatorID)
+	}
+	if filter.TargetType != "" {
+		query = query.Where("target_type = ?", filter.TargetType)
+	}
+	if filter.TargetID != "" {
+		query = query.Where("target_id = ?", filter.TargetID)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", filter.From.UTC())
+	}
+	if filter.To != nil {
+		query = query.Where("created_at < ?", filter.To.UTC())
+	}
+
+	result := query.Offset(offset).Limit(limit).Find(&events)
+	if result.Error != nil {
+		log.Printf("Failed to list audit events: %v", result.Error)
+		return nil, errors.DatabaseError("list audit events", result.Error)
+	}
+
+	return events, nil
+}
+
+// ListAfter retrieves up to limit events following the given ID, in chain order
+func (r *auditRepository) ListAfter(afterID uint, limit int) ([]*domain.AuditEvent, error) {
+	var events []*domain.AuditEvent
+	result := r.db.Where("id > ?", afterID).Order("id").Limit(limit).Find(&events)
+	if result.Error != nil {
+		log.Printf("Failed to list audit events after %d: %v", afterID, result.Error)
+		return nil, errors.DatabaseError("list audit events", result.Error)
+	}
+
+	return events, nil
+}
@@ -0,0 +1,161 @@
+package postgres
+
+import (
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/errors"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// emailChangeLockClass namespaces the transaction scoped advisory locks that
+// serialize reservations of an address across replicas
+const emailChangeLockClass = 7261003
+
+// pendingEmailChange matches changes awaiting confirmation
+const pendingEmailChange = "confirmed_at IS NULL AND reverted_at IS NULL AND cancelled_at IS NULL"
+
+type emailChangeRepository struct {
+	db *gorm.DB
+}
+
+// NewEmailChangeRepository creates a new PostgreSQL email change repository
+func NewEmailChangeRepository(db *gorm.DB) domain.EmailChangeRepository {
+	return &emailChangeRepository{db: db}
+}
+
+// reservedBy limits a query to the changes of other users holding the address
+func reservedBy(db *gorm.DB, email string, userID uint, now time.Time) *gorm.DB {
+	return db.Model(&domain.EmailChange{}).
+		Where("user_id <> ?", userID).
+		Where(db.Where("new_email = ? AND "+pendingEmailChange+" AND expires_at > ?", email, now).
+			Or("old_email = ? AND confirmed_at IS NOT NULL AND reverted_at IS NULL AND revert_expires_at > ?", email, now))
+}
+
+// cancelPending cancels the unconfirmed changes of a user
+func cancelPending(db *gorm.DB, userID uint, now time.Time) error {
+	return db.Model(&domain.EmailChange{}).
+		Where("user_id = ? AND "+pendingEmailChange, userID).
+		Update("cancelled_at", now).Error
+}
+
+// Reserve stores the change if its new address is free and cancels the
+// user's earlier unconfirmed changes
+func (r *emailChangeRepository) Reserve(change *domain.EmailChange) (bool, error) {
+	change.CreatedAt = time.Now()
+
+	stored := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?, hashtext(?))", emailChangeLockClass, change.NewEmail).Error; err != nil {
+			return err
+		}
+
+		var owners int64
+		err := tx.Model(&domain.User{}).Where("email = ? AND id <> ?", change.NewEmail, change.UserID).Count(&owners).Error
+		if err != nil {
+			return err
+		}
+		var reservations int64
+		if err := reservedBy(tx, change.NewEmail, change.UserID, change.CreatedAt).Count(&reservations).Error; err != nil {
+			return err
+		}
+		if owners > 0 || reservations > 0 {
+			return nil
+		}
+
+		if err := cancelPending(tx, change.UserID, change.CreatedAt); err != nil {
+			return err
+		}
+		if err := tx.Create(change).Error; err != nil {
+			return err
+		}
+		stored = true
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to reserve email change for user %d: %v", change.UserID, err)
+		return false, errors.DatabaseError("reserve email change", err)
+	}
+
+	return stored, nil
+}
+
+// Reserved reports whether a change of another user holds the address
+func (r *emailChangeRepository) Reserved(email string, userID uint) (bool, error) {
+	var count int64
+	result := reservedBy(r.db, email, userID, time.Now()).Count(&count)
+	if result.Error != nil {
+		log.Printf("Failed to check email change reservations: %v", result.Error)
+		return false, errors.DatabaseError("check email change reservations", result.Error)
+	}
+
+	return count > 0, nil
+}
+
+// GetPending retrieves the unconfirmed change of a user
+func (r *emailChangeRepository) GetPending(userID uint) (*domain.EmailChange, error) {
+	var change domain.EmailChange
+	result := r.db.Where("user_id = ? AND "+pendingEmailChange, userID).Order("created_at DESC").First(&change)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		log.Printf("Failed to get pending email change for user %d: %v", userID, result.Error)
+		return nil, errors.DatabaseError("get email change", result.Error)
+	}
+
+	return &change, nil
+}
+
+// GetByRevertHash retrieves an email change by the hash of its revert token
+func (r *emailChangeRepository) GetByRevertHash(tokenHash string) (*domain.EmailChange, error) {
+	var change domain.EmailChange
+	result := r.db.Where("revert_token_hash = ?", tokenHash).First(&change)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		log.Printf("Failed to get email change: %v", result.Error)
+		return nil, errors.DatabaseError("get email change", result.Error)
+	}
+
+	return &change, nil
+}
+
+// MarkConfirmed marks a pending change as confirmed. The conditional update
+// makes confirmation and revert mutually exclusive across replicas.
+func (r *emailChangeRepository) MarkConfirmed(id uint) (bool, error) {
+	result := r.db.Model(&domain.EmailChange{}).
+		Where("id = ? AND "+pendingEmailChange, id).
+		Update("confirmed_at", time.Now())
+	if result.Error != nil {
+		log.Printf("Failed to mark email change %d as confirmed: %v", id, result.Error)
+		return false, errors.DatabaseError("confirm email change", result.Error)
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+// MarkReverted marks a change that was neither reverted nor cancelled as reverted
+func (r *emailChangeRepository) MarkReverted(id uint) (bool, error) {
+	result := r.db.Model(&domain.EmailChange{}).
+		Where("id = ? AND reverted_at IS NULL AND cancelled_at IS NULL", id).
+		Update("reverted_at", time.Now())
+	if result.Error != nil {
+		log.Printf("Failed to mark email change %d as reverted: %v", id, result.Error)
+		return false, errors.DatabaseError("revert email change", result.Error)
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+// CancelPending cancels the unconfirmed changes of a user
+func (r *emailChangeRepository) CancelPending(userID uint) error {
+	if err := cancelPending(r.db, userID, time.Now()); err != nil {
+		log.Printf("Failed to cancel pending email changes for user %d: %v", userID, err)
+		return errors.DatabaseError("cancel email changes", err)
+	}
+
+	return nil
+}
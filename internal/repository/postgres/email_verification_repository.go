@@ -0,0 +1,89 @@
+package postgres
+
+import (
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/errors"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type emailVerificationRepository struct {
+	db *gorm.DB
+}
+
+// NewEmailVerificationRepository creates a new PostgreSQL email verification token repository
+func NewEmailVerificationRepository(db *gorm.DB) domain.EmailVerificationRepository {
+	return &emailVerificationRepository{db: db}
+}
+
+// Create stores a new email verification token
+func (r *emailVerificationRepository) Create(token *domain.EmailVerificationToken) error {
+	token.CreatedAt = time.Now()
+
+	result := r.db.Create(token)
+	if result.Error != nil {
+		log.Printf("Failed to create email verification token for user %d: %v", token.UserID, result.Error)
+		return errors.DatabaseError("create email verification token", result.Error)
+	}
+
+	return nil
+}
+
+// GetByHash retrieves a email verification token by the hash of its value
+func (r *emailVerificationRepository) GetByHash(tokenHash string) (*domain.EmailVerificationToken, error) {
+	var token domain.EmailVerificationToken
+	result := r.db.Where("token_hash = ?", tokenHash).First(&token)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		log.Printf("Failed to get email verification token: %v", result.Error)
+		return nil, errors.DatabaseError("get email verification token", result.Error)
+	}
+
+	return &token, nil
+}
+
+// GetLatestForUser retrieves the most recently issued email verification token of a user
+func (r *emailVerificationRepository) GetLatestForUser(userID uint) (*domain.EmailVerificationToken, error) {
+	var token domain.EmailVerificationToken
+	result := r.db.Where("user_id = ?", userID).Order("created_at DESC").First(&token)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		log.Printf("Failed to get latest email verification token for user %d: %v", userID, result.Error)
+		return nil, errors.DatabaseError("get email verification token", result.Error)
+	}
+
+	return &token, nil
+}
+
+// MarkUsed marks an unused token as used. The conditional update makes
+// redemption atomic across replicas: only one concurrent caller can succeed.
+func (r *emailVerificationRepository) MarkUsed(id uint) (bool, error) {
+	result := r.db.Model(&domain.EmailVerificationToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		log.Printf("Failed to mark email verification token %d as used: %v", id, result.Error)
+		return false, errors.DatabaseError("use email verification token", result.Error)
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+// InvalidateForUser marks every outstanding email verification token of a user as used
+func (r *emailVerificationRepository) InvalidateForUser(userID uint) error {
+	result := r.db.Model(&domain.EmailVerificationToken{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		log.Printf("Failed to invalidate email verification tokens for user %d: %v", userID, result.Error)
+		return errors.DatabaseError("invalidate email verification tokens", result.Error)
+	}
+
+	return nil
+}
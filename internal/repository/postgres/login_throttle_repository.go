@@ -0,0 +1,112 @@
+package postgres
+
+import (
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/errors"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// recordFailureSQL counts a failed login in a single statement so concurrent
+// attempts on different replicas never lose an increment
+const recordFailureSQL = `
+INSERT INTO login_throttles (kind, subject, failures, last_failure_at, created_at, updated_at)
+VALUES (@kind, @subject, 1, @now, @now, @now)
+ON CONFLICT (kind, subject) DO UPDATE SET
+	failures = CASE
+		WHEN login_throttles.last_failure_at < @window_start OR login_throttles.locked_until <= @now THEN 1
+		ELSE login_throttles.failures + 1
+	END,
+	locked_until = CASE
+		WHEN login_throttles.locked_until <= @now THEN NULL
+		ELSE login_throttles.locked_until
+	END,
+	last_failure_at = EXCLUDED.last_failure_at,
+	updated_at = EXCLUDED.updated_at
+RETURNING kind, subject, failures, last_failure_at, locked_until, created_at, updated_at`
+
+type loginThrottleRepository struct {
+	db *gorm.DB
+}
+
+// NewLoginThrottleRepository creates a new PostgreSQL login throttle repository
+func NewLoginThrottleRepository(db *gorm.DB) domain.LoginThrottleRepository {
+	return &loginThrottleRepository{db: db}
+}
+
+// Get retrieves the throttle for an account or IP
+func (r *loginThrottleRepository) Get(kind, subject string) (*domain.LoginThrottle, error) {
+	var throttle domain.LoginThrottle
+	result := r.db.Where("kind = ? AND subject = ?", kind, subject).First(&throttle)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		log.Printf("Failed to get login throttle %s %s: %v", kind, subject, result.Error)
+		return nil, errors.DatabaseError("get login throttle", result.Error)
+	}
+
+	return &throttle, nil
+}
+
+// RecordFailure counts a failed login and returns the updated throttle
+func (r *loginThrottleRepository) RecordFailure(kind, subject string, now, windowStart time.Time) (*domain.LoginThrottle, error) {
+	var throttle domain.LoginThrottle
+	result := r.db.Raw(recordFailureSQL, map[string]interface{}{
+		"kind":         kind,
+		"subject":      subject,
+		"now":          now,
+		"window_start": windowStart,
+	}).Scan(&throttle)
+	if result.Error != nil {
+		log.Printf("Failed to record failed login for %s %s: %v", kind, subject, result.Error)
+		return nil, errors.DatabaseError("record failed login", result.Error)
+	}
+
+	return &throttle, nil
+}
+
+// Lock locks the account or IP until the given time unless it is already locked
+func (r *loginThrottleRepository) Lock(kind, subject string, until time.Time) error {
+	result := r.db.Model(&domain.LoginThrottle{}).
+		Where("kind = ? AND subject = ? AND locked_until IS NULL", kind, subject).
+		Updates(map[string]interface{}{"locked_until": until, "updated_at": time.Now()})
+	if result.Error != nil {
+		log.Printf("Failed to lock %s %s: %v", kind, subject, result.Error)
+		return errors.DatabaseError("lock login", result.Error)
+	}
+
+	return nil
+}
+
+// List retrieves recently failing or locked accounts and IPs
+func (r *loginThrottleRepository) List(windowStart, now time.Time, lockedOnly bool) ([]*domain.LoginThrottle, error) {
+	var throttles []*domain.LoginThrottle
+	query := r.db.Order("updated_at DESC")
+	if lockedOnly {
+		query = query.Where("locked_until > ?", now)
+	} else {
+		query = query.Where("last_failure_at >= ? OR locked_until > ?", windowStart, now)
+	}
+
+	result := query.Find(&throttles)
+	if result.Error != nil {
+		log.Printf("Failed to list login throttles: %v", result.Error)
+		return nil, errors.DatabaseError("list login throttles", result.Error)
+	}
+
+	return throttles, nil
+}
+
+// Delete clears the failures and lock of an account or IP
+func (r *loginThrottleRepository) Delete(kind, subject string) (bool, error) {
+	result := r.db.Where("kind = ? AND subject = ?", kind, subject).Delete(&domain.LoginThrottle{})
+	if result.Error != nil {
+		log.Printf("Failed to delete login throttle %s %s: %v", kind, subject, result.Error)
+		return false, errors.DatabaseError("delete login throttle", result.Error)
+	}
+
+	return result.RowsAffected > 0, nil
+}
@@ -0,0 +1,126 @@
+package postgres
+
+import (
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/errors"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type magicLinkRepository struct {
+	db *gorm.DB
+}
+
+// NewMagicLinkRepository creates a new PostgreSQL magic link repository
+func NewMagicLinkRepository(db *gorm.DB) domain.MagicLinkRepository {
+	return &magicLinkRepository{db: db}
+}
+
+// Create stores a new magic link
+func (r *magicLinkRepository) Create(link *domain.MagicLink) error {
+	link.CreatedAt = time.Now()
+
+	result := r.db.Create(link)
+	if result.Error != nil {
+		log.Printf("Failed to create magic link for user %d: %v", link.UserID, result.Error)
+		return errors.DatabaseError("create magic link", result.Error)
+	}
+
+	return nil
+}
+
+// GetByHash retrieves a magic link by the hash of its token
+func (r *magicLinkRepository) GetByHash(tokenHash string) (*domain.MagicLink, error) {
+	var link domain.MagicLink
+	result := r.db.Where("token_hash = ?", tokenHash).First(&link)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		log.Printf("Failed to get magic link: %v", result.Error)
+		return nil, errors.DatabaseError("get magic link", result.Error)
+	}
+
+	return &link, nil
+}
+
+// GetLatestForUser retrieves the most recently sent magic link of a user
+func (r *magicLinkRepository) GetLatestForUser(userID uint) (*domain.MagicLink, error) {
+	var link domain.MagicLink
+	result := r.db.Where("user_id = ?", userID).Order("created_at DESC").First(&link)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		log.Printf("Failed to get latest magic link for user %d: %v", userID, result.Error)
+		return nil, errors.DatabaseError("get magic link", result.Error)
+	}
+
+	return &link, nil
+}
+
+// CountForUserSince counts the magic links sent to a user since the given time
+func (r *magicLinkRepository) CountForUserSince(userID uint, since time.Time) (int64, error) {
+	var count int64
+	result := r.db.Model(&domain.MagicLink{}).Where("user_id = ? AND created_at >= ?", userID, since).Count(&count)
+	if result.Error != nil {
+		log.Printf("Failed to count magic links for user %d: %v", userID, result.Error)
+		return 0, errors.DatabaseError("count magic links", result.Error)
+	}
+
+	return count, nil
+}
+
+// CountForIPSince counts the magic links requested from a client IP since the given time
+func (r *magicLinkRepository) CountForIPSince(clientIP string, since time.Time) (int64, error) {
+	var count int64
+	result := r.db.Model(&domain.MagicLink{}).Where("client_ip = ? AND created_at >= ?", clientIP, since).Count(&count)
+	if result.Error != nil {
+		log.Printf("Failed to count magic links for %s: %v", clientIP, result.Error)
+		return 0, errors.DatabaseError("count magic links", result.Error)
+	}
+
+	return count, nil
+}
+
+// RecordFailure increments the wrong code counter of a magic link in a
+// single statement and returns the new count
+func (r *magicLinkRepository) RecordFailure(id uint) (int, error) {
+	var attempts int
+	result := r.db.Raw("UPDATE magic_links SET attempts = attempts + 1 WHERE id = ? RETURNING attempts", id).Scan(&attempts)
+	if result.Error != nil {
+		log.Printf("Failed to record magic link failure %d: %v", id, result.Error)
+		return 0, errors.DatabaseError("record magic link failure", result.Error)
+	}
+
+	return attempts, nil
+}
+
+// MarkUsed marks an unused magic link as used. The conditional update makes
+// redemption atomic across replicas: only one concurrent caller can succeed.
+func (r *magicLinkRepository) MarkUsed(id uint) (bool, error) {
+	result := r.db.Model(&domain.MagicLink{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		log.Printf("Failed to mark magic link %d as used: %v", id, result.Error)
+		return false, errors.DatabaseError("use magic link", result.Error)
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+// InvalidateForUser marks every outstanding magic link of a user as used
+func (r *magicLinkRepository) InvalidateForUser(userID uint) error {
+	result := r.db.Model(&domain.MagicLink{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		log.Printf("Failed to invalidate magic links for user %d: %v", userID, result.Error)
+		return errors.DatabaseError("invalidate magic links", result.Error)
+	}
+
+	return nil
+}
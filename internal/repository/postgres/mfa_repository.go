@@ -0,0 +1,216 @@
+package postgres
+
+import (
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/errors"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type mfaRepository struct {
+	db *gorm.DB
+}
+
+// NewMFARepository creates a new PostgreSQL MFA repository
+func NewMFARepository(db *gorm.DB) domain.MFARepository {
+	return &mfaRepository{db: db}
+}
+
+// GetFactor retrieves the TOTP factor of a user
+func (r *mfaRepository) GetFactor(userID uint) (*domain.MFAFactor, error) {
+	var factor domain.MFAFactor
+	result := r.db.Where("user_id = ?", userID).First(&factor)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		log.Printf("Failed to get MFA factor for user %d: %v", userID, result.Error)
+		return nil, errors.DatabaseError("get mfa factor", result.Error)
+	}
+
+	return &factor, nil
+}
+
+// SaveFactor creates the factor of a user or replaces its secret while it is
+// still unconfirmed. A confirmed factor is never overwritten.
+func (r *mfaRepository) SaveFactor(factor *domain.MFAFactor) error {
+	now := time.Now()
+	factor.CreatedAt = now
+	factor.UpdatedAt = now
+
+	result := r.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"secret":         factor.Secret,
+			"last_used_step": 0,
+			"created_at":     now,
+			"updated_at":     now,
+		}),
+		Where: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "mfa_factors.confirmed_at IS NULL"}}},
+	}).Create(factor)
+	if result.Error != nil {
+		log.Printf("Failed to save MFA factor for user %d: %v", factor.UserID, result.Error)
+		return errors.DatabaseError("save mfa factor", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.ConflictError("MFA is already enabled")
+	}
+
+	return nil
+}
+
+// ConfirmFactor enables an unconfirmed factor, recording the step of the code that confirmed it
+func (r *mfaRepository) ConfirmFactor(userID uint, step int64) (bool, error) {
+	result := r.db.Model(&domain.MFAFactor{}).
+		Where("user_id = ? AND confirmed_at IS NULL", userID).
+		Updates(map[string]interface{}{"confirmed_at": time.Now(), "last_used_step": step, "updated_at": time.Now()})
+	if result.Error != nil {
+		log.Printf("Failed to confirm MFA factor for user %d: %v", userID, result.Error)
+		return false, errors.DatabaseError("confirm mfa factor", result.Error)
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+// UseStep records an accepted time step. The conditional update makes each
+// code usable once even when replicas verify it concurrently.
+func (r *mfaRepository) UseStep(userID uint, step int64) (bool, error) {
+	result := r.db.Model(&domain.MFAFactor{}).
+		Where("user_id = ? AND confirmed_at IS NOT NULL AND last_used_step < ?", userID, step).
+		Updates(map[string]interface{}{"last_used_step": step, "updated_at": time.Now()})
+	if result.Error != nil {
+		log.Printf("Failed to record MFA step for user %d: %v", userID, result.Error)
+		return false, errors.DatabaseError("use mfa step", result.Error)
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+// DeleteFactor removes the factor, recovery codes and open challenges of a user
+func (r *mfaRepository) DeleteFactor(userID uint) (bool, error) {
+	deleted := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("user_id = ?", userID).Delete(&domain.MFAFactor{})
+		if result.Error != nil {
+			return result.Error
+		}
+		deleted = result.RowsAffected > 0
+
+		if err := tx.Where("user_id = ?", userID).Delete(&domain.MFARecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&domain.MFAChallenge{}).Error
+	})
+	if err != nil {
+		log.Printf("Failed to delete MFA factor for user %d: %v", userID, err)
+		return false, errors.DatabaseError("delete mfa factor", err)
+	}
+
+	return deleted, nil
+}
+
+// ReplaceRecoveryCodes swaps every recovery code of a user for the given hashes
+func (r *mfaRepository) ReplaceRecoveryCodes(userID uint, codeHashes []string) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&domain.MFARecoveryCode{}).Error; err != nil {
+			return err
+		}
+
+		codes := make([]*domain.MFARecoveryCode, 0, len(codeHashes))
+		for _, hash := range codeHashes {
+			codes = append(codes, &domain.MFARecoveryCode{UserID: userID, CodeHash: hash, CreatedAt: time.Now()})
+		}
+		if len(codes) == 0 {
+			return nil
+		}
+		return tx.Create(&codes).Error
+	})
+	if err != nil {
+		log.Printf("Failed to replace recovery codes for user %d: %v", userID, err)
+		return errors.DatabaseError("replace recovery codes", err)
+	}
+
+	return nil
+}
+
+// UseRecoveryCode marks an unused recovery code as used. Only one concurrent caller can succeed.
+func (r *mfaRepository) UseRecoveryCode(userID uint, codeHash string) (bool, error) {
+	result := r.db.Model(&domain.MFARecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		log.Printf("Failed to use recovery code for user %d: %v", userID, result.Error)
+		return false, errors.DatabaseError("use recovery code", result.Error)
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+// CountRecoveryCodes returns the number of unused recovery codes of a user
+func (r *mfaRepository) CountRecoveryCodes(userID uint) (int, error) {
+	var count int64
+	result := r.db.Model(&domain.MFARecoveryCode{}).Where("user_id = ? AND used_at IS NULL", userID).Count(&count)
+	if result.Error != nil {
+		log.Printf("Failed to count recovery codes for user %d: %v", userID, result.Error)
+		return 0, errors.DatabaseError("count recovery codes", result.Error)
+	}
+
+	return int(count), nil
+}
+
+// CreateChallenge stores a new MFA challenge
+func (r *mfaRepository) CreateChallenge(challenge *domain.MFAChallenge) error {
+	challenge.CreatedAt = time.Now()
+
+	result := r.db.Create(challenge)
+	if result.Error != nil {
+		log.Printf("Failed to create MFA challenge for user %d: %v", challenge.UserID, result.Error)
+		return errors.DatabaseError("create mfa challenge", result.Error)
+	}
+
+	return nil
+}
+
+// GetChallengeByHash retrieves an MFA challenge by the hash of its token
+func (r *mfaRepository) GetChallengeByHash(tokenHash string) (*domain.MFAChallenge, error) {
+	var challenge domain.MFAChallenge
+	result := r.db.Where("token_hash = ?", tokenHash).First(&challenge)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		log.Printf("Failed to get MFA challenge: %v", result.Error)
+		return nil, errors.DatabaseError("get mfa challenge", result.Error)
+	}
+
+	return &challenge, nil
+}
+
+// RecordChallengeFailure increments the attempt counter of a challenge in a
+// single statement and returns the new count
+func (r *mfaRepository) RecordChallengeFailure(id uint) (int, error) {
+	var attempts int
+	result := r.db.Raw("UPDATE mfa_challenges SET attempts = attempts + 1 WHERE id = ? RETURNING attempts", id).Scan(&attempts)
+	if result.Error != nil {
+		log.Printf("Failed to record MFA challenge failure %d: %v", id, result.Error)
+		return 0, errors.DatabaseError("record mfa challenge failure", result.Error)
+	}
+
+	return attempts, nil
+}
+
+// MarkChallengeUsed marks an unused challenge as used. Only one concurrent caller can succeed.
+func (r *mfaRepository) MarkChallengeUsed(id uint) (bool, error) {
+	result := r.db.Model(&domain.MFAChallenge{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		log.Printf("Failed to mark MFA challenge %d as used: %v", id, result.Error)
+		return false, errors.DatabaseError("use mfa challenge", result.Error)
+	}
+
+	return result.RowsAffected == 1, nil
+}
@@ -0,0 +1,71 @@
+package postgres
+
+import (
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/errors"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type oauthClientRepository struct {
+	db *gorm.DB
+}
+
+// NewOAuthClientRepository creates a new PostgreSQL OAuth client repository
+func NewOAuthClientRepository(db *gorm.DB) domain.OAuthClientRepository {
+	return &oauthClientRepository{db: db}
+}
+
+// Create registers a new OAuth client
+func (r *oauthClientRepository) Create(client *domain.OAuthClient) error {
+	client.CreatedAt = time.Now()
+	client.UpdatedAt = time.Now()
+
+	result := r.db.Create(client)
+	if result.Error != nil {
+		log.Printf("Failed to create OAuth client %s: %v", client.Name, result.Error)
+		return errors.DatabaseError("create oauth client", result.Error)
+	}
+
+	return nil
+}
+
+// GetByClientID retrieves an OAuth client by its public client ID
+func (r *oauthClientRepository) GetByClientID(clientID string) (*domain.OAuthClient, error) {
+	var client domain.OAuthClient
+	result := r.db.Where("client_id = ?", clientID).First(&client)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		log.Printf("Failed to get OAuth client %s: %v", clientID, result.Error)
+		return nil, errors.DatabaseError("get oauth client", result.Error)
+	}
+
+	return &client, nil
+}
+
+// List retrieves every OAuth client ordered by name
+func (r *oauthClientRepository) List() ([]*domain.OAuthClient, error) {
+	var clients []*domain.OAuthClient
+	result := r.db.Order("name").Find(&clients)
+	if result.Error != nil {
+		log.Printf("Failed to list OAuth clients: %v", result.Error)
+		return nil, errors.DatabaseError("list oauth clients", result.Error)
+	}
+
+	return clients, nil
+}
+
+// Delete removes an OAuth client
+func (r *oauthClientRepository) Delete(clientID string) (bool, error) {
+	result := r.db.Where("client_id = ?", clientID).Delete(&domain.OAuthClient{})
+	if result.Error != nil {
+		log.Printf("Failed to delete OAuth client %s: %v", clientID, result.Error)
+		return false, errors.DatabaseError("delete oauth client", result.Error)
+	}
+
+	return result.RowsAffected > 0, nil
+}
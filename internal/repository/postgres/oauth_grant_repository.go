@@ -0,0 +1,127 @@
+package postgres
+
+import (
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/errors"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type oauthGrantRepository struct {
+	db *gorm.DB
+}
+
+// NewOAuthGrantRepository creates a new PostgreSQL authorization code and token repository
+func NewOAuthGrantRepository(db *gorm.DB) domain.OAuthGrantRepository {
+	return &oauthGrantRepository{db: db}
+}
+
+// CreateCode stores a new authorization code
+func (r *oauthGrantRepository) CreateCode(code *domain.AuthorizationCode) error {
+	code.CreatedAt = time.Now()
+
+	result := r.db.Create(code)
+	if result.Error != nil {
+		log.Printf("Failed to create authorization code for user %d: %v", code.UserID, result.Error)
+		return errors.DatabaseError("create authorization code", result.Error)
+	}
+
+	return nil
+}
+
+// GetCodeByHash retrieves an authorization code by the hash of its value
+func (r *oauthGrantRepository) GetCodeByHash(codeHash string) (*domain.AuthorizationCode, error) {
+	var code domain.AuthorizationCode
+	result := r.db.Where("code_hash = ?", codeHash).First(&code)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		log.Printf("Failed to get authorization code: %v", result.Error)
+		return nil, errors.DatabaseError("get authorization code", result.Error)
+	}
+
+	return &code, nil
+}
+
+// MarkCodeUsed marks an unused authorization code as used. Only one concurrent caller can succeed.
+func (r *oauthGrantRepository) MarkCodeUsed(id uint) (bool, error) {
+	result := r.db.Model(&domain.AuthorizationCode{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		log.Printf("Failed to mark authorization code %d as used: %v", id, result.Error)
+		return false, errors.DatabaseError("use authorization code", result.Error)
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+// CreateToken stores a new access or refresh token
+func (r *oauthGrantRepository) CreateToken(token *domain.OAuthToken) error {
+	token.CreatedAt = time.Now()
+
+	result := r.db.Create(token)
+	if result.Error != nil {
+		log.Printf("Failed to create OAuth token for client %s: %v", token.ClientID, result.Error)
+		return errors.DatabaseError("create oauth token", result.Error)
+	}
+
+	return nil
+}
+
+// GetTokenByHash retrieves an access or refresh token by the hash of its value
+func (r *oauthGrantRepository) GetTokenByHash(tokenHash string) (*domain.OAuthToken, error) {
+	var token domain.OAuthToken
+	result := r.db.Where("token_hash = ?", tokenHash).First(&token)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		log.Printf("Failed to get OAuth token: %v", result.Error)
+		return nil, errors.DatabaseError("get oauth token", result.Error)
+	}
+
+	return &token, nil
+}
+
+// RevokeToken revokes an active token. Only one concurrent caller can succeed.
+func (r *oauthGrantRepository) RevokeToken(id uint) (bool, error) {
+	result := r.db.Model(&domain.OAuthToken{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		log.Printf("Failed to revoke OAuth token %d: %v", id, result.Error)
+		return false, errors.DatabaseError("revoke oauth token", result.Error)
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+// RevokeGrant revokes every active token issued from the same authorization
+func (r *oauthGrantRepository) RevokeGrant(grantID string) error {
+	result := r.db.Model(&domain.OAuthToken{}).
+		Where("grant_id = ? AND revoked_at IS NULL", grantID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		log.Printf("Failed to revoke OAuth grant %s: %v", grantID, result.Error)
+		return errors.DatabaseError("revoke oauth grant", result.Error)
+	}
+
+	return nil
+}
+
+// RevokeClient revokes every active token issued to a client
+func (r *oauthGrantRepository) RevokeClient(clientID string) error {
+	result := r.db.Model(&domain.OAuthToken{}).
+		Where("client_id = ? AND revoked_at IS NULL", clientID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		log.Printf("Failed to revoke tokens of OAuth client %s: %v", clientID, result.Error)
+		return errors.DatabaseError("revoke oauth client tokens", result.Error)
+	}
+
+	return nil
+}
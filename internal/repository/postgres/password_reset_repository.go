@@ -0,0 +1,89 @@
+package postgres
+
+import (
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/errors"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type passwordResetRepository struct {
+	db *gorm.DB
+}
+
+// NewPasswordResetRepository creates a new PostgreSQL password reset token repository
+func NewPasswordResetRepository(db *gorm.DB) domain.PasswordResetRepository {
+	return &passwordResetRepository{db: db}
+}
+
+// Create stores a new password reset token
+func (r *passwordResetRepository) Create(token *domain.PasswordResetToken) error {
+	token.CreatedAt = time.Now()
+
+	result := r.db.Create(token)
+	if result.Error != nil {
+		log.Printf("Failed to create password reset token for user %d: %v", token.UserID, result.Error)
+		return errors.DatabaseError("create password reset token", result.Error)
+	}
+
+	return nil
+}
+
+// GetByHash retrieves a password reset token by the hash of its value
+func (r *passwordResetRepository) GetByHash(tokenHash string) (*domain.PasswordResetToken, error) {
+	var token domain.PasswordResetToken
+	result := r.db.Where("token_hash = ?", tokenHash).First(&token)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		log.Printf("Failed to get password reset token: %v", result.Error)
+		return nil, errors.DatabaseError("get password reset token", result.Error)
+	}
+
+	return &token, nil
+}
+
+// GetLatestForUser retrieves the most recently issued password reset token of a user
+func (r *passwordResetRepository) GetLatestForUser(userID uint) (*domain.PasswordResetToken, error) {
+	var token domain.PasswordResetToken
+	result := r.db.Where("user_id = ?", userID).Order("created_at DESC").First(&token)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		log.Printf("Failed to get latest password reset token for user %d: %v", userID, result.Error)
+		return nil, errors.DatabaseError("get password reset token", result.Error)
+	}
+
+	return &token, nil
+}
+
+// MarkUsed marks an unused token as used. The conditional update makes
+// redemption atomic across replicas: only one concurrent caller can succeed.
+func (r *passwordResetRepository) MarkUsed(id uint) (bool, error) {
+	result := r.db.Model(&domain.PasswordResetToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		log.Printf("Failed to mark password reset token %d as used: %v", id, result.Error)
+		return false, errors.DatabaseError("use password reset token", result.Error)
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+// InvalidateForUser marks every outstanding password reset token of a user as used
+func (r *passwordResetRepository) InvalidateForUser(userID uint) error {
+	result := r.db.Model(&domain.PasswordResetToken{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		log.Printf("Failed to invalidate password reset tokens for user %d: %v", userID, result.Error)
+		return errors.DatabaseError("invalidate password reset tokens", result.Error)
+	}
+
+	return nil
+}
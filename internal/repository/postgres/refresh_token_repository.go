@@ -0,0 +1,105 @@
+package postgres
+
+import (
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/errors"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type refreshTokenRepository struct {
+	db *gorm.DB
+}
+
+// NewRefreshTokenRepository creates a new PostgreSQL refresh token repository
+func NewRefreshTokenRepository(db *gorm.DB) domain.RefreshTokenRepository {
+	return &refreshTokenRepository{db: db}
+}
+
+// Create stores a new refresh token
+func (r *refreshTokenRepository) Create(token *domain.RefreshToken) error {
+	token.CreatedAt = time.Now()
+
+	result := r.db.Create(token)
+	if result.Error != nil {
+		log.Printf("Failed to create refresh token for user %d: %v", token.UserID, result.Error)
+		return errors.DatabaseError("create refresh token", result.Error)
+	}
+
+	return nil
+}
+
+// GetByHash retrieves a refresh token by the hash of its value
+func (r *refreshTokenRepository) GetByHash(tokenHash string) (*domain.RefreshToken, error) {
+	var token domain.RefreshToken
+	result := r.db.Where("token_hash = ?", tokenHash).First(&token)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		log.Printf("Failed to get refresh token: %v", result.Error)
+		return nil, errors.DatabaseError("get refresh token", result.Error)
+	}
+
+	return &token, nil
+}
+
+// MarkRotated marks an active token as used. The conditional update makes the
+// rotation atomic across replicas: only one concurrent caller can succeed.
+func (r *refreshTokenRepository) MarkRotated(id uint) (bool, error) {
+	result := r.db.Model(&domain.RefreshToken{}).
+		Where("id = ? AND rotated_at IS NULL AND revoked_at IS NULL", id).
+		Update("rotated_at", time.Now())
+	if result.Error != nil {
+		log.Printf("Failed to rotate refresh token %d: %v", id, result.Error)
+		return false, errors.DatabaseError("rotate refresh token", result.Error)
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+// RevokeFamily revokes every token descended from the same login and ends its session
+func (r *refreshTokenRepository) RevokeFamily(familyID string) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := tx.Model(&domain.RefreshToken{}).
+			Where("family_id = ? AND revoked_at IS NULL", familyID).
+			Update("revoked_at", now).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&domain.Session{}).
+			Where("id = ? AND revoked_at IS NULL", familyID).
+			Update("revoked_at", now).Error
+	})
+	if err != nil {
+		log.Printf("Failed to revoke refresh token family %s: %v", familyID, err)
+		return errors.DatabaseError("revoke refresh tokens", err)
+	}
+
+	return nil
+}
+
+// RevokeAllForUser revokes every refresh token belonging to a user and ends all their sessions
+func (r *refreshTokenRepository) RevokeAllForUser(userID uint) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := tx.Model(&domain.RefreshToken{}).
+			Where("user_id = ? AND revoked_at IS NULL", userID).
+			Update("revoked_at", now).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&domain.Session{}).
+			Where("user_id = ? AND revoked_at IS NULL", userID).
+			Update("revoked_at", now).Error
+	})
+	if err != nil {
+		log.Printf("Failed to revoke refresh tokens for user %d: %v", userID, err)
+		return errors.DatabaseError("revoke refresh tokens", err)
+	}
+
+	return nil
+}
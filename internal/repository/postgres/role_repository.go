@@ -0,0 +1,165 @@
+package postgres
+
+import (
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/errors"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type roleRepository struct {
+	db *gorm.DB
+}
+
+// NewRoleRepository creates a new PostgreSQL role repository
+func NewRoleRepository(db *gorm.DB) domain.RoleRepository {
+	return &roleRepository{db: db}
+}
+
+// List retrieves every role with its permissions
+func (r *roleRepository) List() ([]*domain.Role, error) {
+	var roles []*domain.Role
+	result := r.db.Preload("Permissions").Order("name").Find(&roles)
+	if result.Error != nil {
+		log.Printf("Failed to list roles: %v", result.Error)
+		return nil, errors.DatabaseError("list roles", result.Error)
+	}
+
+	return roles, nil
+}
+
+// GetByName retrieves a role and its permissions by name
+func (r *roleRepository) GetByName(name string) (*domain.Role, error) {
+	var role domain.Role
+	result := r.db.Preload("Permissions").Where("name = ?", name).First(&role)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		log.Printf("Failed to get role %s: %v", name, result.Error)
+		return nil, errors.DatabaseError("get role", result.Error)
+	}
+
+	return &role, nil
+}
+
+// Create creates a role together with its permissions
+func (r *roleRepository) Create(role *domain.Role) error {
+	role.CreatedAt = time.Now()
+	role.UpdatedAt = time.Now()
+
+	result := r.db.Create(role)
+	if result.Error != nil {
+		log.Printf("Failed to create role %s: %v", role.Name, result.Error)
+		return errors.DatabaseError("create role", result.Error)
+	}
+
+	return nil
+}
+
+// Delete deletes a role, its permissions and its assignments
+func (r *roleRepository) Delete(id uint) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("role_id = ?", id).Delete(&domain.UserRole{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("role_id = ?", id).Delete(&domain.RolePermission{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&domain.Role{}, id).Error
+	})
+	if err != nil {
+		log.Printf("Failed to delete role with id %d: %v", id, err)
+		return errors.DatabaseError("delete role", err)
+	}
+
+	return nil
+}
+
+// Sync creates the role if it does not exist and replaces its permissions.
+// It is safe to run concurrently from several replicas at startup.
+func (r *roleRepository) Sync(role *domain.Role) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&domain.Role{
+			Name:        role.Name,
+			Description: role.Description,
+			Builtin:     role.Builtin,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		var stored domain.Role
+		if err := tx.Where("name = ?", role.Name).First(&stored).Error; err != nil {
+			return err
+		}
+
+		permissions := role.PermissionList()
+		if err := tx.Where("role_id = ? AND permission NOT IN ?", stored.ID, append(permissions, "")).Delete(&domain.RolePermission{}).Error; err != nil {
+			return err
+		}
+		for _, permission := range permissions {
+			err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&domain.RolePermission{RoleID: stored.ID, Permission: permission}).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		role.ID = stored.ID
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to sync role %s: %v", role.Name, err)
+		return errors.DatabaseError("sync role", err)
+	}
+
+	return nil
+}
+
+// GetUserRoles retrieves the roles assigned to a user
+func (r *roleRepository) GetUserRoles(userID uint) ([]*domain.Role, error) {
+	var roles []*domain.Role
+	result := r.db.Preload("Permissions").
+		Joins("JOIN user_roles ON user_roles.role_id = roles.id").
+		Where("user_roles.user_id = ?", userID).
+		Order("roles.name").
+		Find(&roles)
+	if result.Error != nil {
+		log.Printf("Failed to get roles for user %d: %v", userID, result.Error)
+		return nil, errors.DatabaseError("get user roles", result.Error)
+	}
+
+	return roles, nil
+}
+
+// AssignToUser assigns a role to a user, doing nothing if it is already assigned
+func (r *roleRepository) AssignToUser(userID, roleID uint) error {
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&domain.UserRole{
+		UserID:    userID,
+		RoleID:    roleID,
+		CreatedAt: time.Now(),
+	})
+	if result.Error != nil {
+		log.Printf("Failed to assign role %d to user %d: %v", roleID, userID, result.Error)
+		return errors.DatabaseError("assign role", result.Error)
+	}
+
+	return nil
+}
+
+// RemoveFromUser removes a role from a user
+func (r *roleRepository) RemoveFromUser(userID, roleID uint) error {
+	result := r.db.Where("user_id = ? AND role_id = ?", userID, roleID).Delete(&domain.UserRole{})
+	if result.Error != nil {
+		log.Printf("Failed to remove role %d from user %d: %v", roleID, userID, result.Error)
+		return errors.DatabaseError("remove role", result.Error)
+	}
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package postgres
+
+import (
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/errors"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type sessionRepository struct {
+	db *gorm.DB
+}
+
+// NewSessionRepository creates a new PostgreSQL session repository
+func NewSessionRepository(db *gorm.DB) domain.SessionRepository {
+	return &sessionRepository{db: db}
+}
+
+// Create stores a new session
+func (r *sessionRepository) Create(session *domain.Session) error {
+	result := r.db.Create(session)
+	if result.Error != nil {
+		log.Printf("Failed to create session for user %d: %v", session.UserID, result.Error)
+		return errors.DatabaseError("create session", result.Error)
+	}
+
+	return nil
+}
+
+// Get retrieves a session by ID
+func (r *sessionRepository) Get(id string) (*domain.Session, error) {
+	var session domain.Session
+	result := r.db.Where("id = ?", id).First(&session)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		log.Printf("Failed to get session: %v", result.Error)
+		return nil, errors.DatabaseError("get session", result.Error)
+	}
+
+	return &session, nil
+}
+
+// ListActive retrieves the user's unrevoked, unexpired sessions, most recently seen first
+func (r *sessionRepository) ListActive(userID uint, now time.Time) ([]*domain.Session, error) {
+	var sessions []*domain.Session
+	result := r.db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, now).
+		Order("last_seen_at DESC").
+		Find(&sessions)
+	if result.Error != nil {
+		log.Printf("Failed to list sessions for user %d: %v", userID, result.Error)
+		return nil, errors.DatabaseError("list sessions", result.Error)
+	}
+
+	return sessions, nil
+}
+
+// Touch stores the last seen time, client IP and expiry of the session
+func (r *sessionRepository) Touch(session *domain.Session) error {
+	result := r.db.Model(&domain.Session{}).Where("id = ?", session.ID).Updates(map[string]interface{}{
+		"last_seen_at": session.LastSeenAt,
+		"client_ip":    session.ClientIP,
+		"expires_at":   session.ExpiresAt,
+	})
+	if result.Error != nil {
+		log.Printf("Failed to update session of user %d: %v", session.UserID, result.Error)
+		return errors.DatabaseError("update session", result.Error)
+	}
+
+	return nil
+}
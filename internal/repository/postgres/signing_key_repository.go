@@ -0,0 +1,86 @@
+package postgres
+
+import (
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/errors"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// signingKeyLockID serializes signing key rotation across replicas with a
+// transaction scoped advisory lock
+const signingKeyLockID = 7261001
+
+type signingKeyRepository struct {
+	db *gorm.DB
+}
+
+// NewSigningKeyRepository creates a new PostgreSQL signing key repository
+func NewSigningKeyRepository(db *gorm.DB) domain.SigningKeyRepository {
+	return &signingKeyRepository{db: db}
+}
+
+// List retrieves every signing key, newest first
+func (r *signingKeyRepository) List() ([]*domain.SigningKey, error) {
+	var keys []*domain.SigningKey
+	result := r.db.Order("created_at DESC").Find(&keys)
+	if result.Error != nil {
+		log.Printf("Failed to list signing keys: %v", result.Error)
+		return nil, errors.DatabaseError("list signing keys", result.Error)
+	}
+
+	return keys, nil
+}
+
+// Rotate stores a new active key and retires the previous ones. The advisory
+// lock makes concurrent rotations by several replicas produce a single key.
+func (r *signingKeyRepository) Rotate(key *domain.SigningKey, staleBefore time.Time) (bool, error) {
+	rotated := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", signingKeyLockID).Error; err != nil {
+			return err
+		}
+
+		var fresh int64
+		err := tx.Model(&domain.SigningKey{}).
+			Where("retired_at IS NULL AND created_at >= ?", staleBefore).
+			Count(&fresh).Error
+		if err != nil {
+			return err
+		}
+		if fresh > 0 {
+			return nil
+		}
+
+		now := time.Now()
+		err = tx.Model(&domain.SigningKey{}).Where("retired_at IS NULL").Update("retired_at", now).Error
+		if err != nil {
+			return err
+		}
+		key.CreatedAt = now
+		if err := tx.Create(key).Error; err != nil {
+			return err
+		}
+		rotated = true
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to rotate signing key: %v", err)
+		return false, errors.DatabaseError("rotate signing key", err)
+	}
+
+	return rotated, nil
+}
+
+// DeleteRetiredBefore removes keys retired before the given time
+func (r *signingKeyRepository) DeleteRetiredBefore(before time.Time) error {
+	result := r.db.Where("retired_at < ?", before).Delete(&domain.SigningKey{})
+	if result.Error != nil {
+		log.Printf("Failed to delete retired signing keys: %v", result.Error)
+		return errors.DatabaseError("delete signing keys", result.Error)
+	}
+
+	return nil
+}
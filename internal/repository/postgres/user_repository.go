@@ -3,10 +3,15 @@ package postgres
 import (
 	"UserRESTfulApi/internal/domain"
 	"UserRESTfulApi/internal/errors"
+	"encoding/json"
+	"fmt"
 	"log"
+	"strconv"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type userRepository struct {
@@ -22,6 +27,7 @@ func NewUserRepository(db *gorm.DB) domain.UserRepository {
 func (r *userRepository) Create(user *domain.User) error {
 	user.CreatedAt = time.Now()
 	user.UpdatedAt = time.Now()
+	user.Version = 1
 
 	result := r.db.Create(user)
 	if result.Error != nil {
@@ -47,50 +53,161 @@ func (r *userRepository) Get(id uint) (*domain.User, error) {
 	return &user, nil
 }
 
-// Update updates a user
+// Update updates every column of a user if it still has the version it was read at
 func (r *userRepository) Update(user *domain.User) error {
+	return r.updateVersion(user, "*")
+}
+
+// UpdateColumns updates only the named columns of a user, leaving the others
+// as they are stored, if it still has the version it was read at
+func (r *userRepository) UpdateColumns(user *domain.User, columns ...string) error {
+	return r.updateVersion(user, append(columns, "version", "updated_at")...)
+}
+
+// updateVersion stores the columns of a user and advances its version. The
+// conditional UPDATE makes concurrent writers that read the same version
+// fail instead of overwriting each other.
+func (r *userRepository) updateVersion(user *domain.User, columns ...string) error {
+	expected := user.Version
+	user.Version = expected + 1
 	user.UpdatedAt = time.Now()
 
-	result := r.db.Save(user)
+	result := r.db.Model(user).Where("version = ?", expected).Select(columns).Updates(user)
 	if result.Error != nil {
-		if result.Error == gorm.ErrRecordNotFound {
-			log.Printf("Failed to update user with id %d: %v", user.ID, result.Error)
-			return errors.NotFoundError("user", user.ID)
-		}
+		user.Version = expected
 		log.Printf("Failed to update user with id %d: %v", user.ID, result.Error)
 		return errors.DatabaseError("update", result.Error)
 	}
+	if result.RowsAffected == 0 {
+		user.Version = expected
+		return r.versionMismatch(user.ID, "update")
+	}
 
 	return nil
 }
 
-// Delete deletes a user
-func (r *userRepository) Delete(id uint) error {
-	result := r.db.Delete(&domain.User{}, id)
+// versionMismatch explains why a conditional write matched no user
+func (r *userRepository) versionMismatch(id uint, operation string) error {
+	var count int64
+	result := r.db.Model(&domain.User{}).Where("id = ?", id).Count(&count)
+	if result.Error != nil {
+		log.Printf("Failed to %s user with id %d: %v", operation, id, result.Error)
+		return errors.DatabaseError(operation, result.Error)
+	}
+	if count == 0 {
+		return errors.NotFoundError("user", id)
+	}
+	return errors.PreconditionFailedError("user", id)
+}
+
+// Delete deletes a user if it still has the given version
+func (r *userRepository) Delete(id, version uint) error {
+	result := r.db.Where("version = ?", version).Delete(&domain.User{}, id)
 	if result.Error != nil {
-		if result.Error == gorm.ErrRecordNotFound {
-			log.Printf("Failed to delete user with id %d: %v", id, result.Error)
-			return errors.NotFoundError("user", id)
-		}
 		log.Printf("Failed to delete user with id %d: %v", id, result.Error)
 		return errors.DatabaseError("delete", result.Error)
 	}
+	if result.RowsAffected == 0 {
+		return r.versionMismatch(id, "delete")
+	}
 
 	return nil
 }
 
-// List retrieves users with pagination
-func (r *userRepository) List(page, limit int) ([]*domain.User, error) {
-	var users []*domain.User
-	offset := (page - 1) * limit
+// List retrieves the users of the query selected by the seek. Rows are found
+// by comparing their sort keys with the seek key rather than by offset, so
+// concurrent inserts and deletes never shift a page.
+func (r *userRepository) List(query domain.UserQuery, seek domain.UserSeek) (*domain.UserKeyPage, error) {
+	condition, args, err := userListSQL(query)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := userSortKeys(query)
+	if err != nil {
+		return nil, err
+	}
+
+	db := r.db.Table("users").Where(condition, args...)
+	if query.Search != "" {
+		db = db.Select("users.*, "+userSearchRankSQL+" AS search_rank", query.Search, query.Search)
+	}
+	backward := seek.Before != nil
+	if at := seek.After; at != nil || backward {
+		if backward {
+			at = seek.Before
+		}
+		keyCondition, keyArgs, err := keysetSQL(keys, at, backward)
+		if err != nil {
+			return nil, err
+		}
+		db = db.Where(keyCondition, keyArgs...)
+	}
 
-	result := r.db.Offset(offset).Limit(limit).Find(&users)
+	// One row past the limit tells whether another page follows
+	var rows []*rankedUser
+	result := db.Order(userOrderSQL(keys, backward)).Limit(seek.Limit + 1).Find(&rows)
 	if result.Error != nil {
 		log.Printf("Failed to list users: %v", result.Error)
 		return nil, errors.DatabaseError("list", result.Error)
 	}
 
-	return users, nil
+	page := &domain.UserKeyPage{HasPrev: seek.After != nil, HasNext: backward}
+	if len(rows) > seek.Limit {
+		rows = rows[:seek.Limit]
+		if backward {
+			page.HasPrev = true
+		} else {
+			page.HasNext = true
+		}
+	}
+	if backward {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
+	for _, row := range rows {
+		page.Users = append(page.Users, &row.User)
+	}
+	if len(rows) > 0 {
+		page.First = userKey(keys, rows[0])
+		page.Last = userKey(keys, rows[len(rows)-1])
+	}
+
+	switch seek.Total {
+	case domain.UserTotalExact:
+		var total int64
+		if err := r.db.Model(&domain.User{}).Where(condition, args...).Count(&total).Error; err != nil {
+			log.Printf("Failed to count users: %v", err)
+			return nil, errors.DatabaseError("count", err)
+		}
+		page.Total = &total
+	case domain.UserTotalEstimate:
+		total, err := r.estimateCount("SELECT 1 FROM users WHERE "+condition, args...)
+		if err != nil {
+			log.Printf("Failed to estimate user count: %v", err)
+			return nil, errors.DatabaseError("count", err)
+		}
+		page.Total = &total
+	}
+
+	return page, nil
+}
+
+// estimateCount returns the number of rows the query planner expects the query to return
+func (r *userRepository) estimateCount(query string, args ...interface{}) (int64, error) {
+	var plan string
+	if err := r.db.Raw("EXPLAIN (FORMAT JSON) "+query, args...).Row().Scan(&plan); err != nil {
+		return 0, err
+	}
+	var explained []struct {
+		Plan struct {
+			Rows float64 `json:"Plan Rows"`
+		} `json:"Plan"`
+	}
+	if err := json.Unmarshal([]byte(plan), &explained); err != nil || len(explained) == 0 {
+		return 0, fmt.Errorf("unexpected EXPLAIN output %q", plan)
+	}
+	return int64(explained[0].Plan.Rows), nil
 }
 
 // GetByEmail retrieves a user by email
@@ -107,3 +224,319 @@ func (r *userRepository) GetByEmail(email string) (*domain.User, error) {
 
 	return &user, nil
 }
+
+// UpdatePassword replaces the stored password hash of a user
+func (r *userRepository) UpdatePassword(id uint, hashedPassword string) error {
+	result := r.db.Model(&domain.User{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"password":   hashedPassword,
+		"version":    gorm.Expr("version + 1"),
+		"updated_at": time.Now(),
+	})
+	if result.Error != nil {
+		log.Printf("Failed to update password for user with id %d: %v", id, result.Error)
+		return errors.DatabaseError("update password", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.NotFoundError("user", id)
+	}
+
+	return nil
+}
+
+// Search retrieves the users matching the filter ordered by ID
+func (r *userRepository) Search(filter *domain.UserFilter, offset, limit int) ([]*domain.User, int64, error) {
+	condition, args := "TRUE", []interface{}(nil)
+	if filter != nil {
+		var err error
+		if condition, args, err = userFilterSQL(filter); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	var total int64
+	result := r.db.Model(&domain.User{}).Where(condition, args...).Count(&total)
+	if result.Error != nil {
+		log.Printf("Failed to count users matching filter: %v", result.Error)
+		return nil, 0, errors.DatabaseError("search", result.Error)
+	}
+
+	var users []*domain.User
+	result = r.db.Where(condition, args...).Order("id").Offset(offset).Limit(limit).Find(&users)
+	if result.Error != nil {
+		log.Printf("Failed to search users: %v", result.Error)
+		return nil, 0, errors.DatabaseError("search", result.Error)
+	}
+
+	return users, total, nil
+}
+
+// userFilterColumns maps the searchable and sortable user fields to their
+// columns. Active is derived from deactivated_at and handled separately.
+var userFilterColumns = map[string]string{
+	domain.UserFieldID:        "id",
+	domain.UserFieldEmail:     "email",
+	domain.UserFieldName:      "name",
+	domain.UserFieldCreatedAt: "created_at",
+	domain.UserFieldUpdatedAt: "updated_at",
+}
+
+var userFilterComparisons = map[string]string{
+	domain.FilterEqual:          "=",
+	domain.FilterNotEqual:       "<>",
+	domain.FilterGreater:        ">",
+	domain.FilterGreaterOrEqual: ">=",
+	domain.FilterLess:           "<",
+	domain.FilterLessOrEqual:    "<=",
+}
+
+// likeEscaper escapes the LIKE wildcards of a literal pattern
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// userFilterSQL translates a filter into a WHERE condition and its arguments
+func userFilterSQL(filter *domain.UserFilter) (string, []interface{}, error) {
+	switch filter.Operator {
+	case domain.FilterAnd, domain.FilterOr:
+		if len(filter.Operands) == 0 {
+			return "", nil, errors.InvalidInputError("filter", filter.Operator+" requires operands")
+		}
+		var conditions []string
+		var args []interface{}
+		for _, operand := range filter.Operands {
+			condition, operandArgs, err := userFilterSQL(operand)
+			if err != nil {
+				return "", nil, err
+			}
+			conditions = append(conditions, "("+condition+")")
+			args = append(args, operandArgs...)
+		}
+		return strings.Join(conditions, " "+strings.ToUpper(filter.Operator)+" "), args, nil
+	case domain.FilterNot:
+		if len(filter.Operands) != 1 {
+			return "", nil, errors.InvalidInputError("filter", "not requires a single operand")
+		}
+		condition, args, err := userFilterSQL(filter.Operands[0])
+		if err != nil {
+			return "", nil, err
+		}
+		return "NOT (" + condition + ")", args, nil
+	}
+
+	if filter.Field == domain.UserFieldActive {
+		return activeFilterSQL(filter)
+	}
+	column, ok := userFilterColumns[filter.Field]
+	if !ok {
+		return "", nil, errors.InvalidInputError("filter", fmt.Sprintf("%s is not searchable", filter.Field))
+	}
+
+	value := filter.Value
+	if text, isString := value.(string); isString {
+		column, value = "LOWER("+column+")", strings.ToLower(text)
+	}
+
+	switch filter.Operator {
+	case domain.FilterPresent:
+		if filter.Field == domain.UserFieldEmail || filter.Field == domain.UserFieldName {
+			return column + " <> ''", nil, nil
+		}
+		return column + " IS NOT NULL", nil, nil
+	case domain.FilterContains, domain.FilterStartsWith, domain.FilterEndsWith:
+		text, isString := value.(string)
+		if !isString {
+			return "", nil, errors.InvalidInputError("filter", filter.Operator+" requires a string value")
+		}
+		pattern := likeEscaper.Replace(text)
+		switch filter.Operator {
+		case domain.FilterContains:
+			pattern = "%" + pattern + "%"
+		case domain.FilterStartsWith:
+			pattern = pattern + "%"
+		default:
+			pattern = "%" + pattern
+		}
+		return column + " LIKE ?", []interface{}{pattern}, nil
+	}
+
+	comparison, ok := userFilterComparisons[filter.Operator]
+	if !ok {
+		return "", nil, errors.InvalidInputError("filter", fmt.Sprintf("unsupported operator %s", filter.Operator))
+	}
+	return column + " " + comparison + " ?", []interface{}{value}, nil
+}
+
+// activeFilterSQL translates a filter on the active flag, which is stored as deactivated_at
+func activeFilterSQL(filter *domain.UserFilter) (string, []interface{}, error) {
+	if filter.Operator == domain.FilterPresent {
+		return "TRUE", nil, nil
+	}
+	active, ok := filter.Value.(bool)
+	if !ok || (filter.Operator != domain.FilterEqual && filter.Operator != domain.FilterNotEqual) {
+		return "", nil, errors.InvalidInputError("filter", "active only supports eq and ne with a boolean")
+	}
+	if active == (filter.Operator == domain.FilterEqual) {
+		return "deactivated_at IS NULL", nil, nil
+	}
+	return "deactivated_at IS NOT NULL", nil, nil
+}
+
+// rankedUser is a listed user with its similarity to the search text
+type rankedUser struct {
+	domain.User `gorm:"embedded"`
+	SearchRank  float64
+}
+
+// userSearchRankSQL is the similarity of a user to the search text, passed
+// twice. It is cast to double precision so the value round-trips through
+// cursors exactly.
+const userSearchRankSQL = "CAST(GREATEST(word_similarity(?, name), word_similarity(?, email)) AS double precision)"
+
+// searchRankField names the search rank among the sort keys
+const searchRankField = "search_rank"
+
+// userSortKey is a term of the order of a user listing
+type userSortKey struct {
+	field      string
+	sql        string
+	args       []interface{}
+	descending bool
+}
+
+// userListSQL translates the filter and search of a query into a WHERE
+// condition and its arguments
+func userListSQL(query domain.UserQuery) (string, []interface{}, error) {
+	conditions := []string{"TRUE"}
+	var args []interface{}
+	if query.Filter != nil {
+		condition, filterArgs, err := userFilterSQL(query.Filter)
+		if err != nil {
+			return "", nil, err
+		}
+		conditions = append(conditions, "("+condition+")")
+		args = append(args, filterArgs...)
+	}
+	if query.Search != "" {
+		// Trigram word similarity catches typos, the substring match short
+		// terms; the pg_trgm GIN indexes on name and email serve both
+		pattern := "%" + likeEscaper.Replace(query.Search) + "%"
+		conditions = append(conditions, "(? <% name OR ? <% email OR name ILIKE ? OR email ILIKE ?)")
+		args = append(args, query.Search, query.Search, pattern, pattern)
+	}
+	return strings.Join(conditions, " AND "), args, nil
+}
+
+// userSortKeys returns the order of a query: the sort fields, then the
+// relevance to the search, then the ID so that every user has a unique position
+func userSortKeys(query domain.UserQuery) ([]userSortKey, error) {
+	var keys []userSortKey
+	for _, sort := range query.Sort {
+		column, ok := userFilterColumns[sort.Field]
+		if !ok {
+			return nil, errors.InvalidInputError("sort", fmt.Sprintf("%s is not sortable", sort.Field))
+		}
+		keys = append(keys, userSortKey{field: sort.Field, sql: column, descending: sort.Descending})
+		if sort.Field == domain.UserFieldID {
+			return keys, nil
+		}
+	}
+	if query.Search != "" {
+		keys = append(keys, userSortKey{field: searchRankField, sql: userSearchRankSQL, args: []interface{}{query.Search, query.Search}, descending: true})
+	}
+	return append(keys, userSortKey{field: domain.UserFieldID, sql: "id"}), nil
+}
+
+// userOrderSQL translates sort keys into an ORDER BY clause, reversed to
+// read a page backwards
+func userOrderSQL(keys []userSortKey, reverse bool) clause.OrderBy {
+	var terms []string
+	var args []interface{}
+	for _, key := range keys {
+		term := key.sql
+		if key.descending != reverse {
+			term += " DESC"
+		}
+		terms = append(terms, term)
+		args = append(args, key.args...)
+	}
+	return clause.OrderBy{Expression: clause.Expr{SQL: strings.Join(terms, ", "), Vars: args}}
+}
+
+// keysetSQL builds the condition selecting the users after the key in the
+// order of the sort keys, or before it when reverse is set. For keys a, b it
+// is a > ? OR (a = ? AND b > ?), with < for descending keys.
+func keysetSQL(keys []userSortKey, key domain.UserKey, reverse bool) (string, []interface{}, error) {
+	values, err := parseUserKey(keys, key)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var alternatives []string
+	var args []interface{}
+	for i, sortKey := range keys {
+		var terms []string
+		for _, equal := range keys[:i] {
+			terms = append(terms, equal.sql+" = ?")
+		}
+		comparison := " > ?"
+		if sortKey.descending != reverse {
+			comparison = " < ?"
+		}
+		terms = append(terms, sortKey.sql+comparison)
+		alternatives = append(alternatives, "("+strings.Join(terms, " AND ")+")")
+
+		for j, term := range keys[:i+1] {
+			args = append(args, term.args...)
+			args = append(args, values[j])
+		}
+	}
+	return "(" + strings.Join(alternatives, " OR ") + ")", args, nil
+}
+
+// userKey formats the sort key values of a listed user
+func userKey(keys []userSortKey, row *rankedUser) domain.UserKey {
+	key := make(domain.UserKey, 0, len(keys))
+	for _, sortKey := range keys {
+		switch sortKey.field {
+		case domain.UserFieldID:
+			key = append(key, strconv.FormatUint(uint64(row.ID), 10))
+		case domain.UserFieldEmail:
+			key = append(key, row.Email)
+		case domain.UserFieldName:
+			key = append(key, row.Name)
+		case domain.UserFieldCreatedAt:
+			key = append(key, row.CreatedAt.UTC().Format(time.RFC3339Nano))
+		case domain.UserFieldUpdatedAt:
+			key = append(key, row.UpdatedAt.UTC().Format(time.RFC3339Nano))
+		case searchRankField:
+			key = append(key, strconv.FormatFloat(row.SearchRank, 'g', -1, 64))
+		}
+	}
+	return key
+}
+
+// parseUserKey parses the values of a key formatted by userKey
+func parseUserKey(keys []userSortKey, key domain.UserKey) ([]interface{}, error) {
+	invalid := errors.InvalidInputError("cursor", "does not match the sort order")
+	if len(key) != len(keys) {
+		return nil, invalid
+	}
+	values := make([]interface{}, len(keys))
+	for i, sortKey := range keys {
+		var err error
+		switch sortKey.field {
+		case domain.UserFieldID:
+			var id uint64
+			id, err = strconv.ParseUint(key[i], 10, 32)
+			values[i] = uint(id)
+		case domain.UserFieldCreatedAt, domain.UserFieldUpdatedAt:
+			values[i], err = time.Parse(time.RFC3339Nano, key[i])
+		case searchRankField:
+			values[i], err = strconv.ParseFloat(key[i], 64)
+		default:
+			values[i] = key[i]
+		}
+		if err != nil {
+			return nil, invalid
+		}
+	}
+	return values, nil
+}
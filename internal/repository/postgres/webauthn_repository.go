@@ -0,0 +1,139 @@
+package postgres
+
+import (
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/errors"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type webAuthnRepository struct {
+	db *gorm.DB
+}
+
+// NewWebAuthnRepository creates a new PostgreSQL WebAuthn credential and challenge repository
+func NewWebAuthnRepository(db *gorm.DB) domain.WebAuthnRepository {
+	return &webAuthnRepository{db: db}
+}
+
+// CreateCredential stores a newly registered credential
+func (r *webAuthnRepository) CreateCredential(credential *domain.WebAuthnCredential) error {
+	credential.CreatedAt = time.Now()
+
+	result := r.db.Create(credential)
+	if result.Error != nil {
+		log.Printf("Failed to create WebAuthn credential for user %d: %v", credential.UserID, result.Error)
+		return errors.DatabaseError("create WebAuthn credential", result.Error)
+	}
+
+	return nil
+}
+
+// GetCredential retrieves a credential by its base64url credential ID
+func (r *webAuthnRepository) GetCredential(credentialID string) (*domain.WebAuthnCredential, error) {
+	var credential domain.WebAuthnCredential
+	result := r.db.Where("credential_id = ?", credentialID).First(&credential)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		log.Printf("Failed to get WebAuthn credential: %v", result.Error)
+		return nil, errors.DatabaseError("get WebAuthn credential", result.Error)
+	}
+
+	return &credential, nil
+}
+
+// ListCredentials retrieves the credentials of a user, oldest first
+func (r *webAuthnRepository) ListCredentials(userID uint) ([]*domain.WebAuthnCredential, error) {
+	var credentials []*domain.WebAuthnCredential
+	result := r.db.Where("user_id = ?", userID).Order("created_at, id").Find(&credentials)
+	if result.Error != nil {
+		log.Printf("Failed to list WebAuthn credentials for user %d: %v", userID, result.Error)
+		return nil, errors.DatabaseError("list WebAuthn credentials", result.Error)
+	}
+
+	return credentials, nil
+}
+
+// CountCredentials counts the credentials of a user
+func (r *webAuthnRepository) CountCredentials(userID uint) (int64, error) {
+	var count int64
+	result := r.db.Model(&domain.WebAuthnCredential{}).Where("user_id = ?", userID).Count(&count)
+	if result.Error != nil {
+		log.Printf("Failed to count WebAuthn credentials for user %d: %v", userID, result.Error)
+		return 0, errors.DatabaseError("count WebAuthn credentials", result.Error)
+	}
+
+	return count, nil
+}
+
+// UseCredential records a login with the credential. The update only applies
+// while the counter is unchanged, so of two logins checked against the same
+// counter value only one succeeds.
+func (r *webAuthnRepository) UseCredential(id uint, oldSignCount, newSignCount uint32) (bool, error) {
+	result := r.db.Model(&domain.WebAuthnCredential{}).
+		Where("id = ? AND sign_count = ?", id, oldSignCount).
+		Updates(map[string]interface{}{"sign_count": newSignCount, "last_used_at": time.Now()})
+	if result.Error != nil {
+		log.Printf("Failed to record use of WebAuthn credential %d: %v", id, result.Error)
+		return false, errors.DatabaseError("use WebAuthn credential", result.Error)
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+// DeleteCredential removes a credential of the user
+func (r *webAuthnRepository) DeleteCredential(userID, id uint) (bool, error) {
+	result := r.db.Where("id = ? AND user_id = ?", id, userID).Delete(&domain.WebAuthnCredential{})
+	if result.Error != nil {
+		log.Printf("Failed to delete WebAuthn credential %d of user %d: %v", id, userID, result.Error)
+		return false, errors.DatabaseError("delete WebAuthn credential", result.Error)
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+// CreateChallenge stores the challenge of a ceremony in progress
+func (r *webAuthnRepository) CreateChallenge(challenge *domain.WebAuthnChallenge) error {
+	challenge.CreatedAt = time.Now()
+
+	result := r.db.Create(challenge)
+	if result.Error != nil {
+		log.Printf("Failed to create WebAuthn challenge: %v", result.Error)
+		return errors.DatabaseError("create WebAuthn challenge", result.Error)
+	}
+
+	return nil
+}
+
+// GetChallengeByHash retrieves a challenge by the hash of its value
+func (r *webAuthnRepository) GetChallengeByHash(challengeHash string) (*domain.WebAuthnChallenge, error) {
+	var challenge domain.WebAuthnChallenge
+	result := r.db.Where("challenge_hash = ?", challengeHash).First(&challenge)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		log.Printf("Failed to get WebAuthn challenge: %v", result.Error)
+		return nil, errors.DatabaseError("get WebAuthn challenge", result.Error)
+	}
+
+	return &challenge, nil
+}
+
+// MarkChallengeUsed marks an unused challenge as used. The conditional update
+// makes each challenge single-use across replicas.
+func (r *webAuthnRepository) MarkChallengeUsed(id uint) (bool, error) {
+	result := r.db.Model(&domain.WebAuthnChallenge{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		log.Printf("Failed to mark WebAuthn challenge %d as used: %v", id, result.Error)
+		return false, errors.DatabaseError("use WebAuthn challenge", result.Error)
+	}
+
+	return result.RowsAffected == 1, nil
+}
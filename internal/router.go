@@ -1,10 +1,18 @@
 package internal
 
 import (
+	"UserRESTfulApi/internal/domain"
 	"UserRESTfulApi/internal/handlers"
 	"UserRESTfulApi/internal/middleware"
 	"UserRESTfulApi/internal/repository/postgres"
 	"UserRESTfulApi/internal/service"
+	"UserRESTfulApi/pkg/config"
+	"UserRESTfulApi/pkg/mailer"
+	"UserRESTfulApi/pkg/password"
+	"UserRESTfulApi/pkg/secrets"
+	"UserRESTfulApi/pkg/token"
+	"fmt"
+	"log"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -16,43 +24,267 @@ type Router struct {
 }
 
 // NewRouter creates a new router instance
-func NewRouter(db *gorm.DB) *Router {
-	engine := SetupRouter(db)
-	return &Router{engine: engine}
+func NewRouter(db *gorm.DB, cfg *config.Config) (*Router, error) {
+	engine, err := SetupRouter(db, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Router{engine: engine}, nil
 }
 
 // SetupRouter sets up the router with all routes
-func SetupRouter(db *gorm.DB) *gin.Engine {
+func SetupRouter(db *gorm.DB, cfg *config.Config) (*gin.Engine, error) {
 	router := gin.Default()
 
-	// Add metrics middleware
-	router.Use(middleware.Metrics())
+	// Resolve client IPs from the X-Real-IP/X-Forwarded-For headers set by nginx,
+	// but only when the request comes from a trusted proxy
+	if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		return nil, err
+	}
+	router.RemoteIPHeaders = []string{"X-Real-IP", "X-Forwarded-For"}
+
+	// Add request ID and metrics middleware, and render errors recorded by
+	// handlers as RFC 7807 problems
+	router.Use(middleware.RequestID(), middleware.Metrics(), middleware.Problems())
 
 	// Create dependencies
+	tokenManager, err := token.NewManager(cfg.Auth)
+	if err != nil {
+		return nil, err
+	}
+
+	hasher, err := password.NewHasher(cfg.Password)
+	if err != nil {
+		return nil, err
+	}
+
 	userRepo := postgres.NewUserRepository(db)
-	userService := service.NewUserService(userRepo)
+	passwordPolicy, err := password.NewPolicy(cfg.Policy)
+	if err != nil {
+		return nil, err
+	}
+	if err := passwordPolicy.CheckAlgorithm(cfg.Password.Algorithm); err != nil {
+		return nil, fmt.Errorf("PASSWORD_MAX_LENGTH: %w", err)
+	}
+	mail, err := mailer.New(cfg.Mail)
+	if err != nil {
+		return nil, err
+	}
+	auditService := service.NewAuditService(postgres.NewAuditRepository(db))
+	auditHandler := handlers.NewAuditHandler(auditService)
+	refreshTokenRepo := postgres.NewRefreshTokenRepository(db)
+	emailVerificationRepo := postgres.NewEmailVerificationRepository(db)
+	emailChangeRepo := postgres.NewEmailChangeRepository(db)
+	emailVerificationService := service.NewEmailVerificationService(userRepo, emailVerificationRepo, emailChangeRepo, refreshTokenRepo, mail, auditService, cfg.Auth)
+	emailVerificationHandler := handlers.NewEmailVerificationHandler(emailVerificationService)
+	apiConfig := cfg.API
+	if apiConfig.CursorSecret == "" {
+		if apiConfig.CursorSecret, err = token.NewOpaque(); err != nil {
+			return nil, err
+		}
+		log.Printf("API_CURSOR_SECRET is not set; pagination cursors will not survive a restart or work across replicas")
+	}
+	userService := service.NewUserService(userRepo, hasher, passwordPolicy, emailVerificationService, auditService, apiConfig)
 	userHandler := handlers.NewUserHandler(userService)
+	roleRepo := postgres.NewRoleRepository(db)
+	roleService := service.NewRoleService(roleRepo, userRepo)
+	if err := roleService.EnsureBuiltinRoles(); err != nil {
+		return nil, err
+	}
+	roleHandler := handlers.NewRoleHandler(roleService)
+	sessionRepo := postgres.NewSessionRepository(db)
+	sessionHandler := handlers.NewSessionHandler(service.NewSessionService(sessionRepo, refreshTokenRepo, auditService))
+	loginThrottleRepo := postgres.NewLoginThrottleRepository(db)
+	loginThrottleService := service.NewLoginThrottleService(loginThrottleRepo, cfg.Lockout)
+	lockoutHandler := handlers.NewLockoutHandler(loginThrottleService)
+	mfaCipher, err := secrets.NewCipher(cfg.Auth.MFAEncryptionKey)
+	if err != nil {
+		return nil, fmt.Errorf("AUTH_MFA_ENCRYPTION_KEY: %w", err)
+	}
+	mfaRepo := postgres.NewMFARepository(db)
+	mfaService := service.NewMFAService(mfaRepo, refreshTokenRepo, mfaCipher, cfg.Auth)
+	mfaHandler := handlers.NewMFAHandler(mfaService)
+	switch cfg.Auth.LoginMethods {
+	case config.LoginMethodPassword, config.LoginMethodPasswordless, config.LoginMethodBoth:
+	default:
+		return nil, fmt.Errorf("AUTH_LOGIN_METHODS: must be %q, %q or %q", config.LoginMethodPassword, config.LoginMethodPasswordless, config.LoginMethodBoth)
+	}
+	magicLinkService := service.NewMagicLinkService(userRepo, postgres.NewMagicLinkRepository(db), mail, auditService, cfg.Auth)
+	magicLinkHandler := handlers.NewMagicLinkHandler(magicLinkService)
+	webAuthnService := service.NewWebAuthnService(userRepo, postgres.NewWebAuthnRepository(db), cfg.Auth)
+	authService := service.NewAuthService(userService, roleRepo, tokenManager, refreshTokenRepo, sessionRepo, loginThrottleService, mfaService, magicLinkService, webAuthnService, auditService, cfg.Auth)
+	authHandler := handlers.NewAuthHandler(authService)
+	webAuthnHandler := handlers.NewWebAuthnHandler(webAuthnService, authService)
+	passwordHandler := handlers.NewPasswordHandler(passwordPolicy)
+	passwordResetRepo := postgres.NewPasswordResetRepository(db)
+	passwordResetService := service.NewPasswordResetService(userRepo, passwordResetRepo, refreshTokenRepo, hasher, passwordPolicy, mail, auditService, cfg.Auth)
+	passwordResetHandler := handlers.NewPasswordResetHandler(passwordResetService)
+
+	// Signing keys get their own key, so a leaked MFA key does not expose them
+	if cfg.OIDC.KeyEncryptionKey != "" && cfg.OIDC.KeyEncryptionKey == cfg.Auth.MFAEncryptionKey {
+		return nil, fmt.Errorf("OIDC_KEY_ENCRYPTION_KEY must differ from AUTH_MFA_ENCRYPTION_KEY")
+	}
+	oidcCipher, err := secrets.NewCipher(cfg.OIDC.KeyEncryptionKey)
+	if err != nil {
+		return nil, fmt.Errorf("OIDC_KEY_ENCRYPTION_KEY: %w", err)
+	}
+	oauthClientRepo := postgres.NewOAuthClientRepository(db)
+	oauthGrantRepo := postgres.NewOAuthGrantRepository(db)
+	signingKeyService := service.NewSigningKeyService(postgres.NewSigningKeyRepository(db), oidcCipher, cfg.OIDC)
+	oauthClientService := service.NewOAuthClientService(oauthClientRepo, oauthGrantRepo)
+	oidcService := service.NewOIDCService(oauthClientRepo, oauthGrantRepo, userRepo, signingKeyService, cfg.OIDC)
+	oidcHandler := handlers.NewOIDCHandler(oidcService, oauthClientService, signingKeyService, cfg.OIDC.LoginURL)
+	oauthClientHandler := handlers.NewOAuthClientHandler(oauthClientService, signingKeyService)
+	scimService := service.NewSCIMService(userRepo, refreshTokenRepo, hasher, passwordPolicy, auditService, cfg.SCIM)
+	scimHandler := handlers.NewSCIMHandler(scimService)
+	apiKeyService := service.NewAPIKeyService(postgres.NewAPIKeyRepository(db), userRepo, roleRepo)
+	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeyService)
+	problemHandler := handlers.NewProblemHandler()
+
+	requireAuth := middleware.Auth(authService, apiKeyService)
+	can := middleware.RequirePermission
+	canOnUser := middleware.RequireUserPermission
+	requireSession := middleware.RequireUserSession()
+	rejectImpersonation := middleware.RejectImpersonation()
+	requireIfMatch := middleware.RequireIfMatch(cfg.API.RequireIfMatch)
 
 	// API routes
 	api := router.Group("/api")
 	{
+		// Auth routes
+		auth := api.Group("/auth")
+		{
+			auth.POST("/login", authHandler.Login)
+			auth.POST("/refresh", authHandler.Refresh)
+			auth.POST("/logout", authHandler.Logout)
+			auth.POST("/logout-all", requireAuth, requireSession, authHandler.LogoutAll)
+			auth.POST("/magic-link", magicLinkHandler.SendMagicLink)
+			auth.POST("/magic-link/verify", authHandler.LoginWithMagicLink)
+			auth.POST("/mfa/verify", authHandler.VerifyMFA)
+			auth.POST("/webauthn/login/begin", webAuthnHandler.BeginLogin)
+			auth.POST("/webauthn/login/finish", webAuthnHandler.FinishLogin)
+			auth.POST("/webauthn/mfa/begin", webAuthnHandler.BeginMFA)
+			auth.POST("/webauthn/mfa/finish", webAuthnHandler.VerifyMFA)
+			auth.POST("/webauthn/register/begin", requireAuth, requireSession, rejectImpersonation, webAuthnHandler.BeginRegistration)
+			auth.POST("/webauthn/register/finish", requireAuth, requireSession, rejectImpersonation, webAuthnHandler.FinishRegistration)
+			auth.GET("/webauthn/credentials", requireAuth, requireSession, webAuthnHandler.ListPasskeys)
+			auth.DELETE("/webauthn/credentials/:id", requireAuth, requireSession, rejectImpersonation, webAuthnHandler.DeletePasskey)
+			auth.GET("/mfa", requireAuth, requireSession, mfaHandler.GetStatus)
+			auth.POST("/mfa/enroll", requireAuth, requireSession, rejectImpersonation, mfaHandler.Enroll)
+			auth.POST("/mfa/confirm", requireAuth, requireSession, rejectImpersonation, mfaHandler.Confirm)
+			auth.GET("/password/policy", passwordHandler.GetPolicy)
+			auth.POST("/password/check", passwordHandler.CheckPassword)
+			auth.POST("/password/forgot", passwordResetHandler.ForgotPassword)
+			auth.POST("/password/reset", passwordResetHandler.ResetPassword)
+			auth.GET("/verify-email", emailVerificationHandler.VerifyEmail)
+			auth.POST("/verify-email", emailVerificationHandler.VerifyEmail)
+			auth.POST("/verify-email/resend", emailVerificationHandler.ResendVerification)
+			auth.GET("/email-change/revert", emailVerificationHandler.ConfirmEmailChangeRevert)
+			auth.POST("/email-change/revert", emailVerificationHandler.RevertEmailChange)
+		}
+
 		// User routes
 		users := api.Group("/users")
 		{
 			users.POST("", userHandler.CreateUser)
-			users.GET("/:id", userHandler.GetUser)
-			users.PUT("/:id", userHandler.UpdateUser)
-			users.DELETE("/:id", userHandler.DeleteUser)
-			users.GET("", userHandler.ListUsers)
+			users.GET("/:id", requireAuth, canOnUser(domain.PermissionUsersRead), userHandler.GetUser)
+			users.PUT("/:id", requireAuth, canOnUser(domain.PermissionUsersUpdate), requireIfMatch, userHandler.UpdateUser)
+			users.PATCH("/:id", requireAuth, canOnUser(domain.PermissionUsersUpdate), requireIfMatch, userHandler.PatchUser)
+			users.DELETE("/:id", requireAuth, canOnUser(domain.PermissionUsersDelete), requireIfMatch, userHandler.DeleteUser)
+			users.GET("", requireAuth, can(domain.PermissionUsersList), userHandler.ListUsers)
+			users.GET("/:id/roles", requireAuth, roleHandler.GetUserRoles)
+			users.POST("/:id/roles", requireAuth, can(domain.PermissionRolesManage), roleHandler.AssignUserRole)
+			users.DELETE("/:id/roles/:role", requireAuth, can(domain.PermissionRolesManage), roleHandler.RemoveUserRole)
+			users.DELETE("/:id/mfa", requireAuth, can(domain.PermissionMFAManage), rejectImpersonation, mfaHandler.ResetUserMFA)
+			users.POST("/:id/impersonate", requireAuth, can(domain.PermissionUsersImpersonate), requireSession, rejectImpersonation, authHandler.Impersonate)
+			users.GET("/:id/sessions", requireAuth, canOnUser(domain.PermissionSessionsManage), sessionHandler.ListSessions)
+			users.DELETE("/:id/sessions", requireAuth, canOnUser(domain.PermissionSessionsManage), sessionHandler.RevokeAllSessions)
+			users.DELETE("/:id/sessions/:sid", requireAuth, canOnUser(domain.PermissionSessionsManage), sessionHandler.RevokeSession)
 		}
+
+		// Role routes
+		roles := api.Group("/roles", requireAuth, can(domain.PermissionRolesManage))
+		{
+			roles.GET("", roleHandler.ListRoles)
+			roles.POST("", roleHandler.CreateRole)
+			roles.DELETE("/:name", roleHandler.DeleteRole)
+		}
+
+		// Login lockout routes
+		lockouts := api.Group("/lockouts", requireAuth, can(domain.PermissionLockoutsManage))
+		{
+			lockouts.GET("", lockoutHandler.ListLockouts)
+			lockouts.DELETE("/:kind/:subject", lockoutHandler.ClearLockout)
+		}
+
+		// OAuth client and signing key administration
+		oauth := api.Group("/oauth", requireAuth, can(domain.PermissionOAuthManage))
+		{
+			oauth.GET("/clients", oauthClientHandler.ListClients)
+			oauth.POST("/clients", oauthClientHandler.CreateClient)
+			oauth.DELETE("/clients/:client_id", oauthClientHandler.DeleteClient)
+			oauth.POST("/keys/rotate", oauthClientHandler.RotateSigningKey)
+		}
+
+		// API key routes
+		apiKeys := api.Group("/api-keys", requireAuth)
+		{
+			apiKeys.GET("", apiKeyHandler.ListAPIKeys)
+			apiKeys.POST("", rejectImpersonation, apiKeyHandler.CreateAPIKey)
+			apiKeys.DELETE("/:id", rejectImpersonation, apiKeyHandler.DeleteAPIKey)
+		}
+
+		// Audit log routes
+		audit := api.Group("/audit", requireAuth, can(domain.PermissionAuditRead))
+		{
+			audit.GET("", auditHandler.ListAuditEvents)
+			audit.GET("/verify", auditHandler.VerifyAuditLog)
+		}
+
+		// Catalog of the problem types error responses can have
+		api.GET("/problems", problemHandler.ListProblems)
+		api.GET("/problems/:code", problemHandler.GetProblem)
+	}
+
+	// OpenID Connect provider routes
+	router.GET("/.well-known/openid-configuration", oidcHandler.Discovery)
+	oauth2 := router.Group("/oauth2")
+	{
+		oauth2.GET("/authorize", oidcHandler.Authorize)
+		oauth2.POST("/authorize", requireAuth, requireSession, rejectImpersonation, oidcHandler.Approve)
+		oauth2.POST("/token", oidcHandler.Token)
+		oauth2.GET("/userinfo", oidcHandler.UserInfo)
+		oauth2.POST("/userinfo", oidcHandler.UserInfo)
+		oauth2.GET("/jwks", oidcHandler.JWKS)
+		oauth2.POST("/introspect", oidcHandler.Introspect)
+		oauth2.POST("/revoke", oidcHandler.Revoke)
+	}
+
+	// SCIM 2.0 provisioning routes, reporting errors in the SCIM format
+	scim := router.Group("/scim/v2")
+	{
+		scim.GET("/ServiceProviderConfig", scimHandler.ServiceProviderConfig)
+		scim.GET("/ResourceTypes", scimHandler.ListResourceTypes)
+		scim.GET("/ResourceTypes/:id", scimHandler.GetResourceType)
+		scim.GET("/Schemas", scimHandler.ListSchemas)
+		scim.GET("/Schemas/:id", scimHandler.GetSchema)
+
+		users := scim.Group("/Users", middleware.AuthWithErrors(authService, apiKeyService, handlers.SCIMAbort))
+		users.GET("", scimHandler.ListUsers)
+		users.POST("", scimHandler.CreateUser)
+		users.GET("/:id", scimHandler.GetUser)
+		users.PUT("/:id", scimHandler.ReplaceUser)
+		users.PATCH("/:id", scimHandler.PatchUser)
+		users.DELETE("/:id", scimHandler.DeleteUser)
 	}
 
 	// Health check
 	router.GET("/health", func(c *gin.Context) {
 		c.JSON(200, gin.H{"status": "ok"})
 	})
+	router.NoRoute(problemHandler.NoRoute)
 
-	return router
+	return router, nil
 }
 
 // Run starts the HTTP server
@@ -0,0 +1,176 @@
+package service
+
+import (
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/errors"
+	"UserRESTfulApi/pkg/token"
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// apiKeyUsageInterval limits how often the last use of a key is written, so
+// a busy batch job does not update the row on every request
+const apiKeyUsageInterval = time.Minute
+
+type apiKeyService struct {
+	keys  domain.APIKeyRepository
+	users domain.UserRepository
+	roles domain.RoleRepository
+	now   func() time.Time
+}
+
+// NewAPIKeyService creates a new API key service
+func NewAPIKeyService(keys domain.APIKeyRepository, users domain.UserRepository, roles domain.RoleRepository) domain.APIKeyService {
+	return &apiKeyService{keys: keys, users: users, roles: roles, now: time.Now}
+}
+
+// Create issues a new API key. Every scope must be a permission the actor
+// holds, so a key never grants more than its creator could do. Service
+// account keys require the apikeys:manage permission. Keys cannot be created
+// with another API key.
+func (s *apiKeyService) Create(actor *domain.Principal, key *domain.APIKey) (string, error) {
+	if actor == nil || actor.APIKeyID != 0 {
+		return "", errors.ForbiddenError("create API keys with an API key")
+	}
+	if key.IsServiceAccount() && !actor.Can(domain.PermissionAPIKeysManage) {
+		return "", errors.ForbiddenError("create service account API keys")
+	}
+	if !key.IsServiceAccount() && *key.UserID != actor.ID() {
+		return "", errors.ForbiddenError("create API keys for another user")
+	}
+
+	key.Name = strings.TrimSpace(key.Name)
+	if key.Name == "" {
+		return "", errors.InvalidInputError("name", "name is required")
+	}
+	if err := s.validateScopes(actor, key); err != nil {
+		return "", err
+	}
+	for _, allowed := range key.AllowedIPs {
+		if _, err := domain.ParseIPRange(allowed); err != nil {
+			return "", errors.InvalidInputError("allowed_ips", fmt.Sprintf("%q is not an IP address or CIDR range", allowed))
+		}
+	}
+	if key.ExpiresAt != nil && !key.ExpiresAt.After(s.now()) {
+		return "", errors.InvalidInputError("expires_at", "must be in the future")
+	}
+
+	secret, prefix, err := token.NewAPIKey()
+	if err != nil {
+		return "", errors.InternalServerError(err)
+	}
+	key.Prefix = prefix
+	key.KeyHash = token.HashOpaque(secret)
+	key.CreatedBy = actor.ID()
+	key.LastUsedAt = nil
+	key.LastUsedIP = ""
+
+	if err := s.keys.Create(key); err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// validateScopes checks that the scopes are known permissions held by the actor
+func (s *apiKeyService) validateScopes(actor *domain.Principal, key *domain.APIKey) error {
+	if len(key.Scopes) == 0 {
+		return errors.InvalidInputError("scopes", "at least one scope is required")
+	}
+	for _, scope := range key.Scopes {
+		if !domain.IsKnownPermission(scope) {
+			return errors.InvalidInputError("scopes", fmt.Sprintf("unknown scope %s", scope))
+		}
+		if key.IsServiceAccount() && scope.IsSelf() {
+			return errors.InvalidInputError("scopes", fmt.Sprintf("%s needs a user and cannot be granted to a service account", scope))
+		}
+		if !actor.Can(scope) {
+			return errors.ForbiddenError(fmt.Sprintf("grant the %s scope", scope))
+		}
+	}
+	return nil
+}
+
+// List returns the actor's keys, or every key for actors with apikeys:manage
+func (s *apiKeyService) List(actor *domain.Principal) ([]*domain.APIKey, error) {
+	if actor.Can(domain.PermissionAPIKeysManage) {
+		return s.keys.List(nil)
+	}
+	if actor.ID() == 0 {
+		return nil, errors.ForbiddenError("list API keys")
+	}
+	userID := actor.ID()
+	return s.keys.List(&userID)
+}
+
+// Delete revokes one of the actor's keys, or any key for actors with apikeys:manage
+func (s *apiKeyService) Delete(actor *domain.Principal, id uint) error {
+	key, err := s.keys.Get(id)
+	if err != nil {
+		return err
+	}
+	owned := key != nil && key.UserID != nil && *key.UserID == actor.ID() && actor.ID() != 0
+	if !owned && !actor.Can(domain.PermissionAPIKeysManage) {
+		// Keys of other users are reported as missing rather than forbidden
+		return errors.NotFoundError("API key", id)
+	}
+	if key == nil {
+		return errors.NotFoundError("API key", id)
+	}
+
+	if _, err := s.keys.Delete(id); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Authenticate resolves an API key to the principal it acts as. The key must
+// match its stored hash, be unexpired and be used from an allowed IP. A user's
+// key also requires the user to still exist and be active.
+func (s *apiKeyService) Authenticate(secret, clientIP string) (*domain.Principal, error) {
+	prefix, ok := token.APIKeyPrefix(secret)
+	if !ok {
+		return nil, errors.UnauthorizedError("invalid API key")
+	}
+	key, err := s.keys.GetByPrefix(prefix)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil || subtle.ConstantTimeCompare([]byte(token.HashOpaque(secret)), []byte(key.KeyHash)) != 1 {
+		return nil, errors.UnauthorizedError("invalid API key")
+	}
+
+	now := s.now()
+	if key.IsExpired(now) {
+		return nil, errors.UnauthorizedError("API key has expired")
+	}
+	if !key.AllowsIP(clientIP) {
+		return nil, errors.UnauthorizedError("API key is not allowed from this IP address")
+	}
+
+	var user *domain.User
+	var roles []*domain.Role
+	if !key.IsServiceAccount() {
+		user, err = s.users.Get(*key.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if user == nil || !user.IsActive() {
+			return nil, errors.UnauthorizedError("API key owner no longer exists or is deactivated")
+		}
+		if roles, err = s.roles.GetUserRoles(user.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	// Usage tracking is best effort and must not fail the request
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= apiKeyUsageInterval || key.LastUsedIP != clientIP {
+		if err := s.keys.MarkUsed(key.ID, now, clientIP); err != nil {
+			log.Printf("Failed to record use of API key %d: %v", key.ID, err)
+		}
+	}
+
+	return domain.NewAPIKeyPrincipal(key, user, roles), nil
+}
@@ -0,0 +1,398 @@
+package service
+
+import (
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/errors"
+	"sort"
+	"testing"
+	"time"
+)
+
+// Mock API key repository for testing
+type mockAPIKeyRepository struct {
+	keys      map[uint]*domain.APIKey
+	markCalls int
+}
+
+func newMockAPIKeyRepository() *mockAPIKeyRepository {
+	return &mockAPIKeyRepository{keys: make(map[uint]*domain.APIKey)}
+}
+
+func (m *mockAPIKeyRepository) Create(key *domain.APIKey) error {
+	key.ID = uint(len(m.keys) + 1)
+	copied := *key
+	m.keys[key.ID] = &copied
+	return nil
+}
+
+func (m *mockAPIKeyRepository) Get(id uint) (*domain.APIKey, error) {
+	key, exists := m.keys[id]
+	if !exists {
+		return nil, nil
+	}
+	copied := *key
+	return &copied, nil
+}
+
+func (m *mockAPIKeyRepository) GetByPrefix(prefix string) (*domain.APIKey, error) {
+	for _, key := range m.keys {
+		if key.Prefix == prefix {
+			copied := *key
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+// List returns the newest keys first, like the Postgres repository
+func (m *mockAPIKeyRepository) List(userID *uint) ([]*domain.APIKey, error) {
+	var keys []*domain.APIKey
+	for _, key := range m.keys {
+		if userID == nil || (key.UserID != nil && *key.UserID == *userID) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID > keys[j].ID })
+	return keys, nil
+}
+
+func (m *mockAPIKeyRepository) Delete(id uint) (bool, error) {
+	_, exists := m.keys[id]
+	delete(m.keys, id)
+	return exists, nil
+}
+
+func (m *mockAPIKeyRepository) MarkUsed(id uint, at time.Time, clientIP string) error {
+	m.markCalls++
+	m.keys[id].LastUsedAt = &at
+	m.keys[id].LastUsedIP = clientIP
+	return nil
+}
+
+// apiKeyTestTime is the fixed time the API key service runs at in tests
+var apiKeyTestTime = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+// newTestAPIKeyService creates an API key service for the administrator
+// admin@example.com (user 1) and the user user@example.com (user 2). The
+// returned function advances its clock.
+func newTestAPIKeyService() (*apiKeyService, *mockAPIKeyRepository, *mockUserRepository, func(time.Duration)) {
+	keys := newMockAPIKeyRepository()
+	users := newMockUserRepository()
+	roles := newMockRoleRepository()
+	admin, user := builtinRole(domain.RoleAdmin), builtinRole(domain.RoleUser)
+	roles.Create(admin)
+	roles.Create(user)
+
+	users.users[1] = &domain.User{ID: 1, Email: "admin@example.com"}
+	users.users[2] = &domain.User{ID: 2, Email: "user@example.com"}
+	roles.AssignToUser(1, admin.ID)
+	roles.AssignToUser(2, user.ID)
+
+	now := apiKeyTestTime
+	service := NewAPIKeyService(keys, users, roles).(*apiKeyService)
+	service.now = func() time.Time { return now }
+	return service, keys, users, func(d time.Duration) { now = now.Add(d) }
+}
+
+// keyAdmin and keyUser are the principals of the users of newTestAPIKeyService
+func keyAdmin() *domain.Principal {
+	return domain.NewPrincipal(&domain.User{ID: 1, Email: "admin@example.com"}, []*domain.Role{builtinRole(domain.RoleAdmin)})
+}
+
+func keyUser() *domain.Principal {
+	return domain.NewPrincipal(&domain.User{ID: 2, Email: "user@example.com"}, []*domain.Role{builtinRole(domain.RoleUser)})
+}
+
+func createAPIKey(t *testing.T, service domain.APIKeyService, actor *domain.Principal, key *domain.APIKey) string {
+	t.Helper()
+	secret, err := service.Create(actor, key)
+	if err != nil {
+		t.Fatalf("Create(%s) error = %v", key.Name, err)
+	}
+	return secret
+}
+
+// builtinRole returns a role granting the permissions of the built-in role
+func builtinRole(name string) *domain.Role {
+	role := &domain.Role{Name: name, Builtin: true}
+	for _, permission := range domain.BuiltinRoles[name] {
+		role.Permissions = append(role.Permissions, domain.RolePermission{Permission: permission})
+	}
+	return role
+}
+
+func ownedBy(id uint) *uint {
+	return &id
+}
+
+func TestAPIKeyCreate(t *testing.T) {
+	past := apiKeyTestTime.Add(-time.Hour)
+	selfScope := []domain.Permission{domain.PermissionUsersRead.Self()}
+	tests := []struct {
+		name     string
+		actor    *domain.Principal
+		withKey  bool
+		key      *domain.APIKey
+		wantName string
+		wantErr  errors.ErrorType
+	}{
+		{
+			name:     "own key",
+			actor:    keyUser(),
+			key:      &domain.APIKey{Name: " Backup job ", UserID: ownedBy(2), Scopes: selfScope, AllowedIPs: []string{"10.0.0.0/8", "192.0.2.7"}},
+			wantName: "Backup job",
+		},
+		{
+			name:     "service account",
+			actor:    keyAdmin(),
+			key:      &domain.APIKey{Name: "Sync", Scopes: []domain.Permission{domain.PermissionUsersList}},
+			wantName: "Sync",
+		},
+		{name: "no scopes", actor: keyUser(), key: &domain.APIKey{Name: "Key", UserID: ownedBy(2)}, wantErr: errors.InvalidInput},
+		{name: "unknown scope", actor: keyUser(), key: &domain.APIKey{Name: "Key", UserID: ownedBy(2), Scopes: []domain.Permission{"users:fly"}}, wantErr: errors.InvalidInput},
+		{name: "scope not held", actor: keyUser(), key: &domain.APIKey{Name: "Key", UserID: ownedBy(2), Scopes: []domain.Permission{domain.PermissionUsersList}}, wantErr: errors.Forbidden},
+		{name: "blank name", actor: keyUser(), key: &domain.APIKey{Name: " ", UserID: ownedBy(2), Scopes: selfScope}, wantErr: errors.InvalidInput},
+		{name: "invalid IP", actor: keyUser(), key: &domain.APIKey{Name: "Key", UserID: ownedBy(2), Scopes: selfScope, AllowedIPs: []string{"10.0.0.300"}}, wantErr: errors.InvalidInput},
+		{name: "expired", actor: keyUser(), key: &domain.APIKey{Name: "Key", UserID: ownedBy(2), Scopes: selfScope, ExpiresAt: &past}, wantErr: errors.InvalidInput},
+		{name: "other user", actor: keyUser(), key: &domain.APIKey{Name: "Key", UserID: ownedBy(1), Scopes: selfScope}, wantErr: errors.Forbidden},
+		{name: "service account without permission", actor: keyUser(), key: &domain.APIKey{Name: "Key", Scopes: selfScope}, wantErr: errors.Forbidden},
+		{name: "service account with self scope", actor: keyAdmin(), key: &domain.APIKey{Name: "Key", Scopes: selfScope}, wantErr: errors.InvalidInput},
+		// Keys cannot mint further keys
+		{name: "with an API key", actor: keyUser(), withKey: true, key: &domain.APIKey{Name: "Key", UserID: ownedBy(2), Scopes: selfScope}, wantErr: errors.Forbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, keys, _, _ := newTestAPIKeyService()
+			actor := tt.actor
+			if tt.withKey {
+				secret := createAPIKey(t, service, actor, &domain.APIKey{Name: "Minter", UserID: ownedBy(actor.ID()), Scopes: selfScope})
+				var err error
+				if actor, err = service.Authenticate(secret, "10.1.2.3"); err != nil {
+					t.Fatalf("Authenticate() error = %v", err)
+				}
+			}
+			stored := len(keys.keys)
+
+			secret, err := service.Create(actor, tt.key)
+			if tt.wantErr != "" {
+				assertErrorType(t, "Create()", err, tt.wantErr)
+				if len(keys.keys) != stored {
+					t.Errorf("failed Create() stored a key")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+
+			key := keys.keys[tt.key.ID]
+			if key.Name != tt.wantName || key.CreatedBy != actor.ID() || key.Prefix != secret[:len(key.Prefix)] {
+				t.Errorf("Create() stored %+v", key)
+			}
+			if key.KeyHash == "" || key.KeyHash == secret {
+				t.Error("Create() did not store a hash of the key")
+			}
+		})
+	}
+}
+
+func TestAPIKeyAuthenticate(t *testing.T) {
+	tests := []struct {
+		name string
+		// service selects the service account key instead of the user key
+		service  bool
+		secret   func(secret string) string
+		clientIP string
+		// change runs after the keys are created
+		change  func(service *apiKeyService, users *mockUserRepository, advance func(time.Duration))
+		check   func(t *testing.T, principal *domain.Principal)
+		wantErr bool
+	}{
+		{
+			name:     "user key",
+			clientIP: "192.0.2.10",
+			check: func(t *testing.T, principal *domain.Principal) {
+				if principal.ID() != 1 || principal.APIKeyID != 1 {
+					t.Errorf("Authenticate() principal = %+v, want user 1 through key 1", principal)
+				}
+				if !principal.Can(domain.PermissionUsersRead) || !principal.CanAccessUser(domain.PermissionUsersRead, 1) {
+					t.Error("user key lost a permission covered by its scopes")
+				}
+				if principal.Can(domain.PermissionUsersDelete) || principal.Can(domain.PermissionRolesManage) {
+					t.Error("user key kept permissions outside its scopes")
+				}
+			},
+		},
+		{
+			name:     "service account key",
+			service:  true,
+			clientIP: "203.0.113.1",
+			check: func(t *testing.T, principal *domain.Principal) {
+				if principal.ID() != 0 || !principal.Can(domain.PermissionUsersList) || principal.Can(domain.PermissionUsersRead) {
+					t.Errorf("service key principal = %+v, want exactly its scopes", principal)
+				}
+			},
+		},
+		{name: "address not allowed", clientIP: "198.51.100.1", wantErr: true},
+		{name: "tampered key", secret: func(secret string) string { return secret + "x" }, clientIP: "192.0.2.10", wantErr: true},
+		{name: "malformed key", secret: func(secret string) string { return "not-a-key" }, clientIP: "192.0.2.10", wantErr: true},
+		{
+			name:     "expired key",
+			clientIP: "192.0.2.10",
+			change: func(service *apiKeyService, users *mockUserRepository, advance func(time.Duration)) {
+				advance(time.Hour)
+			},
+			wantErr: true,
+		},
+		{
+			name:     "deleted key",
+			clientIP: "192.0.2.10",
+			change: func(service *apiKeyService, users *mockUserRepository, advance func(time.Duration)) {
+				service.Delete(keyAdmin(), 1)
+			},
+			wantErr: true,
+		},
+		{
+			name:     "deactivated owner",
+			clientIP: "192.0.2.10",
+			change: func(service *apiKeyService, users *mockUserRepository, advance func(time.Duration)) {
+				deactivatedAt := apiKeyTestTime
+				users.users[1].DeactivatedAt = &deactivatedAt
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, _, users, advance := newTestAPIKeyService()
+			expires := apiKeyTestTime.Add(time.Hour)
+			secret := createAPIKey(t, service, keyAdmin(), &domain.APIKey{
+				Name:       "Reporting",
+				UserID:     ownedBy(1),
+				Scopes:     []domain.Permission{domain.PermissionUsersRead},
+				AllowedIPs: []string{"192.0.2.0/24"},
+				ExpiresAt:  &expires,
+			})
+			serviceKey := createAPIKey(t, service, keyAdmin(), &domain.APIKey{Name: "Sync", Scopes: []domain.Permission{domain.PermissionUsersList}})
+			if tt.service {
+				secret = serviceKey
+			}
+			if tt.secret != nil {
+				secret = tt.secret(secret)
+			}
+			if tt.change != nil {
+				tt.change(service, users, advance)
+			}
+
+			principal, err := service.Authenticate(secret, tt.clientIP)
+			if tt.wantErr {
+				assertErrorType(t, "Authenticate()", err, errors.Unauthorized)
+				return
+			}
+			if err != nil {
+				t.Fatalf("Authenticate() error = %v", err)
+			}
+			tt.check(t, principal)
+		})
+	}
+}
+
+func TestAPIKeyUsageIsRecorded(t *testing.T) {
+	tests := []struct {
+		name      string
+		after     time.Duration
+		clientIP  string
+		wantMarks int
+	}{
+		// Usage is recorded at most once per interval from the same address
+		{name: "same address right away", clientIP: "203.0.113.1", wantMarks: 1},
+		{name: "same address after the interval", after: apiKeyUsageInterval, clientIP: "203.0.113.1", wantMarks: 2},
+		{name: "new address right away", clientIP: "203.0.113.2", wantMarks: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, keys, _, advance := newTestAPIKeyService()
+			secret := createAPIKey(t, service, keyAdmin(), &domain.APIKey{Name: "Sync", Scopes: []domain.Permission{domain.PermissionUsersList}})
+			if _, err := service.Authenticate(secret, "203.0.113.1"); err != nil {
+				t.Fatalf("Authenticate() error = %v", err)
+			}
+
+			advance(tt.after)
+			if _, err := service.Authenticate(secret, tt.clientIP); err != nil {
+				t.Fatalf("Authenticate() error = %v", err)
+			}
+			if keys.markCalls != tt.wantMarks || keys.keys[1].LastUsedIP != tt.clientIP {
+				t.Errorf("MarkUsed called %d times, last from %s, want %d from %s", keys.markCalls, keys.keys[1].LastUsedIP, tt.wantMarks, tt.clientIP)
+			}
+		})
+	}
+}
+
+func TestAPIKeyList(t *testing.T) {
+	tests := []struct {
+		name      string
+		actor     *domain.Principal
+		wantNames []string
+	}{
+		{name: "own keys", actor: keyUser(), wantNames: []string{"Normal"}},
+		{name: "as manager", actor: keyAdmin(), wantNames: []string{"Normal", "Admin"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, _, _, _ := newTestAPIKeyService()
+			createAPIKey(t, service, keyAdmin(), &domain.APIKey{Name: "Admin", UserID: ownedBy(1), Scopes: []domain.Permission{domain.PermissionUsersRead}})
+			createAPIKey(t, service, keyUser(), &domain.APIKey{Name: "Normal", UserID: ownedBy(2), Scopes: []domain.Permission{domain.PermissionUsersRead.Self()}})
+
+			keys, err := service.List(tt.actor)
+			if err != nil || len(keys) != len(tt.wantNames) {
+				t.Fatalf("List() = %v, %v, want %v", keys, err, tt.wantNames)
+			}
+			for i, key := range keys {
+				if key.Name != tt.wantNames[i] {
+					t.Errorf("List()[%d] = %s, want %s", i, key.Name, tt.wantNames[i])
+				}
+			}
+		})
+	}
+}
+
+func TestAPIKeyDelete(t *testing.T) {
+	tests := []struct {
+		name    string
+		actor   *domain.Principal
+		id      uint
+		wantErr bool
+	}{
+		{name: "own key", actor: keyUser(), id: 2},
+		{name: "as manager", actor: keyAdmin(), id: 2},
+		{name: "key of another user", actor: keyUser(), id: 1, wantErr: true},
+		{name: "missing key", actor: keyAdmin(), id: 3, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, keys, _, _ := newTestAPIKeyService()
+			createAPIKey(t, service, keyAdmin(), &domain.APIKey{Name: "Admin", UserID: ownedBy(1), Scopes: []domain.Permission{domain.PermissionUsersRead}})
+			createAPIKey(t, service, keyUser(), &domain.APIKey{Name: "Normal", UserID: ownedBy(2), Scopes: []domain.Permission{domain.PermissionUsersRead.Self()}})
+
+			err := service.Delete(tt.actor, tt.id)
+			if tt.wantErr {
+				assertErrorType(t, "Delete()", err, errors.NotFound)
+				if len(keys.keys) != 2 {
+					t.Errorf("failed Delete() removed a key")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Delete() error = %v", err)
+			}
+			if _, exists := keys.keys[tt.id]; exists {
+				t.Errorf("Delete() kept key %d", tt.id)
+			}
+		})
+	}
+}
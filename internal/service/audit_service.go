@@ -0,0 +1,133 @@
+package service
+
+import (
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/errors"
+	"log"
+	"time"
+)
+
+// auditVerifyBatchSize is the number of events loaded at once when verifying the chain
+const auditVerifyBatchSize = 500
+
+type auditService struct {
+	repo domain.AuditRepository
+}
+
+// NewAuditService creates a new audit log service
+func NewAuditService(repo domain.AuditRepository) domain.AuditService {
+	return &auditService{repo: repo}
+}
+
+// Record appends the event to the audit log
+func (s *auditService) Record(event *domain.AuditEvent) {
+	if err := s.repo.Append(event); err != nil {
+		log.Printf("Failed to record audit event %s for %s %s: %v", event.Action, event.TargetType, event.TargetID, err)
+	}
+}
+
+// List returns the events matching the filter, newest first
+func (s *auditService) List(actor *domain.Principal, filter domain.AuditFilter, page, limit int) ([]*domain.AuditEvent, error) {
+	if !actor.Can(domain.PermissionAuditRead) {
+		return nil, errors.ForbiddenError("read the audit log")
+	}
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return nil, errors.InvalidInputError("from", "must be before to")
+	}
+
+	return s.repo.List(filter, page, limit)
+}
+
+// Verify walks the whole chain, checking that every event links to its
+// predecessor and that its content still matches its hash
+func (s *auditService) Verify(actor *domain.Principal) (*domain.AuditVerification, error) {
+	if !actor.Can(domain.PermissionAuditRead) {
+		return nil, errors.ForbiddenError("verify the audit log")
+	}
+
+	result := &domain.AuditVerification{Valid: true}
+	prevHash := ""
+	var afterID uint
+	for {
+		events, err := s.repo.ListAfter(afterID, auditVerifyBatchSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, event := range events {
+			if event.PrevHash != prevHash || event.Hash != event.ComputeHash() {
+				id := event.ID
+				result.Valid = false
+				result.BrokenAt = &id
+				return result, nil
+			}
+			result.Checked++
+			prevHash = event.Hash
+			afterID = event.ID
+		}
+		if len(events) < auditVerifyBatchSize {
+			return result, nil
+		}
+	}
+}
+
+// userAuditState returns the audited fields of a user. The password hash is
+// redacted, so the log shows that it changed but not its value.
+func userAuditState(user *domain.User) map[string]interface{} {
+	if user == nil {
+		return map[string]interface{}{}
+	}
+	state := map[string]interface{}{
+		"email":          user.Email,
+		"name":           user.Name,
+		"pending_email":  user.PendingEmail,
+		"email_verified": user.IsEmailVerified(),
+		"active":         user.IsActive(),
+	}
+	if user.Password != "" {
+		state["password"] = user.Password
+	}
+	return state
+}
+
+// userAuditChanges returns the fields that differ between two states of a
+// user; before is nil for a created user and after is nil for a deleted one
+func userAuditChanges(before, after *domain.User) map[string]domain.AuditChange {
+	old, updated := userAuditState(before), userAuditState(after)
+	changes := make(map[string]domain.AuditChange)
+	for field := range mergedKeys(old, updated) {
+		if old[field] == updated[field] {
+			continue
+		}
+		change := domain.AuditChange{Old: old[field], New: updated[field]}
+		if field == "password" {
+			change = domain.AuditChange{Old: redacted(old[field]), New: redacted(updated[field])}
+		}
+		changes[field] = change
+	}
+	return changes
+}
+
+// mergedKeys returns the union of the keys of two maps
+func mergedKeys(a, b map[string]interface{}) map[string]bool {
+	keys := make(map[string]bool, len(a)+len(b))
+	for key := range a {
+		keys[key] = true
+	}
+	for key := range b {
+		keys[key] = true
+	}
+	return keys
+}
+
+// redacted hides a secret value, keeping whether it was set
+func redacted(value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+	return domain.AuditRedacted
+}
+
+// auditTime formats a time for audit details
+func auditTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
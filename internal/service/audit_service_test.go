@@ -0,0 +1,242 @@
+package service
+
+import (
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/errors"
+	"UserRESTfulApi/pkg/config"
+	"UserRESTfulApi/pkg/password"
+	"testing"
+	"time"
+)
+
+// Mock audit repository for testing
+type mockAuditRepository struct {
+	events []*domain.AuditEvent
+}
+
+func newMockAuditRepository() *mockAuditRepository {
+	return &mockAuditRepository{}
+}
+
+func (m *mockAuditRepository) Append(event *domain.AuditEvent) error {
+	prevHash := ""
+	if len(m.events) > 0 {
+		prevHash = m.events[len(m.events)-1].Hash
+	}
+	event.ID = uint(len(m.events) + 1)
+	event.Seal(prevHash, time.Now())
+	m.events = append(m.events, event)
+	return nil
+}
+
+func (m *mockAuditRepository) List(filter domain.AuditFilter, page, limit int) ([]*domain.AuditEvent, error) {
+	var events []*domain.AuditEvent
+	for i := len(m.events) - 1; i >= 0; i-- {
+		event := m.events[i]
+		if filter.Action != "" && event.Action != filter.Action {
+			continue
+		}
+		if filter.TargetID != "" && event.TargetID != filter.TargetID {
+			continue
+		}
+		if filter.ActorID != nil && (event.ActorID == nil || *event.ActorID != *filter.ActorID) {
+			continue
+		}
+		if filter.ImpersonatorID != nil && (event.ImpersonatorID == nil || *event.ImpersonatorID != *filter.ImpersonatorID) {
+			continue
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+func (m *mockAuditRepository) ListAfter(afterID uint, limit int) ([]*domain.AuditEvent, error) {
+	var events []*domain.AuditEvent
+	for _, event := range m.events {
+		if event.ID > afterID && len(events) < limit {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+// actions returns the actions of the recorded events in order
+func (m *mockAuditRepository) actions() []string {
+	actions := make([]string, 0, len(m.events))
+	for _, event := range m.events {
+		actions = append(actions, event.Action)
+	}
+	return actions
+}
+
+func TestAuditChainVerification(t *testing.T) {
+	repo := newMockAuditRepository()
+	service := NewAuditService(repo)
+	auditor := domain.NewPrincipal(&domain.User{ID: 1}, []*domain.Role{builtinRole(domain.RoleAdmin)})
+
+	for i := uint(1); i <= 3; i++ {
+		event := domain.NewAuditEvent(domain.AuditUserUpdated, auditor, testRequest).ForUser(i)
+		event.Changes = map[string]domain.AuditChange{"name": {Old: "Old", New: "New"}}
+		service.Record(event)
+	}
+	if repo.events[0].PrevHash != "" || repo.events[1].PrevHash != repo.events[0].Hash {
+		t.Fatal("events are not chained")
+	}
+	if repo.events[0].ClientIP != testClientIP || repo.events[0].RequestID != "test-request" || *repo.events[0].ActorID != 1 {
+		t.Errorf("event = %+v, want the actor and request recorded", repo.events[0])
+	}
+
+	result, err := service.Verify(auditor)
+	if err != nil || !result.Valid || result.Checked != 3 {
+		t.Fatalf("Verify() = %+v, %v, want 3 valid events", result, err)
+	}
+
+	repo.events[1].Changes["name"] = domain.AuditChange{Old: "Old", New: "Forged"}
+	result, _ = service.Verify(auditor)
+	if result.Valid || result.BrokenAt == nil || *result.BrokenAt != 2 {
+		t.Errorf("Verify(altered) = %+v, want broken at event 2", result)
+	}
+
+	repo.events[1].Changes["name"] = domain.AuditChange{Old: "Old", New: "New"}
+	repo.events = append(repo.events[:1], repo.events[2:]...)
+	result, _ = service.Verify(auditor)
+	if result.Valid || result.BrokenAt == nil || *result.BrokenAt != 3 {
+		t.Errorf("Verify(removed) = %+v, want broken at event 3", result)
+	}
+
+	normal := domain.NewPrincipal(&domain.User{ID: 2}, nil)
+	_, err = service.Verify(normal)
+	assertErrorType(t, "Verify(normal)", err, errors.Forbidden)
+	_, err = service.List(normal, domain.AuditFilter{}, 1, 10)
+	assertErrorType(t, "List(normal)", err, errors.Forbidden)
+	from, to := time.Now(), time.Now().Add(-time.Hour)
+	_, err = service.List(auditor, domain.AuditFilter{From: &from, To: &to}, 1, 10)
+	assertErrorType(t, "List(inverted range)", err, errors.InvalidInput)
+}
+
+func TestUserMutationsAreAudited(t *testing.T) {
+	repo := newMockUserRepository()
+	audit := newMockAuditRepository()
+	service := NewUserService(repo, testHasher, password.DefaultPolicy(), newMockEmailVerifier(), NewAuditService(audit), testAPIConfig)
+
+	user := &domain.User{Email: "audit@example.com", Password: "Password123!", Name: "Audit User"}
+	if err := service.Create(user, testRequest); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	created := audit.events[0]
+	if created.Action != domain.AuditUserCreated || created.ActorID != nil || created.TargetID != "1" || created.ClientIP != testClientIP {
+		t.Errorf("created event = %+v", created)
+	}
+	if created.Changes["password"].New != domain.AuditRedacted || created.Changes["email"].New != "audit@example.com" {
+		t.Errorf("created changes = %+v, want the email and a redacted password", created.Changes)
+	}
+
+	actor := domain.NewPrincipal(repo.users[1], nil)
+	actor.Request = domain.RequestMeta{ClientIP: "198.51.100.4", RequestID: "update-request"}
+	err := service.Update(actor, &domain.User{ID: 1, Email: "audit@example.com", Name: "Renamed", Password: "NewPassword123!"})
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	updated := audit.events[1]
+	if updated.Action != domain.AuditUserUpdated || *updated.ActorID != 1 || updated.RequestID != "update-request" {
+		t.Errorf("updated event = %+v", updated)
+	}
+	want := map[string]domain.AuditChange{
+		"name":     {Old: "Audit User", New: "Renamed"},
+		"password": {Old: domain.AuditRedacted, New: domain.AuditRedacted},
+	}
+	if len(updated.Changes) != len(want) || updated.Changes["name"] != want["name"] || updated.Changes["password"] != want["password"] {
+		t.Errorf("updated changes = %+v, want %+v", updated.Changes, want)
+	}
+
+	admin := domain.NewPrincipal(&domain.User{ID: 2}, []*domain.Role{builtinRole(domain.RoleAdmin)})
+	if err := service.Delete(admin, 1, 0); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	deleted := audit.events[2]
+	if deleted.Action != domain.AuditUserDeleted || *deleted.ActorID != 2 || deleted.Changes["email"].Old != "audit@example.com" {
+		t.Errorf("deleted event = %+v", deleted)
+	}
+
+	// Rejected changes are not recorded
+	service.Delete(actor, 1, 0)
+	service.Delete(admin, 1, 0)
+	if len(audit.events) != 3 {
+		t.Errorf("recorded %d events, want 3", len(audit.events))
+	}
+}
+
+func TestAuthEventsAreAudited(t *testing.T) {
+	tests := []struct {
+		name         string
+		loginMethods string
+		// act performs the auth operations after the events already recorded
+		act        func(t *testing.T, service *authService)
+		wantEvents []string
+		check      func(t *testing.T, event *domain.AuditEvent)
+	}{
+		{
+			name: "login",
+			act: func(t *testing.T, service *authService) {
+				login(t, service)
+			},
+			wantEvents: []string{domain.AuditLoginSucceeded},
+			check: func(t *testing.T, event *domain.AuditEvent) {
+				if *event.ActorID != 1 || event.TargetID != "1" || event.ClientIP != testClientIP {
+					t.Errorf("successful login event = %+v", event)
+				}
+			},
+		},
+		{
+			name: "logout",
+			act: func(t *testing.T, service *authService) {
+				if err := service.Logout(login(t, service).RefreshToken, testRequest); err != nil {
+					t.Fatalf("Logout() error = %v", err)
+				}
+			},
+			wantEvents: []string{domain.AuditLoginSucceeded, domain.AuditTokensRevoked},
+		},
+		{
+			name: "failed login",
+			act: func(t *testing.T, service *authService) {
+				service.Login("test@example.com", "WrongPassword123!", testRequest)
+			},
+			wantEvents: []string{domain.AuditLoginFailed},
+			check: func(t *testing.T, event *domain.AuditEvent) {
+				if event.ActorID != nil || event.Details["email"] != "test@example.com" || event.Details["reason"] == "" {
+					t.Errorf("failed login event = %+v", event)
+				}
+			},
+		},
+		{
+			name:         "magic link login",
+			loginMethods: config.LoginMethodPasswordless,
+			act: func(t *testing.T, service *authService) {
+				linkToken, _ := sendMagicLink(t, service)
+				service.LoginWithMagicLink("wrong-token", "", "", testRequest)
+				service.LoginWithMagicLink(linkToken, "", "", testRequest)
+			},
+			wantEvents: []string{domain.AuditMagicLinkRequested, domain.AuditLoginFailed, domain.AuditLoginSucceeded},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, _, audit := newTestAuthService(t, config.AuthConfig{LoginMethods: tt.loginMethods})
+			tt.act(t, service)
+
+			got := audit.actions()
+			if len(got) != len(tt.wantEvents) {
+				t.Fatalf("recorded %v, want %v", got, tt.wantEvents)
+			}
+			for i := range tt.wantEvents {
+				if got[i] != tt.wantEvents[i] {
+					t.Fatalf("recorded %v, want %v", got, tt.wantEvents)
+				}
+			}
+			if tt.check != nil {
+				tt.check(t, audit.events[0])
+			}
+		})
+	}
+}
@@ -0,0 +1,656 @@
+package service
+
+import (
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/errors"
+	"UserRESTfulApi/pkg/config"
+	"UserRESTfulApi/pkg/token"
+	"UserRESTfulApi/pkg/useragent"
+	stderrors "errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sessionSeenInterval limits how often the last seen time of a session is
+// written while its access tokens are used
+const sessionSeenInterval = time.Minute
+
+type authService struct {
+	users           domain.UserService
+	roles           domain.RoleRepository
+	tokens          *token.Manager
+	refreshTokens   domain.RefreshTokenRepository
+	sessions        domain.SessionRepository
+	throttle        domain.LoginThrottleService
+	mfa             domain.MFAService
+	magicLinks      domain.MagicLinkService
+	passkeys        domain.WebAuthnService
+	audit           domain.AuditService
+	refreshTTL      time.Duration
+	impersonateTTL  time.Duration
+	passwordLogin   bool
+	requireVerified bool
+	mfaRoles        map[string]bool
+	now             func() time.Time
+}
+
+// NewAuthService creates a new authentication service
+func NewAuthService(users domain.UserService, roles domain.RoleRepository, tokens *token.Manager, refreshTokens domain.RefreshTokenRepository, sessions domain.SessionRepository, throttle domain.LoginThrottleService, mfa domain.MFAService, magicLinks domain.MagicLinkService, passkeys domain.WebAuthnService, audit domain.AuditService, cfg config.AuthConfig) domain.AuthService {
+	mfaRoles := make(map[string]bool, len(cfg.MFARequiredRoles))
+	for _, role := range cfg.MFARequiredRoles {
+		mfaRoles[role] = true
+	}
+
+	return &authService{
+		users:           users,
+		roles:           roles,
+		tokens:          tokens,
+		refreshTokens:   refreshTokens,
+		sessions:        sessions,
+		throttle:        throttle,
+		mfa:             mfa,
+		magicLinks:      magicLinks,
+		passkeys:        passkeys,
+		audit:           audit,
+		refreshTTL:      cfg.RefreshTokenTTL,
+		impersonateTTL:  cfg.ImpersonationTTL,
+		passwordLogin:   cfg.PasswordLogin(),
+		requireVerified: cfg.RequireVerifiedEmail,
+		mfaRoles:        mfaRoles,
+		now:             time.Now,
+	}
+}
+
+// Login verifies the user's credentials and issues an access and refresh token.
+// Attempts for a locked or backing off account or client IP are rejected
+// before the password is checked. Users with MFA enabled get an MFA challenge
+// instead, and the failed login counter is only cleared once it is completed.
+// Rejected attempts are recorded in the audit log.
+func (s *authService) Login(email, plainPassword string, request domain.RequestMeta) (*domain.LoginResult, error) {
+	if !s.passwordLogin {
+		return nil, errors.ForbiddenError("log in with a password")
+	}
+	if err := s.throttle.Check(email, request.ClientIP); err != nil {
+		s.recordLoginFailure(0, email, "password", request, err)
+		return nil, err
+	}
+
+	user, err := s.users.VerifyPassword(email, plainPassword)
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok && appErr.Type == errors.Unauthorized {
+			if err := s.throttle.RecordFailure(email, request.ClientIP); err != nil {
+				log.Printf("Failed to record failed login for %s: %v", email, err)
+			}
+			s.recordLoginFailure(0, email, "password", request, err)
+		}
+		return nil, err
+	}
+
+	if !user.IsActive() {
+		err := errors.ForbiddenError("log in to a deactivated account")
+		s.recordLoginFailure(user.ID, email, "password", request, err)
+		return nil, err
+	}
+
+	if s.requireVerified && !user.IsEmailVerified() {
+		err := errors.ForbiddenError("log in before verifying your email address")
+		s.recordLoginFailure(user.ID, email, "password", request, err)
+		return nil, err
+	}
+
+	return s.completeLogin(user, "password", request)
+}
+
+// LoginWithMagicLink redeems a magic link or emailed code and issues an access
+// and refresh token, or an MFA challenge, exactly like a password login. The
+// link proves the user controls the mailbox, so unverified addresses are
+// accepted. Wrong codes count as failed logins of the email address.
+func (s *authService) LoginWithMagicLink(linkToken, email, code string, request domain.RequestMeta) (*domain.LoginResult, error) {
+	if err := s.throttle.Check(email, request.ClientIP); err != nil {
+		s.recordLoginFailure(0, email, "magic_link", request, err)
+		return nil, err
+	}
+
+	user, err := s.magicLinks.Redeem(linkToken, email, code)
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok && appErr.Type == errors.Unauthorized {
+			if err := s.throttle.RecordFailure(email, request.ClientIP); err != nil {
+				log.Printf("Failed to record failed login for %s: %v", email, err)
+			}
+			s.recordLoginFailure(0, email, "magic_link", request, err)
+		}
+		return nil, err
+	}
+
+	if !user.IsActive() {
+		err := errors.ForbiddenError("log in to a deactivated account")
+		s.recordLoginFailure(user.ID, user.Email, "magic_link", request, err)
+		return nil, err
+	}
+
+	return s.completeLogin(user, "magic_link", request)
+}
+
+// LoginWithPasskey verifies a passkey login and issues an access and refresh
+// token. The passkey must be user verified, so it counts as both factors and
+// no MFA challenge follows. Rejected passkeys count as failed logins of the
+// client IP.
+func (s *authService) LoginWithPasskey(assertion *domain.WebAuthnAssertion, request domain.RequestMeta) (*domain.AccessToken, error) {
+	if err := s.throttle.Check("", request.ClientIP); err != nil {
+		s.recordLoginFailure(0, "", "passkey", request, err)
+		return nil, err
+	}
+
+	user, err := s.passkeys.FinishLogin(assertion)
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok && appErr.Type == errors.Unauthorized {
+			if err := s.throttle.RecordFailure("", request.ClientIP); err != nil {
+				log.Printf("Failed to record failed passkey login from %s: %v", request.ClientIP, err)
+			}
+			s.recordLoginFailure(0, "", "passkey", request, err)
+		}
+		return nil, err
+	}
+
+	if !user.IsActive() {
+		err := errors.ForbiddenError("log in to a deactivated account")
+		s.recordLoginFailure(user.ID, user.Email, "passkey", request, err)
+		return nil, err
+	}
+	if err := s.throttle.RecordSuccess(user.Email); err != nil {
+		log.Printf("Failed to clear failed logins for %s: %v", user.Email, err)
+	}
+
+	accessToken, err := s.startSession(user, true, request)
+	if err != nil {
+		return nil, err
+	}
+	s.recordAuthEvent(domain.AuditLoginSucceeded, user.ID, request, map[string]string{"method": "passkey"})
+	return accessToken, nil
+}
+
+// VerifyMFA exchanges the challenge from Login and a TOTP or recovery code for
+// an access and refresh token. Wrong codes count as failed logins.
+func (s *authService) VerifyMFA(mfaToken, code string, request domain.RequestMeta) (*domain.AccessToken, error) {
+	userID, err := s.mfa.VerifyChallenge(mfaToken, code)
+	return s.finishMFA(userID, err, "mfa", request)
+}
+
+// BeginPasskeyMFA starts completing the challenge from Login with one of the
+// user's passkeys
+func (s *authService) BeginPasskeyMFA(mfaToken string) (*domain.WebAuthnRequestOptions, error) {
+	userID, err := s.mfa.ChallengeUser(mfaToken)
+	if err != nil {
+		return nil, err
+	}
+	return s.passkeys.BeginMFA(userID)
+}
+
+// VerifyPasskeyMFA exchanges the challenge from Login and a passkey assertion
+// for an access and refresh token. Rejected passkeys count as failed logins.
+func (s *authService) VerifyPasskeyMFA(mfaToken string, assertion *domain.WebAuthnAssertion, request domain.RequestMeta) (*domain.AccessToken, error) {
+	userID, err := s.mfa.CompleteChallenge(mfaToken, func(userID uint) error {
+		return s.passkeys.FinishMFA(userID, assertion)
+	})
+	return s.finishMFA(userID, err, "mfa_passkey", request)
+}
+
+// finishMFA issues tokens once a second factor was accepted for the user, or
+// counts the failure when it was rejected
+func (s *authService) finishMFA(userID uint, err error, method string, request domain.RequestMeta) (*domain.AccessToken, error) {
+	if err != nil {
+		if userID != 0 {
+			if user, lookupErr := s.lookupUser(userID); lookupErr == nil {
+				if err := s.throttle.RecordFailure(user.Email, request.ClientIP); err != nil {
+					log.Printf("Failed to record failed MFA code for %s: %v", user.Email, err)
+				}
+				s.recordLoginFailure(user.ID, user.Email, method, request, err)
+			}
+		}
+		return nil, err
+	}
+
+	user, err := s.lookupUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.throttle.RecordSuccess(user.Email); err != nil {
+		log.Printf("Failed to clear failed logins for %s: %v", user.Email, err)
+	}
+
+	accessToken, err := s.startSession(user, true, request)
+	if err != nil {
+		return nil, err
+	}
+	s.recordAuthEvent(domain.AuditLoginSucceeded, user.ID, request, map[string]string{"method": method})
+	return accessToken, nil
+}
+
+// Authenticate validates an access token and returns the principal it was
+// issued for. The token's session must not have been revoked, which is checked
+// against the database on every request so a revocation applies at once on
+// every replica.
+func (s *authService) Authenticate(accessToken, clientIP string) (*domain.Principal, error) {
+	claims, err := s.tokens.Parse(accessToken)
+	if err != nil {
+		if stderrors.Is(err, token.ErrExpiredToken) {
+			return nil, errors.UnauthorizedError("token has expired")
+		}
+		return nil, errors.UnauthorizedError("invalid token")
+	}
+
+	userID, err := claims.UserID()
+	if err != nil {
+		return nil, errors.UnauthorizedError("invalid token")
+	}
+	actorID, err := claims.ActorID()
+	if err != nil {
+		return nil, errors.UnauthorizedError("invalid token")
+	}
+
+	user, err := s.lookupUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if actorID != 0 {
+		return s.authenticateImpersonation(claims, user, actorID, clientIP)
+	}
+
+	// Tokens without a session claim predate session tracking and expire on their own
+	if claims.SessionID != "" {
+		if err := s.checkSession(claims.SessionID, user.ID, clientIP); err != nil {
+			return nil, err
+		}
+	}
+
+	roles, err := s.roles.GetUserRoles(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !claims.HasMethod(token.MethodMFA) {
+		roles = s.withoutMFARoles(roles)
+	}
+
+	principal := domain.NewPrincipal(user, roles)
+	principal.SessionID = claims.SessionID
+	return principal, nil
+}
+
+// Refresh exchanges a refresh token for a new access and refresh token. Each
+// refresh token can be used once; presenting an already rotated token is
+// treated as theft and revokes every token in its family.
+func (s *authService) Refresh(refreshToken string, request domain.RequestMeta) (*domain.AccessToken, error) {
+	stored, err := s.refreshTokens.GetByHash(token.HashOpaque(refreshToken))
+	if err != nil {
+		return nil, err
+	}
+	if stored == nil || stored.RevokedAt != nil {
+		return nil, errors.UnauthorizedError("invalid refresh token")
+	}
+	if stored.RotatedAt != nil {
+		return nil, s.reuseDetected(stored, request)
+	}
+	if !s.now().Before(stored.ExpiresAt) {
+		return nil, errors.UnauthorizedError("refresh token has expired")
+	}
+
+	rotated, err := s.refreshTokens.MarkRotated(stored.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !rotated {
+		// Another request exchanged or revoked the token between our read and update
+		return nil, s.reuseDetected(stored, request)
+	}
+
+	user, err := s.lookupUser(stored.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, err := s.issue(user, stored.FamilyID, stored.MFA)
+	if err != nil {
+		return nil, err
+	}
+	s.refreshSession(stored, request)
+	return accessToken, nil
+}
+
+// Logout revokes the refresh token and every token rotated from the same login
+func (s *authService) Logout(refreshToken string, request domain.RequestMeta) error {
+	stored, err := s.refreshTokens.GetByHash(token.HashOpaque(refreshToken))
+	if err != nil {
+		return err
+	}
+	if stored == nil {
+		return errors.UnauthorizedError("invalid refresh token")
+	}
+	if err := s.refreshTokens.RevokeFamily(stored.FamilyID); err != nil {
+		return err
+	}
+
+	s.recordAuthEvent(domain.AuditTokensRevoked, stored.UserID, request, map[string]string{"reason": "logout"})
+	return nil
+}
+
+// LogoutAll revokes every refresh token of the actor, ending all sessions
+func (s *authService) LogoutAll(actor *domain.Principal) error {
+	if err := s.refreshTokens.RevokeAllForUser(actor.ID()); err != nil {
+		return err
+	}
+
+	s.recordAuthEvent(domain.AuditTokensRevoked, actor.ID(), actor.Request, map[string]string{"reason": "logout_all"})
+	return nil
+}
+
+// maxImpersonationReasonLength limits the reason recorded for an impersonation
+const maxImpersonationReasonLength = 500
+
+// Impersonate issues an access token that lets the actor act as the user. The
+// token carries the user as its subject and the actor in its act claim, is
+// bound to the actor's session and cannot be refreshed. Users who may
+// impersonate others cannot be impersonated, so an admin cannot borrow
+// another admin's roles.
+func (s *authService) Impersonate(actor *domain.Principal, userID uint, reason string) (*domain.AccessToken, error) {
+	if actor.Impersonated() {
+		return nil, errors.ForbiddenError("impersonate a user while impersonating")
+	}
+	if actor.SessionID == "" {
+		return nil, errors.ForbiddenError("impersonate a user without a login session")
+	}
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, errors.InvalidInputError("reason", "is required")
+	}
+	if len(reason) > maxImpersonationReasonLength {
+		return nil, errors.InvalidInputError("reason", "must be at most "+strconv.Itoa(maxImpersonationReasonLength)+" characters")
+	}
+	if userID == actor.ID() {
+		return nil, errors.InvalidInputError("user_id", "cannot impersonate yourself")
+	}
+
+	user, err := s.users.Get(domain.SystemPrincipal(), userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.IsActive() {
+		return nil, errors.ForbiddenError("impersonate a deactivated user")
+	}
+	roles, err := s.roles.GetUserRoles(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if domain.NewPrincipal(user, roles).Can(domain.PermissionUsersImpersonate) {
+		return nil, errors.ForbiddenError("impersonate a user who can impersonate others")
+	}
+
+	signed, expiresAt, err := s.tokens.IssueImpersonation(user.ID, user.Email, actor.ID(), actor.SessionID, s.impersonateTTL)
+	if err != nil {
+		return nil, errors.InternalServerError(err)
+	}
+
+	log.Printf("User %d started impersonating user %d until %s", actor.ID(), user.ID, expiresAt.Format(time.RFC3339))
+	event := domain.NewAuditEvent(domain.AuditImpersonationStarted, actor, actor.Request).ForUser(user.ID)
+	event.Details = map[string]string{"reason": reason, "session_id": actor.SessionID, "expires_at": auditTime(expiresAt)}
+	s.audit.Record(event)
+
+	return &domain.AccessToken{
+		AccessToken: signed,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(s.impersonateTTL.Seconds()),
+		ExpiresAt:   expiresAt,
+	}, nil
+}
+
+// authenticateImpersonation resolves an impersonation token to a principal for
+// its subject that remembers the impersonating actor. The token is only
+// accepted while the actor's session is active and the actor may still
+// impersonate users. Roles of the subject that require MFA are withheld.
+func (s *authService) authenticateImpersonation(claims *token.Claims, user *domain.User, actorID uint, clientIP string) (*domain.Principal, error) {
+	if claims.SessionID == "" {
+		return nil, errors.UnauthorizedError("invalid token")
+	}
+	actor, err := s.lookupUser(actorID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkSession(claims.SessionID, actor.ID, clientIP); err != nil {
+		return nil, err
+	}
+	actorRoles, err := s.roles.GetUserRoles(actor.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !domain.NewPrincipal(actor, actorRoles).Can(domain.PermissionUsersImpersonate) {
+		return nil, errors.UnauthorizedError("impersonation is no longer allowed")
+	}
+
+	roles, err := s.roles.GetUserRoles(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	principal := domain.NewPrincipal(user, s.withoutMFARoles(roles))
+	principal.SessionID = claims.SessionID
+	principal.Impersonator = actor
+	return principal, nil
+}
+
+// completeLogin finishes a login whose first factor was accepted, starting an
+// MFA challenge for users with a TOTP authenticator or passkey and a session
+// for everyone else
+func (s *authService) completeLogin(user *domain.User, method string, request domain.RequestMeta) (*domain.LoginResult, error) {
+	methods, err := s.mfaMethods(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(methods) > 0 {
+		mfaToken, expiresAt, err := s.mfa.StartChallenge(user.ID)
+		if err != nil {
+			return nil, err
+		}
+		return &domain.LoginResult{MFARequired: true, MFAToken: mfaToken, MFAExpiresAt: expiresAt, MFAMethods: methods}, nil
+	}
+
+	if err := s.throttle.RecordSuccess(user.Email); err != nil {
+		log.Printf("Failed to clear failed logins for %s: %v", user.Email, err)
+	}
+
+	accessToken, err := s.startSession(user, false, request)
+	if err != nil {
+		return nil, err
+	}
+	s.recordAuthEvent(domain.AuditLoginSucceeded, user.ID, request, map[string]string{"method": method})
+	return &domain.LoginResult{Token: accessToken}, nil
+}
+
+// mfaMethods lists the second factors the user set up
+func (s *authService) mfaMethods(userID uint) ([]string, error) {
+	var methods []string
+	totpEnabled, err := s.mfa.Enabled(userID)
+	if err != nil {
+		return nil, err
+	}
+	if totpEnabled {
+		methods = append(methods, domain.MFAMethodTOTP)
+	}
+	hasPasskeys, err := s.passkeys.HasCredentials(userID)
+	if err != nil {
+		return nil, err
+	}
+	if hasPasskeys {
+		methods = append(methods, domain.MFAMethodWebAuthn)
+	}
+	return methods, nil
+}
+
+// startSession records a new session for the device the request came from and
+// issues the first access and refresh token of its token family
+func (s *authService) startSession(user *domain.User, mfa bool, request domain.RequestMeta) (*domain.AccessToken, error) {
+	familyID, err := token.NewOpaque()
+	if err != nil {
+		return nil, errors.InternalServerError(err)
+	}
+
+	now := s.now()
+	device := useragent.Parse(request.UserAgent)
+	err = s.sessions.Create(&domain.Session{
+		ID:         familyID,
+		UserID:     user.ID,
+		UserAgent:  request.UserAgent,
+		Browser:    device.Browser,
+		OS:         device.OS,
+		DeviceType: device.Type,
+		ClientIP:   request.ClientIP,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(s.refreshTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.issue(user, familyID, mfa)
+}
+
+// checkSession rejects access tokens of revoked sessions and records when and
+// from where an active session was last seen
+func (s *authService) checkSession(sessionID string, userID uint, clientIP string) error {
+	session, err := s.sessions.Get(sessionID)
+	if err != nil {
+		return err
+	}
+	now := s.now()
+	if session == nil || session.UserID != userID || session.RevokedAt != nil {
+		return errors.UnauthorizedError("session has been revoked")
+	}
+
+	// Tracking is best effort and must not fail the request
+	if now.Sub(session.LastSeenAt) >= sessionSeenInterval || session.ClientIP != clientIP {
+		session.LastSeenAt = now
+		session.ClientIP = clientIP
+		if err := s.sessions.Touch(session); err != nil {
+			log.Printf("Failed to record activity of a session of user %d: %v", userID, err)
+		}
+	}
+	return nil
+}
+
+// refreshSession extends the session of a rotated refresh token to the
+// lifetime of its successor
+func (s *authService) refreshSession(stored *domain.RefreshToken, request domain.RequestMeta) {
+	// Failures are logged by the repository and must not fail the refresh
+	session, err := s.sessions.Get(stored.FamilyID)
+	if err != nil || session == nil {
+		return
+	}
+
+	now := s.now()
+	session.LastSeenAt = now
+	session.ClientIP = request.ClientIP
+	session.ExpiresAt = now.Add(s.refreshTTL)
+	if err := s.sessions.Touch(session); err != nil {
+		log.Printf("Failed to extend a session of user %d: %v", stored.UserID, err)
+	}
+}
+
+// issue creates an access token for the family's session and a new refresh
+// token in the family. Whether the login passed a second factor is carried
+// over on refresh.
+func (s *authService) issue(user *domain.User, familyID string, mfa bool) (*domain.AccessToken, error) {
+	methods := []string{token.MethodPassword}
+	if mfa {
+		methods = append(methods, token.MethodOTP, token.MethodMFA)
+	}
+	signed, expiresAt, err := s.tokens.Issue(user.ID, user.Email, familyID, methods...)
+	if err != nil {
+		return nil, errors.InternalServerError(err)
+	}
+
+	refreshToken, err := token.NewOpaque()
+	if err != nil {
+		return nil, errors.InternalServerError(err)
+	}
+	err = s.refreshTokens.Create(&domain.RefreshToken{
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		TokenHash: token.HashOpaque(refreshToken),
+		ExpiresAt: s.now().Add(s.refreshTTL),
+		MFA:       mfa,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.AccessToken{
+		AccessToken:  signed,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(s.tokens.TTL().Seconds()),
+		ExpiresAt:    expiresAt,
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+// withoutMFARoles drops the roles that are only granted to sessions which
+// passed a second factor
+func (s *authService) withoutMFARoles(roles []*domain.Role) []*domain.Role {
+	if len(s.mfaRoles) == 0 {
+		return roles
+	}
+	allowed := make([]*domain.Role, 0, len(roles))
+	for _, role := range roles {
+		if !s.mfaRoles[role.Name] {
+			allowed = append(allowed, role)
+		}
+	}
+	return allowed
+}
+
+// lookupUser loads the subject of a token, rejecting tokens of deleted or
+// deactivated users
+func (s *authService) lookupUser(userID uint) (*domain.User, error) {
+	user, err := s.users.Get(domain.SystemPrincipal(), userID)
+	if err != nil {
+		appErr, ok := err.(*errors.AppError)
+		if ok && appErr.Type == errors.NotFound {
+			return nil, errors.UnauthorizedError("user no longer exists")
+		}
+		return nil, err
+	}
+	if !user.IsActive() {
+		return nil, errors.UnauthorizedError("account is deactivated")
+	}
+	return user, nil
+}
+
+// reuseDetected revokes the token family after a rotated token was presented again
+func (s *authService) reuseDetected(stored *domain.RefreshToken, request domain.RequestMeta) error {
+	log.Printf("Refresh token reuse detected for user %d, revoking token family", stored.UserID)
+	if err := s.refreshTokens.RevokeFamily(stored.FamilyID); err != nil {
+		return err
+	}
+
+	event := domain.NewAuditEvent(domain.AuditTokensRevoked, nil, request).ForUser(stored.UserID)
+	event.Details = map[string]string{"reason": "refresh_token_reuse"}
+	s.audit.Record(event)
+	return errors.UnauthorizedError("refresh token reuse detected")
+}
+
+// recordAuthEvent records a completed authentication event, attributed to the user
+func (s *authService) recordAuthEvent(action string, userID uint, request domain.RequestMeta, details map[string]string) {
+	event := domain.NewAuditEvent(action, nil, request).ForUser(userID)
+	event.ActorID = &userID
+	event.Details = details
+	s.audit.Record(event)
+}
+
+// recordLoginFailure records a rejected login attempt for the email, and for
+// the user when the attempt got far enough to identify them
+func (s *authService) recordLoginFailure(userID uint, email, method string, request domain.RequestMeta, err error) {
+	event := domain.NewAuditEvent(domain.AuditLoginFailed, nil, request)
+	if userID != 0 {
+		event.ForUser(userID)
+	}
+	event.Details = map[string]string{"email": email, "method": method, "reason": err.Error()}
+	s.audit.Record(event)
+}
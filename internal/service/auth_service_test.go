@@ -0,0 +1,701 @@
+package service
+
+import (
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/errors"
+	"UserRESTfulApi/pkg/config"
+	"UserRESTfulApi/pkg/mailer"
+	"UserRESTfulApi/pkg/password"
+	"UserRESTfulApi/pkg/token"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Mock refresh token repository for testing
+type mockRefreshTokenRepository struct {
+	tokens   map[uint]*domain.RefreshToken
+	sessions *mockSessionRepository // ended together with their refresh tokens
+}
+
+func newMockRefreshTokenRepository() *mockRefreshTokenRepository {
+	return &mockRefreshTokenRepository{
+		tokens:   make(map[uint]*domain.RefreshToken),
+		sessions: newMockSessionRepository(),
+	}
+}
+
+func (m *mockRefreshTokenRepository) Create(t *domain.RefreshToken) error {
+	t.ID = uint(len(m.tokens) + 1)
+	m.tokens[t.ID] = t
+	return nil
+}
+
+func (m *mockRefreshTokenRepository) GetByHash(tokenHash string) (*domain.RefreshToken, error) {
+	for _, t := range m.tokens {
+		if t.TokenHash == tokenHash {
+			copied := *t
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *mockRefreshTokenRepository) MarkRotated(id uint) (bool, error) {
+	t, exists := m.tokens[id]
+	if !exists || t.RotatedAt != nil || t.RevokedAt != nil {
+		return false, nil
+	}
+	now := time.Now()
+	t.RotatedAt = &now
+	return true, nil
+}
+
+func (m *mockRefreshTokenRepository) RevokeFamily(familyID string) error {
+	now := time.Now()
+	for _, t := range m.tokens {
+		if t.FamilyID == familyID && t.RevokedAt == nil {
+			t.RevokedAt = &now
+		}
+	}
+	if session, exists := m.sessions.sessions[familyID]; exists && session.RevokedAt == nil {
+		session.RevokedAt = &now
+	}
+	return nil
+}
+
+func (m *mockRefreshTokenRepository) RevokeAllForUser(userID uint) error {
+	now := time.Now()
+	for _, t := range m.tokens {
+		if t.UserID == userID && t.RevokedAt == nil {
+			t.RevokedAt = &now
+		}
+	}
+	for _, session := range m.sessions.sessions {
+		if session.UserID == userID && session.RevokedAt == nil {
+			session.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+const testClientIP = "203.0.113.7"
+
+var testRequest = domain.RequestMeta{ClientIP: testClientIP, RequestID: "test-request"}
+
+// newTestAuthService creates an auth service signing HS256 tokens for the test
+// user test@example.com with the password Password123!. Magic links are mailed
+// into a temporary directory.
+func newTestAuthService(t *testing.T, cfg config.AuthConfig) (*authService, *mockUserRepository, *mockAuditRepository) {
+	cfg.JWTAlgorithm = "HS256"
+	cfg.JWTSecret = "test-secret"
+	cfg.JWTIssuer = "test"
+	cfg.AccessTokenTTL = time.Minute
+	cfg.RefreshTokenTTL = time.Hour
+	cfg.MagicLinkTTL = 10 * time.Minute
+	cfg.MagicLinkURL = "https://app.example.com/login/magic"
+	cfg.MagicLinkMaxAttempts = 3
+	cfg.MagicLinkRateLimit = 3
+	cfg = testWebAuthnConfig(cfg)
+	cfg.ImpersonationTTL = 5 * time.Minute
+	cfg = testMFAConfig(cfg)
+	tokens, err := token.NewManager(cfg)
+	if err != nil {
+		t.Fatalf("NewManager() error = %v", err)
+	}
+	mail, err := mailer.NewFileMailer("no-reply@example.com", t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileMailer() error = %v", err)
+	}
+
+	users := newMockUserRepository()
+	hashed, _ := testHasher.Hash("Password123!")
+	users.users[1] = &domain.User{ID: 1, Email: "test@example.com", Password: hashed, Name: "Test User"}
+	auditRepo := newMockAuditRepository()
+	audit := NewAuditService(auditRepo)
+
+	mfa, _, refreshTokens, _ := newTestMFAService()
+	userService := NewUserService(users, testHasher, password.DefaultPolicy(), newMockEmailVerifier(), audit, testAPIConfig)
+	throttle := NewLoginThrottleService(newMockLoginThrottleRepository(), testLockoutConfig)
+	magicLinks := NewMagicLinkService(users, newMockMagicLinkRepository(), mail, audit, cfg)
+	passkeys := NewWebAuthnService(users, newMockWebAuthnRepository(), cfg)
+	service := NewAuthService(userService, newMockRoleRepository(), tokens, refreshTokens, refreshTokens.sessions, throttle, mfa, magicLinks, passkeys, audit, cfg).(*authService)
+	return service, users, auditRepo
+}
+
+// login signs in the test user with its password and returns the issued tokens
+func login(t *testing.T, service domain.AuthService) *domain.AccessToken {
+	t.Helper()
+	result, err := service.Login("test@example.com", "Password123!", testRequest)
+	if err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+	if result.Token == nil {
+		t.Fatalf("Login() = %+v, want tokens", result)
+	}
+	return result.Token
+}
+
+func assertUnauthorized(t *testing.T, err error) {
+	t.Helper()
+	appErr, ok := err.(*errors.AppError)
+	if !ok || appErr.Type != errors.Unauthorized {
+		t.Errorf("error = %v, want Unauthorized", err)
+	}
+}
+
+// grantRole gives the user a role holding the permissions
+func grantRole(service *authService, userID uint, name string, permissions ...domain.Permission) *domain.Role {
+	role, _ := service.roles.GetByName(name)
+	if role == nil {
+		role = &domain.Role{Name: name}
+		for _, permission := range permissions {
+			role.Permissions = append(role.Permissions, domain.RolePermission{Permission: permission})
+		}
+		service.roles.Create(role)
+	}
+	service.roles.AssignToUser(userID, role.ID)
+	return role
+}
+
+func TestLogin(t *testing.T) {
+	tests := []struct {
+		name           string
+		cfg            config.AuthConfig
+		setup          func(t *testing.T, service *authService, users *mockUserRepository)
+		email          string
+		password       string
+		wantErr        errors.ErrorType
+		wantRetryAfter bool
+		wantMFA        bool
+	}{
+		{name: "valid credentials", email: "test@example.com", password: "Password123!"},
+		{name: "wrong password", email: "test@example.com", password: "WrongPassword123!", wantErr: errors.Unauthorized},
+		{name: "unknown email", email: "nobody@example.com", password: "Password123!", wantErr: errors.Unauthorized},
+		{
+			// Even the right password is rejected until the backoff has passed
+			name: "right password during backoff",
+			setup: func(t *testing.T, service *authService, users *mockUserRepository) {
+				_, err := service.Login("test@example.com", "WrongPassword123!", testRequest)
+				assertUnauthorized(t, err)
+			},
+			email:          "test@example.com",
+			password:       "Password123!",
+			wantErr:        errors.TooManyRequests,
+			wantRetryAfter: true,
+		},
+		{
+			name:     "unverified email when verification is required",
+			cfg:      config.AuthConfig{RequireVerifiedEmail: true},
+			email:    "test@example.com",
+			password: "Password123!",
+			wantErr:  errors.Forbidden,
+		},
+		{
+			name: "verified email when verification is required",
+			cfg:  config.AuthConfig{RequireVerifiedEmail: true},
+			setup: func(t *testing.T, service *authService, users *mockUserRepository) {
+				verifiedAt := time.Now()
+				users.users[1].EmailVerifiedAt = &verifiedAt
+			},
+			email:    "test@example.com",
+			password: "Password123!",
+		},
+		{
+			name: "deactivated user",
+			setup: func(t *testing.T, service *authService, users *mockUserRepository) {
+				deactivatedAt := time.Now()
+				users.users[1].DeactivatedAt = &deactivatedAt
+			},
+			email:    "test@example.com",
+			password: "Password123!",
+			wantErr:  errors.Forbidden,
+		},
+		{
+			name: "MFA enabled",
+			setup: func(t *testing.T, service *authService, users *mockUserRepository) {
+				enableMFA(t, service.mfa.(*mfaService), users.users[1])
+			},
+			email:    "test@example.com",
+			password: "Password123!",
+			wantMFA:  true,
+		},
+		{
+			name:     "password and passwordless login",
+			cfg:      config.AuthConfig{LoginMethods: config.LoginMethodBoth},
+			email:    "test@example.com",
+			password: "Password123!",
+		},
+		{
+			name:     "passwordless login only",
+			cfg:      config.AuthConfig{LoginMethods: config.LoginMethodPasswordless},
+			email:    "test@example.com",
+			password: "Password123!",
+			wantErr:  errors.Forbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, users, _ := newTestAuthService(t, tt.cfg)
+			if tt.setup != nil {
+				tt.setup(t, service, users)
+			}
+
+			result, err := service.Login(tt.email, tt.password, testRequest)
+			if tt.wantErr != "" {
+				assertErrorType(t, "Login()", err, tt.wantErr)
+				if appErr, ok := err.(*errors.AppError); ok && (appErr.RetryAfter > 0) != tt.wantRetryAfter {
+					t.Errorf("Login() RetryAfter = %s, want one = %v", appErr.RetryAfter, tt.wantRetryAfter)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Login() error = %v", err)
+			}
+
+			if tt.wantMFA {
+				if !result.MFARequired || result.MFAToken == "" || result.Token != nil {
+					t.Errorf("Login() = %+v, want an MFA challenge and no tokens", result)
+				}
+				return
+			}
+			if result.Token == nil || result.Token.AccessToken == "" || result.Token.RefreshToken == "" {
+				t.Fatalf("Login() = %+v, want access and refresh token", result)
+			}
+			principal, err := service.Authenticate(result.Token.AccessToken, testClientIP)
+			if err != nil || principal.ID() != 1 {
+				t.Errorf("Authenticate() = %v, %v, want user 1", principal, err)
+			}
+		})
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	tests := []struct {
+		name string
+		// setup returns the refresh token to present
+		setup   func(t *testing.T, service *authService, users *mockUserRepository) string
+		wantErr bool
+	}{
+		{
+			name: "issued token",
+			setup: func(t *testing.T, service *authService, users *mockUserRepository) string {
+				return login(t, service).RefreshToken
+			},
+		},
+		{
+			name: "rotated token",
+			setup: func(t *testing.T, service *authService, users *mockUserRepository) string {
+				refreshed, err := service.Refresh(login(t, service).RefreshToken, testRequest)
+				if err != nil {
+					t.Fatalf("Refresh() error = %v", err)
+				}
+				return refreshed.RefreshToken
+			},
+		},
+		{
+			name: "replayed token",
+			setup: func(t *testing.T, service *authService, users *mockUserRepository) string {
+				issued := login(t, service)
+				if _, err := service.Refresh(issued.RefreshToken, testRequest); err != nil {
+					t.Fatalf("Refresh() error = %v", err)
+				}
+				return issued.RefreshToken
+			},
+			wantErr: true,
+		},
+		{
+			// Replaying a token revokes its legitimate successor with the family
+			name: "successor of a replayed token",
+			setup: func(t *testing.T, service *authService, users *mockUserRepository) string {
+				issued := login(t, service)
+				refreshed, err := service.Refresh(issued.RefreshToken, testRequest)
+				if err != nil {
+					t.Fatalf("Refresh() error = %v", err)
+				}
+				_, err = service.Refresh(issued.RefreshToken, testRequest)
+				assertUnauthorized(t, err)
+				return refreshed.RefreshToken
+			},
+			wantErr: true,
+		},
+		{
+			name: "other login after a replay",
+			setup: func(t *testing.T, service *authService, users *mockUserRepository) string {
+				issued := login(t, service)
+				other := login(t, service)
+				service.Refresh(issued.RefreshToken, testRequest)
+				service.Refresh(issued.RefreshToken, testRequest)
+				return other.RefreshToken
+			},
+		},
+		{
+			name: "expired token",
+			setup: func(t *testing.T, service *authService, users *mockUserRepository) string {
+				issued := login(t, service)
+				for _, stored := range service.refreshTokens.(*mockRefreshTokenRepository).tokens {
+					stored.ExpiresAt = time.Now().Add(-time.Second)
+				}
+				return issued.RefreshToken
+			},
+			wantErr: true,
+		},
+		{
+			name: "logged out token",
+			setup: func(t *testing.T, service *authService, users *mockUserRepository) string {
+				issued := login(t, service)
+				if err := service.Logout(issued.RefreshToken, testRequest); err != nil {
+					t.Fatalf("Logout() error = %v", err)
+				}
+				return issued.RefreshToken
+			},
+			wantErr: true,
+		},
+		{
+			name: "token of another session after a logout",
+			setup: func(t *testing.T, service *authService, users *mockUserRepository) string {
+				first, second := login(t, service), login(t, service)
+				if err := service.Logout(first.RefreshToken, testRequest); err != nil {
+					t.Fatalf("Logout() error = %v", err)
+				}
+				return second.RefreshToken
+			},
+		},
+		{
+			name: "token after logging out everywhere",
+			setup: func(t *testing.T, service *authService, users *mockUserRepository) string {
+				issued := login(t, service)
+				if err := service.LogoutAll(domain.NewPrincipal(&domain.User{ID: 1}, nil)); err != nil {
+					t.Fatalf("LogoutAll() error = %v", err)
+				}
+				return issued.RefreshToken
+			},
+			wantErr: true,
+		},
+		{
+			name: "deactivated user",
+			setup: func(t *testing.T, service *authService, users *mockUserRepository) string {
+				issued := login(t, service)
+				deactivatedAt := time.Now()
+				users.users[1].DeactivatedAt = &deactivatedAt
+				return issued.RefreshToken
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown token",
+			setup: func(t *testing.T, service *authService, users *mockUserRepository) string {
+				return "unknown"
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, users, _ := newTestAuthService(t, config.AuthConfig{})
+			refreshToken := tt.setup(t, service, users)
+
+			refreshed, err := service.Refresh(refreshToken, testRequest)
+			if tt.wantErr {
+				assertUnauthorized(t, err)
+				return
+			}
+			if err != nil {
+				t.Fatalf("Refresh() error = %v", err)
+			}
+
+			if refreshed.RefreshToken == "" || refreshed.RefreshToken == refreshToken {
+				t.Errorf("Refresh() = %+v, want a new refresh token", refreshed)
+			}
+			if _, err := service.Authenticate(refreshed.AccessToken, testClientIP); err != nil {
+				t.Errorf("Authenticate() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	tests := []struct {
+		name string
+		// setup returns the access token to present
+		setup     func(t *testing.T, service *authService, users *mockUserRepository) string
+		wantErr   bool
+		wantAdmin bool
+	}{
+		{
+			name: "access token",
+			setup: func(t *testing.T, service *authService, users *mockUserRepository) string {
+				return login(t, service).AccessToken
+			},
+		},
+		{
+			name: "malformed token",
+			setup: func(t *testing.T, service *authService, users *mockUserRepository) string {
+				return "not-a-token"
+			},
+			wantErr: true,
+		},
+		{
+			name: "deactivated user",
+			setup: func(t *testing.T, service *authService, users *mockUserRepository) string {
+				issued := login(t, service)
+				deactivatedAt := time.Now()
+				users.users[1].DeactivatedAt = &deactivatedAt
+				return issued.AccessToken
+			},
+			wantErr: true,
+		},
+		{
+			// Roles requiring MFA are withheld from password-only sessions
+			name: "admin without MFA",
+			setup: func(t *testing.T, service *authService, users *mockUserRepository) string {
+				grantRole(service, 1, domain.RoleAdmin, domain.PermissionUsersList)
+				return login(t, service).AccessToken
+			},
+		},
+		{
+			name: "admin after MFA",
+			setup: func(t *testing.T, service *authService, users *mockUserRepository) string {
+				grantRole(service, 1, domain.RoleAdmin, domain.PermissionUsersList)
+				mfa := service.mfa.(*mfaService)
+				secret, _ := enableMFA(t, mfa, users.users[1])
+				result, err := service.Login("test@example.com", "Password123!", testRequest)
+				if err != nil {
+					t.Fatalf("Login() error = %v", err)
+				}
+				issued, err := service.VerifyMFA(result.MFAToken, mfaCode(t, mfa, secret), testRequest)
+				if err != nil {
+					t.Fatalf("VerifyMFA() error = %v", err)
+				}
+				return issued.AccessToken
+			},
+			wantAdmin: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, users, _ := newTestAuthService(t, config.AuthConfig{})
+			accessToken := tt.setup(t, service, users)
+
+			principal, err := service.Authenticate(accessToken, testClientIP)
+			if tt.wantErr {
+				assertUnauthorized(t, err)
+				return
+			}
+			if err != nil || principal.ID() != 1 {
+				t.Fatalf("Authenticate() = %v, %v, want user 1", principal, err)
+			}
+			if principal.HasRole(domain.RoleAdmin) != tt.wantAdmin || principal.Can(domain.PermissionUsersList) != tt.wantAdmin {
+				t.Errorf("Authenticate() admin = %v, want %v", principal.HasRole(domain.RoleAdmin), tt.wantAdmin)
+			}
+		})
+	}
+}
+
+func TestVerifyMFA(t *testing.T) {
+	tests := []struct {
+		name      string
+		wrongCode bool
+		mfaToken  string
+		reuse     bool
+		wantErr   bool
+	}{
+		{name: "TOTP code"},
+		{name: "wrong code", wrongCode: true, wantErr: true},
+		{name: "unknown MFA token", mfaToken: "unknown", wantErr: true},
+		{name: "used MFA token", reuse: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, users, _ := newTestAuthService(t, config.AuthConfig{})
+			mfa := service.mfa.(*mfaService)
+			secret, _ := enableMFA(t, mfa, users.users[1])
+			result, err := service.Login("test@example.com", "Password123!", testRequest)
+			if err != nil {
+				t.Fatalf("Login() error = %v", err)
+			}
+
+			mfaToken, code := result.MFAToken, mfaCode(t, mfa, secret)
+			if tt.reuse {
+				if _, err := service.VerifyMFA(mfaToken, code, testRequest); err != nil {
+					t.Fatalf("VerifyMFA() error = %v", err)
+				}
+			}
+			if tt.wrongCode {
+				code = "000000"
+			}
+			if tt.mfaToken != "" {
+				mfaToken = tt.mfaToken
+			}
+
+			issued, err := service.VerifyMFA(mfaToken, code, testRequest)
+			if tt.wantErr {
+				assertUnauthorized(t, err)
+				return
+			}
+			if err != nil {
+				t.Fatalf("VerifyMFA() error = %v", err)
+			}
+
+			// Refreshing keeps the second factor of the login
+			refreshed, err := service.Refresh(issued.RefreshToken, testRequest)
+			if err != nil {
+				t.Fatalf("Refresh() error = %v", err)
+			}
+			verifier, _ := token.NewManager(config.AuthConfig{JWTAlgorithm: "HS256", JWTSecret: "test-secret", JWTIssuer: "test"})
+			parsed, err := verifier.Parse(refreshed.AccessToken)
+			if err != nil || !parsed.HasMethod(token.MethodMFA) {
+				t.Errorf("refreshed access token amr = %v, %v, want mfa", parsed, err)
+			}
+		})
+	}
+}
+
+// impersonationActor signs in support@example.com, who may impersonate users
+func impersonationActor(t *testing.T, service *authService, users *mockUserRepository) *domain.Principal {
+	t.Helper()
+	hashed, _ := testHasher.Hash("Password123!")
+	users.users[2] = &domain.User{ID: 2, Email: "support@example.com", Password: hashed, Name: "Support"}
+	users.users[3] = &domain.User{ID: 3, Email: "support2@example.com", Password: hashed, Name: "Support Two"}
+	grantRole(service, 2, "support", domain.PermissionUsersImpersonate)
+	grantRole(service, 3, "support")
+
+	result, err := service.Login("support@example.com", "Password123!", testRequest)
+	if err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+	actor, err := service.Authenticate(result.Token.AccessToken, testClientIP)
+	if err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	actor.Request = testRequest
+	return actor
+}
+
+func TestImpersonate(t *testing.T) {
+	tests := []struct {
+		name       string
+		userID     uint
+		reason     string
+		deactivate bool
+		nested     bool
+		wantErr    errors.ErrorType
+	}{
+		{name: "user", userID: 1, reason: "ticket 42"},
+		{name: "no reason", userID: 1, reason: " ", wantErr: errors.InvalidInput},
+		{name: "reason too long", userID: 1, reason: strings.Repeat("x", maxImpersonationReasonLength+1), wantErr: errors.InvalidInput},
+		{name: "self", userID: 2, reason: "testing", wantErr: errors.InvalidInput},
+		{name: "another impersonator", userID: 3, reason: "testing", wantErr: errors.Forbidden},
+		{name: "unknown user", userID: 99, reason: "testing", wantErr: errors.NotFound},
+		{name: "deactivated user", userID: 1, reason: "testing", deactivate: true, wantErr: errors.Forbidden},
+		// Impersonation tokens cannot start further impersonations
+		{name: "while impersonating", userID: 3, reason: "testing", nested: true, wantErr: errors.Forbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, users, audit := newTestAuthService(t, config.AuthConfig{})
+			actor := impersonationActor(t, service, users)
+			if tt.nested {
+				issued, err := service.Impersonate(actor, 1, "ticket 42")
+				if err != nil {
+					t.Fatalf("Impersonate() error = %v", err)
+				}
+				actor = authenticate(t, service, issued.AccessToken)
+			}
+			if tt.deactivate {
+				deactivatedAt := time.Now()
+				users.users[tt.userID].DeactivatedAt = &deactivatedAt
+			}
+			events := len(audit.events)
+
+			issued, err := service.Impersonate(actor, tt.userID, tt.reason)
+			if tt.wantErr != "" {
+				assertErrorType(t, "Impersonate()", err, tt.wantErr)
+				if len(audit.events) != events {
+					t.Errorf("failed Impersonate() recorded %v", audit.actions()[events:])
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Impersonate() error = %v", err)
+			}
+
+			if issued.RefreshToken != "" || issued.ExpiresIn != 300 {
+				t.Errorf("Impersonate() = %+v, want a 5 minute access token without refresh token", issued)
+			}
+			impersonated := authenticate(t, service, issued.AccessToken)
+			if impersonated.ID() != tt.userID || !impersonated.Impersonated() || impersonated.Impersonator.ID != 2 {
+				t.Fatalf("Authenticate(impersonation) = %+v, want user %d impersonated by user 2", impersonated, tt.userID)
+			}
+
+			started := audit.events[len(audit.events)-1]
+			if started.Action != domain.AuditImpersonationStarted || *started.ActorID != 2 || started.TargetID != "1" || started.Details["reason"] != tt.reason {
+				t.Errorf("audit event = %+v, want impersonation of user 1 by user 2", started)
+			}
+			if event := domain.NewAuditEvent(domain.AuditUserUpdated, impersonated, testRequest); *event.ActorID != 1 || event.ImpersonatorID == nil || *event.ImpersonatorID != 2 {
+				t.Errorf("NewAuditEvent() = %+v, want actor 1 and impersonator 2", event)
+			}
+		})
+	}
+}
+
+func TestImpersonationEnds(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  func(t *testing.T, service *authService, actor *domain.Principal, support *domain.Role)
+		wantErr bool
+	}{
+		{
+			name:   "actor keeps the permission",
+			change: func(t *testing.T, service *authService, actor *domain.Principal, support *domain.Role) {},
+		},
+		{
+			name: "actor loses the permission",
+			change: func(t *testing.T, service *authService, actor *domain.Principal, support *domain.Role) {
+				service.roles.RemoveFromUser(2, support.ID)
+			},
+			wantErr: true,
+		},
+		{
+			name: "actor regains the permission",
+			change: func(t *testing.T, service *authService, actor *domain.Principal, support *domain.Role) {
+				service.roles.RemoveFromUser(2, support.ID)
+				service.roles.AssignToUser(2, support.ID)
+			},
+		},
+		{
+			name: "actor signs out everywhere",
+			change: func(t *testing.T, service *authService, actor *domain.Principal, support *domain.Role) {
+				if err := service.LogoutAll(actor); err != nil {
+					t.Fatalf("LogoutAll() error = %v", err)
+				}
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, users, _ := newTestAuthService(t, config.AuthConfig{})
+			actor := impersonationActor(t, service, users)
+			issued, err := service.Impersonate(actor, 1, "ticket 42")
+			if err != nil {
+				t.Fatalf("Impersonate() error = %v", err)
+			}
+
+			support, _ := service.roles.GetByName("support")
+			tt.change(t, service, actor, support)
+			_, err = service.Authenticate(issued.AccessToken, testClientIP)
+			if tt.wantErr {
+				assertUnauthorized(t, err)
+			} else if err != nil {
+				t.Errorf("Authenticate(impersonation) error = %v", err)
+			}
+		})
+	}
+}
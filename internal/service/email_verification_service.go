@@ -0,0 +1,391 @@
+package service
+
+import (
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/errors"
+	"UserRESTfulApi/pkg/config"
+	"UserRESTfulApi/pkg/mailer"
+	"UserRESTfulApi/pkg/token"
+	"fmt"
+	"log"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// emailVerificationCooldown is the minimum time between two resent verification emails
+const emailVerificationCooldown = time.Minute
+
+// maxUserUpdateAttempts bounds how often the change of a redeemed token is
+// applied to a reloaded user after losing a race with another write
+const maxUserUpdateAttempts = 5
+
+type emailVerificationService struct {
+	users         domain.UserRepository
+	tokens        domain.EmailVerificationRepository
+	changes       domain.EmailChangeRepository
+	refreshTokens domain.RefreshTokenRepository
+	mailer        mailer.Mailer
+	audit         domain.AuditService
+	ttl           time.Duration
+	verifyURL     string
+	revertTTL     time.Duration
+	revertURL     string
+	now           func() time.Time
+}
+
+// NewEmailVerificationService creates a new email verification service
+func NewEmailVerificationService(users domain.UserRepository, verificationTokens domain.EmailVerificationRepository, changes domain.EmailChangeRepository, refreshTokens domain.RefreshTokenRepository, m mailer.Mailer, audit domain.AuditService, cfg config.AuthConfig) domain.EmailVerificationService {
+	return &emailVerificationService{
+		users:         users,
+		tokens:        verificationTokens,
+		changes:       changes,
+		refreshTokens: refreshTokens,
+		mailer:        m,
+		audit:         audit,
+		ttl:           cfg.EmailVerificationTTL,
+		verifyURL:     cfg.EmailVerificationURL,
+		revertTTL:     cfg.EmailChangeRevertTTL,
+		revertURL:     cfg.EmailChangeRevertURL,
+		now:           time.Now,
+	}
+}
+
+// Send emails a single-use verification link for the address to the user.
+// Delivery failures are logged; the user can ask for the link to be resent.
+func (s *emailVerificationService) Send(user *domain.User, email string) error {
+	if err := s.tokens.InvalidateForUser(user.ID); err != nil {
+		return err
+	}
+
+	verificationToken, err := token.NewOpaque()
+	if err != nil {
+		return errors.InternalServerError(err)
+	}
+	expiresAt := s.now().Add(s.ttl)
+	err = s.tokens.Create(&domain.EmailVerificationToken{
+		UserID:    user.ID,
+		Email:     email,
+		TokenHash: token.HashOpaque(verificationToken),
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	link, err := tokenLink(s.verifyURL, verificationToken)
+	if err != nil {
+		return errors.InternalServerError(err)
+	}
+	err = s.mailer.Send(mailer.Message{
+		To:      email,
+		Subject: "Confirm your email address",
+		Body: fmt.Sprintf("Hello %s,\n\n"+
+			"Please confirm that %s is your email address by opening the link below. "+
+			"It can be used once and expires at %s.\n\n"+
+			"%s\n\n"+
+			"If you did not create an account or change your email address you can ignore this email.\n",
+			user.Name, email, expiresAt.UTC().Format(time.RFC1123), link),
+	})
+	if err != nil {
+		log.Printf("Failed to send verification email to user %d: %v", user.ID, err)
+	}
+	return nil
+}
+
+// Verify confirms the address the token was sent to
+func (s *emailVerificationService) Verify(verificationToken string) (*domain.User, error) {
+	invalidToken := errors.InvalidInputError("token", "verification token is invalid or has expired")
+
+	stored, err := s.tokens.GetByHash(token.HashOpaque(verificationToken))
+	if err != nil {
+		return nil, err
+	}
+	if stored == nil || stored.UsedAt != nil || !s.now().Before(stored.ExpiresAt) {
+		return nil, invalidToken
+	}
+
+	user, err := s.users.Get(stored.UserID)
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok && appErr.Type == errors.NotFound {
+			return nil, invalidToken
+		}
+		return nil, err
+	}
+	if user == nil {
+		return nil, invalidToken
+	}
+
+	var change *domain.EmailChange
+	if stored.Email == user.Email {
+		if user.IsEmailVerified() {
+			return nil, invalidToken
+		}
+	} else {
+		// A new address is only applied while the change reserving it is
+		// pending, so a reverted or superseded change cannot be confirmed
+		change, err = s.changes.GetPending(user.ID)
+		if err != nil {
+			return nil, err
+		}
+		if change == nil || change.NewEmail != stored.Email || !s.now().Before(change.ExpiresAt) {
+			return nil, invalidToken
+		}
+		existing, err := s.users.GetByEmail(stored.Email)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil && existing.ID != user.ID {
+			return nil, errors.DuplicateEmailError(stored.Email)
+		}
+	}
+
+	used, err := s.tokens.MarkUsed(stored.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !used {
+		return nil, invalidToken
+	}
+
+	if change != nil {
+		confirmed, err := s.changes.MarkConfirmed(change.ID)
+		if err != nil {
+			return nil, err
+		}
+		if !confirmed {
+			// The change was reverted between our read and update
+			return nil, invalidToken
+		}
+	}
+
+	now := s.now()
+	err = s.updateUser(user, func(user *domain.User) {
+		if change != nil {
+			user.Email = change.NewEmail
+			if user.PendingEmail == change.NewEmail {
+				user.PendingEmail = ""
+			}
+		}
+		user.EmailVerifiedAt = &now
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if change != nil {
+		event := domain.NewAuditEvent(domain.AuditEmailChangeConfirmed, nil, domain.RequestMeta{}).ForUser(user.ID)
+		event.Changes = map[string]domain.AuditChange{"email": {Old: change.OldEmail, New: change.NewEmail}}
+		s.audit.Record(event)
+	}
+
+	log.Printf("Email address of user %d verified", user.ID)
+	return user, nil
+}
+
+// RequestChange reserves the new address for the user, then emails it a
+// verification link and emails the current address a link that reverts the
+// change. Delivery failures are logged; the verification link can be resent.
+func (s *emailVerificationService) RequestChange(user *domain.User, newEmail string) error {
+	revertToken, err := token.NewOpaque()
+	if err != nil {
+		return errors.InternalServerError(err)
+	}
+	now := s.now()
+	change := &domain.EmailChange{
+		UserID:          user.ID,
+		OldEmail:        user.Email,
+		NewEmail:        newEmail,
+		RevertTokenHash: token.HashOpaque(revertToken),
+		ExpiresAt:       now.Add(s.ttl),
+		RevertExpiresAt: now.Add(s.ttl + s.revertTTL),
+	}
+	reserved, err := s.changes.Reserve(change)
+	if err != nil {
+		return err
+	}
+	if !reserved {
+		return errors.DuplicateEmailError(newEmail)
+	}
+
+	if err := s.Send(user, newEmail); err != nil {
+		return err
+	}
+
+	link, err := tokenLink(s.revertURL, revertToken)
+	if err != nil {
+		return errors.InternalServerError(err)
+	}
+	err = s.mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Your email address is being changed",
+		Body: fmt.Sprintf("Hello %s,\n\n"+
+			"A change of your account's email address from %s to %s was requested. "+
+			"The new address replaces this one once it is confirmed.\n\n"+
+			"If you did not ask for this, open the link below and confirm to cancel the change, or to "+
+			"undo it if it was already confirmed, and sign out every session. It can be used once and expires at %s.\n\n"+
+			"%s\n",
+			user.Name, user.Email, newEmail, change.RevertExpiresAt.UTC().Format(time.RFC1123), link),
+	})
+	if err != nil {
+		log.Printf("Failed to send email change notice to user %d: %v", user.ID, err)
+	}
+	return nil
+}
+
+// Revert cancels the change the revert token was sent for. A confirmed change
+// is undone by restoring and verifying the old address. Either way the
+// verification links and sessions of the user are revoked, as the change was
+// presumably requested by someone else.
+func (s *emailVerificationService) Revert(revertToken string, request domain.RequestMeta) (*domain.User, error) {
+	invalidToken := errors.InvalidInputError("token", "revert token is invalid or has expired")
+
+	change, err := s.changes.GetByRevertHash(token.HashOpaque(revertToken))
+	if err != nil {
+		return nil, err
+	}
+	if change == nil || change.RevertedAt != nil || change.CancelledAt != nil || !s.now().Before(change.RevertExpiresAt) {
+		return nil, invalidToken
+	}
+
+	user, err := s.users.Get(change.UserID)
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok && appErr.Type == errors.NotFound {
+			return nil, invalidToken
+		}
+		return nil, err
+	}
+	if user == nil {
+		return nil, invalidToken
+	}
+
+	if change.ConfirmedAt != nil {
+		// Reserved while the change can be reverted, unless an administrator assigned it meanwhile
+		existing, err := s.users.GetByEmail(change.OldEmail)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil && existing.ID != user.ID {
+			return nil, errors.DuplicateEmailError(change.OldEmail)
+		}
+	}
+
+	reverted, err := s.changes.MarkReverted(change.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !reverted {
+		return nil, invalidToken
+	}
+	// The change may have been confirmed between our read and update
+	if change.ConfirmedAt == nil {
+		reloaded, err := s.changes.GetByRevertHash(change.RevertTokenHash)
+		if err != nil {
+			return nil, err
+		}
+		if reloaded != nil {
+			change = reloaded
+		}
+	}
+	confirmed := change.ConfirmedAt != nil
+
+	// Later changes made with the same access are dropped as well
+	if err := s.changes.CancelPending(user.ID); err != nil {
+		return nil, err
+	}
+	var previous string
+	now := s.now()
+	err = s.updateUser(user, func(user *domain.User) {
+		previous = user.Email
+		user.PendingEmail = ""
+		if confirmed {
+			user.Email = change.OldEmail
+			user.EmailVerifiedAt = &now
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.tokens.InvalidateForUser(user.ID); err != nil {
+		return nil, err
+	}
+	if err := s.refreshTokens.RevokeAllForUser(user.ID); err != nil {
+		return nil, err
+	}
+
+	event := domain.NewAuditEvent(domain.AuditEmailChangeReverted, nil, request).ForUser(user.ID)
+	event.Changes = map[string]domain.AuditChange{"email": {Old: previous, New: user.Email}}
+	event.Details = map[string]string{"requested_email": change.NewEmail, "confirmed": strconv.FormatBool(confirmed)}
+	s.audit.Record(event)
+	revoked := domain.NewAuditEvent(domain.AuditTokensRevoked, nil, request).ForUser(user.ID)
+	revoked.Details = map[string]string{"reason": "email_change_reverted"}
+	s.audit.Record(revoked)
+
+	log.Printf("Email change %d of user %d reverted, all sessions revoked", change.ID, user.ID)
+	return user, nil
+}
+
+// updateUser applies a redeemed token's change to the user and stores it.
+// The token is spent before the update, so losing the update's version check
+// to a concurrent write must not drop the change: the user is reloaded and
+// the change applied again, keeping what the other write stored.
+func (s *emailVerificationService) updateUser(user *domain.User, apply func(*domain.User)) error {
+	for attempt := 1; ; attempt++ {
+		apply(user)
+		err := s.users.Update(user)
+		appErr, ok := err.(*errors.AppError)
+		if !ok || appErr.Type != errors.PreconditionFailed || attempt == maxUserUpdateAttempts {
+			return err
+		}
+
+		reloaded, err := s.users.Get(user.ID)
+		if err != nil {
+			return err
+		}
+		if reloaded == nil {
+			return errors.NotFoundError("user", user.ID)
+		}
+		*user = *reloaded
+	}
+}
+
+// EmailReserved reports whether another user's email change holds the address
+func (s *emailVerificationService) EmailReserved(email string, userID uint) (bool, error) {
+	return s.changes.Reserved(email, userID)
+}
+
+// Resend sends a new verification link to the address of the user that still needs confirming
+func (s *emailVerificationService) Resend(email string) error {
+	email = strings.TrimSpace(email)
+	if _, err := mail.ParseAddress(email); err != nil {
+		return errors.InvalidEmailError(email)
+	}
+
+	user, err := s.users.GetByEmail(email)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return nil
+	}
+
+	if user.PendingEmail == "" && user.IsEmailVerified() {
+		return nil
+	}
+
+	latest, err := s.tokens.GetLatestForUser(user.ID)
+	if err != nil {
+		return err
+	}
+	if latest != nil && s.now().Sub(latest.CreatedAt) < emailVerificationCooldown {
+		log.Printf("Verification email for user %d requested again within %s, not sending another email", user.ID, emailVerificationCooldown)
+		return nil
+	}
+
+	if user.PendingEmail != "" {
+		// Renews the reservation of the address, which lapses with the first link
+		return s.RequestChange(user, user.PendingEmail)
+	}
+	return s.Send(user, user.Email)
+}
@@ -0,0 +1,664 @@
+package service
+
+import (
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/errors"
+	"UserRESTfulApi/pkg/config"
+	"UserRESTfulApi/pkg/mailer"
+	"UserRESTfulApi/pkg/password"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Mock email verification service that sends nothing
+type mockEmailVerifier struct {
+	requestChangeErr error
+}
+
+func newMockEmailVerifier() *mockEmailVerifier {
+	return &mockEmailVerifier{}
+}
+
+func (m *mockEmailVerifier) Send(user *domain.User, email string) error {
+	return nil
+}
+
+func (m *mockEmailVerifier) Verify(verificationToken string) (*domain.User, error) {
+	return nil, errors.InvalidInputError("token", "not supported by the mock")
+}
+
+func (m *mockEmailVerifier) Resend(email string) error {
+	return nil
+}
+
+func (m *mockEmailVerifier) RequestChange(user *domain.User, newEmail string) error {
+	return m.requestChangeErr
+}
+
+func (m *mockEmailVerifier) Revert(revertToken string, request domain.RequestMeta) (*domain.User, error) {
+	return nil, errors.InvalidInputError("token", "not supported by the mock")
+}
+
+func (m *mockEmailVerifier) EmailReserved(email string, userID uint) (bool, error) {
+	return false, nil
+}
+
+// Mock email verification token repository for testing
+type mockEmailVerificationRepository struct {
+	tokens map[uint]*domain.EmailVerificationToken
+}
+
+func newMockEmailVerificationRepository() *mockEmailVerificationRepository {
+	return &mockEmailVerificationRepository{
+		tokens: make(map[uint]*domain.EmailVerificationToken),
+	}
+}
+
+func (m *mockEmailVerificationRepository) Create(t *domain.EmailVerificationToken) error {
+	t.ID = uint(len(m.tokens) + 1)
+	t.CreatedAt = time.Now()
+	m.tokens[t.ID] = t
+	return nil
+}
+
+func (m *mockEmailVerificationRepository) GetByHash(tokenHash string) (*domain.EmailVerificationToken, error) {
+	for _, t := range m.tokens {
+		if t.TokenHash == tokenHash {
+			copied := *t
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *mockEmailVerificationRepository) GetLatestForUser(userID uint) (*domain.EmailVerificationToken, error) {
+	var latest *domain.EmailVerificationToken
+	for _, t := range m.tokens {
+		if t.UserID == userID && (latest == nil || t.ID > latest.ID) {
+			latest = t
+		}
+	}
+	return latest, nil
+}
+
+func (m *mockEmailVerificationRepository) MarkUsed(id uint) (bool, error) {
+	t, exists := m.tokens[id]
+	if !exists || t.UsedAt != nil {
+		return false, nil
+	}
+	now := time.Now()
+	t.UsedAt = &now
+	return true, nil
+}
+
+func (m *mockEmailVerificationRepository) InvalidateForUser(userID uint) error {
+	now := time.Now()
+	for _, t := range m.tokens {
+		if t.UserID == userID && t.UsedAt == nil {
+			t.UsedAt = &now
+		}
+	}
+	return nil
+}
+
+// Mock email change repository for testing
+type mockEmailChangeRepository struct {
+	changes map[uint]*domain.EmailChange
+	users   *mockUserRepository
+}
+
+func newMockEmailChangeRepository(users *mockUserRepository) *mockEmailChangeRepository {
+	return &mockEmailChangeRepository{
+		changes: make(map[uint]*domain.EmailChange),
+		users:   users,
+	}
+}
+
+func (m *mockEmailChangeRepository) Reserve(change *domain.EmailChange) (bool, error) {
+	for _, u := range m.users.users {
+		if u.Email == change.NewEmail && u.ID != change.UserID {
+			return false, nil
+		}
+	}
+	if reserved, _ := m.Reserved(change.NewEmail, change.UserID); reserved {
+		return false, nil
+	}
+	m.CancelPending(change.UserID)
+	change.ID = uint(len(m.changes) + 1)
+	change.CreatedAt = time.Now()
+	m.changes[change.ID] = change
+	return true, nil
+}
+
+func (m *mockEmailChangeRepository) Reserved(email string, userID uint) (bool, error) {
+	now := time.Now()
+	for _, c := range m.changes {
+		if c.UserID == userID || c.RevertedAt != nil {
+			continue
+		}
+		if c.NewEmail == email && c.ConfirmedAt == nil && c.CancelledAt == nil && now.Before(c.ExpiresAt) {
+			return true, nil
+		}
+		if c.OldEmail == email && c.ConfirmedAt != nil && now.Before(c.RevertExpiresAt) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *mockEmailChangeRepository) GetPending(userID uint) (*domain.EmailChange, error) {
+	for _, c := range m.changes {
+		if c.UserID == userID && c.ConfirmedAt == nil && c.RevertedAt == nil && c.CancelledAt == nil {
+			copied := *c
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *mockEmailChangeRepository) GetByRevertHash(tokenHash string) (*domain.EmailChange, error) {
+	for _, c := range m.changes {
+		if c.RevertTokenHash == tokenHash {
+			copied := *c
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *mockEmailChangeRepository) MarkConfirmed(id uint) (bool, error) {
+	c, exists := m.changes[id]
+	if !exists || c.ConfirmedAt != nil || c.RevertedAt != nil || c.CancelledAt != nil {
+		return false, nil
+	}
+	now := time.Now()
+	c.ConfirmedAt = &now
+	return true, nil
+}
+
+func (m *mockEmailChangeRepository) MarkReverted(id uint) (bool, error) {
+	c, exists := m.changes[id]
+	if !exists || c.RevertedAt != nil || c.CancelledAt != nil {
+		return false, nil
+	}
+	now := time.Now()
+	c.RevertedAt = &now
+	return true, nil
+}
+
+func (m *mockEmailChangeRepository) CancelPending(userID uint) error {
+	now := time.Now()
+	for _, c := range m.changes {
+		if c.UserID == userID && c.ConfirmedAt == nil && c.RevertedAt == nil && c.CancelledAt == nil {
+			c.CancelledAt = &now
+		}
+	}
+	return nil
+}
+
+// racingUserRepository stores a write of another client before the next
+// updates, making their version check fail
+type racingUserRepository struct {
+	*mockUserRepository
+	conflicts int
+}
+
+func (r *racingUserRepository) Update(user *domain.User) error {
+	r.race(user.ID)
+	return r.mockUserRepository.Update(user)
+}
+
+func (r *racingUserRepository) UpdateColumns(user *domain.User, columns ...string) error {
+	r.race(user.ID)
+	return r.mockUserRepository.UpdateColumns(user, columns...)
+}
+
+func (r *racingUserRepository) race(id uint) {
+	stored, exists := r.users[id]
+	if r.conflicts == 0 || !exists {
+		return
+	}
+	r.conflicts--
+	concurrent := *stored
+	concurrent.Name = "Renamed Meanwhile"
+	concurrent.Version++
+	r.users[id] = &concurrent
+}
+
+// newTestEmailVerificationService creates an email verification service and a
+// user service sharing its repositories, mailing into a temporary directory
+func newTestEmailVerificationService(t *testing.T, repo *mockUserRepository, refreshTokens *mockRefreshTokenRepository, audit *mockAuditRepository) (*emailVerificationService, domain.UserService, *mailer.FileMailer) {
+	mail, err := mailer.NewFileMailer("no-reply@example.com", t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileMailer() error = %v", err)
+	}
+
+	cfg := config.AuthConfig{
+		EmailVerificationTTL: time.Hour,
+		EmailVerificationURL: "https://app.example.com/verify",
+		EmailChangeRevertTTL: 24 * time.Hour,
+		EmailChangeRevertURL: "https://app.example.com/revert",
+	}
+	auditService := NewAuditService(audit)
+	verifier := NewEmailVerificationService(repo, newMockEmailVerificationRepository(), newMockEmailChangeRepository(repo), refreshTokens, mail, auditService, cfg).(*emailVerificationService)
+	return verifier, NewUserService(repo, testHasher, password.DefaultPolicy(), verifier, auditService, testAPIConfig), mail
+}
+
+// changeEmail requests a change of the user's email address
+func changeEmail(t *testing.T, users domain.UserService, id uint, email string) {
+	t.Helper()
+	if err := users.Update(domain.SystemPrincipal(), &domain.User{ID: id, Email: email, Name: "Test User"}); err != nil {
+		t.Fatalf("Update(%s) error = %v", email, err)
+	}
+}
+
+func TestVerifyEmail(t *testing.T) {
+	signup := func(t *testing.T, users domain.UserService, mail *mailer.FileMailer) string {
+		if err := users.Create(&domain.User{Email: "new@example.com", Password: "Password123!", Name: "New User"}, testRequest); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		return mailedToken(t, mail, "new@example.com")
+	}
+
+	tests := []struct {
+		name string
+		// setup starts from user 1 at old@example.com and returns the token to verify
+		setup     func(t *testing.T, verifier *emailVerificationService, users domain.UserService, repo *mockUserRepository, mail *mailer.FileMailer) string
+		later     time.Duration
+		conflicts int
+		wantErr   errors.ErrorType
+		wantEmail string
+	}{
+		{
+			name: "signup link",
+			setup: func(t *testing.T, verifier *emailVerificationService, users domain.UserService, repo *mockUserRepository, mail *mailer.FileMailer) string {
+				return signup(t, users, mail)
+			},
+			wantEmail: "new@example.com",
+		},
+		{
+			name: "reused link",
+			setup: func(t *testing.T, verifier *emailVerificationService, users domain.UserService, repo *mockUserRepository, mail *mailer.FileMailer) string {
+				verificationToken := signup(t, users, mail)
+				if _, err := verifier.Verify(verificationToken); err != nil {
+					t.Fatalf("Verify() error = %v", err)
+				}
+				return verificationToken
+			},
+			wantErr: errors.InvalidInput,
+		},
+		{
+			name: "unknown token",
+			setup: func(t *testing.T, verifier *emailVerificationService, users domain.UserService, repo *mockUserRepository, mail *mailer.FileMailer) string {
+				return "unknown"
+			},
+			wantErr: errors.InvalidInput,
+		},
+		{
+			name: "expired link",
+			setup: func(t *testing.T, verifier *emailVerificationService, users domain.UserService, repo *mockUserRepository, mail *mailer.FileMailer) string {
+				return signup(t, users, mail)
+			},
+			later:   2 * time.Hour,
+			wantErr: errors.InvalidInput,
+		},
+		{
+			name: "email change",
+			setup: func(t *testing.T, verifier *emailVerificationService, users domain.UserService, repo *mockUserRepository, mail *mailer.FileMailer) string {
+				changeEmail(t, users, 1, "new@example.com")
+				return mailedToken(t, mail, "new@example.com")
+			},
+			wantEmail: "new@example.com",
+		},
+		{
+			name: "pending address taken meanwhile",
+			setup: func(t *testing.T, verifier *emailVerificationService, users domain.UserService, repo *mockUserRepository, mail *mailer.FileMailer) string {
+				changeEmail(t, users, 1, "new@example.com")
+				repo.users[2] = &domain.User{ID: 2, Email: "new@example.com", Name: "Other User"}
+				return mailedToken(t, mail, "new@example.com")
+			},
+			wantErr: errors.DuplicateEmail,
+		},
+		{
+			name: "change superseded by a later change",
+			setup: func(t *testing.T, verifier *emailVerificationService, users domain.UserService, repo *mockUserRepository, mail *mailer.FileMailer) string {
+				changeEmail(t, users, 1, "new@example.com")
+				verificationToken := mailedToken(t, mail, "new@example.com")
+				changeEmail(t, users, 1, "other@example.com")
+				return verificationToken
+			},
+			wantErr: errors.InvalidInput,
+		},
+		{
+			name: "reverted change",
+			setup: func(t *testing.T, verifier *emailVerificationService, users domain.UserService, repo *mockUserRepository, mail *mailer.FileMailer) string {
+				changeEmail(t, users, 1, "new@example.com")
+				if _, err := verifier.Revert(mailedToken(t, mail, "old@example.com"), testRequest); err != nil {
+					t.Fatalf("Revert() error = %v", err)
+				}
+				return mailedToken(t, mail, "new@example.com")
+			},
+			wantErr: errors.InvalidInput,
+		},
+		{
+			// Another write lands between redeeming the token and updating the user
+			name: "version conflict after redeeming the token",
+			setup: func(t *testing.T, verifier *emailVerificationService, users domain.UserService, repo *mockUserRepository, mail *mailer.FileMailer) string {
+				changeEmail(t, users, 1, "new@example.com")
+				return mailedToken(t, mail, "new@example.com")
+			},
+			conflicts: 2,
+			wantEmail: "new@example.com",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newMockUserRepository()
+			repo.users[1] = &domain.User{ID: 1, Email: "old@example.com", Name: "Test User"}
+			verifier, users, mail := newTestEmailVerificationService(t, repo, newMockRefreshTokenRepository(), newMockAuditRepository())
+
+			verificationToken := tt.setup(t, verifier, users, repo, mail)
+			if tt.later != 0 {
+				verifier.now = func() time.Time { return time.Now().Add(tt.later) }
+			}
+			racing := &racingUserRepository{mockUserRepository: repo, conflicts: tt.conflicts}
+			verifier.users = racing
+
+			user, err := verifier.Verify(verificationToken)
+			if tt.wantErr != "" {
+				assertErrorType(t, "Verify()", err, tt.wantErr)
+				if repo.users[1].Email != "old@example.com" {
+					t.Errorf("failed Verify() changed the email to %q", repo.users[1].Email)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Verify() error = %v", err)
+			}
+
+			stored := repo.users[user.ID]
+			if user.Email != tt.wantEmail || user.PendingEmail != "" || !user.IsEmailVerified() {
+				t.Errorf("Verify() = %+v, want the verified address %s", user, tt.wantEmail)
+			}
+			if stored.Email != tt.wantEmail || !stored.IsEmailVerified() {
+				t.Errorf("stored user = %+v, want the verified address %s", stored, tt.wantEmail)
+			}
+			if tt.conflicts > 0 && (racing.conflicts != 0 || stored.Name != "Renamed Meanwhile") {
+				t.Errorf("stored user = %+v, want the concurrent rename kept", stored)
+			}
+		})
+	}
+}
+
+func TestRevertEmailChange(t *testing.T) {
+	tests := []struct {
+		name         string
+		confirm      bool
+		reuse        bool
+		token        string
+		later        time.Duration
+		conflicts    int
+		wantErr      errors.ErrorType
+		wantEmail    string
+		wantVerified bool
+	}{
+		{name: "pending change", wantEmail: "old@example.com"},
+		{name: "confirmed change", confirm: true, wantEmail: "old@example.com", wantVerified: true},
+		{name: "version conflict on a pending change", conflicts: 2, wantEmail: "old@example.com"},
+		{name: "version conflict on a confirmed change", confirm: true, conflicts: 2, wantEmail: "old@example.com", wantVerified: true},
+		{name: "reused link", confirm: true, reuse: true, wantErr: errors.InvalidInput, wantEmail: "old@example.com"},
+		{name: "unknown token", token: "unknown", wantErr: errors.InvalidInput, wantEmail: "old@example.com"},
+		{name: "expired link of a pending change", later: 48 * time.Hour, wantErr: errors.InvalidInput, wantEmail: "old@example.com"},
+		{name: "expired link of a confirmed change", confirm: true, later: 48 * time.Hour, wantErr: errors.InvalidInput, wantEmail: "new@example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newMockUserRepository()
+			repo.users[1] = &domain.User{ID: 1, Email: "old@example.com", Name: "Test User"}
+			refreshTokens := newMockRefreshTokenRepository()
+			refreshTokens.Create(&domain.RefreshToken{UserID: 1})
+			audit := newMockAuditRepository()
+			verifier, users, mail := newTestEmailVerificationService(t, repo, refreshTokens, audit)
+
+			changeEmail(t, users, 1, "new@example.com")
+			verificationToken := mailedToken(t, mail, "new@example.com")
+			if tt.confirm {
+				if _, err := verifier.Verify(verificationToken); err != nil {
+					t.Fatalf("Verify() error = %v", err)
+				}
+			}
+			revertToken := mailedToken(t, mail, "old@example.com")
+			if tt.reuse {
+				if _, err := verifier.Revert(revertToken, testRequest); err != nil {
+					t.Fatalf("Revert() error = %v", err)
+				}
+			}
+			if tt.token != "" {
+				revertToken = tt.token
+			}
+			if tt.later != 0 {
+				verifier.now = func() time.Time { return time.Now().Add(tt.later) }
+			}
+			racing := &racingUserRepository{mockUserRepository: repo, conflicts: tt.conflicts}
+			verifier.users = racing
+
+			user, err := verifier.Revert(revertToken, testRequest)
+			stored := repo.users[1]
+			if stored.Email != tt.wantEmail {
+				t.Errorf("stored email = %q, want %q", stored.Email, tt.wantEmail)
+			}
+			if tt.wantErr != "" {
+				assertErrorType(t, "Revert()", err, tt.wantErr)
+				if !tt.reuse && refreshTokens.tokens[1].RevokedAt != nil {
+					t.Error("failed Revert() revoked the refresh tokens")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Revert() error = %v", err)
+			}
+
+			if user.Email != tt.wantEmail || user.PendingEmail != "" || user.IsEmailVerified() != tt.wantVerified {
+				t.Errorf("Revert() = %+v, want %s with verified = %v", user, tt.wantEmail, tt.wantVerified)
+			}
+			if tt.conflicts > 0 && (racing.conflicts != 0 || stored.Name != "Renamed Meanwhile") {
+				t.Errorf("stored user = %+v, want the concurrent rename kept", stored)
+			}
+			if refreshTokens.tokens[1].RevokedAt == nil {
+				t.Error("Revert() did not revoke the refresh tokens")
+			}
+			actions := map[string]bool{}
+			for _, event := range audit.events {
+				actions[event.Action] = true
+			}
+			if !actions[domain.AuditEmailChangeReverted] || !actions[domain.AuditTokensRevoked] {
+				t.Errorf("audited %v, want the revert and the revoked tokens", audit.actions())
+			}
+
+			// The reverted address is released and its link no longer works
+			if reserved, _ := verifier.EmailReserved("new@example.com", 2); reserved {
+				t.Error("reverted change still reserves its address")
+			}
+			_, err = verifier.Verify(verificationToken)
+			assertErrorType(t, "Verify(reverted)", err, errors.InvalidInput)
+		})
+	}
+}
+
+func TestRequestEmailChange(t *testing.T) {
+	tests := []struct {
+		name string
+		// other is user 2, whose email changes are applied first
+		other            *domain.User
+		otherChanges     []string
+		otherConfirm     bool
+		otherRevert      bool
+		create           bool
+		patch            bool
+		conflicts        int
+		requestChangeErr error
+		wantErr          errors.ErrorType
+	}{
+		{name: "update"},
+		{name: "patch", patch: true},
+		{
+			name:    "address of another user",
+			other:   &domain.User{ID: 2, Email: "new@example.com", Name: "Other User"},
+			wantErr: errors.DuplicateEmail,
+		},
+		{
+			name:         "address pending for another user",
+			other:        &domain.User{ID: 2, Email: "other@example.com", Name: "Other User"},
+			otherChanges: []string{"new@example.com"},
+			wantErr:      errors.DuplicateEmail,
+		},
+		{
+			name:         "signup with an address pending for another user",
+			other:        &domain.User{ID: 2, Email: "other@example.com", Name: "Other User"},
+			otherChanges: []string{"new@example.com"},
+			create:       true,
+			wantErr:      errors.DuplicateEmail,
+		},
+		{
+			// The old address stays reserved while the change can be reverted
+			name:         "old address of a confirmed change",
+			other:        &domain.User{ID: 2, Email: "new@example.com", Name: "Other User"},
+			otherChanges: []string{"moved@example.com"},
+			otherConfirm: true,
+			wantErr:      errors.DuplicateEmail,
+		},
+		{
+			name:         "address of a superseded change",
+			other:        &domain.User{ID: 2, Email: "other@example.com", Name: "Other User"},
+			otherChanges: []string{"new@example.com", "elsewhere@example.com"},
+		},
+		{
+			name:         "address of a reverted change",
+			other:        &domain.User{ID: 2, Email: "other@example.com", Name: "Other User"},
+			otherChanges: []string{"new@example.com"},
+			otherRevert:  true,
+		},
+		{name: "update loses version check", conflicts: 1, wantErr: errors.PreconditionFailed},
+		{name: "patch loses version check", patch: true, conflicts: 1, wantErr: errors.PreconditionFailed},
+		{name: "address taken after the update", requestChangeErr: errors.DuplicateEmailError("new@example.com"), wantErr: errors.DuplicateEmail},
+		{name: "patched address taken after the update", patch: true, requestChangeErr: errors.DuplicateEmailError("new@example.com"), wantErr: errors.DuplicateEmail},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newMockUserRepository()
+			repo.users[1] = &domain.User{ID: 1, Email: "old@example.com", Name: "Test User", Version: 1}
+			audit := newMockAuditRepository()
+			verifier, users, mail := newTestEmailVerificationService(t, repo, newMockRefreshTokenRepository(), audit)
+
+			if tt.other != nil {
+				repo.users[2] = tt.other
+				for _, email := range tt.otherChanges {
+					changeEmail(t, users, 2, email)
+				}
+				if tt.otherConfirm {
+					if _, err := verifier.Verify(mailedToken(t, mail, tt.otherChanges[len(tt.otherChanges)-1])); err != nil {
+						t.Fatalf("Verify() error = %v", err)
+					}
+				}
+				if tt.otherRevert {
+					if _, err := verifier.Revert(mailedToken(t, mail, tt.other.Email), testRequest); err != nil {
+						t.Fatalf("Revert() error = %v", err)
+					}
+				}
+			}
+			if tt.conflicts > 0 || tt.requestChangeErr != nil {
+				var changes domain.EmailVerificationService = verifier
+				if tt.requestChangeErr != nil {
+					changes = &mockEmailVerifier{requestChangeErr: tt.requestChangeErr}
+				}
+				racing := &racingUserRepository{mockUserRepository: repo, conflicts: tt.conflicts}
+				users = NewUserService(racing, testHasher, password.DefaultPolicy(), changes, NewAuditService(audit), testAPIConfig)
+			}
+
+			var err error
+			switch {
+			case tt.create:
+				err = users.Create(&domain.User{Email: "new@example.com", Password: "Password123!", Name: "New User"}, testRequest)
+			case tt.patch:
+				_, err = users.Patch(domain.SystemPrincipal(), 1, &domain.UserPatch{ContentType: domain.MergePatchContentType, Body: []byte(`{"email":"new@example.com"}`)})
+			default:
+				err = users.Update(domain.SystemPrincipal(), &domain.User{ID: 1, Email: "new@example.com", Name: "Test User"})
+			}
+
+			stored := repo.users[1]
+			messages, _ := mail.Messages("old@example.com")
+			if tt.wantErr != "" {
+				assertErrorType(t, "change email", err, tt.wantErr)
+				if stored.Email != "old@example.com" || stored.PendingEmail != "" {
+					t.Errorf("stored email = %q, pending = %q, want the change dropped", stored.Email, stored.PendingEmail)
+				}
+				if pending, _ := verifier.changes.GetPending(1); pending != nil {
+					t.Errorf("failed change left the pending change %+v", pending)
+				}
+				if len(messages) != 0 {
+					t.Errorf("failed change sent %d emails to the old address", len(messages))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("change email error = %v", err)
+			}
+
+			// The old address stays in place until the new one is confirmed
+			if stored.Email != "old@example.com" || stored.PendingEmail != "new@example.com" {
+				t.Errorf("stored email = %q, pending = %q, want new@example.com pending", stored.Email, stored.PendingEmail)
+			}
+			if len(messages) != 1 || !strings.Contains(messages[0], "https://app.example.com/revert?token=") {
+				t.Errorf("old address got %d emails, want a notice with a revert link", len(messages))
+			}
+			if _, err := verifier.Verify(mailedToken(t, mail, "new@example.com")); err != nil {
+				t.Errorf("Verify() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestResendVerification(t *testing.T) {
+	tests := []struct {
+		name       string
+		email      string
+		verified   bool
+		resends    int
+		wantErr    errors.ErrorType
+		wantEmails int
+	}{
+		{name: "unverified email", email: "test@example.com", resends: 1, wantEmails: 1},
+		{name: "within the cooldown", email: "test@example.com", resends: 2, wantEmails: 1},
+		{name: "verified email", email: "test@example.com", verified: true, resends: 1, wantEmails: 0},
+		{name: "unknown email", email: "nobody@example.com", resends: 1, wantEmails: 0},
+		{name: "invalid email", email: "not-an-email", resends: 1, wantErr: errors.InvalidEmail},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newMockUserRepository()
+			repo.users[1] = &domain.User{ID: 1, Email: "test@example.com", Name: "Test User"}
+			if tt.verified {
+				verifiedAt := time.Now()
+				repo.users[1].EmailVerifiedAt = &verifiedAt
+			}
+			verifier, _, mail := newTestEmailVerificationService(t, repo, newMockRefreshTokenRepository(), newMockAuditRepository())
+
+			for i := 0; i < tt.resends; i++ {
+				err := verifier.Resend(tt.email)
+				if tt.wantErr != "" {
+					assertErrorType(t, "Resend()", err, tt.wantErr)
+				} else if err != nil {
+					t.Fatalf("Resend() error = %v", err)
+				}
+			}
+			if messages, _ := mail.Messages(tt.email); len(messages) != tt.wantEmails {
+				t.Errorf("got %d verification emails, want %d", len(messages), tt.wantEmails)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package service
+
+import (
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/errors"
+	"UserRESTfulApi/pkg/config"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+)
+
+type loginThrottleService struct {
+	repo domain.LoginThrottleRepository
+	cfg  config.LockoutConfig
+	now  func() time.Time
+}
+
+// NewLoginThrottleService creates a new service tracking failed logins.
+//
+// Every failed login of an account doubles the delay before the next attempt
+// for that account is accepted, starting at BackoffBase and capped at
+// BackoffMax. Reaching AccountThreshold failures locks the account for
+// LockDuration. Client IPs are only locked, at IPThreshold failures, so users
+// sharing an address are not slowed down by each other's typos.
+func NewLoginThrottleService(repo domain.LoginThrottleRepository, cfg config.LockoutConfig) domain.LoginThrottleService {
+	return &loginThrottleService{repo: repo, cfg: cfg, now: time.Now}
+}
+
+// Check rejects the attempt when the account or IP is locked or still backing off
+func (s *loginThrottleService) Check(email, ip string) error {
+	now := s.now()
+
+	if ip = normalizeIP(ip); ip != "" {
+		throttle, err := s.repo.Get(domain.ThrottleIP, ip)
+		if err != nil {
+			return err
+		}
+		if until := s.blockedUntil(throttle, now); until != nil {
+			return errors.TooManyAttemptsError("too many failed logins from this address", until.Sub(now))
+		}
+	}
+
+	email = normalizeEmail(email)
+	if email == "" {
+		return nil
+	}
+	throttle, err := s.repo.Get(domain.ThrottleAccount, email)
+	if err != nil {
+		return err
+	}
+	if until := s.blockedUntil(throttle, now); until != nil {
+		if throttle.LockedUntil != nil && throttle.LockedUntil.After(now) {
+			return errors.TooManyAttemptsError("account is temporarily locked after too many failed logins", until.Sub(now))
+		}
+		return errors.TooManyAttemptsError("too many failed logins for this account", until.Sub(now))
+	}
+
+	return nil
+}
+
+// RecordFailure counts a failed login for the account and IP, locking them
+// once a threshold is reached. Attempts without an email, such as unknown
+// magic links, only count against the IP.
+func (s *loginThrottleService) RecordFailure(email, ip string) error {
+	if email = normalizeEmail(email); email != "" {
+		if err := s.recordFailure(domain.ThrottleAccount, email, s.cfg.AccountThreshold); err != nil {
+			return err
+		}
+	}
+	if ip = normalizeIP(ip); ip != "" {
+		return s.recordFailure(domain.ThrottleIP, ip, s.cfg.IPThreshold)
+	}
+	return nil
+}
+
+// RecordSuccess clears the failures of the account. IP failures are kept so a
+// valid login cannot be used to reset the counter of an address guessing other accounts.
+func (s *loginThrottleService) RecordSuccess(email string) error {
+	_, err := s.repo.Delete(domain.ThrottleAccount, normalizeEmail(email))
+	return err
+}
+
+// List lists accounts and IPs with recent failures, or only the locked ones
+func (s *loginThrottleService) List(actor *domain.Principal, lockedOnly bool) ([]*domain.LoginThrottle, error) {
+	if !actor.Can(domain.PermissionLockoutsManage) {
+		return nil, errors.ForbiddenError("manage login lockouts")
+	}
+
+	now := s.now()
+	throttles, err := s.repo.List(now.Add(-s.cfg.FailureWindow), now, lockedOnly)
+	if err != nil {
+		return nil, err
+	}
+	for _, throttle := range throttles {
+		throttle.BlockedUntil = s.blockedUntil(throttle, now)
+	}
+	return throttles, nil
+}
+
+// Clear removes the failures and lock of an account or IP
+func (s *loginThrottleService) Clear(actor *domain.Principal, kind, subject string) error {
+	if !actor.Can(domain.PermissionLockoutsManage) {
+		return errors.ForbiddenError("manage login lockouts")
+	}
+
+	switch kind {
+	case domain.ThrottleAccount:
+		subject = normalizeEmail(subject)
+	case domain.ThrottleIP:
+		subject = normalizeIP(subject)
+	default:
+		return errors.InvalidInputError("kind", fmt.Sprintf("must be %q or %q", domain.ThrottleAccount, domain.ThrottleIP))
+	}
+
+	found, err := s.repo.Delete(kind, subject)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return errors.NotFoundError("lockout", kind+" "+subject)
+	}
+
+	log.Printf("Cleared login lockout for %s %s by user %d", kind, subject, actor.ID())
+	return nil
+}
+
+func (s *loginThrottleService) recordFailure(kind, subject string, threshold int) error {
+	now := s.now()
+	throttle, err := s.repo.RecordFailure(kind, subject, now, now.Add(-s.cfg.FailureWindow))
+	if err != nil {
+		return err
+	}
+
+	if threshold > 0 && throttle.Failures >= threshold && throttle.LockedUntil == nil {
+		log.Printf("Locking %s %s for %s after %d failed logins", kind, subject, s.cfg.LockDuration, throttle.Failures)
+		return s.repo.Lock(kind, subject, now.Add(s.cfg.LockDuration))
+	}
+	return nil
+}
+
+// blockedUntil returns when the next attempt is accepted, or nil if it is accepted now
+func (s *loginThrottleService) blockedUntil(throttle *domain.LoginThrottle, now time.Time) *time.Time {
+	if throttle == nil {
+		return nil
+	}
+	if throttle.LockedUntil != nil {
+		if throttle.LockedUntil.After(now) {
+			return throttle.LockedUntil
+		}
+		// The lock expired, the next failure starts a new count
+		return nil
+	}
+	if throttle.Kind != domain.ThrottleAccount || throttle.LastFailureAt.Before(now.Add(-s.cfg.FailureWindow)) {
+		return nil
+	}
+
+	next := throttle.LastFailureAt.Add(s.backoff(throttle.Failures))
+	if next.After(now) {
+		return &next
+	}
+	return nil
+}
+
+// backoff returns the delay imposed after the given number of consecutive failures
+func (s *loginThrottleService) backoff(failures int) time.Duration {
+	if failures < 1 || s.cfg.BackoffBase <= 0 {
+		return 0
+	}
+
+	delay := s.cfg.BackoffBase
+	for i := 1; i < failures; i++ {
+		delay *= 2
+		if s.cfg.BackoffMax > 0 && delay >= s.cfg.BackoffMax {
+			return s.cfg.BackoffMax
+		}
+	}
+	if s.cfg.BackoffMax > 0 && delay > s.cfg.BackoffMax {
+		return s.cfg.BackoffMax
+	}
+	return delay
+}
+
+// normalizeEmail makes differently cased spellings of an email share one throttle
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// normalizeIP returns the canonical form of an IP address, or "" if it is not one
+func normalizeIP(ip string) string {
+	parsed := net.ParseIP(strings.TrimSpace(ip))
+	if parsed == nil {
+		return ""
+	}
+	return parsed.String()
+}
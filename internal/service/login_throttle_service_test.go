@@ -0,0 +1,352 @@
+package service
+
+import (
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/errors"
+	"UserRESTfulApi/pkg/config"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testLockoutConfig = config.LockoutConfig{
+	AccountThreshold: 3,
+	IPThreshold:      5,
+	LockDuration:     15 * time.Minute,
+	FailureWindow:    time.Hour,
+	BackoffBase:      time.Second,
+	BackoffMax:       8 * time.Second,
+}
+
+// Mock login throttle repository for testing
+type mockLoginThrottleRepository struct {
+	throttles map[string]*domain.LoginThrottle
+}
+
+func newMockLoginThrottleRepository() *mockLoginThrottleRepository {
+	return &mockLoginThrottleRepository{
+		throttles: make(map[string]*domain.LoginThrottle),
+	}
+}
+
+func (m *mockLoginThrottleRepository) Get(kind, subject string) (*domain.LoginThrottle, error) {
+	if throttle, exists := m.throttles[kind+" "+subject]; exists {
+		copied := *throttle
+		return &copied, nil
+	}
+	return nil, nil
+}
+
+func (m *mockLoginThrottleRepository) RecordFailure(kind, subject string, now, windowStart time.Time) (*domain.LoginThrottle, error) {
+	throttle, exists := m.throttles[kind+" "+subject]
+	if !exists {
+		throttle = &domain.LoginThrottle{Kind: kind, Subject: subject, CreatedAt: now}
+		m.throttles[kind+" "+subject] = throttle
+	}
+
+	lockExpired := throttle.LockedUntil != nil && !throttle.LockedUntil.After(now)
+	if throttle.LastFailureAt.Before(windowStart) || lockExpired {
+		throttle.Failures = 0
+	}
+	if lockExpired {
+		throttle.LockedUntil = nil
+	}
+	throttle.Failures++
+	throttle.LastFailureAt = now
+	throttle.UpdatedAt = now
+
+	copied := *throttle
+	return &copied, nil
+}
+
+func (m *mockLoginThrottleRepository) Lock(kind, subject string, until time.Time) error {
+	if throttle, exists := m.throttles[kind+" "+subject]; exists && throttle.LockedUntil == nil {
+		throttle.LockedUntil = &until
+	}
+	return nil
+}
+
+func (m *mockLoginThrottleRepository) List(windowStart, now time.Time, lockedOnly bool) ([]*domain.LoginThrottle, error) {
+	var throttles []*domain.LoginThrottle
+	for _, throttle := range m.throttles {
+		locked := throttle.LockedUntil != nil && throttle.LockedUntil.After(now)
+		if locked || (!lockedOnly && !throttle.LastFailureAt.Before(windowStart)) {
+			copied := *throttle
+			throttles = append(throttles, &copied)
+		}
+	}
+	return throttles, nil
+}
+
+func (m *mockLoginThrottleRepository) Delete(kind, subject string) (bool, error) {
+	_, exists := m.throttles[kind+" "+subject]
+	delete(m.throttles, kind+" "+subject)
+	return exists, nil
+}
+
+// newTestThrottleService returns a throttle service whose clock is advanced by the returned function
+func newTestThrottleService() (*loginThrottleService, *mockLoginThrottleRepository, func(time.Duration)) {
+	repo := newMockLoginThrottleRepository()
+	service := NewLoginThrottleService(repo, testLockoutConfig).(*loginThrottleService)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	service.now = func() time.Time { return now }
+	return service, repo, func(d time.Duration) { now = now.Add(d) }
+}
+
+func assertTooManyRequests(t *testing.T, err error, wantRetryAfter time.Duration) {
+	t.Helper()
+	appErr, ok := err.(*errors.AppError)
+	if !ok || appErr.Type != errors.TooManyRequests {
+		t.Fatalf("error = %v, want TooManyRequests", err)
+	}
+	if wantRetryAfter > 0 && appErr.RetryAfter != wantRetryAfter {
+		t.Errorf("RetryAfter = %s, want %s", appErr.RetryAfter, wantRetryAfter)
+	}
+}
+
+// recordFailures records a failed login of each email from the IP, one second apart
+func recordFailures(t *testing.T, service *loginThrottleService, advance func(time.Duration), ip string, emails ...string) {
+	t.Helper()
+	for _, email := range emails {
+		if err := service.RecordFailure(email, ip); err != nil {
+			t.Fatalf("RecordFailure(%s) error = %v", email, err)
+		}
+		advance(time.Second)
+	}
+}
+
+func TestLoginThrottleCheck(t *testing.T) {
+	const email = "victim@example.com"
+	tests := []struct {
+		name string
+		// record records failures before the check
+		record      func(t *testing.T, service *loginThrottleService, advance func(time.Duration))
+		email       string
+		ip          string
+		wantBlocked bool
+		// wantRetryAfter is checked when set
+		wantRetryAfter time.Duration
+	}{
+		{name: "no failures", email: email, ip: testClientIP},
+		// The delay doubles with each failure
+		{
+			name: "one failure",
+			record: func(t *testing.T, service *loginThrottleService, advance func(time.Duration)) {
+				service.RecordFailure(email, testClientIP)
+			},
+			email:          email,
+			ip:             testClientIP,
+			wantBlocked:    true,
+			wantRetryAfter: time.Second,
+		},
+		{
+			name: "one failure after its delay",
+			record: func(t *testing.T, service *loginThrottleService, advance func(time.Duration)) {
+				recordFailures(t, service, advance, testClientIP, email)
+			},
+			email: email,
+			ip:    testClientIP,
+		},
+		{
+			name: "two failures",
+			record: func(t *testing.T, service *loginThrottleService, advance func(time.Duration)) {
+				recordFailures(t, service, advance, testClientIP, email)
+				service.RecordFailure(email, testClientIP)
+			},
+			email:          email,
+			ip:             testClientIP,
+			wantBlocked:    true,
+			wantRetryAfter: 2 * time.Second,
+		},
+		{
+			// Reaching the threshold locks the account, whatever the case of the email
+			name: "account threshold from another IP",
+			record: func(t *testing.T, service *loginThrottleService, advance func(time.Duration)) {
+				recordFailures(t, service, advance, testClientIP, email, email)
+				advance(2 * time.Second)
+				service.RecordFailure("Victim@Example.com", testClientIP)
+			},
+			email:          email,
+			ip:             "198.51.100.1",
+			wantBlocked:    true,
+			wantRetryAfter: testLockoutConfig.LockDuration,
+		},
+		{
+			name: "expired account lock",
+			record: func(t *testing.T, service *loginThrottleService, advance func(time.Duration)) {
+				recordFailures(t, service, advance, testClientIP, email, email, email)
+				advance(testLockoutConfig.LockDuration)
+			},
+			email: email,
+			ip:    testClientIP,
+		},
+		{
+			// The count starts again once the lock expires
+			name: "failure after an expired account lock",
+			record: func(t *testing.T, service *loginThrottleService, advance func(time.Duration)) {
+				recordFailures(t, service, advance, testClientIP, email, email, email)
+				advance(testLockoutConfig.LockDuration)
+				service.RecordFailure(email, testClientIP)
+			},
+			email:          email,
+			ip:             testClientIP,
+			wantBlocked:    true,
+			wantRetryAfter: time.Second,
+		},
+		{
+			// Failures spread over many accounts are caught by the IP counter
+			name: "IP threshold",
+			record: func(t *testing.T, service *loginThrottleService, advance func(time.Duration)) {
+				recordFailures(t, service, advance, testClientIP, "a@example.com", "b@example.com", "c@example.com", "d@example.com", "e@example.com")
+			},
+			email:       "new@example.com",
+			ip:          testClientIP,
+			wantBlocked: true,
+		},
+		{
+			name: "IP threshold from another IP",
+			record: func(t *testing.T, service *loginThrottleService, advance func(time.Duration)) {
+				recordFailures(t, service, advance, testClientIP, "a@example.com", "b@example.com", "c@example.com", "d@example.com", "e@example.com")
+			},
+			email: "new@example.com",
+			ip:    "198.51.100.1",
+		},
+		{
+			// A successful login does not reset the IP counter
+			name: "IP threshold after a successful login",
+			record: func(t *testing.T, service *loginThrottleService, advance func(time.Duration)) {
+				recordFailures(t, service, advance, testClientIP, "a@example.com", "b@example.com", "c@example.com", "d@example.com", "e@example.com")
+				service.RecordSuccess("a@example.com")
+			},
+			email:       "a@example.com",
+			ip:          testClientIP,
+			wantBlocked: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, _, advance := newTestThrottleService()
+			if tt.record != nil {
+				tt.record(t, service, advance)
+			}
+
+			err := service.Check(tt.email, tt.ip)
+			if tt.wantBlocked {
+				assertTooManyRequests(t, err, tt.wantRetryAfter)
+			} else if err != nil {
+				t.Errorf("Check() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestLoginThrottleRecordSuccess(t *testing.T) {
+	const email = "user@example.com"
+	tests := []struct {
+		name        string
+		email       string
+		wantCleared bool
+	}{
+		{name: "same email", email: email, wantCleared: true},
+		{name: "email in another case", email: "User@Example.com", wantCleared: true},
+		{name: "another email", email: "other@example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, repo, advance := newTestThrottleService()
+			recordFailures(t, service, advance, testClientIP, email, email)
+			advance(time.Minute)
+
+			if err := service.RecordSuccess(tt.email); err != nil {
+				t.Fatalf("RecordSuccess() error = %v", err)
+			}
+			if throttle, _ := repo.Get(domain.ThrottleAccount, email); (throttle == nil) != tt.wantCleared {
+				t.Errorf("account throttle = %+v, want cleared = %v", throttle, tt.wantCleared)
+			}
+			if throttle, _ := repo.Get(domain.ThrottleIP, testClientIP); throttle == nil || throttle.Failures != 2 {
+				t.Errorf("IP throttle = %+v, want 2 failures kept", throttle)
+			}
+		})
+	}
+}
+
+func TestLoginThrottleList(t *testing.T) {
+	tests := []struct {
+		name       string
+		actor      *domain.Principal
+		lockedOnly bool
+		want       int
+		wantErr    bool
+	}{
+		{name: "locked only", actor: domain.SystemPrincipal(), lockedOnly: true, want: 1},
+		{name: "every failure in the window", actor: domain.SystemPrincipal(), want: 3},
+		{name: "without the lockout permission", actor: domain.NewPrincipal(&domain.User{ID: 2}, nil), lockedOnly: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, _, _ := newTestThrottleService()
+			for i := 0; i < testLockoutConfig.AccountThreshold; i++ {
+				service.RecordFailure("locked@example.com", "")
+			}
+			service.RecordFailure("other@example.com", testClientIP)
+
+			throttles, err := service.List(tt.actor, tt.lockedOnly)
+			if tt.wantErr {
+				assertErrorType(t, "List()", err, errors.Forbidden)
+				return
+			}
+			if err != nil || len(throttles) != tt.want {
+				t.Fatalf("List() = %+v, %v, want %d throttles", throttles, err, tt.want)
+			}
+			for _, throttle := range throttles {
+				if locked := throttle.Subject == "locked@example.com"; locked != (throttle.LockedUntil != nil) {
+					t.Errorf("List() = %+v, want only the account locked", throttle)
+				}
+			}
+		})
+	}
+}
+
+func TestLoginThrottleClear(t *testing.T) {
+	const email = "locked@example.com"
+	tests := []struct {
+		name    string
+		actor   *domain.Principal
+		kind    string
+		subject string
+		wantErr errors.ErrorType
+	}{
+		{name: "account in another case", actor: domain.SystemPrincipal(), kind: domain.ThrottleAccount, subject: "LOCKED@example.com"},
+		{name: "IP", actor: domain.SystemPrincipal(), kind: domain.ThrottleIP, subject: testClientIP},
+		{name: "unknown kind", actor: domain.SystemPrincipal(), kind: "user", subject: email, wantErr: errors.InvalidInput},
+		{name: "account without failures", actor: domain.SystemPrincipal(), kind: domain.ThrottleAccount, subject: "other@example.com", wantErr: errors.NotFound},
+		{name: "without the lockout permission", actor: domain.NewPrincipal(&domain.User{ID: 2}, nil), kind: domain.ThrottleAccount, subject: email, wantErr: errors.Forbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, repo, _ := newTestThrottleService()
+			for i := 0; i < testLockoutConfig.AccountThreshold; i++ {
+				service.RecordFailure(email, testClientIP)
+			}
+
+			err := service.Clear(tt.actor, tt.kind, tt.subject)
+			if tt.wantErr != "" {
+				assertErrorType(t, "Clear()", err, tt.wantErr)
+				if throttle, _ := repo.Get(domain.ThrottleAccount, email); throttle == nil {
+					t.Error("failed Clear() removed the account lock")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Clear() error = %v", err)
+			}
+			if throttle, _ := repo.Get(tt.kind, strings.ToLower(tt.subject)); throttle != nil {
+				t.Errorf("throttle = %+v, want cleared", throttle)
+			}
+		})
+	}
+}
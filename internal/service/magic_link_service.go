@@ -0,0 +1,224 @@
+package service
+
+import (
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/errors"
+	"UserRESTfulApi/pkg/config"
+	"UserRESTfulApi/pkg/mailer"
+	"UserRESTfulApi/pkg/token"
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+// magicLinkRateWindow is the period MagicLinkRateLimit applies to
+const magicLinkRateWindow = time.Hour
+
+// magicLinkCodeDigits is the length of emailed sign in codes
+const magicLinkCodeDigits = 6
+
+type magicLinkService struct {
+	users       domain.UserRepository
+	links       domain.MagicLinkRepository
+	mailer      mailer.Mailer
+	audit       domain.AuditService
+	enabled     bool
+	ttl         time.Duration
+	linkURL     string
+	maxAttempts int
+	rateLimit   int
+	now         func() time.Time
+}
+
+// NewMagicLinkService creates a new passwordless sign in service
+func NewMagicLinkService(users domain.UserRepository, links domain.MagicLinkRepository, m mailer.Mailer, audit domain.AuditService, cfg config.AuthConfig) domain.MagicLinkService {
+	return &magicLinkService{
+		users:       users,
+		links:       links,
+		mailer:      m,
+		audit:       audit,
+		enabled:     cfg.PasswordlessLogin(),
+		ttl:         cfg.MagicLinkTTL,
+		linkURL:     cfg.MagicLinkURL,
+		maxAttempts: cfg.MagicLinkMaxAttempts,
+		rateLimit:   cfg.MagicLinkRateLimit,
+		now:         time.Now,
+	}
+}
+
+// Send emails a single-use sign in link and code to the user with the given
+// email. At most MagicLinkRateLimit links are sent to an address, and
+// requested from a client IP, each hour. Unknown or deactivated accounts,
+// rate limited requests and delivery failures all look like success to the
+// caller so the response never reveals whether an account exists.
+func (s *magicLinkService) Send(email string, request domain.RequestMeta) error {
+	if !s.enabled {
+		return errors.ForbiddenError("log in without a password")
+	}
+	email = strings.TrimSpace(email)
+	if _, err := mail.ParseAddress(email); err != nil {
+		return errors.InvalidEmailError(email)
+	}
+
+	user, err := s.users.GetByEmail(email)
+	if err != nil {
+		return err
+	}
+	if user == nil || !user.IsActive() {
+		return nil
+	}
+
+	limited, err := s.rateLimited(user.ID, request.ClientIP)
+	if err != nil {
+		return err
+	}
+	if limited {
+		log.Printf("Magic link for user %d from %s rate limited, not sending another email", user.ID, request.ClientIP)
+		return nil
+	}
+
+	linkToken, err := token.NewOpaque()
+	if err != nil {
+		return errors.InternalServerError(err)
+	}
+	code, err := token.NewNumericCode(magicLinkCodeDigits)
+	if err != nil {
+		return errors.InternalServerError(err)
+	}
+	expiresAt := s.now().Add(s.ttl)
+	err = s.links.Create(&domain.MagicLink{
+		UserID:    user.ID,
+		TokenHash: token.HashOpaque(linkToken),
+		CodeHash:  token.HashOpaque(code),
+		ClientIP:  request.ClientIP,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return err
+	}
+	event := domain.NewAuditEvent(domain.AuditMagicLinkRequested, nil, request).ForUser(user.ID)
+	event.Details = map[string]string{"expires_at": auditTime(expiresAt)}
+	s.audit.Record(event)
+
+	link, err := tokenLink(s.linkURL, linkToken)
+	if err != nil {
+		return errors.InternalServerError(err)
+	}
+	err = s.mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Your sign in link",
+		Body: fmt.Sprintf("Hello %s,\n\n"+
+			"Use the link below to sign in, or enter the code %s. "+
+			"Either can be used once and expires at %s.\n\n"+
+			"%s\n\n"+
+			"If you did not try to sign in you can ignore this email.\n",
+			user.Name, code, expiresAt.UTC().Format(time.RFC1123), link),
+	})
+	if err != nil {
+		log.Printf("Failed to send magic link email to user %d: %v", user.ID, err)
+	}
+	return nil
+}
+
+// Redeem consumes a magic link, identified by its token or by the email
+// address and code. Codes are checked against the latest link sent to the
+// address, which is discarded after MagicLinkMaxAttempts wrong codes.
+func (s *magicLinkService) Redeem(linkToken, email, code string) (*domain.User, error) {
+	if !s.enabled {
+		return nil, errors.ForbiddenError("log in without a password")
+	}
+	invalid := errors.UnauthorizedError("magic link or code is invalid or has expired")
+
+	var link *domain.MagicLink
+	var err error
+	switch {
+	case linkToken != "":
+		if link, err = s.links.GetByHash(token.HashOpaque(linkToken)); err != nil {
+			return nil, err
+		}
+	case email != "" && code != "":
+		if link, err = s.latestLink(email); err != nil {
+			return nil, err
+		}
+		if link != nil && s.isRedeemable(link) && subtle.ConstantTimeCompare([]byte(token.HashOpaque(normalizeCode(code))), []byte(link.CodeHash)) != 1 {
+			attempts, err := s.links.RecordFailure(link.ID)
+			if err != nil {
+				return nil, err
+			}
+			if attempts >= s.maxAttempts {
+				if _, err := s.links.MarkUsed(link.ID); err != nil {
+					return nil, err
+				}
+			}
+			return nil, invalid
+		}
+	default:
+		return nil, errors.InvalidInputError("token", "provide a magic link token, or an email and code")
+	}
+	if link == nil || !s.isRedeemable(link) {
+		return nil, invalid
+	}
+
+	used, err := s.links.MarkUsed(link.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !used {
+		// Another request redeemed the link between our read and update
+		return nil, invalid
+	}
+	if err := s.links.InvalidateForUser(link.UserID); err != nil {
+		return nil, err
+	}
+
+	user, err := s.users.Get(link.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, invalid
+	}
+	return user, nil
+}
+
+// latestLink returns the latest magic link sent to the email address, or nil
+func (s *magicLinkService) latestLink(email string) (*domain.MagicLink, error) {
+	user, err := s.users.GetByEmail(strings.TrimSpace(email))
+	if err != nil || user == nil {
+		return nil, err
+	}
+	return s.links.GetLatestForUser(user.ID)
+}
+
+// isRedeemable reports whether the link is unused and unexpired
+func (s *magicLinkService) isRedeemable(link *domain.MagicLink) bool {
+	return link.UsedAt == nil && s.now().Before(link.ExpiresAt)
+}
+
+// rateLimited reports whether the user or the client IP reached the hourly
+// magic link limit
+func (s *magicLinkService) rateLimited(userID uint, clientIP string) (bool, error) {
+	if s.rateLimit <= 0 {
+		return false, nil
+	}
+	since := s.now().Add(-magicLinkRateWindow)
+
+	sent, err := s.links.CountForUserSince(userID, since)
+	if err != nil {
+		return false, err
+	}
+	if sent >= int64(s.rateLimit) {
+		return true, nil
+	}
+	if clientIP == "" {
+		return false, nil
+	}
+	requested, err := s.links.CountForIPSince(clientIP, since)
+	if err != nil {
+		return false, err
+	}
+	return requested >= int64(s.rateLimit), nil
+}
@@ -0,0 +1,329 @@
+package service
+
+import (
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/errors"
+	"UserRESTfulApi/pkg/config"
+	"UserRESTfulApi/pkg/mailer"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+)
+
+// Mock magic link repository for testing
+type mockMagicLinkRepository struct {
+	links map[uint]*domain.MagicLink
+}
+
+func newMockMagicLinkRepository() *mockMagicLinkRepository {
+	return &mockMagicLinkRepository{
+		links: make(map[uint]*domain.MagicLink),
+	}
+}
+
+func (m *mockMagicLinkRepository) Create(link *domain.MagicLink) error {
+	link.ID = uint(len(m.links) + 1)
+	link.CreatedAt = time.Now()
+	m.links[link.ID] = link
+	return nil
+}
+
+func (m *mockMagicLinkRepository) GetByHash(tokenHash string) (*domain.MagicLink, error) {
+	for _, link := range m.links {
+		if link.TokenHash == tokenHash {
+			copied := *link
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *mockMagicLinkRepository) GetLatestForUser(userID uint) (*domain.MagicLink, error) {
+	var latest *domain.MagicLink
+	for _, link := range m.links {
+		if link.UserID == userID && (latest == nil || link.ID > latest.ID) {
+			latest = link
+		}
+	}
+	if latest == nil {
+		return nil, nil
+	}
+	copied := *latest
+	return &copied, nil
+}
+
+func (m *mockMagicLinkRepository) CountForUserSince(userID uint, since time.Time) (int64, error) {
+	var count int64
+	for _, link := range m.links {
+		if link.UserID == userID && !link.CreatedAt.Before(since) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *mockMagicLinkRepository) CountForIPSince(clientIP string, since time.Time) (int64, error) {
+	var count int64
+	for _, link := range m.links {
+		if link.ClientIP == clientIP && !link.CreatedAt.Before(since) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *mockMagicLinkRepository) RecordFailure(id uint) (int, error) {
+	link, exists := m.links[id]
+	if !exists {
+		return 0, nil
+	}
+	link.Attempts++
+	return link.Attempts, nil
+}
+
+func (m *mockMagicLinkRepository) MarkUsed(id uint) (bool, error) {
+	link, exists := m.links[id]
+	if !exists || link.UsedAt != nil {
+		return false, nil
+	}
+	now := time.Now()
+	link.UsedAt = &now
+	return true, nil
+}
+
+func (m *mockMagicLinkRepository) InvalidateForUser(userID uint) error {
+	now := time.Now()
+	for _, link := range m.links {
+		if link.UserID == userID && link.UsedAt == nil {
+			link.UsedAt = &now
+		}
+	}
+	return nil
+}
+
+var (
+	magicLinkTokenPattern = regexp.MustCompile(`token=([A-Za-z0-9_%-]+)`)
+	magicLinkCodePattern  = regexp.MustCompile(`code (\d{6})`)
+)
+
+// sendMagicLink requests a magic link for the test user and returns the token
+// and code from the email
+func sendMagicLink(t *testing.T, service *authService) (string, string) {
+	t.Helper()
+	if err := service.magicLinks.Send("test@example.com", testRequest); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	messages := magicLinkEmails(t, service)
+	if len(messages) == 0 {
+		t.Fatal("Send() mailed no magic link")
+	}
+	message := messages[len(messages)-1]
+	tokenMatch := magicLinkTokenPattern.FindStringSubmatch(message)
+	codeMatch := magicLinkCodePattern.FindStringSubmatch(message)
+	if tokenMatch == nil || codeMatch == nil {
+		t.Fatalf("magic link email has no link or code: %q", message)
+	}
+	linkToken, _ := url.QueryUnescape(tokenMatch[1])
+	return linkToken, codeMatch[1]
+}
+
+// magicLinkEmails returns the magic link emails mailed to the test user
+func magicLinkEmails(t *testing.T, service *authService) []string {
+	t.Helper()
+	messages, err := service.magicLinks.(*magicLinkService).mailer.(*mailer.FileMailer).Messages("test@example.com")
+	if err != nil {
+		t.Fatalf("Messages() error = %v", err)
+	}
+	return messages
+}
+
+func TestLoginWithMagicLink(t *testing.T) {
+	tests := []struct {
+		name         string
+		loginMethods string
+		// redeem sends magic links and returns the token, email and code to log in with
+		redeem  func(t *testing.T, service *authService) (string, string, string)
+		wantErr errors.ErrorType
+	}{
+		{
+			name: "link",
+			redeem: func(t *testing.T, service *authService) (string, string, string) {
+				linkToken, _ := sendMagicLink(t, service)
+				return linkToken, "", ""
+			},
+		},
+		{
+			name: "code",
+			redeem: func(t *testing.T, service *authService) (string, string, string) {
+				_, code := sendMagicLink(t, service)
+				return "", "test@example.com", code
+			},
+		},
+		{
+			name: "wrong link",
+			redeem: func(t *testing.T, service *authService) (string, string, string) {
+				sendMagicLink(t, service)
+				return "wrong-token", "", ""
+			},
+			wantErr: errors.Unauthorized,
+		},
+		{
+			name: "code for another email",
+			redeem: func(t *testing.T, service *authService) (string, string, string) {
+				_, code := sendMagicLink(t, service)
+				return "", "nobody@example.com", code
+			},
+			wantErr: errors.Unauthorized,
+		},
+		{
+			name: "used link",
+			redeem: func(t *testing.T, service *authService) (string, string, string) {
+				linkToken, _ := sendMagicLink(t, service)
+				if _, err := service.LoginWithMagicLink(linkToken, "", "", testRequest); err != nil {
+					t.Fatalf("LoginWithMagicLink() error = %v", err)
+				}
+				return linkToken, "", ""
+			},
+			wantErr: errors.Unauthorized,
+		},
+		{
+			// Redeeming a link invalidates the links sent before it
+			name: "older link after a newer one was used",
+			redeem: func(t *testing.T, service *authService) (string, string, string) {
+				older, _ := sendMagicLink(t, service)
+				newer, _ := sendMagicLink(t, service)
+				if _, err := service.LoginWithMagicLink(newer, "", "", testRequest); err != nil {
+					t.Fatalf("LoginWithMagicLink() error = %v", err)
+				}
+				return older, "", ""
+			},
+			wantErr: errors.Unauthorized,
+		},
+		{
+			name: "expired link",
+			redeem: func(t *testing.T, service *authService) (string, string, string) {
+				linkToken, _ := sendMagicLink(t, service)
+				service.magicLinks.(*magicLinkService).now = func() time.Time {
+					return time.Now().Add(11 * time.Minute)
+				}
+				return linkToken, "", ""
+			},
+			wantErr: errors.Unauthorized,
+		},
+		{
+			name:         "password login only",
+			loginMethods: config.LoginMethodPassword,
+			redeem: func(t *testing.T, service *authService) (string, string, string) {
+				return "token", "", ""
+			},
+			wantErr: errors.Forbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loginMethods := tt.loginMethods
+			if loginMethods == "" {
+				loginMethods = config.LoginMethodPasswordless
+			}
+			service, _, _ := newTestAuthService(t, config.AuthConfig{LoginMethods: loginMethods})
+			linkToken, email, code := tt.redeem(t, service)
+
+			result, err := service.LoginWithMagicLink(linkToken, email, code, testRequest)
+			if tt.wantErr != "" {
+				assertErrorType(t, "LoginWithMagicLink()", err, tt.wantErr)
+				return
+			}
+			if err != nil || result.Token == nil {
+				t.Fatalf("LoginWithMagicLink() = %+v, %v, want tokens", result, err)
+			}
+			if _, err := service.Authenticate(result.Token.AccessToken, testClientIP); err != nil {
+				t.Errorf("Authenticate() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestRedeemMagicLinkCode(t *testing.T) {
+	tests := []struct {
+		name          string
+		wrongAttempts int
+		wantErr       bool
+	}{
+		{name: "first attempt"},
+		{name: "after two wrong codes", wrongAttempts: 2},
+		{name: "after three wrong codes", wrongAttempts: 3, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, _, _ := newTestAuthService(t, config.AuthConfig{LoginMethods: config.LoginMethodPasswordless})
+			_, code := sendMagicLink(t, service)
+			wrong := "000000"
+			if code == wrong {
+				wrong = "111111"
+			}
+
+			// Redeem directly, the login throttle would back off after the first failure
+			for i := 0; i < tt.wrongAttempts; i++ {
+				_, err := service.magicLinks.Redeem("", "test@example.com", wrong)
+				assertUnauthorized(t, err)
+			}
+
+			user, err := service.magicLinks.Redeem("", "test@example.com", code)
+			if tt.wantErr {
+				assertUnauthorized(t, err)
+				return
+			}
+			if err != nil || user.ID != 1 {
+				t.Errorf("Redeem() = %v, %v, want user 1", user, err)
+			}
+		})
+	}
+}
+
+func TestSendMagicLink(t *testing.T) {
+	tests := []struct {
+		name         string
+		loginMethods string
+		email        string
+		sends        int
+		wantEmails   int
+		wantErr      errors.ErrorType
+	}{
+		{name: "known email", email: "test@example.com", sends: 1, wantEmails: 1},
+		{name: "rate limited", email: "test@example.com", sends: 5, wantEmails: 3},
+		{name: "password and passwordless login", loginMethods: config.LoginMethodBoth, email: "test@example.com", sends: 1, wantEmails: 1},
+		// Unknown emails are not revealed
+		{name: "unknown email", email: "nobody@example.com", sends: 1},
+		{name: "invalid email", email: "not-an-email", sends: 1, wantErr: errors.InvalidEmail},
+		{name: "password login only", loginMethods: config.LoginMethodPassword, email: "test@example.com", sends: 1, wantErr: errors.Forbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loginMethods := tt.loginMethods
+			if loginMethods == "" {
+				loginMethods = config.LoginMethodPasswordless
+			}
+			service, _, _ := newTestAuthService(t, config.AuthConfig{LoginMethods: loginMethods})
+
+			for i := 0; i < tt.sends; i++ {
+				err := service.magicLinks.Send(tt.email, testRequest)
+				if tt.wantErr != "" {
+					assertErrorType(t, "Send()", err, tt.wantErr)
+				} else if err != nil {
+					t.Fatalf("Send() error = %v", err)
+				}
+			}
+
+			if got := len(magicLinkEmails(t, service)); got != tt.wantEmails {
+				t.Errorf("sent %d emails, want %d", got, tt.wantEmails)
+			}
+			if links := service.magicLinks.(*magicLinkService).links.(*mockMagicLinkRepository).links; len(links) != tt.wantEmails {
+				t.Errorf("created %d links, want %d", len(links), tt.wantEmails)
+			}
+		})
+	}
+}
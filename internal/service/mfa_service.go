@@ -0,0 +1,329 @@
+package service
+
+import (
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/errors"
+	"UserRESTfulApi/pkg/config"
+	"UserRESTfulApi/pkg/secrets"
+	"UserRESTfulApi/pkg/token"
+	"UserRESTfulApi/pkg/totp"
+	"crypto/rand"
+	"encoding/base32"
+	"strings"
+	"time"
+)
+
+// qrCodeSize is the width and height of enrollment QR codes in pixels
+const qrCodeSize = 256
+
+// recoveryCodeBytes is the randomness of a recovery code, encoded as 16 base32 characters
+const recoveryCodeBytes = 10
+
+type mfaService struct {
+	repo          domain.MFARepository
+	refreshTokens domain.RefreshTokenRepository
+	cipher        *secrets.Cipher
+	issuer        string
+	challengeTTL  time.Duration
+	maxAttempts   int
+	skew          int
+	recoveryCodes int
+	now           func() time.Time
+}
+
+// NewMFAService creates a new TOTP multi-factor authentication service.
+// Secrets are encrypted with the cipher before they are stored.
+func NewMFAService(repo domain.MFARepository, refreshTokens domain.RefreshTokenRepository, cipher *secrets.Cipher, cfg config.AuthConfig) domain.MFAService {
+	return &mfaService{
+		repo:          repo,
+		refreshTokens: refreshTokens,
+		cipher:        cipher,
+		issuer:        cfg.MFAIssuer,
+		challengeTTL:  cfg.MFAChallengeTTL,
+		maxAttempts:   cfg.MFAMaxAttempts,
+		skew:          cfg.MFASkew,
+		recoveryCodes: cfg.MFARecoveryCodes,
+		now:           time.Now,
+	}
+}
+
+// Status describes the user's enrollment and remaining recovery codes
+func (s *mfaService) Status(userID uint) (*domain.MFAStatus, error) {
+	factor, err := s.repo.GetFactor(userID)
+	if err != nil {
+		return nil, err
+	}
+	if factor == nil || factor.ConfirmedAt == nil {
+		return &domain.MFAStatus{}, nil
+	}
+
+	remaining, err := s.repo.CountRecoveryCodes(userID)
+	if err != nil {
+		return nil, err
+	}
+	return &domain.MFAStatus{Enabled: true, ConfirmedAt: factor.ConfirmedAt, RecoveryCodesRemaining: remaining}, nil
+}
+
+// Enroll generates a new secret for the user and returns it together with
+// the otpauth:// URI and a QR code of it. MFA stays disabled until Confirm.
+func (s *mfaService) Enroll(user *domain.User) (*domain.MFAEnrollment, error) {
+	factor, err := s.repo.GetFactor(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if factor != nil && factor.ConfirmedAt != nil {
+		return nil, errors.ConflictError("MFA is already enabled")
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, errors.InternalServerError(err)
+	}
+	encrypted, err := s.cipher.Encrypt(secret)
+	if err != nil {
+		return nil, errors.InternalServerError(err)
+	}
+	if err := s.repo.SaveFactor(&domain.MFAFactor{UserID: user.ID, Secret: encrypted}); err != nil {
+		return nil, err
+	}
+
+	uri := totp.URI(s.issuer, user.Email, secret)
+	qrCode, err := totp.QRCode(uri, qrCodeSize)
+	if err != nil {
+		return nil, errors.InternalServerError(err)
+	}
+	return &domain.MFAEnrollment{Secret: secret, URI: uri, QRCode: qrCode}, nil
+}
+
+// Confirm enables MFA once the user enters a valid code from the enrolled
+// authenticator. Refresh tokens issued before are revoked, so every remaining
+// session has passed the second factor.
+func (s *mfaService) Confirm(userID uint, code string) ([]string, error) {
+	factor, err := s.repo.GetFactor(userID)
+	if err != nil {
+		return nil, err
+	}
+	if factor == nil {
+		return nil, errors.InvalidInputError("code", "start MFA enrollment first")
+	}
+	if factor.ConfirmedAt != nil {
+		return nil, errors.ConflictError("MFA is already enabled")
+	}
+
+	secret, err := s.cipher.Decrypt(factor.Secret)
+	if err != nil {
+		return nil, errors.InternalServerError(err)
+	}
+	step, ok := totp.Validate(secret, code, s.now(), s.skew)
+	if !ok {
+		return nil, errors.InvalidInputError("code", "invalid MFA code")
+	}
+
+	confirmed, err := s.repo.ConfirmFactor(userID, step)
+	if err != nil {
+		return nil, err
+	}
+	if !confirmed {
+		return nil, errors.ConflictError("MFA is already enabled")
+	}
+
+	codes, err := s.newRecoveryCodes(userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.refreshTokens.RevokeAllForUser(userID); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Enabled reports whether the user has a confirmed factor
+func (s *mfaService) Enabled(userID uint) (bool, error) {
+	factor, err := s.repo.GetFactor(userID)
+	if err != nil {
+		return false, err
+	}
+	return factor != nil && factor.ConfirmedAt != nil, nil
+}
+
+// StartChallenge issues a single-use token for the second login step
+func (s *mfaService) StartChallenge(userID uint) (string, time.Time, error) {
+	challengeToken, err := token.NewOpaque()
+	if err != nil {
+		return "", time.Time{}, errors.InternalServerError(err)
+	}
+	expiresAt := s.now().Add(s.challengeTTL)
+	err = s.repo.CreateChallenge(&domain.MFAChallenge{
+		UserID:    userID,
+		TokenHash: token.HashOpaque(challengeToken),
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return challengeToken, expiresAt, nil
+}
+
+// VerifyChallenge redeems a challenge with a TOTP or recovery code. A
+// challenge is discarded after too many wrong codes, so a new login with the
+// password is needed to keep guessing.
+func (s *mfaService) VerifyChallenge(challengeToken, code string) (uint, error) {
+	return s.CompleteChallenge(challengeToken, func(userID uint) error {
+		return s.verifyCode(userID, code)
+	})
+}
+
+// ChallengeUser returns the user of an open challenge, for second factors
+// that need to know the user before they can be checked
+func (s *mfaService) ChallengeUser(challengeToken string) (uint, error) {
+	challenge, err := s.openChallenge(challengeToken)
+	if err != nil {
+		return 0, err
+	}
+	return challenge.UserID, nil
+}
+
+// CompleteChallenge redeems a challenge with a second factor checked by
+// verify. Unauthorized errors from verify count as wrong attempts.
+func (s *mfaService) CompleteChallenge(challengeToken string, verify func(userID uint) error) (uint, error) {
+	challenge, err := s.openChallenge(challengeToken)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := verify(challenge.UserID); err != nil {
+		appErr, ok := err.(*errors.AppError)
+		if !ok || appErr.Type != errors.Unauthorized {
+			return 0, err
+		}
+		attempts, recordErr := s.repo.RecordChallengeFailure(challenge.ID)
+		if recordErr != nil {
+			return 0, recordErr
+		}
+		if attempts >= s.maxAttempts {
+			if _, err := s.repo.MarkChallengeUsed(challenge.ID); err != nil {
+				return 0, err
+			}
+		}
+		return challenge.UserID, err
+	}
+
+	used, err := s.repo.MarkChallengeUsed(challenge.ID)
+	if err != nil {
+		return 0, err
+	}
+	if !used {
+		return 0, errors.UnauthorizedError("invalid MFA token")
+	}
+	return challenge.UserID, nil
+}
+
+// openChallenge returns the unused, unexpired challenge of the token
+func (s *mfaService) openChallenge(challengeToken string) (*domain.MFAChallenge, error) {
+	challenge, err := s.repo.GetChallengeByHash(token.HashOpaque(challengeToken))
+	if err != nil {
+		return nil, err
+	}
+	if challenge == nil || challenge.UsedAt != nil {
+		return nil, errors.UnauthorizedError("invalid MFA token")
+	}
+	if !s.now().Before(challenge.ExpiresAt) {
+		return nil, errors.UnauthorizedError("MFA token has expired")
+	}
+	return challenge, nil
+}
+
+// Reset removes the user's factor and recovery codes, for users who lost
+// their authenticator. Their sessions are ended as well.
+func (s *mfaService) Reset(actor *domain.Principal, userID uint) error {
+	if !actor.Can(domain.PermissionMFAManage) {
+		return errors.ForbiddenError("reset MFA")
+	}
+
+	deleted, err := s.repo.DeleteFactor(userID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return errors.NotFoundError("MFA enrollment of user", userID)
+	}
+	return s.refreshTokens.RevokeAllForUser(userID)
+}
+
+// verifyCode checks a 6 digit TOTP code or a recovery code of the user. Each
+// TOTP code and recovery code is accepted only once.
+func (s *mfaService) verifyCode(userID uint, code string) error {
+	factor, err := s.repo.GetFactor(userID)
+	if err != nil {
+		return err
+	}
+	if factor == nil || factor.ConfirmedAt == nil {
+		return errors.UnauthorizedError("MFA is not enabled")
+	}
+
+	code = normalizeCode(code)
+	if !isTOTPCode(code) {
+		used, err := s.repo.UseRecoveryCode(userID, token.HashOpaque(code))
+		if err != nil {
+			return err
+		}
+		if !used {
+			return errors.UnauthorizedError("invalid MFA code")
+		}
+		return nil
+	}
+
+	secret, err := s.cipher.Decrypt(factor.Secret)
+	if err != nil {
+		return errors.InternalServerError(err)
+	}
+	step, ok := totp.Validate(secret, code, s.now(), s.skew)
+	if !ok {
+		return errors.UnauthorizedError("invalid MFA code")
+	}
+	fresh, err := s.repo.UseStep(userID, step)
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return errors.UnauthorizedError("MFA code has already been used")
+	}
+	return nil
+}
+
+// newRecoveryCodes replaces the user's recovery codes and returns them formatted for display
+func (s *mfaService) newRecoveryCodes(userID uint) ([]string, error) {
+	codes := make([]string, 0, s.recoveryCodes)
+	hashes := make([]string, 0, s.recoveryCodes)
+	for i := 0; i < s.recoveryCodes; i++ {
+		b := make([]byte, recoveryCodeBytes)
+		if _, err := rand.Read(b); err != nil {
+			return nil, errors.InternalServerError(err)
+		}
+		code := strings.ToLower(base32.StdEncoding.EncodeToString(b))
+		codes = append(codes, code[:4]+"-"+code[4:8]+"-"+code[8:12]+"-"+code[12:])
+		hashes = append(hashes, token.HashOpaque(code))
+	}
+
+	if err := s.repo.ReplaceRecoveryCodes(userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// normalizeCode strips the separators users may type and lowercases recovery codes
+func normalizeCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(code)))
+}
+
+func isTOTPCode(code string) bool {
+	if len(code) != totp.Digits {
+		return false
+	}
+	for _, c := range code {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,421 @@
+package service
+
+import (
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/errors"
+	"UserRESTfulApi/pkg/config"
+	"UserRESTfulApi/pkg/secrets"
+	"UserRESTfulApi/pkg/totp"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Mock MFA repository for testing
+type mockMFARepository struct {
+	factors       map[uint]*domain.MFAFactor
+	recoveryCodes map[uint]map[string]bool // user ID -> code hash -> used
+	challenges    map[uint]*domain.MFAChallenge
+}
+
+func newMockMFARepository() *mockMFARepository {
+	return &mockMFARepository{
+		factors:       make(map[uint]*domain.MFAFactor),
+		recoveryCodes: make(map[uint]map[string]bool),
+		challenges:    make(map[uint]*domain.MFAChallenge),
+	}
+}
+
+func (m *mockMFARepository) GetFactor(userID uint) (*domain.MFAFactor, error) {
+	factor, exists := m.factors[userID]
+	if !exists {
+		return nil, nil
+	}
+	copied := *factor
+	return &copied, nil
+}
+
+func (m *mockMFARepository) SaveFactor(factor *domain.MFAFactor) error {
+	if existing, exists := m.factors[factor.UserID]; exists && existing.ConfirmedAt != nil {
+		return errors.ConflictError("MFA is already enabled")
+	}
+	copied := *factor
+	m.factors[factor.UserID] = &copied
+	return nil
+}
+
+func (m *mockMFARepository) ConfirmFactor(userID uint, step int64) (bool, error) {
+	factor, exists := m.factors[userID]
+	if !exists || factor.ConfirmedAt != nil {
+		return false, nil
+	}
+	now := time.Now()
+	factor.ConfirmedAt = &now
+	factor.LastUsedStep = step
+	return true, nil
+}
+
+func (m *mockMFARepository) UseStep(userID uint, step int64) (bool, error) {
+	factor, exists := m.factors[userID]
+	if !exists || factor.ConfirmedAt == nil || factor.LastUsedStep >= step {
+		return false, nil
+	}
+	factor.LastUsedStep = step
+	return true, nil
+}
+
+func (m *mockMFARepository) DeleteFactor(userID uint) (bool, error) {
+	_, exists := m.factors[userID]
+	delete(m.factors, userID)
+	delete(m.recoveryCodes, userID)
+	return exists, nil
+}
+
+func (m *mockMFARepository) ReplaceRecoveryCodes(userID uint, codeHashes []string) error {
+	m.recoveryCodes[userID] = make(map[string]bool)
+	for _, hash := range codeHashes {
+		m.recoveryCodes[userID][hash] = false
+	}
+	return nil
+}
+
+func (m *mockMFARepository) UseRecoveryCode(userID uint, codeHash string) (bool, error) {
+	used, exists := m.recoveryCodes[userID][codeHash]
+	if !exists || used {
+		return false, nil
+	}
+	m.recoveryCodes[userID][codeHash] = true
+	return true, nil
+}
+
+func (m *mockMFARepository) CountRecoveryCodes(userID uint) (int, error) {
+	count := 0
+	for _, used := range m.recoveryCodes[userID] {
+		if !used {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *mockMFARepository) CreateChallenge(challenge *domain.MFAChallenge) error {
+	challenge.ID = uint(len(m.challenges) + 1)
+	copied := *challenge
+	m.challenges[challenge.ID] = &copied
+	return nil
+}
+
+func (m *mockMFARepository) GetChallengeByHash(tokenHash string) (*domain.MFAChallenge, error) {
+	for _, challenge := range m.challenges {
+		if challenge.TokenHash == tokenHash {
+			copied := *challenge
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *mockMFARepository) RecordChallengeFailure(id uint) (int, error) {
+	m.challenges[id].Attempts++
+	return m.challenges[id].Attempts, nil
+}
+
+func (m *mockMFARepository) MarkChallengeUsed(id uint) (bool, error) {
+	challenge, exists := m.challenges[id]
+	if !exists || challenge.UsedAt != nil {
+		return false, nil
+	}
+	now := time.Now()
+	challenge.UsedAt = &now
+	return true, nil
+}
+
+var testMFACipher = func() *secrets.Cipher {
+	c, err := secrets.NewCipher(base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", 32))))
+	if err != nil {
+		panic(err)
+	}
+	return c
+}()
+
+// testMFAConfig holds the MFA settings shared by the MFA and auth service tests
+func testMFAConfig(cfg config.AuthConfig) config.AuthConfig {
+	cfg.MFAIssuer = "test"
+	cfg.MFAChallengeTTL = 5 * time.Minute
+	cfg.MFAMaxAttempts = 3
+	cfg.MFASkew = 1
+	cfg.MFARecoveryCodes = 4
+	cfg.MFARequiredRoles = []string{domain.RoleAdmin}
+	return cfg
+}
+
+// newTestMFAService returns an MFA service whose clock is advanced by the returned function
+func newTestMFAService() (*mfaService, *mockMFARepository, *mockRefreshTokenRepository, func(time.Duration)) {
+	repo := newMockMFARepository()
+	refreshTokens := newMockRefreshTokenRepository()
+	service := NewMFAService(repo, refreshTokens, testMFACipher, testMFAConfig(config.AuthConfig{})).(*mfaService)
+	now := time.Unix(1700000000, 0)
+	service.now = func() time.Time { return now }
+	return service, repo, refreshTokens, func(d time.Duration) { now = now.Add(d) }
+}
+
+// mfaCode returns the TOTP code of the secret at the service's clock
+func mfaCode(t *testing.T, service *mfaService, secret string) string {
+	t.Helper()
+	code, err := totp.Code(secret, totp.Step(service.now()))
+	if err != nil {
+		t.Fatalf("totp.Code() error = %v", err)
+	}
+	return code
+}
+
+// enableMFA enrolls and confirms MFA for the user and returns the secret and
+// recovery codes. The service's clock moves on by a time step, so the next
+// code is fresh.
+func enableMFA(t *testing.T, service *mfaService, user *domain.User) (string, []string) {
+	t.Helper()
+	enrollment, err := service.Enroll(user)
+	if err != nil {
+		t.Fatalf("Enroll() error = %v", err)
+	}
+	codes, err := service.Confirm(user.ID, mfaCode(t, service, enrollment.Secret))
+	if err != nil {
+		t.Fatalf("Confirm() error = %v", err)
+	}
+	now := service.now
+	service.now = func() time.Time { return now().Add(totp.Period * time.Second) }
+	return enrollment.Secret, codes
+}
+
+func TestMFAEnroll(t *testing.T) {
+	tests := []struct {
+		name     string
+		enrolled bool
+		enabled  bool
+		wantErr  errors.ErrorType
+	}{
+		{name: "new enrollment"},
+		{name: "unconfirmed enrollment", enrolled: true},
+		{name: "enabled MFA", enabled: true, wantErr: errors.Conflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, repo, _, _ := newTestMFAService()
+			user := &domain.User{ID: 1, Email: "test@example.com"}
+			if tt.enrolled {
+				if _, err := service.Enroll(user); err != nil {
+					t.Fatalf("Enroll() error = %v", err)
+				}
+			}
+			if tt.enabled {
+				enableMFA(t, service, user)
+			}
+
+			enrollment, err := service.Enroll(user)
+			if tt.wantErr != "" {
+				assertErrorType(t, "Enroll()", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				t.Fatalf("Enroll() error = %v", err)
+			}
+
+			if !strings.HasPrefix(enrollment.URI, "otpauth://totp/") || !strings.Contains(enrollment.URI, enrollment.Secret) {
+				t.Errorf("Enroll() URI = %s", enrollment.URI)
+			}
+			if len(enrollment.QRCode) == 0 {
+				t.Error("Enroll() returned no QR code")
+			}
+			if stored := repo.factors[1].Secret; stored == enrollment.Secret || strings.Contains(stored, enrollment.Secret) {
+				t.Error("Enroll() stored the secret unencrypted")
+			}
+			if enabled, _ := service.Enabled(1); enabled {
+				t.Error("Enabled() = true before confirmation")
+			}
+		})
+	}
+}
+
+func TestMFAConfirm(t *testing.T) {
+	tests := []struct {
+		name     string
+		enrolled bool
+		enabled  bool
+		code     string
+		wantErr  errors.ErrorType
+	}{
+		{name: "valid code", enrolled: true},
+		{name: "wrong code", enrolled: true, code: "000000", wantErr: errors.InvalidInput},
+		{name: "not enrolled", code: "000000", wantErr: errors.InvalidInput},
+		{name: "enabled MFA", enabled: true, wantErr: errors.Conflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, _, refreshTokens, _ := newTestMFAService()
+			user := &domain.User{ID: 1, Email: "test@example.com"}
+			code := tt.code
+			if tt.enrolled {
+				enrollment, err := service.Enroll(user)
+				if err != nil {
+					t.Fatalf("Enroll() error = %v", err)
+				}
+				if code == "" {
+					code = mfaCode(t, service, enrollment.Secret)
+				}
+			}
+			if tt.enabled {
+				secret, _ := enableMFA(t, service, user)
+				code = mfaCode(t, service, secret)
+			}
+			refreshTokens.Create(&domain.RefreshToken{UserID: 1, FamilyID: "password-only"})
+
+			codes, err := service.Confirm(1, code)
+			if tt.wantErr != "" {
+				assertErrorType(t, "Confirm()", err, tt.wantErr)
+				if enabled, _ := service.Enabled(1); enabled != tt.enabled {
+					t.Errorf("Enabled() = %v after a failed Confirm(), want %v", enabled, tt.enabled)
+				}
+				if refreshTokens.tokens[1].RevokedAt != nil {
+					t.Error("failed Confirm() revoked the refresh tokens")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Confirm() error = %v", err)
+			}
+
+			if len(codes) != 4 {
+				t.Errorf("Confirm() returned %d recovery codes, want 4", len(codes))
+			}
+			status, err := service.Status(1)
+			if err != nil || !status.Enabled || status.RecoveryCodesRemaining != 4 {
+				t.Errorf("Status() = %+v, %v, want enabled with 4 recovery codes", status, err)
+			}
+			if refreshTokens.tokens[1].RevokedAt == nil {
+				t.Error("Confirm() kept a refresh token issued before MFA was enabled")
+			}
+		})
+	}
+}
+
+func TestMFAVerifyChallenge(t *testing.T) {
+	tests := []struct {
+		name string
+		// recovery answers with the first recovery code instead of a TOTP code
+		recovery      bool
+		answered      bool
+		usedChallenge bool
+		wrong         int
+		challenge     string
+		later         time.Duration
+		wantErr       bool
+		wantRemaining int
+	}{
+		{name: "TOTP code", wantRemaining: 4},
+		{name: "recovery code", recovery: true, wantRemaining: 3},
+		{name: "wrong code before the right one", wrong: 1, wantRemaining: 4},
+		{name: "TOTP code used before", answered: true, wantErr: true},
+		{name: "recovery code used before", recovery: true, answered: true, wantErr: true},
+		{name: "used challenge", usedChallenge: true, wantErr: true},
+		{name: "too many wrong codes", wrong: 3, wantErr: true},
+		{name: "unknown challenge", challenge: "unknown", wantErr: true},
+		{name: "expired challenge", later: 6 * time.Minute, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, _, _, advance := newTestMFAService()
+			secret, recoveryCodes := enableMFA(t, service, &domain.User{ID: 1, Email: "test@example.com"})
+			code := mfaCode(t, service, secret)
+			if tt.recovery {
+				code = strings.ToUpper(recoveryCodes[0])
+			}
+
+			if tt.answered {
+				earlier, _, _ := service.StartChallenge(1)
+				if _, err := service.VerifyChallenge(earlier, code); err != nil {
+					t.Fatalf("VerifyChallenge() error = %v", err)
+				}
+			}
+			challenge, _, _ := service.StartChallenge(1)
+			if tt.usedChallenge {
+				if _, err := service.VerifyChallenge(challenge, code); err != nil {
+					t.Fatalf("VerifyChallenge() error = %v", err)
+				}
+				advance(totp.Period * time.Second)
+				code = mfaCode(t, service, secret)
+			}
+			for i := 0; i < tt.wrong; i++ {
+				userID, err := service.VerifyChallenge(challenge, "000000")
+				assertUnauthorized(t, err)
+				if userID != 1 {
+					t.Errorf("VerifyChallenge(wrong code) user = %d, want 1", userID)
+				}
+			}
+			if tt.challenge != "" {
+				challenge = tt.challenge
+			}
+			advance(tt.later)
+
+			userID, err := service.VerifyChallenge(challenge, code)
+			if tt.wantErr {
+				assertUnauthorized(t, err)
+				return
+			}
+			if err != nil || userID != 1 {
+				t.Fatalf("VerifyChallenge() = %d, %v, want user 1", userID, err)
+			}
+			if status, _ := service.Status(1); status.RecoveryCodesRemaining != tt.wantRemaining {
+				t.Errorf("RecoveryCodesRemaining = %d, want %d", status.RecoveryCodesRemaining, tt.wantRemaining)
+			}
+		})
+	}
+}
+
+func TestMFAReset(t *testing.T) {
+	tests := []struct {
+		name     string
+		actor    *domain.Principal
+		enrolled bool
+		wantErr  errors.ErrorType
+	}{
+		{name: "by an administrator", actor: domain.SystemPrincipal(), enrolled: true},
+		{name: "own enrollment", actor: domain.NewPrincipal(&domain.User{ID: 2}, nil), enrolled: true, wantErr: errors.Forbidden},
+		{name: "not enrolled", actor: domain.SystemPrincipal(), wantErr: errors.NotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, _, refreshTokens, _ := newTestMFAService()
+			if tt.enrolled {
+				enableMFA(t, service, &domain.User{ID: 2, Email: "user@example.com"})
+			}
+			refreshTokens.Create(&domain.RefreshToken{UserID: 2, FamilyID: "mfa-session", MFA: true})
+
+			err := service.Reset(tt.actor, 2)
+			if tt.wantErr != "" {
+				assertErrorType(t, "Reset()", err, tt.wantErr)
+				if enabled, _ := service.Enabled(2); enabled != tt.enrolled {
+					t.Errorf("Enabled() = %v after a failed Reset(), want %v", enabled, tt.enrolled)
+				}
+				if refreshTokens.tokens[1].RevokedAt != nil {
+					t.Error("failed Reset() ended a session of the user")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Reset() error = %v", err)
+			}
+
+			if enabled, _ := service.Enabled(2); enabled {
+				t.Error("Enabled() = true after reset")
+			}
+			if refreshTokens.tokens[1].RevokedAt == nil {
+				t.Error("Reset() kept a session of the user")
+			}
+		})
+	}
+}
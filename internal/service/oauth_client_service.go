@@ -0,0 +1,133 @@
+package service
+
+import (
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/errors"
+	"UserRESTfulApi/pkg/token"
+	"crypto/subtle"
+	"net/url"
+	"strings"
+)
+
+type oauthClientService struct {
+	repo   domain.OAuthClientRepository
+	grants domain.OAuthGrantRepository
+}
+
+// NewOAuthClientService creates a new OAuth client registration service
+func NewOAuthClientService(repo domain.OAuthClientRepository, grants domain.OAuthGrantRepository) domain.OAuthClientService {
+	return &oauthClientService{repo: repo, grants: grants}
+}
+
+// Create registers a client with a generated client ID. Confidential clients
+// get a generated secret of which only the hash is stored.
+func (s *oauthClientService) Create(actor *domain.Principal, client *domain.OAuthClient) (string, error) {
+	if !actor.Can(domain.PermissionOAuthManage) {
+		return "", errors.ForbiddenError("register OAuth clients")
+	}
+
+	client.Name = strings.TrimSpace(client.Name)
+	if client.Name == "" {
+		return "", errors.InvalidInputError("name", "name is required")
+	}
+	if len(client.RedirectURIs) == 0 {
+		return "", errors.InvalidInputError("redirect_uris", "at least one redirect URI is required")
+	}
+	for _, uri := range client.RedirectURIs {
+		if err := validateRedirectURI(uri); err != nil {
+			return "", err
+		}
+	}
+
+	clientID, err := token.NewOpaque()
+	if err != nil {
+		return "", errors.InternalServerError(err)
+	}
+	client.ClientID = clientID
+
+	secret := ""
+	if !client.Public {
+		secret, err = token.NewOpaque()
+		if err != nil {
+			return "", errors.InternalServerError(err)
+		}
+		client.SecretHash = token.HashOpaque(secret)
+	}
+
+	if err := s.repo.Create(client); err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// List returns every registered client
+func (s *oauthClientService) List(actor *domain.Principal) ([]*domain.OAuthClient, error) {
+	if !actor.Can(domain.PermissionOAuthManage) {
+		return nil, errors.ForbiddenError("list OAuth clients")
+	}
+	return s.repo.List()
+}
+
+// Delete removes a client and revokes every token issued to it
+func (s *oauthClientService) Delete(actor *domain.Principal, clientID string) error {
+	if !actor.Can(domain.PermissionOAuthManage) {
+		return errors.ForbiddenError("delete OAuth clients")
+	}
+
+	deleted, err := s.repo.Delete(clientID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return errors.NotFoundError("OAuth client", clientID)
+	}
+	return s.grants.RevokeClient(clientID)
+}
+
+// Authenticate verifies a client's credentials. Public clients present only
+// their client ID; confidential clients must present their secret.
+func (s *oauthClientService) Authenticate(clientID, secret string) (*domain.OAuthClient, error) {
+	client, err := s.repo.GetByClientID(clientID)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		return nil, domain.NewOAuthError(domain.OAuthInvalidClient, "client authentication failed")
+	}
+
+	if client.Public {
+		if secret != "" {
+			return nil, domain.NewOAuthError(domain.OAuthInvalidClient, "public clients have no secret")
+		}
+		return client, nil
+	}
+	if secret == "" || subtle.ConstantTimeCompare([]byte(token.HashOpaque(secret)), []byte(client.SecretHash)) != 1 {
+		return nil, domain.NewOAuthError(domain.OAuthInvalidClient, "client authentication failed")
+	}
+	return client, nil
+}
+
+// validateRedirectURI accepts absolute URIs without a fragment. Plain http is
+// only allowed for loopback addresses, where native apps receive the code.
+func validateRedirectURI(uri string) error {
+	u, err := url.Parse(uri)
+	if err != nil || !u.IsAbs() || u.Host == "" {
+		return errors.InvalidInputError("redirect_uris", "redirect URIs must be absolute URLs")
+	}
+	if u.Fragment != "" || strings.Contains(uri, "#") {
+		return errors.InvalidInputError("redirect_uris", "redirect URIs must not contain a fragment")
+	}
+
+	switch u.Scheme {
+	case "https":
+		return nil
+	case "http":
+		host := u.Hostname()
+		if host == "localhost" || host == "127.0.0.1" || host == "::1" {
+			return nil
+		}
+		return errors.InvalidInputError("redirect_uris", "plain http redirect URIs are only allowed for localhost")
+	default:
+		return errors.InvalidInputError("redirect_uris", "redirect URIs must use https")
+	}
+}
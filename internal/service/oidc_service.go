@@ -0,0 +1,467 @@
+package service
+
+import (
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/errors"
+	"UserRESTfulApi/pkg/config"
+	"UserRESTfulApi/pkg/token"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Grant types accepted by the token endpoint
+const (
+	grantAuthorizationCode = "authorization_code"
+	grantRefreshToken      = "refresh_token"
+)
+
+// pkceMethodS256 is the only accepted code challenge method; plain would
+// expose the verifier in the authorization request
+const pkceMethodS256 = "S256"
+
+type oidcService struct {
+	clients    domain.OAuthClientRepository
+	grants     domain.OAuthGrantRepository
+	users      domain.UserRepository
+	keys       domain.SigningKeyService
+	issuer     string
+	codeTTL    time.Duration
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+	idTokenTTL time.Duration
+	now        func() time.Time
+}
+
+// NewOIDCService creates a new OpenID Connect authorization server. It
+// implements the authorization code flow with mandatory PKCE for first-party
+// clients, so users are not asked for consent.
+func NewOIDCService(clients domain.OAuthClientRepository, grants domain.OAuthGrantRepository, users domain.UserRepository, keys domain.SigningKeyService, cfg config.OIDCConfig) domain.OIDCService {
+	return &oidcService{
+		clients:    clients,
+		grants:     grants,
+		users:      users,
+		keys:       keys,
+		issuer:     strings.TrimSuffix(cfg.Issuer, "/"),
+		codeTTL:    cfg.AuthorizationCodeTTL,
+		accessTTL:  cfg.AccessTokenTTL,
+		refreshTTL: cfg.RefreshTokenTTL,
+		idTokenTTL: cfg.IDTokenTTL,
+		now:        time.Now,
+	}
+}
+
+// Metadata describes the server for OpenID Connect discovery
+func (s *oidcService) Metadata() *domain.ProviderMetadata {
+	return &domain.ProviderMetadata{
+		Issuer:                            s.issuer,
+		AuthorizationEndpoint:             s.issuer + "/oauth2/authorize",
+		TokenEndpoint:                     s.issuer + "/oauth2/token",
+		UserInfoEndpoint:                  s.issuer + "/oauth2/userinfo",
+		JWKSURI:                           s.issuer + "/oauth2/jwks",
+		IntrospectionEndpoint:             s.issuer + "/oauth2/introspect",
+		RevocationEndpoint:                s.issuer + "/oauth2/revoke",
+		ScopesSupported:                   domain.SupportedScopes,
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{grantAuthorizationCode, grantRefreshToken},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{signingAlgorithm},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{pkceMethodS256},
+		ClaimsSupported:                   []string{"iss", "sub", "aud", "exp", "iat", "nonce", "email", "email_verified", "name"},
+	}
+}
+
+// ValidateAuthorization checks the client and redirect URI first; only once
+// both are trusted is the client returned, so other errors can be redirected
+func (s *oidcService) ValidateAuthorization(req *domain.AuthorizationRequest) (*domain.OAuthClient, error) {
+	if req.ClientID == "" {
+		return nil, domain.NewOAuthError(domain.OAuthInvalidRequest, "client_id is required")
+	}
+	client, err := s.clients.GetByClientID(req.ClientID)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		return nil, domain.NewOAuthError(domain.OAuthInvalidClient, "unknown client")
+	}
+	if !client.HasRedirectURI(req.RedirectURI) {
+		return nil, domain.NewOAuthError(domain.OAuthInvalidRequest, "redirect_uri is not registered for the client")
+	}
+
+	if req.ResponseType != "code" {
+		return client, domain.NewOAuthError(domain.OAuthUnsupportedResponseType, "response_type must be code")
+	}
+	if err := validateScopes(domain.ParseScopes(req.Scope)); err != nil {
+		return client, err
+	}
+	if req.CodeChallenge == "" {
+		return client, domain.NewOAuthError(domain.OAuthInvalidRequest, "code_challenge is required")
+	}
+	if req.CodeChallengeMethod != pkceMethodS256 {
+		return client, domain.NewOAuthError(domain.OAuthInvalidRequest, "code_challenge_method must be S256")
+	}
+	return client, nil
+}
+
+// Authorize issues an authorization code for the signed in user
+func (s *oidcService) Authorize(actor *domain.Principal, req *domain.AuthorizationRequest) (string, error) {
+	client, err := s.ValidateAuthorization(req)
+	if err != nil {
+		return "", err
+	}
+
+	code, err := token.NewOpaque()
+	if err != nil {
+		return "", errors.InternalServerError(err)
+	}
+	grantID, err := token.NewOpaque()
+	if err != nil {
+		return "", errors.InternalServerError(err)
+	}
+	err = s.grants.CreateCode(&domain.AuthorizationCode{
+		CodeHash:      token.HashOpaque(code),
+		GrantID:       grantID,
+		ClientID:      client.ClientID,
+		UserID:        actor.ID(),
+		RedirectURI:   req.RedirectURI,
+		Scope:         domain.ParseScopes(req.Scope).String(),
+		Nonce:         req.Nonce,
+		CodeChallenge: req.CodeChallenge,
+		ExpiresAt:     s.now().Add(s.codeTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return domain.AuthorizationResponseURI(req.RedirectURI, map[string]string{"code": code, "state": req.State}), nil
+}
+
+// Token exchanges an authorization code or a refresh token for new tokens
+func (s *oidcService) Token(client *domain.OAuthClient, req *domain.TokenRequest) (*domain.TokenResponse, error) {
+	switch req.GrantType {
+	case grantAuthorizationCode:
+		return s.exchangeCode(client, req)
+	case grantRefreshToken:
+		return s.refresh(client, req)
+	case "":
+		return nil, domain.NewOAuthError(domain.OAuthInvalidRequest, "grant_type is required")
+	default:
+		return nil, domain.NewOAuthError(domain.OAuthUnsupportedGrantType, "grant_type must be authorization_code or refresh_token")
+	}
+}
+
+// UserInfo returns the claims of the user an access token was issued for,
+// limited to the granted scopes
+func (s *oidcService) UserInfo(accessToken string) (*domain.UserInfo, error) {
+	stored, err := s.activeToken(accessToken)
+	if err != nil {
+		return nil, err
+	}
+	if stored == nil || stored.Kind != domain.OAuthAccessToken {
+		return nil, domain.NewOAuthError(domain.OAuthInvalidToken, "the access token is invalid or has expired")
+	}
+	scopes := domain.ParseScopes(stored.Scope)
+	if !scopes.Has(domain.ScopeOpenID) {
+		return nil, domain.NewOAuthError(domain.OAuthInsufficientScope, "the openid scope is required")
+	}
+
+	user, err := s.users.Get(stored.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || !user.IsActive() {
+		return nil, domain.NewOAuthError(domain.OAuthInvalidToken, "the access token is invalid or has expired")
+	}
+
+	info := &domain.UserInfo{Subject: subject(user)}
+	if scopes.Has(domain.ScopeEmail) {
+		verified := user.IsEmailVerified()
+		info.Email = user.Email
+		info.EmailVerified = &verified
+	}
+	if scopes.Has(domain.ScopeProfile) {
+		info.Name = user.Name
+	}
+	return info, nil
+}
+
+// Introspect describes a token to a resource server. Only confidential
+// clients may introspect; any token issued by this server can be described.
+func (s *oidcService) Introspect(client *domain.OAuthClient, value string) (*domain.Introspection, error) {
+	if client.Public {
+		return nil, domain.NewOAuthError(domain.OAuthUnauthorizedClient, "public clients cannot introspect tokens")
+	}
+
+	stored, err := s.activeToken(value)
+	if err != nil {
+		return nil, err
+	}
+	if stored == nil {
+		return &domain.Introspection{Active: false}, nil
+	}
+	user, err := s.users.Get(stored.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || !user.IsActive() {
+		return &domain.Introspection{Active: false}, nil
+	}
+
+	tokenType := "Bearer"
+	if stored.Kind == domain.OAuthRefreshToken {
+		tokenType = domain.OAuthRefreshToken
+	}
+	return &domain.Introspection{
+		Active:    true,
+		Scope:     stored.Scope,
+		ClientID:  stored.ClientID,
+		Username:  user.Email,
+		TokenType: tokenType,
+		ExpiresAt: stored.ExpiresAt.Unix(),
+		IssuedAt:  stored.CreatedAt.Unix(),
+		Subject:   subject(user),
+		Audience:  stored.ClientID,
+		Issuer:    s.issuer,
+	}, nil
+}
+
+// Revoke revokes a token of the client. Tokens that are unknown or belong to
+// another client are ignored, as RFC 7009 asks.
+func (s *oidcService) Revoke(client *domain.OAuthClient, value string) error {
+	stored, err := s.grants.GetTokenByHash(token.HashOpaque(value))
+	if err != nil {
+		return err
+	}
+	if stored == nil || stored.ClientID != client.ClientID {
+		return nil
+	}
+
+	if stored.Kind == domain.OAuthRefreshToken {
+		return s.grants.RevokeGrant(stored.GrantID)
+	}
+	_, err = s.grants.RevokeToken(stored.ID)
+	return err
+}
+
+// exchangeCode redeems an authorization code. A code presented twice points
+// to a stolen code, so every token issued from it is revoked.
+func (s *oidcService) exchangeCode(client *domain.OAuthClient, req *domain.TokenRequest) (*domain.TokenResponse, error) {
+	if req.Code == "" {
+		return nil, domain.NewOAuthError(domain.OAuthInvalidRequest, "code is required")
+	}
+	code, err := s.grants.GetCodeByHash(token.HashOpaque(req.Code))
+	if err != nil {
+		return nil, err
+	}
+	if code == nil || code.ClientID != client.ClientID {
+		return nil, domain.NewOAuthError(domain.OAuthInvalidGrant, "invalid authorization code")
+	}
+	if code.UsedAt != nil {
+		if err := s.grants.RevokeGrant(code.GrantID); err != nil {
+			return nil, err
+		}
+		return nil, domain.NewOAuthError(domain.OAuthInvalidGrant, "authorization code has already been used")
+	}
+	if !s.now().Before(code.ExpiresAt) {
+		return nil, domain.NewOAuthError(domain.OAuthInvalidGrant, "authorization code has expired")
+	}
+	if req.RedirectURI != code.RedirectURI {
+		return nil, domain.NewOAuthError(domain.OAuthInvalidGrant, "redirect_uri does not match the authorization request")
+	}
+	if !verifyCodeChallenge(req.CodeVerifier, code.CodeChallenge) {
+		return nil, domain.NewOAuthError(domain.OAuthInvalidGrant, "code_verifier does not match the code challenge")
+	}
+
+	used, err := s.grants.MarkCodeUsed(code.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !used {
+		if err := s.grants.RevokeGrant(code.GrantID); err != nil {
+			return nil, err
+		}
+		return nil, domain.NewOAuthError(domain.OAuthInvalidGrant, "authorization code has already been used")
+	}
+
+	user, err := s.users.Get(code.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || !user.IsActive() {
+		return nil, domain.NewOAuthError(domain.OAuthInvalidGrant, "the user no longer exists or is deactivated")
+	}
+	return s.issue(client, user, code.GrantID, domain.ParseScopes(code.Scope), code.Nonce)
+}
+
+// refresh rotates a refresh token. Presenting a revoked refresh token means it
+// was stolen or replayed, so every token of its grant is revoked.
+func (s *oidcService) refresh(client *domain.OAuthClient, req *domain.TokenRequest) (*domain.TokenResponse, error) {
+	if req.RefreshToken == "" {
+		return nil, domain.NewOAuthError(domain.OAuthInvalidRequest, "refresh_token is required")
+	}
+	stored, err := s.grants.GetTokenByHash(token.HashOpaque(req.RefreshToken))
+	if err != nil {
+		return nil, err
+	}
+	if stored == nil || stored.Kind != domain.OAuthRefreshToken || stored.ClientID != client.ClientID {
+		return nil, domain.NewOAuthError(domain.OAuthInvalidGrant, "invalid refresh token")
+	}
+	if stored.RevokedAt != nil {
+		if err := s.grants.RevokeGrant(stored.GrantID); err != nil {
+			return nil, err
+		}
+		return nil, domain.NewOAuthError(domain.OAuthInvalidGrant, "refresh token has been revoked")
+	}
+	if !s.now().Before(stored.ExpiresAt) {
+		return nil, domain.NewOAuthError(domain.OAuthInvalidGrant, "refresh token has expired")
+	}
+
+	// The scope may be narrowed but never widened
+	scopes := domain.ParseScopes(stored.Scope)
+	if req.Scope != "" {
+		requested := domain.ParseScopes(req.Scope)
+		for _, scope := range requested {
+			if !scopes.Has(scope) {
+				return nil, domain.NewOAuthError(domain.OAuthInvalidScope, "scope exceeds the original grant")
+			}
+		}
+		scopes = requested
+	}
+
+	revoked, err := s.grants.RevokeToken(stored.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !revoked {
+		if err := s.grants.RevokeGrant(stored.GrantID); err != nil {
+			return nil, err
+		}
+		return nil, domain.NewOAuthError(domain.OAuthInvalidGrant, "refresh token has been revoked")
+	}
+
+	user, err := s.users.Get(stored.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || !user.IsActive() {
+		return nil, domain.NewOAuthError(domain.OAuthInvalidGrant, "the user no longer exists or is deactivated")
+	}
+	return s.issue(client, user, stored.GrantID, scopes, "")
+}
+
+// issue creates the tokens of a grant: always an access token, a refresh
+// token for offline_access and an ID token for openid
+func (s *oidcService) issue(client *domain.OAuthClient, user *domain.User, grantID string, scopes domain.Scopes, nonce string) (*domain.TokenResponse, error) {
+	now := s.now()
+	accessToken, err := s.createToken(client, user, grantID, scopes, domain.OAuthAccessToken, now.Add(s.accessTTL))
+	if err != nil {
+		return nil, err
+	}
+	resp := &domain.TokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(s.accessTTL.Seconds()),
+		Scope:       scopes.String(),
+	}
+
+	if scopes.Has(domain.ScopeOfflineAccess) {
+		resp.RefreshToken, err = s.createToken(client, user, grantID, scopes, domain.OAuthRefreshToken, now.Add(s.refreshTTL))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if scopes.Has(domain.ScopeOpenID) {
+		claims := map[string]interface{}{
+			"iss": s.issuer,
+			"sub": subject(user),
+			"aud": client.ClientID,
+			"azp": client.ClientID,
+			"iat": now.Unix(),
+			"exp": now.Add(s.idTokenTTL).Unix(),
+		}
+		if nonce != "" {
+			claims["nonce"] = nonce
+		}
+		if scopes.Has(domain.ScopeEmail) {
+			claims["email"] = user.Email
+			claims["email_verified"] = user.IsEmailVerified()
+		}
+		if scopes.Has(domain.ScopeProfile) {
+			claims["name"] = user.Name
+		}
+		resp.IDToken, err = s.keys.Sign(claims)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+// createToken stores a new opaque token and returns its value
+func (s *oidcService) createToken(client *domain.OAuthClient, user *domain.User, grantID string, scopes domain.Scopes, kind string, expiresAt time.Time) (string, error) {
+	value, err := token.NewOpaque()
+	if err != nil {
+		return "", errors.InternalServerError(err)
+	}
+	err = s.grants.CreateToken(&domain.OAuthToken{
+		TokenHash: token.HashOpaque(value),
+		Kind:      kind,
+		GrantID:   grantID,
+		ClientID:  client.ClientID,
+		UserID:    user.ID,
+		Scope:     scopes.String(),
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return "", err
+	}
+	return value, nil
+}
+
+// activeToken looks up a token, returning nil when it is unknown, revoked or expired
+func (s *oidcService) activeToken(value string) (*domain.OAuthToken, error) {
+	if value == "" {
+		return nil, nil
+	}
+	stored, err := s.grants.GetTokenByHash(token.HashOpaque(value))
+	if err != nil {
+		return nil, err
+	}
+	if stored == nil || stored.RevokedAt != nil || !s.now().Before(stored.ExpiresAt) {
+		return nil, nil
+	}
+	return stored, nil
+}
+
+// validateScopes requires at least one scope, all of them supported
+func validateScopes(scopes domain.Scopes) error {
+	if len(scopes) == 0 {
+		return domain.NewOAuthError(domain.OAuthInvalidScope, "scope is required")
+	}
+	for _, scope := range scopes {
+		if !domain.Scopes(domain.SupportedScopes).Has(scope) {
+			return domain.NewOAuthError(domain.OAuthInvalidScope, "unsupported scope "+scope)
+		}
+	}
+	return nil
+}
+
+// verifyCodeChallenge checks the PKCE verifier against the S256 challenge (RFC 7636 section 4.6)
+func verifyCodeChallenge(verifier, challenge string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
+
+// subject is the sub claim of a user
+func subject(user *domain.User) string {
+	return strconv.FormatUint(uint64(user.ID), 10)
+}
@@ -0,0 +1,898 @@
+package service
+
+import (
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/errors"
+	"UserRESTfulApi/pkg/config"
+	"UserRESTfulApi/pkg/token"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"math/big"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Mock OAuth client repository for testing
+type mockOAuthClientRepository struct {
+	clients map[string]*domain.OAuthClient
+}
+
+func newMockOAuthClientRepository() *mockOAuthClientRepository {
+	return &mockOAuthClientRepository{clients: make(map[string]*domain.OAuthClient)}
+}
+
+func (m *mockOAuthClientRepository) Create(client *domain.OAuthClient) error {
+	client.ID = uint(len(m.clients) + 1)
+	copied := *client
+	m.clients[client.ClientID] = &copied
+	return nil
+}
+
+func (m *mockOAuthClientRepository) GetByClientID(clientID string) (*domain.OAuthClient, error) {
+	client, exists := m.clients[clientID]
+	if !exists {
+		return nil, nil
+	}
+	copied := *client
+	return &copied, nil
+}
+
+func (m *mockOAuthClientRepository) List() ([]*domain.OAuthClient, error) {
+	var clients []*domain.OAuthClient
+	for _, client := range m.clients {
+		clients = append(clients, client)
+	}
+	return clients, nil
+}
+
+func (m *mockOAuthClientRepository) Delete(clientID string) (bool, error) {
+	_, exists := m.clients[clientID]
+	delete(m.clients, clientID)
+	return exists, nil
+}
+
+// Mock OAuth grant repository for testing
+type mockOAuthGrantRepository struct {
+	codes  map[uint]*domain.AuthorizationCode
+	tokens map[uint]*domain.OAuthToken
+}
+
+func newMockOAuthGrantRepository() *mockOAuthGrantRepository {
+	return &mockOAuthGrantRepository{
+		codes:  make(map[uint]*domain.AuthorizationCode),
+		tokens: make(map[uint]*domain.OAuthToken),
+	}
+}
+
+func (m *mockOAuthGrantRepository) CreateCode(code *domain.AuthorizationCode) error {
+	code.ID = uint(len(m.codes) + 1)
+	copied := *code
+	m.codes[code.ID] = &copied
+	return nil
+}
+
+func (m *mockOAuthGrantRepository) GetCodeByHash(codeHash string) (*domain.AuthorizationCode, error) {
+	for _, code := range m.codes {
+		if code.CodeHash == codeHash {
+			copied := *code
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *mockOAuthGrantRepository) MarkCodeUsed(id uint) (bool, error) {
+	code, exists := m.codes[id]
+	if !exists || code.UsedAt != nil {
+		return false, nil
+	}
+	now := time.Now()
+	code.UsedAt = &now
+	return true, nil
+}
+
+func (m *mockOAuthGrantRepository) CreateToken(t *domain.OAuthToken) error {
+	t.ID = uint(len(m.tokens) + 1)
+	t.CreatedAt = time.Now()
+	copied := *t
+	m.tokens[t.ID] = &copied
+	return nil
+}
+
+func (m *mockOAuthGrantRepository) GetTokenByHash(tokenHash string) (*domain.OAuthToken, error) {
+	for _, t := range m.tokens {
+		if t.TokenHash == tokenHash {
+			copied := *t
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *mockOAuthGrantRepository) RevokeToken(id uint) (bool, error) {
+	t, exists := m.tokens[id]
+	if !exists || t.RevokedAt != nil {
+		return false, nil
+	}
+	now := time.Now()
+	t.RevokedAt = &now
+	return true, nil
+}
+
+func (m *mockOAuthGrantRepository) revokeWhere(match func(*domain.OAuthToken) bool) {
+	now := time.Now()
+	for _, t := range m.tokens {
+		if match(t) && t.RevokedAt == nil {
+			t.RevokedAt = &now
+		}
+	}
+}
+
+func (m *mockOAuthGrantRepository) RevokeGrant(grantID string) error {
+	m.revokeWhere(func(t *domain.OAuthToken) bool { return t.GrantID == grantID })
+	return nil
+}
+
+func (m *mockOAuthGrantRepository) RevokeClient(clientID string) error {
+	m.revokeWhere(func(t *domain.OAuthToken) bool { return t.ClientID == clientID })
+	return nil
+}
+
+// Mock signing key repository for testing
+type mockSigningKeyRepository struct {
+	keys  []*domain.SigningKey
+	clock *time.Time
+}
+
+func (m *mockSigningKeyRepository) List() ([]*domain.SigningKey, error) {
+	keys := append([]*domain.SigningKey(nil), m.keys...)
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.After(keys[j].CreatedAt) })
+	return keys, nil
+}
+
+func (m *mockSigningKeyRepository) Rotate(key *domain.SigningKey, staleBefore time.Time) (bool, error) {
+	for _, existing := range m.keys {
+		if existing.RetiredAt == nil && !existing.CreatedAt.Before(staleBefore) {
+			return false, nil
+		}
+	}
+	now := *m.clock
+	for _, existing := range m.keys {
+		if existing.RetiredAt == nil {
+			existing.RetiredAt = &now
+		}
+	}
+	key.CreatedAt = now
+	m.keys = append(m.keys, key)
+	return true, nil
+}
+
+func (m *mockSigningKeyRepository) DeleteRetiredBefore(before time.Time) error {
+	var kept []*domain.SigningKey
+	for _, key := range m.keys {
+		if key.RetiredAt == nil || !key.RetiredAt.Before(before) {
+			kept = append(kept, key)
+		}
+	}
+	m.keys = kept
+	return nil
+}
+
+const testRedirectURI = "https://app.example.com/callback"
+
+// newTestOIDCService creates an OpenID provider with the confidential client
+// App and the verified user test@example.com. The returned function advances
+// the clock of the provider and its signing keys.
+func newTestOIDCService(t *testing.T) (*oidcService, domain.OAuthClientService, *domain.OAuthClient, func(time.Duration)) {
+	t.Helper()
+	cfg := config.OIDCConfig{
+		Issuer:               "https://id.example.com",
+		KeyRotationInterval:  24 * time.Hour,
+		KeyRetention:         time.Hour,
+		AuthorizationCodeTTL: time.Minute,
+		AccessTokenTTL:       time.Hour,
+		RefreshTokenTTL:      24 * time.Hour,
+		IDTokenTTL:           time.Hour,
+	}
+	clock := time.Now()
+	grants := newMockOAuthGrantRepository()
+	clientRepo := newMockOAuthClientRepository()
+	users := newMockUserRepository()
+
+	keys := NewSigningKeyService(&mockSigningKeyRepository{clock: &clock}, testMFACipher, cfg).(*signingKeyService)
+	keys.now = func() time.Time { return clock }
+	clients := NewOAuthClientService(clientRepo, grants)
+	service := NewOIDCService(clientRepo, grants, users, keys, cfg).(*oidcService)
+	service.now = func() time.Time { return clock }
+
+	verified := time.Now()
+	users.Create(&domain.User{ID: 1, Email: "test@example.com", Name: "Test User", EmailVerifiedAt: &verified})
+
+	client := &domain.OAuthClient{Name: "App", RedirectURIs: []string{testRedirectURI}}
+	if _, err := clients.Create(domain.SystemPrincipal(), client); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	return service, clients, client, func(d time.Duration) { clock = clock.Add(d) }
+}
+
+// pkce returns a code verifier and its S256 challenge
+func pkce() (string, string) {
+	verifier := strings.Repeat("v", 43)
+	sum := sha256.Sum256([]byte(verifier))
+	return verifier, base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// authorizeCode runs the authorization request for user 1 and returns the code and verifier
+func authorizeCode(t *testing.T, service domain.OIDCService, client *domain.OAuthClient, scope string) (string, string) {
+	t.Helper()
+	verifier, challenge := pkce()
+	redirectTo, err := service.Authorize(domain.NewPrincipal(&domain.User{ID: 1}, nil), &domain.AuthorizationRequest{
+		ResponseType:        "code",
+		ClientID:            client.ClientID,
+		RedirectURI:         testRedirectURI,
+		Scope:               scope,
+		State:               "xyz",
+		Nonce:               "n-0S6",
+		CodeChallenge:       challenge,
+		CodeChallengeMethod: "S256",
+	})
+	if err != nil {
+		t.Fatalf("Authorize() error = %v", err)
+	}
+
+	u, _ := url.Parse(redirectTo)
+	if !strings.HasPrefix(redirectTo, testRedirectURI+"?") || u.Query().Get("state") != "xyz" {
+		t.Fatalf("Authorize() redirect = %s", redirectTo)
+	}
+	return u.Query().Get("code"), verifier
+}
+
+// exchangeCode authorizes the scope and redeems the code with its verifier
+func exchangeCode(t *testing.T, service domain.OIDCService, client *domain.OAuthClient, scope string) *domain.TokenResponse {
+	t.Helper()
+	code, verifier := authorizeCode(t, service, client, scope)
+	resp, err := service.Token(client, &domain.TokenRequest{
+		GrantType:    "authorization_code",
+		Code:         code,
+		RedirectURI:  testRedirectURI,
+		CodeVerifier: verifier,
+	})
+	if err != nil {
+		t.Fatalf("Token() error = %v", err)
+	}
+	return resp
+}
+
+func assertOAuthError(t *testing.T, name string, err error, want string) {
+	t.Helper()
+	oauthErr, ok := err.(*domain.OAuthError)
+	if !ok || oauthErr.Code != want {
+		t.Errorf("%s error = %v, want %s", name, err, want)
+	}
+}
+
+// verifyIDToken checks the ID token signature against the published JWKS
+func verifyIDToken(t *testing.T, keys domain.SigningKeyService, idToken string) jwt.MapClaims {
+	t.Helper()
+	set, err := keys.JWKS()
+	if err != nil {
+		t.Fatalf("JWKS() error = %v", err)
+	}
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(idToken, claims, func(parsed *jwt.Token) (interface{}, error) {
+		for _, key := range set.Keys {
+			if key.KeyID == parsed.Header["kid"] {
+				n, _ := base64.RawURLEncoding.DecodeString(key.Modulus)
+				return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: 65537}, nil
+			}
+		}
+		return nil, jwt.ErrTokenUnverifiable
+	}, jwt.WithValidMethods([]string{"RS256"}))
+	if err != nil {
+		t.Fatalf("ID token does not verify: %v", err)
+	}
+	return claims
+}
+
+// activePrivateKey returns the decrypted active signing key
+func activePrivateKey(t *testing.T, keys *signingKeyService) *rsa.PrivateKey {
+	t.Helper()
+	active, err := keys.activeKey()
+	if err != nil {
+		t.Fatalf("activeKey() error = %v", err)
+	}
+	key, err := keys.privateKey(active)
+	if err != nil {
+		t.Fatalf("privateKey() error = %v", err)
+	}
+	return key
+}
+
+func TestOAuthClientCreate(t *testing.T) {
+	tests := []struct {
+		name       string
+		actor      *domain.Principal
+		client     *domain.OAuthClient
+		wantSecret bool
+		wantErr    errors.ErrorType
+	}{
+		{name: "confidential client", actor: domain.SystemPrincipal(), client: &domain.OAuthClient{Name: "App", RedirectURIs: []string{testRedirectURI}}, wantSecret: true},
+		{name: "public client", actor: domain.SystemPrincipal(), client: &domain.OAuthClient{Name: "CLI", RedirectURIs: []string{"http://127.0.0.1:8400/callback"}, Public: true}},
+		{name: "relative redirect URI", actor: domain.SystemPrincipal(), client: &domain.OAuthClient{Name: "App", RedirectURIs: []string{"/callback"}}, wantErr: errors.InvalidInput},
+		{name: "plain http redirect URI", actor: domain.SystemPrincipal(), client: &domain.OAuthClient{Name: "App", RedirectURIs: []string{"http://app.example.com/callback"}}, wantErr: errors.InvalidInput},
+		{name: "redirect URI with a fragment", actor: domain.SystemPrincipal(), client: &domain.OAuthClient{Name: "App", RedirectURIs: []string{"https://app.example.com/callback#frag"}}, wantErr: errors.InvalidInput},
+		{name: "redirect URI with another scheme", actor: domain.SystemPrincipal(), client: &domain.OAuthClient{Name: "App", RedirectURIs: []string{"ftp://app.example.com"}}, wantErr: errors.InvalidInput},
+		{name: "without the OAuth permission", actor: domain.NewPrincipal(&domain.User{ID: 2}, nil), client: &domain.OAuthClient{Name: "App", RedirectURIs: []string{testRedirectURI}}, wantErr: errors.Forbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newMockOAuthClientRepository()
+			clients := NewOAuthClientService(repo, newMockOAuthGrantRepository())
+
+			secret, err := clients.Create(tt.actor, tt.client)
+			if tt.wantErr != "" {
+				assertErrorType(t, "Create()", err, tt.wantErr)
+				if len(repo.clients) != 0 {
+					t.Errorf("failed Create() stored a client")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+
+			if (secret != "") != tt.wantSecret {
+				t.Errorf("Create() secret = %q, want one = %v", secret, tt.wantSecret)
+			}
+			if tt.wantSecret && tt.client.SecretHash != token.HashOpaque(secret) {
+				t.Error("Create() did not store the secret as its hash")
+			}
+		})
+	}
+}
+
+func TestOAuthClientAuthenticate(t *testing.T) {
+	tests := []struct {
+		name     string
+		public   bool
+		clientID string
+		secret   func(secret string) string
+		wantErr  bool
+	}{
+		{name: "confidential client", secret: func(secret string) string { return secret }},
+		{name: "wrong secret", secret: func(secret string) string { return "wrong" }, wantErr: true},
+		{name: "no secret", secret: func(secret string) string { return "" }, wantErr: true},
+		{name: "unknown client", clientID: "unknown", secret: func(secret string) string { return secret }, wantErr: true},
+		{name: "public client", public: true, secret: func(secret string) string { return "" }},
+		{name: "public client with a secret", public: true, secret: func(secret string) string { return "secret" }, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clients := NewOAuthClientService(newMockOAuthClientRepository(), newMockOAuthGrantRepository())
+			client := &domain.OAuthClient{Name: "App", RedirectURIs: []string{testRedirectURI}, Public: tt.public}
+			secret, err := clients.Create(domain.SystemPrincipal(), client)
+			if err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+			clientID := client.ClientID
+			if tt.clientID != "" {
+				clientID = tt.clientID
+			}
+
+			authenticated, err := clients.Authenticate(clientID, tt.secret(secret))
+			if tt.wantErr {
+				assertOAuthError(t, "Authenticate()", err, domain.OAuthInvalidClient)
+				return
+			}
+			if err != nil || authenticated.ClientID != client.ClientID {
+				t.Errorf("Authenticate() = %v, %v, want client %s", authenticated, err, client.ClientID)
+			}
+		})
+	}
+}
+
+func TestOIDCValidateAuthorization(t *testing.T) {
+	tests := []struct {
+		name       string
+		modify     func(*domain.AuthorizationRequest)
+		want       string
+		redirected bool
+	}{
+		{"valid request", func(r *domain.AuthorizationRequest) {}, "", true},
+		{"no client", func(r *domain.AuthorizationRequest) { r.ClientID = "" }, domain.OAuthInvalidRequest, false},
+		{"unknown client", func(r *domain.AuthorizationRequest) { r.ClientID = "unknown" }, domain.OAuthInvalidClient, false},
+		{"unregistered redirect URI", func(r *domain.AuthorizationRequest) { r.RedirectURI = "https://evil.example.com" }, domain.OAuthInvalidRequest, false},
+		{"implicit flow", func(r *domain.AuthorizationRequest) { r.ResponseType = "token" }, domain.OAuthUnsupportedResponseType, true},
+		{"unknown scope", func(r *domain.AuthorizationRequest) { r.Scope = "openid admin" }, domain.OAuthInvalidScope, true},
+		{"missing PKCE", func(r *domain.AuthorizationRequest) { r.CodeChallenge = "" }, domain.OAuthInvalidRequest, true},
+		{"plain PKCE", func(r *domain.AuthorizationRequest) { r.CodeChallengeMethod = "plain" }, domain.OAuthInvalidRequest, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, _, client, _ := newTestOIDCService(t)
+			_, challenge := pkce()
+			req := domain.AuthorizationRequest{
+				ResponseType:        "code",
+				ClientID:            client.ClientID,
+				RedirectURI:         testRedirectURI,
+				Scope:               "openid email",
+				CodeChallenge:       challenge,
+				CodeChallengeMethod: "S256",
+			}
+			tt.modify(&req)
+
+			validated, err := service.ValidateAuthorization(&req)
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("ValidateAuthorization() error = %v", err)
+				}
+			} else {
+				assertOAuthError(t, "ValidateAuthorization()", err, tt.want)
+			}
+			if (validated != nil) != tt.redirected {
+				t.Errorf("ValidateAuthorization() client = %v, want redirected = %v", validated, tt.redirected)
+			}
+		})
+	}
+}
+
+func TestOIDCAuthorizationCodeGrant(t *testing.T) {
+	tests := []struct {
+		name  string
+		scope string
+		// change runs between the authorization and the token request
+		change           func(t *testing.T, service *oidcService, clients domain.OAuthClientService, req *domain.TokenRequest, advance func(time.Duration)) *domain.OAuthClient
+		wantErr          string
+		wantIDToken      bool
+		wantRefreshToken bool
+	}{
+		{name: "openid scopes", scope: "openid email profile", wantIDToken: true},
+		{name: "offline access", scope: "openid offline_access", wantIDToken: true, wantRefreshToken: true},
+		{name: "without openid", scope: "email"},
+		{
+			name:  "wrong verifier",
+			scope: "openid",
+			change: func(t *testing.T, service *oidcService, clients domain.OAuthClientService, req *domain.TokenRequest, advance func(time.Duration)) *domain.OAuthClient {
+				req.CodeVerifier = strings.Repeat("w", 43)
+				return nil
+			},
+			wantErr: domain.OAuthInvalidGrant,
+		},
+		{
+			name:  "other redirect URI",
+			scope: "openid",
+			change: func(t *testing.T, service *oidcService, clients domain.OAuthClientService, req *domain.TokenRequest, advance func(time.Duration)) *domain.OAuthClient {
+				req.RedirectURI = "https://app.example.com/other"
+				return nil
+			},
+			wantErr: domain.OAuthInvalidGrant,
+		},
+		{
+			name:  "expired code",
+			scope: "openid",
+			change: func(t *testing.T, service *oidcService, clients domain.OAuthClientService, req *domain.TokenRequest, advance func(time.Duration)) *domain.OAuthClient {
+				advance(2 * time.Minute)
+				return nil
+			},
+			wantErr: domain.OAuthInvalidGrant,
+		},
+		{
+			name:  "code of another client",
+			scope: "openid",
+			change: func(t *testing.T, service *oidcService, clients domain.OAuthClientService, req *domain.TokenRequest, advance func(time.Duration)) *domain.OAuthClient {
+				other := &domain.OAuthClient{Name: "Other", RedirectURIs: []string{testRedirectURI}}
+				clients.Create(domain.SystemPrincipal(), other)
+				return other
+			},
+			wantErr: domain.OAuthInvalidGrant,
+		},
+		{
+			name:  "deactivated user",
+			scope: "openid",
+			change: func(t *testing.T, service *oidcService, clients domain.OAuthClientService, req *domain.TokenRequest, advance func(time.Duration)) *domain.OAuthClient {
+				deactivatedAt := time.Now()
+				service.users.(*mockUserRepository).users[1].DeactivatedAt = &deactivatedAt
+				return nil
+			},
+			wantErr: domain.OAuthInvalidGrant,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, clients, client, advance := newTestOIDCService(t)
+			code, verifier := authorizeCode(t, service, client, tt.scope)
+			req := &domain.TokenRequest{GrantType: "authorization_code", Code: code, RedirectURI: testRedirectURI, CodeVerifier: verifier}
+			tokenClient := client
+			if tt.change != nil {
+				if other := tt.change(t, service, clients, req, advance); other != nil {
+					tokenClient = other
+				}
+			}
+
+			resp, err := service.Token(tokenClient, req)
+			if tt.wantErr != "" {
+				assertOAuthError(t, "Token()", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				t.Fatalf("Token() error = %v", err)
+			}
+
+			if resp.AccessToken == "" || resp.TokenType != "Bearer" || (resp.IDToken != "") != tt.wantIDToken || (resp.RefreshToken != "") != tt.wantRefreshToken {
+				t.Fatalf("Token() = %+v, want ID token = %v, refresh token = %v", resp, tt.wantIDToken, tt.wantRefreshToken)
+			}
+			if !tt.wantIDToken {
+				return
+			}
+			claims := verifyIDToken(t, service.keys, resp.IDToken)
+			want := map[string]interface{}{
+				"iss":   "https://id.example.com",
+				"sub":   "1",
+				"aud":   client.ClientID,
+				"nonce": "n-0S6",
+			}
+			if strings.Contains(tt.scope, "email") {
+				want["email"], want["email_verified"] = "test@example.com", true
+			}
+			if strings.Contains(tt.scope, "profile") {
+				want["name"] = "Test User"
+			}
+			for claim, value := range want {
+				if claims[claim] != value {
+					t.Errorf("ID token %s = %v, want %v", claim, claims[claim], value)
+				}
+			}
+		})
+	}
+}
+
+func TestOIDCCodeReuseRevokesGrant(t *testing.T) {
+	service, _, client, _ := newTestOIDCService(t)
+	code, verifier := authorizeCode(t, service, client, "openid")
+	req := &domain.TokenRequest{GrantType: "authorization_code", Code: code, RedirectURI: testRedirectURI, CodeVerifier: verifier}
+	first, err := service.Token(client, req)
+	if err != nil {
+		t.Fatalf("Token() error = %v", err)
+	}
+
+	_, err = service.Token(client, req)
+	assertOAuthError(t, "Token(reused code)", err, domain.OAuthInvalidGrant)
+	_, err = service.UserInfo(first.AccessToken)
+	assertOAuthError(t, "UserInfo(revoked)", err, domain.OAuthInvalidToken)
+}
+
+func TestOIDCUserInfo(t *testing.T) {
+	tests := []struct {
+		name  string
+		scope string
+		// accessToken returns the access token to present
+		accessToken func(resp *domain.TokenResponse, service *oidcService, client *domain.OAuthClient, advance func(time.Duration)) string
+		wantErr     string
+	}{
+		{name: "openid email", scope: "openid email"},
+		{name: "without openid", scope: "email", wantErr: domain.OAuthInsufficientScope},
+		{
+			name:  "unknown token",
+			scope: "openid email",
+			accessToken: func(resp *domain.TokenResponse, service *oidcService, client *domain.OAuthClient, advance func(time.Duration)) string {
+				return "unknown"
+			},
+			wantErr: domain.OAuthInvalidToken,
+		},
+		{
+			name:  "expired token",
+			scope: "openid email",
+			accessToken: func(resp *domain.TokenResponse, service *oidcService, client *domain.OAuthClient, advance func(time.Duration)) string {
+				advance(time.Hour)
+				return resp.AccessToken
+			},
+			wantErr: domain.OAuthInvalidToken,
+		},
+		{
+			name:  "revoked grant",
+			scope: "openid email offline_access",
+			accessToken: func(resp *domain.TokenResponse, service *oidcService, client *domain.OAuthClient, advance func(time.Duration)) string {
+				service.Revoke(client, resp.RefreshToken)
+				return resp.AccessToken
+			},
+			wantErr: domain.OAuthInvalidToken,
+		},
+		{
+			name:  "deactivated user",
+			scope: "openid email",
+			accessToken: func(resp *domain.TokenResponse, service *oidcService, client *domain.OAuthClient, advance func(time.Duration)) string {
+				deactivatedAt := time.Now()
+				service.users.(*mockUserRepository).users[1].DeactivatedAt = &deactivatedAt
+				return resp.AccessToken
+			},
+			wantErr: domain.OAuthInvalidToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, _, client, advance := newTestOIDCService(t)
+			resp := exchangeCode(t, service, client, tt.scope)
+			accessToken := resp.AccessToken
+			if tt.accessToken != nil {
+				accessToken = tt.accessToken(resp, service, client, advance)
+			}
+
+			info, err := service.UserInfo(accessToken)
+			if tt.wantErr != "" {
+				assertOAuthError(t, "UserInfo()", err, tt.wantErr)
+				return
+			}
+			if err != nil || info.Subject != "1" || info.Email != "test@example.com" || info.EmailVerified == nil || !*info.EmailVerified {
+				t.Errorf("UserInfo() = %+v, %v", info, err)
+			}
+		})
+	}
+}
+
+func TestOIDCRefreshTokenGrant(t *testing.T) {
+	tests := []struct {
+		name string
+		// refreshToken returns the refresh token to present after the code exchange
+		refreshToken func(t *testing.T, service *oidcService, client *domain.OAuthClient, resp *domain.TokenResponse, advance func(time.Duration)) string
+		scope        string
+		wantErr      string
+		// wantRevoked is whether the grant is revoked afterwards
+		wantRevoked bool
+	}{
+		{name: "issued token"},
+		{name: "narrower scope", scope: "openid offline_access"},
+		{name: "wider scope", scope: "openid profile offline_access", wantErr: domain.OAuthInvalidScope},
+		{
+			// Replaying the old refresh token revokes the whole grant
+			name: "replayed token",
+			refreshToken: func(t *testing.T, service *oidcService, client *domain.OAuthClient, resp *domain.TokenResponse, advance func(time.Duration)) string {
+				if _, err := service.Token(client, &domain.TokenRequest{GrantType: "refresh_token", RefreshToken: resp.RefreshToken}); err != nil {
+					t.Fatalf("Token(refresh) error = %v", err)
+				}
+				return resp.RefreshToken
+			},
+			wantErr:     domain.OAuthInvalidGrant,
+			wantRevoked: true,
+		},
+		{
+			name: "successor of a replayed token",
+			refreshToken: func(t *testing.T, service *oidcService, client *domain.OAuthClient, resp *domain.TokenResponse, advance func(time.Duration)) string {
+				second, err := service.Token(client, &domain.TokenRequest{GrantType: "refresh_token", RefreshToken: resp.RefreshToken})
+				if err != nil {
+					t.Fatalf("Token(refresh) error = %v", err)
+				}
+				service.Token(client, &domain.TokenRequest{GrantType: "refresh_token", RefreshToken: resp.RefreshToken})
+				return second.RefreshToken
+			},
+			wantErr:     domain.OAuthInvalidGrant,
+			wantRevoked: true,
+		},
+		{
+			name: "expired token",
+			refreshToken: func(t *testing.T, service *oidcService, client *domain.OAuthClient, resp *domain.TokenResponse, advance func(time.Duration)) string {
+				advance(24 * time.Hour)
+				return resp.RefreshToken
+			},
+			wantErr: domain.OAuthInvalidGrant,
+		},
+		{
+			name: "access token",
+			refreshToken: func(t *testing.T, service *oidcService, client *domain.OAuthClient, resp *domain.TokenResponse, advance func(time.Duration)) string {
+				return resp.AccessToken
+			},
+			wantErr: domain.OAuthInvalidGrant,
+		},
+		{
+			name: "deactivated user",
+			refreshToken: func(t *testing.T, service *oidcService, client *domain.OAuthClient, resp *domain.TokenResponse, advance func(time.Duration)) string {
+				deactivatedAt := time.Now()
+				service.users.(*mockUserRepository).users[1].DeactivatedAt = &deactivatedAt
+				return resp.RefreshToken
+			},
+			wantErr: domain.OAuthInvalidGrant,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, _, client, advance := newTestOIDCService(t)
+			resp := exchangeCode(t, service, client, "openid email offline_access")
+			refreshToken := resp.RefreshToken
+			if tt.refreshToken != nil {
+				refreshToken = tt.refreshToken(t, service, client, resp, advance)
+			}
+
+			rotated, err := service.Token(client, &domain.TokenRequest{GrantType: "refresh_token", RefreshToken: refreshToken, Scope: tt.scope})
+			if tt.wantErr != "" {
+				assertOAuthError(t, "Token(refresh)", err, tt.wantErr)
+			} else if err != nil || rotated.RefreshToken == "" || rotated.RefreshToken == refreshToken {
+				t.Fatalf("Token(refresh) = %+v, %v, want a rotated refresh token", rotated, err)
+			}
+
+			revoked := true
+			for _, stored := range service.grants.(*mockOAuthGrantRepository).tokens {
+				revoked = revoked && stored.RevokedAt != nil
+			}
+			if revoked != tt.wantRevoked {
+				t.Errorf("grant revoked = %v, want %v", revoked, tt.wantRevoked)
+			}
+		})
+	}
+}
+
+func TestOIDCIntrospect(t *testing.T) {
+	tests := []struct {
+		name string
+		// value returns the token to introspect after the code exchange
+		value      func(service *oidcService, client *domain.OAuthClient, resp *domain.TokenResponse) string
+		public     bool
+		wantActive bool
+		wantErr    string
+	}{
+		{
+			name: "access token",
+			value: func(service *oidcService, client *domain.OAuthClient, resp *domain.TokenResponse) string {
+				return resp.AccessToken
+			},
+			wantActive: true,
+		},
+		{
+			name: "unknown token",
+			value: func(service *oidcService, client *domain.OAuthClient, resp *domain.TokenResponse) string {
+				return "unknown"
+			},
+		},
+		{
+			name: "access token of a revoked grant",
+			value: func(service *oidcService, client *domain.OAuthClient, resp *domain.TokenResponse) string {
+				service.Revoke(client, resp.RefreshToken)
+				return resp.AccessToken
+			},
+		},
+		{
+			name: "by a public client",
+			value: func(service *oidcService, client *domain.OAuthClient, resp *domain.TokenResponse) string {
+				return resp.AccessToken
+			},
+			public:  true,
+			wantErr: domain.OAuthUnauthorizedClient,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, clients, client, _ := newTestOIDCService(t)
+			resp := exchangeCode(t, service, client, "openid offline_access")
+			value := tt.value(service, client, resp)
+			introspecting := client
+			if tt.public {
+				introspecting = &domain.OAuthClient{Name: "SPA", RedirectURIs: []string{testRedirectURI}, Public: true}
+				clients.Create(domain.SystemPrincipal(), introspecting)
+			}
+
+			result, err := service.Introspect(introspecting, value)
+			if tt.wantErr != "" {
+				assertOAuthError(t, "Introspect()", err, tt.wantErr)
+				return
+			}
+			if err != nil || result.Active != tt.wantActive {
+				t.Fatalf("Introspect() = %+v, %v, want active = %v", result, err, tt.wantActive)
+			}
+			if tt.wantActive && (result.Subject != "1" || result.ClientID != client.ClientID || result.Username != "test@example.com") {
+				t.Errorf("Introspect() = %+v, want a token of user 1", result)
+			}
+		})
+	}
+}
+
+func TestOIDCRevoke(t *testing.T) {
+	tests := []struct {
+		name string
+		// other revokes as another client, whose tokens are ignored
+		other       bool
+		wantRevoked bool
+	}{
+		{name: "own refresh token", wantRevoked: true},
+		{name: "refresh token of another client", other: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, clients, client, _ := newTestOIDCService(t)
+			resp := exchangeCode(t, service, client, "openid offline_access")
+			revoking := client
+			if tt.other {
+				revoking = &domain.OAuthClient{Name: "SPA", RedirectURIs: []string{testRedirectURI}, Public: true}
+				clients.Create(domain.SystemPrincipal(), revoking)
+			}
+
+			if err := service.Revoke(revoking, resp.RefreshToken); err != nil {
+				t.Fatalf("Revoke() error = %v", err)
+			}
+			// Revoking the refresh token revokes the access tokens of its grant
+			if result, _ := service.Introspect(client, resp.AccessToken); result.Active == tt.wantRevoked {
+				t.Errorf("access token active = %v, want %v", result.Active, !tt.wantRevoked)
+			}
+		})
+	}
+}
+
+func TestSigningKeyRotation(t *testing.T) {
+	service, _, client, advance := newTestOIDCService(t)
+	keys := service.keys.(*signingKeyService)
+
+	first := exchangeCode(t, service, client, "openid")
+	set, _ := keys.JWKS()
+	if len(set.Keys) != 1 || set.Keys[0].KeyID != token.Thumbprint(&activePrivateKey(t, keys).PublicKey) {
+		t.Fatalf("JWKS() = %+v, want the single active key", set)
+	}
+
+	// A key older than the rotation interval is replaced on the next signature,
+	// while the retired key stays published for the retention period
+	advance(25 * time.Hour)
+	second := exchangeCode(t, service, client, "openid")
+	set, _ = keys.JWKS()
+	if len(set.Keys) != 2 {
+		t.Fatalf("JWKS() has %d keys after rotation, want 2", len(set.Keys))
+	}
+	verifyIDToken(t, keys, first.IDToken)
+	verifyIDToken(t, keys, second.IDToken)
+
+	advance(2 * time.Hour)
+	rotated, err := keys.Rotate(domain.SystemPrincipal())
+	if err != nil {
+		t.Fatalf("Rotate() error = %v", err)
+	}
+	set, _ = keys.JWKS()
+	if len(set.Keys) != 2 || set.Keys[0].KeyID != rotated.ID {
+		t.Errorf("JWKS() = %+v, want the new key and the one it replaced", set)
+	}
+}
+
+func TestSigningKeyRotate(t *testing.T) {
+	tests := []struct {
+		name    string
+		actor   *domain.Principal
+		wantErr bool
+	}{
+		{name: "system", actor: domain.SystemPrincipal()},
+		{name: "without the OAuth permission", actor: domain.NewPrincipal(&domain.User{ID: 2}, nil), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, _, client, advance := newTestOIDCService(t)
+			keys := service.keys.(*signingKeyService)
+			exchangeCode(t, service, client, "openid")
+			before, _ := keys.JWKS()
+			advance(time.Minute)
+
+			rotated, err := keys.Rotate(tt.actor)
+			after, _ := keys.JWKS()
+			if tt.wantErr {
+				assertErrorType(t, "Rotate()", err, errors.Forbidden)
+				if len(after.Keys) != len(before.Keys) {
+					t.Errorf("failed Rotate() published %d keys, want %d", len(after.Keys), len(before.Keys))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Rotate() error = %v", err)
+			}
+			if len(after.Keys) != 2 || after.Keys[0].KeyID != rotated.ID {
+				t.Errorf("JWKS() = %+v, want the new key and the one it replaced", after)
+			}
+		})
+	}
+}
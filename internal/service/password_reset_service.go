@@ -0,0 +1,202 @@
+package service
+
+import (
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/errors"
+	"UserRESTfulApi/pkg/config"
+	"UserRESTfulApi/pkg/mailer"
+	"UserRESTfulApi/pkg/password"
+	"UserRESTfulApi/pkg/token"
+	"fmt"
+	"log"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// passwordResetCooldown is the minimum time between two reset emails to the same user
+const passwordResetCooldown = time.Minute
+
+type passwordResetService struct {
+	users         domain.UserRepository
+	resetTokens   domain.PasswordResetRepository
+	refreshTokens domain.RefreshTokenRepository
+	hasher        password.Hasher
+	policy        *password.Policy
+	mailer        mailer.Mailer
+	audit         domain.AuditService
+	ttl           time.Duration
+	resetURL      string
+	now           func() time.Time
+	// dispatch runs the work of a reset request after Forgot has returned
+	dispatch func(func())
+}
+
+// NewPasswordResetService creates a new password reset service
+func NewPasswordResetService(users domain.UserRepository, resetTokens domain.PasswordResetRepository, refreshTokens domain.RefreshTokenRepository, hasher password.Hasher, policy *password.Policy, m mailer.Mailer, audit domain.AuditService, cfg config.AuthConfig) domain.PasswordResetService {
+	return &passwordResetService{
+		users:         users,
+		resetTokens:   resetTokens,
+		refreshTokens: refreshTokens,
+		hasher:        hasher,
+		policy:        policy,
+		mailer:        m,
+		audit:         audit,
+		ttl:           cfg.PasswordResetTTL,
+		resetURL:      cfg.PasswordResetURL,
+		now:           time.Now,
+		dispatch:      func(work func()) { go work() },
+	}
+}
+
+// Forgot emails a single-use reset link to the user with the given email.
+// Unknown emails, repeated requests and delivery failures all look like
+// success to the caller so the response never reveals whether an account
+// exists. The account is looked up and mailed in the background, so known
+// and unknown emails also take the same time to answer.
+func (s *passwordResetService) Forgot(email string, request domain.RequestMeta) error {
+	email = strings.TrimSpace(email)
+	if _, err := mail.ParseAddress(email); err != nil {
+		return errors.InvalidEmailError(email)
+	}
+
+	s.dispatch(func() {
+		if err := s.sendResetLink(email, request); err != nil {
+			log.Printf("Failed to handle password reset request: %v", err)
+		}
+	})
+	return nil
+}
+
+// sendResetLink issues a reset token to the user with the given email, if
+// there is one, and mails it unless a reset was requested within the cooldown
+func (s *passwordResetService) sendResetLink(email string, request domain.RequestMeta) error {
+	user, err := s.users.GetByEmail(email)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return nil
+	}
+
+	latest, err := s.resetTokens.GetLatestForUser(user.ID)
+	if err != nil {
+		return err
+	}
+	if latest != nil && s.now().Sub(latest.CreatedAt) < passwordResetCooldown {
+		log.Printf("Password reset for user %d requested again within %s, not sending another email", user.ID, passwordResetCooldown)
+		return nil
+	}
+
+	resetToken, err := token.NewOpaque()
+	if err != nil {
+		return errors.InternalServerError(err)
+	}
+	expiresAt := s.now().Add(s.ttl)
+	err = s.resetTokens.Create(&domain.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: token.HashOpaque(resetToken),
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return err
+	}
+	event := domain.NewAuditEvent(domain.AuditPasswordResetRequested, nil, request).ForUser(user.ID)
+	event.Details = map[string]string{"expires_at": auditTime(expiresAt)}
+	s.audit.Record(event)
+
+	link, err := tokenLink(s.resetURL, resetToken)
+	if err != nil {
+		return errors.InternalServerError(err)
+	}
+	err = s.mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hello %s,\n\n"+
+			"We received a request to reset the password of your account. "+
+			"Use the link below to choose a new password. It can be used once and expires at %s.\n\n"+
+			"%s\n\n"+
+			"If you did not ask for a password reset you can ignore this email.\n",
+			user.Name, expiresAt.UTC().Format(time.RFC1123), link),
+	})
+	if err != nil {
+		log.Printf("Failed to send password reset email to user %d: %v", user.ID, err)
+	}
+	return nil
+}
+
+// Reset validates the token and the new password, stores the new password
+// hash and revokes every refresh token of the user
+func (s *passwordResetService) Reset(resetToken, newPassword string, request domain.RequestMeta) error {
+	invalidToken := errors.InvalidInputError("token", "reset token is invalid or has expired")
+
+	stored, err := s.resetTokens.GetByHash(token.HashOpaque(resetToken))
+	if err != nil {
+		return err
+	}
+	if stored == nil || stored.UsedAt != nil || !s.now().Before(stored.ExpiresAt) {
+		return invalidToken
+	}
+
+	user, err := s.users.Get(stored.UserID)
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok && appErr.Type == errors.NotFound {
+			return invalidToken
+		}
+		return err
+	}
+	if user == nil {
+		return invalidToken
+	}
+
+	// Validate before redeeming so a rejected password does not burn the token
+	if err := validatePassword(s.policy, newPassword, user.Email, user.Name); err != nil {
+		return err
+	}
+	hashed, err := s.hasher.Hash(newPassword)
+	if err != nil {
+		return errors.InternalServerError(err)
+	}
+
+	used, err := s.resetTokens.MarkUsed(stored.ID)
+	if err != nil {
+		return err
+	}
+	if !used {
+		// Another request redeemed the token between our read and update
+		return invalidToken
+	}
+
+	if err := s.users.UpdatePassword(user.ID, hashed); err != nil {
+		return err
+	}
+	if err := s.resetTokens.InvalidateForUser(user.ID); err != nil {
+		return err
+	}
+	if err := s.refreshTokens.RevokeAllForUser(user.ID); err != nil {
+		return err
+	}
+
+	event := domain.NewAuditEvent(domain.AuditPasswordResetCompleted, nil, request).ForUser(user.ID)
+	event.Changes = map[string]domain.AuditChange{"password": {Old: domain.AuditRedacted, New: domain.AuditRedacted}}
+	s.audit.Record(event)
+	revoked := domain.NewAuditEvent(domain.AuditTokensRevoked, nil, request).ForUser(user.ID)
+	revoked.Details = map[string]string{"reason": "password_reset"}
+	s.audit.Record(revoked)
+
+	log.Printf("Password reset for user %d, all sessions revoked", user.ID)
+	return nil
+}
+
+// tokenLink adds the token to a page URL as the "token" query parameter
+func tokenLink(pageURL, value string) (string, error) {
+	link, err := url.Parse(pageURL)
+	if err != nil {
+		return "", err
+	}
+	query := link.Query()
+	query.Set("token", value)
+	link.RawQuery = query.Encode()
+	return link.String(), nil
+}
@@ -0,0 +1,252 @@
+package service
+
+import (
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/errors"
+	"UserRESTfulApi/pkg/config"
+	"UserRESTfulApi/pkg/mailer"
+	"UserRESTfulApi/pkg/password"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+)
+
+// Mock password reset token repository for testing
+type mockPasswordResetRepository struct {
+	tokens map[uint]*domain.PasswordResetToken
+}
+
+func newMockPasswordResetRepository() *mockPasswordResetRepository {
+	return &mockPasswordResetRepository{
+		tokens: make(map[uint]*domain.PasswordResetToken),
+	}
+}
+
+func (m *mockPasswordResetRepository) Create(t *domain.PasswordResetToken) error {
+	t.ID = uint(len(m.tokens) + 1)
+	t.CreatedAt = time.Now()
+	m.tokens[t.ID] = t
+	return nil
+}
+
+func (m *mockPasswordResetRepository) GetByHash(tokenHash string) (*domain.PasswordResetToken, error) {
+	for _, t := range m.tokens {
+		if t.TokenHash == tokenHash {
+			copied := *t
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *mockPasswordResetRepository) GetLatestForUser(userID uint) (*domain.PasswordResetToken, error) {
+	var latest *domain.PasswordResetToken
+	for _, t := range m.tokens {
+		if t.UserID == userID && (latest == nil || t.ID > latest.ID) {
+			latest = t
+		}
+	}
+	return latest, nil
+}
+
+func (m *mockPasswordResetRepository) MarkUsed(id uint) (bool, error) {
+	t, exists := m.tokens[id]
+	if !exists || t.UsedAt != nil {
+		return false, nil
+	}
+	now := time.Now()
+	t.UsedAt = &now
+	return true, nil
+}
+
+func (m *mockPasswordResetRepository) InvalidateForUser(userID uint) error {
+	now := time.Now()
+	for _, t := range m.tokens {
+		if t.UserID == userID && t.UsedAt == nil {
+			t.UsedAt = &now
+		}
+	}
+	return nil
+}
+
+var resetTokenPattern = regexp.MustCompile(`token=([A-Za-z0-9_%-]+)`)
+
+// mailedToken returns the token of the last link mailed to the address
+func mailedToken(t *testing.T, mail *mailer.FileMailer, email string) string {
+	t.Helper()
+	messages, err := mail.Messages(email)
+	if err != nil || len(messages) == 0 {
+		t.Fatalf("Messages(%s) = %d messages, %v, want an email with a link", email, len(messages), err)
+	}
+	match := resetTokenPattern.FindStringSubmatch(messages[len(messages)-1])
+	if match == nil {
+		t.Fatalf("email to %s has no token link: %q", email, messages[len(messages)-1])
+	}
+	value, _ := url.QueryUnescape(match[1])
+	return value
+}
+
+// newTestPasswordResetService creates a password reset service that handles
+// requests right away and mails into a temporary directory
+func newTestPasswordResetService(t *testing.T, users *mockUserRepository, refreshTokens *mockRefreshTokenRepository) (*passwordResetService, *mockPasswordResetRepository, *mailer.FileMailer) {
+	mail, err := mailer.NewFileMailer("no-reply@example.com", t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileMailer() error = %v", err)
+	}
+
+	resetTokens := newMockPasswordResetRepository()
+	cfg := config.AuthConfig{PasswordResetTTL: 30 * time.Minute, PasswordResetURL: "https://app.example.com/reset?lang=en"}
+	service := NewPasswordResetService(users, resetTokens, refreshTokens, testHasher, password.DefaultPolicy(), mail, NewAuditService(newMockAuditRepository()), cfg).(*passwordResetService)
+	service.dispatch = func(work func()) { work() }
+	return service, resetTokens, mail
+}
+
+// requestResetToken asks for a reset email for user 1 and returns the token from the mailed link
+func requestResetToken(t *testing.T, service *passwordResetService, mail *mailer.FileMailer) string {
+	t.Helper()
+	if err := service.Forgot("test@example.com", testRequest); err != nil {
+		t.Fatalf("Forgot() error = %v", err)
+	}
+	return mailedToken(t, mail, "test@example.com")
+}
+
+func TestForgotPassword(t *testing.T) {
+	tests := []struct {
+		name       string
+		email      string
+		previous   bool
+		later      time.Duration
+		wantErr    errors.ErrorType
+		wantQueued int
+		wantEmails int
+	}{
+		{name: "known email", email: "test@example.com", wantQueued: 1, wantEmails: 1},
+		{name: "unknown email", email: "nobody@example.com", wantQueued: 1, wantEmails: 0},
+		{name: "invalid email", email: "not-an-email", wantErr: errors.InvalidEmail},
+		{name: "within the cooldown", email: "test@example.com", previous: true, wantQueued: 1, wantEmails: 0},
+		{name: "after the cooldown", email: "test@example.com", previous: true, later: 2 * time.Minute, wantQueued: 1, wantEmails: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users := newMockUserRepository()
+			users.users[1] = &domain.User{ID: 1, Email: "test@example.com", Name: "Test User"}
+			service, resetTokens, mail := newTestPasswordResetService(t, users, newMockRefreshTokenRepository())
+
+			if tt.previous {
+				requestResetToken(t, service, mail)
+				users.getByEmailCalled = false
+			}
+			if tt.later != 0 {
+				service.now = func() time.Time { return time.Now().Add(tt.later) }
+			}
+			issued := len(resetTokens.tokens)
+			sent, _ := mail.Messages(tt.email)
+			var queued []func()
+			service.dispatch = func(work func()) { queued = append(queued, work) }
+
+			err := service.Forgot(tt.email, testRequest)
+			if tt.wantErr != "" {
+				assertErrorType(t, "Forgot()", err, tt.wantErr)
+			} else if err != nil {
+				t.Fatalf("Forgot() error = %v", err)
+			}
+
+			// Known and unknown emails take the same path until the response is sent
+			if users.getByEmailCalled || len(resetTokens.tokens) != issued {
+				t.Error("Forgot() looked up the account before responding")
+			}
+			if len(queued) != tt.wantQueued {
+				t.Fatalf("Forgot() queued %d jobs, want %d", len(queued), tt.wantQueued)
+			}
+
+			for _, work := range queued {
+				work()
+			}
+			if messages, _ := mail.Messages(tt.email); len(messages)-len(sent) != tt.wantEmails {
+				t.Errorf("Forgot() sent %d emails, want %d", len(messages)-len(sent), tt.wantEmails)
+			}
+		})
+	}
+}
+
+func TestResetPassword(t *testing.T) {
+	tests := []struct {
+		name       string
+		password   string
+		reuse      bool
+		superseded bool
+		token      string
+		later      time.Duration
+		wantErr    errors.ErrorType
+	}{
+		{name: "valid token", password: "NewPassword123!"},
+		// A password rejected by the policy does not consume the token
+		{name: "weak password", password: "weak", wantErr: errors.InvalidPassword},
+		{name: "reused token", password: "OtherPassword123!", reuse: true, wantErr: errors.InvalidInput},
+		{name: "token older than a redeemed one", password: "OtherPassword123!", superseded: true, wantErr: errors.InvalidInput},
+		{name: "unknown token", password: "NewPassword123!", token: "unknown", wantErr: errors.InvalidInput},
+		{name: "expired token", password: "NewPassword123!", later: 31 * time.Minute, wantErr: errors.InvalidInput},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users := newMockUserRepository()
+			users.users[1] = &domain.User{ID: 1, Email: "test@example.com", Name: "Test User"}
+			refreshTokens := newMockRefreshTokenRepository()
+			_ = refreshTokens.Create(&domain.RefreshToken{UserID: 1, FamilyID: "family", TokenHash: "hash", ExpiresAt: time.Now().Add(time.Hour)})
+			service, resetTokens, mail := newTestPasswordResetService(t, users, refreshTokens)
+
+			resetToken := requestResetToken(t, service, mail)
+			if tt.superseded {
+				service.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+				if err := service.Reset(requestResetToken(t, service, mail), "NewPassword123!", testRequest); err != nil {
+					t.Fatalf("Reset() error = %v", err)
+				}
+			}
+			if tt.reuse {
+				if err := service.Reset(resetToken, "NewPassword123!", testRequest); err != nil {
+					t.Fatalf("Reset() error = %v", err)
+				}
+			}
+			if tt.token != "" {
+				resetToken = tt.token
+			}
+			if tt.later != 0 {
+				service.now = func() time.Time { return time.Now().Add(tt.later) }
+			}
+
+			err := service.Reset(resetToken, tt.password, testRequest)
+			if tt.wantErr != "" {
+				assertErrorType(t, "Reset()", err, tt.wantErr)
+				if testHasher.Verify(tt.password, users.users[1].Password) {
+					t.Error("failed Reset() stored the password")
+				}
+				if tt.wantErr == errors.InvalidPassword {
+					if err := service.Reset(resetToken, "NewPassword123!", testRequest); err != nil {
+						t.Errorf("Reset() after a rejected password error = %v", err)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Reset() error = %v", err)
+			}
+
+			if !testHasher.Verify(tt.password, users.users[1].Password) {
+				t.Error("Reset() did not store a hash of the new password")
+			}
+			for _, stored := range resetTokens.tokens {
+				if stored.TokenHash == resetToken {
+					t.Error("reset token stored in plaintext")
+				}
+			}
+			for _, refreshToken := range refreshTokens.tokens {
+				if refreshToken.RevokedAt == nil {
+					t.Error("Reset() did not revoke existing sessions")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/errors"
+	"regexp"
+	"sort"
+)
+
+// roleNamePattern restricts custom role names to lowercase identifiers
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{1,49}$`)
+
+type roleService struct {
+	repo  domain.RoleRepository
+	users domain.UserRepository
+}
+
+// NewRoleService creates a new role service
+func NewRoleService(repo domain.RoleRepository, users domain.UserRepository) domain.RoleService {
+	return &roleService{repo: repo, users: users}
+}
+
+// List lists every role
+func (s *roleService) List(actor *domain.Principal) ([]*domain.Role, error) {
+	if !actor.Can(domain.PermissionRolesManage) {
+		return nil, errors.ForbiddenError("manage roles")
+	}
+	return s.repo.List()
+}
+
+// Create creates a custom role
+func (s *roleService) Create(actor *domain.Principal, role *domain.Role) error {
+	if !actor.Can(domain.PermissionRolesManage) {
+		return errors.ForbiddenError("manage roles")
+	}
+
+	if !roleNamePattern.MatchString(role.Name) {
+		return errors.InvalidInputError("name", "must be 2-50 lowercase letters, digits, '-' or '_' starting with a letter")
+	}
+	if _, builtin := domain.BuiltinRoles[role.Name]; builtin {
+		return errors.ConflictError("role " + role.Name + " is built in")
+	}
+	for _, permission := range role.PermissionList() {
+		if !domain.IsKnownPermission(permission) {
+			return errors.InvalidInputError("permissions", "unknown permission "+string(permission))
+		}
+	}
+
+	existing, err := s.repo.GetByName(role.Name)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return errors.ConflictError("role " + role.Name + " already exists")
+	}
+
+	role.Builtin = false
+	return s.repo.Create(role)
+}
+
+// Delete deletes a custom role
+func (s *roleService) Delete(actor *domain.Principal, name string) error {
+	if !actor.Can(domain.PermissionRolesManage) {
+		return errors.ForbiddenError("manage roles")
+	}
+
+	role, err := s.getRole(name)
+	if err != nil {
+		return err
+	}
+	if role.Builtin {
+		return errors.ConflictError("built-in role " + name + " cannot be deleted")
+	}
+	return s.repo.Delete(role.ID)
+}
+
+// GetUserRoles lists the roles explicitly assigned to a user
+func (s *roleService) GetUserRoles(actor *domain.Principal, userID uint) ([]*domain.Role, error) {
+	if !actor.Can(domain.PermissionRolesManage) && !actor.CanAccessUser(domain.PermissionUsersRead, userID) {
+		return nil, errors.ForbiddenError("read this user's roles")
+	}
+	if err := s.ensureUserExists(userID); err != nil {
+		return nil, err
+	}
+	return s.repo.GetUserRoles(userID)
+}
+
+// AssignToUser assigns a role to a user
+func (s *roleService) AssignToUser(actor *domain.Principal, userID uint, roleName string) error {
+	if !actor.Can(domain.PermissionRolesManage) {
+		return errors.ForbiddenError("manage roles")
+	}
+	if err := s.ensureUserExists(userID); err != nil {
+		return err
+	}
+
+	role, err := s.getRole(roleName)
+	if err != nil {
+		return err
+	}
+	return s.repo.AssignToUser(userID, role.ID)
+}
+
+// RemoveFromUser removes a role from a user
+func (s *roleService) RemoveFromUser(actor *domain.Principal, userID uint, roleName string) error {
+	if !actor.Can(domain.PermissionRolesManage) {
+		return errors.ForbiddenError("manage roles")
+	}
+	if roleName == domain.RoleAdmin && actor.ID() == userID {
+		return errors.ConflictError("you cannot remove your own admin role")
+	}
+
+	role, err := s.getRole(roleName)
+	if err != nil {
+		return err
+	}
+	return s.repo.RemoveFromUser(userID, role.ID)
+}
+
+// EnsureBuiltinRoles creates the built-in roles and syncs their permissions with the code
+func (s *roleService) EnsureBuiltinRoles() error {
+	names := make([]string, 0, len(domain.BuiltinRoles))
+	for name := range domain.BuiltinRoles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		role := &domain.Role{Name: name, Builtin: true}
+		for _, permission := range domain.BuiltinRoles[name] {
+			role.Permissions = append(role.Permissions, domain.RolePermission{Permission: permission})
+		}
+		if err := s.repo.Sync(role); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *roleService) getRole(name string) (*domain.Role, error) {
+	role, err := s.repo.GetByName(name)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return nil, errors.NotFoundError("role", name)
+	}
+	return role, nil
+}
+
+func (s *roleService) ensureUserExists(userID uint) error {
+	user, err := s.users.Get(userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return errors.NotFoundError("user", userID)
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package service
+
+import (
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/errors"
+	"testing"
+)
+
+// Mock role repository for testing
+type mockRoleRepository struct {
+	roles     map[uint]*domain.Role
+	userRoles map[uint]map[uint]bool
+}
+
+func newMockRoleRepository() *mockRoleRepository {
+	return &mockRoleRepository{
+		roles:     make(map[uint]*domain.Role),
+		userRoles: make(map[uint]map[uint]bool),
+	}
+}
+
+func (m *mockRoleRepository) List() ([]*domain.Role, error) {
+	roles := make([]*domain.Role, 0, len(m.roles))
+	for _, role := range m.roles {
+		roles = append(roles, role)
+	}
+	return roles, nil
+}
+
+func (m *mockRoleRepository) GetByName(name string) (*domain.Role, error) {
+	for _, role := range m.roles {
+		if role.Name == name {
+			return role, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *mockRoleRepository) Create(role *domain.Role) error {
+	role.ID = uint(len(m.roles) + 1)
+	m.roles[role.ID] = role
+	return nil
+}
+
+func (m *mockRoleRepository) Delete(id uint) error {
+	delete(m.roles, id)
+	for _, roles := range m.userRoles {
+		delete(roles, id)
+	}
+	return nil
+}
+
+func (m *mockRoleRepository) Sync(role *domain.Role) error {
+	existing, _ := m.GetByName(role.Name)
+	if existing == nil {
+		return m.Create(role)
+	}
+	existing.Permissions = role.Permissions
+	role.ID = existing.ID
+	return nil
+}
+
+func (m *mockRoleRepository) GetUserRoles(userID uint) ([]*domain.Role, error) {
+	var roles []*domain.Role
+	for roleID := range m.userRoles[userID] {
+		roles = append(roles, m.roles[roleID])
+	}
+	return roles, nil
+}
+
+func (m *mockRoleRepository) AssignToUser(userID, roleID uint) error {
+	if m.userRoles[userID] == nil {
+		m.userRoles[userID] = make(map[uint]bool)
+	}
+	m.userRoles[userID][roleID] = true
+	return nil
+}
+
+func (m *mockRoleRepository) RemoveFromUser(userID, roleID uint) error {
+	delete(m.userRoles[userID], roleID)
+	return nil
+}
+
+func newTestRoleService(t *testing.T) (domain.RoleService, *mockRoleRepository, *mockUserRepository) {
+	roles := newMockRoleRepository()
+	users := newMockUserRepository()
+	users.users[1] = &domain.User{ID: 1, Email: "admin@example.com", Name: "Admin User"}
+	users.users[2] = &domain.User{ID: 2, Email: "user@example.com", Name: "Normal User"}
+
+	service := NewRoleService(roles, users)
+	if err := service.EnsureBuiltinRoles(); err != nil {
+		t.Fatalf("EnsureBuiltinRoles() error = %v", err)
+	}
+	return service, roles, users
+}
+
+func assertErrorType(t *testing.T, name string, err error, want errors.ErrorType) {
+	t.Helper()
+	appErr, ok := err.(*errors.AppError)
+	if !ok || appErr.Type != want {
+		t.Errorf("%s error = %v, want %s", name, err, want)
+	}
+}
+
+func TestEnsureBuiltinRolesIsIdempotent(t *testing.T) {
+	service, roles, _ := newTestRoleService(t)
+	if err := service.EnsureBuiltinRoles(); err != nil {
+		t.Fatalf("EnsureBuiltinRoles() error = %v", err)
+	}
+
+	if len(roles.roles) != len(domain.BuiltinRoles) {
+		t.Fatalf("got %d roles, want %d", len(roles.roles), len(domain.BuiltinRoles))
+	}
+	admin, _ := roles.GetByName(domain.RoleAdmin)
+	if admin == nil || !admin.Builtin || len(admin.Permissions) != len(domain.BuiltinRoles[domain.RoleAdmin]) {
+		t.Errorf("admin role = %+v, want built-in role with every admin permission", admin)
+	}
+}
+
+func TestRoleServiceAssignAndAuthorize(t *testing.T) {
+	service, roles, users := newTestRoleService(t)
+	system := domain.SystemPrincipal()
+
+	if err := service.AssignToUser(system, 1, domain.RoleAdmin); err != nil {
+		t.Fatalf("AssignToUser() error = %v", err)
+	}
+	assigned, _ := roles.GetUserRoles(1)
+	admin := domain.NewPrincipal(users.users[1], assigned)
+	normal := domain.NewPrincipal(users.users[2], nil)
+
+	if !admin.Can(domain.PermissionUsersDelete) || normal.Can(domain.PermissionUsersDelete) {
+		t.Fatal("only the admin should hold users:delete")
+	}
+
+	assertErrorType(t, "AssignToUser(normal)", service.AssignToUser(normal, 2, domain.RoleAdmin), errors.Forbidden)
+	_, err := service.List(normal)
+	assertErrorType(t, "List(normal)", err, errors.Forbidden)
+	_, err = service.GetUserRoles(normal, 1)
+	assertErrorType(t, "GetUserRoles(other)", err, errors.Forbidden)
+	if _, err := service.GetUserRoles(normal, 2); err != nil {
+		t.Errorf("GetUserRoles(self) error = %v", err)
+	}
+
+	assertErrorType(t, "AssignToUser(missing role)", service.AssignToUser(admin, 2, "missing"), errors.NotFound)
+	assertErrorType(t, "AssignToUser(missing user)", service.AssignToUser(admin, 99, domain.RoleAdmin), errors.NotFound)
+	assertErrorType(t, "RemoveFromUser(own admin)", service.RemoveFromUser(admin, 1, domain.RoleAdmin), errors.Conflict)
+}
+
+func TestRoleServiceCustomRoles(t *testing.T) {
+	service, _, _ := newTestRoleService(t)
+	system := domain.SystemPrincipal()
+
+	support := &domain.Role{
+		Name:        "support",
+		Permissions: []domain.RolePermission{{Permission: domain.PermissionUsersRead}, {Permission: domain.PermissionUsersList}},
+	}
+	if err := service.Create(system, support); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	assertErrorType(t, "Create(duplicate)", service.Create(system, &domain.Role{Name: "support"}), errors.Conflict)
+	assertErrorType(t, "Create(builtin name)", service.Create(system, &domain.Role{Name: domain.RoleAdmin}), errors.Conflict)
+	assertErrorType(t, "Create(invalid name)", service.Create(system, &domain.Role{Name: "Not Valid"}), errors.InvalidInput)
+	assertErrorType(t, "Create(unknown permission)", service.Create(system, &domain.Role{
+		Name:        "broken",
+		Permissions: []domain.RolePermission{{Permission: "users:everything"}},
+	}), errors.InvalidInput)
+
+	assertErrorType(t, "Delete(builtin)", service.Delete(system, domain.RoleUser), errors.Conflict)
+	if err := service.Delete(system, "support"); err != nil {
+		t.Errorf("Delete() error = %v", err)
+	}
+}
@@ -0,0 +1,775 @@
+package service
+
+import (
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/errors"
+	"UserRESTfulApi/pkg/config"
+	"UserRESTfulApi/pkg/password"
+	"UserRESTfulApi/pkg/scim"
+	"UserRESTfulApi/pkg/token"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// scimUserResourceType is the SCIM resource type of users
+const scimUserResourceType = "User"
+
+type scimService struct {
+	users         domain.UserRepository
+	refreshTokens domain.RefreshTokenRepository
+	hasher        password.Hasher
+	policy        *password.Policy
+	audit         domain.AuditService
+	baseURL       string
+	maxResults    int
+	now           func() time.Time
+}
+
+// NewSCIMService creates a new SCIM provisioning service
+func NewSCIMService(users domain.UserRepository, refreshTokens domain.RefreshTokenRepository, hasher password.Hasher, policy *password.Policy, audit domain.AuditService, cfg config.SCIMConfig) domain.SCIMService {
+	return &scimService{
+		users:         users,
+		refreshTokens: refreshTokens,
+		hasher:        hasher,
+		policy:        policy,
+		audit:         audit,
+		baseURL:       strings.TrimSuffix(cfg.BaseURL, "/"),
+		maxResults:    cfg.MaxResults,
+		now:           time.Now,
+	}
+}
+
+// ServiceProviderConfig describes the supported SCIM features
+func (s *scimService) ServiceProviderConfig() *domain.SCIMServiceProviderConfig {
+	return &domain.SCIMServiceProviderConfig{
+		Schemas:        []string{domain.SCIMServiceProviderConfigSchema},
+		Patch:          domain.SCIMSupported{Supported: true},
+		Bulk:           domain.SCIMBulk{Supported: false},
+		Filter:         domain.SCIMFilterSupport{Supported: true, MaxResults: s.maxResults},
+		ChangePassword: domain.SCIMSupported{Supported: true},
+		Sort:           domain.SCIMSupported{Supported: false},
+		ETag:           domain.SCIMSupported{Supported: false},
+		AuthenticationSchemes: []domain.SCIMAuthenticationType{{
+			Type:        "oauthbearertoken",
+			Name:        "OAuth Bearer Token",
+			Description: "Access token of a user holding the scim:provision permission",
+			Primary:     true,
+		}},
+	}
+}
+
+// ResourceTypes lists the provisioned resource types
+func (s *scimService) ResourceTypes() []*domain.SCIMResourceType {
+	return []*domain.SCIMResourceType{{
+		Schemas:     []string{domain.SCIMResourceTypeSchema},
+		ID:          scimUserResourceType,
+		Name:        scimUserResourceType,
+		Endpoint:    "/Users",
+		Description: "User Account",
+		Schema:      domain.SCIMUserSchema,
+	}}
+}
+
+// Schemas describes the attributes of the provisioned resources
+func (s *scimService) Schemas() []*domain.SCIMSchema {
+	attribute := func(name, description string, required bool) domain.SCIMAttribute {
+		return domain.SCIMAttribute{
+			Name:        name,
+			Type:        "string",
+			Description: description,
+			Required:    required,
+			Mutability:  "readWrite",
+			Returned:    "default",
+			Uniqueness:  "none",
+		}
+	}
+
+	userName := attribute("userName", "Email address the user signs in with", true)
+	userName.Uniqueness = "server"
+	userPassword := attribute("password", "Password of the user, never returned", false)
+	userPassword.Mutability = "writeOnly"
+	userPassword.Returned = "never"
+	active := attribute("active", "Whether the user may sign in", false)
+	active.Type = "boolean"
+	emailValue := attribute("value", "Email address, the same as userName", false)
+	emailType := attribute("type", "Always work", false)
+	emailType.Mutability = "readOnly"
+	emailPrimary := attribute("primary", "Always true", false)
+	emailPrimary.Type = "boolean"
+	emailPrimary.Mutability = "readOnly"
+
+	name := attribute("name", "Components of the user's name", false)
+	name.Type = "complex"
+	name.SubAttributes = []domain.SCIMAttribute{
+		attribute("formatted", "Full name of the user", false),
+		attribute("givenName", "Given name, combined with familyName into the full name", false),
+		attribute("familyName", "Family name, combined with givenName into the full name", false),
+	}
+	emails := attribute("emails", "Email address of the user", false)
+	emails.Type = "complex"
+	emails.MultiValued = true
+	emails.SubAttributes = []domain.SCIMAttribute{emailValue, emailType, emailPrimary}
+
+	return []*domain.SCIMSchema{{
+		Schemas:     []string{domain.SCIMSchemaSchema},
+		ID:          domain.SCIMUserSchema,
+		Name:        scimUserResourceType,
+		Description: "User Account",
+		Attributes: []domain.SCIMAttribute{
+			userName,
+			name,
+			attribute("displayName", "Full name of the user", false),
+			emails,
+			active,
+			userPassword,
+		},
+	}}
+}
+
+// List returns a page of the users matching the SCIM filter. A negative
+// count returns the maximum number of results.
+func (s *scimService) List(actor *domain.Principal, filter string, startIndex, count int) (*domain.SCIMListResponse, error) {
+	if err := authorizeSCIM(actor); err != nil {
+		return nil, err
+	}
+
+	var userFilter *domain.UserFilter
+	if strings.TrimSpace(filter) != "" {
+		expr, err := scim.ParseFilter(filter)
+		if err != nil {
+			return nil, domain.NewSCIMError(http.StatusBadRequest, domain.SCIMInvalidFilter, err.Error())
+		}
+		if userFilter, err = scimUserFilter(expr, ""); err != nil {
+			return nil, err
+		}
+	}
+	if startIndex < 1 {
+		startIndex = 1
+	}
+	if count < 0 || count > s.maxResults {
+		count = s.maxResults
+	}
+
+	users, total, err := s.users.Search(userFilter, startIndex-1, count)
+	if err != nil {
+		return nil, err
+	}
+	resources := make([]*domain.SCIMUser, 0, len(users))
+	for _, user := range users {
+		resources = append(resources, s.toSCIM(user))
+	}
+	return &domain.SCIMListResponse{
+		Schemas:      []string{domain.SCIMListResponseSchema},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	}, nil
+}
+
+// Get retrieves a user by its SCIM id
+func (s *scimService) Get(actor *domain.Principal, id string) (*domain.SCIMUser, error) {
+	if err := authorizeSCIM(actor); err != nil {
+		return nil, err
+	}
+
+	user, err := s.load(id)
+	if err != nil {
+		return nil, err
+	}
+	return s.toSCIM(user), nil
+}
+
+// Create provisions a new user. Provisioned email addresses are trusted as
+// verified; without a password the user must reset it before signing in.
+func (s *scimService) Create(actor *domain.Principal, in *domain.SCIMUser) (*domain.SCIMUser, error) {
+	if err := authorizeSCIM(actor); err != nil {
+		return nil, err
+	}
+
+	email, name := scimIdentity(in)
+	if err := validateSCIMIdentity(email, name); err != nil {
+		return nil, err
+	}
+	if err := s.checkUniqueEmail(email); err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	user := &domain.User{Email: email, Name: name, EmailVerifiedAt: &now}
+	plainPassword := in.Password
+	if plainPassword == "" {
+		random, err := token.NewOpaque()
+		if err != nil {
+			return nil, errors.InternalServerError(err)
+		}
+		plainPassword = random
+	} else if err := s.validatePassword(user, plainPassword); err != nil {
+		return nil, err
+	}
+	hashed, err := s.hasher.Hash(plainPassword)
+	if err != nil {
+		return nil, errors.InternalServerError(err)
+	}
+	user.Password = hashed
+	if in.Active != nil && !*in.Active {
+		user.DeactivatedAt = &now
+	}
+
+	if err := s.users.Create(user); err != nil {
+		return nil, err
+	}
+	event := domain.NewAuditEvent(domain.AuditUserCreated, actor, actor.Request).ForUser(user.ID)
+	event.Changes = userAuditChanges(nil, user)
+	s.audit.Record(event)
+	return s.toSCIM(user), nil
+}
+
+// Replace overwrites the attributes of a user. An omitted active flag or
+// password leaves the current value unchanged.
+func (s *scimService) Replace(actor *domain.Principal, id string, in *domain.SCIMUser) (*domain.SCIMUser, error) {
+	if err := authorizeSCIM(actor); err != nil {
+		return nil, err
+	}
+
+	user, err := s.load(id)
+	if err != nil {
+		return nil, err
+	}
+	patch := newSCIMPatch(user)
+	patch.password, patch.active = in.Password, in.Active
+	user.Email, user.Name = scimIdentity(in)
+	return s.save(actor, patch)
+}
+
+// Patch applies add, replace and remove operations to a user
+func (s *scimService) Patch(actor *domain.Principal, id string, req *domain.SCIMPatchRequest) (*domain.SCIMUser, error) {
+	if err := authorizeSCIM(actor); err != nil {
+		return nil, err
+	}
+	if !containsSchema(req.Schemas, domain.SCIMPatchOpSchema) {
+		return nil, domain.NewSCIMError(http.StatusBadRequest, domain.SCIMInvalidSyntax, "the request must use the "+domain.SCIMPatchOpSchema+" schema")
+	}
+	if len(req.Operations) == 0 {
+		return nil, domain.NewSCIMError(http.StatusBadRequest, domain.SCIMInvalidSyntax, "no operations given")
+	}
+
+	user, err := s.load(id)
+	if err != nil {
+		return nil, err
+	}
+	patch := newSCIMPatch(user)
+	for _, op := range req.Operations {
+		if err := patch.apply(op); err != nil {
+			return nil, err
+		}
+	}
+	patch.applyNameParts()
+	return s.save(actor, patch)
+}
+
+// Delete deprovisions a user
+func (s *scimService) Delete(actor *domain.Principal, id string) error {
+	if err := authorizeSCIM(actor); err != nil {
+		return err
+	}
+
+	user, err := s.load(id)
+	if err != nil {
+		return err
+	}
+	if err := s.users.Delete(user.ID, user.Version); err != nil {
+		return err
+	}
+
+	event := domain.NewAuditEvent(domain.AuditUserDeleted, actor, actor.Request).ForUser(user.ID)
+	event.Changes = userAuditChanges(user, nil)
+	s.audit.Record(event)
+	return nil
+}
+
+// save validates and stores the user changed by a PUT or PATCH request.
+// Deactivating a user revokes its refresh tokens. Changes are recorded in the
+// audit log.
+func (s *scimService) save(actor *domain.Principal, patch *scimPatch) (*domain.SCIMUser, error) {
+	user := patch.user
+	if err := validateSCIMIdentity(user.Email, user.Name); err != nil {
+		return nil, err
+	}
+
+	if user.Email != patch.before.Email {
+		if err := s.checkUniqueEmail(user.Email); err != nil {
+			return nil, err
+		}
+		// The identity provider vouches for the new address
+		now := s.now()
+		user.EmailVerifiedAt = &now
+		user.PendingEmail = ""
+	}
+
+	if patch.password != "" {
+		if err := s.validatePassword(user, patch.password); err != nil {
+			return nil, err
+		}
+		hashed, err := s.hasher.Hash(patch.password)
+		if err != nil {
+			return nil, errors.InternalServerError(err)
+		}
+		user.Password = hashed
+	}
+	if patch.active != nil {
+		if *patch.active {
+			user.DeactivatedAt = nil
+		} else if user.DeactivatedAt == nil {
+			now := s.now()
+			user.DeactivatedAt = &now
+		}
+	}
+
+	if err := s.users.Update(user); err != nil {
+		return nil, err
+	}
+	event := domain.NewAuditEvent(domain.AuditUserUpdated, actor, actor.Request).ForUser(user.ID)
+	event.Changes = userAuditChanges(&patch.before, user)
+	s.audit.Record(event)
+
+	if patch.before.IsActive() && !user.IsActive() {
+		if err := s.refreshTokens.RevokeAllForUser(user.ID); err != nil {
+			return nil, err
+		}
+		revoked := domain.NewAuditEvent(domain.AuditTokensRevoked, actor, actor.Request).ForUser(user.ID)
+		revoked.Details = map[string]string{"reason": "deactivated"}
+		s.audit.Record(revoked)
+	}
+	return s.toSCIM(user), nil
+}
+
+// load retrieves the user with the SCIM id, which is the decimal user ID
+func (s *scimService) load(id string) (*domain.User, error) {
+	userID, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		return nil, scimUserNotFound(id)
+	}
+	user, err := s.users.Get(uint(userID))
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, scimUserNotFound(id)
+	}
+	copied := *user
+	return &copied, nil
+}
+
+// checkUniqueEmail rejects an email address that belongs to another user
+func (s *scimService) checkUniqueEmail(email string) error {
+	existing, err := s.users.GetByEmail(email)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return domain.NewSCIMError(http.StatusConflict, domain.SCIMUniqueness, "userName "+email+" is already taken")
+	}
+	return nil
+}
+
+// validatePassword checks a new password against the policy
+func (s *scimService) validatePassword(user *domain.User, plainPassword string) error {
+	if err := validatePassword(s.policy, plainPassword, user.Email, user.Name); err != nil {
+		return domain.NewSCIMError(http.StatusBadRequest, domain.SCIMInvalidValue, err.Error())
+	}
+	return nil
+}
+
+// toSCIM maps a user to the SCIM core User schema. The password is never returned.
+func (s *scimService) toSCIM(user *domain.User) *domain.SCIMUser {
+	id := strconv.FormatUint(uint64(user.ID), 10)
+	active := user.IsActive()
+	return &domain.SCIMUser{
+		Schemas:     []string{domain.SCIMUserSchema},
+		ID:          id,
+		UserName:    user.Email,
+		Name:        &domain.SCIMName{Formatted: user.Name},
+		DisplayName: user.Name,
+		Emails:      []domain.SCIMEmail{{Value: user.Email, Type: "work", Primary: true}},
+		Active:      &active,
+		Meta: &domain.SCIMMeta{
+			ResourceType: scimUserResourceType,
+			Created:      user.CreatedAt,
+			LastModified: user.UpdatedAt,
+			Location:     s.baseURL + "/Users/" + id,
+		},
+	}
+}
+
+// authorizeSCIM rejects principals without the scim:provision permission
+func authorizeSCIM(actor *domain.Principal) error {
+	if !actor.Can(domain.PermissionSCIMProvision) {
+		return domain.NewSCIMError(http.StatusForbidden, "", "Forbidden: missing permission "+string(domain.PermissionSCIMProvision))
+	}
+	return nil
+}
+
+func scimUserNotFound(id string) error {
+	return domain.NewSCIMError(http.StatusNotFound, "", "User "+id+" not found")
+}
+
+// scimIdentity returns the email and name of a SCIM user. The email is
+// userName or else the primary email; the name is name.formatted, else the
+// given and family name, else displayName.
+func scimIdentity(in *domain.SCIMUser) (string, string) {
+	email := in.UserName
+	if email == "" {
+		email = primaryEmail(in.Emails)
+	}
+
+	name := ""
+	if in.Name != nil {
+		name = in.Name.Formatted
+		if name == "" {
+			name = strings.TrimSpace(in.Name.GivenName + " " + in.Name.FamilyName)
+		}
+	}
+	if name == "" {
+		name = in.DisplayName
+	}
+	return strings.TrimSpace(email), strings.TrimSpace(name)
+}
+
+// primaryEmail returns the primary address, or the first one when none is marked primary
+func primaryEmail(emails []domain.SCIMEmail) string {
+	for _, email := range emails {
+		if email.Primary {
+			return email.Value
+		}
+	}
+	if len(emails) > 0 {
+		return emails[0].Value
+	}
+	return ""
+}
+
+func validateSCIMIdentity(email, name string) error {
+	if email == "" {
+		return domain.NewSCIMError(http.StatusBadRequest, domain.SCIMInvalidValue, "userName is required")
+	}
+	if _, err := mail.ParseAddress(email); err != nil {
+		return domain.NewSCIMError(http.StatusBadRequest, domain.SCIMInvalidValue, "userName must be an email address")
+	}
+	if name == "" {
+		return domain.NewSCIMError(http.StatusBadRequest, domain.SCIMInvalidValue, "name or displayName is required")
+	}
+	return nil
+}
+
+func containsSchema(schemas []string, schema string) bool {
+	for _, s := range schemas {
+		if s == schema {
+			return true
+		}
+	}
+	return false
+}
+
+// scimPatch collects the changes of a PUT or PATCH request to a user
+type scimPatch struct {
+	user *domain.User
+	// before is a copy of the user before the request
+	before   domain.User
+	password string
+	active   *bool
+	// givenName and familyName replace their part of the full name
+	givenName, familyName *string
+}
+
+func newSCIMPatch(user *domain.User) *scimPatch {
+	return &scimPatch{user: user, before: *user}
+}
+
+// apply applies a single PATCH operation. Without a path, the value is an
+// object mapping attribute paths to their new values.
+func (p *scimPatch) apply(op domain.SCIMPatchOperation) error {
+	operation := strings.ToLower(op.Op)
+	if operation != "add" && operation != "replace" && operation != "remove" {
+		return domain.NewSCIMError(http.StatusBadRequest, domain.SCIMInvalidSyntax, fmt.Sprintf("unsupported operation %q", op.Op))
+	}
+
+	if op.Path == "" {
+		if operation == "remove" {
+			return domain.NewSCIMError(http.StatusBadRequest, domain.SCIMNoTarget, "remove requires a path")
+		}
+		var values map[string]json.RawMessage
+		if err := json.Unmarshal(op.Value, &values); err != nil {
+			return domain.NewSCIMError(http.StatusBadRequest, domain.SCIMInvalidValue, "value must be an object when no path is given")
+		}
+		for attribute, value := range values {
+			if err := p.set(attribute, value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if operation == "remove" {
+		path, err := parseSCIMPath(op.Path)
+		if err != nil {
+			return err
+		}
+		if _, known := scimPatchPaths[path]; !known {
+			return domain.NewSCIMError(http.StatusBadRequest, domain.SCIMInvalidPath, fmt.Sprintf("unknown attribute %s", op.Path))
+		}
+		return domain.NewSCIMError(http.StatusBadRequest, domain.SCIMMutability, fmt.Sprintf("%s cannot be removed", op.Path))
+	}
+	return p.set(op.Path, op.Value)
+}
+
+// scimPatchPaths lists the attribute paths a PATCH operation may target, in lower case
+var scimPatchPaths = map[string]bool{
+	"username":        true,
+	"displayname":     true,
+	"name":            true,
+	"name.formatted":  true,
+	"name.givenname":  true,
+	"name.familyname": true,
+	"emails":          true,
+	"emails.value":    true,
+	"active":          true,
+	"password":        true,
+}
+
+// parseSCIMPath parses a PATCH path into its lower case form. A value filter
+// on emails is accepted since a user has a single address.
+func parseSCIMPath(rawPath string) (string, error) {
+	path, err := scim.ParsePath(rawPath)
+	if err != nil {
+		return "", domain.NewSCIMError(http.StatusBadRequest, domain.SCIMInvalidPath, err.Error())
+	}
+	if path.URN != "" && !strings.EqualFold(path.URN, domain.SCIMUserSchema) {
+		return "", domain.NewSCIMError(http.StatusBadRequest, domain.SCIMInvalidPath, fmt.Sprintf("unknown schema %s", path.URN))
+	}
+	if path.Filter != nil && !strings.EqualFold(path.Attribute, "emails") {
+		return "", domain.NewSCIMError(http.StatusBadRequest, domain.SCIMInvalidPath, fmt.Sprintf("%s is not multi-valued", path.Attribute))
+	}
+	return strings.ToLower(path.String()), nil
+}
+
+// set replaces the value of the attribute at the path
+func (p *scimPatch) set(rawPath string, value json.RawMessage) error {
+	path, err := parseSCIMPath(rawPath)
+	if err != nil {
+		return err
+	}
+
+	switch path {
+	case "username", "emails.value":
+		return decodeSCIMValue(rawPath, value, &p.user.Email)
+	case "displayname", "name.formatted":
+		return decodeSCIMValue(rawPath, value, &p.user.Name)
+	case "name.givenname":
+		p.givenName = new(string)
+		return decodeSCIMValue(rawPath, value, p.givenName)
+	case "name.familyname":
+		p.familyName = new(string)
+		return decodeSCIMValue(rawPath, value, p.familyName)
+	case "name":
+		var name domain.SCIMName
+		if err := decodeSCIMValue(rawPath, value, &name); err != nil {
+			return err
+		}
+		if name.Formatted != "" {
+			p.user.Name = name.Formatted
+		} else {
+			p.givenName, p.familyName = &name.GivenName, &name.FamilyName
+		}
+	case "emails":
+		var emails []domain.SCIMEmail
+		if err := decodeSCIMValue(rawPath, value, &emails); err != nil {
+			return err
+		}
+		if email := primaryEmail(emails); email != "" {
+			p.user.Email = email
+		}
+	case "active":
+		// Some identity providers send the flag as a string
+		var active interface{}
+		if err := decodeSCIMValue(rawPath, value, &active); err != nil {
+			return err
+		}
+		switch v := active.(type) {
+		case bool:
+			p.active = &v
+		case string:
+			parsed, err := strconv.ParseBool(v)
+			if err != nil {
+				return domain.NewSCIMError(http.StatusBadRequest, domain.SCIMInvalidValue, "active must be a boolean")
+			}
+			p.active = &parsed
+		default:
+			return domain.NewSCIMError(http.StatusBadRequest, domain.SCIMInvalidValue, "active must be a boolean")
+		}
+	case "password":
+		return decodeSCIMValue(rawPath, value, &p.password)
+	case "id", "meta", "schemas":
+		return domain.NewSCIMError(http.StatusBadRequest, domain.SCIMMutability, fmt.Sprintf("%s is read-only", rawPath))
+	default:
+		return domain.NewSCIMError(http.StatusBadRequest, domain.SCIMInvalidPath, fmt.Sprintf("unknown attribute %s", rawPath))
+	}
+	return nil
+}
+
+// applyNameParts replaces the given or family name part of the full name,
+// taking the first word of the current name as the given name
+func (p *scimPatch) applyNameParts() {
+	if p.givenName == nil && p.familyName == nil {
+		return
+	}
+	given, family, _ := strings.Cut(p.user.Name, " ")
+	if p.givenName != nil {
+		given = *p.givenName
+	}
+	if p.familyName != nil {
+		family = *p.familyName
+	}
+	p.user.Name = strings.TrimSpace(given + " " + family)
+}
+
+func decodeSCIMValue(path string, value json.RawMessage, target interface{}) error {
+	if err := json.Unmarshal(value, target); err != nil {
+		return domain.NewSCIMError(http.StatusBadRequest, domain.SCIMInvalidValue, fmt.Sprintf("invalid value for %s", path))
+	}
+	return nil
+}
+
+// scimUserFields maps the filterable SCIM attributes, in lower case, to user fields
+var scimUserFields = map[string]string{
+	"id":                domain.UserFieldID,
+	"username":          domain.UserFieldEmail,
+	"emails":            domain.UserFieldEmail,
+	"emails.value":      domain.UserFieldEmail,
+	"displayname":       domain.UserFieldName,
+	"name.formatted":    domain.UserFieldName,
+	"active":            domain.UserFieldActive,
+	"meta.created":      domain.UserFieldCreatedAt,
+	"meta.lastmodified": domain.UserFieldUpdatedAt,
+}
+
+var scimFilterOperators = map[string]string{
+	scim.OperatorEqual:          domain.FilterEqual,
+	scim.OperatorNotEqual:       domain.FilterNotEqual,
+	scim.OperatorContains:       domain.FilterContains,
+	scim.OperatorStartsWith:     domain.FilterStartsWith,
+	scim.OperatorEndsWith:       domain.FilterEndsWith,
+	scim.OperatorGreater:        domain.FilterGreater,
+	scim.OperatorGreaterOrEqual: domain.FilterGreaterOrEqual,
+	scim.OperatorLess:           domain.FilterLess,
+	scim.OperatorLessOrEqual:    domain.FilterLessOrEqual,
+	scim.OperatorPresent:        domain.FilterPresent,
+}
+
+// scimUserFilter translates a parsed SCIM filter into a user filter. Inside
+// a value filter such as emails[value co "x"], parent is the filtered attribute.
+func scimUserFilter(expr scim.Expression, parent string) (*domain.UserFilter, error) {
+	switch e := expr.(type) {
+	case *scim.Logical:
+		left, err := scimUserFilter(e.Left, parent)
+		if err != nil {
+			return nil, err
+		}
+		right, err := scimUserFilter(e.Right, parent)
+		if err != nil {
+			return nil, err
+		}
+		operator := domain.FilterAnd
+		if e.Operator == scim.OperatorOr {
+			operator = domain.FilterOr
+		}
+		return &domain.UserFilter{Operator: operator, Operands: []*domain.UserFilter{left, right}}, nil
+	case *scim.Not:
+		operand, err := scimUserFilter(e.Expression, parent)
+		if err != nil {
+			return nil, err
+		}
+		return &domain.UserFilter{Operator: domain.FilterNot, Operands: []*domain.UserFilter{operand}}, nil
+	case *scim.ValuePath:
+		if parent != "" || e.Path.SubAttribute != "" {
+			return nil, domain.NewSCIMError(http.StatusBadRequest, domain.SCIMInvalidFilter, "nested value filters are not supported")
+		}
+		if err := checkSCIMSchema(e.Path); err != nil {
+			return nil, err
+		}
+		return scimUserFilter(e.Filter, e.Path.Attribute)
+	case *scim.Comparison:
+		return scimComparison(e, parent)
+	default:
+		return nil, domain.NewSCIMError(http.StatusBadRequest, domain.SCIMInvalidFilter, "unsupported filter")
+	}
+}
+
+func scimComparison(e *scim.Comparison, parent string) (*domain.UserFilter, error) {
+	if err := checkSCIMSchema(e.Path); err != nil {
+		return nil, err
+	}
+	attribute := e.Path.String()
+	if parent != "" {
+		attribute = parent + "." + attribute
+	}
+	field, ok := scimUserFields[strings.ToLower(attribute)]
+	if !ok {
+		return nil, domain.NewSCIMError(http.StatusBadRequest, domain.SCIMInvalidFilter, fmt.Sprintf("filtering on %s is not supported", attribute))
+	}
+
+	filter := &domain.UserFilter{Operator: scimFilterOperators[e.Operator], Field: field}
+	if filter.Operator == domain.FilterPresent {
+		return filter, nil
+	}
+	invalid := domain.NewSCIMError(http.StatusBadRequest, domain.SCIMInvalidFilter, fmt.Sprintf("invalid comparison %s %s %v", attribute, e.Operator, e.Value))
+	text, isString := e.Value.(string)
+
+	switch field {
+	case domain.UserFieldEmail, domain.UserFieldName:
+		if !isString {
+			return nil, invalid
+		}
+		filter.Value = text
+		return filter, nil
+	}
+
+	// The remaining fields are not strings, so substring matching does not apply
+	if filter.Operator == domain.FilterContains || filter.Operator == domain.FilterStartsWith || filter.Operator == domain.FilterEndsWith {
+		return nil, invalid
+	}
+	switch field {
+	case domain.UserFieldID:
+		id, err := strconv.ParseUint(text, 10, 32)
+		if !isString || err != nil {
+			return nil, invalid
+		}
+		filter.Value = uint(id)
+	case domain.UserFieldActive:
+		active, isBool := e.Value.(bool)
+		if !isBool || (filter.Operator != domain.FilterEqual && filter.Operator != domain.FilterNotEqual) {
+			return nil, invalid
+		}
+		filter.Value = active
+	default:
+		at, err := time.Parse(time.RFC3339, text)
+		if !isString || err != nil {
+			return nil, invalid
+		}
+		filter.Value = at
+	}
+	return filter, nil
+}
+
+// checkSCIMSchema rejects attributes qualified with a schema other than the core User schema
+func checkSCIMSchema(path scim.AttributePath) error {
+	if path.URN != "" && !strings.EqualFold(path.URN, domain.SCIMUserSchema) {
+		return domain.NewSCIMError(http.StatusBadRequest, domain.SCIMInvalidFilter, fmt.Sprintf("unknown schema %s", path.URN))
+	}
+	return nil
+}
@@ -0,0 +1,527 @@
+package service
+
+import (
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/pkg/config"
+	"UserRESTfulApi/pkg/password"
+	"UserRESTfulApi/pkg/scim"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// newTestSCIMService creates a SCIM service listing at most two users per page
+func newTestSCIMService() (*scimService, *mockUserRepository, *mockRefreshTokenRepository) {
+	users := newMockUserRepository()
+	refreshTokens := newMockRefreshTokenRepository()
+	service := NewSCIMService(users, refreshTokens, testHasher, password.DefaultPolicy(), NewAuditService(newMockAuditRepository()), config.SCIMConfig{
+		BaseURL:    "https://id.example.com/scim/v2/",
+		MaxResults: 2,
+	}).(*scimService)
+	return service, users, refreshTokens
+}
+
+// createSCIMUser provisions a user named after the given name and Jensen
+func createSCIMUser(t *testing.T, service domain.SCIMService, email, name string) *domain.SCIMUser {
+	t.Helper()
+	created, err := service.Create(domain.SystemPrincipal(), &domain.SCIMUser{
+		Schemas:  []string{domain.SCIMUserSchema},
+		UserName: email,
+		Name:     &domain.SCIMName{GivenName: name, FamilyName: "Jensen"},
+	})
+	if err != nil {
+		t.Fatalf("Create(%s) error = %v", email, err)
+	}
+	return created
+}
+
+func assertSCIMError(t *testing.T, name string, err error, status int, scimType string) {
+	t.Helper()
+	scimErr, ok := err.(*domain.SCIMError)
+	if !ok || scimErr.Status != strconv.Itoa(status) || scimErr.SCIMType != scimType {
+		t.Errorf("%s error = %#v, want %d %s", name, err, status, scimType)
+	}
+}
+
+func TestSCIMUserFilter(t *testing.T) {
+	created, _ := time.Parse(time.RFC3339, "2024-01-02T03:04:05Z")
+	tests := []struct {
+		filter string
+		want   *domain.UserFilter
+	}{
+		{
+			`userName eq "Bjensen@Example.com"`,
+			&domain.UserFilter{Operator: domain.FilterEqual, Field: domain.UserFieldEmail, Value: "Bjensen@Example.com"},
+		},
+		{
+			`emails[value co "example.com"] or not (active eq false)`,
+			&domain.UserFilter{Operator: domain.FilterOr, Operands: []*domain.UserFilter{
+				{Operator: domain.FilterContains, Field: domain.UserFieldEmail, Value: "example.com"},
+				{Operator: domain.FilterNot, Operands: []*domain.UserFilter{
+					{Operator: domain.FilterEqual, Field: domain.UserFieldActive, Value: false},
+				}},
+			}},
+		},
+		{
+			`id eq "7" and meta.created ge "2024-01-02T03:04:05Z"`,
+			&domain.UserFilter{Operator: domain.FilterAnd, Operands: []*domain.UserFilter{
+				{Operator: domain.FilterEqual, Field: domain.UserFieldID, Value: uint(7)},
+				{Operator: domain.FilterGreaterOrEqual, Field: domain.UserFieldCreatedAt, Value: created},
+			}},
+		},
+		{
+			`urn:ietf:params:scim:schemas:core:2.0:User:displayName pr`,
+			&domain.UserFilter{Operator: domain.FilterPresent, Field: domain.UserFieldName},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			expr, err := scim.ParseFilter(tt.filter)
+			if err != nil {
+				t.Fatalf("ParseFilter() error = %v", err)
+			}
+			got, err := scimUserFilter(expr, "")
+			if err != nil {
+				t.Fatalf("scimUserFilter() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("scimUserFilter() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+
+	invalid := []string{
+		`title eq "Manager"`,
+		`userName eq 42`,
+		`active co "t"`,
+		`id eq "abc"`,
+		`meta.lastModified gt "yesterday"`,
+		`emails[type eq "work"]`,
+		`urn:example:Extension:userName eq "a"`,
+	}
+	for _, filter := range invalid {
+		expr, err := scim.ParseFilter(filter)
+		if err != nil {
+			t.Fatalf("ParseFilter(%q) error = %v", filter, err)
+		}
+		_, err = scimUserFilter(expr, "")
+		assertSCIMError(t, "scimUserFilter("+filter+")", err, http.StatusBadRequest, domain.SCIMInvalidFilter)
+	}
+}
+
+// scimError is the status and scimType of an expected SCIM error
+type scimError struct {
+	status   int
+	scimType string
+}
+
+func scimValue(v interface{}) json.RawMessage {
+	raw, _ := json.Marshal(v)
+	return raw
+}
+
+func TestSCIMCreate(t *testing.T) {
+	tests := []struct {
+		name    string
+		actor   *domain.Principal
+		in      *domain.SCIMUser
+		wantErr *scimError
+	}{
+		{
+			name:  "user",
+			actor: domain.SystemPrincipal(),
+			in:    &domain.SCIMUser{Schemas: []string{domain.SCIMUserSchema}, UserName: "bjensen@example.com", Name: &domain.SCIMName{GivenName: "Barbara", FamilyName: "Jensen"}},
+		},
+		{
+			name:    "taken userName",
+			actor:   domain.SystemPrincipal(),
+			in:      &domain.SCIMUser{UserName: "ajensen@example.com", DisplayName: "Again"},
+			wantErr: &scimError{http.StatusConflict, domain.SCIMUniqueness},
+		},
+		{
+			name:    "invalid userName",
+			actor:   domain.SystemPrincipal(),
+			in:      &domain.SCIMUser{UserName: "not an email", DisplayName: "Invalid"},
+			wantErr: &scimError{http.StatusBadRequest, domain.SCIMInvalidValue},
+		},
+		{
+			name:    "no name",
+			actor:   domain.SystemPrincipal(),
+			in:      &domain.SCIMUser{UserName: "nameless@example.com"},
+			wantErr: &scimError{http.StatusBadRequest, domain.SCIMInvalidValue},
+		},
+		{
+			name:    "weak password",
+			actor:   domain.SystemPrincipal(),
+			in:      &domain.SCIMUser{UserName: "weak@example.com", DisplayName: "Weak", Password: "weak"},
+			wantErr: &scimError{http.StatusBadRequest, domain.SCIMInvalidValue},
+		},
+		{
+			name:    "without the provisioning permission",
+			actor:   domain.NewPrincipal(&domain.User{ID: 1}, nil),
+			in:      &domain.SCIMUser{UserName: "other@example.com", DisplayName: "Other"},
+			wantErr: &scimError{http.StatusForbidden, ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, users, _ := newTestSCIMService()
+			createSCIMUser(t, service, "ajensen@example.com", "Anna")
+
+			user, err := service.Create(tt.actor, tt.in)
+			if tt.wantErr != nil {
+				assertSCIMError(t, "Create()", err, tt.wantErr.status, tt.wantErr.scimType)
+				if len(users.users) != 1 {
+					t.Errorf("failed Create() stored a user")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+
+			if user.ID != "2" || user.DisplayName != "Barbara Jensen" || user.Active == nil || !*user.Active {
+				t.Fatalf("Create() = %+v, want an active user named Barbara Jensen", user)
+			}
+			if user.Meta.Location != "https://id.example.com/scim/v2/Users/2" || user.Password != "" {
+				t.Errorf("Create() meta = %+v, password = %q", user.Meta, user.Password)
+			}
+			if stored := users.users[2]; !stored.IsEmailVerified() || stored.Password == "" {
+				t.Error("Create() did not store a verified user with an unusable password")
+			}
+		})
+	}
+}
+
+func TestSCIMList(t *testing.T) {
+	tests := []struct {
+		name       string
+		actor      *domain.Principal
+		filter     string
+		startIndex int
+		count      int
+		wantTotal  int64
+		wantStart  int
+		wantIDs    []string
+		wantErr    *scimError
+	}{
+		{
+			name:      "userName is case insensitive",
+			actor:     domain.SystemPrincipal(),
+			filter:    `userName eq "BJENSEN@example.com"`,
+			count:     -1,
+			wantTotal: 1,
+			wantStart: 1,
+			wantIDs:   []string{"1"},
+		},
+		{
+			name:       "second page capped by MaxResults",
+			actor:      domain.SystemPrincipal(),
+			filter:     `emails.value co "example.com" or userName eq "kim@example.org"`,
+			startIndex: 2,
+			count:      10,
+			wantTotal:  3,
+			wantStart:  2,
+			wantIDs:    []string{"2", "3"},
+		},
+		{
+			name:    "malformed filter",
+			actor:   domain.SystemPrincipal(),
+			filter:  `userName eq`,
+			count:   10,
+			wantErr: &scimError{http.StatusBadRequest, domain.SCIMInvalidFilter},
+		},
+		{
+			name:    "unsupported attribute",
+			actor:   domain.SystemPrincipal(),
+			filter:  `title eq "Manager"`,
+			count:   10,
+			wantErr: &scimError{http.StatusBadRequest, domain.SCIMInvalidFilter},
+		},
+		{
+			name:    "without the provisioning permission",
+			actor:   domain.NewPrincipal(&domain.User{ID: 1}, nil),
+			count:   10,
+			wantErr: &scimError{http.StatusForbidden, ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, _, _ := newTestSCIMService()
+			createSCIMUser(t, service, "bjensen@example.com", "Barbara")
+			createSCIMUser(t, service, "ajensen@example.com", "Anna")
+			createSCIMUser(t, service, "kim@example.org", "Kim")
+
+			list, err := service.List(tt.actor, tt.filter, tt.startIndex, tt.count)
+			if tt.wantErr != nil {
+				assertSCIMError(t, "List()", err, tt.wantErr.status, tt.wantErr.scimType)
+				return
+			}
+			if err != nil {
+				t.Fatalf("List() error = %v", err)
+			}
+
+			resources := list.Resources.([]*domain.SCIMUser)
+			if list.TotalResults != tt.wantTotal || list.StartIndex != tt.wantStart || list.ItemsPerPage != len(tt.wantIDs) || len(resources) != len(tt.wantIDs) {
+				t.Fatalf("List() = %+v, want %d of %d results from %d", list, len(tt.wantIDs), tt.wantTotal, tt.wantStart)
+			}
+			for i, user := range resources {
+				if user.ID != tt.wantIDs[i] {
+					t.Errorf("List()[%d] = user %s, want %s", i, user.ID, tt.wantIDs[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSCIMGet(t *testing.T) {
+	tests := []struct {
+		name    string
+		actor   *domain.Principal
+		id      string
+		wantErr *scimError
+	}{
+		{name: "user", actor: domain.SystemPrincipal(), id: "1"},
+		{name: "malformed id", actor: domain.SystemPrincipal(), id: "abc", wantErr: &scimError{http.StatusNotFound, ""}},
+		{name: "without the provisioning permission", actor: domain.NewPrincipal(&domain.User{ID: 1}, nil), id: "1", wantErr: &scimError{http.StatusForbidden, ""}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, _, _ := newTestSCIMService()
+			createSCIMUser(t, service, "bjensen@example.com", "Barbara")
+
+			user, err := service.Get(tt.actor, tt.id)
+			if tt.wantErr != nil {
+				assertSCIMError(t, "Get()", err, tt.wantErr.status, tt.wantErr.scimType)
+				return
+			}
+			if err != nil || user.ID != tt.id || user.UserName != "bjensen@example.com" {
+				t.Errorf("Get() = %+v, %v, want bjensen@example.com", user, err)
+			}
+		})
+	}
+}
+
+func TestSCIMPatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		schemas  []string
+		ops      []domain.SCIMPatchOperation
+		wantName string
+		wantUser string
+		// wantActive is whether the user is active after the patch
+		wantActive bool
+		wantErr    *scimError
+	}{
+		{
+			name: "name and work email",
+			ops: []domain.SCIMPatchOperation{
+				{Op: "Replace", Path: "name.givenName", Value: scimValue("Babs")},
+				{Op: "replace", Path: `emails[type eq "work"].value`, Value: scimValue("babs@example.com")},
+			},
+			wantName:   "Babs Jensen",
+			wantUser:   "babs@example.com",
+			wantActive: true,
+		},
+		{
+			// Identity providers commonly send the active flag as a string in a value object
+			name:     "deactivate with a value object",
+			ops:      []domain.SCIMPatchOperation{{Op: "Replace", Value: scimValue(map[string]interface{}{"active": "False"})}},
+			wantName: "Barbara Jensen",
+			wantUser: "bjensen@example.com",
+		},
+		{
+			name:       "active with a path",
+			ops:        []domain.SCIMPatchOperation{{Op: "replace", Path: "active", Value: scimValue(true)}},
+			wantName:   "Barbara Jensen",
+			wantUser:   "bjensen@example.com",
+			wantActive: true,
+		},
+		{
+			name:    "unknown attribute",
+			ops:     []domain.SCIMPatchOperation{{Op: "replace", Path: "title", Value: scimValue("Manager")}},
+			wantErr: &scimError{http.StatusBadRequest, domain.SCIMInvalidPath},
+		},
+		{
+			name:    "remove userName",
+			ops:     []domain.SCIMPatchOperation{{Op: "remove", Path: "userName"}},
+			wantErr: &scimError{http.StatusBadRequest, domain.SCIMMutability},
+		},
+		{
+			name:    "replace id",
+			ops:     []domain.SCIMPatchOperation{{Op: "replace", Path: "id", Value: scimValue("2")}},
+			wantErr: &scimError{http.StatusBadRequest, domain.SCIMMutability},
+		},
+		{
+			name:    "unsupported operation",
+			ops:     []domain.SCIMPatchOperation{{Op: "move", Path: "userName"}},
+			wantErr: &scimError{http.StatusBadRequest, domain.SCIMInvalidSyntax},
+		},
+		{
+			name:    "active that is not a boolean",
+			ops:     []domain.SCIMPatchOperation{{Op: "replace", Path: "active", Value: scimValue("sometimes")}},
+			wantErr: &scimError{http.StatusBadRequest, domain.SCIMInvalidValue},
+		},
+		{
+			// Operations before the failing one are not applied either
+			name: "taken userName after a valid operation",
+			ops: []domain.SCIMPatchOperation{
+				{Op: "replace", Path: "name.givenName", Value: scimValue("Babs")},
+				{Op: "replace", Path: "userName", Value: scimValue("ajensen@example.com")},
+			},
+			wantErr: &scimError{http.StatusConflict, domain.SCIMUniqueness},
+		},
+		{
+			name:    "no operations",
+			wantErr: &scimError{http.StatusBadRequest, domain.SCIMInvalidSyntax},
+		},
+		{
+			name:    "no schema",
+			schemas: []string{},
+			ops:     []domain.SCIMPatchOperation{{Op: "replace", Path: "active", Value: scimValue(true)}},
+			wantErr: &scimError{http.StatusBadRequest, domain.SCIMInvalidSyntax},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, users, refreshTokens := newTestSCIMService()
+			createSCIMUser(t, service, "bjensen@example.com", "Barbara")
+			createSCIMUser(t, service, "ajensen@example.com", "Anna")
+			refreshTokens.Create(&domain.RefreshToken{UserID: 1, FamilyID: "family"})
+			schemas := tt.schemas
+			if schemas == nil {
+				schemas = []string{domain.SCIMPatchOpSchema}
+			}
+
+			user, err := service.Patch(domain.SystemPrincipal(), "1", &domain.SCIMPatchRequest{Schemas: schemas, Operations: tt.ops})
+			if tt.wantErr != nil {
+				assertSCIMError(t, "Patch()", err, tt.wantErr.status, tt.wantErr.scimType)
+				if stored := users.users[1]; stored.Name != "Barbara Jensen" || stored.Email != "bjensen@example.com" || !stored.IsActive() {
+					t.Errorf("a failed PATCH request changed the user to %+v", stored)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Patch() error = %v", err)
+			}
+
+			if user.DisplayName != tt.wantName || user.UserName != tt.wantUser || *user.Active != tt.wantActive || users.users[1].IsActive() != tt.wantActive {
+				t.Errorf("Patch() = %+v, want %s <%s> active = %v", user, tt.wantName, tt.wantUser, tt.wantActive)
+			}
+			if revoked := refreshTokens.tokens[1].RevokedAt != nil; revoked == tt.wantActive {
+				t.Errorf("refresh token revoked = %v, want %v", revoked, !tt.wantActive)
+			}
+		})
+	}
+}
+
+func TestSCIMReplace(t *testing.T) {
+	inactive := false
+	tests := []struct {
+		name    string
+		id      string
+		in      *domain.SCIMUser
+		wantErr *scimError
+	}{
+		{
+			name: "user",
+			id:   "1",
+			in:   &domain.SCIMUser{UserName: "barbara@example.com", DisplayName: "Barbara J.", Active: &inactive, Password: "NewPassword123!"},
+		},
+		{
+			name:    "taken userName",
+			id:      "1",
+			in:      &domain.SCIMUser{UserName: "ajensen@example.com", DisplayName: "Taken"},
+			wantErr: &scimError{http.StatusConflict, domain.SCIMUniqueness},
+		},
+		{
+			name:    "no userName",
+			id:      "1",
+			in:      &domain.SCIMUser{DisplayName: "Nobody"},
+			wantErr: &scimError{http.StatusBadRequest, domain.SCIMInvalidValue},
+		},
+		{
+			name:    "weak password",
+			id:      "1",
+			in:      &domain.SCIMUser{UserName: "bjensen@example.com", DisplayName: "Barbara Jensen", Password: "weak"},
+			wantErr: &scimError{http.StatusBadRequest, domain.SCIMInvalidValue},
+		},
+		{
+			name:    "malformed id",
+			id:      "abc",
+			in:      &domain.SCIMUser{UserName: "barbara@example.com", DisplayName: "Barbara J."},
+			wantErr: &scimError{http.StatusNotFound, ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, users, _ := newTestSCIMService()
+			createSCIMUser(t, service, "bjensen@example.com", "Barbara")
+			createSCIMUser(t, service, "ajensen@example.com", "Anna")
+			passwordHash := users.users[1].Password
+
+			user, err := service.Replace(domain.SystemPrincipal(), tt.id, tt.in)
+			if tt.wantErr != nil {
+				assertSCIMError(t, "Replace()", err, tt.wantErr.status, tt.wantErr.scimType)
+				if stored := users.users[1]; stored.Email != "bjensen@example.com" || stored.Password != passwordHash {
+					t.Errorf("a failed PUT request changed the user to %+v", stored)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Replace() error = %v", err)
+			}
+
+			if user.UserName != "barbara@example.com" || user.DisplayName != "Barbara J." || *user.Active {
+				t.Errorf("Replace() = %+v", user)
+			}
+			if !testHasher.Verify("NewPassword123!", users.users[1].Password) {
+				t.Error("Replace() did not change the password")
+			}
+		})
+	}
+}
+
+func TestSCIMDelete(t *testing.T) {
+	tests := []struct {
+		name    string
+		actor   *domain.Principal
+		id      string
+		wantErr *scimError
+	}{
+		{name: "user", actor: domain.SystemPrincipal(), id: "1"},
+		{name: "malformed id", actor: domain.SystemPrincipal(), id: "abc", wantErr: &scimError{http.StatusNotFound, ""}},
+		{name: "without the provisioning permission", actor: domain.NewPrincipal(&domain.User{ID: 1}, nil), id: "1", wantErr: &scimError{http.StatusForbidden, ""}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, users, _ := newTestSCIMService()
+			createSCIMUser(t, service, "bjensen@example.com", "Barbara")
+
+			err := service.Delete(tt.actor, tt.id)
+			if tt.wantErr != nil {
+				assertSCIMError(t, "Delete()", err, tt.wantErr.status, tt.wantErr.scimType)
+				if _, exists := users.users[1]; !exists {
+					t.Error("a failed DELETE request removed the user")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Delete() error = %v", err)
+			}
+			if _, exists := users.users[1]; exists {
+				t.Error("Delete() kept the user")
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/errors"
+	"time"
+)
+
+type sessionService struct {
+	sessions      domain.SessionRepository
+	refreshTokens domain.RefreshTokenRepository
+	audit         domain.AuditService
+	now           func() time.Time
+}
+
+// NewSessionService creates a new session management service
+func NewSessionService(sessions domain.SessionRepository, refreshTokens domain.RefreshTokenRepository, audit domain.AuditService) domain.SessionService {
+	return &sessionService{sessions: sessions, refreshTokens: refreshTokens, audit: audit, now: time.Now}
+}
+
+// List returns the active sessions of the user, marking the actor's own session
+func (s *sessionService) List(actor *domain.Principal, userID uint) ([]*domain.Session, error) {
+	if !actor.CanAccessUser(domain.PermissionSessionsManage, userID) {
+		return nil, errors.ForbiddenError("list the sessions of this user")
+	}
+
+	sessions, err := s.sessions.ListActive(userID, s.now())
+	if err != nil {
+		return nil, err
+	}
+	for _, session := range sessions {
+		session.Current = actor.SessionID != "" && session.ID == actor.SessionID
+	}
+	return sessions, nil
+}
+
+// Revoke ends one session of the user. Its refresh tokens are revoked and its
+// access tokens are rejected from the next request on.
+func (s *sessionService) Revoke(actor *domain.Principal, userID uint, sessionID string) error {
+	if !actor.CanAccessUser(domain.PermissionSessionsManage, userID) {
+		return errors.ForbiddenError("revoke the sessions of this user")
+	}
+
+	session, err := s.sessions.Get(sessionID)
+	if err != nil {
+		return err
+	}
+	if session == nil || session.UserID != userID || !session.IsActive(s.now()) {
+		return errors.NotFoundError("session", sessionID)
+	}
+	if err := s.refreshTokens.RevokeFamily(session.ID); err != nil {
+		return err
+	}
+
+	event := domain.NewAuditEvent(domain.AuditTokensRevoked, actor, actor.Request).ForUser(userID)
+	event.Details = map[string]string{"reason": "session_revoked", "session_id": session.ID}
+	s.audit.Record(event)
+	return nil
+}
+
+// RevokeAll ends every session of the user, so administrators can force a
+// user to log in again
+func (s *sessionService) RevokeAll(actor *domain.Principal, userID uint) error {
+	if !actor.CanAccessUser(domain.PermissionSessionsManage, userID) {
+		return errors.ForbiddenError("revoke the sessions of this user")
+	}
+
+	if err := s.refreshTokens.RevokeAllForUser(userID); err != nil {
+		return err
+	}
+
+	event := domain.NewAuditEvent(domain.AuditTokensRevoked, actor, actor.Request).ForUser(userID)
+	event.Details = map[string]string{"reason": "sessions_revoked"}
+	s.audit.Record(event)
+	return nil
+}
@@ -0,0 +1,276 @@
+package service
+
+import (
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/errors"
+	"UserRESTfulApi/pkg/config"
+	"UserRESTfulApi/pkg/useragent"
+	"testing"
+	"time"
+)
+
+// Mock session repository for testing
+type mockSessionRepository struct {
+	sessions map[string]*domain.Session
+}
+
+func newMockSessionRepository() *mockSessionRepository {
+	return &mockSessionRepository{sessions: make(map[string]*domain.Session)}
+}
+
+func (m *mockSessionRepository) Create(session *domain.Session) error {
+	m.sessions[session.ID] = session
+	return nil
+}
+
+func (m *mockSessionRepository) Get(id string) (*domain.Session, error) {
+	session, exists := m.sessions[id]
+	if !exists {
+		return nil, nil
+	}
+	copied := *session
+	return &copied, nil
+}
+
+func (m *mockSessionRepository) ListActive(userID uint, now time.Time) ([]*domain.Session, error) {
+	var sessions []*domain.Session
+	for _, session := range m.sessions {
+		if session.UserID == userID && session.IsActive(now) {
+			copied := *session
+			sessions = append(sessions, &copied)
+		}
+	}
+	return sessions, nil
+}
+
+func (m *mockSessionRepository) Touch(session *domain.Session) error {
+	stored, exists := m.sessions[session.ID]
+	if exists {
+		stored.LastSeenAt = session.LastSeenAt
+		stored.ClientIP = session.ClientIP
+		stored.ExpiresAt = session.ExpiresAt
+	}
+	return nil
+}
+
+const firefoxUserAgent = "Mozilla/5.0 (X11; Linux x86_64; rv:127.0) Gecko/20100101 Firefox/127.0"
+
+// authenticate resolves the access token, failing the test on errors
+func authenticate(t *testing.T, service domain.AuthService, accessToken string) *domain.Principal {
+	t.Helper()
+	principal, err := service.Authenticate(accessToken, testClientIP)
+	if err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	return principal
+}
+
+// newTestSessionService creates a session service over the sessions of the
+// auth service, with the test user logged in on a laptop and a phone
+func newTestSessionService(t *testing.T) (domain.SessionService, *authService, *mockAuditRepository, *domain.AccessToken, *domain.AccessToken) {
+	service, _, audit := newTestAuthService(t, config.AuthConfig{})
+	sessions := NewSessionService(service.sessions, service.refreshTokens, service.audit)
+	return sessions, service, audit, login(t, service), login(t, service)
+}
+
+func TestLoginTracksSession(t *testing.T) {
+	tests := []struct {
+		name string
+		// lastSeen is how long ago the session was last seen
+		lastSeen     time.Duration
+		clientIP     string
+		wantTouched  bool
+		wantClientIP string
+	}{
+		{name: "seen just now", clientIP: testClientIP, wantClientIP: testClientIP},
+		{name: "seen an hour ago", lastSeen: time.Hour, clientIP: testClientIP, wantTouched: true, wantClientIP: testClientIP},
+		// Use from a new IP is recorded right away
+		{name: "new IP", clientIP: "198.51.100.9", wantTouched: true, wantClientIP: "198.51.100.9"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, _, _ := newTestAuthService(t, config.AuthConfig{})
+			request := testRequest
+			request.UserAgent = firefoxUserAgent
+			result, err := service.Login("test@example.com", "Password123!", request)
+			if err != nil {
+				t.Fatalf("Login() error = %v", err)
+			}
+
+			actor := authenticate(t, service, result.Token.AccessToken)
+			session := service.sessions.(*mockSessionRepository).sessions[actor.SessionID]
+			if session == nil || session.UserID != 1 || session.ClientIP != testClientIP {
+				t.Fatalf("session = %+v, want a session of user 1 from the login IP", session)
+			}
+			if session.Browser != "Firefox" || session.OS != "Linux" || session.DeviceType != useragent.TypeDesktop {
+				t.Errorf("session device = %s/%s/%s, want Firefox/Linux/desktop", session.Browser, session.OS, session.DeviceType)
+			}
+
+			seenAt := time.Now().Add(-tt.lastSeen - time.Second)
+			session.LastSeenAt = seenAt
+			if _, err := service.Authenticate(result.Token.AccessToken, tt.clientIP); err != nil {
+				t.Fatalf("Authenticate() error = %v", err)
+			}
+			if touched := session.LastSeenAt.After(seenAt); touched != tt.wantTouched || session.ClientIP != tt.wantClientIP {
+				t.Errorf("session = %+v, want touched = %v from %s", session, tt.wantTouched, tt.wantClientIP)
+			}
+		})
+	}
+}
+
+func TestListSessions(t *testing.T) {
+	tests := []struct {
+		name       string
+		actor      func(laptop *domain.Principal) *domain.Principal
+		wantErr    errors.ErrorType
+		wantMarked bool
+	}{
+		{
+			name:       "own sessions",
+			actor:      func(laptop *domain.Principal) *domain.Principal { return laptop },
+			wantMarked: true,
+		},
+		{
+			name: "administrator",
+			actor: func(laptop *domain.Principal) *domain.Principal {
+				return domain.NewPrincipal(&domain.User{ID: 2}, []*domain.Role{builtinRole(domain.RoleAdmin)})
+			},
+		},
+		{
+			name: "another user",
+			actor: func(laptop *domain.Principal) *domain.Principal {
+				return domain.NewPrincipal(&domain.User{ID: 2}, nil)
+			},
+			wantErr: errors.Forbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sessions, service, _, laptop, _ := newTestSessionService(t)
+			actor := tt.actor(authenticate(t, service, laptop.AccessToken))
+
+			listed, err := sessions.List(actor, 1)
+			if tt.wantErr != "" {
+				assertErrorType(t, "List()", err, tt.wantErr)
+				return
+			}
+			if err != nil || len(listed) != 2 {
+				t.Fatalf("List() = %v, %v, want 2 sessions", listed, err)
+			}
+			current := 0
+			for _, session := range listed {
+				if session.Current {
+					current++
+					if session.ID != actor.SessionID {
+						t.Errorf("session %s is marked current, want %s", session.ID, actor.SessionID)
+					}
+				}
+			}
+			if (current == 1) != tt.wantMarked {
+				t.Errorf("List() marked %d sessions current, want marked = %v", current, tt.wantMarked)
+			}
+		})
+	}
+}
+
+func TestRevokeSession(t *testing.T) {
+	tests := []struct {
+		name    string
+		actor   func(laptop *domain.Principal) *domain.Principal
+		revoked bool
+		wantErr errors.ErrorType
+	}{
+		{
+			name:  "own other session",
+			actor: func(laptop *domain.Principal) *domain.Principal { return laptop },
+		},
+		{
+			name:    "already revoked session",
+			actor:   func(laptop *domain.Principal) *domain.Principal { return laptop },
+			revoked: true,
+			wantErr: errors.NotFound,
+		},
+		{
+			name: "session of another user",
+			actor: func(laptop *domain.Principal) *domain.Principal {
+				return domain.NewPrincipal(&domain.User{ID: 2}, nil)
+			},
+			wantErr: errors.Forbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sessions, service, audit, laptop, phone := newTestSessionService(t)
+			actor := tt.actor(authenticate(t, service, laptop.AccessToken))
+			phoneSession := authenticate(t, service, phone.AccessToken).SessionID
+			if tt.revoked {
+				if err := sessions.Revoke(actor, 1, phoneSession); err != nil {
+					t.Fatalf("Revoke() error = %v", err)
+				}
+			}
+			events := len(audit.events)
+
+			err := sessions.Revoke(actor, 1, phoneSession)
+			if tt.wantErr != "" {
+				assertErrorType(t, "Revoke()", err, tt.wantErr)
+				if len(audit.events) != events {
+					t.Errorf("failed Revoke() recorded %v", audit.actions()[events:])
+				}
+				if !tt.revoked {
+					authenticate(t, service, phone.AccessToken)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Revoke() error = %v", err)
+			}
+
+			_, err = service.Authenticate(phone.AccessToken, testClientIP)
+			assertUnauthorized(t, err)
+			_, err = service.Refresh(phone.RefreshToken, testRequest)
+			assertUnauthorized(t, err)
+			authenticate(t, service, laptop.AccessToken)
+		})
+	}
+}
+
+func TestRevokeAllSessions(t *testing.T) {
+	tests := []struct {
+		name    string
+		actor   *domain.Principal
+		wantErr bool
+	}{
+		// Administrators can force a user to log in again
+		{name: "administrator", actor: domain.NewPrincipal(&domain.User{ID: 2}, []*domain.Role{builtinRole(domain.RoleAdmin)})},
+		{name: "another user", actor: domain.NewPrincipal(&domain.User{ID: 2}, nil), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sessions, service, audit, laptop, phone := newTestSessionService(t)
+
+			err := sessions.RevokeAll(tt.actor, 1)
+			if tt.wantErr {
+				assertErrorType(t, "RevokeAll()", err, errors.Forbidden)
+				authenticate(t, service, laptop.AccessToken)
+				authenticate(t, service, phone.AccessToken)
+				return
+			}
+			if err != nil {
+				t.Fatalf("RevokeAll() error = %v", err)
+			}
+
+			for _, issued := range []*domain.AccessToken{laptop, phone} {
+				_, err = service.Authenticate(issued.AccessToken, testClientIP)
+				assertUnauthorized(t, err)
+			}
+			revoked := audit.events[len(audit.events)-1]
+			if revoked.Action != domain.AuditTokensRevoked || *revoked.ActorID != 2 || revoked.Details["reason"] != "sessions_revoked" {
+				t.Errorf("revocation event = %+v", revoked)
+			}
+		})
+	}
+}
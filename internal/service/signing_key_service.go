@@ -0,0 +1,182 @@
+package service
+
+import (
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/errors"
+	"UserRESTfulApi/pkg/config"
+	"UserRESTfulApi/pkg/secrets"
+	"UserRESTfulApi/pkg/token"
+	"crypto/rsa"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// signingAlgorithm is the JWS algorithm of ID tokens
+const signingAlgorithm = "RS256"
+
+type signingKeyService struct {
+	repo             domain.SigningKeyRepository
+	cipher           *secrets.Cipher
+	rotationInterval time.Duration
+	retention        time.Duration
+	now              func() time.Time
+
+	mu          sync.Mutex
+	privateKeys map[string]*rsa.PrivateKey // decrypted keys by kid
+}
+
+// NewSigningKeyService creates a new ID token signing key service. Private
+// keys are encrypted with the cipher before they are stored.
+func NewSigningKeyService(repo domain.SigningKeyRepository, cipher *secrets.Cipher, cfg config.OIDCConfig) domain.SigningKeyService {
+	return &signingKeyService{
+		repo:             repo,
+		cipher:           cipher,
+		rotationInterval: cfg.KeyRotationInterval,
+		retention:        cfg.KeyRetention,
+		now:              time.Now,
+		privateKeys:      make(map[string]*rsa.PrivateKey),
+	}
+}
+
+// Sign signs the claims with the active key. Keys are read from the database
+// on every call, so a rotation by another replica takes effect immediately.
+func (s *signingKeyService) Sign(claims map[string]interface{}) (string, error) {
+	active, err := s.activeKey()
+	if err != nil {
+		return "", err
+	}
+
+	privateKey, err := s.privateKey(active)
+	if err != nil {
+		return "", err
+	}
+	signed, err := token.SignRS256(claims, privateKey, active.ID)
+	if err != nil {
+		return "", errors.InternalServerError(err)
+	}
+	return signed, nil
+}
+
+// JWKS returns the active key and the keys retired within the retention period
+func (s *signingKeyService) JWKS() (*domain.JSONWebKeySet, error) {
+	if _, err := s.activeKey(); err != nil {
+		return nil, err
+	}
+	keys, err := s.repo.List()
+	if err != nil {
+		return nil, err
+	}
+
+	retainedSince := s.now().Add(-s.retention)
+	set := &domain.JSONWebKeySet{Keys: []domain.JSONWebKey{}}
+	for _, key := range keys {
+		if key.RetiredAt != nil && key.RetiredAt.Before(retainedSince) {
+			continue
+		}
+		publicKey, err := token.DecodeRSAPublicKey(key.PublicKey)
+		if err != nil {
+			return nil, errors.InternalServerError(err)
+		}
+		n, e := token.JWKParams(publicKey)
+		set.Keys = append(set.Keys, domain.JSONWebKey{
+			KeyType:   "RSA",
+			Use:       "sig",
+			Algorithm: key.Algorithm,
+			KeyID:     key.ID,
+			Modulus:   n,
+			Exponent:  e,
+		})
+	}
+	return set, nil
+}
+
+// Rotate replaces the active key immediately, e.g. after a suspected leak.
+// Tokens signed by the previous key stay verifiable during the retention period.
+func (s *signingKeyService) Rotate(actor *domain.Principal) (*domain.SigningKey, error) {
+	if !actor.Can(domain.PermissionOAuthManage) {
+		return nil, errors.ForbiddenError("rotate signing keys")
+	}
+
+	if err := s.rotate(s.now()); err != nil {
+		return nil, err
+	}
+	return s.activeKey()
+}
+
+// activeKey returns the newest unretired key, generating a new one when there
+// is none or it is older than the rotation interval
+func (s *signingKeyService) activeKey() (*domain.SigningKey, error) {
+	keys, err := s.repo.List()
+	if err != nil {
+		return nil, err
+	}
+	staleBefore := s.now().Add(-s.rotationInterval)
+	for _, key := range keys {
+		if key.RetiredAt == nil && key.CreatedAt.After(staleBefore) {
+			return key, nil
+		}
+	}
+
+	if err := s.rotate(staleBefore); err != nil {
+		return nil, err
+	}
+	keys, err = s.repo.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		if key.RetiredAt == nil {
+			return key, nil
+		}
+	}
+	return nil, errors.InternalServerError(fmt.Errorf("no active signing key"))
+}
+
+// rotate stores a new key unless a key created after staleBefore is already
+// active, and drops keys retired longer than the retention period
+func (s *signingKeyService) rotate(staleBefore time.Time) error {
+	privateKey, err := token.NewRSAKey()
+	if err != nil {
+		return errors.InternalServerError(err)
+	}
+	publicKey, err := token.EncodeRSAPublicKey(&privateKey.PublicKey)
+	if err != nil {
+		return errors.InternalServerError(err)
+	}
+	encrypted, err := s.cipher.Encrypt(token.EncodeRSAPrivateKey(privateKey))
+	if err != nil {
+		return errors.InternalServerError(err)
+	}
+
+	key := &domain.SigningKey{
+		ID:         token.Thumbprint(&privateKey.PublicKey),
+		Algorithm:  signingAlgorithm,
+		PrivateKey: encrypted,
+		PublicKey:  publicKey,
+	}
+	if _, err := s.repo.Rotate(key, staleBefore); err != nil {
+		return err
+	}
+	return s.repo.DeleteRetiredBefore(s.now().Add(-s.retention))
+}
+
+// privateKey decrypts the private part of a key, caching it by kid
+func (s *signingKeyService) privateKey(key *domain.SigningKey) (*rsa.PrivateKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if privateKey, exists := s.privateKeys[key.ID]; exists {
+		return privateKey, nil
+	}
+	decrypted, err := s.cipher.Decrypt(key.PrivateKey)
+	if err != nil {
+		return nil, errors.InternalServerError(err)
+	}
+	privateKey, err := token.DecodeRSAPrivateKey(decrypted)
+	if err != nil {
+		return nil, errors.InternalServerError(err)
+	}
+	s.privateKeys[key.ID] = privateKey
+	return privateKey, nil
+}
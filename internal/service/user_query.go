@@ -0,0 +1,234 @@
+package service
+
+import (
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/errors"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxUserSearchLength bounds the q parameter of a user listing
+const maxUserSearchLength = 100
+
+// userListOperators maps the operators of filter[field][operator] parameters
+// to filter operators
+var userListOperators = map[string]string{
+	"eq":          domain.FilterEqual,
+	"ne":          domain.FilterNotEqual,
+	"gt":          domain.FilterGreater,
+	"gte":         domain.FilterGreaterOrEqual,
+	"lt":          domain.FilterLess,
+	"lte":         domain.FilterLessOrEqual,
+	"contains":    domain.FilterContains,
+	"starts_with": domain.FilterStartsWith,
+	"ends_with":   domain.FilterEndsWith,
+}
+
+var (
+	textOperators    = []string{domain.FilterEqual, domain.FilterNotEqual, domain.FilterContains, domain.FilterStartsWith, domain.FilterEndsWith}
+	orderedOperators = []string{domain.FilterEqual, domain.FilterNotEqual, domain.FilterGreater, domain.FilterGreaterOrEqual, domain.FilterLess, domain.FilterLessOrEqual}
+)
+
+// filterableUserFields lists the operators allowed on each filterable field
+var filterableUserFields = map[string][]string{
+	domain.UserFieldID:        orderedOperators,
+	domain.UserFieldEmail:     textOperators,
+	domain.UserFieldName:      textOperators,
+	domain.UserFieldActive:    {domain.FilterEqual, domain.FilterNotEqual},
+	domain.UserFieldCreatedAt: orderedOperators,
+	domain.UserFieldUpdatedAt: orderedOperators,
+}
+
+// sortableUserFields lists the fields users can be sorted by
+var sortableUserFields = map[string]bool{
+	domain.UserFieldID:        true,
+	domain.UserFieldEmail:     true,
+	domain.UserFieldName:      true,
+	domain.UserFieldCreatedAt: true,
+	domain.UserFieldUpdatedAt: true,
+}
+
+// parseUserQuery checks the parameters of a user listing against the
+// filterable and sortable fields and converts them to a query. Filters are
+// combined with and.
+func parseUserQuery(params domain.UserListParams) (domain.UserQuery, error) {
+	var query domain.UserQuery
+
+	var filters []*domain.UserFilter
+	for _, param := range params.Filters {
+		filter, err := parseUserListFilter(param)
+		if err != nil {
+			return domain.UserQuery{}, err
+		}
+		filters = append(filters, filter)
+	}
+	switch len(filters) {
+	case 0:
+	case 1:
+		query.Filter = filters[0]
+	default:
+		query.Filter = &domain.UserFilter{Operator: domain.FilterAnd, Operands: filters}
+	}
+
+	if params.Sort != "" {
+		seen := make(map[string]bool)
+		fields := strings.Split(params.Sort, ",")
+		for i, field := range fields {
+			sort := domain.UserSort{Field: strings.TrimSpace(field)}
+			if strings.HasPrefix(sort.Field, "-") {
+				sort.Field, sort.Descending = sort.Field[1:], true
+			}
+			if !sortableUserFields[sort.Field] {
+				return domain.UserQuery{}, errors.InvalidInputError("sort", fmt.Sprintf("cannot sort by %q", sort.Field))
+			}
+			if seen[sort.Field] {
+				return domain.UserQuery{}, errors.InvalidInputError("sort", fmt.Sprintf("%s is listed more than once", sort.Field))
+			}
+			// IDs are unique, so fields after id would never take effect
+			if sort.Field == domain.UserFieldID && i < len(fields)-1 {
+				return domain.UserQuery{}, errors.InvalidInputError("sort", "id must be the last field")
+			}
+			seen[sort.Field] = true
+			query.Sort = append(query.Sort, sort)
+		}
+	}
+
+	query.Search = strings.TrimSpace(params.Search)
+	if len(query.Search) > maxUserSearchLength {
+		return domain.UserQuery{}, errors.InvalidInputError("q", fmt.Sprintf("must be at most %d characters", maxUserSearchLength))
+	}
+
+	return query, nil
+}
+
+// parseUserListFilter converts a filter parameter, checking its operator and value
+func parseUserListFilter(param domain.UserListFilter) (*domain.UserFilter, error) {
+	name := "filter[" + param.Field + "]"
+	allowed, ok := filterableUserFields[param.Field]
+	if !ok {
+		return nil, errors.InvalidInputError(name, fmt.Sprintf("cannot filter by %q", param.Field))
+	}
+
+	operator := domain.FilterEqual
+	if param.Operator != "" {
+		name += "[" + param.Operator + "]"
+		if operator, ok = userListOperators[param.Operator]; !ok || !containsOperator(allowed, operator) {
+			return nil, errors.InvalidInputError(name, fmt.Sprintf("operator %q is not supported on %s", param.Operator, param.Field))
+		}
+	}
+
+	filter := &domain.UserFilter{Operator: operator, Field: param.Field}
+	switch param.Field {
+	case domain.UserFieldID:
+		id, err := strconv.ParseUint(param.Value, 10, 32)
+		if err != nil {
+			return nil, errors.InvalidInputError(name, "must be a user ID")
+		}
+		filter.Value = uint(id)
+	case domain.UserFieldActive:
+		active, err := strconv.ParseBool(param.Value)
+		if err != nil {
+			return nil, errors.InvalidInputError(name, "must be true or false")
+		}
+		filter.Value = active
+	case domain.UserFieldCreatedAt, domain.UserFieldUpdatedAt:
+		at, err := time.Parse(time.RFC3339, param.Value)
+		if err != nil {
+			return nil, errors.InvalidInputError(name, "must be an RFC 3339 time")
+		}
+		filter.Value = at
+	default:
+		filter.Value = param.Value
+	}
+	return filter, nil
+}
+
+func containsOperator(operators []string, operator string) bool {
+	for _, allowed := range operators {
+		if allowed == operator {
+			return true
+		}
+	}
+	return false
+}
+
+// userCursor is the payload of a user listing cursor. Query fingerprints the
+// filter, sort and search parameters, so a cursor only continues the listing
+// it was issued for.
+type userCursor struct {
+	Query string         `json:"q"`
+	Key   domain.UserKey `json:"k"`
+}
+
+// userQueryFingerprint identifies the filter, sort and search parameters of a listing
+func userQueryFingerprint(params domain.UserListParams) string {
+	data, _ := json.Marshal(params)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:16])
+}
+
+// encodeCursor returns the signed cursor of the position in the listing
+func (s *userService) encodeCursor(params domain.UserListParams, key domain.UserKey) (string, error) {
+	encoded, err := s.cursors.Encode(userCursor{Query: userQueryFingerprint(params), Key: key})
+	if err != nil {
+		log.Printf("Failed to encode user listing cursor: %v", err)
+		return "", errors.InternalServerError(err)
+	}
+	return encoded, nil
+}
+
+// decodeCursor verifies a cursor issued for the listing and returns its position
+func (s *userService) decodeCursor(param, encoded string, params domain.UserListParams) (domain.UserKey, error) {
+	var payload userCursor
+	if err := s.cursors.Decode(encoded, &payload); err != nil || payload.Key == nil {
+		return nil, errors.InvalidInputError(param, "invalid cursor")
+	}
+	if payload.Query != userQueryFingerprint(params) {
+		return nil, errors.InvalidInputError(param, "cursor belongs to a listing with other filter, sort or q parameters")
+	}
+	return payload.Key, nil
+}
+
+// userSeek checks the page parameters of a listing, applying the configured
+// page sizes, and decodes its cursor
+func (s *userService) userSeek(params domain.UserListParams, page domain.UserPageParams) (domain.UserSeek, error) {
+	seek := domain.UserSeek{Limit: page.Limit, Total: page.Total}
+	switch {
+	case seek.Limit < 0:
+		return domain.UserSeek{}, errors.InvalidInputError("limit", "must be positive")
+	case seek.Limit == 0:
+		seek.Limit = s.cfg.DefaultPageSize
+	}
+	if s.cfg.MaxPageSize > 0 && seek.Limit > s.cfg.MaxPageSize {
+		seek.Limit = s.cfg.MaxPageSize
+	}
+	if seek.Limit < 1 {
+		seek.Limit = 1
+	}
+
+	switch page.Total {
+	case "", domain.UserTotalExact, domain.UserTotalEstimate:
+	default:
+		return domain.UserSeek{}, errors.InvalidInputError("total", fmt.Sprintf("must be %s or %s", domain.UserTotalExact, domain.UserTotalEstimate))
+	}
+
+	var err error
+	switch {
+	case page.After != "" && page.Before != "":
+		return domain.UserSeek{}, errors.InvalidInputError("before", "cannot be combined with after")
+	case page.After != "":
+		seek.After, err = s.decodeCursor("after", page.After, params)
+	case page.Before != "":
+		seek.Before, err = s.decodeCursor("before", page.Before, params)
+	}
+	if err != nil {
+		return domain.UserSeek{}, err
+	}
+	return seek, nil
+}
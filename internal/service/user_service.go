@@ -3,48 +3,84 @@ package service
 import (
 	"UserRESTfulApi/internal/domain"
 	"UserRESTfulApi/internal/errors"
+	"UserRESTfulApi/pkg/config"
+	"UserRESTfulApi/pkg/cursor"
+	"UserRESTfulApi/pkg/jsonpatch"
+	"UserRESTfulApi/pkg/password"
+	"bytes"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"log"
 	"net/mail"
 	"strings"
-	"unicode"
+	"sync"
 )
 
 type userService struct {
-	repo domain.UserRepository
+	repo     domain.UserRepository
+	hasher   password.Hasher
+	policy   *password.Policy
+	verifier domain.EmailVerificationService
+	audit    domain.AuditService
+	cursors  *cursor.Codec
+	cfg      config.APIConfig
+
+	dummyHashOnce sync.Once
+	dummyHash     string
 }
 
-// NewUserService creates a new user service
-func NewUserService(repo domain.UserRepository) domain.UserService {
-	return &userService{repo: repo}
+// NewUserService creates a new user service. Listing cursors are signed with cfg.CursorSecret.
+func NewUserService(repo domain.UserRepository, hasher password.Hasher, policy *password.Policy, verifier domain.EmailVerificationService, audit domain.AuditService, cfg config.APIConfig) domain.UserService {
+	return &userService{repo: repo, hasher: hasher, policy: policy, verifier: verifier, audit: audit, cursors: cursor.NewCodec(cfg.CursorSecret), cfg: cfg}
 }
 
-// Create creates a new user
-func (s *userService) Create(user *domain.User) error {
+// Create creates a new user signing up in the request
+func (s *userService) Create(user *domain.User, request domain.RequestMeta) error {
 	if err := s.validateEmail(user.Email); err != nil {
 		return err
 	}
 
-	if err := s.validatePassword(user.Password); err != nil {
+	if err := s.validateName(user.Name); err != nil {
 		return err
 	}
 
-	if err := s.validateName(user.Name); err != nil {
+	if err := s.validatePassword(user.Password, user.Email, user.Name); err != nil {
 		return err
 	}
 
-	existingUser, err := s.repo.GetByEmail(user.Email)
+	if err := s.checkEmailAvailable(user.Email, 0); err != nil {
+		return err
+	}
+
+	hashed, err := s.hasher.Hash(user.Password)
 	if err != nil {
 		return errors.InternalServerError(err)
 	}
-	if existingUser != nil {
-		return errors.DuplicateEmailError(user.Email)
+	user.Password = hashed
+	user.EmailVerifiedAt = nil
+	user.PendingEmail = ""
+
+	if err := s.repo.Create(user); err != nil {
+		return err
 	}
+	event := domain.NewAuditEvent(domain.AuditUserCreated, nil, request).ForUser(user.ID)
+	event.Changes = userAuditChanges(nil, user)
+	s.audit.Record(event)
 
-	// TODO: Hash password before saving
-	return s.repo.Create(user)
+	// The account is usable right away; a failure here only delays verification
+	if err := s.verifier.Send(user, user.Email); err != nil {
+		log.Printf("Failed to send verification email to user %d: %v", user.ID, err)
+	}
+	return nil
 }
 
 // Get retrieves a user by ID
-func (s *userService) Get(id uint) (*domain.User, error) {
+func (s *userService) Get(actor *domain.Principal, id uint) (*domain.User, error) {
+	if !actor.CanAccessUser(domain.PermissionUsersRead, id) {
+		return nil, errors.ForbiddenError("read this user")
+	}
+
 	user, err := s.repo.Get(id)
 	if err != nil {
 		return nil, err
@@ -56,21 +92,28 @@ func (s *userService) Get(id uint) (*domain.User, error) {
 }
 
 // Update updates a user
-func (s *userService) Update(user *domain.User) error {
+func (s *userService) Update(actor *domain.Principal, user *domain.User) error {
+	if !actor.CanAccessUser(domain.PermissionUsersUpdate, user.ID) {
+		return errors.ForbiddenError("update this user")
+	}
+
 	if err := s.validateEmail(user.Email); err != nil {
 		return err
 	}
 
+	if err := s.validateName(user.Name); err != nil {
+		return err
+	}
+
 	if user.Password != "" {
-		if err := s.validatePassword(user.Password); err != nil {
+		if actor.Impersonated() {
+			return errors.ForbiddenError("change a password while impersonating")
+		}
+		if err := s.validatePassword(user.Password, user.Email, user.Name); err != nil {
 			return err
 		}
 	}
 
-	if err := s.validateName(user.Name); err != nil {
-		return err
-	}
-
 	existingUser, err := s.repo.Get(user.ID)
 	if err != nil {
 		return err
@@ -78,24 +121,208 @@ func (s *userService) Update(user *domain.User) error {
 	if existingUser == nil {
 		return errors.NotFoundError("user", user.ID)
 	}
+	if user.Version == 0 {
+		user.Version = existingUser.Version
+	} else if user.Version != existingUser.Version {
+		return errors.PreconditionFailedError("user", user.ID)
+	}
 
-	// Check if email is being changed and if it's already taken
-	if existingUser.Email != user.Email {
-		emailUser, err := s.repo.GetByEmail(user.Email)
+	// Check if email is being changed and if it's already taken. A new address
+	// is kept pending and only replaces the current one once it is confirmed.
+	emailChanged := existingUser.Email != user.Email
+	if emailChanged {
+		if err := s.checkEmailAvailable(user.Email, user.ID); err != nil {
+			return err
+		}
+		user.PendingEmail = user.Email
+		user.Email = existingUser.Email
+	} else {
+		user.PendingEmail = existingUser.PendingEmail
+	}
+	user.EmailVerifiedAt = existingUser.EmailVerifiedAt
+	user.DeactivatedAt = existingUser.DeactivatedAt
+
+	// Keep the stored hash unless a new password was supplied
+	if user.Password != "" {
+		hashed, err := s.hasher.Hash(user.Password)
 		if err != nil {
 			return errors.InternalServerError(err)
 		}
-		if emailUser != nil {
-			return errors.DuplicateEmailError(user.Email)
+		user.Password = hashed
+	} else {
+		user.Password = existingUser.Password
+	}
+	user.CreatedAt = existingUser.CreatedAt
+
+	if err := s.repo.Update(user); err != nil {
+		return err
+	}
+	event := domain.NewAuditEvent(domain.AuditUserUpdated, actor, actor.Request).ForUser(user.ID)
+	event.Changes = userAuditChanges(existingUser, user)
+	s.audit.Record(event)
+
+	if emailChanged {
+		return s.requestEmailChange(existingUser, user)
+	}
+	return nil
+}
+
+// userPatchDocument is the document a PATCH request edits
+type userPatchDocument struct {
+	ID       uint   `json:"id"`
+	Email    string `json:"email"`
+	Name     string `json:"name"`
+	Password string `json:"password,omitempty"`
+}
+
+// Patch applies a JSON Merge Patch or JSON Patch to the id, email and name of
+// the stored user. Only the fields the patch changes are validated and
+// stored, so a rename does not depend on the email passing today's rules. An
+// email change is kept pending like with Update.
+func (s *userService) Patch(actor *domain.Principal, id uint, patch *domain.UserPatch) (*domain.User, error) {
+	if !actor.CanAccessUser(domain.PermissionUsersUpdate, id) {
+		return nil, errors.ForbiddenError("update this user")
+	}
+
+	existingUser, err := s.repo.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if existingUser == nil {
+		return nil, errors.NotFoundError("user", id)
+	}
+	if patch.Version != 0 && patch.Version != existingUser.Version {
+		return nil, errors.PreconditionFailedError("user", id)
+	}
+
+	document, err := json.Marshal(userPatchDocument{ID: existingUser.ID, Email: existingUser.Email, Name: existingUser.Name})
+	if err != nil {
+		return nil, errors.InternalServerError(err)
+	}
+	var patched []byte
+	switch patch.ContentType {
+	case domain.MergePatchContentType:
+		patched, err = jsonpatch.MergePatch(document, patch.Body)
+	case domain.JSONPatchContentType:
+		patched, err = jsonpatch.Apply(document, patch.Body)
+	default:
+		return nil, errors.InvalidInputError("Content-Type", fmt.Sprintf("must be %s or %s", domain.MergePatchContentType, domain.JSONPatchContentType))
+	}
+	if err != nil {
+		if stderrors.Is(err, jsonpatch.ErrTestFailed) {
+			return nil, errors.ConflictError(err.Error())
 		}
+		return nil, errors.InvalidInputError("patch", err.Error())
 	}
 
-	// TODO: Hash password before saving if it's being updated
-	return s.repo.Update(user)
+	var result userPatchDocument
+	decoder := json.NewDecoder(bytes.NewReader(patched))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&result); err != nil {
+		return nil, errors.InvalidInputError("patch", "the patched user is invalid: "+err.Error())
+	}
+	if result.ID != existingUser.ID {
+		return nil, errors.InvalidInputError("id", "cannot be changed")
+	}
+
+	user := *existingUser
+	var columns []string
+	if result.Name != existingUser.Name {
+		if err := s.validateName(result.Name); err != nil {
+			return nil, err
+		}
+		user.Name = result.Name
+		columns = append(columns, "name")
+	}
+	if result.Email != existingUser.Email {
+		if err := s.validateEmail(result.Email); err != nil {
+			return nil, err
+		}
+		if err := s.checkEmailAvailable(result.Email, id); err != nil {
+			return nil, err
+		}
+	}
+	if result.Password != "" {
+		if actor.Impersonated() {
+			return nil, errors.ForbiddenError("change a password while impersonating")
+		}
+		if err := s.validatePassword(result.Password, result.Email, result.Name); err != nil {
+			return nil, err
+		}
+		hashed, err := s.hasher.Hash(result.Password)
+		if err != nil {
+			return nil, errors.InternalServerError(err)
+		}
+		user.Password = hashed
+		columns = append(columns, "password")
+	}
+	if result.Email != existingUser.Email {
+		user.PendingEmail = result.Email
+		columns = append(columns, "pending_email")
+	}
+	if len(columns) == 0 {
+		return existingUser, nil
+	}
+
+	if err := s.repo.UpdateColumns(&user, columns...); err != nil {
+		return nil, err
+	}
+	event := domain.NewAuditEvent(domain.AuditUserUpdated, actor, actor.Request).ForUser(user.ID)
+	event.Changes = userAuditChanges(existingUser, &user)
+	s.audit.Record(event)
+
+	if result.Email != existingUser.Email {
+		if err := s.requestEmailChange(existingUser, &user); err != nil {
+			return nil, err
+		}
+	}
+	return &user, nil
+}
+
+// checkEmailAvailable returns a DuplicateEmail error when a user other than
+// userID has the address or an email change of theirs reserves it
+func (s *userService) checkEmailAvailable(email string, userID uint) error {
+	emailUser, err := s.repo.GetByEmail(email)
+	if err != nil {
+		return errors.InternalServerError(err)
+	}
+	if emailUser != nil && emailUser.ID != userID {
+		return errors.DuplicateEmailError(email)
+	}
+	reserved, err := s.verifier.EmailReserved(email, userID)
+	if err != nil {
+		return err
+	}
+	if reserved {
+		return errors.DuplicateEmailError(email)
+	}
+	return nil
+}
+
+// requestEmailChange reserves the pending address of the updated user and
+// emails the confirmation and revert links. It runs after the update is
+// stored, so a rejected update neither reserves the address nor sends email.
+// If the address was taken since it was checked, the pending address the
+// update stored is put back.
+func (s *userService) requestEmailChange(existingUser, user *domain.User) error {
+	err := s.verifier.RequestChange(existingUser, user.PendingEmail)
+	if err == nil {
+		return nil
+	}
+
+	user.PendingEmail = existingUser.PendingEmail
+	if restoreErr := s.repo.UpdateColumns(user, "pending_email"); restoreErr != nil {
+		log.Printf("Failed to restore the pending email of user %d: %v", user.ID, restoreErr)
+	}
+	return err
 }
 
 // Delete deletes a user
-func (s *userService) Delete(id uint) error {
+func (s *userService) Delete(actor *domain.Principal, id, version uint) error {
+	if !actor.CanAccessUser(domain.PermissionUsersDelete, id) {
+		return errors.ForbiddenError("delete this user")
+	}
+
 	user, err := s.repo.Get(id)
 	if err != nil {
 		return err
@@ -103,12 +330,51 @@ func (s *userService) Delete(id uint) error {
 	if user == nil {
 		return errors.NotFoundError("user", id)
 	}
-	return s.repo.Delete(id)
+	if version == 0 {
+		version = user.Version
+	}
+	if err := s.repo.Delete(id, version); err != nil {
+		return err
+	}
+
+	event := domain.NewAuditEvent(domain.AuditUserDeleted, actor, actor.Request).ForUser(id)
+	event.Changes = userAuditChanges(user, nil)
+	s.audit.Record(event)
+	return nil
 }
 
-// List lists users with pagination
-func (s *userService) List(page, limit int) ([]*domain.User, error) {
-	return s.repo.List(page, limit)
+// List lists a page of the users matching the filter and search parameters
+func (s *userService) List(actor *domain.Principal, params domain.UserListParams, page domain.UserPageParams) (*domain.UserPage, error) {
+	if !actor.Can(domain.PermissionUsersList) {
+		return nil, errors.ForbiddenError("list users")
+	}
+
+	query, err := parseUserQuery(params)
+	if err != nil {
+		return nil, err
+	}
+	seek, err := s.userSeek(params, page)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.repo.List(query, seek)
+	if err != nil {
+		return nil, err
+	}
+
+	listed := &domain.UserPage{Users: result.Users, Total: result.Total, TotalEstimated: seek.Total == domain.UserTotalEstimate}
+	if result.HasNext && result.Last != nil {
+		if listed.NextCursor, err = s.encodeCursor(params, result.Last); err != nil {
+			return nil, err
+		}
+	}
+	if result.HasPrev && result.First != nil {
+		if listed.PrevCursor, err = s.encodeCursor(params, result.First); err != nil {
+			return nil, err
+		}
+	}
+	return listed, nil
 }
 
 // GetByEmail retrieves a user by email
@@ -126,13 +392,45 @@ func (s *userService) VerifyPassword(email, plainPassword string) (*domain.User,
 		return nil, err
 	}
 	if user == nil {
-		return nil, errors.NotFoundError("user", email)
+		// Pay the same hashing cost as for a known email, so response times
+		// do not reveal which emails have accounts
+		s.hasher.Verify(plainPassword, s.unknownUserHash())
+		return nil, errors.InvalidCredentialsError()
+	}
+	if !s.hasher.Verify(plainPassword, user.Password) {
+		return nil, errors.InvalidCredentialsError()
+	}
+
+	// Transparently upgrade hashes produced with an outdated algorithm or parameters.
+	// A failure here must not fail the login, the upgrade is retried next time.
+	if s.hasher.NeedsRehash(user.Password) {
+		hashed, err := s.hasher.Hash(plainPassword)
+		if err != nil {
+			log.Printf("Failed to rehash password for user %d: %v", user.ID, err)
+		} else if err := s.repo.UpdatePassword(user.ID, hashed); err != nil {
+			log.Printf("Failed to store rehashed password for user %d: %v", user.ID, err)
+		} else {
+			user.Password = hashed
+		}
 	}
 
-	// TODO: Verify password
 	return user, nil
 }
 
+// unknownUserHash returns a hash made with the current algorithm and
+// parameters, which passwords for unknown emails are checked against
+func (s *userService) unknownUserHash() string {
+	s.dummyHashOnce.Do(func() {
+		hashed, err := s.hasher.Hash("unknown user password")
+		if err != nil {
+			log.Printf("Failed to hash the unknown user password: %v", err)
+			return
+		}
+		s.dummyHash = hashed
+	})
+	return s.dummyHash
+}
+
 // validateEmail validates email format
 func (s *userService) validateEmail(email string) error {
 	if strings.TrimSpace(email) == "" {
@@ -145,55 +443,33 @@ func (s *userService) validateEmail(email string) error {
 	return nil
 }
 
-// validatePassword validates password strength
-func (s *userService) validatePassword(password string) error {
-	if strings.TrimSpace(password) == "" {
-		return errors.InvalidPasswordError("password cannot be empty")
-	}
-
-	if len(password) < 8 {
-		return errors.InvalidPasswordError("password must be at least 8 characters long")
-	}
-
-	var (
-		hasUpper   bool
-		hasLower   bool
-		hasNumber  bool
-		hasSpecial bool
-	)
+// validatePassword validates the password against the configured policy
+func (s *userService) validatePassword(plainPassword string, userInfo ...string) error {
+	return validatePassword(s.policy, plainPassword, userInfo...)
+}
 
-	for _, char := range password {
-		switch {
-		case unicode.IsUpper(char):
-			hasUpper = true
-		case unicode.IsLower(char):
-			hasLower = true
-		case unicode.IsNumber(char):
-			hasNumber = true
-		case unicode.IsPunct(char) || unicode.IsSymbol(char):
-			hasSpecial = true
-		}
+// validatePassword validates the password against the policy, reporting every
+// violation as a detail of an InvalidPassword error
+func validatePassword(policy *password.Policy, plainPassword string, userInfo ...string) error {
+	err := policy.Validate(plainPassword, userInfo...)
+	if err == nil {
+		return nil
 	}
 
-	var missing []string
-	if !hasUpper {
-		missing = append(missing, "uppercase letter")
-	}
-	if !hasLower {
-		missing = append(missing, "lowercase letter")
-	}
-	if !hasNumber {
-		missing = append(missing, "number")
-	}
-	if !hasSpecial {
-		missing = append(missing, "special character")
+	policyErr, ok := err.(*password.PolicyError)
+	if !ok {
+		return errors.InvalidPasswordError(err.Error())
 	}
 
-	if len(missing) > 0 {
-		return errors.InvalidPasswordError("password must contain at least one " + strings.Join(missing, ", "))
+	details := make([]errors.ErrorDetail, 0, len(policyErr.Violations))
+	for _, violation := range policyErr.Violations {
+		details = append(details, errors.ErrorDetail{
+			Field:   "password",
+			Code:    violation.Code,
+			Message: violation.Message,
+		})
 	}
-
-	return nil
+	return errors.PasswordPolicyError(details)
 }
 
 // validateName validates user name
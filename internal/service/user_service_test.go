@@ -3,9 +3,24 @@ package service
 import (
 	"UserRESTfulApi/internal/domain"
 	"UserRESTfulApi/internal/errors"
+	"UserRESTfulApi/pkg/config"
+	"UserRESTfulApi/pkg/password"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
 )
 
+// testHasher keeps hashing cheap in unit tests
+var testHasher = password.NewBcryptHasher(bcrypt.MinCost)
+
+var testAPIConfig = config.APIConfig{DefaultPageSize: 2, MaxPageSize: 3, CursorSecret: "test-cursor-secret"}
+
 // Mock repository for testing
 type mockUserRepository struct {
 	users map[uint]*domain.User
@@ -16,6 +31,10 @@ type mockUserRepository struct {
 	updateCalled     bool
 	deleteCalled     bool
 	listCalled       bool
+	listQuery        domain.UserQuery
+	listSeek         domain.UserSeek
+	updatePasswordCalled bool
+	updatedColumns   []string
 }
 
 func newMockUserRepository() *mockUserRepository {
@@ -53,34 +72,173 @@ func (m *mockUserRepository) Create(user *domain.User) error {
 
 func (m *mockUserRepository) Update(user *domain.User) error {
 	m.updateCalled = true
-	if _, exists := m.users[user.ID]; !exists {
+	stored, exists := m.users[user.ID]
+	if !exists {
 		return errors.NotFoundError("user", user.ID)
 	}
+	if stored.Version != user.Version {
+		return errors.PreconditionFailedError("user", user.ID)
+	}
+	user.Version++
 	m.users[user.ID] = user
 	return nil
 }
 
-func (m *mockUserRepository) Delete(id uint) error {
+func (m *mockUserRepository) UpdateColumns(user *domain.User, columns ...string) error {
+	stored, exists := m.users[user.ID]
+	if !exists {
+		return errors.NotFoundError("user", user.ID)
+	}
+	if stored.Version != user.Version {
+		return errors.PreconditionFailedError("user", user.ID)
+	}
+	user.Version++
+	// Store a copy, as users read before the update keep their old values
+	updated := *stored
+	updated.Version = user.Version
+	m.updatedColumns = columns
+	for _, column := range columns {
+		switch column {
+		case "name":
+			updated.Name = user.Name
+		case "email":
+			updated.Email = user.Email
+		case "password":
+			updated.Password = user.Password
+		case "pending_email":
+			updated.PendingEmail = user.PendingEmail
+		}
+	}
+	m.users[user.ID] = &updated
+	return nil
+}
+
+func (m *mockUserRepository) Delete(id, version uint) error {
 	m.deleteCalled = true
-	if _, exists := m.users[id]; !exists {
+	stored, exists := m.users[id]
+	if !exists {
 		return errors.NotFoundError("user", id)
 	}
+	if stored.Version != version {
+		return errors.PreconditionFailedError("user", id)
+	}
 	delete(m.users, id)
 	return nil
 }
 
-func (m *mockUserRepository) List(page, limit int) ([]*domain.User, error) {
+// List orders users by ID, using the ID as the key
+func (m *mockUserRepository) List(query domain.UserQuery, seek domain.UserSeek) (*domain.UserKeyPage, error) {
 	m.listCalled = true
-	users := make([]*domain.User, 0, len(m.users))
+	m.listQuery = query
+	m.listSeek = seek
+	ids := make([]int, 0, len(m.users))
+	for id := range m.users {
+		ids = append(ids, int(id))
+	}
+	sort.Ints(ids)
+
+	keyID := func(key domain.UserKey) int {
+		id, _ := strconv.Atoi(key[0])
+		return id
+	}
+	var selected []int
+	for _, id := range ids {
+		if (seek.After != nil && id <= keyID(seek.After)) || (seek.Before != nil && id >= keyID(seek.Before)) {
+			continue
+		}
+		selected = append(selected, id)
+	}
+	page := &domain.UserKeyPage{HasPrev: seek.After != nil, HasNext: seek.Before != nil}
+	if len(selected) > seek.Limit {
+		if seek.Before != nil {
+			selected, page.HasPrev = selected[len(selected)-seek.Limit:], true
+		} else {
+			selected, page.HasNext = selected[:seek.Limit], true
+		}
+	}
+	for _, id := range selected {
+		page.Users = append(page.Users, m.users[uint(id)])
+	}
+	if len(selected) > 0 {
+		page.First = domain.UserKey{strconv.Itoa(selected[0])}
+		page.Last = domain.UserKey{strconv.Itoa(selected[len(selected)-1])}
+	}
+	total := int64(len(selected))
+	page.Total = &total
+	return page, nil
+}
+
+func (m *mockUserRepository) UpdatePassword(id uint, hashedPassword string) error {
+	m.updatePasswordCalled = true
+	user, exists := m.users[id]
+	if !exists {
+		return errors.NotFoundError("user", id)
+	}
+	user.Password = hashedPassword
+	user.Version++
+	return nil
+}
+
+func (m *mockUserRepository) Search(filter *domain.UserFilter, offset, limit int) ([]*domain.User, int64, error) {
+	var matched []*domain.User
 	for _, user := range m.users {
-		users = append(users, user)
+		if filter == nil || matchUserFilter(user, filter) {
+			matched = append(matched, user)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].ID < matched[j].ID })
+	total := int64(len(matched))
+	if offset > len(matched) {
+		offset = len(matched)
+	}
+	matched = matched[offset:]
+	if limit < len(matched) {
+		matched = matched[:limit]
+	}
+	return matched, total, nil
+}
+
+// matchUserFilter evaluates the filters used in tests: logical operators and
+// eq, co and pr on strings, eq on IDs and the active flag
+func matchUserFilter(user *domain.User, filter *domain.UserFilter) bool {
+	switch filter.Operator {
+	case domain.FilterAnd:
+		return matchUserFilter(user, filter.Operands[0]) && matchUserFilter(user, filter.Operands[1])
+	case domain.FilterOr:
+		return matchUserFilter(user, filter.Operands[0]) || matchUserFilter(user, filter.Operands[1])
+	case domain.FilterNot:
+		return !matchUserFilter(user, filter.Operands[0])
+	}
+
+	var value interface{}
+	switch filter.Field {
+	case domain.UserFieldID:
+		value = user.ID
+	case domain.UserFieldEmail:
+		value = strings.ToLower(user.Email)
+	case domain.UserFieldName:
+		value = strings.ToLower(user.Name)
+	case domain.UserFieldActive:
+		value = user.IsActive()
+	}
+	if text, ok := filter.Value.(string); ok {
+		filter = &domain.UserFilter{Operator: filter.Operator, Field: filter.Field, Value: strings.ToLower(text)}
+	}
+
+	switch filter.Operator {
+	case domain.FilterPresent:
+		return value != nil && value != ""
+	case domain.FilterContains:
+		text, _ := value.(string)
+		return strings.Contains(text, filter.Value.(string))
+	default:
+		return value == filter.Value
 	}
-	return users, nil
 }
 
 func TestCreateUser(t *testing.T) {
 	repo := newMockUserRepository()
-	service := NewUserService(repo)
+	service := NewUserService(repo, testHasher, password.DefaultPolicy(), newMockEmailVerifier(), NewAuditService(newMockAuditRepository()), testAPIConfig)
 
 	tests := []struct {
 		name    string
@@ -109,7 +267,7 @@ func TestCreateUser(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := service.Create(tt.user)
+			err := service.Create(tt.user, testRequest)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Create() error = %v, wantErr %v", err, tt.wantErr)
 			}
@@ -119,7 +277,7 @@ func TestCreateUser(t *testing.T) {
 
 func TestUpdateUser(t *testing.T) {
 	repo := newMockUserRepository()
-	service := NewUserService(repo)
+	service := NewUserService(repo, testHasher, password.DefaultPolicy(), newMockEmailVerifier(), NewAuditService(newMockAuditRepository()), testAPIConfig)
 
 	// Create initial user
 	user := &domain.User{
@@ -159,7 +317,7 @@ func TestUpdateUser(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := service.Update(tt.user)
+			err := service.Update(domain.SystemPrincipal(), tt.user)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Update() error = %v, wantErr %v", err, tt.wantErr)
 			}
@@ -169,7 +327,7 @@ func TestUpdateUser(t *testing.T) {
 
 func TestGetUser(t *testing.T) {
 	repo := newMockUserRepository()
-	service := NewUserService(repo)
+	service := NewUserService(repo, testHasher, password.DefaultPolicy(), newMockEmailVerifier(), NewAuditService(newMockAuditRepository()), testAPIConfig)
 
 	// Create test user
 	user := &domain.User{
@@ -202,7 +360,7 @@ func TestGetUser(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := service.Get(tt.id)
+			got, err := service.Get(domain.SystemPrincipal(), tt.id)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Get() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -213,3 +371,511 @@ func TestGetUser(t *testing.T) {
 		})
 	}
 }
+
+// countingHasher counts the passwords checked by Verify
+type countingHasher struct {
+	password.Hasher
+	verified int
+}
+
+func (h *countingHasher) Verify(plainPassword, encoded string) bool {
+	h.verified++
+	return h.Hasher.Verify(plainPassword, encoded)
+}
+
+func TestVerifyPassword(t *testing.T) {
+	repo := newMockUserRepository()
+	hasher := &countingHasher{Hasher: testHasher}
+	service := NewUserService(repo, hasher, password.DefaultPolicy(), newMockEmailVerifier(), NewAuditService(newMockAuditRepository()), testAPIConfig)
+
+	hashed, err := testHasher.Hash("Password123!")
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+	repo.users[1] = &domain.User{
+		ID:       1,
+		Email:    "test@example.com",
+		Password: hashed,
+		Name:     "Test User",
+	}
+
+	tests := []struct {
+		name     string
+		email    string
+		password string
+		wantErr  bool
+	}{
+		{
+			name:     "valid credentials",
+			email:    "test@example.com",
+			password: "Password123!",
+			wantErr:  false,
+		},
+		{
+			name:     "wrong password",
+			email:    "test@example.com",
+			password: "WrongPassword123!",
+			wantErr:  true,
+		},
+		{
+			name:     "unknown email",
+			email:    "unknown@example.com",
+			password: "Password123!",
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hasher.verified = 0
+			user, err := service.VerifyPassword(tt.email, tt.password)
+			// Unknown emails are checked against a dummy hash, so they cost as much as known ones
+			if hasher.verified != 1 {
+				t.Errorf("VerifyPassword() checked %d hashes, want 1", hasher.verified)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("VerifyPassword() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				appErr, ok := err.(*errors.AppError)
+				if !ok || appErr.Type != errors.Unauthorized {
+					t.Errorf("VerifyPassword() error = %v, want Unauthorized", err)
+				}
+				return
+			}
+			if user.ID != 1 {
+				t.Errorf("VerifyPassword() user = %v, want ID 1", user)
+			}
+		})
+	}
+}
+
+func TestPasswordIsHashedBeforeSaving(t *testing.T) {
+	repo := newMockUserRepository()
+	service := NewUserService(repo, testHasher, password.DefaultPolicy(), newMockEmailVerifier(), NewAuditService(newMockAuditRepository()), testAPIConfig)
+
+	user := &domain.User{
+		Email:    "test@example.com",
+		Password: "Password123!",
+		Name:     "Test User",
+	}
+	if err := service.Create(user, testRequest); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	stored := repo.users[user.ID]
+	if stored.Password == "Password123!" || !testHasher.Verify("Password123!", stored.Password) {
+		t.Errorf("Create() stored password = %q, want bcrypt hash", stored.Password)
+	}
+	createdHash := stored.Password
+
+	// Updating without a password keeps the existing hash
+	err := service.Update(domain.SystemPrincipal(), &domain.User{ID: user.ID, Email: user.Email, Name: "Renamed User"})
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if repo.users[user.ID].Password != createdHash {
+		t.Error("Update() without password changed the stored hash")
+	}
+
+	// Updating with a password stores a hash of the new one
+	err = service.Update(domain.SystemPrincipal(), &domain.User{ID: user.ID, Email: user.Email, Password: "NewPassword123!", Name: "Renamed User"})
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if !testHasher.Verify("NewPassword123!", repo.users[user.ID].Password) {
+		t.Errorf("Update() stored password = %q, want bcrypt hash of new password", repo.users[user.ID].Password)
+	}
+}
+
+func TestVerifyPasswordRehashesOutdatedHash(t *testing.T) {
+	repo := newMockUserRepository()
+	hasher, err := password.NewHasher(config.PasswordConfig{
+		Algorithm:         password.AlgorithmArgon2id,
+		BcryptCost:        bcrypt.MinCost,
+		Argon2Memory:      1024,
+		Argon2Iterations:  1,
+		Argon2Parallelism: 1,
+		Argon2SaltLength:  16,
+		Argon2KeyLength:   32,
+	})
+	if err != nil {
+		t.Fatalf("NewHasher() error = %v", err)
+	}
+	service := NewUserService(repo, hasher, password.DefaultPolicy(), newMockEmailVerifier(), NewAuditService(newMockAuditRepository()), testAPIConfig)
+
+	// Stored with the legacy bcrypt algorithm
+	legacyHash, _ := testHasher.Hash("Password123!")
+	repo.users[1] = &domain.User{
+		ID:       1,
+		Email:    "test@example.com",
+		Password: legacyHash,
+		Name:     "Test User",
+	}
+
+	if _, err := service.VerifyPassword("test@example.com", "Password123!"); err != nil {
+		t.Fatalf("VerifyPassword() error = %v", err)
+	}
+	if !repo.updatePasswordCalled {
+		t.Fatal("VerifyPassword() did not persist the upgraded hash")
+	}
+	upgraded := repo.users[1].Password
+	if hasher.NeedsRehash(upgraded) || !hasher.Verify("Password123!", upgraded) {
+		t.Errorf("VerifyPassword() stored hash %q, want current argon2id hash", upgraded)
+	}
+
+	// A current hash is left alone
+	repo.updatePasswordCalled = false
+	if _, err := service.VerifyPassword("test@example.com", "Password123!"); err != nil {
+		t.Fatalf("VerifyPassword() error = %v", err)
+	}
+	if repo.updatePasswordCalled {
+		t.Error("VerifyPassword() rehashed a current hash")
+	}
+}
+
+func TestCreateUserReportsAllPasswordViolations(t *testing.T) {
+	repo := newMockUserRepository()
+	policy := password.DefaultPolicy()
+	policy.DisallowUserInfo = true
+	service := NewUserService(repo, testHasher, policy, newMockEmailVerifier(), NewAuditService(newMockAuditRepository()), testAPIConfig)
+
+	err := service.Create(&domain.User{
+		Email:    "jdoe@example.com",
+		Password: "jdoe",
+		Name:     "John Doe",
+	}, testRequest)
+	appErr, ok := err.(*errors.AppError)
+	if !ok || appErr.Type != errors.InvalidPassword {
+		t.Fatalf("Create() error = %v, want InvalidPassword", err)
+	}
+
+	codes := make(map[string]bool)
+	for _, detail := range appErr.Details {
+		codes[detail.Code] = true
+	}
+	for _, want := range []string{
+		password.ViolationTooShort,
+		password.ViolationMissingUpper,
+		password.ViolationMissingNumber,
+		password.ViolationMissingSpecial,
+		password.ViolationContainsUserInfo,
+	} {
+		if !codes[want] {
+			t.Errorf("Create() details = %v, missing %s", appErr.Details, want)
+		}
+	}
+	if repo.createCalled {
+		t.Error("Create() stored a user with an invalid password")
+	}
+}
+
+func TestUserServiceAuthorization(t *testing.T) {
+	repo := newMockUserRepository()
+	service := NewUserService(repo, testHasher, password.DefaultPolicy(), newMockEmailVerifier(), NewAuditService(newMockAuditRepository()), testAPIConfig)
+	repo.users[1] = &domain.User{ID: 1, Email: "self@example.com", Name: "Self User"}
+	repo.users[2] = &domain.User{ID: 2, Email: "other@example.com", Name: "Other User"}
+
+	self := domain.NewPrincipal(repo.users[1], nil)
+	adminRole := &domain.Role{Name: domain.RoleAdmin}
+	for _, permission := range domain.BuiltinRoles[domain.RoleAdmin] {
+		adminRole.Permissions = append(adminRole.Permissions, domain.RolePermission{Permission: permission})
+	}
+	admin := domain.NewPrincipal(&domain.User{ID: 3}, []*domain.Role{adminRole})
+
+	assertForbidden := func(name string, err error) {
+		t.Helper()
+		appErr, ok := err.(*errors.AppError)
+		if !ok || appErr.Type != errors.Forbidden {
+			t.Errorf("%s error = %v, want Forbidden", name, err)
+		}
+	}
+
+	// A normal user can read and update their own record only
+	if _, err := service.Get(self, 1); err != nil {
+		t.Errorf("Get(self) error = %v", err)
+	}
+	if err := service.Update(self, &domain.User{ID: 1, Email: "self@example.com", Name: "Renamed Self"}); err != nil {
+		t.Errorf("Update(self) error = %v", err)
+	}
+	_, err := service.Get(self, 2)
+	assertForbidden("Get(other)", err)
+	assertForbidden("Update(other)", service.Update(self, &domain.User{ID: 2, Email: "other@example.com", Name: "Hijacked"}))
+	assertForbidden("Delete(self)", service.Delete(self, 1, 0))
+	_, err = service.List(self, domain.UserListParams{}, domain.UserPageParams{})
+	assertForbidden("List()", err)
+	_, err = service.Get(nil, 1)
+	assertForbidden("Get(nil)", err)
+
+	// An admin impersonating the user cannot change their password
+	impersonated := domain.NewPrincipal(repo.users[1], nil)
+	impersonated.Impersonator = &domain.User{ID: 3}
+	assertForbidden("Update(impersonated password)", service.Update(impersonated, &domain.User{ID: 1, Email: "self@example.com", Name: "Renamed Self", Password: "NewPassword123!"}))
+
+	if repo.users[2].Name != "Other User" || repo.users[1] == nil {
+		t.Error("unauthorized calls modified the repository")
+	}
+
+	// An admin can manage everyone
+	if _, err := service.Get(admin, 2); err != nil {
+		t.Errorf("Get(admin) error = %v", err)
+	}
+	if _, err := service.List(admin, domain.UserListParams{}, domain.UserPageParams{}); err != nil {
+		t.Errorf("List(admin) error = %v", err)
+	}
+	if err := service.Delete(admin, 2, 0); err != nil {
+		t.Errorf("Delete(admin) error = %v", err)
+	}
+}
+
+func TestPatchUser(t *testing.T) {
+	newService := func() (*mockUserRepository, domain.UserService) {
+		repo := newMockUserRepository()
+		// Stored before the email rules were enforced
+		repo.users[1] = &domain.User{ID: 1, Email: "legacy user@example", Name: "Test User", Password: "stored-hash"}
+		repo.users[2] = &domain.User{ID: 2, Email: "taken@example.com", Name: "Other User"}
+		return repo, NewUserService(repo, testHasher, password.DefaultPolicy(), newMockEmailVerifier(), NewAuditService(newMockAuditRepository()), testAPIConfig)
+	}
+	mergePatch := func(body string) *domain.UserPatch {
+		return &domain.UserPatch{ContentType: domain.MergePatchContentType, Body: []byte(body)}
+	}
+	jsonPatch := func(body string) *domain.UserPatch {
+		return &domain.UserPatch{ContentType: domain.JSONPatchContentType, Body: []byte(body)}
+	}
+
+	t.Run("merge patch renames without resending the email", func(t *testing.T) {
+		repo, service := newService()
+		user, err := service.Patch(domain.SystemPrincipal(), 1, mergePatch(`{"name":"Renamed User"}`))
+		if err != nil {
+			t.Fatalf("Patch() error = %v", err)
+		}
+		if user.Name != "Renamed User" || repo.users[1].Name != "Renamed User" {
+			t.Errorf("after Patch() name = %q", repo.users[1].Name)
+		}
+		if repo.users[1].Email != "legacy user@example" || repo.users[1].Password != "stored-hash" {
+			t.Error("Patch() modified fields the patch did not touch")
+		}
+		if strings.Join(repo.updatedColumns, ",") != "name" {
+			t.Errorf("Patch() stored columns %v, want [name]", repo.updatedColumns)
+		}
+	})
+
+	t.Run("json patch with test operations", func(t *testing.T) {
+		repo, service := newService()
+		_, err := service.Patch(domain.SystemPrincipal(), 1, jsonPatch(`[
+			{"op":"test","path":"/name","value":"Test User"},
+			{"op":"replace","path":"/name","value":"Patched User"},
+			{"op":"add","path":"/password","value":"NewPassword123!"}
+		]`))
+		if err != nil {
+			t.Fatalf("Patch() error = %v", err)
+		}
+		if repo.users[1].Name != "Patched User" || !testHasher.Verify("NewPassword123!", repo.users[1].Password) {
+			t.Errorf("after Patch() user = %+v", repo.users[1])
+		}
+
+		_, err = service.Patch(domain.SystemPrincipal(), 1, jsonPatch(`[{"op":"test","path":"/name","value":"Test User"},{"op":"replace","path":"/name","value":"Lost Update"}]`))
+		assertErrorType(t, "Patch(failed test)", err, errors.Conflict)
+		if repo.users[1].Name != "Patched User" {
+			t.Error("Patch() applied operations after a failed test")
+		}
+	})
+
+	t.Run("email change stays pending", func(t *testing.T) {
+		repo, service := newService()
+		user, err := service.Patch(domain.SystemPrincipal(), 1, mergePatch(`{"email":"new@example.com"}`))
+		if err != nil {
+			t.Fatalf("Patch() error = %v", err)
+		}
+		if user.Email != "legacy user@example" || repo.users[1].PendingEmail != "new@example.com" {
+			t.Errorf("after Patch() email = %q, pending = %q", user.Email, repo.users[1].PendingEmail)
+		}
+
+		_, err = service.Patch(domain.SystemPrincipal(), 1, mergePatch(`{"email":"taken@example.com"}`))
+		assertErrorType(t, "Patch(taken email)", err, errors.DuplicateEmail)
+	})
+
+	t.Run("resulting document is validated", func(t *testing.T) {
+		repo, service := newService()
+		tests := []struct {
+			name  string
+			patch *domain.UserPatch
+			want  errors.ErrorType
+		}{
+			{"removed name", mergePatch(`{"name":null}`), errors.InvalidInput},
+			{"invalid email", mergePatch(`{"email":"not an email"}`), errors.InvalidEmail},
+			{"weak password", mergePatch(`{"password":"short"}`), errors.InvalidPassword},
+			{"changed id", mergePatch(`{"id":2}`), errors.InvalidInput},
+			{"unknown field", mergePatch(`{"role":"admin"}`), errors.InvalidInput},
+			{"wrong type", mergePatch(`{"name":42}`), errors.InvalidInput},
+			{"missing path", jsonPatch(`[{"op":"remove","path":"/nickname"}]`), errors.InvalidInput},
+			{"unsupported media type", &domain.UserPatch{ContentType: "application/json", Body: []byte(`{}`)}, errors.InvalidInput},
+		}
+		for _, tt := range tests {
+			_, err := service.Patch(domain.SystemPrincipal(), 1, tt.patch)
+			assertErrorType(t, "Patch("+tt.name+")", err, tt.want)
+		}
+		if repo.updatedColumns != nil {
+			t.Errorf("rejected patches stored columns %v", repo.updatedColumns)
+		}
+	})
+
+	t.Run("authorization", func(t *testing.T) {
+		repo, service := newService()
+		self := domain.NewPrincipal(repo.users[1], nil)
+		_, err := service.Patch(self, 2, mergePatch(`{"name":"Hijacked"}`))
+		assertErrorType(t, "Patch(other)", err, errors.Forbidden)
+
+		impersonated := domain.NewPrincipal(repo.users[1], nil)
+		impersonated.Impersonator = &domain.User{ID: 3}
+		_, err = service.Patch(impersonated, 1, mergePatch(`{"password":"NewPassword123!"}`))
+		assertErrorType(t, "Patch(impersonated password)", err, errors.Forbidden)
+
+		_, err = service.Patch(domain.SystemPrincipal(), 999, mergePatch(`{"name":"Nobody"}`))
+		assertErrorType(t, "Patch(missing)", err, errors.NotFound)
+	})
+}
+
+func TestUserVersionPreconditions(t *testing.T) {
+	repo := newMockUserRepository()
+	service := NewUserService(repo, testHasher, password.DefaultPolicy(), newMockEmailVerifier(), NewAuditService(newMockAuditRepository()), testAPIConfig)
+	repo.users[1] = &domain.User{ID: 1, Email: "test@example.com", Name: "Test User", Version: 3}
+
+	// Two admins read version 3; the second write must not overwrite the first
+	if err := service.Update(domain.SystemPrincipal(), &domain.User{ID: 1, Email: "test@example.com", Name: "First Admin", Version: 3}); err != nil {
+		t.Fatalf("Update(current version) error = %v", err)
+	}
+	if repo.users[1].Version != 4 {
+		t.Errorf("after Update() version = %d, want 4", repo.users[1].Version)
+	}
+	err := service.Update(domain.SystemPrincipal(), &domain.User{ID: 1, Email: "test@example.com", Name: "Second Admin", Version: 3})
+	assertErrorType(t, "Update(stale version)", err, errors.PreconditionFailed)
+	_, err = service.Patch(domain.SystemPrincipal(), 1, &domain.UserPatch{ContentType: domain.MergePatchContentType, Body: []byte(`{"name":"Second Admin"}`), Version: 3})
+	assertErrorType(t, "Patch(stale version)", err, errors.PreconditionFailed)
+	assertErrorType(t, "Delete(stale version)", service.Delete(domain.SystemPrincipal(), 1, 3), errors.PreconditionFailed)
+	if repo.users[1].Name != "First Admin" {
+		t.Errorf("stale writes changed the name to %q", repo.users[1].Name)
+	}
+
+	user, err := service.Patch(domain.SystemPrincipal(), 1, &domain.UserPatch{ContentType: domain.MergePatchContentType, Body: []byte(`{"name":"Patched"}`), Version: 4})
+	if err != nil {
+		t.Fatalf("Patch(current version) error = %v", err)
+	}
+	if user.Version != 5 {
+		t.Errorf("after Patch() version = %d, want 5", user.Version)
+	}
+	if err := service.Delete(domain.SystemPrincipal(), 1, 5); err != nil {
+		t.Errorf("Delete(current version) error = %v", err)
+	}
+}
+
+func TestListUsersQuery(t *testing.T) {
+	repo := newMockUserRepository()
+	service := NewUserService(repo, testHasher, password.DefaultPolicy(), newMockEmailVerifier(), NewAuditService(newMockAuditRepository()), testAPIConfig)
+	admin := domain.SystemPrincipal()
+
+	_, err := service.List(admin, domain.UserListParams{
+		Filters: []domain.UserListFilter{
+			{Field: "email", Value: "Test@Example.com"},
+			{Field: "created_at", Operator: "gte", Value: "2024-01-01T00:00:00Z"},
+			{Field: "active", Value: "false"},
+		},
+		Sort:   "-created_at, name",
+		Search: "  jon  ",
+	}, domain.UserPageParams{})
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	want := domain.UserQuery{
+		Filter: &domain.UserFilter{Operator: domain.FilterAnd, Operands: []*domain.UserFilter{
+			{Operator: domain.FilterEqual, Field: domain.UserFieldEmail, Value: "Test@Example.com"},
+			{Operator: domain.FilterGreaterOrEqual, Field: domain.UserFieldCreatedAt, Value: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+			{Operator: domain.FilterEqual, Field: domain.UserFieldActive, Value: false},
+		}},
+		Search: "jon",
+		Sort:   []domain.UserSort{{Field: domain.UserFieldCreatedAt, Descending: true}, {Field: domain.UserFieldName}},
+	}
+	if !reflect.DeepEqual(repo.listQuery, want) {
+		t.Errorf("List() query = %+v, want %+v", repo.listQuery, want)
+	}
+
+	// Only whitelisted fields, operators and well-formed values reach the repository
+	invalid := []domain.UserListParams{
+		{Filters: []domain.UserListFilter{{Field: "password", Value: "x"}}},
+		{Filters: []domain.UserListFilter{{Field: "email", Operator: "gt", Value: "a"}}},
+		{Filters: []domain.UserListFilter{{Field: "name", Operator: "like", Value: "a"}}},
+		{Filters: []domain.UserListFilter{{Field: "id", Value: "1 OR 1=1"}}},
+		{Filters: []domain.UserListFilter{{Field: "active", Operator: "lt", Value: "true"}}},
+		{Filters: []domain.UserListFilter{{Field: "created_at", Operator: "gte", Value: "yesterday"}}},
+		{Sort: "password"},
+		{Sort: "name;DROP TABLE users"},
+		{Sort: "name,-name"},
+		{Sort: "name,"},
+		{Sort: "id,name"},
+		{Sort: "-id,-created_at"},
+		{Search: strings.Repeat("a", maxUserSearchLength+1)},
+	}
+	for _, params := range invalid {
+		repo.listCalled = false
+		_, err := service.List(admin, params, domain.UserPageParams{})
+		assertErrorType(t, fmt.Sprintf("List(%+v)", params), err, errors.InvalidInput)
+		if repo.listCalled {
+			t.Errorf("List(%+v) queried the repository", params)
+		}
+	}
+}
+
+func TestListUsersCursors(t *testing.T) {
+	repo := newMockUserRepository()
+	service := NewUserService(repo, testHasher, password.DefaultPolicy(), newMockEmailVerifier(), NewAuditService(newMockAuditRepository()), testAPIConfig)
+	admin := domain.SystemPrincipal()
+	for id := uint(1); id <= 5; id++ {
+		repo.users[id] = &domain.User{ID: id, Email: fmt.Sprintf("user%d@example.com", id), Name: "User"}
+	}
+	params := domain.UserListParams{Sort: "id"}
+	ids := func(page *domain.UserPage) []uint {
+		var ids []uint
+		for _, user := range page.Users {
+			ids = append(ids, user.ID)
+		}
+		return ids
+	}
+
+	// Page sizes default to and are capped by the configuration
+	first, err := service.List(admin, params, domain.UserPageParams{})
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if !reflect.DeepEqual(ids(first), []uint{1, 2}) || first.PrevCursor != "" || first.NextCursor == "" {
+		t.Fatalf("List() = %v, prev %q, next %q, want users 1 and 2 and only a next cursor", ids(first), first.PrevCursor, first.NextCursor)
+	}
+	if _, err := service.List(admin, params, domain.UserPageParams{Limit: 50}); err != nil || repo.listSeek.Limit != testAPIConfig.MaxPageSize {
+		t.Errorf("List(limit 50) limit = %d, %v, want %d", repo.listSeek.Limit, err, testAPIConfig.MaxPageSize)
+	}
+
+	second, err := service.List(admin, params, domain.UserPageParams{After: first.NextCursor, Total: domain.UserTotalEstimate})
+	if err != nil {
+		t.Fatalf("List(after) error = %v", err)
+	}
+	if !reflect.DeepEqual(ids(second), []uint{3, 4}) || second.PrevCursor == "" || second.NextCursor == "" || !second.TotalEstimated {
+		t.Fatalf("List(after) = %+v, want users 3 and 4 with both cursors and an estimated total", second)
+	}
+	back, err := service.List(admin, params, domain.UserPageParams{Before: second.PrevCursor})
+	if err != nil || !reflect.DeepEqual(ids(back), []uint{1, 2}) || back.PrevCursor != "" {
+		t.Errorf("List(before) = %v, %v, want users 1 and 2 without a previous page", ids(back), err)
+	}
+
+	// Cursors are bound to their listing and cannot be altered
+	_, err = service.List(admin, domain.UserListParams{Sort: "-id"}, domain.UserPageParams{After: first.NextCursor})
+	assertErrorType(t, "List(cursor of another sort)", err, errors.InvalidInput)
+	forged := first.NextCursor[:len(first.NextCursor)-2] + "AA"
+	_, err = service.List(admin, params, domain.UserPageParams{After: forged})
+	assertErrorType(t, "List(forged cursor)", err, errors.InvalidInput)
+	_, err = service.List(admin, params, domain.UserPageParams{After: first.NextCursor, Before: second.PrevCursor})
+	assertErrorType(t, "List(after and before)", err, errors.InvalidInput)
+	_, err = service.List(admin, params, domain.UserPageParams{Limit: -1})
+	assertErrorType(t, "List(negative limit)", err, errors.InvalidInput)
+	_, err = service.List(admin, params, domain.UserPageParams{Total: "all"})
+	assertErrorType(t, "List(total all)", err, errors.InvalidInput)
+}
@@ -0,0 +1,341 @@
+package service
+
+import (
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/errors"
+	"UserRESTfulApi/pkg/config"
+	"UserRESTfulApi/pkg/token"
+	"UserRESTfulApi/pkg/webauthn"
+	"encoding/hex"
+	stderrors "errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxPasskeyNameLength limits the display name users give a passkey
+const maxPasskeyNameLength = 64
+
+type webAuthnService struct {
+	users        domain.UserRepository
+	repo         domain.WebAuthnRepository
+	rp           *webauthn.RelyingParty
+	rpName       string
+	timeout      time.Duration
+	passwordless bool
+	now          func() time.Time
+}
+
+// NewWebAuthnService creates a new passkey service for the configured relying party
+func NewWebAuthnService(users domain.UserRepository, repo domain.WebAuthnRepository, cfg config.AuthConfig) domain.WebAuthnService {
+	return &webAuthnService{
+		users:        users,
+		repo:         repo,
+		rp:           &webauthn.RelyingParty{ID: cfg.WebAuthnRPID, Origins: cfg.WebAuthnOrigins},
+		rpName:       cfg.WebAuthnRPName,
+		timeout:      cfg.WebAuthnTimeout,
+		passwordless: cfg.PasswordlessLogin(),
+		now:          time.Now,
+	}
+}
+
+// BeginRegistration starts registering a passkey for the actor. Credentials
+// the actor already registered are excluded so an authenticator is not
+// registered twice.
+func (s *webAuthnService) BeginRegistration(actor *domain.Principal) (*domain.WebAuthnCreationOptions, error) {
+	user, err := s.users.Get(actor.ID())
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, errors.NotFoundError("user", actor.ID())
+	}
+	credentials, err := s.repo.ListCredentials(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	challenge, err := s.newChallenge(&user.ID, domain.WebAuthnRegistration)
+	if err != nil {
+		return nil, err
+	}
+
+	params := make([]domain.WebAuthnCredentialParameter, 0, len(webauthn.SupportedAlgorithms))
+	for _, alg := range webauthn.SupportedAlgorithms {
+		params = append(params, domain.WebAuthnCredentialParameter{Type: "public-key", Alg: alg})
+	}
+	return &domain.WebAuthnCreationOptions{
+		Challenge:          challenge,
+		RP:                 domain.WebAuthnRelyingParty{ID: s.rp.ID, Name: s.rpName},
+		User:               domain.WebAuthnUser{ID: userHandle(user.ID), Name: user.Email, DisplayName: user.Name},
+		PubKeyCredParams:   params,
+		Timeout:            s.timeout.Milliseconds(),
+		ExcludeCredentials: descriptors(credentials),
+		AuthenticatorSelection: domain.WebAuthnAuthenticatorSelection{
+			ResidentKey:      "preferred",
+			UserVerification: "preferred",
+		},
+		Attestation: "direct",
+	}, nil
+}
+
+// FinishRegistration verifies the attestation against the actor's open
+// registration challenge and stores the new credential
+func (s *webAuthnService) FinishRegistration(actor *domain.Principal, name string, attestation *domain.WebAuthnAttestation) (*domain.WebAuthnCredential, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = "Passkey"
+	}
+	if len(name) > maxPasskeyNameLength {
+		return nil, errors.InvalidInputError("name", "must be at most "+strconv.Itoa(maxPasskeyNameLength)+" characters")
+	}
+
+	clientDataJSON, err := webauthn.DecodeBase64(attestation.Response.ClientDataJSON)
+	if err != nil {
+		return nil, errors.InvalidInputError("response.clientDataJSON", "must be base64url encoded")
+	}
+	attestationObject, err := webauthn.DecodeBase64(attestation.Response.AttestationObject)
+	if err != nil {
+		return nil, errors.InvalidInputError("response.attestationObject", "must be base64url encoded")
+	}
+
+	userID := actor.ID()
+	challenge, err := s.redeemChallenge(clientDataJSON, domain.WebAuthnRegistration, &userID)
+	if err != nil {
+		return nil, errors.InvalidInputError("response.clientDataJSON", "registration challenge is invalid or has expired")
+	}
+	verified, err := s.rp.VerifyRegistration(challenge, clientDataJSON, attestationObject, false)
+	if err != nil {
+		return nil, errors.InvalidInputError("credential", err.Error())
+	}
+
+	credentialID := webauthn.EncodeBase64(verified.ID)
+	existing, err := s.repo.GetCredential(credentialID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, errors.InvalidInputError("credential", "is already registered")
+	}
+
+	credential := &domain.WebAuthnCredential{
+		UserID:          userID,
+		CredentialID:    credentialID,
+		PublicKey:       verified.PublicKey,
+		Algorithm:       verified.Algorithm,
+		SignCount:       verified.SignCount,
+		AAGUID:          hex.EncodeToString(verified.AAGUID),
+		AttestationType: verified.AttestationType,
+		Name:            name,
+	}
+	if err := s.repo.CreateCredential(credential); err != nil {
+		return nil, err
+	}
+	log.Printf("Passkey %d registered for user %d with %s attestation", credential.ID, userID, verified.AttestationType)
+	return credential, nil
+}
+
+// List lists the actor's passkeys
+func (s *webAuthnService) List(actor *domain.Principal) ([]*domain.WebAuthnCredential, error) {
+	return s.repo.ListCredentials(actor.ID())
+}
+
+// Delete removes one of the actor's passkeys
+func (s *webAuthnService) Delete(actor *domain.Principal, id uint) error {
+	deleted, err := s.repo.DeleteCredential(actor.ID(), id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return errors.NotFoundError("passkey", id)
+	}
+	return nil
+}
+
+// HasCredentials reports whether the user registered a passkey
+func (s *webAuthnService) HasCredentials(userID uint) (bool, error) {
+	count, err := s.repo.CountCredentials(userID)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// BeginLogin starts a passkey login. No credentials are listed, so the
+// authenticator offers the passkeys it holds for the relying party and the
+// user is identified from the one chosen.
+func (s *webAuthnService) BeginLogin() (*domain.WebAuthnRequestOptions, error) {
+	if !s.passwordless {
+		return nil, errors.ForbiddenError("log in without a password")
+	}
+	return s.requestOptions(nil, domain.WebAuthnLogin, nil, "required")
+}
+
+// FinishLogin verifies a passkey login. The authenticator must have verified
+// the user with a PIN or biometric, which makes the passkey a second factor
+// of its own.
+func (s *webAuthnService) FinishLogin(assertion *domain.WebAuthnAssertion) (*domain.User, error) {
+	if !s.passwordless {
+		return nil, errors.ForbiddenError("log in without a password")
+	}
+	credential, err := s.verifyAssertion(assertion, domain.WebAuthnLogin, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.users.Get(credential.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, errors.UnauthorizedError("passkey is invalid")
+	}
+	return user, nil
+}
+
+// BeginMFA starts a second factor check listing the user's credentials
+func (s *webAuthnService) BeginMFA(userID uint) (*domain.WebAuthnRequestOptions, error) {
+	credentials, err := s.repo.ListCredentials(userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(credentials) == 0 {
+		return nil, errors.UnauthorizedError("no passkey is registered")
+	}
+	return s.requestOptions(&userID, domain.WebAuthnMFA, credentials, "discouraged")
+}
+
+// FinishMFA verifies a second factor assertion made with one of the user's credentials
+func (s *webAuthnService) FinishMFA(userID uint, assertion *domain.WebAuthnAssertion) error {
+	_, err := s.verifyAssertion(assertion, domain.WebAuthnMFA, &userID)
+	return err
+}
+
+// verifyAssertion checks an assertion against an open challenge of the
+// purpose and the stored credential, and records the new signature counter.
+// Every failure is reported as Unauthorized.
+func (s *webAuthnService) verifyAssertion(assertion *domain.WebAuthnAssertion, purpose string, userID *uint) (*domain.WebAuthnCredential, error) {
+	invalid := errors.UnauthorizedError("passkey is invalid")
+
+	clientDataJSON, err1 := webauthn.DecodeBase64(assertion.Response.ClientDataJSON)
+	authenticatorData, err2 := webauthn.DecodeBase64(assertion.Response.AuthenticatorData)
+	signature, err3 := webauthn.DecodeBase64(assertion.Response.Signature)
+	credentialID, err4 := webauthn.DecodeBase64(assertion.ID)
+	if err := stderrors.Join(err1, err2, err3, err4); err != nil {
+		return nil, invalid
+	}
+
+	challenge, err := s.redeemChallenge(clientDataJSON, purpose, userID)
+	if err != nil {
+		return nil, err
+	}
+	credential, err := s.repo.GetCredential(webauthn.EncodeBase64(credentialID))
+	if err != nil {
+		return nil, err
+	}
+	if credential == nil || (userID != nil && credential.UserID != *userID) {
+		return nil, invalid
+	}
+	if assertion.Response.UserHandle != "" {
+		handle, err := webauthn.DecodeBase64(assertion.Response.UserHandle)
+		if err != nil || webauthn.EncodeBase64(handle) != userHandle(credential.UserID) {
+			return nil, invalid
+		}
+	}
+
+	verified, err := s.rp.VerifyAssertion(challenge, credential.PublicKey, credential.SignCount,
+		clientDataJSON, authenticatorData, signature, purpose == domain.WebAuthnLogin)
+	if err != nil {
+		if stderrors.Is(err, webauthn.ErrSignCountRegressed) {
+			log.Printf("Signature counter of passkey %d of user %d went back, the authenticator may be cloned", credential.ID, credential.UserID)
+		}
+		return nil, invalid
+	}
+
+	used, err := s.repo.UseCredential(credential.ID, credential.SignCount, verified.SignCount)
+	if err != nil {
+		return nil, err
+	}
+	if !used {
+		// Another login with the credential was accepted between our read and update
+		log.Printf("Passkey %d of user %d was used concurrently, the authenticator may be cloned", credential.ID, credential.UserID)
+		return nil, invalid
+	}
+	return credential, nil
+}
+
+// newChallenge stores a random challenge for a ceremony and returns it base64url encoded
+func (s *webAuthnService) newChallenge(userID *uint, purpose string) (string, error) {
+	raw, err := webauthn.NewChallenge()
+	if err != nil {
+		return "", errors.InternalServerError(err)
+	}
+	challenge := webauthn.EncodeBase64(raw)
+	err = s.repo.CreateChallenge(&domain.WebAuthnChallenge{
+		UserID:        userID,
+		Purpose:       purpose,
+		ChallengeHash: token.HashOpaque(challenge),
+		ExpiresAt:     s.now().Add(s.timeout),
+	})
+	if err != nil {
+		return "", err
+	}
+	return challenge, nil
+}
+
+// redeemChallenge finds the open challenge signed in the client data, checks
+// it was issued for the purpose and user, and marks it used
+func (s *webAuthnService) redeemChallenge(clientDataJSON []byte, purpose string, userID *uint) ([]byte, error) {
+	invalid := errors.UnauthorizedError("passkey challenge is invalid or has expired")
+
+	_, raw, err := webauthn.ParseClientData(clientDataJSON)
+	if err != nil {
+		return nil, invalid
+	}
+	challenge, err := s.repo.GetChallengeByHash(token.HashOpaque(webauthn.EncodeBase64(raw)))
+	if err != nil {
+		return nil, err
+	}
+	if challenge == nil || challenge.UsedAt != nil || challenge.Purpose != purpose || !s.now().Before(challenge.ExpiresAt) {
+		return nil, invalid
+	}
+	if (userID == nil) != (challenge.UserID == nil) || (userID != nil && *userID != *challenge.UserID) {
+		return nil, invalid
+	}
+
+	used, err := s.repo.MarkChallengeUsed(challenge.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !used {
+		return nil, invalid
+	}
+	return raw, nil
+}
+
+func (s *webAuthnService) requestOptions(userID *uint, purpose string, credentials []*domain.WebAuthnCredential, userVerification string) (*domain.WebAuthnRequestOptions, error) {
+	challenge, err := s.newChallenge(userID, purpose)
+	if err != nil {
+		return nil, err
+	}
+	return &domain.WebAuthnRequestOptions{
+		Challenge:        challenge,
+		Timeout:          s.timeout.Milliseconds(),
+		RPID:             s.rp.ID,
+		AllowCredentials: descriptors(credentials),
+		UserVerification: userVerification,
+	}, nil
+}
+
+// userHandle is the base64url WebAuthn user handle of a user, their ID in decimal
+func userHandle(userID uint) string {
+	return webauthn.EncodeBase64([]byte(strconv.FormatUint(uint64(userID), 10)))
+}
+
+func descriptors(credentials []*domain.WebAuthnCredential) []domain.WebAuthnCredentialDescriptor {
+	list := make([]domain.WebAuthnCredentialDescriptor, 0, len(credentials))
+	for _, credential := range credentials {
+		list = append(list, domain.WebAuthnCredentialDescriptor{Type: "public-key", ID: credential.CredentialID})
+	}
+	return list
+}
@@ -0,0 +1,498 @@
+package service
+
+import (
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/errors"
+	"UserRESTfulApi/pkg/config"
+	"UserRESTfulApi/pkg/webauthn"
+	"UserRESTfulApi/pkg/webauthn/webauthntest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Mock WebAuthn repository for testing
+type mockWebAuthnRepository struct {
+	credentials map[uint]*domain.WebAuthnCredential
+	challenges  map[uint]*domain.WebAuthnChallenge
+}
+
+func newMockWebAuthnRepository() *mockWebAuthnRepository {
+	return &mockWebAuthnRepository{
+		credentials: make(map[uint]*domain.WebAuthnCredential),
+		challenges:  make(map[uint]*domain.WebAuthnChallenge),
+	}
+}
+
+func (m *mockWebAuthnRepository) CreateCredential(credential *domain.WebAuthnCredential) error {
+	credential.ID = uint(len(m.credentials) + 1)
+	credential.CreatedAt = time.Now()
+	m.credentials[credential.ID] = credential
+	return nil
+}
+
+func (m *mockWebAuthnRepository) GetCredential(credentialID string) (*domain.WebAuthnCredential, error) {
+	for _, credential := range m.credentials {
+		if credential.CredentialID == credentialID {
+			copied := *credential
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *mockWebAuthnRepository) ListCredentials(userID uint) ([]*domain.WebAuthnCredential, error) {
+	var credentials []*domain.WebAuthnCredential
+	for id := uint(1); id <= uint(len(m.credentials)); id++ {
+		if credential, exists := m.credentials[id]; exists && credential.UserID == userID {
+			credentials = append(credentials, credential)
+		}
+	}
+	return credentials, nil
+}
+
+func (m *mockWebAuthnRepository) CountCredentials(userID uint) (int64, error) {
+	credentials, _ := m.ListCredentials(userID)
+	return int64(len(credentials)), nil
+}
+
+func (m *mockWebAuthnRepository) UseCredential(id uint, oldSignCount, newSignCount uint32) (bool, error) {
+	credential, exists := m.credentials[id]
+	if !exists || credential.SignCount != oldSignCount {
+		return false, nil
+	}
+	now := time.Now()
+	credential.SignCount = newSignCount
+	credential.LastUsedAt = &now
+	return true, nil
+}
+
+func (m *mockWebAuthnRepository) DeleteCredential(userID, id uint) (bool, error) {
+	credential, exists := m.credentials[id]
+	if !exists || credential.UserID != userID {
+		return false, nil
+	}
+	delete(m.credentials, id)
+	return true, nil
+}
+
+func (m *mockWebAuthnRepository) CreateChallenge(challenge *domain.WebAuthnChallenge) error {
+	challenge.ID = uint(len(m.challenges) + 1)
+	challenge.CreatedAt = time.Now()
+	m.challenges[challenge.ID] = challenge
+	return nil
+}
+
+func (m *mockWebAuthnRepository) GetChallengeByHash(challengeHash string) (*domain.WebAuthnChallenge, error) {
+	for _, challenge := range m.challenges {
+		if challenge.ChallengeHash == challengeHash {
+			copied := *challenge
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *mockWebAuthnRepository) MarkChallengeUsed(id uint) (bool, error) {
+	challenge, exists := m.challenges[id]
+	if !exists || challenge.UsedAt != nil {
+		return false, nil
+	}
+	now := time.Now()
+	challenge.UsedAt = &now
+	return true, nil
+}
+
+const (
+	testRPID   = "example.com"
+	testOrigin = "https://app.example.com"
+)
+
+func testWebAuthnConfig(cfg config.AuthConfig) config.AuthConfig {
+	cfg.WebAuthnRPID = testRPID
+	cfg.WebAuthnRPName = "Test"
+	cfg.WebAuthnOrigins = []string{testOrigin}
+	cfg.WebAuthnTimeout = 5 * time.Minute
+	return cfg
+}
+
+// newTestWebAuthnService creates a passkey service for the users
+// test@example.com and other@example.com
+func newTestWebAuthnService(t *testing.T) (domain.WebAuthnService, *mockUserRepository, *mockWebAuthnRepository) {
+	users := newMockUserRepository()
+	users.users[1] = &domain.User{ID: 1, Email: "test@example.com", Name: "Test User"}
+	users.users[2] = &domain.User{ID: 2, Email: "other@example.com", Name: "Other User"}
+	repo := newMockWebAuthnRepository()
+	return NewWebAuthnService(users, repo, testWebAuthnConfig(config.AuthConfig{})), users, repo
+}
+
+func newTestAuthenticator(t *testing.T, origin string) *webauthntest.Authenticator {
+	t.Helper()
+	authenticator, err := webauthntest.New(testRPID, origin)
+	if err != nil {
+		t.Fatalf("webauthntest.New() error = %v", err)
+	}
+	return authenticator
+}
+
+// registerPasskey creates a passkey on the authenticator for the user
+func registerPasskey(t *testing.T, service domain.WebAuthnService, authenticator *webauthntest.Authenticator, user *domain.User, format string) *domain.WebAuthnCredential {
+	t.Helper()
+	actor := domain.NewPrincipal(user, nil)
+	options, err := service.BeginRegistration(actor)
+	if err != nil {
+		t.Fatalf("BeginRegistration() error = %v", err)
+	}
+	attestation, err := authenticator.Register(options.Challenge, options.User.ID, format, false)
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	credential, err := service.FinishRegistration(actor, "Laptop", toAttestation(attestation))
+	if err != nil {
+		t.Fatalf("FinishRegistration() error = %v", err)
+	}
+	return credential
+}
+
+// signAssertion answers request options with the credential
+func signAssertion(t *testing.T, authenticator *webauthntest.Authenticator, options *domain.WebAuthnRequestOptions, credentialID string) *domain.WebAuthnAssertion {
+	t.Helper()
+	assertion, err := authenticator.Login(options.Challenge, credentialID)
+	if err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+	return &domain.WebAuthnAssertion{
+		ID:    assertion.ID,
+		RawID: assertion.RawID,
+		Type:  assertion.Type,
+		Response: domain.WebAuthnAssertionResponse{
+			ClientDataJSON:    assertion.Response.ClientDataJSON,
+			AuthenticatorData: assertion.Response.AuthenticatorData,
+			Signature:         assertion.Response.Signature,
+			UserHandle:        assertion.Response.UserHandle,
+		},
+	}
+}
+
+func toAttestation(attestation *webauthntest.Attestation) *domain.WebAuthnAttestation {
+	return &domain.WebAuthnAttestation{
+		ID:    attestation.ID,
+		RawID: attestation.RawID,
+		Type:  attestation.Type,
+		Response: domain.WebAuthnAttestationResponse{
+			ClientDataJSON:    attestation.Response.ClientDataJSON,
+			AttestationObject: attestation.Response.AttestationObject,
+		},
+	}
+}
+
+func TestPasskeyRegistration(t *testing.T) {
+	tests := []struct {
+		name            string
+		format          string
+		passkeyName     string
+		origin          string
+		reuse           bool
+		finishAs        uint
+		wantAttestation string
+		wantName        string
+		wantErr         bool
+	}{
+		{name: "none attestation", format: webauthn.FormatNone, passkeyName: "Laptop", wantAttestation: webauthn.AttestationNone, wantName: "Laptop"},
+		{name: "packed attestation", format: webauthn.FormatPacked, passkeyName: "Laptop", wantAttestation: webauthn.AttestationBasic, wantName: "Laptop"},
+		{name: "default name", format: webauthn.FormatNone, wantAttestation: webauthn.AttestationNone, wantName: "Passkey"},
+		{name: "name too long", format: webauthn.FormatNone, passkeyName: strings.Repeat("x", maxPasskeyNameLength+1), wantErr: true},
+		{name: "other origin", format: webauthn.FormatNone, origin: "https://evil.example.com", wantErr: true},
+		// A challenge is redeemed once
+		{name: "reused challenge", format: webauthn.FormatNone, reuse: true, wantErr: true},
+		{name: "challenge of another user", format: webauthn.FormatNone, finishAs: 2, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, users, repo := newTestWebAuthnService(t)
+			origin := tt.origin
+			if origin == "" {
+				origin = testOrigin
+			}
+			authenticator := newTestAuthenticator(t, origin)
+			actor := domain.NewPrincipal(users.users[1], nil)
+
+			options, err := service.BeginRegistration(actor)
+			if err != nil || options.RP.ID != testRPID {
+				t.Fatalf("BeginRegistration() = %+v, %v, want options for %s", options, err, testRPID)
+			}
+			attestation, err := authenticator.Register(options.Challenge, options.User.ID, tt.format, false)
+			if err != nil {
+				t.Fatalf("Register() error = %v", err)
+			}
+			if tt.reuse {
+				if _, err := service.FinishRegistration(actor, tt.passkeyName, toAttestation(attestation)); err != nil {
+					t.Fatalf("FinishRegistration() error = %v", err)
+				}
+			}
+			if tt.finishAs != 0 {
+				actor = domain.NewPrincipal(users.users[tt.finishAs], nil)
+			}
+			registered := len(repo.credentials)
+
+			credential, err := service.FinishRegistration(actor, tt.passkeyName, toAttestation(attestation))
+			if tt.wantErr {
+				assertErrorType(t, "FinishRegistration()", err, errors.InvalidInput)
+				if len(repo.credentials) != registered {
+					t.Errorf("failed FinishRegistration() stored a credential")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FinishRegistration() error = %v", err)
+			}
+			if credential.AttestationType != tt.wantAttestation || credential.Algorithm != webauthn.AlgES256 || len(credential.PublicKey) == 0 {
+				t.Errorf("FinishRegistration() = %+v, want an ES256 credential with %s attestation", credential, tt.wantAttestation)
+			}
+			if credential.Name != tt.wantName || credential.UserID != 1 {
+				t.Errorf("FinishRegistration() = %q of user %d, want %q of user 1", credential.Name, credential.UserID, tt.wantName)
+			}
+		})
+	}
+}
+
+func TestBeginPasskeyRegistration(t *testing.T) {
+	tests := []struct {
+		name         string
+		registered   int
+		wantExcluded int
+	}{
+		{name: "no passkeys"},
+		// Registered credentials are excluded from the next registration
+		{name: "registered passkeys", registered: 2, wantExcluded: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, users, _ := newTestWebAuthnService(t)
+			authenticator := newTestAuthenticator(t, testOrigin)
+			for i := 0; i < tt.registered; i++ {
+				registerPasskey(t, service, authenticator, users.users[1], webauthn.FormatNone)
+			}
+			registerPasskey(t, service, authenticator, users.users[2], webauthn.FormatNone)
+
+			options, err := service.BeginRegistration(domain.NewPrincipal(users.users[1], nil))
+			if err != nil || len(options.ExcludeCredentials) != tt.wantExcluded {
+				t.Errorf("BeginRegistration() = %+v, %v, want %d excluded credentials", options, err, tt.wantExcluded)
+			}
+		})
+	}
+}
+
+func TestPasskeyDelete(t *testing.T) {
+	tests := []struct {
+		name    string
+		actor   uint
+		unknown bool
+		wantErr bool
+	}{
+		{name: "own passkey", actor: 1},
+		{name: "passkey of another user", actor: 2, wantErr: true},
+		{name: "unknown passkey", actor: 1, unknown: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, users, repo := newTestWebAuthnService(t)
+			credential := registerPasskey(t, service, newTestAuthenticator(t, testOrigin), users.users[1], webauthn.FormatNone)
+			id := credential.ID
+			if tt.unknown {
+				id = 99
+			}
+
+			err := service.Delete(domain.NewPrincipal(users.users[tt.actor], nil), id)
+			if tt.wantErr {
+				assertErrorType(t, "Delete()", err, errors.NotFound)
+				if repo.credentials[credential.ID] == nil {
+					t.Errorf("failed Delete() removed the passkey")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Delete() error = %v", err)
+			}
+			credentials, _ := service.List(domain.NewPrincipal(users.users[1], nil))
+			if len(credentials) != 0 {
+				t.Errorf("List() = %d credentials, want 0", len(credentials))
+			}
+		})
+	}
+}
+
+func TestLoginWithPasskey(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		// before runs between registering the passkey and signing the login
+		before  func(t *testing.T, service *authService, authenticator *webauthntest.Authenticator, credential *domain.WebAuthnCredential)
+		replay  bool
+		wantErr bool
+	}{
+		{name: "discoverable login", format: webauthn.FormatNone},
+		// The assertion cannot be replayed
+		{name: "replayed assertion", format: webauthn.FormatNone, replay: true, wantErr: true},
+		{
+			name:   "without user verification",
+			format: webauthn.FormatNone,
+			before: func(t *testing.T, service *authService, authenticator *webauthntest.Authenticator, credential *domain.WebAuthnCredential) {
+				authenticator.SkipUserVerification = true
+			},
+			wantErr: true,
+		},
+		{
+			// A clone still reports the counter value of the original before the login
+			name:   "cloned authenticator",
+			format: webauthn.FormatPacked,
+			before: func(t *testing.T, service *authService, authenticator *webauthntest.Authenticator, credential *domain.WebAuthnCredential) {
+				options, _ := service.passkeys.BeginLogin()
+				if _, err := service.LoginWithPasskey(signAssertion(t, authenticator, options, credential.CredentialID), testRequest); err != nil {
+					t.Fatalf("LoginWithPasskey() error = %v", err)
+				}
+				authenticator.SetSignCount(credential.CredentialID, 0)
+			},
+			wantErr: true,
+		},
+		{
+			name:   "deleted passkey",
+			format: webauthn.FormatNone,
+			before: func(t *testing.T, service *authService, authenticator *webauthntest.Authenticator, credential *domain.WebAuthnCredential) {
+				if err := service.passkeys.Delete(domain.NewPrincipal(&domain.User{ID: 1}, nil), credential.ID); err != nil {
+					t.Fatalf("Delete() error = %v", err)
+				}
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, users, _ := newTestAuthService(t, config.AuthConfig{LoginMethods: config.LoginMethodBoth})
+			authenticator := newTestAuthenticator(t, testOrigin)
+			credential := registerPasskey(t, service.passkeys, authenticator, users.users[1], tt.format)
+			if tt.before != nil {
+				tt.before(t, service, authenticator, credential)
+			}
+
+			options, err := service.passkeys.BeginLogin()
+			if err != nil || len(options.AllowCredentials) != 0 || options.UserVerification != "required" {
+				t.Fatalf("BeginLogin() = %+v, %v, want a discoverable login requiring user verification", options, err)
+			}
+			assertion := signAssertion(t, authenticator, options, credential.CredentialID)
+			if tt.replay {
+				if _, err := service.LoginWithPasskey(assertion, testRequest); err != nil {
+					t.Fatalf("LoginWithPasskey() error = %v", err)
+				}
+			}
+
+			issued, err := service.LoginWithPasskey(assertion, testRequest)
+			if tt.wantErr {
+				assertUnauthorized(t, err)
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoginWithPasskey() error = %v", err)
+			}
+			if principal := authenticate(t, service, issued.AccessToken); principal.ID() != 1 {
+				t.Errorf("Authenticate() = user %d, want user 1", principal.ID())
+			}
+			if signCount := service.passkeys.(*webAuthnService).repo.(*mockWebAuthnRepository).credentials[credential.ID].SignCount; signCount != 1 {
+				t.Errorf("SignCount = %d, want 1", signCount)
+			}
+		})
+	}
+}
+
+func TestBeginPasskeyLogin(t *testing.T) {
+	tests := []struct {
+		name         string
+		loginMethods string
+		wantErr      bool
+	}{
+		{name: "password and passwordless login", loginMethods: config.LoginMethodBoth},
+		{name: "passwordless login only", loginMethods: config.LoginMethodPasswordless},
+		{name: "password login only", loginMethods: config.LoginMethodPassword, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, _, _ := newTestAuthService(t, config.AuthConfig{LoginMethods: tt.loginMethods})
+
+			_, err := service.passkeys.BeginLogin()
+			if tt.wantErr {
+				assertErrorType(t, "BeginLogin()", err, errors.Forbidden)
+			} else if err != nil {
+				t.Errorf("BeginLogin() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestPasskeyAsSecondFactor(t *testing.T) {
+	tests := []struct {
+		name string
+		// signWith is the user whose passkey answers the challenge
+		signWith uint
+		mfaToken string
+		spent    bool
+		wantErr  bool
+	}{
+		{name: "own passkey", signWith: 1},
+		{name: "passkey of another user", signWith: 2, wantErr: true},
+		{name: "unknown MFA token", signWith: 1, mfaToken: "unknown", wantErr: true},
+		// The challenge is spent by the first verification
+		{name: "spent challenge", signWith: 1, spent: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, users, _ := newTestAuthService(t, config.AuthConfig{})
+			users.users[2] = &domain.User{ID: 2, Email: "other@example.com", Name: "Other User"}
+			authenticator := newTestAuthenticator(t, testOrigin)
+			credentials := map[uint]*domain.WebAuthnCredential{
+				1: registerPasskey(t, service.passkeys, authenticator, users.users[1], webauthn.FormatNone),
+				2: registerPasskey(t, service.passkeys, authenticator, users.users[2], webauthn.FormatNone),
+			}
+
+			result, err := service.Login("test@example.com", "Password123!", testRequest)
+			if err != nil {
+				t.Fatalf("Login() error = %v", err)
+			}
+			if !result.MFARequired || len(result.MFAMethods) != 1 || result.MFAMethods[0] != domain.MFAMethodWebAuthn {
+				t.Fatalf("Login() = %+v, want an MFA challenge for a passkey", result)
+			}
+			options, err := service.BeginPasskeyMFA(result.MFAToken)
+			if err != nil || len(options.AllowCredentials) != 1 || options.AllowCredentials[0].ID != credentials[1].CredentialID {
+				t.Fatalf("BeginPasskeyMFA() = %+v, %v, want the registered credential", options, err)
+			}
+			if tt.spent {
+				if _, err := service.VerifyPasskeyMFA(result.MFAToken, signAssertion(t, authenticator, options, credentials[1].CredentialID), testRequest); err != nil {
+					t.Fatalf("VerifyPasskeyMFA() error = %v", err)
+				}
+				_, err = service.BeginPasskeyMFA(result.MFAToken)
+				assertUnauthorized(t, err)
+			}
+			mfaToken := result.MFAToken
+			if tt.mfaToken != "" {
+				mfaToken = tt.mfaToken
+			}
+
+			issued, err := service.VerifyPasskeyMFA(mfaToken, signAssertion(t, authenticator, options, credentials[tt.signWith].CredentialID), testRequest)
+			if tt.wantErr {
+				assertUnauthorized(t, err)
+				return
+			}
+			if err != nil {
+				t.Fatalf("VerifyPasskeyMFA() error = %v", err)
+			}
+			if principal := authenticate(t, service, issued.AccessToken); principal.ID() != 1 {
+				t.Errorf("Authenticate() = user %d, want user 1", principal.ID())
+			}
+		})
+	}
+}
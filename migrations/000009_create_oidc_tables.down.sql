@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS signing_keys;
+DROP TABLE IF EXISTS oauth_tokens;
+DROP TABLE IF EXISTS authorization_codes;
+DROP TABLE IF EXISTS oauth_clients;
@@ -0,0 +1,2 @@
+-- Users deprovisioned through SCIM keep their record but can no longer sign in
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deactivated_at TIMESTAMP;
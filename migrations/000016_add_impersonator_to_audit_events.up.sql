@@ -0,0 +1,4 @@
+-- The admin who acted as actor_id with an impersonation token
+ALTER TABLE audit_events ADD COLUMN IF NOT EXISTS impersonator_id INTEGER;
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_impersonator_id ON audit_events (impersonator_id);
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS email_changes (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    old_email VARCHAR(255) NOT NULL,
+    new_email VARCHAR(255) NOT NULL,
+    revert_token_hash VARCHAR(64) NOT NULL UNIQUE,
+    expires_at TIMESTAMP NOT NULL,
+    revert_expires_at TIMESTAMP NOT NULL,
+    confirmed_at TIMESTAMP,
+    reverted_at TIMESTAMP,
+    cancelled_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_changes_user_id ON email_changes (user_id);
+CREATE INDEX IF NOT EXISTS idx_email_changes_old_email ON email_changes (old_email);
+CREATE INDEX IF NOT EXISTS idx_email_changes_new_email ON email_changes (new_email);
@@ -0,0 +1,2 @@
+-- Advanced by every write; conditional updates on it implement If-Match
+ALTER TABLE users ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
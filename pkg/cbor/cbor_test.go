@@ -0,0 +1,103 @@
+package cbor
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestRoundTripMatchesRFC8949(t *testing.T) {
+	// Examples from RFC 8949 appendix A
+	tests := []struct {
+		hex   string
+		value interface{}
+	}{
+		{"00", int64(0)},
+		{"17", int64(23)},
+		{"1818", int64(24)},
+		{"1903e8", int64(1000)},
+		{"1a000f4240", int64(1000000)},
+		{"1b000000e8d4a51000", int64(1000000000000)},
+		{"20", int64(-1)},
+		{"3903e7", int64(-1000)},
+		{"40", []byte{}},
+		{"4401020304", []byte{1, 2, 3, 4}},
+		{"60", ""},
+		{"6449455446", "IETF"},
+		{"f4", false},
+		{"f5", true},
+		{"f6", nil},
+		{"83010203", []interface{}{int64(1), int64(2), int64(3)}},
+		{"a201020304", map[interface{}]interface{}{int64(1): int64(2), int64(3): int64(4)}},
+		{"a26161016162820203", map[interface{}]interface{}{"a": int64(1), "b": []interface{}{int64(2), int64(3)}}},
+	}
+
+	for _, tt := range tests {
+		data, _ := hex.DecodeString(tt.hex)
+		got, err := Unmarshal(data)
+		if err != nil {
+			t.Errorf("Unmarshal(%s) error = %v", tt.hex, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.value) {
+			t.Errorf("Unmarshal(%s) = %#v, want %#v", tt.hex, got, tt.value)
+		}
+
+		encoded, err := Marshal(tt.value)
+		if err != nil {
+			t.Errorf("Marshal(%#v) error = %v", tt.value, err)
+			continue
+		}
+		if !bytes.Equal(encoded, data) {
+			t.Errorf("Marshal(%#v) = %x, want %s", tt.value, encoded, tt.hex)
+		}
+	}
+}
+
+func TestMarshalSortsMapKeysCanonically(t *testing.T) {
+	// A COSE key: integer keys sort before longer encodings, positive before negative
+	encoded, err := Marshal(map[interface{}]interface{}{-2: []byte{1}, 3: -7, 1: 2, -1: 1})
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if got, want := hex.EncodeToString(encoded), "a4010203262001214101"; got != want {
+		t.Errorf("Marshal() = %s, want %s", got, want)
+	}
+}
+
+func TestDecodeReturnsTrailingBytes(t *testing.T) {
+	v, rest, err := Decode([]byte{0x01, 0xff, 0xfe})
+	if err != nil || v != int64(1) || !bytes.Equal(rest, []byte{0xff, 0xfe}) {
+		t.Errorf("Decode() = %v, %x, %v, want 1 followed by fffe", v, rest, err)
+	}
+	if _, err := Unmarshal([]byte{0x01, 0xff}); !errors.Is(err, ErrTrailingData) {
+		t.Errorf("Unmarshal() error = %v, want ErrTrailingData", err)
+	}
+}
+
+func TestUnmarshalRejectsMalformedData(t *testing.T) {
+	tests := map[string]string{
+		"truncated integer":   "19 03",
+		"truncated string":    "44 0102",
+		"huge array length":   "9b 00000000ffffffff",
+		"indefinite length":   "9f 01 ff",
+		"float":               "fb 3ff199999999999a",
+		"tag":                 "c1 1a514b67b0",
+		"invalid UTF-8":       "62 c328",
+		"array map key":       "a1 80 01",
+		"duplicate map key":   "a2 01 02 01 03",
+		"integer overflow":    "1b ffffffffffffffff",
+		"empty input":         "",
+		"nested too deep":     "8181818181818181818181818181818181 01",
+		"map missing a value": "a1 01",
+	}
+
+	for name, h := range tests {
+		data, _ := hex.DecodeString(string(bytes.ReplaceAll([]byte(h), []byte(" "), nil)))
+		if _, err := Unmarshal(data); err == nil {
+			t.Errorf("%s: Unmarshal(%s) error = nil, want an error", name, h)
+		}
+	}
+}
@@ -13,12 +13,20 @@ type Config struct {
 	Server   ServerConfig
 	Database DatabaseConfig
 	API      APIConfig
+	Auth     AuthConfig
+	Password PasswordConfig
+	Policy   PasswordPolicyConfig
+	Lockout  LockoutConfig
+	Mail     MailConfig
+	OIDC     OIDCConfig
+	SCIM     SCIMConfig
 }
 
 type ServerConfig struct {
 	Port            string
 	Host            string
 	ShutdownTimeout time.Duration // Time to wait for graceful shutdown
+	TrustedProxies  []string      // Proxies allowed to set X-Real-IP/X-Forwarded-For
 }
 
 type DatabaseConfig struct {
@@ -46,6 +54,128 @@ type APIConfig struct {
 	EnableSwagger     bool          // Enable Swagger documentation
 	EnablePrometheus  bool          // Enable Prometheus metrics
 	EnableHealthCheck bool          // Enable health check endpoint
+	RequireIfMatch    bool          // Reject writes to users without an If-Match header
+	CursorSecret      string        // Key signing pagination cursors; random per process when empty
+}
+
+type AuthConfig struct {
+	JWTAlgorithm      string        // Signing algorithm: HS256 or RS256
+	JWTSecret         string        // Shared secret used with HS256
+	JWTPrivateKeyPath string        // PEM encoded RSA private key used with RS256
+	JWTPublicKeyPath  string        // PEM encoded RSA public key used with RS256
+	JWTIssuer         string        // Value of the iss claim
+	AccessTokenTTL    time.Duration // Lifetime of issued access tokens
+	RefreshTokenTTL   time.Duration // Lifetime of each issued refresh token
+	PasswordResetTTL  time.Duration // Lifetime of password reset tokens
+	PasswordResetURL  string        // Page receiving the reset token as the "token" query parameter
+
+	EmailVerificationTTL time.Duration // Lifetime of email verification tokens
+	EmailVerificationURL string        // Page receiving the verification token as the "token" query parameter
+	RequireVerifiedEmail bool          // Reject logins of users who have not confirmed their email
+	EmailChangeRevertTTL time.Duration // How long the old address can undo a confirmed email change
+	EmailChangeRevertURL string        // Page receiving the revert token as the "token" query parameter
+
+	MFAIssuer        string        // Issuer shown by authenticator apps
+	MFAEncryptionKey string        // Base64 encoded 32 byte key encrypting TOTP secrets at rest
+	MFAChallengeTTL  time.Duration // Lifetime of the token exchanged for the second login step
+	MFAMaxAttempts   int           // Wrong codes accepted per MFA challenge
+	MFASkew          int           // Time steps accepted on either side of the current one
+	MFARecoveryCodes int           // Number of recovery codes issued on enrollment
+	MFARequiredRoles []string      // Roles granted only to sessions that passed a second factor
+
+	LoginMethods         string        // "password", "passwordless" or "both"
+	MagicLinkTTL         time.Duration // Lifetime of magic links and their codes
+	MagicLinkURL         string        // Page receiving the magic link token as the "token" query parameter
+	MagicLinkMaxAttempts int           // Wrong codes accepted per magic link
+	MagicLinkRateLimit   int           // Magic links sent per email address and per client IP each hour
+
+	WebAuthnRPID    string        // Relying party ID passkeys are scoped to, the site's domain
+	WebAuthnRPName  string        // Name authenticators show when registering a passkey
+	WebAuthnOrigins []string      // Web origins allowed to run WebAuthn ceremonies
+	WebAuthnTimeout time.Duration // Lifetime of registration and login challenges
+
+	ImpersonationTTL time.Duration // Lifetime of the access tokens admins get to act as another user
+}
+
+// Values of AuthConfig.LoginMethods
+const (
+	LoginMethodPassword     = "password"
+	LoginMethodPasswordless = "passwordless"
+	LoginMethodBoth         = "both"
+)
+
+// PasswordLogin reports whether logins with an email and password are accepted
+func (c AuthConfig) PasswordLogin() bool {
+	return c.LoginMethods != LoginMethodPasswordless
+}
+
+// PasswordlessLogin reports whether logins with an emailed magic link or code are accepted
+func (c AuthConfig) PasswordlessLogin() bool {
+	return c.LoginMethods == LoginMethodPasswordless || c.LoginMethods == LoginMethodBoth
+}
+
+type PasswordConfig struct {
+	Algorithm         string // Hash algorithm for new hashes: bcrypt or argon2id
+	BcryptCost        int    // bcrypt work factor
+	Argon2Memory      uint32 // Argon2id memory in KiB
+	Argon2Iterations  uint32 // Argon2id number of passes
+	Argon2Parallelism uint8  // Argon2id degree of parallelism
+	Argon2SaltLength  uint32 // Argon2id salt length in bytes
+	Argon2KeyLength   uint32 // Argon2id derived key length in bytes
+}
+
+type PasswordPolicyConfig struct {
+	MinLength        int    // Minimum password length
+	MaxLength        int    // Maximum password length in bytes
+	RequireUpper     bool   // Require at least one uppercase letter
+	RequireLower     bool   // Require at least one lowercase letter
+	RequireNumber    bool   // Require at least one number
+	RequireSpecial   bool   // Require at least one punctuation or symbol character
+	ForbidWhitespace bool   // Reject passwords containing whitespace
+	ForbiddenChars   string // Characters that may not appear in a password
+	MaxRepeated      int    // Maximum run of identical characters, 0 disables the check
+	DisallowUserInfo bool   // Reject passwords containing the user's email or name
+
+	BlocklistPath              string  // Plain text file of common passwords, one per line
+	BreachedHashesPath         string  // Pwned Passwords SHA-1 file or directory of prefix bucket files
+	BreachedMinCount           int     // Only block breached hashes seen at least this many times
+	BlocklistFalsePositiveRate float64 // Bloom filter false positive rate
+}
+
+type LockoutConfig struct {
+	AccountThreshold int           // Failed logins per account before it is locked, 0 disables
+	IPThreshold      int           // Failed logins per client IP before it is locked, 0 disables
+	LockDuration     time.Duration // How long a lock lasts
+	FailureWindow    time.Duration // Failures older than this are forgotten
+	BackoffBase      time.Duration // Delay after the first failed login of an account, doubled per failure
+	BackoffMax       time.Duration // Upper bound of the per-account delay
+}
+
+type MailConfig struct {
+	Driver       string // Delivery mechanism: log, file or smtp
+	From         string // Sender address
+	FileDir      string // Directory the file driver writes messages to
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string // PLAIN auth is used when set
+	SMTPPassword string
+}
+
+type OIDCConfig struct {
+	Issuer               string        // Public base URL of this server, used as the iss claim
+	LoginURL             string        // Page that signs the user in and completes the authorization request
+	KeyEncryptionKey     string        // Base64 encoded 32 byte key encrypting signing keys at rest
+	KeyRotationInterval  time.Duration // Age after which a new ID token signing key is generated
+	KeyRetention         time.Duration // How long a replaced signing key stays in the JWKS
+	AuthorizationCodeTTL time.Duration // Lifetime of authorization codes
+	AccessTokenTTL       time.Duration // Lifetime of access tokens issued to clients
+	RefreshTokenTTL      time.Duration // Lifetime of refresh tokens issued to clients
+	IDTokenTTL           time.Duration // Lifetime of ID tokens
+}
+
+type SCIMConfig struct {
+	BaseURL    string // Public URL of the SCIM endpoints, used in resource locations
+	MaxResults int    // Maximum number of users returned by a single query
 }
 
 // LoadConfig returns a new Config struct populated with values from environment variables
@@ -55,6 +185,9 @@ func LoadConfig() *Config {
 			Port:            getEnv("SERVER_PORT", "8080"),
 			Host:            getEnv("SERVER_HOST", "localhost"),
 			ShutdownTimeout: getEnvAsDuration("SERVER_SHUTDOWN_TIMEOUT", "5s"),
+			TrustedProxies: getEnvAsStringSlice("SERVER_TRUSTED_PROXIES", []string{
+				"127.0.0.1/32", "::1/128", "10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16",
+			}),
 		},
 		Database: DatabaseConfig{
 			Host:            getEnv("DB_HOST", "postgres"),
@@ -80,6 +213,104 @@ func LoadConfig() *Config {
 			EnableSwagger:    getEnvAsBool("API_ENABLE_SWAGGER", true),
 			EnablePrometheus: getEnvAsBool("API_ENABLE_PROMETHEUS", true),
 			EnableHealthCheck: getEnvAsBool("API_ENABLE_HEALTH_CHECK", true),
+			RequireIfMatch:    getEnvAsBool("API_REQUIRE_IF_MATCH", false),
+			CursorSecret:      getEnv("API_CURSOR_SECRET", ""),
+		},
+		Auth: AuthConfig{
+			JWTAlgorithm:      getEnv("AUTH_JWT_ALGORITHM", "HS256"),
+			JWTSecret:         getEnv("AUTH_JWT_SECRET", ""),
+			JWTPrivateKeyPath: getEnv("AUTH_JWT_PRIVATE_KEY_PATH", ""),
+			JWTPublicKeyPath:  getEnv("AUTH_JWT_PUBLIC_KEY_PATH", ""),
+			JWTIssuer:         getEnv("AUTH_JWT_ISSUER", "user-api"),
+			AccessTokenTTL:    getEnvAsDuration("AUTH_ACCESS_TOKEN_TTL", "15m"),
+			RefreshTokenTTL:   getEnvAsDuration("AUTH_REFRESH_TOKEN_TTL", "720h"),
+			PasswordResetTTL:  getEnvAsDuration("AUTH_PASSWORD_RESET_TTL", "30m"),
+			PasswordResetURL:  getEnv("AUTH_PASSWORD_RESET_URL", "http://localhost:8080/reset-password"),
+
+			EmailVerificationTTL: getEnvAsDuration("AUTH_EMAIL_VERIFICATION_TTL", "24h"),
+			EmailVerificationURL: getEnv("AUTH_EMAIL_VERIFICATION_URL", "http://localhost:8080/api/auth/verify-email"),
+			RequireVerifiedEmail: getEnvAsBool("AUTH_REQUIRE_VERIFIED_EMAIL", false),
+			EmailChangeRevertTTL: getEnvAsDuration("AUTH_EMAIL_CHANGE_REVERT_TTL", "168h"),
+			EmailChangeRevertURL: getEnv("AUTH_EMAIL_CHANGE_REVERT_URL", "http://localhost:8080/api/auth/email-change/revert"),
+
+			MFAIssuer:        getEnv("AUTH_MFA_ISSUER", "User API"),
+			MFAEncryptionKey: getEnv("AUTH_MFA_ENCRYPTION_KEY", ""),
+			MFAChallengeTTL:  getEnvAsDuration("AUTH_MFA_CHALLENGE_TTL", "5m"),
+			MFAMaxAttempts:   getEnvAsInt("AUTH_MFA_MAX_ATTEMPTS", 5),
+			MFASkew:          getEnvAsInt("AUTH_MFA_SKEW", 1),
+			MFARecoveryCodes: getEnvAsInt("AUTH_MFA_RECOVERY_CODES", 10),
+			MFARequiredRoles: getEnvAsStringSlice("AUTH_MFA_REQUIRED_ROLES", []string{"admin"}),
+
+			LoginMethods:         getEnv("AUTH_LOGIN_METHODS", LoginMethodPassword),
+			MagicLinkTTL:         getEnvAsDuration("AUTH_MAGIC_LINK_TTL", "10m"),
+			MagicLinkURL:         getEnv("AUTH_MAGIC_LINK_URL", "http://localhost:8080/login/magic"),
+			MagicLinkMaxAttempts: getEnvAsInt("AUTH_MAGIC_LINK_MAX_ATTEMPTS", 5),
+			MagicLinkRateLimit:   getEnvAsInt("AUTH_MAGIC_LINK_RATE_LIMIT", 5),
+
+			WebAuthnRPID:    getEnv("AUTH_WEBAUTHN_RP_ID", "localhost"),
+			WebAuthnRPName:  getEnv("AUTH_WEBAUTHN_RP_NAME", "User API"),
+			WebAuthnOrigins: getEnvAsStringSlice("AUTH_WEBAUTHN_ORIGINS", []string{"http://localhost:8080"}),
+			WebAuthnTimeout: getEnvAsDuration("AUTH_WEBAUTHN_TIMEOUT", "5m"),
+
+			ImpersonationTTL: getEnvAsDuration("AUTH_IMPERSONATION_TTL", "15m"),
+		},
+		Password: PasswordConfig{
+			Algorithm:         getEnv("PASSWORD_HASH_ALGORITHM", "bcrypt"),
+			BcryptCost:        getEnvAsInt("PASSWORD_BCRYPT_COST", 10),
+			Argon2Memory:      uint32(getEnvAsInt("PASSWORD_ARGON2_MEMORY", 64*1024)),
+			Argon2Iterations:  uint32(getEnvAsInt("PASSWORD_ARGON2_ITERATIONS", 3)),
+			Argon2Parallelism: uint8(getEnvAsInt("PASSWORD_ARGON2_PARALLELISM", 2)),
+			Argon2SaltLength:  uint32(getEnvAsInt("PASSWORD_ARGON2_SALT_LENGTH", 16)),
+			Argon2KeyLength:   uint32(getEnvAsInt("PASSWORD_ARGON2_KEY_LENGTH", 32)),
+		},
+		Policy: PasswordPolicyConfig{
+			MinLength:        getEnvAsInt("PASSWORD_MIN_LENGTH", 8),
+			MaxLength:        getEnvAsInt("PASSWORD_MAX_LENGTH", 72),
+			RequireUpper:     getEnvAsBool("PASSWORD_REQUIRE_UPPER", true),
+			RequireLower:     getEnvAsBool("PASSWORD_REQUIRE_LOWER", true),
+			RequireNumber:    getEnvAsBool("PASSWORD_REQUIRE_NUMBER", true),
+			RequireSpecial:   getEnvAsBool("PASSWORD_REQUIRE_SPECIAL", true),
+			ForbidWhitespace: getEnvAsBool("PASSWORD_FORBID_WHITESPACE", false),
+			ForbiddenChars:   getEnv("PASSWORD_FORBIDDEN_CHARS", ""),
+			MaxRepeated:      getEnvAsInt("PASSWORD_MAX_REPEATED", 3),
+			DisallowUserInfo: getEnvAsBool("PASSWORD_DISALLOW_USER_INFO", true),
+
+			BlocklistPath:              getEnv("PASSWORD_BLOCKLIST_PATH", ""),
+			BreachedHashesPath:         getEnv("PASSWORD_BREACHED_HASHES_PATH", ""),
+			BreachedMinCount:           getEnvAsInt("PASSWORD_BREACHED_MIN_COUNT", 1),
+			BlocklistFalsePositiveRate: getEnvAsFloat("PASSWORD_BLOCKLIST_FALSE_POSITIVE_RATE", 0.001),
+		},
+		Lockout: LockoutConfig{
+			AccountThreshold: getEnvAsInt("LOGIN_LOCKOUT_ACCOUNT_THRESHOLD", 5),
+			IPThreshold:      getEnvAsInt("LOGIN_LOCKOUT_IP_THRESHOLD", 50),
+			LockDuration:     getEnvAsDuration("LOGIN_LOCKOUT_DURATION", "15m"),
+			FailureWindow:    getEnvAsDuration("LOGIN_LOCKOUT_WINDOW", "1h"),
+			BackoffBase:      getEnvAsDuration("LOGIN_BACKOFF_BASE", "1s"),
+			BackoffMax:       getEnvAsDuration("LOGIN_BACKOFF_MAX", "1m"),
+		},
+		Mail: MailConfig{
+			Driver:       getEnv("MAIL_DRIVER", "log"),
+			From:         getEnv("MAIL_FROM", "no-reply@localhost"),
+			FileDir:      getEnv("MAIL_FILE_DIR", ""),
+			SMTPHost:     getEnv("MAIL_SMTP_HOST", ""),
+			SMTPPort:     getEnv("MAIL_SMTP_PORT", "587"),
+			SMTPUsername: getEnv("MAIL_SMTP_USERNAME", ""),
+			SMTPPassword: getEnv("MAIL_SMTP_PASSWORD", ""),
+		},
+		OIDC: OIDCConfig{
+			Issuer:               getEnv("OIDC_ISSUER", "http://localhost:8080"),
+			LoginURL:             getEnv("OIDC_LOGIN_URL", "http://localhost:8080/login"),
+			KeyEncryptionKey:     getEnv("OIDC_KEY_ENCRYPTION_KEY", ""),
+			KeyRotationInterval:  getEnvAsDuration("OIDC_KEY_ROTATION_INTERVAL", "720h"),
+			KeyRetention:         getEnvAsDuration("OIDC_KEY_RETENTION", "168h"),
+			AuthorizationCodeTTL: getEnvAsDuration("OIDC_AUTHORIZATION_CODE_TTL", "1m"),
+			AccessTokenTTL:       getEnvAsDuration("OIDC_ACCESS_TOKEN_TTL", "1h"),
+			RefreshTokenTTL:      getEnvAsDuration("OIDC_REFRESH_TOKEN_TTL", "720h"),
+			IDTokenTTL:           getEnvAsDuration("OIDC_ID_TOKEN_TTL", "1h"),
+		},
+		SCIM: SCIMConfig{
+			BaseURL:    getEnv("SCIM_BASE_URL", "http://localhost:8080/scim/v2"),
+			MaxResults: getEnvAsInt("SCIM_MAX_RESULTS", 200),
 		},
 	}
 }
@@ -113,6 +344,15 @@ func getEnvAsInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+	if value, exists := os.LookupEnv(key); exists {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+	}
+	return defaultValue
+}
+
 func getEnvAsBool(key string, defaultValue bool) bool {
 	if value, exists := os.LookupEnv(key); exists {
 		if boolValue, err := strconv.ParseBool(value); err == nil {
@@ -0,0 +1,58 @@
+package cursor
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testPayload struct {
+	Key   []string `json:"k"`
+	Query string   `json:"q"`
+}
+
+func TestEncodeDecode(t *testing.T) {
+	codec := NewCodec("secret")
+	want := testPayload{Key: []string{"2024-01-01T00:00:00Z", "42"}, Query: "abc"}
+
+	cursor, err := codec.Encode(want)
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	if strings.ContainsAny(cursor, "+/=") {
+		t.Errorf("Encode() = %q, want URL-safe characters only", cursor)
+	}
+
+	var got testPayload
+	if err := codec.Decode(cursor, &got); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Decode() = %+v, want %+v", got, want)
+	}
+}
+
+func TestDecodeRejectsForgedCursors(t *testing.T) {
+	codec := NewCodec("secret")
+	cursor, _ := codec.Encode(testPayload{Key: []string{"42"}})
+	other, _ := NewCodec("other secret").Encode(testPayload{Key: []string{"42"}})
+	data, mac, _ := strings.Cut(cursor, ".")
+	tampered, _ := codec.Encode(testPayload{Key: []string{"43"}})
+	tamperedData, _, _ := strings.Cut(tampered, ".")
+
+	for _, forged := range []string{
+		"",
+		"not a cursor",
+		data,
+		data + ".",
+		data + "." + mac + "x",
+		tamperedData + "." + mac,
+		other,
+	} {
+		var payload testPayload
+		if err := codec.Decode(forged, &payload); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("Decode(%q) error = %v, want ErrInvalidCursor", forged, err)
+		}
+	}
+}
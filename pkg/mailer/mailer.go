@@ -0,0 +1,184 @@
+package mailer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"UserRESTfulApi/pkg/config"
+)
+
+const (
+	DriverLog  = "log"
+	DriverFile = "file"
+	DriverSMTP = "smtp"
+)
+
+// Message is a plain text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers email messages
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// New creates the mailer selected in the mail configuration
+func New(cfg config.MailConfig) (Mailer, error) {
+	switch cfg.Driver {
+	case DriverLog:
+		return NewLogMailer(cfg.From), nil
+	case DriverFile:
+		if cfg.FileDir == "" {
+			return nil, fmt.Errorf("MAIL_FILE_DIR is required for the file mail driver")
+		}
+		return NewFileMailer(cfg.From, cfg.FileDir)
+	case DriverSMTP:
+		if cfg.SMTPHost == "" {
+			return nil, fmt.Errorf("MAIL_SMTP_HOST is required for the smtp mail driver")
+		}
+		return NewSMTPMailer(cfg), nil
+	default:
+		return nil, fmt.Errorf("unsupported mail driver: %s", cfg.Driver)
+	}
+}
+
+// LogMailer writes messages to the application log. It is meant for local
+// development; the log will contain any secrets the messages carry.
+type LogMailer struct {
+	from string
+}
+
+// NewLogMailer creates a mailer that logs every message
+func NewLogMailer(from string) *LogMailer {
+	return &LogMailer{from: from}
+}
+
+// Send logs the message
+func (m *LogMailer) Send(msg Message) error {
+	log.Printf("Mail from %s to %s: %s\n%s", m.from, msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// FileMailer writes every message as an RFC 5322 file into a directory, so
+// tests and local setups can read the mail that would have been sent
+type FileMailer struct {
+	from string
+	dir  string
+	mu   sync.Mutex
+}
+
+// NewFileMailer creates a mailer writing messages into dir, creating it if needed
+func NewFileMailer(from, dir string) (*FileMailer, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create mail directory: %v", err)
+	}
+	return &FileMailer{from: from, dir: dir}, nil
+}
+
+// Send writes the message to a new file named after the send time and recipient
+func (m *FileMailer) Send(msg Message) error {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name := fmt.Sprintf("%s_%s_%s.eml",
+		time.Now().UTC().Format("20060102T150405.000000000"),
+		sanitizeFileName(msg.To),
+		hex.EncodeToString(suffix),
+	)
+	return os.WriteFile(filepath.Join(m.dir, name), format(m.from, msg), 0o600)
+}
+
+// Messages returns the files written for the recipient, oldest first
+func (m *FileMailer) Messages(to string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entries, err := os.ReadDir(m.dir)
+	if err != nil {
+		return nil, err
+	}
+	var messages []string
+	marker := "_" + sanitizeFileName(to) + "_"
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.Contains(entry.Name(), marker) {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(m.dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, string(content))
+	}
+	return messages, nil
+}
+
+// SMTPMailer delivers messages through an SMTP relay
+type SMTPMailer struct {
+	from string
+	addr string
+	auth smtp.Auth
+}
+
+// NewSMTPMailer creates a mailer sending through the configured SMTP server.
+// PLAIN authentication is used when a username is configured.
+func NewSMTPMailer(cfg config.MailConfig) *SMTPMailer {
+	m := &SMTPMailer{
+		from: cfg.From,
+		addr: net.JoinHostPort(cfg.SMTPHost, cfg.SMTPPort),
+	}
+	if cfg.SMTPUsername != "" {
+		m.auth = smtp.PlainAuth("", cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPHost)
+	}
+	return m
+}
+
+// Send delivers the message
+func (m *SMTPMailer) Send(msg Message) error {
+	return smtp.SendMail(m.addr, m.auth, m.from, []string{msg.To}, format(m.from, msg))
+}
+
+// format renders the message with the headers needed for delivery
+func format(from string, msg Message) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", stripNewlines(from))
+	fmt.Fprintf(&buf, "To: %s\r\n", stripNewlines(msg.To))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", stripNewlines(msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	buf.WriteString("\r\n")
+	buf.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return buf.Bytes()
+}
+
+// stripNewlines prevents header injection through user supplied values
+func stripNewlines(value string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(value)
+}
+
+func sanitizeFileName(value string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '@' || r == '.' || r == '-' {
+			return r
+		}
+		return '_'
+	}, value)
+}
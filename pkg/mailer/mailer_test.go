@@ -0,0 +1,63 @@
+package mailer
+
+import (
+	"strings"
+	"testing"
+
+	"UserRESTfulApi/pkg/config"
+)
+
+func TestFileMailer(t *testing.T) {
+	m, err := NewFileMailer("no-reply@example.com", t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileMailer() error = %v", err)
+	}
+
+	if err := m.Send(Message{To: "jane@example.com", Subject: "Hello", Body: "first"}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if err := m.Send(Message{To: "jane@example.com", Subject: "Hello\r\nBcc: evil@example.com", Body: "second"}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if err := m.Send(Message{To: "john@example.com", Subject: "Hello", Body: "other"}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	messages, err := m.Messages("jane@example.com")
+	if err != nil {
+		t.Fatalf("Messages() error = %v", err)
+	}
+	if len(messages) != 2 {
+		t.Fatalf("Messages() returned %d messages, want 2", len(messages))
+	}
+	if !strings.Contains(messages[0], "To: jane@example.com\r\n") || !strings.HasSuffix(messages[0], "\r\n\r\nfirst") {
+		t.Errorf("first message = %q", messages[0])
+	}
+	if strings.Contains(messages[1], "\r\nBcc:") {
+		t.Errorf("second message allowed header injection: %q", messages[1])
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.MailConfig
+		wantErr bool
+	}{
+		{name: "log", cfg: config.MailConfig{Driver: DriverLog}},
+		{name: "file", cfg: config.MailConfig{Driver: DriverFile, FileDir: t.TempDir()}},
+		{name: "file without directory", cfg: config.MailConfig{Driver: DriverFile}, wantErr: true},
+		{name: "smtp", cfg: config.MailConfig{Driver: DriverSMTP, SMTPHost: "localhost", SMTPPort: "25"}},
+		{name: "smtp without host", cfg: config.MailConfig{Driver: DriverSMTP}, wantErr: true},
+		{name: "unknown driver", cfg: config.MailConfig{Driver: "pigeon"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
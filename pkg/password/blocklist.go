@@ -0,0 +1,271 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"UserRESTfulApi/pkg/config"
+)
+
+// hibpPrefixLength is the number of hex characters of the SHA-1 hash used to
+// name a bucket in the k-anonymity (Have I Been Pwned range API) format
+const hibpPrefixLength = 5
+
+// Blocklist is a bloom filter of SHA-1 password hashes built from locally
+// stored corpora of common and breached passwords. Lookups never touch the
+// network or the source files. A small configurable false positive rate means
+// a handful of strong passwords may be rejected, but a listed password is
+// never accepted.
+type Blocklist struct {
+	bits   []uint64
+	size   uint64 // number of bits
+	hashes uint64 // number of hash functions
+	count  int
+}
+
+// NewBlocklist creates an empty blocklist sized for the expected number of
+// entries at the given false positive rate
+func NewBlocklist(expected int, falsePositiveRate float64) *Blocklist {
+	if expected < 1 {
+		expected = 1
+	}
+	if falsePositiveRate <= 0 || falsePositiveRate >= 1 {
+		falsePositiveRate = 0.001
+	}
+
+	size := uint64(math.Ceil(-float64(expected) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2)))
+	if size < 64 {
+		size = 64
+	}
+	hashes := uint64(math.Round(float64(size) / float64(expected) * math.Ln2))
+	if hashes < 1 {
+		hashes = 1
+	}
+
+	return &Blocklist{
+		bits:   make([]uint64, (size+63)/64),
+		size:   size,
+		hashes: hashes,
+	}
+}
+
+// LoadBlocklist builds a blocklist from the files configured in the password
+// policy. It returns nil when no corpus is configured.
+//
+// BlocklistPath points to a plain text file with one password per line.
+// BreachedHashesPath points either to a file of "SHA1:COUNT" lines (the
+// downloadable Pwned Passwords format) or to a directory of bucket files
+// named after a 5 character hash prefix (e.g. "21BD1" or "21BD1.txt") holding
+// "SUFFIX:COUNT" lines as returned by the range API. Lines are told apart by
+// their length, so a bucket-named file of full hashes loads correctly.
+func LoadBlocklist(cfg config.PasswordPolicyConfig) (*Blocklist, error) {
+	if cfg.BlocklistPath == "" && cfg.BreachedHashesPath == "" {
+		return nil, nil
+	}
+
+	var hibpFiles []string
+	if cfg.BreachedHashesPath != "" {
+		files, err := hibpSourceFiles(cfg.BreachedHashesPath)
+		if err != nil {
+			return nil, err
+		}
+		hibpFiles = files
+	}
+
+	// Count entries first so the filter can be sized for the requested false positive rate
+	expected := 0
+	if cfg.BlocklistPath != "" {
+		n, err := countLines(cfg.BlocklistPath)
+		if err != nil {
+			return nil, err
+		}
+		expected += n
+	}
+	for _, file := range hibpFiles {
+		n, err := countLines(file)
+		if err != nil {
+			return nil, err
+		}
+		expected += n
+	}
+
+	blocklist := NewBlocklist(expected, cfg.BlocklistFalsePositiveRate)
+
+	if cfg.BlocklistPath != "" {
+		if err := withFile(cfg.BlocklistPath, blocklist.LoadPlainList); err != nil {
+			return nil, fmt.Errorf("failed to load password blocklist %s: %v", cfg.BlocklistPath, err)
+		}
+	}
+	for _, file := range hibpFiles {
+		prefix := bucketPrefix(file)
+		err := withFile(file, func(r io.Reader) error {
+			return blocklist.LoadHIBP(r, prefix, cfg.BreachedMinCount)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to load breached password hashes %s: %v", file, err)
+		}
+	}
+
+	return blocklist, nil
+}
+
+// Add adds a plaintext password to the blocklist
+func (b *Blocklist) Add(password string) {
+	b.AddHash(sha1.Sum([]byte(password)))
+}
+
+// AddHash adds the SHA-1 digest of a password to the blocklist
+func (b *Blocklist) AddHash(digest [sha1.Size]byte) {
+	h1, h2 := splitDigest(digest)
+	for i := uint64(0); i < b.hashes; i++ {
+		bit := (h1 + i*h2) % b.size
+		b.bits[bit/64] |= 1 << (bit % 64)
+	}
+	b.count++
+}
+
+// Contains reports whether the password is (probably) on the blocklist
+func (b *Blocklist) Contains(password string) bool {
+	h1, h2 := splitDigest(sha1.Sum([]byte(password)))
+	for i := uint64(0); i < b.hashes; i++ {
+		bit := (h1 + i*h2) % b.size
+		if b.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Len returns the number of entries added to the blocklist
+func (b *Blocklist) Len() int {
+	return b.count
+}
+
+// LoadPlainList adds one password per line. Blank lines and lines starting with # are skipped.
+func (b *Blocklist) LoadPlainList(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		b.Add(line)
+	}
+	return scanner.Err()
+}
+
+// LoadHIBP adds hashes in the Pwned Passwords format. Each line holds either
+// a full 40 character hash or the 35 character suffix of a hash starting with
+// prefix, which suffixes require. Hashes seen fewer than minCount times are skipped.
+func (b *Blocklist) LoadHIBP(r io.Reader, prefix string, minCount int) error {
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		hash, countValue, hasCount := strings.Cut(line, ":")
+		if hasCount && minCount > 1 {
+			count, err := strconv.Atoi(strings.TrimSpace(countValue))
+			if err != nil {
+				return fmt.Errorf("line %d: invalid count %q", lineNumber, countValue)
+			}
+			if count < minCount {
+				continue
+			}
+		}
+
+		hash = strings.TrimSpace(hash)
+		if len(hash) == 2*sha1.Size-hibpPrefixLength {
+			if prefix == "" {
+				return fmt.Errorf("line %d: hash suffix %q outside a prefix bucket file", lineNumber, hash)
+			}
+			hash = prefix + hash
+		}
+		decoded, err := hex.DecodeString(hash)
+		if err != nil || len(decoded) != sha1.Size {
+			return fmt.Errorf("line %d: invalid SHA-1 hash %q", lineNumber, hash)
+		}
+
+		var digest [sha1.Size]byte
+		copy(digest[:], decoded)
+		b.AddHash(digest)
+	}
+	return scanner.Err()
+}
+
+// splitDigest derives the two base hashes used for double hashing from a SHA-1 digest
+func splitDigest(digest [sha1.Size]byte) (uint64, uint64) {
+	return binary.BigEndian.Uint64(digest[0:8]), binary.BigEndian.Uint64(digest[8:16]) | 1
+}
+
+// hibpSourceFiles returns the files to load from a HIBP file or bucket directory
+func hibpSourceFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, entry := range entries {
+		name := filepath.Join(path, entry.Name())
+		if !entry.IsDir() && bucketPrefix(name) != "" {
+			files = append(files, name)
+		}
+	}
+	return files, nil
+}
+
+// bucketPrefix returns the hash prefix encoded in a bucket file name, or "" if
+// the name is not a bucket. Only hash suffixes in the file are completed with it.
+func bucketPrefix(path string) string {
+	base := filepath.Base(path)
+	base = strings.TrimSuffix(base, filepath.Ext(base))
+	if len(base) != hibpPrefixLength {
+		return ""
+	}
+	if _, err := hex.DecodeString(base + "0"); err != nil {
+		return ""
+	}
+	return strings.ToUpper(base)
+}
+
+func countLines(path string) (int, error) {
+	count := 0
+	err := withFile(path, func(r io.Reader) error {
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			count++
+		}
+		return scanner.Err()
+	})
+	return count, err
+}
+
+func withFile(path string, fn func(io.Reader) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return fn(bufio.NewReaderSize(f, 64*1024))
+}
@@ -0,0 +1,160 @@
+package password
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"UserRESTfulApi/pkg/config"
+)
+
+func sha1Hex(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+func TestBlocklistSources(t *testing.T) {
+	dir := t.TempDir()
+
+	plainPath := filepath.Join(dir, "common.txt")
+	os.WriteFile(plainPath, []byte("# common passwords\nPassword1!\r\nQwerty123!\n\n"), 0644)
+
+	fullPath := filepath.Join(dir, "pwned.txt")
+	os.WriteFile(fullPath, []byte(fmt.Sprintf("%s:120\n%s:1\n", sha1Hex("Breached1!"), sha1Hex("RarelySeen1!"))), 0644)
+
+	bucketDir := filepath.Join(dir, "buckets")
+	os.Mkdir(bucketDir, 0755)
+	bucketHash := sha1Hex("Bucketed1!")
+	os.WriteFile(filepath.Join(bucketDir, bucketHash[:5]+".txt"), []byte(bucketHash[5:]+":42\r\n"), 0644)
+	os.WriteFile(filepath.Join(bucketDir, "README"), []byte("not a bucket"), 0644)
+
+	// Full hashes in a file whose name happens to be a hash prefix
+	bucketNamedPath := filepath.Join(dir, "ABCDE.txt")
+	os.WriteFile(bucketNamedPath, []byte(sha1Hex("Misnamed1!")+":7\n"), 0644)
+	mixedDir := filepath.Join(dir, "mixed")
+	os.Mkdir(mixedDir, 0755)
+	os.WriteFile(filepath.Join(mixedDir, bucketHash[:5]+".txt"), []byte(bucketHash[5:]+":42\n"+sha1Hex("Misnamed1!")+":7\n"), 0644)
+
+	tests := []struct {
+		name     string
+		cfg      config.PasswordPolicyConfig
+		blocked  []string
+		accepted []string
+	}{
+		{
+			name:     "plain list",
+			cfg:      config.PasswordPolicyConfig{BlocklistPath: plainPath},
+			blocked:  []string{"Password1!", "Qwerty123!"},
+			accepted: []string{"# common passwords", "Str0ng&Unique!"},
+		},
+		{
+			name:     "full hash file",
+			cfg:      config.PasswordPolicyConfig{BreachedHashesPath: fullPath},
+			blocked:  []string{"Breached1!", "RarelySeen1!"},
+			accepted: []string{"Str0ng&Unique!"},
+		},
+		{
+			name:     "full hash file with minimum count",
+			cfg:      config.PasswordPolicyConfig{BreachedHashesPath: fullPath, BreachedMinCount: 10},
+			blocked:  []string{"Breached1!"},
+			accepted: []string{"RarelySeen1!"},
+		},
+		{
+			name:     "prefix bucket directory",
+			cfg:      config.PasswordPolicyConfig{BreachedHashesPath: bucketDir},
+			blocked:  []string{"Bucketed1!"},
+			accepted: []string{"Str0ng&Unique!"},
+		},
+		{
+			name:     "full hash file named like a bucket",
+			cfg:      config.PasswordPolicyConfig{BreachedHashesPath: bucketNamedPath},
+			blocked:  []string{"Misnamed1!"},
+			accepted: []string{"Str0ng&Unique!"},
+		},
+		{
+			name:     "bucket directory with full hashes",
+			cfg:      config.PasswordPolicyConfig{BreachedHashesPath: mixedDir},
+			blocked:  []string{"Bucketed1!", "Misnamed1!"},
+			accepted: []string{"Str0ng&Unique!"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blocklist, err := LoadBlocklist(tt.cfg)
+			if err != nil {
+				t.Fatalf("LoadBlocklist() error = %v", err)
+			}
+			for _, password := range tt.blocked {
+				if !blocklist.Contains(password) {
+					t.Errorf("Contains(%q) = false, want true", password)
+				}
+			}
+			for _, password := range tt.accepted {
+				if blocklist.Contains(password) {
+					t.Errorf("Contains(%q) = true, want false", password)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadBlocklistErrors(t *testing.T) {
+	blocklist, err := LoadBlocklist(config.PasswordPolicyConfig{})
+	if err != nil || blocklist != nil {
+		t.Errorf("LoadBlocklist() without sources = %v, %v, want nil, nil", blocklist, err)
+	}
+
+	badPath := filepath.Join(t.TempDir(), "bad.txt")
+	os.WriteFile(badPath, []byte("not-a-hash:3\n"), 0644)
+	if _, err := LoadBlocklist(config.PasswordPolicyConfig{BreachedHashesPath: badPath}); err == nil {
+		t.Error("LoadBlocklist() expected error for malformed hash")
+	}
+
+	suffixPath := filepath.Join(t.TempDir(), "suffixes.txt")
+	os.WriteFile(suffixPath, []byte(sha1Hex("Bucketed1!")[5:]+":3\n"), 0644)
+	if _, err := LoadBlocklist(config.PasswordPolicyConfig{BreachedHashesPath: suffixPath}); err == nil {
+		t.Error("LoadBlocklist() expected error for hash suffixes outside a bucket file")
+	}
+
+	if _, err := LoadBlocklist(config.PasswordPolicyConfig{BlocklistPath: "/does/not/exist"}); err == nil {
+		t.Error("LoadBlocklist() expected error for missing file")
+	}
+}
+
+func TestBlocklistFalsePositiveRate(t *testing.T) {
+	blocklist := NewBlocklist(10000, 0.01)
+	for i := 0; i < 10000; i++ {
+		blocklist.Add(fmt.Sprintf("listed-%d", i))
+	}
+
+	falsePositives := 0
+	for i := 0; i < 10000; i++ {
+		if blocklist.Contains(fmt.Sprintf("unlisted-%d", i)) {
+			falsePositives++
+		}
+	}
+	if falsePositives > 300 {
+		t.Errorf("false positives = %d of 10000, want about 100", falsePositives)
+	}
+}
+
+func TestPolicyRejectsBlockedPassword(t *testing.T) {
+	policy := DefaultPolicy()
+	policy.Blocklist = NewBlocklist(1, 0.001)
+	policy.Blocklist.Add("Password1!")
+
+	err := policy.Validate("Password1!")
+	policyErr, ok := err.(*PolicyError)
+	if !ok || len(policyErr.Violations) != 1 || policyErr.Violations[0].Code != ViolationBreached {
+		t.Errorf("Validate() error = %v, want single %s violation", err, ViolationBreached)
+	}
+
+	if err := policy.Validate("Str0ng&Unique!"); err != nil {
+		t.Errorf("Validate() error = %v, want nil", err)
+	}
+}
@@ -0,0 +1,111 @@
+package password
+
+import (
+	"strings"
+	"testing"
+
+	"UserRESTfulApi/pkg/config"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var testArgon2idParams = Argon2idParams{
+	Memory:      1024,
+	Iterations:  1,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+func TestHashers(t *testing.T) {
+	tests := []struct {
+		name   string
+		hasher Hasher
+		prefix string
+	}{
+		{
+			name:   "bcrypt",
+			hasher: NewBcryptHasher(bcrypt.MinCost),
+			prefix: "$2a$04$",
+		},
+		{
+			name:   "argon2id",
+			hasher: NewArgon2idHasher(testArgon2idParams),
+			prefix: "$argon2id$v=19$m=1024,t=1,p=1$",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded, err := tt.hasher.Hash("Test123!@#")
+			if err != nil {
+				t.Fatalf("Hash() error = %v", err)
+			}
+			if !strings.HasPrefix(encoded, tt.prefix) {
+				t.Errorf("Hash() = %q, want prefix %q", encoded, tt.prefix)
+			}
+			if !tt.hasher.Identifies(encoded) {
+				t.Error("Identifies() = false for own hash")
+			}
+			if !tt.hasher.Verify("Test123!@#", encoded) {
+				t.Error("Verify() failed to verify valid password")
+			}
+			if tt.hasher.Verify("wrongTest123!@#", encoded) {
+				t.Error("Verify() verified invalid password")
+			}
+			if tt.hasher.NeedsRehash(encoded) {
+				t.Error("NeedsRehash() = true for hash with current parameters")
+			}
+		})
+	}
+}
+
+func TestNeedsRehashOnParameterChange(t *testing.T) {
+	bcryptHash, _ := NewBcryptHasher(bcrypt.MinCost).Hash("Test123!@#")
+	if !NewBcryptHasher(bcrypt.MinCost + 1).NeedsRehash(bcryptHash) {
+		t.Error("NeedsRehash() = false after bcrypt cost change")
+	}
+
+	argon2Hash, _ := NewArgon2idHasher(testArgon2idParams).Hash("Test123!@#")
+	stronger := testArgon2idParams
+	stronger.Iterations = 2
+	if !NewArgon2idHasher(stronger).NeedsRehash(argon2Hash) {
+		t.Error("NeedsRehash() = false after argon2id parameter change")
+	}
+}
+
+func TestNewHasherMigratesBetweenAlgorithms(t *testing.T) {
+	cfg := config.PasswordConfig{
+		Algorithm:         AlgorithmArgon2id,
+		BcryptCost:        bcrypt.MinCost,
+		Argon2Memory:      testArgon2idParams.Memory,
+		Argon2Iterations:  testArgon2idParams.Iterations,
+		Argon2Parallelism: testArgon2idParams.Parallelism,
+		Argon2SaltLength:  testArgon2idParams.SaltLength,
+		Argon2KeyLength:   testArgon2idParams.KeyLength,
+	}
+	hasher, err := NewHasher(cfg)
+	if err != nil {
+		t.Fatalf("NewHasher() error = %v", err)
+	}
+
+	legacy, _ := NewBcryptHasher(bcrypt.MinCost).Hash("Test123!@#")
+	if !hasher.Verify("Test123!@#", legacy) {
+		t.Error("Verify() failed to verify legacy bcrypt hash")
+	}
+	if !hasher.NeedsRehash(legacy) {
+		t.Error("NeedsRehash() = false for legacy bcrypt hash")
+	}
+
+	current, _ := hasher.Hash("Test123!@#")
+	if !strings.HasPrefix(current, "$argon2id$") {
+		t.Errorf("Hash() = %q, want argon2id hash", current)
+	}
+	if hasher.NeedsRehash(current) {
+		t.Error("NeedsRehash() = true for current hash")
+	}
+
+	if _, err := NewHasher(config.PasswordConfig{Algorithm: "md5"}); err == nil {
+		t.Error("NewHasher() expected error for unsupported algorithm")
+	}
+}
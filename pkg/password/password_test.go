@@ -4,59 +4,6 @@ import (
 	"testing"
 )
 
-func TestValidate(t *testing.T) {
-	tests := []struct {
-		name     string
-		password string
-		wantErr  error
-	}{
-		{
-			name:     "valid password",
-			password: "Test123!@#",
-			wantErr:  nil,
-		},
-		{
-			name:     "password too short",
-			password: "Test1!",
-			wantErr:  ErrPasswordTooShort,
-		},
-		{
-			name:     "password too long",
-			password: "Test1!Test1!Test1!Test1!Test1!Test1!Test1!Test1!Test1!Test1!Test1!Test1!Test1!Test1!Test1!",
-			wantErr:  ErrPasswordTooLong,
-		},
-		{
-			name:     "missing uppercase",
-			password: "test123!@#",
-			wantErr:  ErrMissingUpper,
-		},
-		{
-			name:     "missing lowercase",
-			password: "TEST123!@#",
-			wantErr:  ErrMissingLower,
-		},
-		{
-			name:     "missing number",
-			password: "TestTest!@#",
-			wantErr:  ErrMissingNumber,
-		},
-		{
-			name:     "missing special",
-			password: "Test12345",
-			wantErr:  ErrMissingSpecial,
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			err := Validate(tt.password)
-			if err != tt.wantErr {
-				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
-			}
-		})
-	}
-}
-
 func TestHashAndVerify(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -100,3 +47,20 @@ func TestHashAndVerify(t *testing.T) {
 		})
 	}
 }
+
+func TestIsHash(t *testing.T) {
+	hashed, err := Hash("Test123!@#")
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+
+	if !IsHash(hashed) {
+		t.Errorf("IsHash(%q) = false, want true", hashed)
+	}
+	if IsHash("Test123!@#") {
+		t.Error("IsHash() = true for plaintext password")
+	}
+	if IsHash("") {
+		t.Error("IsHash() = true for empty value")
+	}
+}
@@ -0,0 +1,248 @@
+package password
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"UserRESTfulApi/pkg/config"
+)
+
+// Violation codes reported by Policy.Validate
+const (
+	ViolationEmpty            = "empty"
+	ViolationTooShort         = "too_short"
+	ViolationTooLong          = "too_long"
+	ViolationMissingUpper     = "missing_upper"
+	ViolationMissingLower     = "missing_lower"
+	ViolationMissingNumber    = "missing_number"
+	ViolationMissingSpecial   = "missing_special"
+	ViolationForbiddenChar    = "forbidden_character"
+	ViolationRepeatedChars    = "repeated_characters"
+	ViolationContainsUserInfo = "contains_user_info"
+	ViolationBreached         = "breached"
+)
+
+// minUserInfoLength is the shortest email/name fragment checked against the password
+const minUserInfoLength = 4
+
+// Violation describes a single password policy rule that was not met
+type Violation struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// PolicyError is returned by Policy.Validate and lists every violated rule
+type PolicyError struct {
+	Violations []Violation
+}
+
+func (e *PolicyError) Error() string {
+	messages := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		messages = append(messages, v.Message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Policy describes the rules a password must satisfy
+type Policy struct {
+	MinLength        int    `json:"min_length"`
+	MaxLength        int    `json:"max_length"` // in bytes, bcrypt only considers the first 72
+	RequireUpper     bool   `json:"require_upper"`
+	RequireLower     bool   `json:"require_lower"`
+	RequireNumber    bool   `json:"require_number"`
+	RequireSpecial   bool   `json:"require_special"`
+	ForbidWhitespace bool   `json:"forbid_whitespace"`
+	ForbiddenChars   string `json:"forbidden_chars,omitempty"`
+	MaxRepeated      int    `json:"max_repeated"` // maximum run of identical characters, 0 disables the check
+	DisallowUserInfo bool   `json:"disallow_user_info"`
+
+	// Blocklist of common and breached passwords, nil disables the check
+	Blocklist *Blocklist `json:"-"`
+}
+
+// NewPolicy creates a password policy from the configuration, loading the
+// breached password blocklist when one is configured
+func NewPolicy(cfg config.PasswordPolicyConfig) (*Policy, error) {
+	blocklist, err := LoadBlocklist(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Policy{
+		MinLength:        cfg.MinLength,
+		MaxLength:        cfg.MaxLength,
+		RequireUpper:     cfg.RequireUpper,
+		RequireLower:     cfg.RequireLower,
+		RequireNumber:    cfg.RequireNumber,
+		RequireSpecial:   cfg.RequireSpecial,
+		ForbidWhitespace: cfg.ForbidWhitespace,
+		ForbiddenChars:   cfg.ForbiddenChars,
+		MaxRepeated:      cfg.MaxRepeated,
+		DisallowUserInfo: cfg.DisallowUserInfo,
+		Blocklist:        blocklist,
+	}, nil
+}
+
+// CheckAlgorithm returns an error when the policy admits passwords the hash
+// algorithm cannot hash, which bcrypt does for passwords over 72 bytes
+func (p *Policy) CheckAlgorithm(algorithm string) error {
+	if algorithm == AlgorithmBcrypt && (p.MaxLength <= 0 || p.MaxLength > BcryptMaxLength) {
+		return fmt.Errorf("password max length must be between 1 and %d bytes with %s hashing", BcryptMaxLength, AlgorithmBcrypt)
+	}
+	return nil
+}
+
+// DefaultPolicy returns the policy used when nothing is configured
+func DefaultPolicy() *Policy {
+	return &Policy{
+		MinLength:        8,
+		MaxLength:        BcryptMaxLength,
+		RequireUpper:     true,
+		RequireLower:     true,
+		RequireNumber:    true,
+		RequireSpecial:   true,
+		MaxRepeated:      3,
+		DisallowUserInfo: true,
+	}
+}
+
+// Validate checks the password against every rule of the policy. userInfo
+// holds values such as the user's email and name that must not appear in the
+// password when DisallowUserInfo is set. All violations are returned at once
+// in a *PolicyError.
+func (p *Policy) Validate(password string, userInfo ...string) error {
+	if strings.TrimSpace(password) == "" {
+		return &PolicyError{Violations: []Violation{{Code: ViolationEmpty, Message: "password cannot be empty"}}}
+	}
+
+	var violations []Violation
+	add := func(code, message string) {
+		violations = append(violations, Violation{Code: code, Message: message})
+	}
+
+	if p.MinLength > 0 && len(password) < p.MinLength {
+		add(ViolationTooShort, fmt.Sprintf("password must be at least %d characters long", p.MinLength))
+	}
+	if p.MaxLength > 0 && len(password) > p.MaxLength {
+		add(ViolationTooLong, fmt.Sprintf("password must not exceed %d characters", p.MaxLength))
+	}
+
+	var (
+		hasUpper     bool
+		hasLower     bool
+		hasNumber    bool
+		hasSpecial   bool
+		hasSpace     bool
+		forbidden    []string
+		longestRun   int
+		run          int
+		previousChar rune
+	)
+
+	for i, char := range password {
+		switch {
+		case unicode.IsUpper(char):
+			hasUpper = true
+		case unicode.IsLower(char):
+			hasLower = true
+		case unicode.IsNumber(char):
+			hasNumber = true
+		case unicode.IsPunct(char) || unicode.IsSymbol(char):
+			hasSpecial = true
+		case unicode.IsSpace(char):
+			hasSpace = true
+		}
+
+		if strings.ContainsRune(p.ForbiddenChars, char) && !containsString(forbidden, string(char)) {
+			forbidden = append(forbidden, string(char))
+		}
+
+		if i > 0 && char == previousChar {
+			run++
+		} else {
+			run = 1
+		}
+		if run > longestRun {
+			longestRun = run
+		}
+		previousChar = char
+	}
+
+	if p.RequireUpper && !hasUpper {
+		add(ViolationMissingUpper, "password must contain at least one uppercase letter")
+	}
+	if p.RequireLower && !hasLower {
+		add(ViolationMissingLower, "password must contain at least one lowercase letter")
+	}
+	if p.RequireNumber && !hasNumber {
+		add(ViolationMissingNumber, "password must contain at least one number")
+	}
+	if p.RequireSpecial && !hasSpecial {
+		add(ViolationMissingSpecial, "password must contain at least one special character")
+	}
+	if p.ForbidWhitespace && hasSpace {
+		add(ViolationForbiddenChar, "password must not contain spaces")
+	}
+	if len(forbidden) > 0 {
+		add(ViolationForbiddenChar, fmt.Sprintf("password must not contain the characters %q", strings.Join(forbidden, "")))
+	}
+	if p.MaxRepeated > 0 && longestRun > p.MaxRepeated {
+		add(ViolationRepeatedChars, fmt.Sprintf("password must not repeat the same character more than %d times in a row", p.MaxRepeated))
+	}
+	if p.DisallowUserInfo && containsUserInfo(password, userInfo) {
+		add(ViolationContainsUserInfo, "password must not contain your email address or name")
+	}
+	if p.Blocklist != nil && p.Blocklist.Contains(password) {
+		add(ViolationBreached, "password is too common or has appeared in a data breach")
+	}
+
+	if len(violations) > 0 {
+		return &PolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// containsUserInfo reports whether the password contains the user's email
+// local part, a label of the email domain (excluding the top-level domain) or
+// any part of the user's name, ignoring case
+func containsUserInfo(password string, userInfo []string) bool {
+	lowered := strings.ToLower(password)
+
+	for _, info := range userInfo {
+		info = strings.ToLower(strings.TrimSpace(info))
+		if info == "" {
+			continue
+		}
+
+		if local, domain, found := strings.Cut(info, "@"); found {
+			if i := strings.LastIndex(domain, "."); i >= 0 {
+				domain = domain[:i]
+			}
+			info = local + " " + domain
+			if len(local) >= minUserInfoLength && strings.Contains(lowered, local) {
+				return true
+			}
+		}
+
+		fragments := strings.FieldsFunc(info, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+		})
+		for _, fragment := range fragments {
+			if len(fragment) >= minUserInfoLength && strings.Contains(lowered, fragment) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,161 @@
+package password
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPolicyValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    *Policy
+		password  string
+		userInfo  []string
+		wantCodes []string
+	}{
+		{
+			name:     "valid password",
+			policy:   DefaultPolicy(),
+			password: "Test123!@#",
+		},
+		{
+			name:      "empty password",
+			policy:    DefaultPolicy(),
+			password:  "   ",
+			wantCodes: []string{ViolationEmpty},
+		},
+		{
+			name:      "password too short",
+			policy:    DefaultPolicy(),
+			password:  "Test1!",
+			wantCodes: []string{ViolationTooShort},
+		},
+		{
+			name:      "password too long",
+			policy:    DefaultPolicy(),
+			password:  "Test1!Test1!Test1!Test1!Test1!Test1!Test1!Test1!Test1!Test1!Test1!Test1!Test1!Test1!Test1!",
+			wantCodes: []string{ViolationTooLong},
+		},
+		{
+			name:      "missing uppercase",
+			policy:    DefaultPolicy(),
+			password:  "test123!@#",
+			wantCodes: []string{ViolationMissingUpper},
+		},
+		{
+			name:      "missing lowercase",
+			policy:    DefaultPolicy(),
+			password:  "TEST123!@#",
+			wantCodes: []string{ViolationMissingLower},
+		},
+		{
+			name:      "missing number",
+			policy:    DefaultPolicy(),
+			password:  "TestTest!@#",
+			wantCodes: []string{ViolationMissingNumber},
+		},
+		{
+			name:      "missing special",
+			policy:    DefaultPolicy(),
+			password:  "Test12345",
+			wantCodes: []string{ViolationMissingSpecial},
+		},
+		{
+			name:      "all violations at once",
+			policy:    DefaultPolicy(),
+			password:  "aaaa",
+			wantCodes: []string{ViolationTooShort, ViolationMissingUpper, ViolationMissingNumber, ViolationMissingSpecial, ViolationRepeatedChars},
+		},
+		{
+			name:      "repeated characters",
+			policy:    DefaultPolicy(),
+			password:  "Test1111!@#",
+			wantCodes: []string{ViolationRepeatedChars},
+		},
+		{
+			name:     "whitespace allowed by default",
+			policy:   DefaultPolicy(),
+			password: "Test 123!@#",
+		},
+		{
+			name:      "whitespace forbidden",
+			policy:    &Policy{MinLength: 8, ForbidWhitespace: true},
+			password:  "Test 123!@#",
+			wantCodes: []string{ViolationForbiddenChar},
+		},
+		{
+			name:      "forbidden characters",
+			policy:    &Policy{MinLength: 8, ForbiddenChars: "<>"},
+			password:  "Test<123>",
+			wantCodes: []string{ViolationForbiddenChar},
+		},
+		{
+			name:      "contains email local part",
+			policy:    &Policy{DisallowUserInfo: true},
+			password:  "MyJdoe123!",
+			userInfo:  []string{"jdoe@example.com", "John Doe"},
+			wantCodes: []string{ViolationContainsUserInfo},
+		},
+		{
+			name:      "contains name",
+			policy:    &Policy{DisallowUserInfo: true},
+			password:  "JOHN2024!!",
+			userInfo:  []string{"jdoe@example.com", "John Doe"},
+			wantCodes: []string{ViolationContainsUserInfo},
+		},
+		{
+			name:     "top-level domain is ignored",
+			policy:   &Policy{DisallowUserInfo: true},
+			password: "Income123!",
+			userInfo: []string{"jdoe@example.com"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Validate(tt.password, tt.userInfo...)
+			if len(tt.wantCodes) == 0 {
+				if err != nil {
+					t.Errorf("Validate() error = %v, want nil", err)
+				}
+				return
+			}
+
+			policyErr, ok := err.(*PolicyError)
+			if !ok {
+				t.Fatalf("Validate() error = %v, want *PolicyError", err)
+			}
+			var codes []string
+			for _, v := range policyErr.Violations {
+				codes = append(codes, v.Code)
+			}
+			if !reflect.DeepEqual(codes, tt.wantCodes) {
+				t.Errorf("Validate() codes = %v, want %v", codes, tt.wantCodes)
+			}
+		})
+	}
+}
+
+func TestPolicyCheckAlgorithm(t *testing.T) {
+	tests := []struct {
+		name      string
+		maxLength int
+		algorithm string
+		wantErr   bool
+	}{
+		{name: "bcrypt limit", maxLength: BcryptMaxLength, algorithm: AlgorithmBcrypt},
+		{name: "over bcrypt limit", maxLength: BcryptMaxLength + 1, algorithm: AlgorithmBcrypt, wantErr: true},
+		{name: "unbounded with bcrypt", maxLength: 0, algorithm: AlgorithmBcrypt, wantErr: true},
+		{name: "over bcrypt limit with argon2id", maxLength: 256, algorithm: AlgorithmArgon2id},
+		{name: "unbounded with argon2id", maxLength: 0, algorithm: AlgorithmArgon2id},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := &Policy{MaxLength: tt.maxLength}
+			if err := policy.CheckAlgorithm(tt.algorithm); (err != nil) != tt.wantErr {
+				t.Errorf("CheckAlgorithm(%q) error = %v, wantErr %v", tt.algorithm, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,389 @@
+// Package scim parses the filter and attribute path syntax of SCIM 2.0
+// (RFC 7644 section 3.4.2.2 and 3.5.2).
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Comparison operators
+const (
+	OperatorEqual          = "eq"
+	OperatorNotEqual       = "ne"
+	OperatorContains       = "co"
+	OperatorStartsWith     = "sw"
+	OperatorEndsWith       = "ew"
+	OperatorGreater        = "gt"
+	OperatorGreaterOrEqual = "ge"
+	OperatorLess           = "lt"
+	OperatorLessOrEqual    = "le"
+	OperatorPresent        = "pr"
+)
+
+// Logical operators
+const (
+	OperatorAnd = "and"
+	OperatorOr  = "or"
+)
+
+var comparisonOperators = map[string]bool{
+	OperatorEqual: true, OperatorNotEqual: true, OperatorContains: true, OperatorStartsWith: true,
+	OperatorEndsWith: true, OperatorGreater: true, OperatorGreaterOrEqual: true, OperatorLess: true,
+	OperatorLessOrEqual: true, OperatorPresent: true,
+}
+
+// Expression is a node of a parsed filter: *Comparison, *Logical, *Not or *ValuePath
+type Expression interface {
+	expression()
+}
+
+// AttributePath names an attribute, optionally qualified by its schema URN
+// and followed by a sub-attribute, as in name.givenName
+type AttributePath struct {
+	URN          string
+	Attribute    string
+	SubAttribute string
+}
+
+// String returns the path without the schema URN, e.g. emails.value
+func (p AttributePath) String() string {
+	if p.SubAttribute == "" {
+		return p.Attribute
+	}
+	return p.Attribute + "." + p.SubAttribute
+}
+
+// Comparison compares an attribute with a value. Value is a string, float64,
+// bool or nil and is unset for the pr operator.
+type Comparison struct {
+	Path     AttributePath
+	Operator string
+	Value    interface{}
+}
+
+// Logical combines two expressions with and or or
+type Logical struct {
+	Operator    string
+	Left, Right Expression
+}
+
+// Not negates an expression
+type Not struct {
+	Expression Expression
+}
+
+// ValuePath applies a filter to the values of a multi-valued attribute, as in
+// emails[type eq "work"]. Attributes in the filter are sub-attributes.
+type ValuePath struct {
+	Path   AttributePath
+	Filter Expression
+}
+
+func (*Comparison) expression() {}
+func (*Logical) expression()    {}
+func (*Not) expression()        {}
+func (*ValuePath) expression()  {}
+
+// Path is the target of a PATCH operation, e.g. emails[type eq "work"].value
+type Path struct {
+	AttributePath
+	// Filter selects values of a multi-valued attribute, nil when absent
+	Filter Expression
+}
+
+// ParseFilter parses the filter query parameter
+func ParseFilter(filter string) (Expression, error) {
+	p, err := newParser(filter)
+	if err != nil {
+		return nil, err
+	}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if !p.done() {
+		return nil, fmt.Errorf("unexpected %q", p.peek().text)
+	}
+	return expr, nil
+}
+
+// ParsePath parses the path of a PATCH operation
+func ParsePath(path string) (*Path, error) {
+	p, err := newParser(path)
+	if err != nil {
+		return nil, err
+	}
+	tok := p.next()
+	if tok.kind != tokenWord {
+		return nil, fmt.Errorf("expected an attribute name")
+	}
+	attr, err := parseAttributePath(tok.text)
+	if err != nil {
+		return nil, err
+	}
+	result := &Path{AttributePath: attr}
+
+	if p.peek().kind == tokenOpenBracket {
+		p.next()
+		if attr.SubAttribute != "" {
+			return nil, fmt.Errorf("a value filter must follow a multi-valued attribute")
+		}
+		if result.Filter, err = p.parseOr(); err != nil {
+			return nil, err
+		}
+		if p.next().kind != tokenCloseBracket {
+			return nil, fmt.Errorf("expected ]")
+		}
+		if tok := p.peek(); tok.kind == tokenWord && strings.HasPrefix(tok.text, ".") {
+			p.next()
+			result.SubAttribute = strings.TrimPrefix(tok.text, ".")
+		}
+	}
+	if !p.done() {
+		return nil, fmt.Errorf("unexpected %q", p.peek().text)
+	}
+	return result, nil
+}
+
+// parseAttributePath splits urn:...:User:name.givenName into its parts
+func parseAttributePath(text string) (AttributePath, error) {
+	var path AttributePath
+	if i := strings.LastIndex(text, ":"); i >= 0 {
+		path.URN, text = text[:i], text[i+1:]
+	}
+	path.Attribute, path.SubAttribute, _ = strings.Cut(text, ".")
+	if !validName(path.Attribute) || (path.SubAttribute != "" && !validName(path.SubAttribute)) {
+		return path, fmt.Errorf("invalid attribute name %q", text)
+	}
+	return path, nil
+}
+
+// validName reports whether the name is an ATTRNAME of RFC 7643 section 2.1
+func validName(name string) bool {
+	for i, c := range name {
+		alpha := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+		if i == 0 && !alpha && c != '$' {
+			return false
+		}
+		if !alpha && !(c >= '0' && c <= '9') && c != '_' && c != '-' && c != '$' {
+			return false
+		}
+	}
+	return name != ""
+}
+
+type tokenKind int
+
+const (
+	tokenEnd tokenKind = iota
+	tokenWord
+	tokenString
+	tokenOpenParen
+	tokenCloseParen
+	tokenOpenBracket
+	tokenCloseBracket
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func newParser(input string) (*parser, error) {
+	tokens, err := tokenize(input)
+	if err != nil {
+		return nil, err
+	}
+	return &parser{tokens: tokens}, nil
+}
+
+func tokenize(input string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(input); {
+		switch c := input[i]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, token{tokenOpenParen, "("})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{tokenCloseParen, ")"})
+			i++
+		case c == '[':
+			tokens = append(tokens, token{tokenOpenBracket, "["})
+			i++
+		case c == ']':
+			tokens = append(tokens, token{tokenCloseBracket, "]"})
+			i++
+		case c == '"':
+			end := i + 1
+			for ; end < len(input) && input[end] != '"'; end++ {
+				if input[end] == '\\' {
+					end++
+				}
+			}
+			if end >= len(input) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			var value string
+			if err := json.Unmarshal([]byte(input[i:end+1]), &value); err != nil {
+				return nil, fmt.Errorf("invalid string %s", input[i:end+1])
+			}
+			tokens = append(tokens, token{tokenString, value})
+			i = end + 1
+		default:
+			end := i
+			for end < len(input) && !strings.ContainsRune(" \t\n\r()[]\"", rune(input[end])) {
+				end++
+			}
+			tokens = append(tokens, token{tokenWord, input[i:end]})
+			i = end
+		}
+	}
+	return tokens, nil
+}
+
+func (p *parser) peek() token {
+	if p.pos >= len(p.tokens) {
+		return token{kind: tokenEnd}
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.peek()
+	if tok.kind != tokenEnd {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+// keyword reports whether the next token is the case-insensitive keyword
+func (p *parser) keyword(word string) bool {
+	tok := p.peek()
+	return tok.kind == tokenWord && strings.EqualFold(tok.text, word)
+}
+
+// parseOr parses expressions joined by or, which binds weaker than and
+func (p *parser) parseOr() (Expression, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword(OperatorOr) {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &Logical{Operator: OperatorOr, Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Expression, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword(OperatorAnd) {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &Logical{Operator: OperatorAnd, Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (Expression, error) {
+	if p.keyword("not") {
+		p.next()
+		if p.peek().kind != tokenOpenParen {
+			return nil, fmt.Errorf("expected ( after not")
+		}
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &Not{Expression: expr}, nil
+	}
+
+	tok := p.next()
+	switch tok.kind {
+	case tokenOpenParen:
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next().kind != tokenCloseParen {
+			return nil, fmt.Errorf("expected )")
+		}
+		return expr, nil
+	case tokenWord:
+		return p.parseAttributeExpression(tok.text)
+	case tokenEnd:
+		return nil, fmt.Errorf("unexpected end of filter")
+	default:
+		return nil, fmt.Errorf("unexpected %q", tok.text)
+	}
+}
+
+// parseAttributeExpression parses what follows an attribute path: a value
+// filter in brackets, pr, or a comparison operator and value
+func (p *parser) parseAttributeExpression(attribute string) (Expression, error) {
+	path, err := parseAttributePath(attribute)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.peek().kind == tokenOpenBracket {
+		p.next()
+		filter, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next().kind != tokenCloseBracket {
+			return nil, fmt.Errorf("expected ]")
+		}
+		return &ValuePath{Path: path, Filter: filter}, nil
+	}
+
+	op := p.next()
+	operator := strings.ToLower(op.text)
+	if op.kind != tokenWord || !comparisonOperators[operator] {
+		return nil, fmt.Errorf("expected an operator after %s", attribute)
+	}
+	if operator == OperatorPresent {
+		return &Comparison{Path: path, Operator: operator}, nil
+	}
+
+	value := p.next()
+	switch value.kind {
+	case tokenString:
+		return &Comparison{Path: path, Operator: operator, Value: value.text}, nil
+	case tokenWord:
+		var literal interface{}
+		if err := json.Unmarshal([]byte(value.text), &literal); err != nil {
+			return nil, fmt.Errorf("invalid value %q", value.text)
+		}
+		if _, isString := literal.(string); isString {
+			return nil, fmt.Errorf("invalid value %q", value.text)
+		}
+		return &Comparison{Path: path, Operator: operator, Value: literal}, nil
+	default:
+		return nil, fmt.Errorf("expected a value after %s %s", attribute, operator)
+	}
+}
@@ -0,0 +1,127 @@
+package scim
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseFilter(t *testing.T) {
+	userName := AttributePath{Attribute: "userName"}
+	emails := AttributePath{Attribute: "emails", SubAttribute: "value"}
+
+	tests := []struct {
+		filter string
+		want   Expression
+	}{
+		{
+			`userName eq "bjensen@example.com"`,
+			&Comparison{Path: userName, Operator: OperatorEqual, Value: "bjensen@example.com"},
+		},
+		{
+			`urn:ietf:params:scim:schemas:core:2.0:User:userName Eq "a\"b"`,
+			&Comparison{Path: AttributePath{URN: "urn:ietf:params:scim:schemas:core:2.0:User", Attribute: "userName"}, Operator: OperatorEqual, Value: `a"b`},
+		},
+		{
+			`emails.value co "example.com" and active eq true`,
+			&Logical{
+				Operator: OperatorAnd,
+				Left:     &Comparison{Path: emails, Operator: OperatorContains, Value: "example.com"},
+				Right:    &Comparison{Path: AttributePath{Attribute: "active"}, Operator: OperatorEqual, Value: true},
+			},
+		},
+		{
+			// and binds tighter than or
+			`userName sw "a" or userName sw "b" and title pr`,
+			&Logical{
+				Operator: OperatorOr,
+				Left:     &Comparison{Path: userName, Operator: OperatorStartsWith, Value: "a"},
+				Right: &Logical{
+					Operator: OperatorAnd,
+					Left:     &Comparison{Path: userName, Operator: OperatorStartsWith, Value: "b"},
+					Right:    &Comparison{Path: AttributePath{Attribute: "title"}, Operator: OperatorPresent},
+				},
+			},
+		},
+		{
+			`not (userName eq "a" OR userName eq "b")`,
+			&Not{Expression: &Logical{
+				Operator: OperatorOr,
+				Left:     &Comparison{Path: userName, Operator: OperatorEqual, Value: "a"},
+				Right:    &Comparison{Path: userName, Operator: OperatorEqual, Value: "b"},
+			}},
+		},
+		{
+			`emails[type eq "work" and value ew "@example.com"]`,
+			&ValuePath{
+				Path: AttributePath{Attribute: "emails"},
+				Filter: &Logical{
+					Operator: OperatorAnd,
+					Left:     &Comparison{Path: AttributePath{Attribute: "type"}, Operator: OperatorEqual, Value: "work"},
+					Right:    &Comparison{Path: AttributePath{Attribute: "value"}, Operator: OperatorEndsWith, Value: "@example.com"},
+				},
+			},
+		},
+		{
+			`meta.lastModified gt "2011-05-13T04:42:34Z"`,
+			&Comparison{Path: AttributePath{Attribute: "meta", SubAttribute: "lastModified"}, Operator: OperatorGreater, Value: "2011-05-13T04:42:34Z"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			got, err := ParseFilter(tt.filter)
+			if err != nil {
+				t.Fatalf("ParseFilter() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseFilter() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFilterRejectsInvalidFilters(t *testing.T) {
+	invalid := []string{
+		``,
+		`userName`,
+		`userName eq`,
+		`userName like "a"`,
+		`userName eq bjensen`,
+		`userName eq "a" and`,
+		`(userName eq "a"`,
+		`emails[type eq "work"`,
+		`userName eq "unterminated`,
+		`1userName eq "a"`,
+		`userName eq "a" userName eq "b"`,
+	}
+	for _, filter := range invalid {
+		if _, err := ParseFilter(filter); err == nil {
+			t.Errorf("ParseFilter(%q) succeeded, want an error", filter)
+		}
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	path, err := ParsePath(`emails[type eq "work"].value`)
+	if err != nil {
+		t.Fatalf("ParsePath() error = %v", err)
+	}
+	want := &Path{
+		AttributePath: AttributePath{Attribute: "emails", SubAttribute: "value"},
+		Filter:        &Comparison{Path: AttributePath{Attribute: "type"}, Operator: OperatorEqual, Value: "work"},
+	}
+	if !reflect.DeepEqual(path, want) {
+		t.Errorf("ParsePath() = %#v, want %#v", path, want)
+	}
+
+	path, err = ParsePath("name.givenName")
+	if err != nil || path.String() != "name.givenName" || path.Filter != nil {
+		t.Errorf("ParsePath(name.givenName) = %#v, %v", path, err)
+	}
+
+	for _, invalid := range []string{"", "emails[", "name.givenName[type eq \"a\"]", "userName eq \"a\""} {
+		if _, err := ParsePath(invalid); err == nil {
+			t.Errorf("ParsePath(%q) succeeded, want an error", invalid)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// keySize is the length of an AES-256 key in bytes
+const keySize = 32
+
+// version prefixes every ciphertext so the format can change without
+// breaking values that are already stored
+const version = "v1"
+
+var (
+	ErrMissingKey        = errors.New("encryption key is not configured")
+	ErrInvalidKey        = errors.New("encryption key must be 32 bytes encoded as base64")
+	ErrInvalidCiphertext = errors.New("invalid ciphertext")
+)
+
+// Cipher encrypts small secrets such as TOTP seeds before they are stored,
+// using AES-256-GCM with a random nonce per value
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// NewCipher creates a cipher from a base64 encoded 32 byte key
+func NewCipher(encodedKey string) (*Cipher, error) {
+	if encodedKey == "" {
+		return nil, ErrMissingKey
+	}
+	key, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil || len(key) != keySize {
+		return nil, ErrInvalidKey
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Cipher{aead: aead}, nil
+}
+
+// Encrypt returns the plaintext sealed and encoded as "v1:<base64 nonce+ciphertext>"
+func (c *Cipher) Encrypt(plaintext string) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := c.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return version + ":" + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt opens a value produced by Encrypt. Values that were tampered with or
+// encrypted with another key are rejected.
+func (c *Cipher) Decrypt(ciphertext string) (string, error) {
+	prefix, encoded, found := strings.Cut(ciphertext, ":")
+	if !found || prefix != version {
+		return "", ErrInvalidCiphertext
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < c.aead.NonceSize() {
+		return "", ErrInvalidCiphertext
+	}
+
+	nonce, sealed := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	plaintext, err := c.aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidCiphertext, err)
+	}
+	return string(plaintext), nil
+}
@@ -0,0 +1,63 @@
+package secrets
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func testKey(b byte) string {
+	return base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(b), keySize)))
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	c, err := NewCipher(testKey('k'))
+	if err != nil {
+		t.Fatalf("NewCipher() error = %v", err)
+	}
+
+	first, err := c.Encrypt("JBSWY3DPEHPK3PXP")
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+	second, _ := c.Encrypt("JBSWY3DPEHPK3PXP")
+	if first == second {
+		t.Error("Encrypt() returned the same ciphertext twice, want a random nonce")
+	}
+	if strings.Contains(first, "JBSWY3DPEHPK3PXP") {
+		t.Error("Encrypt() leaked the plaintext")
+	}
+
+	plaintext, err := c.Decrypt(first)
+	if err != nil || plaintext != "JBSWY3DPEHPK3PXP" {
+		t.Errorf("Decrypt() = %q, %v, want the original secret", plaintext, err)
+	}
+
+	other, _ := NewCipher(testKey('o'))
+	if _, err := other.Decrypt(first); !errors.Is(err, ErrInvalidCiphertext) {
+		t.Errorf("Decrypt() with another key error = %v, want ErrInvalidCiphertext", err)
+	}
+
+	tampered := []byte(first)
+	if i := len(tampered) - 5; tampered[i] == 'A' {
+		tampered[i] = 'B'
+	} else {
+		tampered[i] = 'A'
+	}
+	if _, err := c.Decrypt(string(tampered)); !errors.Is(err, ErrInvalidCiphertext) {
+		t.Errorf("Decrypt(tampered) error = %v, want ErrInvalidCiphertext", err)
+	}
+}
+
+func TestNewCipherRejectsBadKeys(t *testing.T) {
+	if _, err := NewCipher(""); !errors.Is(err, ErrMissingKey) {
+		t.Errorf("NewCipher(\"\") error = %v, want ErrMissingKey", err)
+	}
+	short := base64.StdEncoding.EncodeToString([]byte("too short"))
+	for _, key := range []string{short, "not base64!"} {
+		if _, err := NewCipher(key); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("NewCipher(%q) error = %v, want ErrInvalidKey", key, err)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package token
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"strings"
+)
+
+// apiKeyMarker starts every API key so leaked keys are easy to recognize
+const apiKeyMarker = "uak_"
+
+// apiKeyPrefixLength is the length of the public part of a key: the marker
+// followed by 8 random characters identifying the key
+const apiKeyPrefixLength = len(apiKeyMarker) + 8
+
+// NewAPIKey generates an API key of the form uak_<id>_<secret> and returns it
+// with its public prefix uak_<id>, which is stored to look the key up
+func NewAPIKey() (string, string, error) {
+	id := make([]byte, 6)
+	if _, err := rand.Read(id); err != nil {
+		return "", "", err
+	}
+	secret, err := NewOpaque()
+	if err != nil {
+		return "", "", err
+	}
+
+	prefix := apiKeyMarker + base64.RawURLEncoding.EncodeToString(id)
+	return prefix + "_" + secret, prefix, nil
+}
+
+// APIKeyPrefix returns the public prefix of an API key, or false when the
+// value is not shaped like a key
+func APIKeyPrefix(key string) (string, bool) {
+	if len(key) <= apiKeyPrefixLength+1 || !strings.HasPrefix(key, apiKeyMarker) || key[apiKeyPrefixLength] != '_' {
+		return "", false
+	}
+	return key[:apiKeyPrefixLength], true
+}
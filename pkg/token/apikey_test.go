@@ -0,0 +1,25 @@
+package token
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAPIKeyPrefix(t *testing.T) {
+	key, prefix, err := NewAPIKey()
+	if err != nil {
+		t.Fatalf("NewAPIKey() error = %v", err)
+	}
+	if !strings.HasPrefix(key, prefix+"_") || len(prefix) != 12 {
+		t.Fatalf("NewAPIKey() = %q, %q, want the key to start with its prefix", key, prefix)
+	}
+	if got, ok := APIKeyPrefix(key); !ok || got != prefix {
+		t.Errorf("APIKeyPrefix() = %q, %v, want %q", got, ok, prefix)
+	}
+
+	for _, invalid := range []string{"", prefix, prefix + "_", "xyz_" + key[4:], strings.Replace(key, "_", "-", 2)} {
+		if _, ok := APIKeyPrefix(invalid); ok {
+			t.Errorf("APIKeyPrefix(%q) accepted a malformed key", invalid)
+		}
+	}
+}
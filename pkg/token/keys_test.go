@@ -0,0 +1,50 @@
+package token
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestThumbprintMatchesRFC7638(t *testing.T) {
+	// Example key of RFC 7638 section 3.1
+	n, _ := base64.RawURLEncoding.DecodeString("0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw")
+	key := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: 65537}
+
+	if got := Thumbprint(key); got != "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs" {
+		t.Errorf("Thumbprint() = %s", got)
+	}
+	if _, e := JWKParams(key); e != "AQAB" {
+		t.Errorf("JWKParams() e = %s, want AQAB", e)
+	}
+}
+
+func TestSignRS256(t *testing.T) {
+	key, err := NewRSAKey()
+	if err != nil {
+		t.Fatalf("NewRSAKey() error = %v", err)
+	}
+	publicPEM, err := EncodeRSAPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("EncodeRSAPublicKey() error = %v", err)
+	}
+	if _, err := DecodeRSAPrivateKey(EncodeRSAPrivateKey(key)); err != nil {
+		t.Fatalf("private key PEM does not parse: %v", err)
+	}
+	publicKey, err := DecodeRSAPublicKey(publicPEM)
+	if err != nil {
+		t.Fatalf("public key PEM does not parse: %v", err)
+	}
+
+	signed, err := SignRS256(map[string]interface{}{"sub": "42"}, key, "kid-1")
+	if err != nil {
+		t.Fatalf("SignRS256() error = %v", err)
+	}
+	parsed, err := jwt.Parse(signed, func(t *jwt.Token) (interface{}, error) { return publicKey, nil })
+	if err != nil || parsed.Header["kid"] != "kid-1" {
+		t.Errorf("Parse() = %v, %v, want a valid token with kid-1", parsed, err)
+	}
+}
@@ -0,0 +1,25 @@
+package token
+
+import "testing"
+
+func TestNewNumericCode(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 50; i++ {
+		code, err := NewNumericCode(6)
+		if err != nil {
+			t.Fatalf("NewNumericCode() error = %v", err)
+		}
+		if len(code) != 6 {
+			t.Fatalf("NewNumericCode() = %q, want 6 digits", code)
+		}
+		for _, c := range code {
+			if c < '0' || c > '9' {
+				t.Fatalf("NewNumericCode() = %q, want only digits", code)
+			}
+		}
+		seen[code] = true
+	}
+	if len(seen) < 45 {
+		t.Errorf("NewNumericCode() returned %d distinct codes out of 50", len(seen))
+	}
+}
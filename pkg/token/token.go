@@ -0,0 +1,189 @@
+package token
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"UserRESTfulApi/pkg/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrInvalidToken         = errors.New("invalid token")
+	ErrExpiredToken         = errors.New("token has expired")
+	ErrUnsupportedAlgorithm = errors.New("unsupported signing algorithm")
+	ErrMissingSigningKey    = errors.New("signing key is not configured")
+)
+
+// Authentication method references (RFC 8176) recorded in the amr claim
+const (
+	MethodPassword = "pwd"
+	MethodOTP      = "otp"
+	MethodMFA      = "mfa"
+)
+
+// Claims represents the claims carried by an access token
+type Claims struct {
+	Email     string   `json:"email"`
+	AMR       []string `json:"amr,omitempty"` // how the user authenticated
+	SessionID string   `json:"sid,omitempty"` // the session the token was issued to
+	Actor     *Actor   `json:"act,omitempty"` // set when another user acts as the subject
+	jwt.RegisteredClaims
+}
+
+// Actor is the party acting on behalf of the subject of a token, recorded in
+// the act claim (RFC 8693)
+type Actor struct {
+	Subject string `json:"sub"`
+}
+
+// HasMethod reports whether the amr claim lists the authentication method
+func (c *Claims) HasMethod(method string) bool {
+	for _, m := range c.AMR {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// UserID returns the user ID stored in the subject claim
+func (c *Claims) UserID() (uint, error) {
+	id, err := strconv.ParseUint(c.Subject, 10, 32)
+	if err != nil {
+		return 0, ErrInvalidToken
+	}
+	return uint(id), nil
+}
+
+// ActorID returns the user ID stored in the act claim, or 0 when the token
+// is not an impersonation token
+func (c *Claims) ActorID() (uint, error) {
+	if c.Actor == nil {
+		return 0, nil
+	}
+	id, err := strconv.ParseUint(c.Actor.Subject, 10, 32)
+	if err != nil || id == 0 {
+		return 0, ErrInvalidToken
+	}
+	return uint(id), nil
+}
+
+// Manager issues and verifies signed access tokens
+type Manager struct {
+	method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+	issuer    string
+	ttl       time.Duration
+	now       func() time.Time
+}
+
+// NewManager creates a new token manager from the auth configuration
+func NewManager(cfg config.AuthConfig) (*Manager, error) {
+	m := &Manager{
+		issuer: cfg.JWTIssuer,
+		ttl:    cfg.AccessTokenTTL,
+		now:    time.Now,
+	}
+
+	switch cfg.JWTAlgorithm {
+	case "HS256":
+		if cfg.JWTSecret == "" {
+			return nil, fmt.Errorf("%w: AUTH_JWT_SECRET is required for HS256", ErrMissingSigningKey)
+		}
+		m.method = jwt.SigningMethodHS256
+		m.signKey = []byte(cfg.JWTSecret)
+		m.verifyKey = []byte(cfg.JWTSecret)
+	case "RS256":
+		if cfg.JWTPrivateKeyPath == "" || cfg.JWTPublicKeyPath == "" {
+			return nil, fmt.Errorf("%w: AUTH_JWT_PRIVATE_KEY_PATH and AUTH_JWT_PUBLIC_KEY_PATH are required for RS256", ErrMissingSigningKey)
+		}
+		privatePEM, err := os.ReadFile(cfg.JWTPrivateKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read private key: %v", err)
+		}
+		publicPEM, err := os.ReadFile(cfg.JWTPublicKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read public key: %v", err)
+		}
+		privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(privatePEM)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse private key: %v", err)
+		}
+		publicKey, err := jwt.ParseRSAPublicKeyFromPEM(publicPEM)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse public key: %v", err)
+		}
+		m.method = jwt.SigningMethodRS256
+		m.signKey = privateKey
+		m.verifyKey = publicKey
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, cfg.JWTAlgorithm)
+	}
+
+	return m, nil
+}
+
+// Issue creates a signed access token for the given user and session,
+// recording the authentication methods used to sign in
+func (m *Manager) Issue(userID uint, email, sessionID string, methods ...string) (string, time.Time, error) {
+	return m.sign(Claims{Email: email, AMR: methods, SessionID: sessionID}, userID, m.ttl)
+}
+
+// IssueImpersonation creates a signed access token that lets the actor act as
+// the given user. The token is bound to the actor's session and lives for ttl
+// instead of the configured access token lifetime.
+func (m *Manager) IssueImpersonation(userID uint, email string, actorID uint, actorSessionID string, ttl time.Duration) (string, time.Time, error) {
+	actor := &Actor{Subject: strconv.FormatUint(uint64(actorID), 10)}
+	return m.sign(Claims{Email: email, SessionID: actorSessionID, Actor: actor}, userID, ttl)
+}
+
+// sign completes the registered claims for the subject and signs the token
+func (m *Manager) sign(claims Claims, userID uint, ttl time.Duration) (string, time.Time, error) {
+	now := m.now()
+	expiresAt := now.Add(ttl)
+
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		Issuer:    m.issuer,
+		Subject:   strconv.FormatUint(uint64(userID), 10),
+		IssuedAt:  jwt.NewNumericDate(now),
+		NotBefore: jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(expiresAt),
+	}
+
+	signed, err := jwt.NewWithClaims(m.method, claims).SignedString(m.signKey)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return signed, expiresAt, nil
+}
+
+// Parse verifies the token signature and standard claims and returns its claims
+func (m *Manager) Parse(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		return m.verifyKey, nil
+	},
+		jwt.WithValidMethods([]string{m.method.Alg()}),
+		jwt.WithIssuer(m.issuer),
+		jwt.WithExpirationRequired(),
+		jwt.WithTimeFunc(m.now),
+	)
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, ErrExpiredToken
+		}
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// TTL returns the lifetime of issued access tokens
+func (m *Manager) TTL() time.Duration {
+	return m.ttl
+}
@@ -0,0 +1,171 @@
+package token
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"UserRESTfulApi/pkg/config"
+)
+
+func TestIssueAndParse(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.AuthConfig
+	}{
+		{
+			name: "HS256",
+			cfg: config.AuthConfig{
+				JWTAlgorithm:   "HS256",
+				JWTSecret:      "test-secret",
+				JWTIssuer:      "test",
+				AccessTokenTTL: time.Minute,
+			},
+		},
+		{
+			name: "RS256",
+			cfg:  rsaConfig(t),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := NewManager(tt.cfg)
+			if err != nil {
+				t.Fatalf("NewManager() error = %v", err)
+			}
+
+			signed, expiresAt, err := m.Issue(42, "test@example.com", "session-1", MethodPassword, MethodOTP, MethodMFA)
+			if err != nil {
+				t.Fatalf("Issue() error = %v", err)
+			}
+			if !expiresAt.After(time.Now()) {
+				t.Errorf("Issue() expiresAt = %v, want future time", expiresAt)
+			}
+
+			claims, err := m.Parse(signed)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			userID, err := claims.UserID()
+			if err != nil || userID != 42 {
+				t.Errorf("UserID() = %v, %v, want 42", userID, err)
+			}
+			if claims.Email != "test@example.com" {
+				t.Errorf("Email = %v, want test@example.com", claims.Email)
+			}
+			if claims.SessionID != "session-1" {
+				t.Errorf("SessionID = %v, want session-1", claims.SessionID)
+			}
+			if !claims.HasMethod(MethodMFA) || claims.HasMethod("hwk") {
+				t.Errorf("AMR = %v, want pwd, otp and mfa", claims.AMR)
+			}
+		})
+	}
+}
+
+func TestIssueImpersonation(t *testing.T) {
+	m, _ := NewManager(config.AuthConfig{
+		JWTAlgorithm:   "HS256",
+		JWTSecret:      "test-secret",
+		JWTIssuer:      "test",
+		AccessTokenTTL: time.Hour,
+	})
+
+	signed, expiresAt, err := m.IssueImpersonation(42, "user@example.com", 7, "session-1", time.Minute)
+	if err != nil {
+		t.Fatalf("IssueImpersonation() error = %v", err)
+	}
+	if expiresAt.After(time.Now().Add(2 * time.Minute)) {
+		t.Errorf("IssueImpersonation() expiresAt = %v, want the impersonation TTL", expiresAt)
+	}
+
+	claims, err := m.Parse(signed)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	userID, _ := claims.UserID()
+	actorID, err := claims.ActorID()
+	if userID != 42 || actorID != 7 || err != nil {
+		t.Errorf("UserID(), ActorID() = %d, %d, %v, want 42 and 7", userID, actorID, err)
+	}
+	if claims.SessionID != "session-1" || len(claims.AMR) != 0 {
+		t.Errorf("claims = %+v, want the actor's session and no amr", claims)
+	}
+
+	// Regular tokens have no actor
+	signed, _, _ = m.Issue(42, "user@example.com", "")
+	claims, _ = m.Parse(signed)
+	if actorID, err := claims.ActorID(); actorID != 0 || err != nil {
+		t.Errorf("ActorID() = %d, %v, want 0", actorID, err)
+	}
+}
+
+func TestParseRejectsInvalidTokens(t *testing.T) {
+	cfg := config.AuthConfig{
+		JWTAlgorithm:   "HS256",
+		JWTSecret:      "test-secret",
+		JWTIssuer:      "test",
+		AccessTokenTTL: time.Minute,
+	}
+	m, _ := NewManager(cfg)
+
+	otherCfg := cfg
+	otherCfg.JWTSecret = "other-secret"
+	other, _ := NewManager(otherCfg)
+	forged, _, _ := other.Issue(1, "test@example.com", "")
+
+	if _, err := m.Parse(forged); err != ErrInvalidToken {
+		t.Errorf("Parse() forged token error = %v, want %v", err, ErrInvalidToken)
+	}
+
+	m.now = func() time.Time { return time.Now().Add(-time.Hour) }
+	expired, _, _ := m.Issue(1, "test@example.com", "")
+	m.now = time.Now
+	if _, err := m.Parse(expired); err != ErrExpiredToken {
+		t.Errorf("Parse() expired token error = %v, want %v", err, ErrExpiredToken)
+	}
+
+	if _, err := m.Parse("not-a-token"); err != ErrInvalidToken {
+		t.Errorf("Parse() garbage error = %v, want %v", err, ErrInvalidToken)
+	}
+}
+
+func TestNewManagerValidation(t *testing.T) {
+	if _, err := NewManager(config.AuthConfig{JWTAlgorithm: "HS256"}); err == nil {
+		t.Error("NewManager() expected error for missing secret")
+	}
+	if _, err := NewManager(config.AuthConfig{JWTAlgorithm: "none"}); err == nil {
+		t.Error("NewManager() expected error for unsupported algorithm")
+	}
+}
+
+func rsaConfig(t *testing.T) config.AuthConfig {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %v", err)
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("failed to marshal public key: %v", err)
+	}
+
+	dir := t.TempDir()
+	privatePath := filepath.Join(dir, "private.pem")
+	publicPath := filepath.Join(dir, "public.pem")
+	os.WriteFile(privatePath, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}), 0600)
+	os.WriteFile(publicPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}), 0644)
+
+	return config.AuthConfig{
+		JWTAlgorithm:      "RS256",
+		JWTPrivateKeyPath: privatePath,
+		JWTPublicKeyPath:  publicPath,
+		JWTIssuer:         "test",
+		AccessTokenTTL:    time.Minute,
+	}
+}
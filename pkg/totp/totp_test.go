@@ -0,0 +1,97 @@
+package totp
+
+import (
+	"bytes"
+	"encoding/base32"
+	"image/png"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 test key of RFC 6238 appendix B
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestCodeMatchesRFC6238(t *testing.T) {
+	// The RFC lists 8 digit codes, these are their last 6 digits
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tt := range tests {
+		got, err := Code(rfcSecret, Step(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatalf("Code(%d) error = %v", tt.unix, err)
+		}
+		if got != tt.want {
+			t.Errorf("Code(%d) = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("GenerateSecret() error = %v", err)
+	}
+	now := time.Unix(1700000000, 0)
+
+	previous, _ := Code(secret, Step(now)-1)
+	if step, ok := Validate(secret, previous, now, 1); !ok || step != Step(now)-1 {
+		t.Errorf("Validate(previous step) = %d, %v, want %d, true", step, ok, Step(now)-1)
+	}
+
+	stale, _ := Code(secret, Step(now)-2)
+	if _, ok := Validate(secret, stale, now, 1); ok {
+		t.Error("Validate() accepted a code outside the skew window")
+	}
+
+	for _, code := range []string{"", "12345", "1234567", "abcdef"} {
+		if _, ok := Validate(secret, code, now, 1); ok {
+			t.Errorf("Validate(%q) = true, want false", code)
+		}
+	}
+
+	if _, ok := Validate("not base32!", "123456", now, 1); ok {
+		t.Error("Validate() accepted an invalid secret")
+	}
+}
+
+func TestURIAndQRCode(t *testing.T) {
+	uri := URI("User API", "jane@example.com", "JBSWY3DPEHPK3PXP")
+
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		t.Fatalf("url.Parse() error = %v", err)
+	}
+	if parsed.Scheme != "otpauth" || parsed.Host != "totp" {
+		t.Errorf("URI() = %s, want otpauth://totp/...", uri)
+	}
+	if !strings.HasSuffix(parsed.Path, "User API:jane@example.com") {
+		t.Errorf("URI() label = %s", parsed.Path)
+	}
+	if got := parsed.Query().Get("secret"); got != "JBSWY3DPEHPK3PXP" {
+		t.Errorf("URI() secret = %s", got)
+	}
+
+	image, err := QRCode(uri, 200)
+	if err != nil {
+		t.Fatalf("QRCode() error = %v", err)
+	}
+	decoded, err := png.Decode(bytes.NewReader(image))
+	if err != nil {
+		t.Fatalf("QRCode() is not a PNG: %v", err)
+	}
+	if bounds := decoded.Bounds(); bounds.Dx() != 200 || bounds.Dy() != 200 {
+		t.Errorf("QRCode() size = %v, want 200x200", bounds)
+	}
+}
@@ -0,0 +1,104 @@
+// Package useragent derives a coarse device description from a User-Agent
+// header, good enough to tell a user's sessions apart
+package useragent
+
+import "strings"
+
+// Device types
+const (
+	TypeDesktop = "desktop"
+	TypeMobile  = "mobile"
+	TypeTablet  = "tablet"
+	TypeBot     = "bot"
+	TypeOther   = "other"
+)
+
+// Unknown is reported for a browser or OS that is not recognised
+const Unknown = "Unknown"
+
+// Device describes the client that sent a request
+type Device struct {
+	Browser string
+	OS      string
+	Type    string
+}
+
+// match maps a User-Agent token to the name it is reported as. Lists are
+// ordered so that more specific tokens win, e.g. Edge and Opera also
+// announce themselves as Chrome and Chrome also announces Safari.
+type match struct {
+	token string
+	name  string
+}
+
+var browsers = []match{
+	{"edg/", "Edge"},
+	{"edga/", "Edge"},
+	{"edgios/", "Edge"},
+	{"opr/", "Opera"},
+	{"samsungbrowser/", "Samsung Internet"},
+	{"firefox/", "Firefox"},
+	{"fxios/", "Firefox"},
+	{"crios/", "Chrome"},
+	{"chrome/", "Chrome"},
+	{"chromium/", "Chromium"},
+	{"safari/", "Safari"},
+	{"curl/", "curl"},
+	{"postmanruntime/", "Postman"},
+	{"okhttp/", "OkHttp"},
+	{"go-http-client/", "Go"},
+	{"python-requests/", "Python"},
+}
+
+var systems = []match{
+	{"windows", "Windows"},
+	{"iphone", "iOS"},
+	{"ipad", "iPadOS"},
+	{"android", "Android"},
+	{"cros", "ChromeOS"},
+	{"mac os x", "macOS"},
+	{"macintosh", "macOS"},
+	{"linux", "Linux"},
+}
+
+var bots = []string{"bot", "crawler", "spider", "slurp"}
+
+// Parse describes the device that sent the User-Agent header
+func Parse(header string) Device {
+	ua := strings.ToLower(header)
+	device := Device{Browser: lookup(browsers, ua), OS: lookup(systems, ua)}
+
+	switch {
+	case containsAny(ua, bots...):
+		device.Type = TypeBot
+	case containsAny(ua, "ipad", "tablet") || (strings.Contains(ua, "android") && !strings.Contains(ua, "mobile")):
+		device.Type = TypeTablet
+	case containsAny(ua, "mobile", "iphone", "ipod"):
+		device.Type = TypeMobile
+	case device.OS == "Windows" || device.OS == "macOS" || device.OS == "Linux" || device.OS == "ChromeOS":
+		device.Type = TypeDesktop
+	default:
+		device.Type = TypeOther
+	}
+	return device
+}
+
+// lookup returns the name of the first token found in the User-Agent
+func lookup(matches []match, ua string) string {
+	for _, m := range matches {
+		if strings.Contains(ua, m.token) {
+			return m.name
+		}
+	}
+	return Unknown
+}
+
+// containsAny reports whether the User-Agent contains any of the tokens
+func containsAny(ua string, tokens ...string) bool {
+	for _, token := range tokens {
+		if strings.Contains(ua, token) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,53 @@
+package useragent
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		header string
+		want   Device
+	}{
+		{
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36 Edg/126.0.0.0",
+			Device{Browser: "Edge", OS: "Windows", Type: TypeDesktop},
+		},
+		{
+			"Mozilla/5.0 (Macintosh; Intel Mac OS X 14_5) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.5 Safari/605.1.15",
+			Device{Browser: "Safari", OS: "macOS", Type: TypeDesktop},
+		},
+		{
+			"Mozilla/5.0 (X11; Linux x86_64; rv:127.0) Gecko/20100101 Firefox/127.0",
+			Device{Browser: "Firefox", OS: "Linux", Type: TypeDesktop},
+		},
+		{
+			"Mozilla/5.0 (iPhone; CPU iPhone OS 17_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) CriOS/126.0.6478.54 Mobile/15E148 Safari/604.1",
+			Device{Browser: "Chrome", OS: "iOS", Type: TypeMobile},
+		},
+		{
+			"Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Mobile Safari/537.36",
+			Device{Browser: "Chrome", OS: "Android", Type: TypeMobile},
+		},
+		{
+			"Mozilla/5.0 (Linux; Android 13; SM-X700) AppleWebKit/537.36 (KHTML, like Gecko) SamsungBrowser/25.0 Chrome/121.0.0.0 Safari/537.36",
+			Device{Browser: "Samsung Internet", OS: "Android", Type: TypeTablet},
+		},
+		{
+			"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			Device{Browser: Unknown, OS: Unknown, Type: TypeBot},
+		},
+		{
+			"curl/8.6.0",
+			Device{Browser: "curl", OS: Unknown, Type: TypeOther},
+		},
+		{
+			"",
+			Device{Browser: Unknown, OS: Unknown, Type: TypeOther},
+		},
+	}
+
+	for _, tt := range tests {
+		if got := Parse(tt.header); got != tt.want {
+			t.Errorf("Parse(%q) = %+v, want %+v", tt.header, got, tt.want)
+		}
+	}
+}
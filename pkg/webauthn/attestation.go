@@ -0,0 +1,124 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/asn1"
+	"fmt"
+)
+
+// Attestation statement formats
+const (
+	FormatNone   = "none"
+	FormatPacked = "packed"
+)
+
+// attestationOU is the organizational unit packed attestation certificates must name
+const attestationOU = "Authenticator Attestation"
+
+// oidFIDOAAGUID is the certificate extension carrying the authenticator's AAGUID
+var oidFIDOAAGUID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 45724, 1, 1, 4}
+
+// x509Algorithms maps COSE algorithms to the certificate signature algorithm verifying them
+var x509Algorithms = map[int]x509.SignatureAlgorithm{
+	AlgES256: x509.ECDSAWithSHA256,
+	AlgEdDSA: x509.PureEd25519,
+	AlgRS256: x509.SHA256WithRSA,
+}
+
+// verifyStatement verifies the attestation statement over the authenticator
+// data and client data hash and returns the attestation type
+func verifyStatement(format string, statement map[interface{}]interface{}, signed []byte, authData *AuthenticatorData, credentialKey *PublicKey) (string, error) {
+	switch format {
+	case FormatNone:
+		if len(statement) != 0 {
+			return "", fmt.Errorf("%w: none attestation with a statement", ErrInvalidAttestation)
+		}
+		return AttestationNone, nil
+	case FormatPacked:
+		return verifyPacked(statement, signed, authData, credentialKey)
+	}
+	return "", fmt.Errorf("%w: %s", ErrUnsupportedFormat, format)
+}
+
+// verifyPacked verifies a packed attestation statement (WebAuthn §8.2), signed
+// either by an attestation certificate or by the credential key itself
+func verifyPacked(statement map[interface{}]interface{}, signed []byte, authData *AuthenticatorData, credentialKey *PublicKey) (string, error) {
+	alg, ok := statement["alg"].(int64)
+	if !ok {
+		return "", fmt.Errorf("%w: packed statement without alg", ErrInvalidAttestation)
+	}
+	signature, ok := statement["sig"].([]byte)
+	if !ok {
+		return "", fmt.Errorf("%w: packed statement without sig", ErrInvalidAttestation)
+	}
+
+	chain, hasChain := statement["x5c"].([]interface{})
+	if !hasChain {
+		if _, present := statement["x5c"]; present {
+			return "", fmt.Errorf("%w: x5c is not an array", ErrInvalidAttestation)
+		}
+		if int(alg) != credentialKey.Algorithm {
+			return "", fmt.Errorf("%w: self attestation alg %d does not match the credential key", ErrInvalidAttestation, alg)
+		}
+		if err := credentialKey.Verify(signed, signature); err != nil {
+			return "", fmt.Errorf("%w: %v", ErrInvalidAttestation, err)
+		}
+		return AttestationSelf, nil
+	}
+
+	if len(chain) == 0 {
+		return "", fmt.Errorf("%w: empty x5c", ErrInvalidAttestation)
+	}
+	der, ok := chain[0].([]byte)
+	if !ok {
+		return "", fmt.Errorf("%w: x5c entry is not a certificate", ErrInvalidAttestation)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidAttestation, err)
+	}
+	algorithm, ok := x509Algorithms[int(alg)]
+	if !ok {
+		return "", fmt.Errorf("%w: %d", ErrUnsupportedAlgorithm, alg)
+	}
+	if err := cert.CheckSignature(algorithm, signed, signature); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidAttestation, err)
+	}
+	if err := checkAttestationCertificate(cert, authData.AAGUID); err != nil {
+		return "", err
+	}
+	return AttestationBasic, nil
+}
+
+// checkAttestationCertificate applies the packed attestation certificate requirements of WebAuthn §8.2.1
+func checkAttestationCertificate(cert *x509.Certificate, aaguid []byte) error {
+	if cert.Version != 3 {
+		return fmt.Errorf("%w: attestation certificate is not X.509 v3", ErrInvalidAttestation)
+	}
+	subject := cert.Subject
+	if len(subject.Country) == 0 || len(subject.Organization) == 0 || subject.CommonName == "" ||
+		len(subject.OrganizationalUnit) != 1 || subject.OrganizationalUnit[0] != attestationOU {
+		return fmt.Errorf("%w: attestation certificate subject does not meet the packed format requirements", ErrInvalidAttestation)
+	}
+	if cert.IsCA {
+		return fmt.Errorf("%w: attestation certificate is a CA", ErrInvalidAttestation)
+	}
+
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(oidFIDOAAGUID) {
+			continue
+		}
+		if ext.Critical {
+			return fmt.Errorf("%w: AAGUID extension is marked critical", ErrInvalidAttestation)
+		}
+		var certAAGUID []byte
+		if rest, err := asn1.Unmarshal(ext.Value, &certAAGUID); err != nil || len(rest) != 0 {
+			return fmt.Errorf("%w: malformed AAGUID extension", ErrInvalidAttestation)
+		}
+		if !bytes.Equal(certAAGUID, aaguid) {
+			return fmt.Errorf("%w: certificate AAGUID does not match the authenticator data", ErrInvalidAttestation)
+		}
+	}
+	return nil
+}
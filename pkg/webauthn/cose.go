@@ -0,0 +1,153 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+
+	"UserRESTfulApi/pkg/cbor"
+)
+
+// COSE algorithm identifiers (RFC 9053) of the supported credential keys
+const (
+	AlgES256 = -7
+	AlgEdDSA = -8
+	AlgRS256 = -257
+)
+
+// SupportedAlgorithms lists the accepted algorithms in order of preference
+var SupportedAlgorithms = []int{AlgES256, AlgEdDSA, AlgRS256}
+
+// COSE_Key parameters and values
+const (
+	coseKty = 1
+	coseAlg = 3
+	coseCrv = -1 // EC2 and OKP curve; the RSA modulus n uses the same label
+	coseX   = -2 // EC2 and OKP x coordinate; the RSA exponent e uses the same label
+	coseY   = -3
+
+	ktyOKP = 1
+	ktyEC2 = 2
+	ktyRSA = 3
+
+	crvP256    = 1
+	crvEd25519 = 6
+)
+
+// minRSABits is the smallest RSA modulus accepted for RS256 keys
+const minRSABits = 2048
+
+// PublicKey is a credential public key decoded from its COSE_Key encoding
+type PublicKey struct {
+	Algorithm int
+	Key       crypto.PublicKey
+}
+
+// ParsePublicKey decodes a COSE_Key holding an ES256, EdDSA or RS256 public key
+func ParsePublicKey(coseKey []byte) (*PublicKey, error) {
+	v, err := cbor.Unmarshal(coseKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPublicKey, err)
+	}
+	return publicKeyFromMap(v)
+}
+
+func publicKeyFromMap(v interface{}) (*PublicKey, error) {
+	m, ok := v.(map[interface{}]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: not a map", ErrInvalidPublicKey)
+	}
+	kty, _ := m[int64(coseKty)].(int64)
+	alg, _ := m[int64(coseAlg)].(int64)
+
+	switch {
+	case kty == ktyEC2 && alg == AlgES256:
+		crv, _ := m[int64(coseCrv)].(int64)
+		x, _ := m[int64(coseX)].([]byte)
+		y, _ := m[int64(coseY)].([]byte)
+		if crv != crvP256 || len(x) != 32 || len(y) != 32 {
+			return nil, fmt.Errorf("%w: ES256 key is not an uncompressed P-256 point", ErrInvalidPublicKey)
+		}
+		point := append(append([]byte{0x04}, x...), y...)
+		// ecdh rejects points that are not on the curve
+		if _, err := ecdh.P256().NewPublicKey(point); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPublicKey, err)
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		return &PublicKey{Algorithm: AlgES256, Key: key}, nil
+	case kty == ktyOKP && alg == AlgEdDSA:
+		crv, _ := m[int64(coseCrv)].(int64)
+		x, _ := m[int64(coseX)].([]byte)
+		if crv != crvEd25519 || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("%w: EdDSA key is not an Ed25519 key", ErrInvalidPublicKey)
+		}
+		return &PublicKey{Algorithm: AlgEdDSA, Key: ed25519.PublicKey(x)}, nil
+	case kty == ktyRSA && alg == AlgRS256:
+		n, _ := m[int64(coseCrv)].([]byte)
+		e, _ := m[int64(coseX)].([]byte)
+		modulus := new(big.Int).SetBytes(n)
+		exponent := new(big.Int).SetBytes(e)
+		if modulus.BitLen() < minRSABits || !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("%w: RS256 key is too small or has an invalid exponent", ErrInvalidPublicKey)
+		}
+		return &PublicKey{Algorithm: AlgRS256, Key: &rsa.PublicKey{N: modulus, E: int(exponent.Int64())}}, nil
+	}
+	return nil, fmt.Errorf("%w: key type %d with algorithm %d", ErrUnsupportedAlgorithm, kty, alg)
+}
+
+// Verify checks a signature over data made with the key's algorithm
+func (k *PublicKey) Verify(data, signature []byte) error {
+	var ok bool
+	switch key := k.Key.(type) {
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(data)
+		ok = ecdsa.VerifyASN1(key, digest[:], signature)
+	case ed25519.PublicKey:
+		ok = ed25519.Verify(key, data, signature)
+	case *rsa.PublicKey:
+		digest := sha256.Sum256(data)
+		ok = rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil
+	}
+	if !ok {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// EncodePublicKey returns the COSE_Key encoding of an ECDSA P-256, Ed25519 or RSA public key
+func EncodePublicKey(key crypto.PublicKey) ([]byte, error) {
+	switch key := key.(type) {
+	case *ecdsa.PublicKey:
+		if key.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("%w: curve %s", ErrUnsupportedAlgorithm, key.Curve.Params().Name)
+		}
+		return cbor.Marshal(map[interface{}]interface{}{
+			coseKty: ktyEC2,
+			coseAlg: AlgES256,
+			coseCrv: crvP256,
+			coseX:   key.X.FillBytes(make([]byte, 32)),
+			coseY:   key.Y.FillBytes(make([]byte, 32)),
+		})
+	case ed25519.PublicKey:
+		return cbor.Marshal(map[interface{}]interface{}{
+			coseKty: ktyOKP,
+			coseAlg: AlgEdDSA,
+			coseCrv: crvEd25519,
+			coseX:   []byte(key),
+		})
+	case *rsa.PublicKey:
+		return cbor.Marshal(map[interface{}]interface{}{
+			coseKty: ktyRSA,
+			coseAlg: AlgRS256,
+			coseCrv: key.N.Bytes(),
+			coseX:   big.NewInt(int64(key.E)).Bytes(),
+		})
+	}
+	return nil, fmt.Errorf("%w: key of type %T", ErrUnsupportedAlgorithm, key)
+}
@@ -0,0 +1,313 @@
+// Package webauthn verifies the registration and authentication ceremonies of
+// Web Authentication (W3C WebAuthn Level 2) for a relying party. It supports
+// the "none" and "packed" attestation formats and ES256, EdDSA and RS256
+// credential keys.
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"UserRESTfulApi/pkg/cbor"
+)
+
+// challengeBytes is the randomness of a ceremony challenge
+const challengeBytes = 32
+
+// Types of collected client data
+const (
+	TypeCreate = "webauthn.create"
+	TypeGet    = "webauthn.get"
+)
+
+// Authenticator data flags
+const (
+	FlagUserPresent            = 0x01
+	FlagUserVerified           = 0x04
+	FlagBackupEligible         = 0x08
+	FlagBackupState            = 0x10
+	FlagAttestedCredentialData = 0x40
+	FlagExtensionData          = 0x80
+)
+
+// Attestation types reported for registered credentials
+const (
+	AttestationNone  = "none"  // the authenticator made no statement
+	AttestationSelf  = "self"  // packed, signed with the credential key itself
+	AttestationBasic = "basic" // packed, signed with an attestation certificate
+)
+
+var (
+	ErrInvalidClientData    = errors.New("invalid client data")
+	ErrChallengeMismatch    = errors.New("challenge does not match")
+	ErrOriginMismatch       = errors.New("origin is not allowed")
+	ErrInvalidAuthData      = errors.New("invalid authenticator data")
+	ErrRPIDMismatch         = errors.New("relying party ID hash does not match")
+	ErrUserNotPresent       = errors.New("user presence was not confirmed")
+	ErrUserNotVerified      = errors.New("user verification is required")
+	ErrInvalidAttestation   = errors.New("invalid attestation")
+	ErrUnsupportedFormat    = errors.New("unsupported attestation format")
+	ErrInvalidPublicKey     = errors.New("invalid credential public key")
+	ErrUnsupportedAlgorithm = errors.New("unsupported credential algorithm")
+	ErrInvalidSignature     = errors.New("invalid signature")
+	ErrSignCountRegressed   = errors.New("signature counter did not increase, the authenticator may be cloned")
+)
+
+// encoding is the unpadded base64url encoding WebAuthn uses for binary values in JSON
+var encoding = base64.RawURLEncoding
+
+// NewChallenge returns a random ceremony challenge
+func NewChallenge() ([]byte, error) {
+	b := make([]byte, challengeBytes)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// EncodeBase64 encodes binary values the way WebAuthn JSON does
+func EncodeBase64(b []byte) string {
+	return encoding.EncodeToString(b)
+}
+
+// DecodeBase64 decodes a base64url value, with or without padding
+func DecodeBase64(s string) ([]byte, error) {
+	return encoding.DecodeString(string(bytes.TrimRight([]byte(s), "=")))
+}
+
+// ClientData is the collected client data the browser signs over
+type ClientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin,omitempty"`
+}
+
+// ParseClientData decodes clientDataJSON and its challenge
+func ParseClientData(clientDataJSON []byte) (*ClientData, []byte, error) {
+	var clientData ClientData
+	if err := json.Unmarshal(clientDataJSON, &clientData); err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidClientData, err)
+	}
+	challenge, err := DecodeBase64(clientData.Challenge)
+	if err != nil || len(challenge) == 0 {
+		return nil, nil, fmt.Errorf("%w: malformed challenge", ErrInvalidClientData)
+	}
+	return &clientData, challenge, nil
+}
+
+// AuthenticatorData is the authenticator's signed statement about a ceremony
+type AuthenticatorData struct {
+	RPIDHash  []byte
+	Flags     byte
+	SignCount uint32
+
+	// Set during registration, when FlagAttestedCredentialData is set
+	AAGUID       []byte
+	CredentialID []byte
+	PublicKey    []byte // COSE_Key
+}
+
+// ParseAuthenticatorData decodes authenticator data
+func ParseAuthenticatorData(data []byte) (*AuthenticatorData, error) {
+	if len(data) < 37 {
+		return nil, fmt.Errorf("%w: %d bytes is too short", ErrInvalidAuthData, len(data))
+	}
+	authData := &AuthenticatorData{
+		RPIDHash:  data[:32],
+		Flags:     data[32],
+		SignCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+	rest := data[37:]
+
+	if authData.Flags&FlagAttestedCredentialData != 0 {
+		if len(rest) < 18 {
+			return nil, fmt.Errorf("%w: truncated attested credential data", ErrInvalidAuthData)
+		}
+		authData.AAGUID = rest[:16]
+		idLength := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if idLength == 0 || idLength > 1023 || len(rest) < idLength {
+			return nil, fmt.Errorf("%w: invalid credential ID length", ErrInvalidAuthData)
+		}
+		authData.CredentialID = rest[:idLength]
+		rest = rest[idLength:]
+
+		_, after, err := cbor.Decode(rest)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPublicKey, err)
+		}
+		authData.PublicKey = rest[:len(rest)-len(after)]
+		rest = after
+	}
+
+	if authData.Flags&FlagExtensionData != 0 {
+		_, after, err := cbor.Decode(rest)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid extensions: %v", ErrInvalidAuthData, err)
+		}
+		rest = after
+	}
+	if len(rest) != 0 {
+		return nil, fmt.Errorf("%w: trailing data", ErrInvalidAuthData)
+	}
+	return authData, nil
+}
+
+// UserVerified reports whether the authenticator verified the user, with a PIN or biometric
+func (d *AuthenticatorData) UserVerified() bool {
+	return d.Flags&FlagUserVerified != 0
+}
+
+// RelyingParty verifies ceremonies for a relying party ID and its web origins
+type RelyingParty struct {
+	ID      string   // effective domain, e.g. "example.com"
+	Origins []string // accepted origins, e.g. "https://app.example.com"
+}
+
+// Credential is a newly registered credential
+type Credential struct {
+	ID              []byte
+	PublicKey       []byte // COSE_Key
+	Algorithm       int
+	SignCount       uint32
+	AAGUID          []byte
+	AttestationType string
+	UserVerified    bool
+	BackupEligible  bool
+}
+
+// Assertion is the verified outcome of an authentication ceremony
+type Assertion struct {
+	SignCount    uint32
+	UserVerified bool
+}
+
+// VerifyRegistration checks the response of navigator.credentials.create()
+// against the challenge the ceremony was started with and returns the new
+// credential. Attestation certificates are checked for the packed format's
+// requirements, but are not chained to a trust anchor, so the result proves
+// which key the authenticator holds, not which make of authenticator it is.
+func (rp *RelyingParty) VerifyRegistration(challenge, clientDataJSON, attestationObject []byte, requireUserVerification bool) (*Credential, error) {
+	if err := rp.verifyClientData(TypeCreate, challenge, clientDataJSON); err != nil {
+		return nil, err
+	}
+
+	v, err := cbor.Unmarshal(attestationObject)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidAttestation, err)
+	}
+	object, _ := v.(map[interface{}]interface{})
+	format, _ := object["fmt"].(string)
+	rawAuthData, _ := object["authData"].([]byte)
+	statement, ok := object["attStmt"].(map[interface{}]interface{})
+	if format == "" || rawAuthData == nil || !ok {
+		return nil, fmt.Errorf("%w: missing fmt, authData or attStmt", ErrInvalidAttestation)
+	}
+
+	authData, err := rp.verifyAuthenticatorData(rawAuthData, requireUserVerification)
+	if err != nil {
+		return nil, err
+	}
+	if authData.CredentialID == nil {
+		return nil, fmt.Errorf("%w: no attested credential data", ErrInvalidAuthData)
+	}
+	publicKey, err := ParsePublicKey(authData.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signed := append(append([]byte{}, rawAuthData...), clientDataHash[:]...)
+	attestationType, err := verifyStatement(format, statement, signed, authData, publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Credential{
+		ID:              authData.CredentialID,
+		PublicKey:       authData.PublicKey,
+		Algorithm:       publicKey.Algorithm,
+		SignCount:       authData.SignCount,
+		AAGUID:          authData.AAGUID,
+		AttestationType: attestationType,
+		UserVerified:    authData.UserVerified(),
+		BackupEligible:  authData.Flags&FlagBackupEligible != 0,
+	}, nil
+}
+
+// VerifyAssertion checks the response of navigator.credentials.get() against
+// the challenge, the stored credential key and its last signature counter.
+// A counter that did not increase means the credential was copied to another
+// authenticator, unless the authenticator does not count at all.
+func (rp *RelyingParty) VerifyAssertion(challenge, coseKey []byte, storedSignCount uint32, clientDataJSON, authenticatorData, signature []byte, requireUserVerification bool) (*Assertion, error) {
+	if err := rp.verifyClientData(TypeGet, challenge, clientDataJSON); err != nil {
+		return nil, err
+	}
+	authData, err := rp.verifyAuthenticatorData(authenticatorData, requireUserVerification)
+	if err != nil {
+		return nil, err
+	}
+
+	publicKey, err := ParsePublicKey(coseKey)
+	if err != nil {
+		return nil, err
+	}
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signed := append(append([]byte{}, authenticatorData...), clientDataHash[:]...)
+	if err := publicKey.Verify(signed, signature); err != nil {
+		return nil, err
+	}
+
+	if (authData.SignCount != 0 || storedSignCount != 0) && authData.SignCount <= storedSignCount {
+		return nil, ErrSignCountRegressed
+	}
+	return &Assertion{SignCount: authData.SignCount, UserVerified: authData.UserVerified()}, nil
+}
+
+func (rp *RelyingParty) verifyClientData(ceremony string, challenge, clientDataJSON []byte) error {
+	clientData, signedChallenge, err := ParseClientData(clientDataJSON)
+	if err != nil {
+		return err
+	}
+	if clientData.Type != ceremony {
+		return fmt.Errorf("%w: type %q, want %q", ErrInvalidClientData, clientData.Type, ceremony)
+	}
+	if subtle.ConstantTimeCompare(signedChallenge, challenge) != 1 {
+		return ErrChallengeMismatch
+	}
+	if clientData.CrossOrigin {
+		return fmt.Errorf("%w: cross-origin ceremonies are not accepted", ErrOriginMismatch)
+	}
+	for _, origin := range rp.Origins {
+		if clientData.Origin == origin {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrOriginMismatch, clientData.Origin)
+}
+
+func (rp *RelyingParty) verifyAuthenticatorData(raw []byte, requireUserVerification bool) (*AuthenticatorData, error) {
+	authData, err := ParseAuthenticatorData(raw)
+	if err != nil {
+		return nil, err
+	}
+	rpIDHash := sha256.Sum256([]byte(rp.ID))
+	if subtle.ConstantTimeCompare(authData.RPIDHash, rpIDHash[:]) != 1 {
+		return nil, ErrRPIDMismatch
+	}
+	if authData.Flags&FlagUserPresent == 0 {
+		return nil, ErrUserNotPresent
+	}
+	if requireUserVerification && !authData.UserVerified() {
+		return nil, ErrUserNotVerified
+	}
+	return authData, nil
+}
@@ -0,0 +1,208 @@
+package webauthn_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"testing"
+
+	"UserRESTfulApi/pkg/webauthn"
+	"UserRESTfulApi/pkg/webauthn/webauthntest"
+)
+
+const (
+	testRPID   = "example.com"
+	testOrigin = "https://app.example.com"
+)
+
+var testRP = &webauthn.RelyingParty{ID: testRPID, Origins: []string{testOrigin}}
+
+func newAuthenticator(t *testing.T) *webauthntest.Authenticator {
+	t.Helper()
+	authenticator, err := webauthntest.New(testRPID, testOrigin)
+	if err != nil {
+		t.Fatalf("webauthntest.New() error = %v", err)
+	}
+	return authenticator
+}
+
+func newChallenge(t *testing.T) []byte {
+	t.Helper()
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		t.Fatalf("NewChallenge() error = %v", err)
+	}
+	return challenge
+}
+
+func decode(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := webauthn.DecodeBase64(s)
+	if err != nil {
+		t.Fatalf("DecodeBase64(%q) error = %v", s, err)
+	}
+	return b
+}
+
+// register runs a registration ceremony and returns the verified credential
+func register(t *testing.T, authenticator *webauthntest.Authenticator, format string, selfAttest bool) *webauthn.Credential {
+	t.Helper()
+	challenge := newChallenge(t)
+	attestation, err := authenticator.Register(webauthn.EncodeBase64(challenge), webauthn.EncodeBase64([]byte("1")), format, selfAttest)
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	credential, err := testRP.VerifyRegistration(challenge, decode(t, attestation.Response.ClientDataJSON), decode(t, attestation.Response.AttestationObject), true)
+	if err != nil {
+		t.Fatalf("VerifyRegistration(%s) error = %v", format, err)
+	}
+	return credential
+}
+
+func TestVerifyRegistration(t *testing.T) {
+	tests := []struct {
+		format     string
+		selfAttest bool
+		want       string
+	}{
+		{webauthn.FormatNone, false, webauthn.AttestationNone},
+		{webauthn.FormatPacked, false, webauthn.AttestationBasic},
+		{webauthn.FormatPacked, true, webauthn.AttestationSelf},
+	}
+
+	for _, tt := range tests {
+		credential := register(t, newAuthenticator(t), tt.format, tt.selfAttest)
+		if credential.AttestationType != tt.want {
+			t.Errorf("AttestationType = %s, want %s", credential.AttestationType, tt.want)
+		}
+		if credential.Algorithm != webauthn.AlgES256 || len(credential.ID) == 0 || !credential.UserVerified {
+			t.Errorf("VerifyRegistration(%s) = %+v, want a user verified ES256 credential", tt.format, credential)
+		}
+		if string(credential.AAGUID) != string(webauthntest.AAGUID) {
+			t.Errorf("AAGUID = %q, want %q", credential.AAGUID, webauthntest.AAGUID)
+		}
+	}
+}
+
+func TestVerifyRegistrationRejectsMismatchedCeremonies(t *testing.T) {
+	authenticator := newAuthenticator(t)
+	challenge := newChallenge(t)
+	attestation, err := authenticator.Register(webauthn.EncodeBase64(challenge), webauthn.EncodeBase64([]byte("1")), webauthn.FormatPacked, false)
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	clientDataJSON := decode(t, attestation.Response.ClientDataJSON)
+	attestationObject := decode(t, attestation.Response.AttestationObject)
+
+	tests := []struct {
+		name      string
+		rp        *webauthn.RelyingParty
+		challenge []byte
+		want      error
+	}{
+		{"other challenge", testRP, newChallenge(t), webauthn.ErrChallengeMismatch},
+		{"other origin", &webauthn.RelyingParty{ID: testRPID, Origins: []string{"https://evil.example"}}, challenge, webauthn.ErrOriginMismatch},
+		{"other relying party", &webauthn.RelyingParty{ID: "evil.example", Origins: []string{testOrigin}}, challenge, webauthn.ErrRPIDMismatch},
+	}
+	for _, tt := range tests {
+		_, err := tt.rp.VerifyRegistration(tt.challenge, clientDataJSON, attestationObject, false)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: VerifyRegistration() error = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	// Tampering with the signed authenticator data breaks the attestation signature
+	tampered := append([]byte{}, attestationObject...)
+	tampered[len(tampered)-1] ^= 0xff
+	if _, err := testRP.VerifyRegistration(challenge, clientDataJSON, tampered, false); err == nil {
+		t.Error("VerifyRegistration(tampered) error = nil, want an error")
+	}
+}
+
+func TestVerifyRegistrationRequiresUserVerification(t *testing.T) {
+	authenticator := newAuthenticator(t)
+	authenticator.SkipUserVerification = true
+	challenge := newChallenge(t)
+	attestation, _ := authenticator.Register(webauthn.EncodeBase64(challenge), webauthn.EncodeBase64([]byte("1")), webauthn.FormatNone, false)
+
+	_, err := testRP.VerifyRegistration(challenge, decode(t, attestation.Response.ClientDataJSON), decode(t, attestation.Response.AttestationObject), true)
+	if !errors.Is(err, webauthn.ErrUserNotVerified) {
+		t.Errorf("VerifyRegistration() error = %v, want ErrUserNotVerified", err)
+	}
+}
+
+func TestVerifyAssertion(t *testing.T) {
+	authenticator := newAuthenticator(t)
+	credential := register(t, authenticator, webauthn.FormatNone, false)
+	credentialID := webauthn.EncodeBase64(credential.ID)
+
+	login := func(signCount uint32) (*webauthn.Assertion, error) {
+		challenge := newChallenge(t)
+		assertion, err := authenticator.Login(webauthn.EncodeBase64(challenge), credentialID)
+		if err != nil {
+			t.Fatalf("Login() error = %v", err)
+		}
+		return testRP.VerifyAssertion(challenge, credential.PublicKey, signCount,
+			decode(t, assertion.Response.ClientDataJSON), decode(t, assertion.Response.AuthenticatorData), decode(t, assertion.Response.Signature), true)
+	}
+
+	result, err := login(credential.SignCount)
+	if err != nil {
+		t.Fatalf("VerifyAssertion() error = %v", err)
+	}
+	if result.SignCount != 1 || !result.UserVerified {
+		t.Errorf("VerifyAssertion() = %+v, want counter 1 and user verified", result)
+	}
+
+	// A clone of the authenticator replays an old counter value
+	authenticator.SetSignCount(credentialID, 0)
+	if _, err := login(result.SignCount); !errors.Is(err, webauthn.ErrSignCountRegressed) {
+		t.Errorf("VerifyAssertion(cloned) error = %v, want ErrSignCountRegressed", err)
+	}
+}
+
+func TestVerifyAssertionRejectsForeignSignatures(t *testing.T) {
+	authenticator := newAuthenticator(t)
+	credential := register(t, authenticator, webauthn.FormatNone, false)
+	other := register(t, authenticator, webauthn.FormatNone, false)
+
+	challenge := newChallenge(t)
+	assertion, _ := authenticator.Login(webauthn.EncodeBase64(challenge), webauthn.EncodeBase64(other.ID))
+	_, err := testRP.VerifyAssertion(challenge, credential.PublicKey, 0,
+		decode(t, assertion.Response.ClientDataJSON), decode(t, assertion.Response.AuthenticatorData), decode(t, assertion.Response.Signature), false)
+	if !errors.Is(err, webauthn.ErrInvalidSignature) {
+		t.Errorf("VerifyAssertion() error = %v, want ErrInvalidSignature", err)
+	}
+}
+
+func TestPublicKeyRoundTrip(t *testing.T) {
+	edPublic, edPrivate, _ := ed25519.GenerateKey(rand.Reader)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+
+	encoded, err := webauthn.EncodePublicKey(edPublic)
+	if err != nil {
+		t.Fatalf("EncodePublicKey(Ed25519) error = %v", err)
+	}
+	key, err := webauthn.ParsePublicKey(encoded)
+	if err != nil || key.Algorithm != webauthn.AlgEdDSA {
+		t.Fatalf("ParsePublicKey(Ed25519) = %+v, %v, want an EdDSA key", key, err)
+	}
+	if err := key.Verify([]byte("data"), ed25519.Sign(edPrivate, []byte("data"))); err != nil {
+		t.Errorf("Verify(Ed25519) error = %v", err)
+	}
+
+	encoded, _ = webauthn.EncodePublicKey(&rsaKey.PublicKey)
+	if key, err := webauthn.ParsePublicKey(encoded); err != nil || key.Algorithm != webauthn.AlgRS256 {
+		t.Errorf("ParsePublicKey(RSA) = %+v, %v, want an RS256 key", key, err)
+	}
+
+	smallKey, _ := rsa.GenerateKey(rand.Reader, 1024)
+	encoded, _ = webauthn.EncodePublicKey(&smallKey.PublicKey)
+	if _, err := webauthn.ParsePublicKey(encoded); !errors.Is(err, webauthn.ErrInvalidPublicKey) {
+		t.Errorf("ParsePublicKey(RSA 1024) error = %v, want ErrInvalidPublicKey", err)
+	}
+}
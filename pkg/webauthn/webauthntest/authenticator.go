@@ -0,0 +1,248 @@
+// Package webauthntest provides a software authenticator that answers
+// WebAuthn ceremonies in memory, so registration and login can be tested
+// without a browser or security key.
+package webauthntest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"time"
+
+	"UserRESTfulApi/pkg/cbor"
+	"UserRESTfulApi/pkg/webauthn"
+)
+
+// AAGUID identifies the software authenticator in attested credential data
+var AAGUID = []byte("software-authn-1")
+
+// Attestation is a registration response in the JSON form of PublicKeyCredential
+type Attestation struct {
+	ID       string              `json:"id"`
+	RawID    string              `json:"rawId"`
+	Type     string              `json:"type"`
+	Response AttestationResponse `json:"response"`
+}
+
+// AttestationResponse is the AuthenticatorAttestationResponse of a registration
+type AttestationResponse struct {
+	ClientDataJSON    string `json:"clientDataJSON"`
+	AttestationObject string `json:"attestationObject"`
+}
+
+// Assertion is an authentication response in the JSON form of PublicKeyCredential
+type Assertion struct {
+	ID       string            `json:"id"`
+	RawID    string            `json:"rawId"`
+	Type     string            `json:"type"`
+	Response AssertionResponse `json:"response"`
+}
+
+// AssertionResponse is the AuthenticatorAssertionResponse of a login
+type AssertionResponse struct {
+	ClientDataJSON    string `json:"clientDataJSON"`
+	AuthenticatorData string `json:"authenticatorData"`
+	Signature         string `json:"signature"`
+	UserHandle        string `json:"userHandle,omitempty"`
+}
+
+// Authenticator is an in-memory authenticator holding ES256 credentials. Every
+// ceremony reports the user as present and, unless SkipUserVerification is
+// set, verified.
+type Authenticator struct {
+	RPID                 string
+	Origin               string
+	SkipUserVerification bool
+
+	credentials     map[string]*credential
+	attestationKey  *ecdsa.PrivateKey
+	attestationCert []byte
+}
+
+type credential struct {
+	id         []byte
+	key        *ecdsa.PrivateKey
+	userHandle []byte
+	signCount  uint32
+}
+
+// New creates an authenticator for the relying party ID, answering ceremonies
+// as if started from the origin
+func New(rpID, origin string) (*Authenticator, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	aaguidExt, err := asn1.Marshal(AAGUID)
+	if err != nil {
+		return nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject: pkix.Name{
+			Country:            []string{"US"},
+			Organization:       []string{"UserRESTfulApi"},
+			OrganizationalUnit: []string{"Authenticator Attestation"},
+			CommonName:         "Software Authenticator",
+		},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		BasicConstraintsValid: true,
+		ExtraExtensions:       []pkix.Extension{{Id: asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 45724, 1, 1, 4}, Value: aaguidExt}},
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Authenticator{
+		RPID:            rpID,
+		Origin:          origin,
+		credentials:     make(map[string]*credential),
+		attestationKey:  key,
+		attestationCert: cert,
+	}, nil
+}
+
+// Register creates a credential for the user handle in answer to the
+// base64url challenge of a registration ceremony. The format is "none" or
+// "packed"; packed statements are signed with the authenticator's
+// attestation certificate, or with the credential key when selfAttest is set.
+func (a *Authenticator) Register(challenge, userHandle, format string, selfAttest bool) (*Attestation, error) {
+	handle, err := webauthn.DecodeBase64(userHandle)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	cred := &credential{id: id, key: key, userHandle: handle}
+
+	clientDataJSON, err := a.clientData(webauthn.TypeCreate, challenge)
+	if err != nil {
+		return nil, err
+	}
+	coseKey, err := webauthn.EncodePublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	attested := append([]byte{}, AAGUID...)
+	attested = binary.BigEndian.AppendUint16(attested, uint16(len(id)))
+	attested = append(append(attested, id...), coseKey...)
+	authData := a.authenticatorData(webauthn.FlagAttestedCredentialData, cred.signCount, attested)
+
+	statement := map[interface{}]interface{}{}
+	switch format {
+	case webauthn.FormatNone:
+	case webauthn.FormatPacked:
+		signer, chain := a.attestationKey, []interface{}{a.attestationCert}
+		if selfAttest {
+			signer, chain = key, nil
+		}
+		signature, err := sign(signer, authData, clientDataJSON)
+		if err != nil {
+			return nil, err
+		}
+		statement["alg"] = webauthn.AlgES256
+		statement["sig"] = signature
+		if chain != nil {
+			statement["x5c"] = chain
+		}
+	default:
+		return nil, fmt.Errorf("unsupported attestation format %q", format)
+	}
+
+	attestationObject, err := cbor.Marshal(map[interface{}]interface{}{
+		"fmt":      format,
+		"authData": authData,
+		"attStmt":  statement,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	a.credentials[webauthn.EncodeBase64(id)] = cred
+	return &Attestation{
+		ID:    webauthn.EncodeBase64(id),
+		RawID: webauthn.EncodeBase64(id),
+		Type:  "public-key",
+		Response: AttestationResponse{
+			ClientDataJSON:    webauthn.EncodeBase64(clientDataJSON),
+			AttestationObject: webauthn.EncodeBase64(attestationObject),
+		},
+	}, nil
+}
+
+// Login signs the base64url challenge of an authentication ceremony with the
+// credential, incrementing its signature counter
+func (a *Authenticator) Login(challenge, credentialID string) (*Assertion, error) {
+	cred, ok := a.credentials[credentialID]
+	if !ok {
+		return nil, fmt.Errorf("no credential %s", credentialID)
+	}
+	clientDataJSON, err := a.clientData(webauthn.TypeGet, challenge)
+	if err != nil {
+		return nil, err
+	}
+	cred.signCount++
+	authData := a.authenticatorData(0, cred.signCount, nil)
+	signature, err := sign(cred.key, authData, clientDataJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Assertion{
+		ID:    credentialID,
+		RawID: credentialID,
+		Type:  "public-key",
+		Response: AssertionResponse{
+			ClientDataJSON:    webauthn.EncodeBase64(clientDataJSON),
+			AuthenticatorData: webauthn.EncodeBase64(authData),
+			Signature:         webauthn.EncodeBase64(signature),
+			UserHandle:        webauthn.EncodeBase64(cred.userHandle),
+		},
+	}, nil
+}
+
+// SetSignCount sets the signature counter of a credential, for simulating a
+// cloned authenticator
+func (a *Authenticator) SetSignCount(credentialID string, signCount uint32) {
+	if cred, ok := a.credentials[credentialID]; ok {
+		cred.signCount = signCount
+	}
+}
+
+func (a *Authenticator) clientData(ceremony, challenge string) ([]byte, error) {
+	return json.Marshal(webauthn.ClientData{Type: ceremony, Challenge: challenge, Origin: a.Origin})
+}
+
+func (a *Authenticator) authenticatorData(flags byte, signCount uint32, attested []byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(a.RPID))
+	flags |= webauthn.FlagUserPresent
+	if !a.SkipUserVerification {
+		flags |= webauthn.FlagUserVerified
+	}
+	data := append(rpIDHash[:], flags)
+	data = binary.BigEndian.AppendUint32(data, signCount)
+	return append(data, attested...)
+}
+
+// sign signs the authenticator data and client data hash the way authenticators do
+func sign(key *ecdsa.PrivateKey, authData, clientDataJSON []byte) ([]byte, error) {
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+	return ecdsa.SignASN1(rand.Reader, key, digest[:])
+}
@@ -0,0 +1,105 @@
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/handlers"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// requestWithAPIKey makes a request authenticated with an API key
+func requestWithAPIKey(method, path, key string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set("X-API-Key", key)
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestAPIKeys(t *testing.T) {
+	user := createTestUser(t)
+	userPath := "/api/users/" + strconv.FormatUint(uint64(user.ID), 10)
+
+	rr := makeRequestAs(t, http.MethodPost, "/api/api-keys", handlers.CreateAPIKeyRequest{
+		Name:   "Backup job",
+		Scopes: []domain.Permission{domain.PermissionUsersRead.Self()},
+	}, bearer(t, user))
+	require.Equal(t, http.StatusCreated, rr.Code)
+	var created struct {
+		APIKey domain.APIKey `json:"api_key"`
+		Key    string        `json:"key"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &created))
+	require.NotEmpty(t, created.Key)
+	assert.Equal(t, created.APIKey.Prefix, created.Key[:len(created.APIKey.Prefix)])
+	assert.NotContains(t, rr.Body.String(), "key_hash")
+
+	// The key acts as its owner, limited to its scopes
+	rr = requestWithAPIKey(http.MethodGet, userPath, created.Key)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, user.Email, jsonField(t, rr, "email"))
+	rr = requestWithAPIKey(http.MethodGet, "/api/api-keys", created.Key)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"last_used_ip":"192.0.2.1"`)
+
+	rr = requestWithAPIKey(http.MethodGet, "/api/auth/mfa", created.Key)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	rr = requestWithAPIKey(http.MethodGet, userPath, created.Key+"x")
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	// Users cannot grant scopes they do not hold or create service account keys
+	rr = makeRequestAs(t, http.MethodPost, "/api/api-keys", handlers.CreateAPIKeyRequest{
+		Name:   "Too much",
+		Scopes: []domain.Permission{domain.PermissionUsersList},
+	}, bearer(t, user))
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	rr = makeRequestAs(t, http.MethodPost, "/api/api-keys", handlers.CreateAPIKeyRequest{
+		Name:           "Service",
+		Scopes:         []domain.Permission{domain.PermissionUsersRead},
+		ServiceAccount: true,
+	}, bearer(t, user))
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	// A service account key holds exactly its scopes and honours the IP allowlist
+	rr = makeRequest(t, http.MethodPost, "/api/api-keys", handlers.CreateAPIKeyRequest{
+		Name:           "Directory sync",
+		Scopes:         []domain.Permission{domain.PermissionUsersList},
+		AllowedIPs:     []string{"192.0.2.0/24"},
+		ServiceAccount: true,
+	})
+	require.Equal(t, http.StatusCreated, rr.Code)
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &created))
+	assert.Nil(t, created.APIKey.UserID)
+
+	rr = requestWithAPIKey(http.MethodGet, "/api/users", created.Key)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	rr = requestWithAPIKey(http.MethodGet, userPath, created.Key)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	rr = makeRequest(t, http.MethodPost, "/api/api-keys", handlers.CreateAPIKeyRequest{
+		Name:           "Elsewhere",
+		Scopes:         []domain.Permission{domain.PermissionUsersList},
+		AllowedIPs:     []string{"203.0.113.7"},
+		ServiceAccount: true,
+	})
+	require.Equal(t, http.StatusCreated, rr.Code)
+	var elsewhere struct {
+		Key string `json:"key"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &elsewhere))
+	rr = requestWithAPIKey(http.MethodGet, "/api/users", elsewhere.Key)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	// Deleted keys stop working immediately
+	rr = makeRequest(t, http.MethodDelete, "/api/api-keys/"+strconv.FormatUint(uint64(created.APIKey.ID), 10), nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	rr = requestWithAPIKey(http.MethodGet, "/api/users", created.Key)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
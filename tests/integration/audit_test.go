@@ -0,0 +1,77 @@
+package integration
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/handlers"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// listAuditEvents lists the audit events matching the query as the test principal
+func listAuditEvents(t *testing.T, query string) []domain.AuditEvent {
+	rr := makeRequest(t, http.MethodGet, "/api/audit?"+query, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var events []domain.AuditEvent
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &events))
+	return events
+}
+
+func TestAuditLog(t *testing.T) {
+	user := createTestUser(t)
+	userID := strconv.FormatUint(uint64(user.ID), 10)
+
+	// The request ID forwarded by nginx is recorded and echoed
+	body, _ := json.Marshal(handlers.UpdateUserRequest{Email: user.Email, Name: "Audited User", Password: "Audited@123"})
+	req := httptest.NewRequest(http.MethodPut, "/api/users/"+userID, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", authHeader(t))
+	req.Header.Set("X-Request-ID", "audit-test-request")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "audit-test-request", rr.Header().Get("X-Request-ID"))
+
+	rr = makeRequestAs(t, http.MethodPost, "/api/auth/login", handlers.LoginRequest{Email: user.Email, Password: "Wrong@123"}, "")
+	require.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	events := listAuditEvents(t, "target_type=user&target_id="+userID)
+	require.Len(t, events, 2)
+	assert.Equal(t, domain.AuditUserUpdated, events[0].Action)
+	assert.Equal(t, domain.AuditUserCreated, events[1].Action)
+	assert.Equal(t, "audit-test-request", events[0].RequestID)
+	assert.Equal(t, ensureTestPrincipal(t).ID, *events[0].ActorID)
+	assert.Equal(t, "Audited User", events[0].Changes["name"].New)
+	assert.Equal(t, domain.AuditRedacted, events[0].Changes["password"].New)
+
+	// Failed logins for unknown credentials are recorded against the email
+	events = listAuditEvents(t, "action="+domain.AuditLoginFailed)
+	require.Len(t, events, 1)
+	assert.Equal(t, user.Email, events[0].Details["email"])
+	assert.Nil(t, events[0].ActorID)
+
+	events = listAuditEvents(t, "actor_id="+strconv.FormatUint(uint64(ensureTestPrincipal(t).ID), 10))
+	require.Len(t, events, 1)
+	assert.Empty(t, listAuditEvents(t, "from=2000-01-01T00:00:00Z&to=2000-01-02T00:00:00Z"))
+
+	rr = makeRequest(t, http.MethodGet, "/api/audit?from=yesterday", nil)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = makeRequest(t, http.MethodGet, "/api/audit/verify", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var verification domain.AuditVerification
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &verification))
+	assert.True(t, verification.Valid)
+	assert.Equal(t, int64(3), verification.Checked)
+
+	// Only auditors can read the log
+	rr = makeRequestAs(t, http.MethodGet, "/api/audit", nil, bearer(t, user))
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+}
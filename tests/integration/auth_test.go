@@ -0,0 +1,166 @@
+package integration
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"UserRESTfulApi/internal/handlers"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogin(t *testing.T) {
+	setupTest(t)
+	principal := ensureTestPrincipal(t)
+
+	t.Run("valid credentials", func(t *testing.T) {
+		rr := makeRequest(t, http.MethodPost, "/api/auth/login", map[string]string{
+			"email":    testPrincipalEmail,
+			"password": testPrincipalPassword,
+		})
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var response map[string]interface{}
+		err := json.Unmarshal(rr.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, "Bearer", response["token_type"])
+		assert.NotEmpty(t, response["access_token"])
+
+		// Use the issued token on a protected route
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/users/%d", principal.ID), nil)
+		req.Header.Set("Authorization", "Bearer "+response["access_token"].(string))
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("user created through the API", func(t *testing.T) {
+		rr := makeRequest(t, http.MethodPost, "/api/users", handlers.CreateUserRequest{
+			Email:    "login@example.com",
+			Password: "Test123!@#",
+			Name:     "Login User",
+		})
+		assert.Equal(t, http.StatusCreated, rr.Code)
+
+		rr = makeRequest(t, http.MethodPost, "/api/auth/login", map[string]string{
+			"email":    "login@example.com",
+			"password": "Test123!@#",
+		})
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+
+	t.Run("wrong password", func(t *testing.T) {
+		rr := makeRequest(t, http.MethodPost, "/api/auth/login", map[string]string{
+			"email":    testPrincipalEmail,
+			"password": "Wrong123!",
+		})
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	})
+
+	t.Run("unknown email", func(t *testing.T) {
+		rr := makeRequest(t, http.MethodPost, "/api/auth/login", map[string]string{
+			"email":    "nobody@example.com",
+			"password": testPrincipalPassword,
+		})
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	})
+
+	t.Run("missing token", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/users/%d", principal.ID), nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Header().Get("WWW-Authenticate"), "Bearer")
+	})
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	setupTest(t)
+	ensureTestPrincipal(t)
+
+	login := func() map[string]interface{} {
+		rr := makeRequest(t, http.MethodPost, "/api/auth/login", map[string]string{
+			"email":    testPrincipalEmail,
+			"password": testPrincipalPassword,
+		})
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var response map[string]interface{}
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+		assert.NotEmpty(t, response["refresh_token"])
+		return response
+	}
+	refresh := func(refreshToken interface{}) *httptest.ResponseRecorder {
+		return makeRequest(t, http.MethodPost, "/api/auth/refresh", map[string]interface{}{"refresh_token": refreshToken})
+	}
+
+	t.Run("rotation and reuse detection", func(t *testing.T) {
+		issued := login()
+
+		rr := refresh(issued["refresh_token"])
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var rotated map[string]interface{}
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &rotated))
+		assert.NotEqual(t, issued["refresh_token"], rotated["refresh_token"])
+
+		// Replaying the original token revokes the family, including the rotated token
+		assert.Equal(t, http.StatusUnauthorized, refresh(issued["refresh_token"]).Code)
+		assert.Equal(t, http.StatusUnauthorized, refresh(rotated["refresh_token"]).Code)
+	})
+
+	t.Run("logout", func(t *testing.T) {
+		issued := login()
+
+		rr := makeRequest(t, http.MethodPost, "/api/auth/logout", map[string]interface{}{"refresh_token": issued["refresh_token"]})
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, http.StatusUnauthorized, refresh(issued["refresh_token"]).Code)
+	})
+
+	t.Run("logout all", func(t *testing.T) {
+		first := login()
+		second := login()
+
+		rr := makeRequest(t, http.MethodPost, "/api/auth/logout-all", nil)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, http.StatusUnauthorized, refresh(first["refresh_token"]).Code)
+		assert.Equal(t, http.StatusUnauthorized, refresh(second["refresh_token"]).Code)
+	})
+}
+
+func TestLoginLockout(t *testing.T) {
+	user := createTestUser(t)
+
+	login := func(password string) *httptest.ResponseRecorder {
+		return makeRequest(t, http.MethodPost, "/api/auth/login", map[string]string{
+			"email":    user.Email,
+			"password": password,
+		})
+	}
+
+	t.Run("failed login delays the next attempt", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, login("Wrong123!").Code)
+
+		rr := login("Test@123")
+		assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+		assert.NotEmpty(t, rr.Header().Get("Retry-After"))
+	})
+
+	t.Run("admin can list and clear the lockout", func(t *testing.T) {
+		rr := makeRequest(t, http.MethodGet, "/api/lockouts", nil)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), user.Email)
+
+		rr = makeRequest(t, http.MethodDelete, "/api/lockouts/account/"+user.Email, nil)
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		assert.Equal(t, http.StatusOK, login("Test@123").Code)
+	})
+
+	t.Run("users cannot manage lockouts", func(t *testing.T) {
+		rr := makeRequestAs(t, http.MethodGet, "/api/lockouts", nil, bearer(t, user))
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+	})
+}
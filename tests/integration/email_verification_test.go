@@ -0,0 +1,84 @@
+package integration
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"UserRESTfulApi/internal/handlers"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// lastMailedToken returns the token of the most recent link mailed to the address
+func lastMailedToken(t *testing.T, email string) string {
+	messages, err := mail.Messages(email)
+	require.NoError(t, err)
+	require.NotEmpty(t, messages)
+	match := resetTokenPattern.FindStringSubmatch(messages[len(messages)-1])
+	require.NotNil(t, match)
+	value, err := url.QueryUnescape(match[1])
+	require.NoError(t, err)
+	return value
+}
+
+func TestEmailVerification(t *testing.T) {
+	user := createTestUser(t)
+
+	t.Run("signup link verifies the address", func(t *testing.T) {
+		verificationToken := lastMailedToken(t, user.Email)
+
+		rr := makeRequest(t, http.MethodGet, "/api/auth/verify-email?token="+url.QueryEscape(verificationToken), nil)
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), `"email_verified":true`)
+
+		rr = makeRequest(t, http.MethodGet, "/api/auth/verify-email?token="+url.QueryEscape(verificationToken), nil)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("email change stays pending until verified", func(t *testing.T) {
+		update := handlers.UpdateUserRequest{Email: "changed@example.com", Name: user.Name}
+		rr := makeRequestAs(t, http.MethodPut, fmt.Sprintf("/api/users/%d", user.ID), update, bearer(t, user))
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, user.Email, jsonField(t, rr, "email"))
+		assert.Equal(t, "changed@example.com", jsonField(t, rr, "pending_email"))
+
+		rr = makeRequest(t, http.MethodPost, "/api/auth/verify-email", handlers.VerifyEmailRequest{Token: lastMailedToken(t, "changed@example.com")})
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), `"email":"changed@example.com"`)
+		assert.NotContains(t, rr.Body.String(), "pending_email")
+	})
+
+	t.Run("revert link restores the old address", func(t *testing.T) {
+		revertToken := lastMailedToken(t, user.Email)
+
+		// Opening the link only asks for confirmation
+		rr := makeRequest(t, http.MethodGet, "/api/auth/email-change/revert?token="+url.QueryEscape(revertToken), nil)
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, http.MethodPost, jsonField(t, rr, "method"))
+		assert.Equal(t, revertToken, jsonField(t, rr, "token"))
+		assert.Equal(t, "no-store", rr.Header().Get("Cache-Control"))
+		rr = makeRequestAs(t, http.MethodGet, fmt.Sprintf("/api/users/%d", user.ID), nil, bearer(t, user))
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "changed@example.com", jsonField(t, rr, "email"))
+
+		rr = makeRequest(t, http.MethodPost, "/api/auth/email-change/revert", handlers.RevertEmailChangeRequest{Token: revertToken})
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), fmt.Sprintf(`"email":%q`, user.Email))
+		assert.Contains(t, rr.Body.String(), `"email_verified":true`)
+
+		rr = makeRequest(t, http.MethodPost, "/api/auth/email-change/revert", handlers.RevertEmailChangeRequest{Token: revertToken})
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("pending address is reserved", func(t *testing.T) {
+		update := handlers.UpdateUserRequest{Email: "reserved@example.com", Name: user.Name}
+		rr := makeRequestAs(t, http.MethodPut, fmt.Sprintf("/api/users/%d", user.ID), update, bearer(t, user))
+		require.Equal(t, http.StatusOK, rr.Code)
+
+		rr = makeRequest(t, http.MethodPost, "/api/users", handlers.CreateUserRequest{Email: "reserved@example.com", Password: "Test@123", Name: "Other User"})
+		assert.Equal(t, http.StatusConflict, rr.Code)
+	})
+}
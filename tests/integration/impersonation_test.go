@@ -0,0 +1,90 @@
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/handlers"
+	"UserRESTfulApi/internal/middleware"
+	"UserRESTfulApi/pkg/token"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sessionBearer returns an Authorization header value for a new session of
+// the user, signed in with a second factor
+func sessionBearer(t *testing.T, user *domain.User) string {
+	t.Helper()
+	sessionID, err := token.NewOpaque()
+	require.NoError(t, err)
+	now := time.Now()
+	require.NoError(t, db.Create(&domain.Session{ID: sessionID, UserID: user.ID, CreatedAt: now, LastSeenAt: now, ExpiresAt: now.Add(time.Hour)}).Error)
+
+	accessToken, _, err := tokens.Issue(user.ID, user.Email, sessionID, token.MethodPassword, token.MethodOTP, token.MethodMFA)
+	require.NoError(t, err)
+	return "Bearer " + accessToken
+}
+
+func TestImpersonation(t *testing.T) {
+	user := createTestUser(t)
+	admin := ensureTestPrincipal(t)
+	userPath := "/api/users/" + strconv.FormatUint(uint64(user.ID), 10)
+	adminID := strconv.FormatUint(uint64(admin.ID), 10)
+
+	rr := makeRequestAs(t, http.MethodPost, userPath+"/impersonate", handlers.ImpersonateRequest{Reason: "ticket 42"}, sessionBearer(t, admin))
+	require.Equal(t, http.StatusCreated, rr.Code)
+	impersonation := "Bearer " + jsonField(t, rr, "access_token")
+	assert.Empty(t, jsonField(t, rr, "refresh_token"))
+
+	t.Run("requests act as the user and carry the impersonator", func(t *testing.T) {
+		rr := makeRequestAs(t, http.MethodGet, userPath, nil, impersonation)
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, user.Email, jsonField(t, rr, "email"))
+		assert.Equal(t, adminID, rr.Header().Get(middleware.ImpersonatedByHeader))
+
+		rr = makeRequestAs(t, http.MethodGet, "/api/users/"+adminID, nil, impersonation)
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+	})
+
+	t.Run("credentials cannot be changed", func(t *testing.T) {
+		rr := makeRequestAs(t, http.MethodPut, userPath, handlers.UpdateUserRequest{Email: user.Email, Name: user.Name, Password: "Changed@123"}, impersonation)
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+		rr = makeRequestAs(t, http.MethodPost, "/api/auth/mfa/enroll", nil, impersonation)
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+		rr = makeRequestAs(t, http.MethodPost, "/api/auth/webauthn/register/begin", nil, impersonation)
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+		rr = makeRequestAs(t, http.MethodPost, "/api/api-keys", map[string]interface{}{"name": "key"}, impersonation)
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+	})
+
+	t.Run("changes are audited with the impersonator", func(t *testing.T) {
+		rr := makeRequestAs(t, http.MethodPut, userPath, handlers.UpdateUserRequest{Email: user.Email, Name: "Renamed By Support"}, impersonation)
+		require.Equal(t, http.StatusOK, rr.Code)
+
+		events := listAuditEvents(t, "impersonator_id="+adminID)
+		require.Len(t, events, 1)
+		assert.Equal(t, domain.AuditUserUpdated, events[0].Action)
+		require.NotNil(t, events[0].ActorID)
+		assert.Equal(t, user.ID, *events[0].ActorID)
+
+		events = listAuditEvents(t, "action="+domain.AuditImpersonationStarted)
+		require.Len(t, events, 1)
+		assert.Equal(t, "ticket 42", events[0].Details["reason"])
+
+		rr = makeRequest(t, http.MethodGet, "/api/audit/verify", nil)
+		require.Equal(t, http.StatusOK, rr.Code)
+		var verification domain.AuditVerification
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &verification))
+		assert.True(t, verification.Valid)
+	})
+
+	t.Run("users cannot impersonate", func(t *testing.T) {
+		rr := makeRequestAs(t, http.MethodPost, "/api/users/"+adminID+"/impersonate", handlers.ImpersonateRequest{Reason: "testing"}, bearer(t, user))
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+	})
+}
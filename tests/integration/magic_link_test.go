@@ -0,0 +1,72 @@
+package integration
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"testing"
+
+	"UserRESTfulApi/internal/handlers"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var magicLinkCodePattern = regexp.MustCompile(`code (\d{6})`)
+
+// latestMagicLink returns the token and code of the last magic link mailed to the address
+func latestMagicLink(t *testing.T, email string) (string, string) {
+	t.Helper()
+	messages, err := mail.Messages(email)
+	require.NoError(t, err)
+	require.NotEmpty(t, messages)
+	message := messages[len(messages)-1]
+
+	tokenMatch := resetTokenPattern.FindStringSubmatch(message)
+	require.NotNil(t, tokenMatch)
+	linkToken, _ := url.QueryUnescape(tokenMatch[1])
+	codeMatch := magicLinkCodePattern.FindStringSubmatch(message)
+	require.NotNil(t, codeMatch)
+	return linkToken, codeMatch[1]
+}
+
+func TestMagicLinkLogin(t *testing.T) {
+	user := createTestUser(t)
+
+	t.Run("unknown email gets the same response", func(t *testing.T) {
+		known := makeRequest(t, http.MethodPost, "/api/auth/magic-link", handlers.SendMagicLinkRequest{Email: user.Email})
+		unknown := makeRequest(t, http.MethodPost, "/api/auth/magic-link", handlers.SendMagicLinkRequest{Email: "nobody@example.com"})
+		assert.Equal(t, http.StatusAccepted, known.Code)
+		assert.Equal(t, known.Code, unknown.Code)
+		assert.Equal(t, known.Body.String(), unknown.Body.String())
+	})
+
+	t.Run("sign in with the mailed link once", func(t *testing.T) {
+		rr := makeRequest(t, http.MethodPost, "/api/auth/magic-link", handlers.SendMagicLinkRequest{Email: user.Email})
+		require.Equal(t, http.StatusAccepted, rr.Code)
+		linkToken, _ := latestMagicLink(t, user.Email)
+
+		rr = makeRequest(t, http.MethodPost, "/api/auth/magic-link/verify", handlers.MagicLinkLoginRequest{Token: linkToken})
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.NotEmpty(t, jsonField(t, rr, "access_token"))
+		assert.NotEmpty(t, jsonField(t, rr, "refresh_token"))
+
+		rr = makeRequest(t, http.MethodPost, "/api/auth/magic-link/verify", handlers.MagicLinkLoginRequest{Token: linkToken})
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	})
+
+	t.Run("sign in with the mailed code", func(t *testing.T) {
+		rr := makeRequest(t, http.MethodPost, "/api/auth/magic-link", handlers.SendMagicLinkRequest{Email: user.Email})
+		require.Equal(t, http.StatusAccepted, rr.Code)
+		_, code := latestMagicLink(t, user.Email)
+
+		rr = makeRequest(t, http.MethodPost, "/api/auth/magic-link/verify", handlers.MagicLinkLoginRequest{Email: user.Email, Code: code})
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.NotEmpty(t, jsonField(t, rr, "access_token"))
+	})
+
+	t.Run("token or email and code are required", func(t *testing.T) {
+		rr := makeRequest(t, http.MethodPost, "/api/auth/magic-link/verify", handlers.MagicLinkLoginRequest{Email: user.Email})
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+}
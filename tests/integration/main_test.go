@@ -3,10 +3,16 @@ package integration
 import (
 	"UserRESTfulApi/internal"
 	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/handlers"
+	"UserRESTfulApi/internal/middleware"
+	"UserRESTfulApi/pkg/config"
+	"UserRESTfulApi/pkg/mailer"
+	"UserRESTfulApi/pkg/token"
 	"bytes"
 	"encoding/json"
 	"fmt"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"testing"
 	"time"
@@ -20,6 +26,8 @@ import (
 var (
 	router *gin.Engine
 	db     *gorm.DB
+	tokens *token.Manager
+	mail   *mailer.FileMailer
 )
 
 func TestMain(m *testing.M) {
@@ -46,15 +54,60 @@ func TestMain(m *testing.M) {
 		os.Exit(1)
 	}
 
+	// User search relies on the pg_trgm similarity operators
+	if err := db.Exec("CREATE EXTENSION IF NOT EXISTS pg_trgm").Error; err != nil {
+		fmt.Printf("Error creating pg_trgm extension: %v\n", err)
+		os.Exit(1)
+	}
+
 	// Auto migrate the schema
-	err = db.AutoMigrate(&domain.User{})
+	err = db.AutoMigrate(&domain.User{}, &domain.RefreshToken{}, &domain.Role{}, &domain.RolePermission{}, &domain.UserRole{}, &domain.LoginThrottle{}, &domain.PasswordResetToken{}, &domain.EmailVerificationToken{}, &domain.MFAFactor{}, &domain.MFARecoveryCode{}, &domain.MFAChallenge{}, &domain.OAuthClient{}, &domain.AuthorizationCode{}, &domain.OAuthToken{}, &domain.SigningKey{}, &domain.APIKey{}, &domain.AuditEvent{}, &domain.Session{}, &domain.MagicLink{}, &domain.WebAuthnCredential{}, &domain.WebAuthnChallenge{}, &domain.EmailChange{})
 	if err != nil {
 		fmt.Printf("Error migrating database: %v\n", err)
 		os.Exit(1)
 	}
 
+	// Setup auth configuration
+	if os.Getenv("AUTH_JWT_SECRET") == "" {
+		os.Setenv("AUTH_JWT_SECRET", "integration-test-secret")
+	}
+	if os.Getenv("AUTH_MFA_ENCRYPTION_KEY") == "" {
+		os.Setenv("AUTH_MFA_ENCRYPTION_KEY", "aW50ZWdyYXRpb24tdGVzdC1tZmEta2V5LTMyYnl0ZXM=")
+	}
+	if os.Getenv("OIDC_KEY_ENCRYPTION_KEY") == "" {
+		os.Setenv("OIDC_KEY_ENCRYPTION_KEY", "aW50ZWdyYXRpb24tdGVzdC1vaWRjLWtleS0zMmJ5dGU=")
+	}
+	if os.Getenv("AUTH_LOGIN_METHODS") == "" {
+		os.Setenv("AUTH_LOGIN_METHODS", "both")
+	}
+	cfg := config.LoadConfig()
+
+	// Deliver mail to files the tests can read
+	mailDir, err := os.MkdirTemp("", "user-api-mail")
+	if err != nil {
+		fmt.Printf("Error creating mail directory: %v\n", err)
+		os.Exit(1)
+	}
+	cfg.Mail.Driver = mailer.DriverFile
+	cfg.Mail.FileDir = mailDir
+	mail, err = mailer.NewFileMailer(cfg.Mail.From, mailDir)
+	if err != nil {
+		fmt.Printf("Error creating mailer: %v\n", err)
+		os.Exit(1)
+	}
+
+	tokens, err = token.NewManager(cfg.Auth)
+	if err != nil {
+		fmt.Printf("Error creating token manager: %v\n", err)
+		os.Exit(1)
+	}
+
 	// Setup router
-	router = internal.SetupRouter(db)
+	router, err = internal.SetupRouter(db, cfg)
+	if err != nil {
+		fmt.Printf("Error setting up router: %v\n", err)
+		os.Exit(1)
+	}
 
 	// Run tests
 	code := m.Run()
@@ -66,6 +119,7 @@ func TestMain(m *testing.M) {
 		os.Exit(1)
 	}
 	sqlDB.Close()
+	os.RemoveAll(mailDir)
 
 	os.Exit(code)
 }
@@ -78,11 +132,29 @@ func getEnvOrDefault(key, defaultValue string) string {
 }
 
 func clearDatabase() {
+	db.Exec("DELETE FROM refresh_tokens")
+	db.Exec("DELETE FROM user_roles")
+	db.Exec("DELETE FROM login_throttles")
+	db.Exec("DELETE FROM password_reset_tokens")
+	db.Exec("DELETE FROM email_verification_tokens")
+	db.Exec("DELETE FROM email_changes")
+	db.Exec("DELETE FROM mfa_challenges")
+	db.Exec("DELETE FROM mfa_recovery_codes")
+	db.Exec("DELETE FROM mfa_factors")
+	db.Exec("DELETE FROM oauth_tokens")
+	db.Exec("DELETE FROM authorization_codes")
+	db.Exec("DELETE FROM oauth_clients")
+	db.Exec("DELETE FROM api_keys")
+	db.Exec("DELETE FROM audit_events")
+	db.Exec("DELETE FROM sessions")
+	db.Exec("DELETE FROM magic_links")
+	db.Exec("DELETE FROM webauthn_credentials")
+	db.Exec("DELETE FROM webauthn_challenges")
 	db.Exec("DELETE FROM users")
 }
 
 func cleanupDatabase(t *testing.T) {
-	err := db.Exec("TRUNCATE users CASCADE").Error
+	err := db.Exec("TRUNCATE users, refresh_tokens, user_roles, login_throttles, password_reset_tokens, email_verification_tokens, email_changes, mfa_factors, mfa_recovery_codes, mfa_challenges, oauth_clients, authorization_codes, oauth_tokens, api_keys, audit_events, sessions, magic_links, webauthn_credentials, webauthn_challenges CASCADE").Error
 	if err != nil {
 		t.Fatalf("Failed to cleanup database: %v", err)
 	}
@@ -95,7 +167,7 @@ func setupTest(t *testing.T) {
 func createTestUser(t *testing.T) *domain.User {
 	setupTest(t)
 
-	user := &domain.User{
+	user := &handlers.CreateUserRequest{
 		Email:    "test@example.com",
 		Password: "Test@123",
 		Name:     "Test User",
@@ -118,7 +190,7 @@ func createTestUser(t *testing.T) *domain.User {
 func TestCreateUser(t *testing.T) {
 	setupTest(t)
 
-	user := &domain.User{
+	user := &handlers.CreateUserRequest{
 		Email:    "test@example.com",
 		Password: "Test@123",
 		Name:     "Test User",
@@ -131,8 +203,13 @@ func TestCreateUser(t *testing.T) {
 
 	assert.Equal(t, 201, w.Code)
 
+	var raw map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &raw)
+	assert.NoError(t, err)
+	assert.NotContains(t, raw, "password")
+
 	var createdUser domain.User
-	err := json.NewDecoder(w.Body).Decode(&createdUser)
+	err = json.NewDecoder(w.Body).Decode(&createdUser)
 	assert.NoError(t, err)
 	assert.NotZero(t, createdUser.ID)
 	assert.Equal(t, user.Email, createdUser.Email)
@@ -147,6 +224,7 @@ func TestGetUser(t *testing.T) {
 
 	w := httptest.NewRecorder()
 	req := httptest.NewRequest("GET", fmt.Sprintf("/api/users/%d", user.ID), nil)
+	req.Header.Set("Authorization", authHeader(t))
 	router.ServeHTTP(w, req)
 
 	assert.Equal(t, 200, w.Code)
@@ -163,7 +241,7 @@ func TestUpdateUser(t *testing.T) {
 	setupTest(t)
 	user := createTestUser(t)
 
-	updatedUser := &domain.User{
+	updatedUser := &handlers.UpdateUserRequest{
 		Email: "updated@example.com",
 		Name:  "Updated User",
 	}
@@ -171,6 +249,7 @@ func TestUpdateUser(t *testing.T) {
 	w := httptest.NewRecorder()
 	body, _ := json.Marshal(updatedUser)
 	req := httptest.NewRequest("PUT", fmt.Sprintf("/api/users/%d", user.ID), bytes.NewReader(body))
+	req.Header.Set("Authorization", authHeader(t))
 	router.ServeHTTP(w, req)
 
 	assert.Equal(t, 200, w.Code)
@@ -179,8 +258,28 @@ func TestUpdateUser(t *testing.T) {
 	err := json.NewDecoder(w.Body).Decode(&responseUser)
 	assert.NoError(t, err)
 	assert.Equal(t, user.ID, responseUser.ID)
-	assert.Equal(t, updatedUser.Email, responseUser.Email)
 	assert.Equal(t, updatedUser.Name, responseUser.Name)
+	// The new address stays pending until it is confirmed
+	assert.Equal(t, user.Email, responseUser.Email)
+	assert.Equal(t, updatedUser.Email, responseUser.PendingEmail)
+
+	w = httptest.NewRecorder()
+	body, _ = json.Marshal(handlers.VerifyEmailRequest{Token: lastMailedToken(t, updatedUser.Email)})
+	req = httptest.NewRequest("POST", "/api/auth/verify-email", bytes.NewReader(body))
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, 200, w.Code)
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", fmt.Sprintf("/api/users/%d", user.ID), nil)
+	req.Header.Set("Authorization", authHeader(t))
+	router.ServeHTTP(w, req)
+
+	responseUser = domain.User{}
+	err = json.NewDecoder(w.Body).Decode(&responseUser)
+	assert.NoError(t, err)
+	assert.Equal(t, updatedUser.Email, responseUser.Email)
+	assert.Empty(t, responseUser.PendingEmail)
 }
 
 func TestDeleteUser(t *testing.T) {
@@ -189,6 +288,7 @@ func TestDeleteUser(t *testing.T) {
 
 	w := httptest.NewRecorder()
 	req := httptest.NewRequest("DELETE", fmt.Sprintf("/api/users/%d", user.ID), nil)
+	req.Header.Set("Authorization", authHeader(t))
 	router.ServeHTTP(w, req)
 
 	assert.Equal(t, 200, w.Code)
@@ -196,6 +296,7 @@ func TestDeleteUser(t *testing.T) {
 	// Verify user is deleted
 	w = httptest.NewRecorder()
 	req = httptest.NewRequest("GET", fmt.Sprintf("/api/users/%d", user.ID), nil)
+	req.Header.Set("Authorization", authHeader(t))
 	router.ServeHTTP(w, req)
 
 	assert.Equal(t, 404, w.Code)
@@ -206,7 +307,7 @@ func TestListUsers(t *testing.T) {
 
 	// Create multiple users
 	for i := 0; i < 3; i++ {
-		user := &domain.User{
+		user := &handlers.CreateUserRequest{
 			Email:    fmt.Sprintf("test%d@example.com", i),
 			Password: "Test@123",
 			Name:     fmt.Sprintf("Test User %d", i),
@@ -220,17 +321,32 @@ func TestListUsers(t *testing.T) {
 		assert.Equal(t, 201, w.Code)
 	}
 
-	// Test listing users
+	// Page through the three created users plus the test principal
 	w := httptest.NewRecorder()
-	req := httptest.NewRequest("GET", "/api/users", nil)
+	req := httptest.NewRequest("GET", "/api/users?limit=3", nil)
+	req.Header.Set("Authorization", authHeader(t))
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, 200, w.Code)
+
+	var page handlers.UserPageResponse
+	err := json.NewDecoder(w.Body).Decode(&page)
+	assert.NoError(t, err)
+	assert.Len(t, page.Data, 3)
+	assert.NotEmpty(t, page.NextCursor)
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", "/api/users?limit=3&after="+url.QueryEscape(page.NextCursor), nil)
+	req.Header.Set("Authorization", authHeader(t))
 	router.ServeHTTP(w, req)
 
 	assert.Equal(t, 200, w.Code)
 
-	var users []*domain.User
-	err := json.NewDecoder(w.Body).Decode(&users)
+	var next handlers.UserPageResponse
+	err = json.NewDecoder(w.Body).Decode(&next)
 	assert.NoError(t, err)
-	assert.Len(t, users, 3)
+	assert.Len(t, next.Data, 1)
+	assert.Empty(t, next.NextCursor)
 }
 
 func TestCreateUserValidation(t *testing.T) {
@@ -238,12 +354,12 @@ func TestCreateUserValidation(t *testing.T) {
 
 	testCases := []struct {
 		name     string
-		user     domain.User
+		user     handlers.CreateUserRequest
 		wantCode int
 	}{
 		{
 			name: "Invalid Email",
-			user: domain.User{
+			user: handlers.CreateUserRequest{
 				Email:    "invalid-email",
 				Password: "Test@123",
 				Name:     "Test User",
@@ -252,7 +368,7 @@ func TestCreateUserValidation(t *testing.T) {
 		},
 		{
 			name: "Weak Password",
-			user: domain.User{
+			user: handlers.CreateUserRequest{
 				Email:    "test@example.com",
 				Password: "weak",
 				Name:     "Test User",
@@ -261,7 +377,7 @@ func TestCreateUserValidation(t *testing.T) {
 		},
 		{
 			name: "Empty Name",
-			user: domain.User{
+			user: handlers.CreateUserRequest{
 				Email:    "test@example.com",
 				Password: "Test@123",
 				Name:     "",
@@ -279,10 +395,13 @@ func TestCreateUserValidation(t *testing.T) {
 
 			assert.Equal(t, tc.wantCode, w.Code)
 
-			var response map[string]string
-			err := json.NewDecoder(w.Body).Decode(&response)
+			assert.Equal(t, middleware.ProblemContentType, w.Header().Get("Content-Type"))
+			var problem middleware.ProblemDetails
+			err := json.NewDecoder(w.Body).Decode(&problem)
 			assert.NoError(t, err)
-			assert.Contains(t, response, "error")
+			assert.Equal(t, tc.wantCode, problem.Status)
+			assert.NotEmpty(t, problem.Code)
+			assert.NotEmpty(t, problem.Detail)
 		})
 	}
 }
@@ -291,7 +410,7 @@ func TestDuplicateEmail(t *testing.T) {
 	setupTest(t)
 
 	// Create first user
-	user := &domain.User{
+	user := &handlers.CreateUserRequest{
 		Email:    "test@example.com",
 		Password: "Test@123",
 		Name:     "Test User",
@@ -311,9 +430,10 @@ func TestDuplicateEmail(t *testing.T) {
 
 	assert.Equal(t, 409, w.Code)
 
-	var response map[string]string
-	err := json.NewDecoder(w.Body).Decode(&response)
+	var problem middleware.ProblemDetails
+	err := json.NewDecoder(w.Body).Decode(&problem)
 	assert.NoError(t, err)
-	assert.Contains(t, response, "error")
-	assert.Contains(t, response["error"], "already registered")
+	assert.Equal(t, "duplicate_email", problem.Code)
+	assert.Equal(t, "/api/problems/duplicate_email", problem.Type)
+	assert.Contains(t, problem.Detail, "already registered")
 }
@@ -0,0 +1,64 @@
+package integration
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"UserRESTfulApi/internal/handlers"
+	"UserRESTfulApi/pkg/totp"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMFA(t *testing.T) {
+	user := createTestUser(t)
+	credentials := map[string]string{"email": user.Email, "password": "Test@123"}
+
+	rr := makeRequestAs(t, http.MethodPost, "/api/auth/mfa/enroll", nil, bearer(t, user))
+	require.Equal(t, http.StatusOK, rr.Code)
+	secret := jsonField(t, rr, "secret")
+	require.NotEmpty(t, secret)
+	assert.NotEmpty(t, jsonField(t, rr, "qr_code_png"))
+
+	code, err := totp.Code(secret, totp.Step(time.Now()))
+	require.NoError(t, err)
+	rr = makeRequestAs(t, http.MethodPost, "/api/auth/mfa/confirm", handlers.ConfirmMFARequest{Code: code}, bearer(t, user))
+	require.Equal(t, http.StatusOK, rr.Code)
+	var confirmed struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &confirmed))
+	require.NotEmpty(t, confirmed.RecoveryCodes)
+
+	t.Run("login requires a second factor", func(t *testing.T) {
+		rr := makeRequest(t, http.MethodPost, "/api/auth/login", credentials)
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), `"mfa_required":true`)
+		assert.NotContains(t, rr.Body.String(), "access_token")
+		mfaToken := jsonField(t, rr, "mfa_token")
+
+		rr = makeRequest(t, http.MethodPost, "/api/auth/mfa/verify", handlers.VerifyMFARequest{MFAToken: mfaToken, Code: "000000"})
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+		rr = makeRequest(t, http.MethodPost, "/api/auth/mfa/verify", handlers.VerifyMFARequest{MFAToken: mfaToken, Code: confirmed.RecoveryCodes[0]})
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.NotEmpty(t, jsonField(t, rr, "access_token"))
+	})
+
+	t.Run("admin reset", func(t *testing.T) {
+		path := fmt.Sprintf("/api/users/%d/mfa", user.ID)
+		rr := makeRequestAs(t, http.MethodDelete, path, nil, bearer(t, user))
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+
+		rr = makeRequest(t, http.MethodDelete, path, nil)
+		require.Equal(t, http.StatusOK, rr.Code)
+
+		rr = makeRequest(t, http.MethodPost, "/api/auth/login", credentials)
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.NotEmpty(t, jsonField(t, rr, "access_token"))
+	})
+}
@@ -0,0 +1,143 @@
+package integration
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"UserRESTfulApi/internal/handlers"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// postForm makes a form encoded request authenticated with the client's credentials
+func postForm(path string, form url.Values, clientID, secret string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(clientID), url.QueryEscape(secret))
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestOIDCAuthorizationCodeFlow(t *testing.T) {
+	user := createTestUser(t)
+	const redirectURI = "https://app.example.com/callback"
+
+	rr := makeRequest(t, http.MethodPost, "/api/oauth/clients", handlers.CreateOAuthClientRequest{
+		Name:         "Internal App",
+		RedirectURIs: []string{redirectURI},
+	})
+	require.Equal(t, http.StatusCreated, rr.Code)
+	var registered struct {
+		Client struct {
+			ClientID string `json:"client_id"`
+		} `json:"client"`
+		ClientSecret string `json:"client_secret"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &registered))
+	clientID, secret := registered.Client.ClientID, registered.ClientSecret
+	require.NotEmpty(t, clientID)
+	require.NotEmpty(t, secret)
+
+	rr = makeRequestAs(t, http.MethodGet, "/api/oauth/clients", nil, bearer(t, user))
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	rr = makeRequest(t, http.MethodGet, "/.well-known/openid-configuration", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.NotEmpty(t, jsonField(t, rr, "issuer"))
+	assert.Contains(t, rr.Body.String(), `"code_challenge_methods_supported":["S256"]`)
+
+	verifier := strings.Repeat("verifier", 6)
+	sum := sha256.Sum256([]byte(verifier))
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {clientID},
+		"redirect_uri":          {redirectURI},
+		"scope":                 {"openid email profile offline_access"},
+		"state":                 {"xyz"},
+		"nonce":                 {"abc"},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(sum[:])},
+		"code_challenge_method": {"S256"},
+	}
+
+	rr = makeRequest(t, http.MethodGet, "/oauth2/authorize?"+params.Encode(), nil)
+	require.Equal(t, http.StatusFound, rr.Code)
+	assert.Contains(t, rr.Header().Get("Location"), "client_id="+url.QueryEscape(clientID))
+
+	rr = makeRequest(t, http.MethodGet, "/oauth2/authorize?client_id=unknown&redirect_uri=https://evil.example.com", nil)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	approval := map[string]string{}
+	for name := range params {
+		approval[name] = params.Get(name)
+	}
+	rr = makeRequestAs(t, http.MethodPost, "/oauth2/authorize", approval, bearer(t, user))
+	require.Equal(t, http.StatusOK, rr.Code)
+	redirectTo, err := url.Parse(jsonField(t, rr, "redirect_to"))
+	require.NoError(t, err)
+	assert.Equal(t, "xyz", redirectTo.Query().Get("state"))
+	code := redirectTo.Query().Get("code")
+	require.NotEmpty(t, code)
+
+	exchange := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectURI},
+		"code_verifier": {verifier},
+	}
+	rr = postForm("/oauth2/token", exchange, clientID, "wrong")
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	rr = postForm("/oauth2/token", exchange, clientID, secret)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "no-store", rr.Header().Get("Cache-Control"))
+	accessToken := jsonField(t, rr, "access_token")
+	refreshToken := jsonField(t, rr, "refresh_token")
+	assert.NotEmpty(t, jsonField(t, rr, "id_token"))
+	require.NotEmpty(t, refreshToken)
+
+	rr = postForm("/oauth2/token", exchange, clientID, secret)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, "invalid_grant", jsonField(t, rr, "error"))
+
+	// The reused code revoked the grant, so start a new one
+	rr = makeRequestAs(t, http.MethodPost, "/oauth2/authorize", approval, bearer(t, user))
+	require.Equal(t, http.StatusOK, rr.Code)
+	redirectTo, _ = url.Parse(jsonField(t, rr, "redirect_to"))
+	exchange.Set("code", redirectTo.Query().Get("code"))
+	rr = postForm("/oauth2/token", exchange, clientID, secret)
+	require.Equal(t, http.StatusOK, rr.Code)
+	accessToken = jsonField(t, rr, "access_token")
+	refreshToken = jsonField(t, rr, "refresh_token")
+
+	rr = makeRequestAs(t, http.MethodGet, "/oauth2/userinfo", nil, "Bearer "+accessToken)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, user.Email, jsonField(t, rr, "email"))
+	assert.Equal(t, user.Name, jsonField(t, rr, "name"))
+
+	rr = makeRequest(t, http.MethodGet, "/oauth2/jwks", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"kty":"RSA"`)
+
+	rr = postForm("/oauth2/introspect", url.Values{"token": {accessToken}}, clientID, secret)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"active":true`)
+
+	rr = postForm("/oauth2/revoke", url.Values{"token": {refreshToken}}, clientID, secret)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	rr = postForm("/oauth2/introspect", url.Values{"token": {accessToken}}, clientID, secret)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"active":false`)
+
+	rr = makeRequestAs(t, http.MethodGet, "/oauth2/userinfo", nil, "Bearer "+accessToken)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.Contains(t, rr.Header().Get("WWW-Authenticate"), "invalid_token")
+}
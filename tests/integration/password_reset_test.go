@@ -0,0 +1,60 @@
+package integration
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"UserRESTfulApi/internal/handlers"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var resetTokenPattern = regexp.MustCompile(`token=([A-Za-z0-9_%-]+)`)
+
+func TestPasswordReset(t *testing.T) {
+	user := createTestUser(t)
+
+	t.Run("unknown email gets the same response", func(t *testing.T) {
+		known := makeRequest(t, http.MethodPost, "/api/auth/password/forgot", handlers.ForgotPasswordRequest{Email: user.Email})
+		unknown := makeRequest(t, http.MethodPost, "/api/auth/password/forgot", handlers.ForgotPasswordRequest{Email: "nobody@example.com"})
+		assert.Equal(t, http.StatusAccepted, known.Code)
+		assert.Equal(t, known.Code, unknown.Code)
+		assert.Equal(t, known.Body.String(), unknown.Body.String())
+	})
+
+	t.Run("reset with the mailed token", func(t *testing.T) {
+		// The reset email is sent in the background after the response
+		var messages []string
+		require.Eventually(t, func() bool {
+			messages, _ = mail.Messages(user.Email)
+			return len(messages) > 0
+		}, 5*time.Second, 10*time.Millisecond)
+		match := resetTokenPattern.FindStringSubmatch(messages[len(messages)-1])
+		require.NotNil(t, match)
+		resetToken, _ := url.QueryUnescape(match[1])
+
+		// A login session that must not survive the reset
+		rr := makeRequest(t, http.MethodPost, "/api/auth/login", map[string]string{"email": user.Email, "password": "Test@123"})
+		require.Equal(t, http.StatusOK, rr.Code)
+		refreshToken := jsonField(t, rr, "refresh_token")
+
+		rr = makeRequest(t, http.MethodPost, "/api/auth/password/reset", handlers.ResetPasswordRequest{Token: resetToken, Password: "short"})
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+		rr = makeRequest(t, http.MethodPost, "/api/auth/password/reset", handlers.ResetPasswordRequest{Token: resetToken, Password: "NewPass@456"})
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		rr = makeRequest(t, http.MethodPost, "/api/auth/password/reset", handlers.ResetPasswordRequest{Token: resetToken, Password: "Other@789x"})
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+		rr = makeRequest(t, http.MethodPost, "/api/auth/refresh", map[string]string{"refresh_token": refreshToken})
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+		rr = makeRequest(t, http.MethodPost, "/api/auth/login", map[string]string{"email": user.Email, "password": "NewPass@456"})
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+}
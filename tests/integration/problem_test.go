@@ -0,0 +1,86 @@
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"UserRESTfulApi/internal/errors"
+	"UserRESTfulApi/internal/middleware"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// decodeProblem checks that the response is an RFC 7807 problem and decodes it
+func decodeProblem(t *testing.T, rr *httptest.ResponseRecorder, wantStatus int) middleware.ProblemDetails {
+	t.Helper()
+	require.Equal(t, wantStatus, rr.Code, rr.Body.String())
+	assert.Equal(t, middleware.ProblemContentType, rr.Header().Get("Content-Type"))
+	var problem middleware.ProblemDetails
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &problem))
+	assert.Equal(t, wantStatus, problem.Status)
+	assert.Equal(t, middleware.ProblemTypeBase+problem.Code, problem.Type)
+	assert.NotEmpty(t, problem.Title)
+	assert.Equal(t, rr.Header().Get("X-Request-ID"), problem.RequestID)
+	return problem
+}
+
+func TestProblemResponses(t *testing.T) {
+	user := createTestUser(t)
+
+	t.Run("validation errors name the JSON fields", func(t *testing.T) {
+		rr := makeRequest(t, http.MethodPost, "/api/auth/login", map[string]string{"password": "Test@123"})
+		problem := decodeProblem(t, rr, http.StatusBadRequest)
+		assert.Equal(t, "invalid_input", problem.Code)
+		assert.Equal(t, "/api/auth/login", problem.Instance)
+		assert.Equal(t, []errors.ErrorDetail{{Field: "email", Code: "required", Message: "is required"}}, problem.Errors)
+
+		rr = makeRequest(t, http.MethodPost, "/api/auth/login", map[string]interface{}{"email": 42, "password": "Test@123"})
+		problem = decodeProblem(t, rr, http.StatusBadRequest)
+		require.Len(t, problem.Errors, 1)
+		assert.Equal(t, "email", problem.Errors[0].Field)
+		assert.Equal(t, "type", problem.Errors[0].Code)
+	})
+
+	t.Run("invalid parameters", func(t *testing.T) {
+		problem := decodeProblem(t, makeRequest(t, http.MethodGet, "/api/users/abc", nil), http.StatusBadRequest)
+		require.Len(t, problem.Errors, 1)
+		assert.Equal(t, "id", problem.Errors[0].Field)
+
+		problem = decodeProblem(t, makeRequest(t, http.MethodGet, "/api/users?sort=password", nil), http.StatusBadRequest)
+		require.Len(t, problem.Errors, 1)
+		assert.Equal(t, "sort", problem.Errors[0].Field)
+	})
+
+	t.Run("authentication and authorization", func(t *testing.T) {
+		problem := decodeProblem(t, makeRequestAs(t, http.MethodGet, "/api/users", nil, ""), http.StatusUnauthorized)
+		assert.Equal(t, "unauthorized", problem.Code)
+
+		problem = decodeProblem(t, makeRequestAs(t, http.MethodGet, "/api/users", nil, bearer(t, user)), http.StatusForbidden)
+		assert.Equal(t, "forbidden", problem.Code)
+	})
+
+	t.Run("unknown routes", func(t *testing.T) {
+		problem := decodeProblem(t, makeRequest(t, http.MethodGet, "/api/no-such-route", nil), http.StatusNotFound)
+		assert.Equal(t, "not_found", problem.Code)
+		assert.Equal(t, "/api/no-such-route", problem.Instance)
+	})
+
+	t.Run("problem types resolve to the catalog", func(t *testing.T) {
+		rr := makeRequest(t, http.MethodGet, "/api/problems", nil)
+		require.Equal(t, http.StatusOK, rr.Code)
+		var catalog []errors.Problem
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &catalog))
+		assert.Equal(t, errors.Catalog(), catalog)
+
+		rr = makeRequest(t, http.MethodGet, middleware.ProblemTypeBase+"duplicate_email", nil)
+		require.Equal(t, http.StatusOK, rr.Code)
+		var problem errors.Problem
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &problem))
+		assert.Equal(t, http.StatusConflict, problem.Status)
+
+		decodeProblem(t, makeRequest(t, http.MethodGet, middleware.ProblemTypeBase+"no_such_problem", nil), http.StatusNotFound)
+	})
+}
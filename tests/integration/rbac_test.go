@@ -0,0 +1,69 @@
+package integration
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"UserRESTfulApi/internal/handlers"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoleBasedAccess(t *testing.T) {
+	user := createTestUser(t)
+	admin := ensureTestPrincipal(t)
+	asUser := bearer(t, user)
+
+	t.Run("user can read and update only their own record", func(t *testing.T) {
+		rr := makeRequestAs(t, http.MethodGet, fmt.Sprintf("/api/users/%d", user.ID), nil, asUser)
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		rr = makeRequestAs(t, http.MethodPut, fmt.Sprintf("/api/users/%d", user.ID), handlers.UpdateUserRequest{
+			Email: user.Email,
+			Name:  "Renamed User",
+		}, asUser)
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		rr = makeRequestAs(t, http.MethodGet, fmt.Sprintf("/api/users/%d", admin.ID), nil, asUser)
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+
+		rr = makeRequestAs(t, http.MethodPut, fmt.Sprintf("/api/users/%d", admin.ID), handlers.UpdateUserRequest{
+			Email: admin.Email,
+			Name:  "Hijacked",
+		}, asUser)
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+	})
+
+	t.Run("user cannot list or delete users or manage roles", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, makeRequestAs(t, http.MethodGet, "/api/users", nil, asUser).Code)
+		assert.Equal(t, http.StatusForbidden, makeRequestAs(t, http.MethodDelete, fmt.Sprintf("/api/users/%d", user.ID), nil, asUser).Code)
+		assert.Equal(t, http.StatusForbidden, makeRequestAs(t, http.MethodGet, "/api/roles", nil, asUser).Code)
+		assert.Equal(t, http.StatusForbidden, makeRequestAs(t, http.MethodPost, fmt.Sprintf("/api/users/%d/roles", user.ID),
+			handlers.AssignRoleRequest{Role: "admin"}, asUser).Code)
+	})
+
+	t.Run("custom role grants its permissions", func(t *testing.T) {
+		rr := makeRequest(t, http.MethodPost, "/api/roles", map[string]interface{}{
+			"name":        "auditor",
+			"permissions": []string{"users:read", "users:list"},
+		})
+		assert.Equal(t, http.StatusCreated, rr.Code)
+
+		rr = makeRequest(t, http.MethodPost, fmt.Sprintf("/api/users/%d/roles", user.ID), handlers.AssignRoleRequest{Role: "auditor"})
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		assert.Equal(t, http.StatusOK, makeRequestAs(t, http.MethodGet, "/api/users", nil, asUser).Code)
+		assert.Equal(t, http.StatusOK, makeRequestAs(t, http.MethodGet, fmt.Sprintf("/api/users/%d", admin.ID), nil, asUser).Code)
+		assert.Equal(t, http.StatusForbidden, makeRequestAs(t, http.MethodDelete, fmt.Sprintf("/api/users/%d", admin.ID), nil, asUser).Code)
+
+		rr = makeRequest(t, http.MethodDelete, "/api/roles/auditor", nil)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, http.StatusForbidden, makeRequestAs(t, http.MethodGet, "/api/users", nil, asUser).Code)
+	})
+
+	t.Run("built-in roles cannot be deleted", func(t *testing.T) {
+		rr := makeRequest(t, http.MethodDelete, "/api/roles/admin", nil)
+		assert.Equal(t, http.StatusConflict, rr.Code)
+	})
+}
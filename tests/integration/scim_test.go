@@ -0,0 +1,86 @@
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"UserRESTfulApi/internal/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSCIMProvisioning(t *testing.T) {
+	setupTest(t)
+	defer cleanupDatabase(t)
+
+	rr := makeRequestAs(t, http.MethodGet, "/scim/v2/ServiceProviderConfig", nil, "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/scim+json", rr.Header().Get("Content-Type"))
+	assert.Contains(t, rr.Body.String(), `"patch":{"supported":true}`)
+
+	rr = makeRequestAs(t, http.MethodGet, "/scim/v2/Users", nil, "")
+	require.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.Equal(t, "401", jsonField(t, rr, "status"))
+	assert.Contains(t, rr.Body.String(), domain.SCIMErrorSchema)
+
+	rr = makeRequest(t, http.MethodPost, "/scim/v2/Users", map[string]interface{}{
+		"schemas":  []string{domain.SCIMUserSchema},
+		"userName": "bjensen@example.com",
+		"name":     map[string]string{"givenName": "Barbara", "familyName": "Jensen"},
+		"password": "Password123!",
+	})
+	require.Equal(t, http.StatusCreated, rr.Code)
+	id := jsonField(t, rr, "id")
+	require.NotEmpty(t, id)
+	assert.Equal(t, "Barbara Jensen", jsonField(t, rr, "displayName"))
+	assert.Equal(t, "http://localhost:8080/scim/v2/Users/"+id, rr.Header().Get("Location"))
+
+	rr = makeRequest(t, http.MethodPost, "/scim/v2/Users", map[string]interface{}{
+		"userName":    "bjensen@example.com",
+		"displayName": "Duplicate",
+	})
+	require.Equal(t, http.StatusConflict, rr.Code)
+	assert.Equal(t, domain.SCIMUniqueness, jsonField(t, rr, "scimType"))
+
+	filter := url.QueryEscape(`userName eq "BJENSEN@example.com" and emails.value co "example"`)
+	rr = makeRequest(t, http.MethodGet, "/scim/v2/Users?filter="+filter, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var list struct {
+		TotalResults int64             `json:"totalResults"`
+		Resources    []domain.SCIMUser `json:"Resources"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &list))
+	require.EqualValues(t, 1, list.TotalResults)
+	assert.Equal(t, id, list.Resources[0].ID)
+
+	rr = makeRequest(t, http.MethodGet, "/scim/v2/Users?filter="+url.QueryEscape(`title eq "x"`), nil)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, domain.SCIMInvalidFilter, jsonField(t, rr, "scimType"))
+
+	// The user can sign in until it is deactivated
+	rr = makeRequestAs(t, http.MethodPost, "/api/auth/login", map[string]string{"email": "bjensen@example.com", "password": "Password123!"}, "")
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	rr = makeRequest(t, http.MethodPatch, "/scim/v2/Users/"+id, map[string]interface{}{
+		"schemas":    []string{domain.SCIMPatchOpSchema},
+		"Operations": []map[string]interface{}{{"op": "Replace", "path": "active", "value": false}},
+	})
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"active":false`)
+
+	rr = makeRequestAs(t, http.MethodPost, "/api/auth/login", map[string]string{"email": "bjensen@example.com", "password": "Password123!"}, "")
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	rr = makeRequest(t, http.MethodGet, "/scim/v2/Users?filter="+url.QueryEscape(`active eq false`), nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"totalResults":1`)
+
+	rr = makeRequest(t, http.MethodDelete, "/scim/v2/Users/"+id, nil)
+	require.Equal(t, http.StatusNoContent, rr.Code)
+	rr = makeRequest(t, http.MethodGet, "/scim/v2/Users/"+id, nil)
+	require.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Equal(t, "404", jsonField(t, rr, "status"))
+}
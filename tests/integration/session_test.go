@@ -0,0 +1,84 @@
+package integration
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/handlers"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// loginFrom signs in the test user from a browser with the given User-Agent
+// and returns the issued tokens
+func loginFrom(t *testing.T, userAgent string) domain.AccessToken {
+	body, _ := json.Marshal(handlers.LoginRequest{Email: "test@example.com", Password: "Test@123"})
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/login", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var issued domain.AccessToken
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &issued))
+	return issued
+}
+
+func TestSessions(t *testing.T) {
+	user := createTestUser(t)
+	sessionsPath := "/api/users/" + strconv.FormatUint(uint64(user.ID), 10) + "/sessions"
+
+	laptop := loginFrom(t, "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:127.0) Gecko/20100101 Firefox/127.0")
+	phone := loginFrom(t, "Mozilla/5.0 (iPhone; CPU iPhone OS 17_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.5 Mobile/15E148 Safari/604.1")
+
+	rr := makeRequestAs(t, http.MethodGet, sessionsPath, nil, "Bearer "+laptop.AccessToken)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var sessions []domain.Session
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &sessions))
+	require.Len(t, sessions, 2)
+
+	var current, other domain.Session
+	for _, session := range sessions {
+		if session.Current {
+			current = session
+		} else {
+			other = session
+		}
+	}
+	assert.Equal(t, "Firefox", current.Browser)
+	assert.Equal(t, "Windows", current.OS)
+	assert.Equal(t, "Safari", other.Browser)
+	assert.Equal(t, "mobile", other.DeviceType)
+	assert.NotEmpty(t, other.ClientIP)
+
+	// Revoking a session rejects its access and refresh tokens at once
+	rr = makeRequestAs(t, http.MethodDelete, sessionsPath+"/"+other.ID, nil, "Bearer "+laptop.AccessToken)
+	require.Equal(t, http.StatusOK, rr.Code)
+	rr = makeRequestAs(t, http.MethodGet, sessionsPath, nil, "Bearer "+phone.AccessToken)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	rr = makeRequestAs(t, http.MethodPost, "/api/auth/refresh", handlers.RefreshRequest{RefreshToken: phone.RefreshToken}, "")
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	rr = makeRequestAs(t, http.MethodDelete, sessionsPath+"/"+other.ID, nil, "Bearer "+laptop.AccessToken)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	// Users cannot see the sessions of others
+	rr = makeRequestAs(t, http.MethodGet, "/api/users/"+strconv.FormatUint(uint64(ensureTestPrincipal(t).ID), 10)+"/sessions", nil, "Bearer "+laptop.AccessToken)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	// Administrators can force a user to log out everywhere
+	rr = makeRequest(t, http.MethodDelete, sessionsPath, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	rr = makeRequestAs(t, http.MethodGet, sessionsPath, nil, "Bearer "+laptop.AccessToken)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	rr = makeRequest(t, http.MethodGet, sessionsPath, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &sessions))
+	assert.Empty(t, sessions)
+}
@@ -1,14 +1,77 @@
 package integration
 
 import (
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/pkg/password"
+	"UserRESTfulApi/pkg/token"
 	"bytes"
 	"encoding/json"
 	"net/http/httptest"
 	"testing"
+
+	"gorm.io/gorm"
+)
+
+const (
+	testPrincipalEmail    = "principal@example.com"
+	testPrincipalPassword = "Principal123!"
 )
 
-// makeRequest is a helper function to make HTTP requests in tests
+// ensureTestPrincipal creates the admin user that authenticated test requests act as
+func ensureTestPrincipal(t *testing.T) *domain.User {
+	var principal domain.User
+	err := db.Where("email = ?", testPrincipalEmail).First(&principal).Error
+	if err == nil {
+		return &principal
+	}
+	if err != gorm.ErrRecordNotFound {
+		t.Fatalf("Failed to look up test principal: %v", err)
+	}
+
+	hashed, err := password.Hash(testPrincipalPassword)
+	if err != nil {
+		t.Fatalf("Failed to hash test principal password: %v", err)
+	}
+	principal = domain.User{
+		Email:    testPrincipalEmail,
+		Password: hashed,
+		Name:     "Test Principal",
+	}
+	if err := db.Create(&principal).Error; err != nil {
+		t.Fatalf("Failed to create test principal: %v", err)
+	}
+
+	// The built-in roles are synced by the router on startup
+	err = db.Exec("INSERT INTO user_roles (user_id, role_id, created_at) SELECT ?, id, NOW() FROM roles WHERE name = ?",
+		principal.ID, domain.RoleAdmin).Error
+	if err != nil {
+		t.Fatalf("Failed to grant admin role to test principal: %v", err)
+	}
+	return &principal
+}
+
+// authHeader returns an Authorization header value for the test principal
+func authHeader(t *testing.T) string {
+	return bearer(t, ensureTestPrincipal(t))
+}
+
+// bearer returns an Authorization header value for the given user, as if it
+// signed in with a second factor so roles requiring MFA apply
+func bearer(t *testing.T, user *domain.User) string {
+	accessToken, _, err := tokens.Issue(user.ID, user.Email, "", token.MethodPassword, token.MethodOTP, token.MethodMFA)
+	if err != nil {
+		t.Fatalf("Failed to issue access token: %v", err)
+	}
+	return "Bearer " + accessToken
+}
+
+// makeRequest is a helper function to make HTTP requests in tests as the test principal
 func makeRequest(t *testing.T, method, path string, body interface{}) *httptest.ResponseRecorder {
+	return makeRequestAs(t, method, path, body, authHeader(t))
+}
+
+// makeRequestAs makes an HTTP request with the given Authorization header
+func makeRequestAs(t *testing.T, method, path string, body interface{}, authorization string) *httptest.ResponseRecorder {
 	var reqBody []byte
 	var err error
 	
@@ -22,7 +85,18 @@ func makeRequest(t *testing.T, method, path string, body interface{}) *httptest.
 	w := httptest.NewRecorder()
 	req := httptest.NewRequest(method, path, bytes.NewReader(reqBody))
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", authorization)
 	
 	router.ServeHTTP(w, req)
 	return w
 }
+
+// jsonField decodes the response body and returns a string field of it
+func jsonField(t *testing.T, rr *httptest.ResponseRecorder, field string) string {
+	var body map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to decode response body: %v", err)
+	}
+	value, _ := body[field].(string)
+	return value
+}
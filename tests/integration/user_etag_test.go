@@ -0,0 +1,83 @@
+package integration
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"UserRESTfulApi/internal/handlers"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// makeConditionalRequest makes an HTTP request as the test admin with extra headers
+func makeConditionalRequest(t *testing.T, method, path string, body interface{}, headers map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	var reqBody []byte
+	if body != nil {
+		var err error
+		reqBody, err = json.Marshal(body)
+		require.NoError(t, err)
+	}
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(method, path, bytes.NewReader(reqBody))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", authHeader(t))
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestUserETags(t *testing.T) {
+	user := createTestUser(t)
+	path := fmt.Sprintf("/api/users/%d", user.ID)
+
+	rr := makeRequest(t, http.MethodGet, path, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	etag := rr.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+
+	t.Run("unchanged user is not modified", func(t *testing.T) {
+		rr := makeConditionalRequest(t, http.MethodGet, path, nil, map[string]string{"If-None-Match": etag})
+		assert.Equal(t, http.StatusNotModified, rr.Code)
+		assert.Empty(t, rr.Body.String())
+	})
+
+	t.Run("update with the current version", func(t *testing.T) {
+		update := handlers.UpdateUserRequest{Email: user.Email, Name: "First Admin"}
+		rr := makeConditionalRequest(t, http.MethodPut, path, update, map[string]string{"If-Match": etag})
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.NotEqual(t, etag, rr.Header().Get("ETag"))
+
+		rr = makeConditionalRequest(t, http.MethodGet, path, nil, map[string]string{"If-None-Match": etag})
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+
+	t.Run("stale version is rejected", func(t *testing.T) {
+		update := handlers.UpdateUserRequest{Email: user.Email, Name: "Second Admin"}
+		rr := makeConditionalRequest(t, http.MethodPut, path, update, map[string]string{"If-Match": etag})
+		assert.Equal(t, http.StatusPreconditionFailed, rr.Code)
+
+		rr = patchUser(t, user.ID, "application/merge-patch+json", `{"name":"Second Admin"}`, authHeader(t))
+		require.Equal(t, http.StatusOK, rr.Code)
+		rr = makeConditionalRequest(t, http.MethodDelete, path, nil, map[string]string{"If-Match": etag})
+		assert.Equal(t, http.StatusPreconditionFailed, rr.Code)
+		rr = makeConditionalRequest(t, http.MethodDelete, path, nil, map[string]string{"If-Match": `W/"1"`})
+		assert.Equal(t, http.StatusPreconditionFailed, rr.Code)
+	})
+
+	t.Run("delete with the current version", func(t *testing.T) {
+		rr := makeRequest(t, http.MethodGet, path, nil)
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "Second Admin", jsonField(t, rr, "name"))
+
+		rr = makeConditionalRequest(t, http.MethodDelete, path, nil, map[string]string{"If-Match": rr.Header().Get("ETag")})
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+}
@@ -0,0 +1,167 @@
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"UserRESTfulApi/internal/handlers"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// listUsers lists users with the query string
+func listUsers(t *testing.T, query string) (handlers.UserPageResponse, http.Header) {
+	t.Helper()
+	rr := makeRequest(t, http.MethodGet, "/api/users?"+query, nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var page handlers.UserPageResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &page))
+	return page, rr.Header()
+}
+
+// pageEmails returns the emails of the users on a page, in order
+func pageEmails(page handlers.UserPageResponse) []string {
+	emails := make([]string, 0, len(page.Data))
+	for _, user := range page.Data {
+		emails = append(emails, user.Email)
+	}
+	return emails
+}
+
+// listUserEmails lists users with the query string and returns their emails in order
+func listUserEmails(t *testing.T, query string) []string {
+	t.Helper()
+	page, _ := listUsers(t, query)
+	return pageEmails(page)
+}
+
+func TestListUsersQuery(t *testing.T) {
+	setupTest(t)
+	ensureTestPrincipal(t)
+	start := time.Now().UTC().Add(-time.Second)
+
+	for _, user := range []handlers.CreateUserRequest{
+		{Email: "jonathan@example.com", Password: "Test@123", Name: "Jonathan Harker"},
+		{Email: "mina@example.com", Password: "Test@123", Name: "Mina Murray"},
+		{Email: "lucy@example.org", Password: "Test@123", Name: "Lucy Westenra"},
+	} {
+		rr := makeRequest(t, http.MethodPost, "/api/users", user)
+		require.Equal(t, http.StatusCreated, rr.Code)
+	}
+	require.NoError(t, db.Exec("UPDATE users SET created_at = ? WHERE email = ?", start.Add(-48*time.Hour), "lucy@example.org").Error)
+
+	t.Run("default order is by ID", func(t *testing.T) {
+		emails := listUserEmails(t, "")
+		assert.Equal(t, []string{testPrincipalEmail, "jonathan@example.com", "mina@example.com", "lucy@example.org"}, emails)
+	})
+
+	t.Run("filters", func(t *testing.T) {
+		assert.Equal(t, []string{"mina@example.com"}, listUserEmails(t, "filter[email]=MINA@example.com"))
+		assert.Equal(t, []string{"lucy@example.org"}, listUserEmails(t, "filter[email][ends_with]=.org"))
+
+		query := url.Values{"filter[created_at][gte]": {start.Format(time.RFC3339)}, "filter[name][contains]": {"ar"}, "sort": {"-name"}}
+		assert.Equal(t, []string{"mina@example.com", "jonathan@example.com"}, listUserEmails(t, query.Encode()))
+	})
+
+	t.Run("sort", func(t *testing.T) {
+		emails := listUserEmails(t, "sort=-created_at,name&filter[name][ne]=Test%20Principal")
+		assert.Equal(t, "lucy@example.org", emails[len(emails)-1])
+		assert.Equal(t, []string{"jonathan@example.com", "lucy@example.org", "mina@example.com", testPrincipalEmail}, listUserEmails(t, "sort=email"))
+	})
+
+	t.Run("fuzzy search", func(t *testing.T) {
+		assert.Equal(t, []string{"jonathan@example.com"}, listUserEmails(t, "q=jonathn"))
+		assert.Equal(t, []string{"lucy@example.org"}, listUserEmails(t, "q=westenra"))
+		assert.Contains(t, listUserEmails(t, "q=mu"), "mina@example.com")
+	})
+
+	t.Run("invalid parameters", func(t *testing.T) {
+		for _, query := range []string{
+			"filter[password]=x",
+			"filter[email][gt]=a",
+			"filter[id]=1%20OR%201=1",
+			"filter[created_at][gte]=yesterday",
+			"filter=email",
+			"filter[email]]=a",
+			"sort=password",
+			"sort=name%3BDROP%20TABLE%20users",
+			"sort=id,name",
+		} {
+			rr := makeRequest(t, http.MethodGet, "/api/users?"+query, nil)
+			assert.Equal(t, http.StatusBadRequest, rr.Code, query)
+		}
+	})
+}
+
+func TestListUsersCursors(t *testing.T) {
+	setupTest(t)
+	ensureTestPrincipal(t)
+	for _, user := range []handlers.CreateUserRequest{
+		{Email: "a@example.com", Password: "Test@123", Name: "Same Name"},
+		{Email: "b@example.com", Password: "Test@123", Name: "Same Name"},
+		{Email: "c@example.com", Password: "Test@123", Name: "Same Name"},
+		{Email: "d@example.com", Password: "Test@123", Name: "Other Name"},
+	} {
+		rr := makeRequest(t, http.MethodPost, "/api/users", user)
+		require.Equal(t, http.StatusCreated, rr.Code)
+	}
+
+	t.Run("walk forward and back", func(t *testing.T) {
+		// Equal names are ordered by ID, so no user is skipped or repeated
+		first, header := listUsers(t, "sort=-name&limit=2&total=exact")
+		assert.Equal(t, []string{testPrincipalEmail, "a@example.com"}, pageEmails(first))
+		require.NotEmpty(t, first.NextCursor)
+		assert.Empty(t, first.PrevCursor)
+		require.NotNil(t, first.Total)
+		assert.Equal(t, int64(5), *first.Total)
+		assert.Contains(t, header.Get("Link"), `rel="next"`)
+		assert.NotContains(t, header.Get("Link"), `rel="prev"`)
+
+		// A user inserted before the cursor does not shift the next page
+		rr := makeRequest(t, http.MethodPost, "/api/users", handlers.CreateUserRequest{Email: "z@example.com", Password: "Test@123", Name: "Zed"})
+		require.Equal(t, http.StatusCreated, rr.Code)
+
+		second, header := listUsers(t, "sort=-name&limit=2&after="+url.QueryEscape(first.NextCursor))
+		assert.Equal(t, []string{"b@example.com", "c@example.com"}, pageEmails(second))
+		assert.Nil(t, second.Total)
+		assert.Contains(t, header.Get("Link"), "/api/users?after=")
+		assert.Contains(t, header.Get("Link"), `rel="prev"`)
+
+		third, _ := listUsers(t, "sort=-name&limit=2&after="+url.QueryEscape(second.NextCursor))
+		assert.Equal(t, []string{"d@example.com"}, pageEmails(third))
+		assert.Empty(t, third.NextCursor)
+
+		back, _ := listUsers(t, "sort=-name&limit=2&before="+url.QueryEscape(second.PrevCursor))
+		assert.Equal(t, []string{testPrincipalEmail, "a@example.com"}, pageEmails(back))
+	})
+
+	t.Run("estimated total", func(t *testing.T) {
+		page, _ := listUsers(t, "total=estimate")
+		require.NotNil(t, page.Total)
+		assert.True(t, page.TotalEstimated)
+	})
+
+	t.Run("page sizes are capped", func(t *testing.T) {
+		page, _ := listUsers(t, "limit=100000")
+		assert.Len(t, page.Data, 6)
+		rr := makeRequest(t, http.MethodGet, "/api/users?limit=0", nil)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("cursors cannot be forged or reused", func(t *testing.T) {
+		first, _ := listUsers(t, "limit=2")
+		for _, query := range []string{
+			"after=not-a-cursor",
+			"sort=name&after=" + url.QueryEscape(first.NextCursor),
+			"after=" + url.QueryEscape(first.NextCursor) + "&before=" + url.QueryEscape(first.NextCursor),
+			"total=all",
+		} {
+			rr := makeRequest(t, http.MethodGet, "/api/users?"+query, nil)
+			assert.Equal(t, http.StatusBadRequest, rr.Code, query)
+		}
+	})
+}
@@ -0,0 +1,61 @@
+package integration
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"UserRESTfulApi/internal/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// patchUser sends a PATCH request with the raw body and content type
+func patchUser(t *testing.T, id uint, contentType, body, authorization string) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPatch, fmt.Sprintf("/api/users/%d", id), strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Authorization", authorization)
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestPatchUser(t *testing.T) {
+	user := createTestUser(t)
+
+	t.Run("merge patch changes only the name", func(t *testing.T) {
+		rr := patchUser(t, user.ID, domain.MergePatchContentType, `{"name":"Patched User"}`, bearer(t, user))
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "Patched User", jsonField(t, rr, "name"))
+		assert.Equal(t, user.Email, jsonField(t, rr, "email"))
+
+		// The password was kept
+		rr = makeRequest(t, http.MethodPost, "/api/auth/login", map[string]string{"email": user.Email, "password": "Test@123"})
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+
+	t.Run("json patch test guards against lost updates", func(t *testing.T) {
+		patch := `[{"op":"test","path":"/name","value":"Patched User"},{"op":"replace","path":"/name","value":"Tested User"}]`
+		rr := patchUser(t, user.ID, domain.JSONPatchContentType, patch, bearer(t, user))
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "Tested User", jsonField(t, rr, "name"))
+
+		rr = patchUser(t, user.ID, domain.JSONPatchContentType, patch, bearer(t, user))
+		assert.Equal(t, http.StatusConflict, rr.Code)
+	})
+
+	t.Run("invalid patches are rejected", func(t *testing.T) {
+		rr := patchUser(t, user.ID, domain.MergePatchContentType, `{"email":"not an email"}`, bearer(t, user))
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		rr = patchUser(t, user.ID, domain.JSONPatchContentType, `[{"op":"remove","path":"/nickname"}]`, bearer(t, user))
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+		rr = patchUser(t, user.ID, "application/json", `{"name":"Plain JSON"}`, bearer(t, user))
+		assert.Equal(t, http.StatusUnsupportedMediaType, rr.Code)
+		assert.Contains(t, rr.Header().Get("Accept-Patch"), domain.MergePatchContentType)
+	})
+}
@@ -7,13 +7,14 @@ import (
 	"testing"
 
 	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/handlers"
 )
 
 func TestUserAPI(t *testing.T) {
 	setupTest(t)
 
 	t.Run("Create User Flow", func(t *testing.T) {
-		testUser := domain.User{
+		testUser := handlers.CreateUserRequest{
 			Email:    "create@example.com",
 			Password: "Test123!@#",
 			Name:     "Test User",
@@ -57,7 +58,7 @@ func TestUserAPI(t *testing.T) {
 
 	t.Run("Update User Flow", func(t *testing.T) {
 		// Create user first
-		testUser := domain.User{
+		testUser := handlers.CreateUserRequest{
 			Email:    "update@example.com",
 			Password: "Test123!@#",
 			Name:     "Test User",
@@ -72,8 +73,7 @@ func TestUserAPI(t *testing.T) {
 		userID := uint(response["id"].(float64))
 
 		// Update user
-		updatedUser := domain.User{
-			ID:       userID,
+		updatedUser := handlers.UpdateUserRequest{
 			Email:    "updated@example.com",
 			Password: "UpdatedTest123!@#",
 			Name:     "Updated User",
@@ -92,14 +92,35 @@ func TestUserAPI(t *testing.T) {
 			t.Fatalf("Failed to unmarshal response: %v", err)
 		}
 
-		if user.Email != updatedUser.Email {
-			t.Errorf("handler returned wrong email: got %v want %v", user.Email, updatedUser.Email)
+		// The new address stays pending until it is confirmed
+		if user.Email != testUser.Email {
+			t.Errorf("handler returned wrong email: got %v want %v", user.Email, testUser.Email)
+		}
+		if user.PendingEmail != updatedUser.Email {
+			t.Errorf("handler returned wrong pending email: got %v want %v", user.PendingEmail, updatedUser.Email)
+		}
+
+		// Confirm the new address
+		rr = makeRequest(t, http.MethodPost, "/api/auth/verify-email", handlers.VerifyEmailRequest{Token: lastMailedToken(t, updatedUser.Email)})
+		if rr.Code != http.StatusOK {
+			t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+		}
+
+		rr = makeRequest(t, http.MethodGet, fmt.Sprintf("/api/users/%d", userID), nil)
+		user = domain.User{}
+		err = json.Unmarshal(rr.Body.Bytes(), &user)
+		if err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+
+		if user.Email != updatedUser.Email || user.PendingEmail != "" {
+			t.Errorf("handler returned wrong email after confirming: got %v (pending %q) want %v", user.Email, user.PendingEmail, updatedUser.Email)
 		}
 	})
 
 	t.Run("Delete User Flow", func(t *testing.T) {
 		// Create user first
-		testUser := domain.User{
+		testUser := handlers.CreateUserRequest{
 			Email:    "delete@example.com",
 			Password: "Test123!@#",
 			Name:     "Test User",
@@ -129,7 +150,7 @@ func TestUserAPI(t *testing.T) {
 	t.Run("List Users Flow", func(t *testing.T) {
 		// Create multiple users
 		for i := 0; i < 3; i++ {
-			user := domain.User{
+			user := handlers.CreateUserRequest{
 				Email:    fmt.Sprintf("list%d@example.com", i),
 				Password: "Test123!@#",
 				Name:     fmt.Sprintf("Test User %d", i),
@@ -138,25 +159,25 @@ func TestUserAPI(t *testing.T) {
 		}
 
 		// Get user list
-		rr := makeRequest(t, http.MethodGet, "/api/users?page=1&limit=10", nil)
+		rr := makeRequest(t, http.MethodGet, "/api/users?limit=10", nil)
 		if rr.Code != http.StatusOK {
 			t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
 		}
 
-		var users []*domain.User
-		err := json.Unmarshal(rr.Body.Bytes(), &users)
+		var page handlers.UserPageResponse
+		err := json.Unmarshal(rr.Body.Bytes(), &page)
 		if err != nil {
 			t.Fatalf("Failed to unmarshal response: %v", err)
 		}
 
-		if len(users) < 3 {
-			t.Errorf("handler returned wrong number of users: got %v want at least 3", len(users))
+		if len(page.Data) < 3 {
+			t.Errorf("handler returned wrong number of users: got %v want at least 3", len(page.Data))
 		}
 	})
 
 	t.Run("Invalid Input Tests", func(t *testing.T) {
 		// Test invalid email
-		invalidUser := domain.User{
+		invalidUser := handlers.CreateUserRequest{
 			Email:    "invalid-email",
 			Password: "Test123!@#",
 			Name:     "Test User",
@@ -167,7 +188,7 @@ func TestUserAPI(t *testing.T) {
 		}
 
 		// Test invalid password
-		invalidUser = domain.User{
+		invalidUser = handlers.CreateUserRequest{
 			Email:    "test@example.com",
 			Password: "weak",
 			Name:     "Test User",
@@ -178,7 +199,7 @@ func TestUserAPI(t *testing.T) {
 		}
 
 		// Test empty name
-		invalidUser = domain.User{
+		invalidUser = handlers.CreateUserRequest{
 			Email:    "test@example.com",
 			Password: "Test123!@#",
 			Name:     "",
@@ -189,12 +210,12 @@ func TestUserAPI(t *testing.T) {
 		}
 
 		// Test duplicate email
-		makeRequest(t, http.MethodPost, "/api/users", domain.User{
+		makeRequest(t, http.MethodPost, "/api/users", handlers.CreateUserRequest{
 			Email:    "duplicate@example.com",
 			Password: "Test123!@#",
 			Name:     "Test User",
 		})
-		rr = makeRequest(t, http.MethodPost, "/api/users", domain.User{
+		rr = makeRequest(t, http.MethodPost, "/api/users", handlers.CreateUserRequest{
 			Email:    "duplicate@example.com",
 			Password: "Test123!@#",
 			Name:     "Test User",
@@ -0,0 +1,116 @@
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"UserRESTfulApi/internal/domain"
+	"UserRESTfulApi/internal/handlers"
+	"UserRESTfulApi/pkg/webauthn"
+	"UserRESTfulApi/pkg/webauthn/webauthntest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// registerPasskey registers a passkey on the authenticator for the signed in user
+func registerPasskey(t *testing.T, authenticator *webauthntest.Authenticator, authorization string) domain.WebAuthnCredential {
+	t.Helper()
+	rr := makeRequestAs(t, http.MethodPost, "/api/auth/webauthn/register/begin", nil, authorization)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var options domain.WebAuthnCreationOptions
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &options))
+
+	attestation, err := authenticator.Register(options.Challenge, options.User.ID, webauthn.FormatPacked, false)
+	require.NoError(t, err)
+	rr = makeRequestAs(t, http.MethodPost, "/api/auth/webauthn/register/finish", map[string]interface{}{
+		"name":       "Test key",
+		"credential": attestation,
+	}, authorization)
+	require.Equal(t, http.StatusCreated, rr.Code)
+
+	var credential domain.WebAuthnCredential
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &credential))
+	return credential
+}
+
+// signPasskey answers the request options returned by a begin endpoint
+func signPasskey(t *testing.T, authenticator *webauthntest.Authenticator, rr []byte, credentialID string) *webauthntest.Assertion {
+	t.Helper()
+	var options domain.WebAuthnRequestOptions
+	require.NoError(t, json.Unmarshal(rr, &options))
+	assertion, err := authenticator.Login(options.Challenge, credentialID)
+	require.NoError(t, err)
+	return assertion
+}
+
+func TestPasskeys(t *testing.T) {
+	user := createTestUser(t)
+	authenticator, err := webauthntest.New(getEnvOrDefault("AUTH_WEBAUTHN_RP_ID", "localhost"), "http://localhost:8080")
+	require.NoError(t, err)
+
+	credential := registerPasskey(t, authenticator, bearer(t, user))
+	assert.Equal(t, "Test key", credential.Name)
+	assert.Equal(t, webauthn.AttestationBasic, credential.AttestationType)
+
+	t.Run("sign in with a passkey", func(t *testing.T) {
+		rr := makeRequest(t, http.MethodPost, "/api/auth/webauthn/login/begin", nil)
+		require.Equal(t, http.StatusOK, rr.Code)
+		assertion := signPasskey(t, authenticator, rr.Body.Bytes(), credential.CredentialID)
+
+		rr = makeRequest(t, http.MethodPost, "/api/auth/webauthn/login/finish", assertion)
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.NotEmpty(t, jsonField(t, rr, "access_token"))
+
+		// The challenge was used up
+		rr = makeRequest(t, http.MethodPost, "/api/auth/webauthn/login/finish", assertion)
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	})
+
+	t.Run("cloned authenticator is rejected", func(t *testing.T) {
+		authenticator.SetSignCount(credential.CredentialID, 0)
+		rr := makeRequest(t, http.MethodPost, "/api/auth/webauthn/login/begin", nil)
+		require.Equal(t, http.StatusOK, rr.Code)
+		assertion := signPasskey(t, authenticator, rr.Body.Bytes(), credential.CredentialID)
+
+		rr = makeRequest(t, http.MethodPost, "/api/auth/webauthn/login/finish", assertion)
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	})
+
+	t.Run("passkey as second factor", func(t *testing.T) {
+		authenticator.SetSignCount(credential.CredentialID, 10)
+		rr := makeRequest(t, http.MethodPost, "/api/auth/login", handlers.LoginRequest{Email: user.Email, Password: "Test@123"})
+		require.Equal(t, http.StatusOK, rr.Code)
+		mfaToken := jsonField(t, rr, "mfa_token")
+		require.NotEmpty(t, mfaToken)
+		assert.Contains(t, rr.Body.String(), domain.MFAMethodWebAuthn)
+
+		rr = makeRequest(t, http.MethodPost, "/api/auth/webauthn/mfa/begin", handlers.PasskeyMFARequest{MFAToken: mfaToken})
+		require.Equal(t, http.StatusOK, rr.Code)
+		assertion := signPasskey(t, authenticator, rr.Body.Bytes(), credential.CredentialID)
+
+		rr = makeRequest(t, http.MethodPost, "/api/auth/webauthn/mfa/finish", map[string]interface{}{
+			"mfa_token":  mfaToken,
+			"credential": assertion,
+		})
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.NotEmpty(t, jsonField(t, rr, "access_token"))
+	})
+
+	t.Run("list and delete passkeys", func(t *testing.T) {
+		rr := makeRequestAs(t, http.MethodGet, "/api/auth/webauthn/credentials", nil, bearer(t, user))
+		require.Equal(t, http.StatusOK, rr.Code)
+		var credentials []domain.WebAuthnCredential
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &credentials))
+		require.Len(t, credentials, 1)
+		assert.NotNil(t, credentials[0].LastUsedAt)
+
+		path := "/api/auth/webauthn/credentials/" + strconv.FormatUint(uint64(credential.ID), 10)
+		rr = makeRequestAs(t, http.MethodDelete, path, nil, bearer(t, user))
+		assert.Equal(t, http.StatusOK, rr.Code)
+		rr = makeRequestAs(t, http.MethodDelete, path, nil, bearer(t, user))
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+}